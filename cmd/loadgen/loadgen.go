@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/elad-da/dynamopagination/internal/loadtest"
+	"golang.org/x/time/rate"
+)
+
+// loadgen plays sessions against the service
+type loadgen struct {
+	client   *http.Client
+	target   string
+	header   http.Header
+	limiter  *rate.Limiter
+	pageSize int
+	// maxRequests stops the run after this many requests when positive
+	maxRequests int64
+	sent        atomic.Int64
+}
+
+// result is the outcome of one request of a session
+type result struct {
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// run plays the sessions it receives with the given number of workers until in is closed, ctx is done
+// or the request budget is spent
+func (l *loadgen) run(ctx context.Context, in <-chan session, workers int) <-chan result {
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for s := range in {
+				if !l.play(ctx, s, results) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// play sends the requests of a session: one for its page, or one per page down to its depth when it
+// follows cursors. It reports false when the run is over.
+func (l *loadgen) play(ctx context.Context, s session, results chan<- result) bool {
+	query := s.query(l.pageSize)
+	if !s.cursor {
+		query.Set("page", strconv.Itoa(s.depth))
+	} else {
+		query.Set("cursor", "")
+	}
+	for page := 1; page <= s.depth; page++ {
+		if l.maxRequests > 0 && l.sent.Add(1) > l.maxRequests {
+			return false
+		}
+		if err := l.limiter.Wait(ctx); err != nil {
+			return false
+		}
+		req, err := http.NewRequest(http.MethodGet, l.target+"/paginate?"+query.Encode(), nil)
+		if err != nil {
+			results <- result{err: err}
+			return true
+		}
+		for name, values := range l.header {
+			req.Header[name] = values
+		}
+		var body bytes.Buffer
+		status, latency, err := loadtest.Send(ctx, l.client, req, &body)
+		results <- result{status: status, latency: latency, err: err}
+		if !s.cursor || err != nil || status != http.StatusOK {
+			return true
+		}
+
+		var page struct {
+			NextCursor string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(body.Bytes(), &page); err != nil || page.NextCursor == "" {
+			return true
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+// pagingServer serves three pages of every partition, continued by cursors, and records the queries
+type pagingServer struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (p *pagingServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	p.queries = append(p.queries, req.URL.RawQuery)
+	p.mu.Unlock()
+
+	page := struct {
+		NextCursor string `json:"next_cursor,omitempty"`
+	}{}
+	if cursor := req.URL.Query().Get("cursor"); cursor != "3" {
+		n, _ := strconv.Atoi(cursor)
+		page.NextCursor = strconv.Itoa(n + 1)
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func TestLoadgenPlay(t *testing.T) {
+	server := &pagingServer{}
+	target := httptest.NewServer(server)
+	defer target.Close()
+	l := &loadgen{client: target.Client(), target: target.URL, limiter: rate.NewLimiter(rate.Inf, 1), pageSize: 5}
+
+	play := func(s session) []result {
+		server.queries = nil
+		results := make(chan result, 10)
+		assert.True(t, l.play(context.Background(), s, results))
+		close(results)
+		var all []result
+		for res := range results {
+			all = append(all, res)
+		}
+		return all
+	}
+
+	results := play(session{partition: "test", depth: 7})
+	assert.Len(t, results, 1)
+	assert.Equal(t, http.StatusOK, results[0].status)
+	assert.Equal(t, []string{"key_condition=test&page=7&pagesize=5"}, server.queries)
+
+	// Cursor sessions stop at their depth, or at the last page
+	assert.Len(t, play(session{partition: "test", depth: 2, cursor: true, search: "item"}), 2)
+	assert.Equal(t, []string{
+		"cursor=&key_condition=test&pagesize=5&search=item",
+		"cursor=1&key_condition=test&pagesize=5&search=item",
+	}, server.queries)
+	assert.Len(t, play(session{partition: "test", depth: 10, cursor: true}), 4)
+}
+
+func TestLoadgenRequestBudget(t *testing.T) {
+	target := httptest.NewServer(&pagingServer{})
+	defer target.Close()
+	l := &loadgen{client: target.Client(), target: target.URL, limiter: rate.NewLimiter(rate.Inf, 1), pageSize: 5, maxRequests: 5}
+
+	sessions := make(chan session, 10)
+	for i := 0; i < 10; i++ {
+		sessions <- session{partition: "test", depth: 1}
+	}
+	close(sessions)
+	var sent int
+	for range l.run(context.Background(), sessions, 3) {
+		sent++
+	}
+	assert.Equal(t, 5, sent)
+}
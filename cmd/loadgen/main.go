@@ -0,0 +1,102 @@
+// Command loadgen sends synthetic pagination traffic to the service and reports the latencies of its
+// responses, so performance changes can be measured the same way every time. The partitions read, how
+// deep sessions page, how many follow cursors and how many search are configurable; the same seed
+// generates the same traffic.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/elad-da/dynamopagination/internal/loadtest"
+	"golang.org/x/time/rate"
+)
+
+func main() {
+	target := flag.String("target", "http://localhost:8080", "base URL of the service")
+	rps := flag.Float64("rate", 20, "requests per second, 0 for no limit")
+	workers := flag.Int("concurrency", 4, "sessions played at once")
+	duration := flag.Duration("duration", 30*time.Second, "how long to send traffic")
+	requests := flag.Int64("requests", 0, "stop after this many requests, 0 for no limit")
+	seed := flag.Int64("seed", 1, "seed of the generated traffic")
+	partitions := flag.String("partitions", "test", "comma separated partitions to read")
+	skew := flag.Float64("skew", 0, "Zipf exponent over the partitions, greater than 1 to make the first ones hot; 0 reads them uniformly")
+	meanPage := flag.Float64("mean-page", 2, "mean page sessions read down to")
+	maxPage := flag.Int("max-page", 50, "deepest page a session reads")
+	cursorRate := flag.Float64("cursor-rate", 0.5, "share of sessions that follow cursors instead of requesting a page number")
+	searchRate := flag.Float64("search-rate", 0.1, "share of sessions that search")
+	searches := flag.String("searches", "item", "comma separated search terms")
+	pageSize := flag.Int("pagesize", 10, "page size of the requests")
+	apiKey := flag.String("api-key", "", "API key to send with every request")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of each request")
+	flag.Parse()
+
+	if *workers <= 0 {
+		log.Fatal("-concurrency must be positive")
+	}
+	g, err := newGenerator(mix{
+		Partitions: strings.Split(*partitions, ","),
+		Skew:       *skew,
+		MeanPage:   *meanPage,
+		MaxPage:    *maxPage,
+		CursorRate: *cursorRate,
+		SearchRate: *searchRate,
+		Searches:   strings.Split(*searches, ","),
+	}, *seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	limit := rate.Inf
+	if *rps > 0 {
+		limit = rate.Limit(*rps)
+	}
+	l := &loadgen{
+		client:      &http.Client{Timeout: *timeout},
+		target:      strings.TrimSuffix(*target, "/"),
+		header:      http.Header{},
+		limiter:     rate.NewLimiter(limit, 1),
+		pageSize:    *pageSize,
+		maxRequests: *requests,
+	}
+	if *apiKey != "" {
+		l.header.Set("X-Api-Key", *apiKey)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithTimeout(ctx, *duration)
+	defer cancel()
+
+	sessions := make(chan session)
+	go func() {
+		defer close(sessions)
+		for {
+			select {
+			case sessions <- g.next():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	rep := loadtest.NewReport()
+	for res := range l.run(ctx, sessions, *workers) {
+		// Requests cut short by the end of the run aren't failures of the service
+		if res.err != nil && ctx.Err() != nil {
+			continue
+		}
+		rep.Add(res.status, res.latency, res.err)
+	}
+	elapsed := time.Since(start)
+	fmt.Printf("elapsed %s, %.1f requests/s\n", elapsed.Round(time.Millisecond), float64(rep.Sent)/elapsed.Seconds())
+	rep.Write(os.Stdout)
+}
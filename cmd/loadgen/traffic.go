@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"strconv"
+)
+
+// mix describes the traffic to generate. Traffic is made of sessions: a client reading one partition
+// down to some page, either by asking for that page number or by following cursors page by page.
+type mix struct {
+	Partitions []string
+	// Skew is the exponent of a Zipf distribution over Partitions, in the order given, so the first
+	// ones are hot; 0 picks partitions uniformly
+	Skew float64
+	// MeanPage is the mean page a session reads down to. Depths are geometric, so most sessions stop
+	// at the first pages and a few go deep.
+	MeanPage float64
+	// MaxPage caps the depth of sessions
+	MaxPage int
+	// CursorRate is the share of sessions that follow cursors instead of requesting a page number
+	CursorRate float64
+	// SearchRate is the share of sessions searching their partition for one of Searches
+	SearchRate float64
+	Searches   []string
+}
+
+func (m mix) validate() error {
+	switch {
+	case len(m.Partitions) == 0:
+		return fmt.Errorf("no partitions to read")
+	case m.Skew != 0 && m.Skew <= 1:
+		return fmt.Errorf("skew must be 0 or greater than 1, got %v", m.Skew)
+	case m.MeanPage < 1:
+		return fmt.Errorf("mean page must be at least 1, got %v", m.MeanPage)
+	case m.MaxPage < 1:
+		return fmt.Errorf("max page must be at least 1, got %d", m.MaxPage)
+	case m.CursorRate < 0 || m.CursorRate > 1:
+		return fmt.Errorf("cursor rate must be between 0 and 1, got %v", m.CursorRate)
+	case m.SearchRate < 0 || m.SearchRate > 1:
+		return fmt.Errorf("search rate must be between 0 and 1, got %v", m.SearchRate)
+	case m.SearchRate > 0 && len(m.Searches) == 0:
+		return fmt.Errorf("no searches for a search rate of %v", m.SearchRate)
+	}
+	return nil
+}
+
+// session is a client reading a partition down to a page
+type session struct {
+	partition string
+	depth     int
+	cursor    bool
+	search    string
+}
+
+// query returns the parameters of the session's requests, without their page or cursor
+func (s session) query(pageSize int) url.Values {
+	q := url.Values{"key_condition": {s.partition}, "pagesize": {strconv.Itoa(pageSize)}}
+	if s.search != "" {
+		q.Set("search", s.search)
+	}
+	return q
+}
+
+// generator draws the sessions of a mix. The same seed draws the same sessions, so runs can be
+// compared. It isn't safe for concurrent use.
+type generator struct {
+	mix  mix
+	rand *rand.Rand
+	zipf *rand.Zipf
+}
+
+func newGenerator(m mix, seed int64) (*generator, error) {
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	g := &generator{mix: m, rand: rand.New(rand.NewSource(seed))}
+	if m.Skew > 0 {
+		g.zipf = rand.NewZipf(g.rand, m.Skew, 1, uint64(len(m.Partitions)-1))
+	}
+	return g, nil
+}
+
+func (g *generator) next() session {
+	s := session{depth: g.depth()}
+	if g.zipf != nil {
+		s.partition = g.mix.Partitions[g.zipf.Uint64()]
+	} else {
+		s.partition = g.mix.Partitions[g.rand.Intn(len(g.mix.Partitions))]
+	}
+	s.cursor = g.rand.Float64() < g.mix.CursorRate
+	if g.rand.Float64() < g.mix.SearchRate {
+		s.search = g.mix.Searches[g.rand.Intn(len(g.mix.Searches))]
+	}
+	return s
+}
+
+// depth draws a page from a geometric distribution with the mean page of the mix
+func (g *generator) depth() int {
+	if g.mix.MeanPage <= 1 {
+		return 1
+	}
+	p := 1 / g.mix.MeanPage
+	depth := 1 + int(math.Log(1-g.rand.Float64())/math.Log(1-p))
+	if depth > g.mix.MaxPage {
+		return g.mix.MaxPage
+	}
+	return depth
+}
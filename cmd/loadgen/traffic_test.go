@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMix() mix {
+	return mix{Partitions: []string{"hot", "warm", "cold"}, MeanPage: 3, MaxPage: 20, CursorRate: 0.5, SearchRate: 0.2, Searches: []string{"item"}}
+}
+
+func TestGenerator(t *testing.T) {
+	draw := func(m mix, seed int64, n int) []session {
+		g, err := newGenerator(m, seed)
+		require.NoError(t, err)
+		sessions := make([]session, n)
+		for i := range sessions {
+			sessions[i] = g.next()
+		}
+		return sessions
+	}
+
+	// The same seed draws the same traffic
+	sessions := draw(testMix(), 7, 10000)
+	assert.Equal(t, sessions, draw(testMix(), 7, 10000))
+	assert.NotEqual(t, sessions, draw(testMix(), 8, 10000))
+
+	partitions := map[string]int{}
+	var depths, cursors, searches int
+	for _, s := range sessions {
+		partitions[s.partition]++
+		depths += s.depth
+		assert.True(t, s.depth >= 1 && s.depth <= 20)
+		if s.cursor {
+			cursors++
+		}
+		if s.search != "" {
+			searches++
+		}
+	}
+	assert.Len(t, partitions, 3)
+	assert.InDelta(t, 3, float64(depths)/10000, 0.2)
+	assert.InDelta(t, 5000, cursors, 300)
+	assert.InDelta(t, 2000, searches, 300)
+
+	// A skewed mix favors the first partitions
+	m := testMix()
+	m.Skew = 2
+	partitions = map[string]int{}
+	for _, s := range draw(m, 7, 10000) {
+		partitions[s.partition]++
+	}
+	assert.Greater(t, partitions["hot"], partitions["warm"]+partitions["cold"])
+	assert.Greater(t, partitions["warm"], partitions["cold"])
+}
+
+func TestMixValidate(t *testing.T) {
+	require.NoError(t, testMix().validate())
+
+	invalid := []func(*mix){
+		func(m *mix) { m.Partitions = nil },
+		func(m *mix) { m.Skew = 0.5 },
+		func(m *mix) { m.MeanPage = 0 },
+		func(m *mix) { m.MaxPage = 0 },
+		func(m *mix) { m.CursorRate = 2 },
+		func(m *mix) { m.Searches = nil },
+	}
+	for _, change := range invalid {
+		m := testMix()
+		change(&m)
+		assert.Error(t, m.validate())
+	}
+}
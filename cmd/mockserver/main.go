@@ -0,0 +1,35 @@
+// Command mockserver serves the pagination API from fixture data instead of DynamoDB, so consumers can
+// develop and test against it without AWS access or production data. The environment variables of the
+// service still apply.
+package main
+
+import (
+	"flag"
+	"log"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	fixtures := flag.String("fixtures", "", "fixture file to serve")
+	generate := flag.Int("generate", 0, "serve this many generated items per partition instead of a fixture file")
+	partitions := flag.String("partitions", "test", "comma separated partitions of the generated items")
+	flag.Parse()
+
+	opts := server.Options{Addr: *addr, Fixtures: *fixtures}
+	switch {
+	case *generate > 0:
+		// Generated items carry the key attributes configured through the environment
+		server.ConfigureTable(opts)
+		fixture := server.GenerateFixture(strings.Split(*partitions, ","), *generate)
+		opts.Fixture = &fixture
+	case *fixtures == "":
+		log.Fatal("mockserver needs -fixtures or -generate")
+	}
+
+	if err := server.Run(opts); err != nil {
+		log.Fatal(err)
+	}
+}
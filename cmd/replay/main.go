@@ -0,0 +1,87 @@
+// Command replay reads the access logs of the service and replays their requests against a target
+// environment at a controlled rate, for load tests and for checking behavior changes against the shape
+// of real traffic. It reports the statuses and latencies of the replayed requests, and the requests
+// answered with another status than the one logged.
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func main() {
+	target := flag.String("target", "", "base URL of the environment to replay against, e.g. http://localhost:8080")
+	rps := flag.Float64("rate", 10, "requests per second, 0 for no limit")
+	workers := flag.Int("concurrency", 4, "requests in flight at once")
+	minLatency := flag.Duration("min-latency", 0, "only replay requests that took at least this long, to replay slow queries")
+	prefix := flag.String("prefix", "", "only replay requests whose path starts with this prefix")
+	apiKey := flag.String("api-key", "", "API key to send with every request")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout of each request")
+	flag.Parse()
+
+	if *target == "" {
+		log.Fatal("replay needs -target")
+	}
+	if *workers <= 0 {
+		log.Fatal("-concurrency must be positive")
+	}
+	limit := rate.Inf
+	if *rps > 0 {
+		limit = rate.Limit(*rps)
+	}
+	r := &replayer{
+		client:  &http.Client{Timeout: *timeout},
+		target:  strings.TrimSuffix(*target, "/"),
+		header:  http.Header{},
+		limiter: rate.NewLimiter(limit, 1),
+	}
+	if *apiKey != "" {
+		r.header.Set("X-Api-Key", *apiKey)
+	}
+
+	// Logs are read from the files given, or from standard input
+	var input io.Reader = os.Stdin
+	if flag.NArg() > 0 {
+		readers := make([]io.Reader, 0, flag.NArg())
+		for _, name := range flag.Args() {
+			f, err := os.Open(name)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+		input = io.MultiReader(readers...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	records := make(chan record)
+	var stats readStats
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(records)
+		var err error
+		stats, err = readRecords(ctx, input, filter{MinLatency: *minLatency, Prefix: *prefix}, records)
+		readErr <- err
+	}()
+
+	rep := newReport()
+	for res := range r.replay(ctx, records, *workers) {
+		rep.add(res)
+	}
+	if err := <-readErr; err != nil && ctx.Err() == nil {
+		log.Fatal(err)
+	}
+	rep.write(os.Stdout, stats)
+}
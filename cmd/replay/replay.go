@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elad-da/dynamopagination/internal/loadtest"
+	"golang.org/x/time/rate"
+)
+
+// record is a request read from the access log
+type record struct {
+	Method  string `json:"method"`
+	URI     string `json:"uri"`
+	Status  int    `json:"status"`
+	Latency int64  `json:"latency"`
+}
+
+// filter selects the records to replay
+type filter struct {
+	// MinLatency skips requests served faster, to replay only slow queries
+	MinLatency time.Duration
+	// Prefix skips requests whose URI doesn't start with it
+	Prefix string
+}
+
+// readStats counts the log lines that weren't replayed
+type readStats struct {
+	Invalid  int
+	Unsafe   int
+	Filtered int
+}
+
+// readRecords sends the records of a log to out, skipping lines that aren't access log records and
+// requests other than GET and HEAD, whose bodies aren't logged and which may change data. It stops when
+// ctx is done.
+func readRecords(ctx context.Context, r io.Reader, f filter, out chan<- record) (readStats, error) {
+	var stats readStats
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Method == "" || !strings.HasPrefix(rec.URI, "/") {
+			stats.Invalid++
+			continue
+		}
+		switch {
+		case rec.Method != http.MethodGet && rec.Method != http.MethodHead:
+			stats.Unsafe++
+		case time.Duration(rec.Latency) < f.MinLatency, !strings.HasPrefix(rec.URI, f.Prefix):
+			stats.Filtered++
+		default:
+			select {
+			case out <- rec:
+			case <-ctx.Done():
+				return stats, ctx.Err()
+			}
+		}
+	}
+	return stats, scanner.Err()
+}
+
+// replayer sends records to a target environment
+type replayer struct {
+	client  *http.Client
+	target  string
+	header  http.Header
+	limiter *rate.Limiter
+}
+
+// result is the outcome of a replayed request
+type result struct {
+	rec     record
+	status  int
+	latency time.Duration
+	err     error
+}
+
+// replay sends the records it receives with the given number of workers until in is closed or ctx is
+// done
+func (r *replayer) replay(ctx context.Context, in <-chan record, workers int) <-chan result {
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rec := range in {
+				if err := r.limiter.Wait(ctx); err != nil {
+					return
+				}
+				results <- r.send(ctx, rec)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// send replays one request
+func (r *replayer) send(ctx context.Context, rec record) result {
+	req, err := http.NewRequest(rec.Method, r.target+rec.URI, nil)
+	if err != nil {
+		return result{rec: rec, err: err}
+	}
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+	status, latency, err := loadtest.Send(ctx, r.client, req, nil)
+	return result{rec: rec, status: status, latency: latency, err: err}
+}
+
+// report summarizes the replayed requests
+type report struct {
+	*loadtest.Report
+	Mismatches map[string]int
+}
+
+func newReport() *report {
+	return &report{Report: loadtest.NewReport(), Mismatches: map[string]int{}}
+}
+
+// add records a result. A request answered with another status than the one logged is a mismatch,
+// keyed by the logged and replayed statuses.
+func (r *report) add(res result) {
+	r.Add(res.status, res.latency, res.err)
+	if res.err == nil && res.rec.Status != 0 && res.rec.Status != res.status {
+		r.Mismatches[fmt.Sprintf("%d -> %d", res.rec.Status, res.status)]++
+	}
+}
+
+func (r *report) write(w io.Writer, stats readStats) {
+	fmt.Fprintf(w, "skipped %d invalid, %d unsafe, %d filtered\n", stats.Invalid, stats.Unsafe, stats.Filtered)
+	r.Write(w)
+	mismatches := make([]string, 0, len(r.Mismatches))
+	for m := range r.Mismatches {
+		mismatches = append(mismatches, m)
+	}
+	sort.Strings(mismatches)
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "mismatch %s: %d\n", m, r.Mismatches[m])
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+const accessLog = `{"time":"2024-01-01T00:00:00Z","method":"GET","uri":"/paginate?key_condition=test","status":200,"latency":2000000,"fingerprint":"a"}
+{"time":"2024-01-01T00:00:01Z","method":"GET","uri":"/paginate?key_condition=missing","status":200,"latency":50000000,"fingerprint":"b"}
+{"time":"2024-01-01T00:00:02Z","method":"POST","uri":"/items","status":201,"latency":3000000,"fingerprint":"c"}
+⇨ http server started on [::]:8080
+{"time":"2024-01-01T00:00:03Z","method":"GET","uri":"/scan","status":200,"latency":90000000,"fingerprint":"d"}
+`
+
+func TestReadRecords(t *testing.T) {
+	read := func(f filter) ([]record, readStats) {
+		out := make(chan record, 10)
+		stats, err := readRecords(context.Background(), strings.NewReader(accessLog), f, out)
+		require.NoError(t, err)
+		close(out)
+		var records []record
+		for rec := range out {
+			records = append(records, rec)
+		}
+		return records, stats
+	}
+
+	records, stats := read(filter{})
+	assert.Len(t, records, 3)
+	assert.Equal(t, record{Method: "GET", URI: "/paginate?key_condition=test", Status: 200, Latency: 2000000}, records[0])
+	assert.Equal(t, readStats{Invalid: 1, Unsafe: 1}, stats)
+
+	// Only slow queries
+	records, stats = read(filter{MinLatency: 10 * time.Millisecond})
+	assert.Len(t, records, 2)
+	assert.Equal(t, 1, stats.Filtered)
+
+	records, _ = read(filter{MinLatency: 10 * time.Millisecond, Prefix: "/paginate"})
+	require.Len(t, records, 1)
+	assert.Equal(t, "/paginate?key_condition=missing", records[0].URI)
+}
+
+func TestReplay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		received = append(received, req.URL.RequestURI())
+		mu.Unlock()
+		assert.Equal(t, "key", req.Header.Get("X-Api-Key"))
+		if req.URL.Query().Get("key_condition") == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	r := &replayer{
+		client:  target.Client(),
+		target:  target.URL,
+		header:  http.Header{"X-Api-Key": {"key"}},
+		limiter: rate.NewLimiter(rate.Inf, 1),
+	}
+	records := make(chan record, 10)
+	stats, err := readRecords(context.Background(), strings.NewReader(accessLog), filter{}, records)
+	require.NoError(t, err)
+	close(records)
+
+	rep := newReport()
+	for res := range r.replay(context.Background(), records, 2) {
+		rep.add(res)
+	}
+	assert.ElementsMatch(t, []string{"/paginate?key_condition=test", "/paginate?key_condition=missing", "/scan"}, received)
+	assert.Equal(t, 3, rep.Sent)
+	assert.Equal(t, map[int]int{200: 2, 404: 1}, rep.Statuses)
+	assert.Equal(t, map[string]int{"200 -> 404": 1}, rep.Mismatches)
+
+	var out bytes.Buffer
+	rep.write(&out, stats)
+	assert.Contains(t, out.String(), "skipped 1 invalid, 1 unsafe, 0 filtered\nsent 3, errors 0\n")
+	assert.Contains(t, out.String(), "mismatch 200 -> 404: 1\n")
+}
+
+func TestReplayRate(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer target.Close()
+
+	r := &replayer{client: target.Client(), target: target.URL, limiter: rate.NewLimiter(20, 1)}
+	records := make(chan record, 5)
+	for i := 0; i < 5; i++ {
+		records <- record{Method: http.MethodGet, URI: "/paginate"}
+	}
+	close(records)
+
+	start := time.Now()
+	rep := newReport()
+	for res := range r.replay(context.Background(), records, 5) {
+		rep.add(res)
+	}
+	assert.Equal(t, 5, rep.Sent)
+	// The first request goes out at once, the next four 50ms apart
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
@@ -0,0 +1,154 @@
+// Package fixture declares pagination scenarios in YAML: the tables and items they read, and the pages
+// they are expected to serve. A scenario file seeds DynamoDB Local or any other DynamoDB, and runs its
+// scenarios through a pagination.Paginator on the client of either it or an in-memory fake, so
+// multi-page, searched and ordered walks are tested without writing AttributeValue maps by hand.
+package fixture
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"gopkg.in/yaml.v3"
+)
+
+// Suite is a scenario file
+type Suite struct {
+	Tables    []Table    `yaml:"tables"`
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+// Table is a table and the items it holds
+type Table struct {
+	Name         string `yaml:"name"`
+	PartitionKey string `yaml:"partition_key"`
+	SortKey      string `yaml:"sort_key"`
+	// Items are plain values, marshalled like attributevalue.MarshalMap does: strings to S, numbers to N
+	Items []map[string]interface{} `yaml:"items"`
+	// Generate adds generated items after Items
+	Generate []Generate `yaml:"generate"`
+}
+
+// Generate adds Count items to a partition whose sort keys are SortKey formatted with 1, 2 and so on,
+// e.g. "item%04d", and which all have the attributes of Attributes
+type Generate struct {
+	Partition  string                 `yaml:"partition"`
+	Count      int                    `yaml:"count"`
+	SortKey    string                 `yaml:"sort_key"`
+	Attributes map[string]interface{} `yaml:"attributes"`
+}
+
+// Scenario is a walk through the pages of a query and the sort keys expected on each page
+type Scenario struct {
+	Name  string `yaml:"name"`
+	Table string `yaml:"table"`
+	// Params are the parameters of every page; Page is set by the walk
+	Params Params `yaml:"params"`
+	// Cursor walks the pages with cursors instead of page numbers
+	Cursor bool `yaml:"cursor"`
+	// Pages are the sort keys of the pages, from page 1. The walk also expects no page after the last.
+	Pages [][]string `yaml:"pages"`
+}
+
+// Params are the pagination.Params a scenario can set
+type Params struct {
+	KeyCondition string                `yaml:"key_condition"`
+	PageSize     int64                 `yaml:"pagesize"`
+	OrderBy      string                `yaml:"orderby"`
+	Search       string                `yaml:"search"`
+	SearchMode   string                `yaml:"search_mode"`
+	SortRange    *pagination.SortRange `yaml:"sort_range"`
+	Select       string                `yaml:"select"`
+	Fields       []string              `yaml:"fields"`
+}
+
+// Load reads a scenario file
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse reads the YAML of a scenario file and checks that its scenarios name its tables
+func Parse(data []byte) (*Suite, error) {
+	var suite Suite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, err
+	}
+	tables := map[string]bool{}
+	for _, table := range suite.Tables {
+		if table.Name == "" || table.PartitionKey == "" {
+			return nil, errors.New("every table needs a name and a partition_key")
+		}
+		if tables[table.Name] {
+			return nil, fmt.Errorf("table %q is declared twice", table.Name)
+		}
+		for _, g := range table.Generate {
+			if table.SortKey != "" && g.SortKey == "" {
+				return nil, fmt.Errorf("table %q generates items without a sort_key format", table.Name)
+			}
+		}
+		tables[table.Name] = true
+	}
+	for _, scenario := range suite.Scenarios {
+		if !tables[scenario.Table] {
+			return nil, fmt.Errorf("scenario %q reads unknown table %q", scenario.Name, scenario.Table)
+		}
+		if scenario.Params.PageSize <= 0 {
+			return nil, fmt.Errorf("scenario %q needs a positive pagesize", scenario.Name)
+		}
+	}
+	return &suite, nil
+}
+
+// Table returns the table of a name
+func (s *Suite) Table(name string) (*Table, bool) {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return &s.Tables[i], true
+		}
+	}
+	return nil, false
+}
+
+// Keys returns the key schema of the table
+func (t *Table) Keys() pagination.KeySchema {
+	return pagination.KeySchema{PartitionKey: t.PartitionKey, SortKey: t.SortKey}
+}
+
+// Values returns the items of the table as plain values, the declared ones followed by the generated
+// ones
+func (t *Table) Values() []map[string]interface{} {
+	items := append([]map[string]interface{}(nil), t.Items...)
+	for _, g := range t.Generate {
+		for i := 1; i <= g.Count; i++ {
+			item := map[string]interface{}{t.PartitionKey: g.Partition}
+			for name, value := range g.Attributes {
+				item[name] = value
+			}
+			if t.SortKey != "" {
+				item[t.SortKey] = fmt.Sprintf(g.SortKey, i)
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// AttributeValues returns the items of the table as DynamoDB items
+func (t *Table) AttributeValues() ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	for i, value := range t.Values() {
+		item, err := attributevalue.MarshalMap(value)
+		if err != nil {
+			return nil, fmt.Errorf("table %q item %d: %w", t.Name, i, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,102 @@
+package fixture
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/elad-da/dynamopagination/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	suite, err := Load("testdata/scenarios.yaml")
+	require.NoError(t, err)
+	table, ok := suite.Table("Orders")
+	require.True(t, ok)
+	items, err := table.AttributeValues()
+	require.NoError(t, err)
+	assert.Len(t, items, 30)
+	assert.Equal(t, map[string]interface{}{"customer": "carol", "order_id": "2023-01#g", "status": "shipped"}, table.Values()[5])
+
+	for _, data := range []string{
+		`tables: [{name: Orders}]`,
+		`tables: [{name: Orders, partition_key: pk}, {name: Orders, partition_key: pk}]`,
+		`tables: [{name: Orders, partition_key: pk, sort_key: sk, generate: [{partition: a, count: 1}]}]`,
+		`scenarios: [{name: s, table: Missing, params: {pagesize: 1}}]`,
+		`{tables: [{name: Orders, partition_key: pk}], scenarios: [{name: s, table: Orders}]}`,
+		`tables: {}`,
+	} {
+		_, err := Parse([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func runScenarios(t *testing.T, suite *Suite, client func(table *Table) pagination.DynamoClient) {
+	for _, scenario := range suite.Scenarios {
+		scenario := scenario
+		t.Run(scenario.Name, func(t *testing.T) {
+			table, _ := suite.Table(scenario.Table)
+			assert.NoError(t, suite.Run(context.Background(), client(table), scenario))
+		})
+	}
+}
+
+func TestScenariosInMemory(t *testing.T) {
+	suite, err := Load("testdata/scenarios.yaml")
+	require.NoError(t, err)
+	runScenarios(t, suite, func(table *Table) pagination.DynamoClient {
+		client, err := server.NewFixtureClient(server.Fixture{PartitionKey: table.PartitionKey, SortKey: table.SortKey, Items: table.Values()})
+		require.NoError(t, err)
+		return client
+	})
+}
+
+func TestRunReportsDifferences(t *testing.T) {
+	suite, err := Load("testdata/scenarios.yaml")
+	require.NoError(t, err)
+	table, _ := suite.Table("Orders")
+	client, err := server.NewFixtureClient(server.Fixture{PartitionKey: table.PartitionKey, SortKey: table.SortKey, Items: table.Values()})
+	require.NoError(t, err)
+
+	for _, test := range []struct {
+		pages    [][]string
+		expected string
+	}{
+		{pages: [][]string{{"2024-01-05#o1", "2024-02-11#o2"}}, expected: `page 2 holds ["2024-02-19#o3" "2024-03-02#o4"], expected 1 pages`},
+		{pages: [][]string{{"2024-01-05#o1", "2024-02-11#o2"}, {"2024-02-19#o3"}}, expected: `page 2 holds ["2024-02-19#o3" "2024-03-02#o4"], expected ["2024-02-19#o3"]`},
+		{pages: [][]string{{"2024-01-05#o1", "2024-02-11#o2"}, {"2024-02-19#o3", "2024-03-02#o4"}, {}}, expected: `page 2 is the last page, expected 3 pages`},
+	} {
+		scenario := Scenario{Table: "Orders", Params: Params{KeyCondition: "alice", PageSize: 2}, Pages: test.pages}
+		err := suite.Run(context.Background(), client, scenario)
+		if assert.Error(t, err) {
+			assert.Equal(t, test.expected, err.Error())
+		}
+	}
+}
+
+// TestScenariosDynamoDBLocal runs the scenarios on the DynamoDB Local at DYNAMODB_LOCAL_ENDPOINT, e.g.
+// http://localhost:8000, which must not hold the tables of the scenarios yet
+func TestScenariosDynamoDBLocal(t *testing.T) {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT isn't set")
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithRegion("us-east-1"),
+		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider("local", "local", "")),
+	)
+	require.NoError(t, err)
+	client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) { o.BaseEndpoint = aws.String(endpoint) })
+
+	suite, err := Load("testdata/scenarios.yaml")
+	require.NoError(t, err)
+	require.NoError(t, suite.Seed(context.Background(), client))
+	runScenarios(t, suite, func(*Table) pagination.DynamoClient { return client })
+}
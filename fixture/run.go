@@ -0,0 +1,163 @@
+package fixture
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+const (
+	// batchWriteSize is the maximum number of requests BatchWriteItem accepts
+	batchWriteSize = 25
+	// tableWait bounds how long Seed waits for a table to become active
+	tableWait = time.Minute
+)
+
+// Seeder is the part of a DynamoDB client Seed uses, which *dynamodb.Client implements
+type Seeder interface {
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+}
+
+// Seed creates the tables of the suite, with on-demand capacity and string keys, and writes their items.
+// Tables must not exist yet, e.g. on a fresh DynamoDB Local.
+func (s *Suite) Seed(ctx context.Context, client Seeder) error {
+	for i := range s.Tables {
+		table := &s.Tables[i]
+		if err := createTable(ctx, client, table); err != nil {
+			return fmt.Errorf("creating table %q: %w", table.Name, err)
+		}
+		items, err := table.AttributeValues()
+		if err != nil {
+			return err
+		}
+		if err := writeItems(ctx, client, table.Name, items); err != nil {
+			return fmt.Errorf("writing the items of table %q: %w", table.Name, err)
+		}
+	}
+	return nil
+}
+
+func createTable(ctx context.Context, client Seeder, table *Table) error {
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(table.Name),
+		BillingMode: types.BillingModePayPerRequest,
+	}
+	for _, key := range []struct {
+		name    string
+		keyType types.KeyType
+	}{{table.PartitionKey, types.KeyTypeHash}, {table.SortKey, types.KeyTypeRange}} {
+		if key.name == "" {
+			continue
+		}
+		input.AttributeDefinitions = append(input.AttributeDefinitions, types.AttributeDefinition{AttributeName: aws.String(key.name), AttributeType: types.ScalarAttributeTypeS})
+		input.KeySchema = append(input.KeySchema, types.KeySchemaElement{AttributeName: aws.String(key.name), KeyType: key.keyType})
+	}
+	if _, err := client.CreateTable(ctx, input); err != nil {
+		return err
+	}
+	return dynamodb.NewTableExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{TableName: input.TableName}, tableWait)
+}
+
+// writeItems writes items in batches, retrying unprocessed ones until ctx is done
+func writeItems(ctx context.Context, client Seeder, table string, items []map[string]types.AttributeValue) error {
+	for start := 0; start < len(items); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(items) {
+			end = len(items)
+		}
+		var pending []types.WriteRequest
+		for _, item := range items[start:end] {
+			pending = append(pending, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+		for len(pending) > 0 {
+			out, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: map[string][]types.WriteRequest{table: pending}})
+			if err != nil {
+				return err
+			}
+			pending = out.UnprocessedItems[table]
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run walks the pages of a scenario through client and returns how they differ from the pages expected,
+// or nil when they match
+func (s *Suite) Run(ctx context.Context, client pagination.DynamoClient, scenario Scenario) error {
+	table, ok := s.Table(scenario.Table)
+	if !ok {
+		return fmt.Errorf("unknown table %q", scenario.Table)
+	}
+	p := pagination.New[map[string]types.AttributeValue](client, table.Name, table.Keys())
+	p.Decode = func(item map[string]types.AttributeValue, _ bool) (map[string]types.AttributeValue, []pagination.Warning, bool, error) {
+		return item, nil, true, nil
+	}
+
+	params := pagination.Params{
+		KeyCondition: scenario.Params.KeyCondition,
+		PageSize:     scenario.Params.PageSize,
+		OrderBy:      scenario.Params.OrderBy,
+		Search:       scenario.Params.Search,
+		SearchMode:   scenario.Params.SearchMode,
+		SortRange:    scenario.Params.SortRange,
+		Select:       scenario.Params.Select,
+		Fields:       scenario.Params.Fields,
+		CursorMode:   scenario.Cursor,
+	}
+	for number := 1; ; number++ {
+		params.Page = int64(number)
+		res, err := p.GetPage(ctx, params)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", number, err)
+		}
+		got := make([]string, 0, len(res.Data))
+		for _, item := range res.Data {
+			got = append(got, sortKeyString(item[table.SortKey]))
+		}
+
+		// DynamoDB may only find out on the page after the last that the results ended
+		if number > len(scenario.Pages) {
+			if len(got) > 0 {
+				return fmt.Errorf("page %d holds %q, expected %d pages", number, got, len(scenario.Pages))
+			}
+			return nil
+		}
+		if expected := append([]string{}, scenario.Pages[number-1]...); !reflect.DeepEqual(expected, got) {
+			return fmt.Errorf("page %d holds %q, expected %q", number, got, expected)
+		}
+
+		more := res.HasMore || res.NextCursor != ""
+		if !more && number < len(scenario.Pages) {
+			return fmt.Errorf("page %d is the last page, expected %d pages", number, len(scenario.Pages))
+		}
+		if !more {
+			return nil
+		}
+		if scenario.Cursor {
+			if params.Cursor, err = pagination.DecodeCursor(res.NextCursor); err != nil {
+				return fmt.Errorf("page %d: %w", number, err)
+			}
+		}
+	}
+}
+
+// sortKeyString renders a sort key value like the pages of a scenario list it
+func sortKeyString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
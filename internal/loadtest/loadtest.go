@@ -0,0 +1,84 @@
+// Package loadtest holds what the commands sending traffic to the service share: sending a request and
+// reporting the statuses and latencies of the responses.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Send sends req and reads its whole response into body, or discards it when body is nil. It returns
+// the status and how long the response took.
+func Send(ctx context.Context, client *http.Client, req *http.Request, body io.Writer) (int, time.Duration, error) {
+	if body == nil {
+		body = io.Discard
+	}
+	start := time.Now()
+	res, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, 0, err
+	}
+	defer res.Body.Close()
+	if _, err := io.Copy(body, res.Body); err != nil {
+		return 0, 0, err
+	}
+	return res.StatusCode, time.Since(start), nil
+}
+
+// Report summarizes the responses to the requests sent. It isn't safe for concurrent use.
+type Report struct {
+	Sent     int
+	Errors   int
+	Statuses map[int]int
+	// latencies are those of the requests answered, sorted on demand
+	latencies []time.Duration
+	sorted    bool
+}
+
+// NewReport creates an empty report
+func NewReport() *Report {
+	return &Report{Statuses: map[int]int{}}
+}
+
+// Add records the outcome of a request. Failed requests count as errors, without a latency.
+func (r *Report) Add(status int, latency time.Duration, err error) {
+	r.Sent++
+	if err != nil {
+		r.Errors++
+		return
+	}
+	r.Statuses[status]++
+	r.latencies = append(r.latencies, latency)
+	r.sorted = false
+}
+
+// Percentile returns the latency below which p percent of the requests answered were served
+func (r *Report) Percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	if !r.sorted {
+		sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+		r.sorted = true
+	}
+	return r.latencies[int(float64(len(r.latencies)-1)*p/100)]
+}
+
+// Write prints the number of requests sent and failed, the count of each status and the latency
+// percentiles
+func (r *Report) Write(w io.Writer) {
+	fmt.Fprintf(w, "sent %d, errors %d\n", r.Sent, r.Errors)
+	statuses := make([]int, 0, len(r.Statuses))
+	for status := range r.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		fmt.Fprintf(w, "status %d: %d\n", status, r.Statuses[status])
+	}
+	fmt.Fprintf(w, "latency p50 %s, p90 %s, p99 %s, max %s\n", r.Percentile(50), r.Percentile(90), r.Percentile(99), r.Percentile(100))
+}
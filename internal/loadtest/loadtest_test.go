@@ -0,0 +1,55 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("body"))
+	}))
+	defer target.Close()
+
+	req, err := http.NewRequest(http.MethodGet, target.URL, nil)
+	require.NoError(t, err)
+	var body bytes.Buffer
+	status, latency, err := Send(context.Background(), target.Client(), req, &body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusTeapot, status)
+	assert.Positive(t, latency)
+	assert.Equal(t, "body", body.String())
+
+	_, _, err = Send(context.Background(), target.Client(), req, nil)
+	assert.NoError(t, err)
+}
+
+func TestReport(t *testing.T) {
+	r := NewReport()
+	for i := 10; i >= 1; i-- {
+		r.Add(http.StatusOK, time.Duration(i)*time.Millisecond, nil)
+	}
+	r.Add(http.StatusNotFound, 20*time.Millisecond, nil)
+	r.Add(0, 0, errors.New("connection refused"))
+
+	assert.Equal(t, 12, r.Sent)
+	assert.Equal(t, 1, r.Errors)
+	assert.Equal(t, map[int]int{200: 10, 404: 1}, r.Statuses)
+	assert.Equal(t, 6*time.Millisecond, r.Percentile(50))
+	assert.Equal(t, 20*time.Millisecond, r.Percentile(100))
+
+	var out bytes.Buffer
+	r.Write(&out)
+	assert.Equal(t, "sent 12, errors 1\nstatus 200: 10\nstatus 404: 1\nlatency p50 6ms, p90 10ms, p99 10ms, max 20ms\n", out.String())
+
+	assert.Zero(t, NewReport().Percentile(50))
+}
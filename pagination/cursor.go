@@ -0,0 +1,111 @@
+package pagination
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// cursorValue is the JSON form of one key attribute in a cursor
+type cursorValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+	B []byte `json:"B,omitempty"`
+}
+
+// EncodeCursor turns a LastEvaluatedKey into an opaque token, or "" at the end of the results
+func EncodeCursor(key map[string]types.AttributeValue) (string, error) {
+	if key == nil {
+		return "", nil
+	}
+
+	values := make(map[string]cursorValue, len(key))
+	for name, av := range key {
+		switch v := av.(type) {
+		case *types.AttributeValueMemberS:
+			values[name] = cursorValue{S: v.Value}
+		case *types.AttributeValueMemberN:
+			values[name] = cursorValue{N: v.Value}
+		case *types.AttributeValueMemberB:
+			values[name] = cursorValue{B: v.Value}
+		default:
+			return "", fmt.Errorf("key attribute %q has unsupported type %T", name, av)
+		}
+	}
+
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor turns a token back into the ExclusiveStartKey it was created from
+func DecodeCursor(token string) (map[string]types.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]cursorValue
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, errors.New("empty cursor")
+	}
+
+	key := make(map[string]types.AttributeValue, len(values))
+	for name, v := range values {
+		switch {
+		case v.S != "":
+			key[name] = &types.AttributeValueMemberS{Value: v.S}
+		case v.N != "":
+			key[name] = &types.AttributeValueMemberN{Value: v.N}
+		case v.B != nil:
+			key[name] = &types.AttributeValueMemberB{Value: v.B}
+		default:
+			return nil, fmt.Errorf("key attribute %q has no value", name)
+		}
+	}
+	return key, nil
+}
+
+// cursorPage serves one page with a single query continuing from the cursor
+func (p *Paginator[T]) cursorPage(ctx context.Context, params Params, keys KeySchema) (Response[T], error) {
+	result, err := p.client.Query(WithPageDepth(ctx, 1), p.pageQuery(params, keys, params.Cursor))
+	if err != nil {
+		return Response[T]{}, &QueryError{Err: err}
+	}
+
+	matched, warnings, err := p.decodeItems(params, keys, result.Items)
+	if err != nil {
+		return Response[T]{}, err
+	}
+	check := p.orderCheck(params, keys)
+	check.Observe(result.Items...)
+	res := Response[T]{Data: append([]T{}, matched...)}
+	res.Size = int64(len(res.Data))
+
+	if p.OnProgress != nil {
+		p.OnProgress(newProgressTracker(1).record(result, len(res.Data)))
+	}
+
+	if res.NextCursor, err = EncodeCursor(result.LastEvaluatedKey); err != nil {
+		return Response[T]{}, fmt.Errorf("encoding cursor: %w", err)
+	}
+	res.HasMore = result.LastEvaluatedKey != nil
+
+	if len(warnings) > 0 || params.ConsumedCapacity != "" {
+		res.Meta = &Meta{Warnings: warnings}
+		var breakdown CapacityBreakdown
+		breakdown.add(result.ConsumedCapacity)
+		res.Meta.capacity(params, ConsumedUnits(result.ConsumedCapacity), breakdown)
+	}
+	check.report(&res.Meta)
+	return res, nil
+}
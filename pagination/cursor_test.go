@@ -0,0 +1,35 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	key := map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberN{Value: "42"},
+		"blob":     &types.AttributeValueMemberB{Value: []byte{1, 2}},
+	}
+
+	token, err := EncodeCursor(key)
+	require.NoError(t, err)
+	decoded, err := DecodeCursor(token)
+	require.NoError(t, err)
+	assert.Equal(t, key, decoded)
+
+	token, err = EncodeCursor(nil)
+	require.NoError(t, err)
+	assert.Empty(t, token)
+
+	_, err = EncodeCursor(map[string]types.AttributeValue{"flag": &types.AttributeValueMemberBOOL{Value: true}})
+	assert.Error(t, err)
+
+	for _, invalid := range []string{"%%%", "bm90IGpzb24", "e30", "eyJrIjp7fX0"} {
+		_, err := DecodeCursor(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
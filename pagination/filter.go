@@ -0,0 +1,73 @@
+package pagination
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Filter is a condition on the attributes of the items read, sent to DynamoDB as a FilterExpression.
+// DynamoDB applies it after the limit, so pages are filled from further round trips. Its placeholders
+// start with #n and :v, which the expressions of this package don't use.
+type Filter struct {
+	Expression string
+	Names      map[string]string
+	Values     map[string]types.AttributeValue
+	// Attributes are the attributes the expression names, checked against the keys of queries
+	Attributes []string
+}
+
+// ValidateFilter checks that a query with keys can apply the filter: the FilterExpression of a query
+// can't reference its partition or sort key, which scans' can
+func (p Params) ValidateFilter(keys KeySchema) error {
+	if p.Filter == nil {
+		return nil
+	}
+	for _, attribute := range p.Filter.Attributes {
+		if attribute == keys.PartitionKey || attribute == keys.SortKey {
+			return fmt.Errorf("can't filter on the key attribute %q", attribute)
+		}
+	}
+	return nil
+}
+
+// applyFilter adds the filter to the FilterExpression of a query
+func (p Params) applyFilter(input *dynamodb.QueryInput) {
+	if p.Filter == nil || p.Filter.Expression == "" {
+		return
+	}
+	condition := p.Filter.Expression
+	if input.FilterExpression != nil {
+		condition = *input.FilterExpression + " AND (" + condition + ")"
+	}
+	input.FilterExpression = aws.String(condition)
+	if len(p.Filter.Names) > 0 && input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]string{}
+	}
+	for placeholder, name := range p.Filter.Names {
+		input.ExpressionAttributeNames[placeholder] = name
+	}
+	if len(p.Filter.Values) > 0 && input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+	}
+	for placeholder, value := range p.Filter.Values {
+		input.ExpressionAttributeValues[placeholder] = value
+	}
+}
+
+// shape renders the expression and names of the filter, which its plan depends on
+func (f *Filter) shape() string {
+	if f == nil {
+		return ""
+	}
+	names := make([]string, 0, len(f.Names))
+	for placeholder, name := range f.Names {
+		names = append(names, placeholder+"="+name)
+	}
+	sort.Strings(names)
+	return f.Expression + "\x00" + strings.Join(names, "\x00")
+}
@@ -0,0 +1,50 @@
+package pagination
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func statusFilter(status string) *Filter {
+	return &Filter{
+		Expression: "#n0 = :v0",
+		Names:      map[string]string{"#n0": "status"},
+		Values:     map[string]types.AttributeValue{":v0": &types.AttributeValueMemberS{Value: status}},
+		Attributes: []string{"status"},
+	}
+}
+
+func TestApplyFilter(t *testing.T) {
+	p := New[Entry](newMemoryClient(), "Entries", testKeys)
+	input := p.pageQuery(Params{KeyCondition: "test", PageSize: 2, Filter: statusFilter("open")}, testKeys, nil)
+	assert.Equal(t, "#n0 = :v0", *input.FilterExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond", "#n0": "status"}, input.ExpressionAttributeNames)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "open"}, input.ExpressionAttributeValues[":v0"])
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "test"}, input.ExpressionAttributeValues[":keyCond"])
+
+	// Scans combine it with the search they filter on
+	scan := NewScan[Entry](nil, "Entries", testKeys)
+	input = scan.pageQuery(Params{PageSize: 2, Search: "item", Filter: statusFilter("open")}, testKeys, nil)
+	assert.Equal(t, "contains(#sk, :search) AND (#n0 = :v0)", *input.FilterExpression)
+}
+
+func TestValidateFilter(t *testing.T) {
+	assert.NoError(t, Params{}.ValidateFilter(testKeys))
+	assert.NoError(t, Params{Filter: statusFilter("open")}.ValidateFilter(testKeys))
+	assert.EqualError(t, Params{Filter: &Filter{Attributes: []string{"status", "sort_key"}}}.ValidateFilter(testKeys), `can't filter on the key attribute "sort_key"`)
+}
+
+func TestPlanCacheFilters(t *testing.T) {
+	p := New[Entry](newMemoryClient(), "Entries", testKeys)
+	p.Plans = NewPlanCache(10)
+
+	// Filters are part of the shape, so a filtered query doesn't reuse the plan of an unfiltered one
+	assert.Nil(t, p.pageQuery(Params{KeyCondition: "test", PageSize: 2}, testKeys, nil).FilterExpression)
+	filtered := p.pageQuery(Params{KeyCondition: "test", PageSize: 2, Filter: statusFilter("open")}, testKeys, nil)
+	assert.Equal(t, "#n0 = :v0", *filtered.FilterExpression)
+	// Their values aren't bound by plans, so they aren't cached
+	p.pageQuery(Params{KeyCondition: "test", PageSize: 2, Filter: statusFilter("closed")}, testKeys, nil)
+	assert.Equal(t, PlanCacheStats{Plans: 1, Misses: 3}, p.Plans.Stats())
+}
@@ -0,0 +1,120 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// maxHintProbes bounds the other indexes an empty partition is looked up in
+const maxHintProbes = 5
+
+// Hint is a likely reason a page is empty, found by checks made after it for Params.ExplainEmpty
+type Hint struct {
+	Code    string
+	Message string
+}
+
+// explainEmpty adds hints on why the empty page of params is empty to meta. Each check reads at most
+// one item: whether the partition has items at all, and when it doesn't, whether the table or another
+// index has items with the same key. Failed checks are left out, as hints are best effort.
+func (p *Paginator[T]) explainEmpty(ctx context.Context, params Params, meta **Meta) {
+	if p.scan {
+		return
+	}
+	var hints []Hint
+	has, err := p.hasItems(ctx, params.KeyCondition, params.IndexName)
+	switch {
+	case err != nil:
+		return
+	case has && params.CursorMode:
+		hints = append(hints, Hint{Code: "cursor_at_end", Message: "The partition has items, but none follow the cursor in this order"})
+	case has && params.Page > 1:
+		hints = append(hints, Hint{Code: "past_last_page", Message: fmt.Sprintf("The partition has items, but page %d is past the last page of the query", params.Page)})
+	case has && (params.SortRange != nil || params.Search != "" || params.Filter != nil):
+		hints = append(hints, Hint{Code: "filtered_out", Message: fmt.Sprintf("The partition has items, but none match the %s", filterNames(params))})
+	case has:
+		hints = append(hints, Hint{Code: "items_skipped", Message: "The partition has items, but all of those read were left out of the page; see the warnings"})
+	default:
+		where := "the table"
+		if params.IndexName != "" {
+			where = fmt.Sprintf("index %q", params.IndexName)
+		}
+		hints = append(hints, Hint{Code: "partition_empty", Message: fmt.Sprintf("No items in %s have this key_condition", where)})
+		hints = append(hints, p.otherIndexHints(ctx, params)...)
+	}
+
+	if *meta == nil {
+		*meta = &Meta{}
+	}
+	(*meta).Hints = append((*meta).Hints, hints...)
+}
+
+// otherIndexHints looks the key of an empty partition up in the table and the indexes not queried
+func (p *Paginator[T]) otherIndexHints(ctx context.Context, params Params) []Hint {
+	var candidates []string
+	if params.IndexName != "" {
+		candidates = append(candidates, "")
+	}
+	names := make([]string, 0, len(p.Indexes))
+	for name := range p.Indexes {
+		if name != params.IndexName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	candidates = append(candidates, names...)
+	if len(candidates) > maxHintProbes {
+		candidates = candidates[:maxHintProbes]
+	}
+
+	var hints []Hint
+	for _, index := range candidates {
+		has, err := p.hasItems(ctx, params.KeyCondition, index)
+		if err != nil || !has {
+			continue
+		}
+		if index == "" {
+			hints = append(hints, Hint{Code: "wrong_index", Message: "The table has items with this key_condition; query it without index"})
+		} else {
+			hints = append(hints, Hint{Code: "wrong_index", Message: fmt.Sprintf("Index %q has items with this key_condition; query it with index=%s", index, index)})
+		}
+	}
+	return hints
+}
+
+// hasItems reports whether any item of the table, or of index when it is set, has partition as its
+// partition key, reading at most one
+func (p *Paginator[T]) hasItems(ctx context.Context, partition, index string) (bool, error) {
+	keys := p.keys
+	if index != "" {
+		keys = p.Indexes[index]
+	}
+	input := p.query(Params{KeyCondition: partition, IndexName: index}, keys)
+	input.Limit = aws.Int32(1)
+	input.Select = types.SelectCount
+	out, err := p.client.Query(ctx, input)
+	if err != nil {
+		return false, err
+	}
+	return out.Count > 0, nil
+}
+
+// filterNames names the conditions of params that narrow a partition
+func filterNames(params Params) string {
+	var names []string
+	if params.SortRange != nil {
+		names = append(names, "sort key range")
+	}
+	if params.Search != "" {
+		names = append(names, "search")
+	}
+	if params.Filter != nil {
+		names = append(names, "filters")
+	}
+	return strings.Join(names, " and ")
+}
@@ -0,0 +1,87 @@
+package pagination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// indexedClient counts the items of each partition of the table and its indexes, and serves no items
+type indexedClient struct {
+	counts  map[string]int32
+	queries []*dynamodb.QueryInput
+}
+
+func (c *indexedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.queries = append(c.queries, params)
+	if params.Select != types.SelectCount {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	partition := params.ExpressionAttributeValues[":keyCond"].(*types.AttributeValueMemberS).Value
+	count := c.counts[aws.StringValue(params.IndexName)+"/"+partition]
+	if params.Limit != nil && count > *params.Limit {
+		count = *params.Limit
+	}
+	return &dynamodb.QueryOutput{Count: count}, nil
+}
+
+func TestGetPageExplainEmpty(t *testing.T) {
+	p := New[Entry](newMemoryClient("item1", "item2", "item3"), "Entries", testKeys)
+	hints := func(params Params) []Hint {
+		params.KeyCondition, params.PageSize, params.ExplainEmpty = "test", 2, true
+		res, err := p.GetPage(context.Background(), params)
+		require.NoError(t, err)
+		if res.Meta == nil {
+			return nil
+		}
+		return res.Meta.Hints
+	}
+
+	assert.Equal(t, []Hint{{Code: "filtered_out", Message: "The partition has items, but none match the sort key range"}}, hints(Params{SortRange: &SortRange{Op: "begins_with", Value: "other"}}))
+	assert.Equal(t, []Hint{{Code: "past_last_page", Message: "The partition has items, but page 3 is past the last page of the query"}}, hints(Params{Page: 3}))
+	cursor := map[string]types.AttributeValue{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item3"}}
+	assert.Equal(t, "cursor_at_end", hints(Params{CursorMode: true, Cursor: cursor})[0].Code)
+	// Pages with items aren't explained
+	assert.Nil(t, hints(Params{Page: 1}))
+}
+
+func TestGetPageExplainEmptyPartition(t *testing.T) {
+	client := &indexedClient{counts: map[string]int32{"by_status/test": 4, "/active": 2}}
+	p := New[Entry](client, "Entries", testKeys)
+	p.Indexes = map[string]KeySchema{
+		"by_status": {PartitionKey: "status", SortKey: "sort_key"},
+		"by_owner":  {PartitionKey: "owner", SortKey: "sort_key"},
+	}
+
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 2, ExplainEmpty: true})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, []Hint{
+		{Code: "partition_empty", Message: "No items in the table have this key_condition"},
+		{Code: "wrong_index", Message: `Index "by_status" has items with this key_condition; query it with index=by_status`},
+	}, res.Meta.Hints)
+	// The page, then one item read from the table and each index
+	require.Len(t, client.queries, 4)
+	for _, query := range client.queries[1:] {
+		assert.Equal(t, int32(1), *query.Limit)
+	}
+
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "active", PageSize: 2, IndexName: "by_owner", ExplainEmpty: true})
+	require.NoError(t, err)
+	assert.Equal(t, []Hint{
+		{Code: "partition_empty", Message: `No items in index "by_owner" have this key_condition`},
+		{Code: "wrong_index", Message: "The table has items with this key_condition; query it without index"},
+	}, res.Meta.Hints)
+
+	// Without explain_empty, no checks are made
+	client.queries = nil
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 2})
+	require.NoError(t, err)
+	assert.Nil(t, res.Meta)
+	assert.Len(t, client.queries, 1)
+}
@@ -0,0 +1,181 @@
+package pagination
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// KVStore is a key-value store shared by the instances of a service, such as Redis. Implementations
+// must be safe for concurrent use.
+type KVStore interface {
+	// Get returns the value of key, reporting false when there is none
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores a value for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr increments the counter stored at key, from 0 when there is none, and returns its new value
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// KVPageCache is a PageCache keeping checkpoints in a KVStore, so every instance of a service resumes
+// the walks the others made. It keeps no pages, which only the Paginators of one process can read back.
+//
+// Entries are never deleted: every key carries the generations of its table and partition, which
+// Invalidate increments, so the entries it drops are no longer found and expire after their TTL.
+type KVPageCache struct {
+	store  KVStore
+	prefix string
+	ttl    time.Duration
+	// AEAD, when set, encrypts the checkpoints stored, which hold table keys
+	AEAD cipher.AEAD
+	// OnError, when set, is called with the errors of the store, which are otherwise treated as misses
+	OnError func(error)
+}
+
+// kvCheckpoint is the stored form of a Checkpoint
+type kvCheckpoint struct {
+	Start  string `json:"start"`
+	Offset int64  `json:"offset"`
+}
+
+// NewKVPageCache creates a cache keeping checkpoints in store for ttl, under keys starting with prefix
+func NewKVPageCache(store KVStore, prefix string, ttl time.Duration) *KVPageCache {
+	return &KVPageCache{store: store, prefix: prefix, ttl: ttl}
+}
+
+func (c *KVPageCache) Checkpoint(ctx context.Context, key PageKey) (Checkpoint, bool) {
+	storeKey, err := c.key(ctx, key)
+	if err != nil {
+		c.fail(err)
+		return Checkpoint{}, false
+	}
+	data, ok, err := c.store.Get(ctx, storeKey)
+	if err != nil || !ok {
+		c.fail(err)
+		return Checkpoint{}, false
+	}
+	if data, err = c.open(data); err != nil {
+		c.fail(err)
+		return Checkpoint{}, false
+	}
+
+	var stored kvCheckpoint
+	if err := json.Unmarshal(data, &stored); err != nil {
+		c.fail(err)
+		return Checkpoint{}, false
+	}
+	start, err := DecodeCursor(stored.Start)
+	if err != nil {
+		c.fail(err)
+		return Checkpoint{}, false
+	}
+	return Checkpoint{Start: start, Offset: stored.Offset}, true
+}
+
+func (c *KVPageCache) PutCheckpoint(ctx context.Context, key PageKey, checkpoint Checkpoint) {
+	start, err := EncodeCursor(checkpoint.Start)
+	if err != nil || start == "" {
+		c.fail(err)
+		return
+	}
+	data, err := json.Marshal(kvCheckpoint{Start: start, Offset: checkpoint.Offset})
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	if data, err = c.seal(data); err != nil {
+		c.fail(err)
+		return
+	}
+	storeKey, err := c.key(ctx, key)
+	if err != nil {
+		c.fail(err)
+		return
+	}
+	c.fail(c.store.Set(ctx, storeKey, data, c.ttl))
+}
+
+func (c *KVPageCache) Page(context.Context, PageKey) (interface{}, bool) {
+	return nil, false
+}
+
+func (c *KVPageCache) PutPage(context.Context, PageKey, interface{}) {}
+
+func (c *KVPageCache) Invalidate(ctx context.Context, table, partition string) {
+	if partition == "" {
+		_, err := c.store.Incr(ctx, c.generationKey(table, nil))
+		c.fail(err)
+		return
+	}
+	// Entries with no partition read every partition, so a write to one drops them too
+	for _, p := range []string{partition, ""} {
+		_, err := c.store.Incr(ctx, c.generationKey(table, &p))
+		c.fail(err)
+	}
+}
+
+// key returns the store key of the checkpoint of key, with the current generations of its table and
+// partition
+func (c *KVPageCache) key(ctx context.Context, key PageKey) (string, error) {
+	tableGeneration, err := c.generation(ctx, c.generationKey(key.Table, nil))
+	if err != nil {
+		return "", err
+	}
+	partitionGeneration, err := c.generation(ctx, c.generationKey(key.Table, &key.Partition))
+	if err != nil {
+		return "", err
+	}
+	return c.prefix + "checkpoint/" + tableGeneration + "." + partitionGeneration + "/" + key.String(), nil
+}
+
+// generationKey returns the key of the generation of a table, or of one of its partitions
+func (c *KVPageCache) generationKey(table string, partition *string) string {
+	if partition == nil {
+		return c.prefix + "generation/" + table
+	}
+	return c.prefix + "generation/" + table + "/" + *partition
+}
+
+func (c *KVPageCache) generation(ctx context.Context, key string) (string, error) {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil || !ok {
+		return "0", err
+	}
+	if _, err := strconv.ParseInt(string(data), 10, 64); err != nil {
+		return "", errors.New("invalid page cache generation " + strconv.Quote(string(data)))
+	}
+	return string(data), nil
+}
+
+// seal encrypts a value to store when the cache has an AEAD, prefixing it with its nonce
+func (c *KVPageCache) seal(data []byte) ([]byte, error) {
+	if c.AEAD == nil {
+		return data, nil
+	}
+	nonce := make([]byte, c.AEAD.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.AEAD.Seal(nonce, nonce, data, nil), nil
+}
+
+// open decrypts a value sealed by seal
+func (c *KVPageCache) open(data []byte) ([]byte, error) {
+	if c.AEAD == nil {
+		return data, nil
+	}
+	if len(data) < c.AEAD.NonceSize() {
+		return nil, errors.New("page cache entry is too short")
+	}
+	return c.AEAD.Open(nil, data[:c.AEAD.NonceSize()], data[c.AEAD.NonceSize():], nil)
+}
+
+func (c *KVPageCache) fail(err error) {
+	if err != nil && c.OnError != nil {
+		c.OnError(err)
+	}
+}
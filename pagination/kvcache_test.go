@@ -0,0 +1,118 @@
+package pagination
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapStore is an in-memory KVStore, failing every call while err is set
+type mapStore struct {
+	mu     sync.Mutex
+	values map[string][]byte
+	err    error
+}
+
+func (s *mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.values[key]
+	return value, ok, s.err
+}
+
+func (s *mapStore) Set(_ context.Context, key string, value []byte, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.values[key] = value
+	}
+	return s.err
+}
+
+func (s *mapStore) Incr(_ context.Context, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, _ := strconv.ParseInt(string(s.values[key]), 10, 64)
+	s.values[key] = []byte(strconv.FormatInt(n+1, 10))
+	return n + 1, s.err
+}
+
+func newTestAEAD(t *testing.T, key string) cipher.AEAD {
+	block, err := aes.NewCipher([]byte(key))
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	return aead
+}
+
+func TestKVPageCache(t *testing.T) {
+	store := &mapStore{values: map[string][]byte{}}
+	client := newMemoryClient("item1", "item2", "item3", "item4", "item5", "item6")
+	newInstance := func() *Paginator[Entry] {
+		p := New[Entry](client, "Entries", testKeys)
+		cache := NewKVPageCache(store, "test:", time.Minute)
+		cache.AEAD = newTestAEAD(t, "0123456789abcdef")
+		p.Cache = cache
+		return p
+	}
+	params := Params{KeyCondition: "test", Page: 3, PageSize: 2}
+
+	// One instance walks to page 3, and another reads it with one round trip
+	_, err := newInstance().GetPage(context.Background(), params)
+	require.NoError(t, err)
+	before := len(client.queries)
+	res, err := newInstance().GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item5", "item6"}, sortKeys(res.Data))
+	assert.Equal(t, 1, len(client.queries)-before)
+
+	// The checkpoints hold no keys in plaintext
+	for key, value := range store.values {
+		assert.False(t, bytes.Contains(value, []byte("item")), key)
+	}
+
+	// A write to the partition drops its checkpoints on every instance
+	newInstance().Cache.Invalidate(context.Background(), "Entries", "test")
+	before = len(client.queries)
+	_, err = newInstance().GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(client.queries)-before)
+
+	// Checkpoints sealed with another key aren't read
+	other := New[Entry](client, "Entries", testKeys)
+	otherCache := NewKVPageCache(store, "test:", time.Minute)
+	otherCache.AEAD = newTestAEAD(t, "fedcba9876543210")
+	var failures int
+	otherCache.OnError = func(error) { failures++ }
+	other.Cache = otherCache
+	before = len(client.queries)
+	_, err = other.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(client.queries)-before)
+	assert.Equal(t, 1, failures)
+}
+
+func TestKVPageCacheStoreErrors(t *testing.T) {
+	store := &mapStore{values: map[string][]byte{}, err: errors.New("connection refused")}
+	client := newMemoryClient("item1", "item2", "item3")
+	p := New[Entry](client, "Entries", testKeys)
+	cache := NewKVPageCache(store, "test:", time.Minute)
+	var failures []error
+	cache.OnError = func(err error) { failures = append(failures, err) }
+	p.Cache = cache
+
+	// Pages are walked as if nothing were cached
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item3"}, sortKeys(res.Data))
+	assert.NotEmpty(t, failures)
+}
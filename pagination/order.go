@@ -0,0 +1,133 @@
+package pagination
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// maxOrderViolations bounds the violations reported for one page
+const maxOrderViolations = 10
+
+// OrderCheck verifies that items arrive in the order of their query, for Params.AssertOrder. Pages check
+// the items as the client returns them, so merges and sorts done by clients wrapping DynamoDB, like dual
+// reads or fixtures, are checked along with DynamoDB itself.
+type OrderCheck struct {
+	keys       KeySchema
+	descending bool
+	last       types.AttributeValue
+	violations []Warning
+}
+
+// NewOrderCheck returns a check of items ordered by the sort key of keys in the direction of params, or
+// nil when params don't ask for one. Cursor pages start from the sort key of their cursor.
+func NewOrderCheck(params Params, keys KeySchema) *OrderCheck {
+	if !params.AssertOrder || keys.SortKey == "" {
+		return nil
+	}
+	return &OrderCheck{keys: keys, descending: params.Descending(), last: params.Cursor[keys.SortKey]}
+}
+
+// orderCheck returns the check of a page's items; scans have no order to check
+func (p *Paginator[T]) orderCheck(params Params, keys KeySchema) *OrderCheck {
+	if p.scan {
+		return nil
+	}
+	return NewOrderCheck(params, keys)
+}
+
+// Observe checks the next items read. A nil check does nothing.
+func (o *OrderCheck) Observe(items ...map[string]types.AttributeValue) {
+	if o == nil {
+		return
+	}
+	for _, item := range items {
+		v := item[o.keys.SortKey]
+		if v == nil {
+			continue
+		}
+		if o.last != nil && len(o.violations) < maxOrderViolations {
+			cmp := compareKeyValues(o.last, v)
+			if o.descending {
+				cmp = -cmp
+			}
+			// Index sort keys may repeat
+			if cmp > 0 {
+				o.violations = append(o.violations, o.violation(item, v))
+			}
+		}
+		o.last = v
+	}
+}
+
+func (o *OrderCheck) violation(item map[string]types.AttributeValue, v types.AttributeValue) Warning {
+	direction := "ascending"
+	if o.descending {
+		direction = "descending"
+	}
+	return Warning{
+		Code:    "order_violation",
+		Message: fmt.Sprintf("%s %s follows %s, out of %s order", o.keys.SortKey, keyValueString(v), keyValueString(o.last), direction),
+		Key:     map[string]string{o.keys.PartitionKey: keyValueString(item[o.keys.PartitionKey]), o.keys.SortKey: keyValueString(v)},
+	}
+}
+
+// Violations returns an order_violation warning for each item read out of order, up to 10
+func (o *OrderCheck) Violations() []Warning {
+	if o == nil {
+		return nil
+	}
+	return o.violations
+}
+
+// report adds the violations found to the warnings of a page
+func (o *OrderCheck) report(meta **Meta) {
+	if len(o.Violations()) == 0 {
+		return
+	}
+	if *meta == nil {
+		*meta = &Meta{}
+	}
+	(*meta).Warnings = append((*meta).Warnings, o.violations...)
+}
+
+// compareKeyValues orders two key values the way DynamoDB sorts them: numbers numerically, strings
+// and binary by their bytes. Values of different types compare by type; a sort key has one type.
+func compareKeyValues(a, b types.AttributeValue) int {
+	switch a := a.(type) {
+	case *types.AttributeValueMemberN:
+		if b, ok := b.(*types.AttributeValueMemberN); ok {
+			x, okA := new(big.Float).SetString(a.Value)
+			y, okB := new(big.Float).SetString(b.Value)
+			if okA && okB {
+				return x.Cmp(y)
+			}
+			return strings.Compare(a.Value, b.Value)
+		}
+	case *types.AttributeValueMemberS:
+		if b, ok := b.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(a.Value, b.Value)
+		}
+	case *types.AttributeValueMemberB:
+		if b, ok := b.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(a.Value, b.Value)
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+// keyValueString formats a key value for messages
+func keyValueString(v types.AttributeValue) string {
+	switch v := v.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	case *types.AttributeValueMemberB:
+		return fmt.Sprintf("%x", v.Value)
+	}
+	return ""
+}
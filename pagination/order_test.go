@@ -0,0 +1,71 @@
+package pagination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPageAssertOrder(t *testing.T) {
+	// The client returns item2 after item3, as a broken merge would
+	client := newMemoryClient("item1", "item3", "item2", "item4")
+	p := New[Entry](client, "Entries", testKeys)
+
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2, AssertOrder: true})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	require.Len(t, res.Meta.Warnings, 1)
+	assert.Equal(t, Warning{
+		Code:    "order_violation",
+		Message: "sort_key item2 follows item3, out of ascending order",
+		Key:     map[string]string{"key_cond": "test", "sort_key": "item2"},
+	}, res.Meta.Warnings[0])
+
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2, OrderBy: "-sort_key", AssertOrder: true})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	require.Len(t, res.Meta.Warnings, 1)
+	assert.Equal(t, "sort_key item3 follows item2, out of descending order", res.Meta.Warnings[0].Message)
+
+	// Without the assertion, pages carry no warnings
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	assert.Nil(t, res.Meta)
+}
+
+func TestCursorPageAssertOrder(t *testing.T) {
+	p := New[Entry](newMemoryClient("item1", "item2", "item3"), "Entries", testKeys)
+
+	// A page starting before its cursor is out of order
+	cursor := map[string]types.AttributeValue{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item4"}}
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 10, CursorMode: true, Cursor: cursor, AssertOrder: true})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, "sort_key item1 follows item4, out of ascending order", res.Meta.Warnings[0].Message)
+
+	cursor["sort_key"] = &types.AttributeValueMemberS{Value: "item1"}
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 10, CursorMode: true, Cursor: cursor, AssertOrder: true})
+	require.NoError(t, err)
+	assert.Nil(t, res.Meta)
+}
+
+func TestCompareKeyValues(t *testing.T) {
+	n := func(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+	b := func(v ...byte) types.AttributeValue { return &types.AttributeValueMemberB{Value: v} }
+
+	assert.Equal(t, -1, compareKeyValues(n("9"), n("10")))
+	assert.Equal(t, 0, compareKeyValues(n("1.50"), n("1.5")))
+	assert.Equal(t, 1, compareKeyValues(n("-1"), n("-2")))
+	assert.Equal(t, -1, compareKeyValues(s("10"), s("9")))
+	assert.Equal(t, -1, compareKeyValues(s("Z"), s("a")))
+	assert.Equal(t, 1, compareKeyValues(b(2), b(1, 9)))
+
+	// A nil check does nothing
+	var check *OrderCheck
+	check.Observe(map[string]types.AttributeValue{"sort_key": s("a")})
+	assert.Empty(t, check.Violations())
+}
@@ -0,0 +1,261 @@
+package pagination
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// PageKey identifies a page of a query: the table and partition it reads, the query it runs, and its
+// number. Scans and index queries have no partition.
+type PageKey struct {
+	Table     string
+	Partition string
+	// Query is a digest of everything that decides the items of the query: its key condition,
+	// filters, index, projection, order, page size and search
+	Query string
+	Page  int64
+}
+
+// String renders the key, e.g. for the keys of a shared store
+func (k PageKey) String() string {
+	return k.Table + "/" + k.Partition + "/" + k.Query + "/" + strconv.FormatInt(k.Page, 10)
+}
+
+// Checkpoint is where the walk to a page resumes: the ExclusiveStartKey of its last round trip, and the
+// number of items the query matched before it
+type Checkpoint struct {
+	Start  map[string]types.AttributeValue
+	Offset int64
+}
+
+// PageCache stores what a Paginator learns walking its queries: the checkpoint each page resumes from,
+// so a page is reached with one round trip instead of walking from the start, and the pages served.
+// Implementations may keep either, and must be safe for concurrent use.
+type PageCache interface {
+	Checkpoint(ctx context.Context, key PageKey) (Checkpoint, bool)
+	PutCheckpoint(ctx context.Context, key PageKey, checkpoint Checkpoint)
+	// Page returns a page stored by PutPage, which only Paginators of the same item type read back
+	Page(ctx context.Context, key PageKey) (interface{}, bool)
+	PutPage(ctx context.Context, key PageKey, page interface{})
+	// Invalidate drops what a write to a partition of table can change: the entries of the partition,
+	// and those with no partition. An empty partition drops every entry of the table.
+	Invalidate(ctx context.Context, table, partition string)
+}
+
+// PageCacheMode selects what an LRUPageCache keeps
+type PageCacheMode int
+
+const (
+	// CacheCheckpoints keeps the checkpoints of pages, so every page is still read but without the walk
+	CacheCheckpoints PageCacheMode = 1 << iota
+	// CachePages keeps the pages served, so repeated requests skip DynamoDB
+	CachePages
+	// CacheAll keeps both
+	CacheAll = CacheCheckpoints | CachePages
+)
+
+// LRUPageCache is an in-memory PageCache holding a fixed number of entries for a TTL, evicting the least
+// recently used ones
+type LRUPageCache struct {
+	size int
+	ttl  time.Duration
+	mode PageCacheMode
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[pageEntryKey]*list.Element
+	now     func() time.Time
+}
+
+// pageEntryKey tells checkpoints and pages of the same key apart
+type pageEntryKey struct {
+	key  PageKey
+	page bool
+}
+
+type pageEntry struct {
+	key    pageEntryKey
+	value  interface{}
+	stored time.Time
+}
+
+// NewLRUPageCache creates a cache holding up to size checkpoints and pages, as selected by mode, for ttl
+func NewLRUPageCache(size int, ttl time.Duration, mode PageCacheMode) *LRUPageCache {
+	return &LRUPageCache{size: size, ttl: ttl, mode: mode, order: list.New(), entries: map[pageEntryKey]*list.Element{}, now: time.Now}
+}
+
+func (c *LRUPageCache) Checkpoint(_ context.Context, key PageKey) (Checkpoint, bool) {
+	v, ok := c.get(pageEntryKey{key: key})
+	if !ok {
+		return Checkpoint{}, false
+	}
+	return v.(Checkpoint), true
+}
+
+func (c *LRUPageCache) PutCheckpoint(_ context.Context, key PageKey, checkpoint Checkpoint) {
+	if c.mode&CacheCheckpoints != 0 {
+		c.put(pageEntryKey{key: key}, checkpoint)
+	}
+}
+
+func (c *LRUPageCache) Page(_ context.Context, key PageKey) (interface{}, bool) {
+	return c.get(pageEntryKey{key: key, page: true})
+}
+
+func (c *LRUPageCache) PutPage(_ context.Context, key PageKey, page interface{}) {
+	if c.mode&CachePages != 0 {
+		c.put(pageEntryKey{key: key, page: true}, page)
+	}
+}
+
+func (c *LRUPageCache) Invalidate(_ context.Context, table, partition string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, element := range c.entries {
+		if key.key.Table == table && (partition == "" || key.key.Partition == "" || key.key.Partition == partition) {
+			c.order.Remove(element)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// Len returns the number of checkpoints and pages held
+func (c *LRUPageCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *LRUPageCache) get(key pageEntryKey) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*pageEntry)
+	if c.now().Sub(entry.stored) >= c.ttl {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *LRUPageCache) put(key pageEntryKey, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+	} else if len(c.entries) >= c.size {
+		oldest := c.order.Back()
+		if oldest == nil {
+			return
+		}
+		delete(c.entries, c.order.Remove(oldest).(*pageEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&pageEntry{key: key, value: value, stored: c.now()})
+}
+
+// cacheable reports whether the pages of params go through the page cache. Strongly consistent reads
+// are always walked.
+func (p *Paginator[T]) cacheable(params Params) bool {
+	return p.Cache != nil && !params.ConsistentRead
+}
+
+// cachesPage reports whether the page of params may be served from the cache. Fresh pages, and those
+// streaming their progress or reporting the capacity they consume, are always read.
+func (p *Paginator[T]) cachesPage(params Params) bool {
+	return !params.Fresh && p.OnProgress == nil && params.ConsumedCapacity == ""
+}
+
+// PageCheckpoint returns the checkpoint the walk to page params.Page resumes from, when the cache holds
+// one
+func (p *Paginator[T]) PageCheckpoint(ctx context.Context, params Params) (Checkpoint, bool) {
+	if !p.cacheable(params) || params.Page <= 1 {
+		return Checkpoint{}, false
+	}
+	keys, err := p.keysFor(params)
+	if err != nil {
+		return Checkpoint{}, false
+	}
+	return p.Cache.Checkpoint(ctx, p.pageKey(params, keys))
+}
+
+// pageKey identifies page params.Page of the query of params
+func (p *Paginator[T]) pageKey(params Params, keys KeySchema) PageKey {
+	key := PageKey{Table: p.table, Query: queryDigest(p.pageQuery(params, keys, nil), params), Page: params.Page}
+	if !p.scan && params.IndexName == "" {
+		key.Partition = params.KeyCondition
+	}
+	return key
+}
+
+// queryDigest digests the first round trip of a query along with the search applied to its results and
+// the region it is read from
+func queryDigest(input *dynamodb.QueryInput, params Params) string {
+	str := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	parts := []string{
+		str(input.TableName), str(input.IndexName), str(input.KeyConditionExpression), str(input.FilterExpression),
+		str(input.ProjectionExpression), string(input.Select), string(input.ReturnConsumedCapacity),
+		fmt.Sprint(input.ScanIndexForward != nil && !*input.ScanIndexForward), params.Search, params.SearchMode, fmt.Sprint(params.partial()),
+		params.Region,
+	}
+	if input.Limit != nil {
+		parts = append(parts, strconv.Itoa(int(*input.Limit)))
+	}
+	var names []string
+	for placeholder, name := range input.ExpressionAttributeNames {
+		names = append(names, placeholder+"="+name)
+	}
+	sort.Strings(names)
+	var values []string
+	for placeholder, v := range input.ExpressionAttributeValues {
+		// The member type tells values of the same text apart, e.g. the string "1" from the number 1
+		data, _ := json.Marshal(v)
+		values = append(values, fmt.Sprintf("%s=%T%s", placeholder, v, data))
+	}
+	sort.Strings(values)
+	parts = append(append(parts, names...), values...)
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// cachedPage is a page stored in the page cache, with when it was stored for Params.MaxAge
+type cachedPage[T any] struct {
+	res    Response[T]
+	stored time.Time
+}
+
+// clone copies a page read from the cache, so callers adding to it leave the cached page alone
+func (r Response[T]) clone() Response[T] {
+	r.Data = append([]T(nil), r.Data...)
+	if r.Meta != nil {
+		meta := *r.Meta
+		meta.Warnings = append([]Warning(nil), meta.Warnings...)
+		r.Meta = &meta
+	}
+	return r
+}
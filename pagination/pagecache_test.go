@@ -0,0 +1,215 @@
+package pagination
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCacheCheckpoints(t *testing.T) {
+	var sks []string
+	for i := 1; i <= 12; i++ {
+		sks = append(sks, fmt.Sprintf("item%02d", i))
+	}
+	client := newMemoryClient(sks...)
+	cached := New[Entry](client, "Entries", testKeys)
+	cached.Cache = NewLRUPageCache(100, time.Minute, CacheCheckpoints)
+	uncached := New[Entry](newMemoryClient(sks...), "Entries", testKeys)
+
+	// Sequential pages resume from the checkpoint of the page before them, with one round trip each
+	for _, params := range []Params{
+		{KeyCondition: "test", PageSize: 3},
+		{KeyCondition: "test", PageSize: 3, Search: "1"},
+	} {
+		for page := int64(1); page <= 5; page++ {
+			params.Page = page
+			before := len(client.queries)
+			res, err := cached.GetPage(context.Background(), params)
+			require.NoError(t, err)
+			expected, err := uncached.GetPage(context.Background(), params)
+			require.NoError(t, err)
+			assert.Equal(t, sortKeys(expected.Data), sortKeys(res.Data), "%+v", params)
+			assert.Equal(t, expected.HasMore, res.HasMore, "%+v", params)
+			if page > 1 && page <= 4 {
+				assert.Equal(t, 1, len(client.queries)-before, "page %d", page)
+			}
+		}
+	}
+
+	// Each page size and query has checkpoints of its own
+	before := len(client.queries)
+	res, err := cached.GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item05", "item06"}, sortKeys(res.Data))
+	assert.Equal(t, 3, len(client.queries)-before)
+
+	// Repeated pages are still read
+	before = len(client.queries)
+	_, err = cached.GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(client.queries)-before)
+
+	// Consistent reads always walk
+	before = len(client.queries)
+	_, err = cached.GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2, ConsistentRead: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(client.queries)-before)
+}
+
+func TestPageCachePages(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3")
+	p := New[Entry](client, "Entries", testKeys)
+	cache := NewLRUPageCache(100, time.Minute, CachePages)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	p.Cache = cache
+	params := Params{KeyCondition: "test", Page: 1, PageSize: 2}
+
+	res, err := p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	res.Data[0].SortKey = "changed"
+	res, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item1", "item2"}, sortKeys(res.Data))
+	assert.True(t, res.HasMore)
+	assert.Len(t, client.queries, 1)
+
+	// Writes to the partition, or to any partition for scans and index queries, drop its pages
+	cache.Invalidate(context.Background(), "Entries", "other")
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 1)
+	cache.Invalidate(context.Background(), "Entries", "test")
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 2)
+
+	// Pages expire after the TTL
+	now = now.Add(time.Minute)
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 3)
+
+	// Fresh pages are read, and refresh the cached page
+	params.Fresh = true
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 4)
+	params.Fresh = false
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 4)
+
+	// Pages reporting their capacity are read
+	for i := 0; i < 2; i++ {
+		_, err = p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 2, ConsumedCapacity: "total"})
+		require.NoError(t, err)
+	}
+	assert.Len(t, client.queries, 6)
+}
+
+func TestPageCacheMaxAge(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3")
+	p := New[Entry](client, "Entries", testKeys)
+	cache := NewLRUPageCache(100, time.Hour, CachePages)
+	p.Cache = cache
+	params := Params{KeyCondition: "test", Page: 1, PageSize: 2}
+
+	res, err := p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	// The page was stored half a minute ago
+	cache.PutPage(context.Background(), p.pageKey(params, testKeys), cachedPage[Entry]{res: res, stored: time.Now().Add(-30 * time.Second)})
+
+	params.MaxAge = time.Minute
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 1)
+
+	// Too old, the page is read again and the fresh page stored
+	params.MaxAge = 10 * time.Second
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 2)
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	assert.Len(t, client.queries, 2)
+}
+
+func TestLRUPageCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUPageCache(2, time.Minute, CacheAll)
+	a, b, c := PageKey{Table: "T", Partition: "a", Page: 1}, PageKey{Table: "T", Partition: "b", Page: 1}, PageKey{Table: "T", Page: 1}
+	cache.PutPage(ctx, a, "a")
+	cache.PutCheckpoint(ctx, b, Checkpoint{Offset: 2})
+	_, ok := cache.Page(ctx, a)
+	require.True(t, ok)
+
+	// The least recently used entry makes room
+	cache.PutPage(ctx, c, "c")
+	_, ok = cache.Checkpoint(ctx, b)
+	assert.False(t, ok)
+	_, ok = cache.Page(ctx, a)
+	assert.True(t, ok)
+	_, ok = cache.Checkpoint(ctx, a)
+	assert.False(t, ok, "checkpoints and pages are kept apart")
+
+	cache.Invalidate(ctx, "T", "x")
+	assert.Equal(t, 1, cache.Len())
+	cache.Invalidate(ctx, "T", "")
+	assert.Zero(t, cache.Len())
+}
+
+func TestQueryDigest(t *testing.T) {
+	p := New[Entry](newMemoryClient(), "Entries", testKeys)
+	digest := func(params Params) string {
+		return p.pageKey(params, testKeys).Query
+	}
+	base := Params{KeyCondition: "test", PageSize: 2}
+	assert.Equal(t, digest(base), digest(Params{KeyCondition: "test", PageSize: 2, Page: 4}))
+	for _, other := range []Params{
+		{KeyCondition: "other", PageSize: 2},
+		{KeyCondition: "test", PageSize: 3},
+		{KeyCondition: "test", PageSize: 2, Search: "a"},
+		{KeyCondition: "test", PageSize: 2, OrderBy: "-sort_key"},
+		{KeyCondition: "test", PageSize: 2, Fields: []string{"a"}},
+		{KeyCondition: "test", PageSize: 2, Region: "eu-west-1"},
+		{KeyCondition: "test", PageSize: 2, Filter: &Filter{Expression: "attribute_exists(#n0)", Names: map[string]string{"#n0": "a"}}},
+	} {
+		assert.NotEqual(t, digest(base), digest(other), "%+v", other)
+	}
+
+	// Values of the same text and different types are told apart
+	filter := func(v types.AttributeValue) Params {
+		return Params{KeyCondition: "test", PageSize: 2, Filter: &Filter{Expression: "#n0 = :v0", Names: map[string]string{"#n0": "a"}, Values: map[string]types.AttributeValue{":v0": v}}}
+	}
+	assert.NotEqual(t, digest(filter(&types.AttributeValueMemberS{Value: "1"})), digest(filter(&types.AttributeValueMemberN{Value: "1"})))
+}
+
+func TestMaxRoundTrips(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3", "item4", "item5", "item6")
+	p := New[Entry](client, "Entries", testKeys)
+	p.MaxRoundTrips = 2
+
+	_, err := p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+
+	before := len(client.queries)
+	_, err = p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2})
+	var budgetErr *BudgetError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, BudgetError{RoundTrips: 3, Max: 2}, *budgetErr)
+	assert.Equal(t, before, len(client.queries))
+
+	// A checkpoint brings a deep page within the budget
+	p.Cache = NewLRUPageCache(100, time.Minute, CacheCheckpoints)
+	_, err = p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2})
+	require.NoError(t, err)
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"item5", "item6"}, sortKeys(res.Data))
+}
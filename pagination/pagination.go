@@ -0,0 +1,921 @@
+// Package pagination pages through the items of a DynamoDB partition, or of a whole table with NewScan.
+// A Paginator walks the query in round trips of pagesize items to serve numbered pages, or continues
+// from a cursor so that every page takes a single round trip. Items are unmarshalled into the
+// Paginator's type parameter.
+package pagination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// DynamoClient is the part of the DynamoDB API a Paginator uses
+type DynamoClient interface {
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+// KeySchema names the key attributes of a table
+type KeySchema struct {
+	PartitionKey string
+	SortKey      string
+	// KeyCondition overrides the expression selecting a partition, which compares the partition key
+	// with :keyCond by default
+	KeyCondition string
+	// Local marks the keys of a local secondary index. Global secondary indexes don't support
+	// consistent reads.
+	Local bool
+	// SortKeyType is the DynamoDB type of the sort key, a string when empty
+	SortKeyType types.ScalarAttributeType
+}
+
+// AttributeType returns the DynamoDB type of a key attribute: partitions are read as strings, and sort
+// keys as SortKeyType. It reports false for attributes that aren't keys.
+func (k KeySchema) AttributeType(name string) (types.ScalarAttributeType, bool) {
+	switch {
+	case name == "":
+		return "", false
+	case name == k.PartitionKey:
+		return types.ScalarAttributeTypeS, true
+	case name == k.SortKey && k.SortKeyType != "":
+		return k.SortKeyType, true
+	case name == k.SortKey:
+		return types.ScalarAttributeTypeS, true
+	}
+	return "", false
+}
+
+// Query builds the base QueryInput selecting every item of a partition
+func (k KeySchema) Query(table, partition string) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{
+		TableName:              &table,
+		KeyConditionExpression: aws.String("#pk = :keyCond"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":keyCond": &types.AttributeValueMemberS{Value: partition},
+		},
+	}
+	if k.KeyCondition != "" {
+		input.KeyConditionExpression = aws.String(k.KeyCondition)
+	} else {
+		input.ExpressionAttributeNames = map[string]string{"#pk": k.PartitionKey}
+	}
+	return input
+}
+
+// Params struct represents the pagination parameters
+type Params struct {
+	// KeyCondition is the partition key value to page through
+	KeyCondition string `json:"key_condition,omitempty"`
+	Page         int64  `json:"page"`
+	PageSize     int64  `json:"pagesize"`
+	OrderBy      string `json:"orderby"`
+	Search       string `json:"search"`
+	// SearchMode is "" to match Search anywhere in the sort key, "prefix" to match its start or
+	// "client" to match anywhere without sending the search to DynamoDB
+	SearchMode string `json:"search_mode,omitempty"`
+	// SortRange narrows the query to a range of sort keys
+	SortRange *SortRange `json:"sort_range,omitempty"`
+	// Filter narrows the items read by their other attributes
+	Filter *Filter `json:"-"`
+	// Select is "all", "keys_only" or "count"
+	Select string `json:"select,omitempty"`
+	// Fields, when set, projects the items read onto these attributes and the key attributes, which
+	// identify the items. Names are top-level attributes; reserved words are fine.
+	Fields []string `json:"fields,omitempty"`
+	// IndexName pages through a secondary index, whose keys are listed in Paginator.Indexes
+	IndexName string `json:"index,omitempty"`
+	// ConsistentRead reads the table, or a local secondary index, with strongly consistent reads
+	ConsistentRead bool `json:"consistent,omitempty"`
+	// Region is the replica region the page is read from, so the page cache keeps the pages of each
+	// region apart
+	Region string `json:"-"`
+	// ConsumedCapacity is the return_consumed_capacity mode: "none", "total" or "indexes"
+	ConsumedCapacity string `json:"return_consumed_capacity,omitempty"`
+	// IncludeCount adds the total number of items and pages to the response, counted with an extra
+	// Select=COUNT query. Only searches that DynamoDB applies narrow the count.
+	IncludeCount bool `json:"include_count,omitempty"`
+	// Total is a count carried over from an earlier page of the same cursor chain, used instead of
+	// counting again
+	Total *int64 `json:"-"`
+	// Fresh reads the page even when the page cache holds it, e.g. for polls waiting for it to change
+	Fresh bool `json:"-"`
+	// MaxAge, when positive, only serves a page from the page cache if it was stored within MaxAge
+	MaxAge time.Duration `json:"-"`
+	// CursorMode serves a single page continuing from Cursor instead of walking to Page
+	CursorMode bool                            `json:"-"`
+	Cursor     map[string]types.AttributeValue `json:"-"`
+	// AssertOrder checks that the items read arrive in the order of the query and reports those that
+	// don't as order_violation warnings, to debug the merges and sorts of the clients wrapping DynamoDB
+	AssertOrder bool `json:"-"`
+	// Debug adds the Stats of the page to its Meta
+	Debug bool `json:"-"`
+	// ExplainEmpty adds Hints to the Meta of an empty page on why it is empty
+	ExplainEmpty bool `json:"-"`
+}
+
+// SortRange is a condition on the sort key, applied by DynamoDB before the limit. Op is "begins_with",
+// "between", ">" or "<"; between compares with Value and End inclusively. Values are compared as strings.
+type SortRange struct {
+	Op    string `json:"op"`
+	Value string `json:"value"`
+	End   string `json:"end,omitempty"`
+}
+
+// SortRangeOps are the operators of a SortRange
+var SortRangeOps = map[string]bool{"begins_with": true, "between": true, ">": true, "<": true}
+
+// condition renders the range with the #sk, :sortValue and :sortEnd placeholders
+func (r *SortRange) condition() string {
+	switch r.Op {
+	case "begins_with":
+		return "begins_with(#sk, :sortValue)"
+	case "between":
+		return "#sk BETWEEN :sortValue AND :sortEnd"
+	}
+	return "#sk " + r.Op + " :sortValue"
+}
+
+// ConsumedCapacityModes maps the return_consumed_capacity parameter onto QueryInput
+var ConsumedCapacityModes = map[string]types.ReturnConsumedCapacity{
+	"none":    types.ReturnConsumedCapacityNone,
+	"total":   types.ReturnConsumedCapacityTotal,
+	"true":    types.ReturnConsumedCapacityTotal,
+	"indexes": types.ReturnConsumedCapacityIndexes,
+}
+
+// ParseOrderBy splits an orderby parameter into the attribute to order by and the direction: a leading
+// '-' orders descending, a leading '+' or none ascending
+func ParseOrderBy(orderBy string) (attribute string, descending bool) {
+	switch {
+	case strings.HasPrefix(orderBy, "-"):
+		return orderBy[1:], true
+	case strings.HasPrefix(orderBy, "+"):
+		return orderBy[1:], false
+	}
+	return orderBy, false
+}
+
+// Descending reports whether OrderBy asks for descending order
+func (p Params) Descending() bool {
+	_, descending := ParseOrderBy(p.OrderBy)
+	return descending
+}
+
+// ValidateOrder checks that OrderBy names the sort key of keys: queries return items in sort key order,
+// so it's the only attribute they can be ordered by
+func (p Params) ValidateOrder(keys KeySchema) error {
+	if p.OrderBy == "" {
+		return nil
+	}
+	attribute, _ := ParseOrderBy(p.OrderBy)
+	if keys.SortKey == "" {
+		return fmt.Errorf("can't order by %q: the keys have no sort key", attribute)
+	}
+	if attribute != keys.SortKey {
+		return fmt.Errorf("can't order by %q, only by the sort key %q", attribute, keys.SortKey)
+	}
+	return nil
+}
+
+// ValidateSortKey checks that the search and the sort key range, which are conditions on the sort key,
+// are only used with keys that have one
+func (p Params) ValidateSortKey(keys KeySchema) error {
+	if keys.SortKey != "" {
+		return nil
+	}
+	switch {
+	case p.Search != "":
+		return errors.New("can't search: the keys have no sort key")
+	case p.SortRange != nil:
+		return errors.New("can't take a sort key range: the keys have no sort key")
+	}
+	return nil
+}
+
+// ApplyOrder sets the query direction from the order by parameter, if provided
+func (p Params) ApplyOrder(input *dynamodb.QueryInput) {
+	if p.OrderBy != "" {
+		input.ScanIndexForward = aws.Bool(!p.Descending())
+	}
+}
+
+// ApplySearch adds a prefix search to the key condition of a query, so DynamoDB applies it before the
+// limit. Other searches are matched with Matches: the FilterExpression of a query can't reference the
+// sort key.
+func (p Params) ApplySearch(input *dynamodb.QueryInput, keys KeySchema) {
+	if p.Search == "" || p.SearchMode != "prefix" || input.KeyConditionExpression == nil {
+		return
+	}
+	input.KeyConditionExpression = aws.String(*input.KeyConditionExpression + " AND begins_with(#sk, :search)")
+	p.bindSearch(input, keys)
+}
+
+// ApplySortRange adds the sort key range to the key condition of a query. It can't be combined with a
+// prefix search, which is a condition on the sort key too.
+func (p Params) ApplySortRange(input *dynamodb.QueryInput, keys KeySchema) {
+	if p.SortRange == nil || input.KeyConditionExpression == nil {
+		return
+	}
+	input.KeyConditionExpression = aws.String(*input.KeyConditionExpression + " AND " + p.SortRange.condition())
+	p.bindSortRange(input, keys)
+}
+
+// applyScanSortRange adds the sort key range to the FilterExpression of a scan
+func (p Params) applyScanSortRange(input *dynamodb.QueryInput, keys KeySchema) {
+	if p.SortRange == nil {
+		return
+	}
+	condition := p.SortRange.condition()
+	if input.FilterExpression != nil {
+		condition = *input.FilterExpression + " AND " + condition
+	}
+	input.FilterExpression = aws.String(condition)
+	p.bindSortRange(input, keys)
+}
+
+func (p Params) bindSortRange(input *dynamodb.QueryInput, keys KeySchema) {
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]string{}
+	}
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+	}
+	input.ExpressionAttributeNames["#sk"] = keys.SortKey
+	input.ExpressionAttributeValues[":sortValue"] = &types.AttributeValueMemberS{Value: p.SortRange.Value}
+	if p.SortRange.Op == "between" {
+		input.ExpressionAttributeValues[":sortEnd"] = &types.AttributeValueMemberS{Value: p.SortRange.End}
+	}
+}
+
+// applyScanSearch builds the search into a FilterExpression, which scans can apply to key attributes
+func (p Params) applyScanSearch(input *dynamodb.QueryInput, keys KeySchema) {
+	if p.Search == "" || p.SearchMode == "client" {
+		return
+	}
+	input.FilterExpression = aws.String("contains(#sk, :search)")
+	if p.SearchMode == "prefix" {
+		input.FilterExpression = aws.String("begins_with(#sk, :search)")
+	}
+	p.bindSearch(input, keys)
+}
+
+func (p Params) bindSearch(input *dynamodb.QueryInput, keys KeySchema) {
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]string{}
+	}
+	if input.ExpressionAttributeValues == nil {
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+	}
+	input.ExpressionAttributeNames["#sk"] = keys.SortKey
+	input.ExpressionAttributeValues[":search"] = &types.AttributeValueMemberS{Value: p.Search}
+}
+
+// Matches reports whether a sort key value passes the free-text search, ignoring case. Prefix searches
+// were already applied by DynamoDB.
+func (p Params) Matches(sortKey string) bool {
+	if p.Search == "" || p.SearchMode == "prefix" {
+		return true
+	}
+	return strings.Contains(strings.ToLower(sortKey), strings.ToLower(p.Search))
+}
+
+// ApplyPassthrough sets the projection and capacity reporting requested by the client
+func (p Params) ApplyPassthrough(input *dynamodb.QueryInput, keys KeySchema) {
+	if mode, ok := ConsumedCapacityModes[p.ConsumedCapacity]; ok {
+		input.ReturnConsumedCapacity = RequestCapacity(input.ReturnConsumedCapacity, mode)
+	}
+	switch p.Select {
+	case "keys_only":
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = map[string]string{}
+		}
+		input.ProjectionExpression = aws.String("#pk")
+		input.ExpressionAttributeNames["#pk"] = keys.PartitionKey
+		if keys.SortKey != "" {
+			input.ProjectionExpression = aws.String("#pk, #sk")
+			input.ExpressionAttributeNames["#sk"] = keys.SortKey
+		}
+	case "count":
+		input.Select = types.SelectCount
+	default:
+		p.applyFields(input, keys)
+	}
+}
+
+// applyFields projects the query onto Fields and the key attributes. Every name goes through a
+// placeholder, so reserved words and any character can be projected.
+func (p Params) applyFields(input *dynamodb.QueryInput, keys KeySchema) {
+	if len(p.Fields) == 0 {
+		return
+	}
+	if input.ExpressionAttributeNames == nil {
+		input.ExpressionAttributeNames = map[string]string{}
+	}
+	input.ExpressionAttributeNames["#pk"] = keys.PartitionKey
+	placeholders := []string{"#pk"}
+	projected := map[string]bool{keys.PartitionKey: true}
+	if keys.SortKey != "" {
+		input.ExpressionAttributeNames["#sk"] = keys.SortKey
+		placeholders = append(placeholders, "#sk")
+		projected[keys.SortKey] = true
+	}
+	for i, field := range p.Fields {
+		if projected[field] {
+			continue
+		}
+		projected[field] = true
+		placeholder := fmt.Sprintf("#f%d", i)
+		input.ExpressionAttributeNames[placeholder] = field
+		placeholders = append(placeholders, placeholder)
+	}
+	input.ProjectionExpression = aws.String(strings.Join(placeholders, ", "))
+}
+
+// partial reports whether the items read for params hold only some of their attributes
+func (p Params) partial() bool {
+	return p.Select == "keys_only" || (p.Select != "count" && len(p.Fields) > 0)
+}
+
+// Entry is an item with only the key attributes of the service's table, key_cond and sort_key
+type Entry struct {
+	KeyCond string `dynamodbav:"key_cond" json:"key_cond"`
+	SortKey string `dynamodbav:"sort_key" json:"sort_key"`
+	// Computed holds the fields the service derives from the item's other attributes
+	Computed map[string]interface{} `dynamodbav:"-" json:"computed,omitempty"`
+	// Attributes holds the attributes the service serves with an output type, converted to it
+	Attributes map[string]interface{} `dynamodbav:"-" json:"attributes,omitempty"`
+}
+
+// Key returns the primary key attributes of the entry, used to identify it in warnings
+func (e Entry) Key() map[string]string {
+	return map[string]string{"key_cond": e.KeyCond, "sort_key": e.SortKey}
+}
+
+// Response is a page of items of type T
+type Response[T any] struct {
+	Data []T
+	Page int64
+	Size int64
+	Meta *Meta `json:",omitempty"`
+	// NextCursor resumes after this page in cursor mode; it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+	// TotalItems and TotalPages count the items selected by the query and the pages they fill,
+	// returned with include_count
+	TotalItems *int64 `json:",omitempty"`
+	TotalPages *int64 `json:",omitempty"`
+
+	// HasMore is set when the query stopped before the end of the results
+	HasMore bool `json:"-"`
+}
+
+// Meta carries information about how the page was assembled
+type Meta struct {
+	Warnings []Warning `json:",omitempty"`
+	// Count is the number of items in the partition, returned for select=count
+	Count *int64 `json:",omitempty"`
+	// ConsumedCapacity is the total read capacity used, returned with return_consumed_capacity
+	ConsumedCapacity float64 `json:",omitempty"`
+	// CapacityBreakdown splits ConsumedCapacity by table and index, returned with return_consumed_capacity=indexes
+	CapacityBreakdown *CapacityBreakdown `json:",omitempty"`
+	// Stats describe the DynamoDB reads of the page, returned with debug=true
+	Stats *Stats `json:",omitempty"`
+	// Hints are likely reasons an empty page is empty, returned with explain_empty=true
+	Hints []Hint `json:",omitempty"`
+}
+
+// CapacityBreakdown is the read capacity used on the table and on each of its indexes
+type CapacityBreakdown struct {
+	Table                  float64
+	GlobalSecondaryIndexes map[string]float64 `json:",omitempty"`
+	LocalSecondaryIndexes  map[string]float64 `json:",omitempty"`
+}
+
+// add accumulates the capacity DynamoDB reported for a call
+func (b *CapacityBreakdown) add(consumed *types.ConsumedCapacity) {
+	if consumed == nil {
+		return
+	}
+	if consumed.Table != nil && consumed.Table.CapacityUnits != nil {
+		b.Table += *consumed.Table.CapacityUnits
+	}
+	b.GlobalSecondaryIndexes = addIndexCapacity(b.GlobalSecondaryIndexes, consumed.GlobalSecondaryIndexes)
+	b.LocalSecondaryIndexes = addIndexCapacity(b.LocalSecondaryIndexes, consumed.LocalSecondaryIndexes)
+}
+
+// merge accumulates another breakdown
+func (b *CapacityBreakdown) merge(other CapacityBreakdown) {
+	b.Table += other.Table
+	for name, units := range other.GlobalSecondaryIndexes {
+		if b.GlobalSecondaryIndexes == nil {
+			b.GlobalSecondaryIndexes = map[string]float64{}
+		}
+		b.GlobalSecondaryIndexes[name] += units
+	}
+	for name, units := range other.LocalSecondaryIndexes {
+		if b.LocalSecondaryIndexes == nil {
+			b.LocalSecondaryIndexes = map[string]float64{}
+		}
+		b.LocalSecondaryIndexes[name] += units
+	}
+}
+
+func addIndexCapacity(totals map[string]float64, indexes map[string]types.Capacity) map[string]float64 {
+	for name, capacity := range indexes {
+		if capacity.CapacityUnits == nil {
+			continue
+		}
+		if totals == nil {
+			totals = map[string]float64{}
+		}
+		totals[name] += *capacity.CapacityUnits
+	}
+	return totals
+}
+
+// capacity fills in the consumed capacity requested by params
+func (m *Meta) capacity(params Params, total float64, breakdown CapacityBreakdown) {
+	if params.ConsumedCapacity == "" {
+		return
+	}
+	m.ConsumedCapacity = total
+	if ConsumedCapacityModes[params.ConsumedCapacity] == types.ReturnConsumedCapacityIndexes {
+		m.CapacityBreakdown = &breakdown
+	}
+}
+
+// Warning describes a problem with a single item that did not fail the request
+type Warning struct {
+	Code    string
+	Message string
+	Key     map[string]string `json:",omitempty"`
+}
+
+// QueryError is a failed DynamoDB round trip
+type QueryError struct {
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("query failed: %v", e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// BudgetError is a page that takes more round trips to walk to than the Paginator allows
+type BudgetError struct {
+	RoundTrips int64
+	Max        int64
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("page needs %d round trips, over the budget of %d", e.RoundTrips, e.Max)
+}
+
+// DecodeFunc converts a raw item into a T. It reports false for items to leave out of the page; its
+// errors fail the page and are returned by GetPage unchanged. Partial items were read with a projection,
+// of the keys for select=keys_only or of Params.Fields.
+type DecodeFunc[T any] func(item map[string]types.AttributeValue, partial bool) (T, []Warning, bool, error)
+
+// Paginator serves pages of one table as items of type T
+type Paginator[T any] struct {
+	client DynamoClient
+	table  string
+	keys   KeySchema
+	// scan reads the whole table instead of a partition, see NewScan
+	scan bool
+
+	// Indexes are the key attributes of the secondary indexes Params.IndexName can select
+	Indexes map[string]KeySchema
+	// Plans, when set, caches the query built for each query shape
+	Plans *PlanCache
+	// Cache, when set, keeps the checkpoints and pages of walked queries
+	Cache PageCache
+	// MaxRoundTrips, when set, bounds the round trips walking to a page; deeper pages fail with a
+	// BudgetError
+	MaxRoundTrips int64
+
+	// Decode converts the items of a page, by default with attributevalue.UnmarshalMap
+	Decode DecodeFunc[T]
+	// OnProgress, when set, is called after every DynamoDB round trip
+	OnProgress func(Progress)
+}
+
+// New creates a Paginator querying table through client
+func New[T any](client DynamoClient, table string, keys KeySchema) *Paginator[T] {
+	return &Paginator[T]{client: client, table: table, keys: keys, Decode: Unmarshal[T]}
+}
+
+// Unmarshal is the default DecodeFunc, which keeps every item
+func Unmarshal[T any](item map[string]types.AttributeValue, partial bool) (T, []Warning, bool, error) {
+	var v T
+	if err := attributevalue.UnmarshalMap(item, &v); err != nil {
+		return v, nil, false, err
+	}
+	return v, nil, true, nil
+}
+
+// Query builds the base QueryInput selecting every item of a partition of the table, or of the whole
+// table for a scanning Paginator
+func (p *Paginator[T]) Query(partition string) *dynamodb.QueryInput {
+	return p.query(Params{KeyCondition: partition}, p.keys)
+}
+
+// keysFor returns the key attributes of the table, or of the index selected by params
+func (p *Paginator[T]) keysFor(params Params) (KeySchema, error) {
+	if params.IndexName == "" {
+		return p.keys, nil
+	}
+	keys, ok := p.Indexes[params.IndexName]
+	if !ok {
+		return KeySchema{}, fmt.Errorf("unknown index %q", params.IndexName)
+	}
+	if params.ConsistentRead && !keys.Local {
+		return KeySchema{}, fmt.Errorf("index %q is a global secondary index, which doesn't support consistent reads", params.IndexName)
+	}
+	return keys, nil
+}
+
+// query builds the base QueryInput of a page, on the index selected by params
+func (p *Paginator[T]) query(params Params, keys KeySchema) *dynamodb.QueryInput {
+	input := &dynamodb.QueryInput{TableName: aws.String(p.table)}
+	if !p.scan {
+		input = keys.Query(p.table, params.KeyCondition)
+	}
+	if params.IndexName != "" {
+		input.IndexName = aws.String(params.IndexName)
+	}
+	if params.ConsistentRead {
+		input.ConsistentRead = aws.Bool(true)
+	}
+	return input
+}
+
+// sortKey renders the sort key of a raw item for the search
+func sortKey(item map[string]types.AttributeValue, keys KeySchema) string {
+	switch v := item[keys.SortKey].(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
+
+// pageQuery builds the query for one round trip of a page starting after start
+func (p *Paginator[T]) pageQuery(params Params, keys KeySchema, start map[string]types.AttributeValue) *dynamodb.QueryInput {
+	limit := int32(params.PageSize)
+	input := p.plannedQuery(params, keys)
+	input.Limit = &limit
+	input.ExclusiveStartKey = start
+	return input
+}
+
+// RoundTripQuery builds the query of the round trip of a page of params starting after start, on the
+// table or index selected by params, checking params like GetPage
+func (p *Paginator[T]) RoundTripQuery(params Params, start map[string]types.AttributeValue) (*dynamodb.QueryInput, error) {
+	keys, err := p.keysFor(params)
+	if err != nil {
+		return nil, err
+	}
+	if err := params.ValidateSortKey(keys); err != nil {
+		return nil, err
+	}
+	if !p.scan {
+		if err := params.ValidateOrder(keys); err != nil {
+			return nil, err
+		}
+	}
+	return p.pageQuery(params, keys, start), nil
+}
+
+// buildQuery builds the query of a round trip without its limit and start key. Counts read every
+// item and report no progress, so they are left in the table's order.
+func (p *Paginator[T]) buildQuery(params Params, keys KeySchema) *dynamodb.QueryInput {
+	input := p.query(params, keys)
+	if params.Select != "count" {
+		if !p.scan {
+			params.ApplyOrder(input)
+		}
+		if p.OnProgress != nil {
+			input.ReturnConsumedCapacity = RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+		}
+	}
+	p.applyPassthrough(params, keys, input)
+	p.applySearch(params, keys, input)
+	params.applyFilter(input)
+	return input
+}
+
+// applyPassthrough applies the client's parameters for the keys being read. Projected reads of an index
+// also project the table keys, which identify the items.
+func (p *Paginator[T]) applyPassthrough(params Params, keys KeySchema, input *dynamodb.QueryInput) {
+	params.ApplyPassthrough(input, keys)
+	if !params.partial() || params.IndexName == "" {
+		return
+	}
+	projected := map[string]bool{keys.PartitionKey: true, keys.SortKey: true}
+	if params.Select != "keys_only" {
+		for _, field := range params.Fields {
+			projected[field] = true
+		}
+	}
+	for placeholder, name := range map[string]string{"#tpk": p.keys.PartitionKey, "#tsk": p.keys.SortKey} {
+		if name != "" && !projected[name] {
+			*input.ProjectionExpression += ", " + placeholder
+			input.ExpressionAttributeNames[placeholder] = name
+		}
+	}
+}
+
+// applySearch sends the search and the sort key range to DynamoDB where it can apply them
+func (p *Paginator[T]) applySearch(params Params, keys KeySchema, input *dynamodb.QueryInput) {
+	if p.scan {
+		params.applyScanSearch(input, keys)
+		params.applyScanSortRange(input, keys)
+	} else {
+		params.ApplySearch(input, keys)
+		params.ApplySortRange(input, keys)
+	}
+}
+
+// decodeItems decodes the items of one round trip, returning those that pass the search
+func (p *Paginator[T]) decodeItems(params Params, keys KeySchema, items []map[string]types.AttributeValue) ([]T, []Warning, error) {
+	var decoded []T
+	var warnings []Warning
+	for _, item := range items {
+		v, itemWarnings, keep, err := p.Decode(item, params.partial())
+		if err != nil {
+			return nil, nil, err
+		}
+		warnings = append(warnings, itemWarnings...)
+		if keep && params.Matches(sortKey(item, keys)) {
+			decoded = append(decoded, v)
+		}
+	}
+	return decoded, warnings, nil
+}
+
+// GetPage serves the page described by params: the count of the partition for select=count, the page
+// continuing from the cursor in cursor mode, and otherwise page number params.Page, reached by walking
+// the query. Pages include their totals with params.IncludeCount, their stats with params.Debug, and
+// hints on why they are empty with params.ExplainEmpty.
+func (p *Paginator[T]) GetPage(ctx context.Context, params Params) (Response[T], error) {
+	if params.Debug {
+		return p.debugPage(ctx, params)
+	}
+	return p.getPage(ctx, params)
+}
+
+func (p *Paginator[T]) getPage(ctx context.Context, params Params) (Response[T], error) {
+	keys, err := p.keysFor(params)
+	if err != nil {
+		return Response[T]{}, err
+	}
+	if err := params.ValidateSortKey(keys); err != nil {
+		return Response[T]{}, err
+	}
+	if !p.scan {
+		if err := params.ValidateOrder(keys); err != nil {
+			return Response[T]{}, err
+		}
+	}
+	if params.Select == "count" {
+		return p.count(ctx, params, keys)
+	}
+
+	var res Response[T]
+	if params.CursorMode {
+		res, err = p.cursorPage(ctx, params, keys)
+	} else {
+		res, err = p.walkPage(ctx, params, keys)
+	}
+	if err == nil && params.IncludeCount {
+		res, err = p.addTotal(ctx, params, keys, res)
+	}
+	if err == nil && params.ExplainEmpty && len(res.Data) == 0 {
+		p.explainEmpty(ctx, params, &res.Meta)
+	}
+	return res, err
+}
+
+// walkPage serves page number params.Page by walking the query from the start, or from the checkpoint
+// of the page in the cache
+func (p *Paginator[T]) walkPage(ctx context.Context, params Params, keys KeySchema) (Response[T], error) {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	cacheable := p.cacheable(params)
+	var key PageKey
+	if cacheable {
+		key = p.pageKey(params, keys)
+		if p.cachesPage(params) {
+			if cached, ok := p.Cache.Page(ctx, key); ok {
+				if page, ok := cached.(cachedPage[T]); ok && (params.MaxAge <= 0 || time.Since(page.stored) <= params.MaxAge) {
+					return page.res.clone(), nil
+				}
+			}
+		}
+	}
+
+	// Every round trip reads up to a page of items, so the walk to a page can resume after the round
+	// trips of the pages before it, with the items they matched counted
+	first := int64(1)
+	var checkpoint Checkpoint
+	if cacheable && params.Page > 1 {
+		if cp, ok := p.Cache.Checkpoint(ctx, key); ok {
+			first, checkpoint = params.Page, cp
+		}
+	}
+
+	roundTrips := params.Page - first + 1
+	if p.MaxRoundTrips > 0 && roundTrips > p.MaxRoundTrips {
+		return Response[T]{}, &BudgetError{RoundTrips: roundTrips, Max: p.MaxRoundTrips}
+	}
+
+	var lastEvaluatedKey map[string]types.AttributeValue
+	var itemsForPage []T
+	var warnings []Warning
+	var consumed float64
+	var breakdown CapacityBreakdown
+	tracker := newProgressTracker(roundTrips)
+	check := p.orderCheck(params, keys)
+
+	// Stop the fetch stage when decoding fails before the walk ends
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := fetchPagesFrom(ctx, p.client, first, checkpoint.Start, params.Page, func(start map[string]types.AttributeValue) *dynamodb.QueryInput {
+		return p.pageQuery(params, keys, start)
+	})
+
+	for page := range pages {
+		if page.err != nil {
+			return Response[T]{}, &QueryError{Err: page.err}
+		}
+		result := page.result
+
+		// Unmarshal DynamoDB items into Entry structs
+		matched, itemWarnings, err := p.decodeItems(params, keys, result.Items)
+		if err != nil {
+			return Response[T]{}, err
+		}
+		check.Observe(result.Items...)
+		itemsForPage = append(itemsForPage, matched...)
+		warnings = append(warnings, itemWarnings...)
+
+		consumed += ConsumedUnits(result.ConsumedCapacity)
+		breakdown.add(result.ConsumedCapacity)
+		lastEvaluatedKey = result.LastEvaluatedKey
+		if cacheable && lastEvaluatedKey != nil {
+			next := key
+			next.Page = page.number + 1
+			p.Cache.PutCheckpoint(ctx, next, Checkpoint{Start: lastEvaluatedKey, Offset: checkpoint.Offset + int64(len(itemsForPage))})
+		}
+
+		if p.OnProgress != nil {
+			p.OnProgress(tracker.record(result, len(matched)))
+		}
+	}
+	// The fetch stage stops without its error once ctx is done, which would leave the page short
+	if err := ctx.Err(); err != nil {
+		return Response[T]{}, &QueryError{Err: err}
+	}
+
+	// Calculate the start and end indices for the requested page. The walk stops early when the query
+	// runs out of items, so a page past the end is empty rather than a repeat of the last one.
+	startIndex := int((params.Page-1)*params.PageSize - checkpoint.Offset)
+	endIndex := int(params.Page*params.PageSize - checkpoint.Offset)
+
+	// Ensure the indices are within the range of the items
+	if startIndex < 0 {
+		startIndex = 0
+	}
+	if startIndex > len(itemsForPage) {
+		startIndex = len(itemsForPage)
+	}
+	if endIndex > len(itemsForPage) {
+		endIndex = len(itemsForPage)
+	}
+
+	actualSize := int64(endIndex - startIndex)
+
+	// Extract the items for the requested page
+	pageItems := itemsForPage[startIndex:endIndex]
+
+	res := Response[T]{
+		Data:    pageItems,
+		Page:    params.Page,
+		Size:    actualSize,
+		HasMore: lastEvaluatedKey != nil || endIndex < len(itemsForPage),
+	}
+	if len(warnings) > 0 || params.ConsumedCapacity != "" {
+		res.Meta = &Meta{Warnings: warnings}
+		res.Meta.capacity(params, consumed, breakdown)
+	}
+	check.report(&res.Meta)
+
+	if cacheable && p.cachesPage(params) {
+		p.Cache.PutPage(ctx, key, cachedPage[T]{res: res.clone(), stored: time.Now()})
+	}
+	return res, nil
+}
+
+// addTotal adds the number of items the query selects, and the pages they fill, to a page. A total
+// carried over in params is reused, so the pages of a cursor chain report the same total as writes land.
+func (p *Paginator[T]) addTotal(ctx context.Context, params Params, keys KeySchema, res Response[T]) (Response[T], error) {
+	total := params.Total
+	if total == nil {
+		countParams := params
+		countParams.Select = "count"
+		counted, err := p.count(ctx, countParams, keys)
+		if err != nil {
+			return Response[T]{}, err
+		}
+		total = counted.Meta.Count
+
+		// The count is part of the cost of the page
+		if res.Meta != nil && params.ConsumedCapacity != "" {
+			res.Meta.ConsumedCapacity += counted.Meta.ConsumedCapacity
+			if res.Meta.CapacityBreakdown != nil && counted.Meta.CapacityBreakdown != nil {
+				res.Meta.CapacityBreakdown.merge(*counted.Meta.CapacityBreakdown)
+			}
+		}
+	}
+
+	if params.Total != nil {
+		if res.Meta == nil {
+			res.Meta = &Meta{}
+		}
+		res.Meta.Warnings = append(res.Meta.Warnings, Warning{Code: "approximate_count", Message: "the total was counted on the first page of the cursor chain; items written since aren't reflected"})
+	}
+
+	var pages int64
+	if params.PageSize > 0 {
+		pages = (*total + params.PageSize - 1) / params.PageSize
+	}
+	res.TotalItems, res.TotalPages = total, &pages
+	return res, nil
+}
+
+// count counts the items of a partition with Select=COUNT, without reading them. Only searches that
+// DynamoDB applies narrow the count.
+func (p *Paginator[T]) count(ctx context.Context, params Params, keys KeySchema) (Response[T], error) {
+	var count int64
+	var consumed float64
+	var breakdown CapacityBreakdown
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		input := p.plannedQuery(params, keys)
+		input.ExclusiveStartKey = lastEvaluatedKey
+
+		result, err := p.client.Query(ctx, input)
+		if err != nil {
+			return Response[T]{}, &QueryError{Err: err}
+		}
+		count += int64(result.Count)
+		consumed += ConsumedUnits(result.ConsumedCapacity)
+		breakdown.add(result.ConsumedCapacity)
+
+		lastEvaluatedKey = result.LastEvaluatedKey
+		if lastEvaluatedKey == nil {
+			break
+		}
+	}
+
+	meta := &Meta{Count: &count, ConsumedCapacity: consumed}
+	meta.capacity(params, consumed, breakdown)
+	return Response[T]{Data: []T{}, Page: 1, Meta: meta}, nil
+}
+
+// capacityDetail orders the ReturnConsumedCapacity modes by how much they report
+var capacityDetail = map[types.ReturnConsumedCapacity]int{
+	types.ReturnConsumedCapacityTotal:   1,
+	types.ReturnConsumedCapacityIndexes: 2,
+}
+
+// RequestCapacity returns the more detailed of the current ReturnConsumedCapacity of an input and the
+// mode a caller needs, so adding a reason to report capacity never hides a breakdown asked for earlier
+func RequestCapacity(current, mode types.ReturnConsumedCapacity) types.ReturnConsumedCapacity {
+	if capacityDetail[mode] > capacityDetail[current] {
+		return mode
+	}
+	return current
+}
+
+// ConsumedUnits returns the capacity units DynamoDB reported for a call
+func ConsumedUnits(consumed *types.ConsumedCapacity) float64 {
+	if consumed == nil || consumed.CapacityUnits == nil {
+		return 0
+	}
+	return *consumed.CapacityUnits
+}
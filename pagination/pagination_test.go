@@ -0,0 +1,546 @@
+package pagination
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKeys = KeySchema{PartitionKey: "key_cond", SortKey: "sort_key"}
+
+// memoryClient serves the items of one partition in order, honoring Limit, ExclusiveStartKey,
+// ScanIndexForward and prefix searches
+type memoryClient struct {
+	items    []map[string]types.AttributeValue
+	err      error
+	queries  []*dynamodb.QueryInput
+	consumed *types.ConsumedCapacity
+}
+
+func newMemoryClient(sortKeys ...string) *memoryClient {
+	client := &memoryClient{}
+	for _, sk := range sortKeys {
+		client.items = append(client.items, map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "test"},
+			"sort_key": &types.AttributeValueMemberS{Value: sk},
+		})
+	}
+	return client
+}
+
+func (m *memoryClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.queries = append(m.queries, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	items := append([]map[string]types.AttributeValue{}, m.items...)
+	if params.ScanIndexForward != nil && !*params.ScanIndexForward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	// Like DynamoDB, the key condition applies before the limit
+	if params.KeyConditionExpression != nil && strings.Contains(*params.KeyConditionExpression, "begins_with(#sk, :search)") {
+		prefix := params.ExpressionAttributeValues[":search"].(*types.AttributeValueMemberS).Value
+		var matched []map[string]types.AttributeValue
+		for _, item := range items {
+			if strings.HasPrefix(item["sort_key"].(*types.AttributeValueMemberS).Value, prefix) {
+				matched = append(matched, item)
+			}
+		}
+		items = matched
+	}
+	if params.KeyConditionExpression != nil && params.ExpressionAttributeValues[":sortValue"] != nil {
+		var matched []map[string]types.AttributeValue
+		for _, item := range items {
+			if inSortRange(*params.KeyConditionExpression, params.ExpressionAttributeValues, item["sort_key"].(*types.AttributeValueMemberS).Value) {
+				matched = append(matched, item)
+			}
+		}
+		items = matched
+	}
+	if params.ExclusiveStartKey != nil {
+		start := params.ExclusiveStartKey["sort_key"].(*types.AttributeValueMemberS).Value
+		for i, item := range items {
+			if item["sort_key"].(*types.AttributeValueMemberS).Value == start {
+				items = items[i+1:]
+				break
+			}
+		}
+	}
+
+	output := &dynamodb.QueryOutput{ConsumedCapacity: m.consumed}
+	if params.Limit != nil && int(*params.Limit) < len(items) {
+		items = items[:*params.Limit]
+		output.LastEvaluatedKey = items[len(items)-1]
+	}
+	output.ScannedCount = int32(len(items))
+	output.Count = int32(len(items))
+	if params.Select != types.SelectCount {
+		output.Items = items
+	}
+	return output, nil
+}
+
+// inSortRange applies the sort key range of a key condition to a sort key
+func inSortRange(condition string, values map[string]types.AttributeValue, sk string) bool {
+	value := values[":sortValue"].(*types.AttributeValueMemberS).Value
+	switch {
+	case strings.HasSuffix(condition, "begins_with(#sk, :sortValue)"):
+		return strings.HasPrefix(sk, value)
+	case strings.HasSuffix(condition, "#sk BETWEEN :sortValue AND :sortEnd"):
+		return sk >= value && sk <= values[":sortEnd"].(*types.AttributeValueMemberS).Value
+	case strings.HasSuffix(condition, "#sk > :sortValue"):
+		return sk > value
+	case strings.HasSuffix(condition, "#sk < :sortValue"):
+		return sk < value
+	}
+	return true
+}
+
+func sortKeys(entries []Entry) []string {
+	keys := []string{}
+	for _, entry := range entries {
+		keys = append(keys, entry.SortKey)
+	}
+	return keys
+}
+
+func TestGetPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   Params
+		expected []string
+		hasMore  bool
+	}{
+		{name: "first page", params: Params{Page: 1, PageSize: 2}, expected: []string{"a", "b"}, hasMore: true},
+		{name: "last page", params: Params{Page: 3, PageSize: 2}, expected: []string{"e"}},
+		{name: "descending", params: Params{Page: 1, PageSize: 2, OrderBy: "-sort_key"}, expected: []string{"e", "d"}, hasMore: true},
+		{name: "descending second page", params: Params{Page: 2, PageSize: 2, OrderBy: "-sort_key"}, expected: []string{"c", "b"}, hasMore: true},
+		{name: "descending last page", params: Params{Page: 3, PageSize: 2, OrderBy: "-sort_key"}, expected: []string{"a"}},
+		{name: "explicit ascending", params: Params{Page: 2, PageSize: 2, OrderBy: "+sort_key"}, expected: []string{"c", "d"}, hasMore: true},
+		{name: "past the end", params: Params{Page: 4, PageSize: 2}, expected: []string{}},
+		{name: "search", params: Params{Page: 1, PageSize: 5, Search: "c"}, expected: []string{"c"}},
+		{name: "search ignores case", params: Params{Page: 1, PageSize: 5, Search: "C"}, expected: []string{"c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newMemoryClient("a", "b", "c", "d", "e")
+			params := test.params
+			params.KeyCondition = "test"
+
+			res, err := New[Entry](client, "Entries", testKeys).GetPage(context.Background(), params)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, sortKeys(res.Data))
+			assert.Equal(t, test.params.Page, res.Page)
+			assert.Equal(t, int64(len(test.expected)), res.Size)
+			assert.Equal(t, test.hasMore, res.HasMore)
+
+			input := client.queries[0]
+			assert.Equal(t, "Entries", *input.TableName)
+			assert.Equal(t, "#pk = :keyCond", *input.KeyConditionExpression)
+			assert.Equal(t, "key_cond", input.ExpressionAttributeNames["#pk"])
+		})
+	}
+}
+
+func TestGetPageCursor(t *testing.T) {
+	client := newMemoryClient("a", "b", "c")
+	paginator := New[Entry](client, "Entries", testKeys)
+
+	var pages [][]string
+	params := Params{KeyCondition: "test", PageSize: 2, CursorMode: true}
+	for {
+		res, err := paginator.GetPage(context.Background(), params)
+		require.NoError(t, err)
+		pages = append(pages, sortKeys(res.Data))
+		if res.NextCursor == "" {
+			assert.False(t, res.HasMore)
+			break
+		}
+		params.Cursor, err = DecodeCursor(res.NextCursor)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, [][]string{{"a", "b"}, {"c"}}, pages)
+	assert.Len(t, client.queries, 2)
+}
+
+func TestGetPageSearch(t *testing.T) {
+	client := newMemoryClient("ab", "b", "ba", "cb")
+	paginator := New[Entry](client, "Entries", testKeys)
+
+	// A query's FilterExpression can't reference the sort key, so contains is matched after the query
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 4, Search: "B"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab", "b", "ba", "cb"}, sortKeys(res.Data))
+	assert.Nil(t, client.queries[0].FilterExpression)
+	assert.Equal(t, "#pk = :keyCond", *client.queries[0].KeyConditionExpression)
+
+	res, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 2, Search: "b", SearchMode: "prefix", Select: "keys_only"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "ba"}, sortKeys(res.Data))
+	input := client.queries[1]
+	assert.Equal(t, "#pk = :keyCond AND begins_with(#sk, :search)", *input.KeyConditionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond", "#sk": "sort_key"}, input.ExpressionAttributeNames)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "b"}, input.ExpressionAttributeValues[":search"])
+
+	res, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Select: "count", Search: "b", SearchMode: "prefix"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), *res.Meta.Count)
+}
+
+func TestGetPageSortRange(t *testing.T) {
+	client := newMemoryClient("a", "b", "ba", "c", "d")
+	paginator := New[Entry](client, "Entries", testKeys)
+
+	for _, tc := range []struct {
+		sortRange SortRange
+		condition string
+		expected  []string
+	}{
+		{SortRange{Op: "begins_with", Value: "b"}, "#pk = :keyCond AND begins_with(#sk, :sortValue)", []string{"b", "ba"}},
+		{SortRange{Op: "between", Value: "b", End: "c"}, "#pk = :keyCond AND #sk BETWEEN :sortValue AND :sortEnd", []string{"b", "ba", "c"}},
+		{SortRange{Op: ">", Value: "ba"}, "#pk = :keyCond AND #sk > :sortValue", []string{"c", "d"}},
+		{SortRange{Op: "<", Value: "b"}, "#pk = :keyCond AND #sk < :sortValue", []string{"a"}},
+	} {
+		sortRange := tc.sortRange
+		res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 3, SortRange: &sortRange})
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, sortKeys(res.Data), tc.sortRange.Op)
+
+		input := client.queries[len(client.queries)-1]
+		assert.Equal(t, tc.condition, *input.KeyConditionExpression)
+		assert.Equal(t, "sort_key", input.ExpressionAttributeNames["#sk"])
+	}
+
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Select: "count", SortRange: &SortRange{Op: ">", Value: "b"}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), *res.Meta.Count)
+}
+
+func TestGetPageCount(t *testing.T) {
+	client := newMemoryClient("a", "b", "c")
+
+	res, err := New[Entry](client, "Entries", testKeys).GetPage(context.Background(), Params{KeyCondition: "test", Select: "count"})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, int64(3), *res.Meta.Count)
+	assert.Equal(t, types.SelectCount, client.queries[0].Select)
+}
+
+func TestGetPageKeysOnly(t *testing.T) {
+	client := newMemoryClient("a")
+
+	_, err := New[Entry](client, "Entries", testKeys).GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1, Select: "keys_only"})
+	require.NoError(t, err)
+	input := client.queries[0]
+	assert.Equal(t, "#pk, #sk", *input.ProjectionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond", "#sk": "sort_key"}, input.ExpressionAttributeNames)
+}
+
+func TestGetPageWithoutSortKey(t *testing.T) {
+	client := newMemoryClient("a")
+	paginator := New[Entry](client, "Entries", KeySchema{PartitionKey: "key_cond"})
+
+	_, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1, Select: "keys_only"})
+	require.NoError(t, err)
+	require.Len(t, client.queries, 1)
+	assert.Equal(t, "#pk", *client.queries[0].ProjectionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond"}, client.queries[0].ExpressionAttributeNames)
+
+	for _, params := range []Params{
+		{KeyCondition: "test", Page: 1, PageSize: 1, Search: "a"},
+		{KeyCondition: "test", Page: 1, PageSize: 1, SortRange: &SortRange{Op: ">", Value: "a"}},
+		{KeyCondition: "test", Page: 1, PageSize: 1, OrderBy: "-sort_key"},
+	} {
+		_, err := paginator.GetPage(context.Background(), params)
+		assert.Error(t, err)
+	}
+	assert.Len(t, client.queries, 1, "no query is sent with conditions on a missing sort key")
+}
+
+func TestGetPageFields(t *testing.T) {
+	client := newMemoryClient("a")
+	paginator := New[Entry](client, "Entries", testKeys)
+	var partial []bool
+	paginator.Decode = func(item map[string]types.AttributeValue, p bool) (Entry, []Warning, bool, error) {
+		partial = append(partial, p)
+		return Unmarshal[Entry](item, p)
+	}
+
+	_, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1, Fields: []string{"status", "sort_key", "size"}})
+	require.NoError(t, err)
+	input := client.queries[0]
+	// Reserved words go through placeholders, and the keys are always projected
+	assert.Equal(t, "#pk, #sk, #f0, #f2", *input.ProjectionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond", "#sk": "sort_key", "#f0": "status", "#f2": "size"}, input.ExpressionAttributeNames)
+	assert.Equal(t, []bool{true}, partial)
+
+	// Index reads also project the table keys, unless they are among the fields
+	paginator.Indexes = map[string]KeySchema{"by_status": {PartitionKey: "status", SortKey: "sort_key"}}
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "open", IndexName: "by_status", Page: 1, PageSize: 1, Fields: []string{"key_cond"}})
+	require.NoError(t, err)
+	assert.Equal(t, "#pk, #sk, #f0", *client.queries[1].ProjectionExpression)
+
+	// Counts read no attributes
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Select: "count", Fields: []string{"status"}})
+	require.NoError(t, err)
+	assert.Nil(t, client.queries[2].ProjectionExpression)
+}
+
+func TestGetPageConsistentRead(t *testing.T) {
+	client := newMemoryClient("a")
+	paginator := New[Entry](client, "Entries", testKeys)
+	paginator.Indexes = map[string]KeySchema{
+		"by_status":  {PartitionKey: "status", SortKey: "sort_key"},
+		"by_created": {PartitionKey: "key_cond", SortKey: "created_at", Local: true},
+	}
+
+	_, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1, ConsistentRead: true, IncludeCount: true})
+	require.NoError(t, err)
+	// The count is read consistently too
+	require.Len(t, client.queries, 2)
+	for _, input := range client.queries {
+		assert.True(t, *input.ConsistentRead)
+	}
+
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", IndexName: "by_created", Page: 1, PageSize: 1, ConsistentRead: true})
+	require.NoError(t, err)
+	assert.True(t, *client.queries[2].ConsistentRead)
+
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "open", IndexName: "by_status", Page: 1, PageSize: 1, ConsistentRead: true})
+	assert.EqualError(t, err, `index "by_status" is a global secondary index, which doesn't support consistent reads`)
+	assert.Len(t, client.queries, 3)
+
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1})
+	require.NoError(t, err)
+	assert.Nil(t, client.queries[3].ConsistentRead)
+}
+
+func TestGetPageDecode(t *testing.T) {
+	client := newMemoryClient("a", "b", "c")
+	paginator := New[Entry](client, "Entries", testKeys)
+	paginator.Decode = func(item map[string]types.AttributeValue, partial bool) (Entry, []Warning, bool, error) {
+		entry, _, _, err := Unmarshal[Entry](item, partial)
+		if entry.SortKey == "b" {
+			return entry, []Warning{{Code: "dropped", Key: entry.Key()}}, false, err
+		}
+		return entry, nil, true, err
+	}
+
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "c"}, sortKeys(res.Data))
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, []Warning{{Code: "dropped", Key: map[string]string{"key_cond": "test", "sort_key": "b"}}}, res.Meta.Warnings)
+
+	decodeErr := errors.New("bad item")
+	paginator.Decode = func(map[string]types.AttributeValue, bool) (Entry, []Warning, bool, error) {
+		return Entry{}, nil, false, decodeErr
+	}
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 3})
+	assert.Same(t, decodeErr, err)
+}
+
+func TestGetPageQueryError(t *testing.T) {
+	client := newMemoryClient()
+	client.err = context.DeadlineExceeded
+
+	_, err := New[Entry](client, "Entries", testKeys).GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 1})
+	var queryErr *QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGetPageProgress(t *testing.T) {
+	client := newMemoryClient("a", "b", "c")
+	paginator := New[Entry](client, "Entries", testKeys)
+
+	var reports []Progress
+	paginator.OnProgress = func(p Progress) { reports = append(reports, p) }
+
+	_, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 1})
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, int64(2), reports[1].RoundTrips)
+	assert.Equal(t, int64(2), reports[1].ItemsScanned)
+	assert.Equal(t, types.ReturnConsumedCapacityTotal, client.queries[0].ReturnConsumedCapacity)
+}
+
+func TestGetPageCapacityBreakdown(t *testing.T) {
+	units := func(v float64) *float64 { return &v }
+	client := newMemoryClient("a", "b", "c")
+	client.consumed = &types.ConsumedCapacity{
+		CapacityUnits:          units(1.5),
+		Table:                  &types.Capacity{CapacityUnits: units(0.5)},
+		GlobalSecondaryIndexes: map[string]types.Capacity{"by_status": {CapacityUnits: units(1)}},
+	}
+	paginator := New[Entry](client, "Entries", testKeys)
+	// Progress asks for the total, which doesn't replace the breakdown
+	paginator.OnProgress = func(Progress) {}
+
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 1, ConsumedCapacity: "indexes"})
+	require.NoError(t, err)
+	assert.Equal(t, types.ReturnConsumedCapacityIndexes, client.queries[0].ReturnConsumedCapacity)
+	assert.Equal(t, 3.0, res.Meta.ConsumedCapacity)
+	assert.Equal(t, &CapacityBreakdown{Table: 1, GlobalSecondaryIndexes: map[string]float64{"by_status": 2}}, res.Meta.CapacityBreakdown)
+
+	res, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Select: "count", ConsumedCapacity: "total"})
+	require.NoError(t, err)
+	assert.Equal(t, 1.5, res.Meta.ConsumedCapacity)
+	assert.Nil(t, res.Meta.CapacityBreakdown)
+}
+
+func TestRequestCapacity(t *testing.T) {
+	assert.Equal(t, types.ReturnConsumedCapacityTotal, RequestCapacity("", types.ReturnConsumedCapacityTotal))
+	assert.Equal(t, types.ReturnConsumedCapacityIndexes, RequestCapacity(types.ReturnConsumedCapacityIndexes, types.ReturnConsumedCapacityTotal))
+	assert.Equal(t, types.ReturnConsumedCapacityTotal, RequestCapacity(types.ReturnConsumedCapacityTotal, types.ReturnConsumedCapacityNone))
+}
+
+type order struct {
+	Customer string `dynamodbav:"customer"`
+	ID       string `dynamodbav:"order_id"`
+	Total    int    `dynamodbav:"total"`
+}
+
+func TestGetPageItemType(t *testing.T) {
+	client := &memoryClient{}
+	for i, id := range []string{"o-1", "o-2", "o-3"} {
+		client.items = append(client.items, map[string]types.AttributeValue{
+			"customer": &types.AttributeValueMemberS{Value: "c-1"},
+			"order_id": &types.AttributeValueMemberS{Value: id},
+			"sort_key": &types.AttributeValueMemberS{Value: id},
+			"total":    &types.AttributeValueMemberN{Value: strconv.Itoa((i + 1) * 10)},
+		})
+	}
+	keys := KeySchema{PartitionKey: "customer", SortKey: "order_id"}
+
+	res, err := New[order](client, "Orders", keys).GetPage(context.Background(), Params{KeyCondition: "c-1", Page: 1, PageSize: 3, Search: "-2"})
+	require.NoError(t, err)
+	assert.Equal(t, []order{{Customer: "c-1", ID: "o-2", Total: 20}}, res.Data)
+	assert.Equal(t, "customer", client.queries[0].ExpressionAttributeNames["#pk"])
+}
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		orderBy    string
+		attribute  string
+		descending bool
+	}{
+		{orderBy: "sort_key", attribute: "sort_key"},
+		{orderBy: "+sort_key", attribute: "sort_key"},
+		{orderBy: "-sort_key", attribute: "sort_key", descending: true},
+		{orderBy: "-", attribute: "", descending: true},
+	}
+	for _, test := range tests {
+		attribute, descending := ParseOrderBy(test.orderBy)
+		assert.Equal(t, test.attribute, attribute, test.orderBy)
+		assert.Equal(t, test.descending, descending, test.orderBy)
+	}
+}
+
+func TestGetPageOrderDirection(t *testing.T) {
+	client := newMemoryClient("a", "b", "c", "d", "e")
+	_, err := New[Entry](client, "Entries", testKeys).GetPage(context.Background(), Params{KeyCondition: "test", Page: 3, PageSize: 2, OrderBy: "-sort_key"})
+	require.NoError(t, err)
+
+	// Every round trip of the walk reads in the same direction
+	require.Len(t, client.queries, 3)
+	for _, input := range client.queries {
+		assert.False(t, *input.ScanIndexForward)
+	}
+}
+
+func TestGetPageInvalidOrder(t *testing.T) {
+	paginator := New[Entry](newMemoryClient("a"), "Entries", testKeys)
+	for _, orderBy := range []string{"-", "created_at", "-key_cond"} {
+		_, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 2, OrderBy: orderBy})
+		assert.Error(t, err, orderBy)
+	}
+
+	_, err := New[Entry](newMemoryClient("a"), "Entries", KeySchema{PartitionKey: "key_cond"}).GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 2, OrderBy: "sort_key"})
+	assert.Error(t, err)
+}
+
+func TestKeySchemaQuery(t *testing.T) {
+	input := testKeys.Query("Entries", "test")
+	assert.Equal(t, "#pk = :keyCond", *input.KeyConditionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond"}, input.ExpressionAttributeNames)
+
+	custom := KeySchema{PartitionKey: "key_cond", SortKey: "sort_key", KeyCondition: "pk = :keyCond"}
+	input = custom.Query("Entries", "test")
+	assert.Equal(t, "pk = :keyCond", *input.KeyConditionExpression)
+	assert.Nil(t, input.ExpressionAttributeNames)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "test"}, input.ExpressionAttributeValues[":keyCond"])
+}
+
+func TestGetPageIndex(t *testing.T) {
+	client := &memoryClient{}
+	for _, id := range []string{"o-1", "o-2"} {
+		client.items = append(client.items, map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "c-1"},
+			"sort_key": &types.AttributeValueMemberS{Value: id},
+			"status":   &types.AttributeValueMemberS{Value: "open"},
+		})
+	}
+	paginator := New[Entry](client, "Entries", testKeys)
+	paginator.Indexes = map[string]KeySchema{
+		"by_status": {PartitionKey: "status", SortKey: "sort_key"},
+	}
+
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "open", IndexName: "by_status", Page: 1, PageSize: 2, Select: "keys_only"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"o-1", "o-2"}, sortKeys(res.Data))
+	input := client.queries[0]
+	assert.Equal(t, "by_status", *input.IndexName)
+	assert.Equal(t, "#pk = :keyCond", *input.KeyConditionExpression)
+	// The table's sort key is also the index sort key, so only the table partition key is added
+	assert.Equal(t, "#pk, #sk, #tpk", *input.ProjectionExpression)
+	assert.Equal(t, map[string]string{"#pk": "status", "#sk": "sort_key", "#tpk": "key_cond"}, input.ExpressionAttributeNames)
+
+	_, err = paginator.GetPage(context.Background(), Params{KeyCondition: "open", IndexName: "by_owner", Page: 1, PageSize: 2})
+	assert.EqualError(t, err, `unknown index "by_owner"`)
+}
+
+func TestGetPageIncludeCount(t *testing.T) {
+	client := newMemoryClient("a", "b", "c", "d", "e")
+	client.consumed = &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}
+	paginator := New[Entry](client, "Entries", testKeys)
+
+	res, err := paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2, IncludeCount: true, ConsumedCapacity: "total"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, sortKeys(res.Data))
+	assert.Equal(t, int64(5), *res.TotalItems)
+	assert.Equal(t, int64(3), *res.TotalPages)
+	// Two round trips walk to the page and one counts the partition
+	require.Len(t, client.queries, 3)
+	assert.Equal(t, types.SelectCount, client.queries[2].Select)
+	assert.Equal(t, 1.5, res.Meta.ConsumedCapacity)
+
+	// A total carried over from an earlier page isn't counted again
+	total := int64(7)
+	res, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 2, IncludeCount: true, Total: &total, CursorMode: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), *res.TotalItems)
+	assert.Equal(t, int64(4), *res.TotalPages)
+	assert.Len(t, client.queries, 4)
+	require.NotNil(t, res.Meta)
+	require.Len(t, res.Meta.Warnings, 1)
+	assert.Equal(t, "approximate_count", res.Meta.Warnings[0].Code)
+
+	res, err = paginator.GetPage(context.Background(), Params{KeyCondition: "test", Page: 1, PageSize: 2})
+	require.NoError(t, err)
+	assert.Nil(t, res.TotalItems)
+	assert.Nil(t, res.TotalPages)
+}
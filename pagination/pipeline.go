@@ -0,0 +1,64 @@
+package pagination
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// pipelineDepth is how many query results the fetch stage may run ahead of decoding
+const pipelineDepth = 2
+
+type pageDepthKey struct{}
+
+// WithPageDepth records which round trip of a request a query is
+func WithPageDepth(ctx context.Context, depth int64) context.Context {
+	return context.WithValue(ctx, pageDepthKey{}, depth)
+}
+
+// PageDepth returns the round trip recorded by WithPageDepth, or 0
+func PageDepth(ctx context.Context) int64 {
+	depth, _ := ctx.Value(pageDepthKey{}).(int64)
+	return depth
+}
+
+// fetchedPage is one query result handed from the fetch stage to the decode stage
+type fetchedPage struct {
+	number int64
+	result *dynamodb.QueryOutput
+	err    error
+}
+
+// fetchPages runs the round trips of a page walk in its own goroutine so DynamoDB I/O overlaps with
+// decoding the previous results. Every query continues from the previous one; the walk stops after
+// the last page, after page last, on the first error or when ctx is cancelled. Results arrive in order
+// on a channel buffered to pipelineDepth, which is closed when the walk ends.
+func fetchPages(ctx context.Context, client DynamoClient, last int64, input func(start map[string]types.AttributeValue) *dynamodb.QueryInput) <-chan fetchedPage {
+	return fetchPagesFrom(ctx, client, 1, nil, last, input)
+}
+
+// fetchPagesFrom is fetchPages resuming the walk at round trip first, which continues from start
+func fetchPagesFrom(ctx context.Context, client DynamoClient, first int64, start map[string]types.AttributeValue, last int64, input func(start map[string]types.AttributeValue) *dynamodb.QueryInput) <-chan fetchedPage {
+	pages := make(chan fetchedPage, pipelineDepth)
+
+	go func() {
+		defer close(pages)
+
+		for number := first; ; number++ {
+			result, err := client.Query(WithPageDepth(ctx, number), input(start))
+
+			select {
+			case pages <- fetchedPage{number: number, result: result, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil || result.LastEvaluatedKey == nil || number >= last {
+				return
+			}
+			start = result.LastEvaluatedKey
+		}
+	}()
+
+	return pages
+}
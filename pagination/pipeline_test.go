@@ -0,0 +1,99 @@
+package pagination
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchPages(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3")
+	limit := int32(1)
+
+	query := func(start map[string]types.AttributeValue) *dynamodb.QueryInput {
+		input := testKeys.Query("Entries", "test")
+		input.Limit = &limit
+		input.ExclusiveStartKey = start
+		return input
+	}
+
+	tests := []struct {
+		name     string
+		last     int64
+		expected []string
+	}{
+		{name: "stops at last page", last: 2, expected: []string{"item1", "item2"}},
+		{name: "stops at end of results", last: 10, expected: []string{"item1", "item2", "item3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sortKeys []string
+			var number int64
+			for page := range fetchPages(context.Background(), client, test.last, query) {
+				require.NoError(t, page.err)
+				number++
+				assert.Equal(t, number, page.number)
+				for _, item := range page.result.Items {
+					sortKeys = append(sortKeys, item["sort_key"].(*types.AttributeValueMemberS).Value)
+				}
+			}
+			assert.Equal(t, test.expected, sortKeys)
+		})
+	}
+}
+
+func TestFetchPagesStopsOnError(t *testing.T) {
+	client := newMemoryClient()
+	client.err = errors.New("boom")
+
+	var pages []fetchedPage
+	for page := range fetchPages(context.Background(), client, 5, func(map[string]types.AttributeValue) *dynamodb.QueryInput {
+		return testKeys.Query("Entries", "test")
+	}) {
+		pages = append(pages, page)
+	}
+
+	require.Len(t, pages, 1)
+	assert.EqualError(t, pages[0].err, "boom")
+	assert.Len(t, client.queries, 1)
+}
+
+func TestFetchPagesCancelled(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3")
+	limit := int32(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pages := fetchPages(ctx, client, 10, func(start map[string]types.AttributeValue) *dynamodb.QueryInput {
+		input := testKeys.Query("Entries", "test")
+		input.Limit = &limit
+		input.ExclusiveStartKey = start
+		return input
+	})
+
+	first := <-pages
+	require.NoError(t, first.err)
+	cancel()
+
+	// The channel is closed once the fetch stage notices the cancellation
+	for range pages {
+	}
+}
+
+func TestGetPageCancelled(t *testing.T) {
+	p := New[Entry](newMemoryClient("item1", "item2", "item3"), "Entries", testKeys)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A walk cut short fails instead of serving a short page
+	_, err := p.GetPage(ctx, Params{KeyCondition: "test", Page: 2, PageSize: 1})
+	var queryErr *QueryError
+	require.ErrorAs(t, err, &queryErr)
+	assert.ErrorIs(t, err, context.Canceled)
+}
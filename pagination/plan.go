@@ -0,0 +1,219 @@
+package pagination
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// planShape is the normalized shape of a query: everything that decides its expressions, attribute
+// names and index, but none of the values it is run with
+type planShape struct {
+	table           string
+	scan            bool
+	tableKeys, keys KeySchema
+	index           string
+	selectMode      string
+	fields          string
+	order           string
+	search          bool
+	searchMode      string
+	sortOp          string
+	filter          string
+	capacity        string
+	consistent      bool
+	progress        bool
+}
+
+// plan is a query built for a shape, without its values, limit and start key
+type plan struct {
+	input *dynamodb.QueryInput
+	// values are the placeholders bound from the parameters of each request
+	values []string
+}
+
+// PlanCache keeps the query plans of the shapes a Paginator serves, so repeated requests skip building
+// their expressions. It holds up to a fixed number of plans and is safe for concurrent use.
+type PlanCache struct {
+	mu     sync.Mutex
+	size   int
+	plans  map[planShape]*plan
+	hits   int64
+	misses int64
+}
+
+// PlanCacheStats reports how often requests found their plan in a PlanCache
+type PlanCacheStats struct {
+	Plans   int
+	Hits    int64
+	Misses  int64
+	HitRate float64
+}
+
+// NewPlanCache creates a cache holding up to size plans
+func NewPlanCache(size int) *PlanCache {
+	return &PlanCache{size: size, plans: map[planShape]*plan{}}
+}
+
+// Stats returns the number of cached plans and the hit rate of lookups so far
+func (c *PlanCache) Stats() PlanCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := PlanCacheStats{Plans: len(c.plans), Hits: c.hits, Misses: c.misses}
+	if lookups := c.hits + c.misses; lookups > 0 {
+		stats.HitRate = float64(c.hits) / float64(lookups)
+	}
+	return stats
+}
+
+func (c *PlanCache) get(shape planShape) *plan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.plans[shape]; ok {
+		c.hits++
+		return cached
+	}
+	c.misses++
+	return nil
+}
+
+func (c *PlanCache) put(shape planShape, cached *plan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+	if _, ok := c.plans[shape]; !ok && len(c.plans) >= c.size {
+		// Shapes are few in practice, so making room for a new one by dropping any other is enough
+		for evicted := range c.plans {
+			delete(c.plans, evicted)
+			break
+		}
+	}
+	c.plans[shape] = cached
+}
+
+// shape normalizes the parameters of a query to the ones its plan depends on
+func (p *Paginator[T]) shape(params Params, keys KeySchema) planShape {
+	shape := planShape{
+		table:      p.table,
+		scan:       p.scan,
+		tableKeys:  p.keys,
+		keys:       keys,
+		index:      params.IndexName,
+		selectMode: params.Select,
+		fields:     strings.Join(params.Fields, "\x00"),
+		capacity:   params.ConsumedCapacity,
+		consistent: params.ConsistentRead,
+		progress:   p.OnProgress != nil,
+		filter:     params.Filter.shape(),
+	}
+	if params.OrderBy != "" {
+		shape.order = "asc"
+		if params.Descending() {
+			shape.order = "desc"
+		}
+	}
+	if params.Search != "" {
+		shape.search = true
+		shape.searchMode = params.SearchMode
+	}
+	if params.SortRange != nil {
+		shape.sortOp = params.SortRange.Op
+	}
+	return shape
+}
+
+// plannedQuery returns the QueryInput of a round trip, without its limit and start key, from the
+// cached plan of its shape when there is one
+func (p *Paginator[T]) plannedQuery(params Params, keys KeySchema) *dynamodb.QueryInput {
+	if p.Plans == nil {
+		return p.buildQuery(params, keys)
+	}
+
+	shape := p.shape(params, keys)
+	if cached := p.Plans.get(shape); cached != nil {
+		return cached.bind(params)
+	}
+
+	input := p.buildQuery(params, keys)
+	if cached, ok := newPlan(input); ok {
+		p.Plans.put(shape, cached)
+	}
+	return input
+}
+
+// newPlan keeps the parts of input that don't depend on the request, or reports false when input
+// binds values a plan can't fill in
+func newPlan(input *dynamodb.QueryInput) (*plan, bool) {
+	cached := &plan{input: cloneQuery(input)}
+	cached.input.ExpressionAttributeValues = nil
+	for name := range input.ExpressionAttributeValues {
+		if planValues[name] == nil {
+			return nil, false
+		}
+		cached.values = append(cached.values, name)
+	}
+	return cached, true
+}
+
+// bind fills the plan in with the values of a request
+func (c *plan) bind(params Params) *dynamodb.QueryInput {
+	input := cloneQuery(c.input)
+	if len(c.values) > 0 {
+		input.ExpressionAttributeValues = make(map[string]types.AttributeValue, len(c.values))
+		for _, name := range c.values {
+			input.ExpressionAttributeValues[name] = &types.AttributeValueMemberS{Value: planValues[name](params)}
+		}
+	}
+	return input
+}
+
+// planValues are the placeholders of the expressions this package builds, and the values a request
+// binds to them
+var planValues = map[string]func(Params) string{
+	":keyCond":   func(p Params) string { return p.KeyCondition },
+	":search":    func(p Params) string { return p.Search },
+	":sortValue": func(p Params) string { return p.SortRange.Value },
+	":sortEnd":   func(p Params) string { return p.SortRange.End },
+}
+
+// cloneQuery copies the parts of a QueryInput a plan holds, so callers can change the copy freely
+func cloneQuery(input *dynamodb.QueryInput) *dynamodb.QueryInput {
+	clone := &dynamodb.QueryInput{
+		TableName:                 copyString(input.TableName),
+		IndexName:                 copyString(input.IndexName),
+		KeyConditionExpression:    copyString(input.KeyConditionExpression),
+		FilterExpression:          copyString(input.FilterExpression),
+		ProjectionExpression:      copyString(input.ProjectionExpression),
+		Select:                    input.Select,
+		ReturnConsumedCapacity:    input.ReturnConsumedCapacity,
+		ExpressionAttributeValues: input.ExpressionAttributeValues,
+	}
+	if input.ScanIndexForward != nil {
+		clone.ScanIndexForward = aws.Bool(*input.ScanIndexForward)
+	}
+	if input.ConsistentRead != nil {
+		clone.ConsistentRead = aws.Bool(*input.ConsistentRead)
+	}
+	if input.ExpressionAttributeNames != nil {
+		clone.ExpressionAttributeNames = make(map[string]string, len(input.ExpressionAttributeNames))
+		for placeholder, name := range input.ExpressionAttributeNames {
+			clone.ExpressionAttributeNames[placeholder] = name
+		}
+	}
+	return clone
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	return aws.String(*s)
+}
@@ -0,0 +1,61 @@
+package pagination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanCache(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3")
+	p := New[Entry](client, "TableName", testKeys)
+	p.Plans = NewPlanCache(10)
+
+	params := Params{KeyCondition: "test", Page: 1, PageSize: 2, OrderBy: "-sort_key", Search: "item", SearchMode: "prefix", Select: "keys_only"}
+	_, err := p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+	params.KeyCondition, params.Search = "other", "it"
+	_, err = p.GetPage(context.Background(), params)
+	require.NoError(t, err)
+
+	assert.Equal(t, PlanCacheStats{Plans: 1, Hits: 1, Misses: 1, HitRate: 0.5}, p.Plans.Stats())
+
+	// The cached plan is bound to the values of the request, as if it had been built for it
+	uncached := New[Entry](client, "TableName", testKeys)
+	expected := uncached.pageQuery(params, testKeys, nil)
+	planned := client.queries[len(client.queries)-1]
+	assert.Equal(t, expected, planned)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "other"}, planned.ExpressionAttributeValues[":keyCond"])
+
+	// Changing a query doesn't change its plan
+	planned.ExpressionAttributeNames["#pk"] = "changed"
+	assert.Equal(t, expected, p.pageQuery(params, testKeys, nil))
+}
+
+func TestPlanCacheShapes(t *testing.T) {
+	p := New[Entry](newMemoryClient(), "TableName", testKeys)
+	p.Plans = NewPlanCache(1)
+
+	ascending := Params{KeyCondition: "test", PageSize: 2, OrderBy: "sort_key"}
+	descending := Params{KeyCondition: "test", PageSize: 2, OrderBy: "-sort_key"}
+	assert.True(t, *p.pageQuery(ascending, testKeys, nil).ScanIndexForward)
+	assert.False(t, *p.pageQuery(descending, testKeys, nil).ScanIndexForward)
+	assert.True(t, *p.pageQuery(ascending, testKeys, nil).ScanIndexForward)
+
+	// Only one shape fits, so each order evicted the other
+	assert.Equal(t, PlanCacheStats{Plans: 1, Misses: 3}, p.Plans.Stats())
+
+	// Projections are part of the shape too
+	projected := Params{KeyCondition: "test", PageSize: 2, Fields: []string{"status"}}
+	assert.Equal(t, "#pk, #sk, #f0", *p.pageQuery(projected, testKeys, nil).ProjectionExpression)
+	projected.Fields = []string{"size"}
+	assert.Equal(t, "size", p.pageQuery(projected, testKeys, nil).ExpressionAttributeNames["#f0"])
+
+	// And so are consistent reads
+	consistent := Params{KeyCondition: "test", PageSize: 2, ConsistentRead: true}
+	assert.True(t, *p.pageQuery(consistent, testKeys, nil).ConsistentRead)
+	assert.Nil(t, p.pageQuery(ascending, testKeys, nil).ConsistentRead)
+}
@@ -0,0 +1,54 @@
+package pagination
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Progress is reported while a page is being assembled
+type Progress struct {
+	RoundTrips int64
+	// ItemsScanned counts the items DynamoDB read, including those a filter expression left out
+	ItemsScanned int64
+	ItemsMatched int64
+	ConsumedRCU  float64
+	ElapsedMs    int64
+	// EtaMs estimates the time left to reach the requested page from the rate of round trips so far
+	EtaMs int64
+}
+
+type progressTracker struct {
+	start      time.Time
+	targetPage int64
+	progress   Progress
+}
+
+func newProgressTracker(targetPage int64) *progressTracker {
+	return &progressTracker{start: time.Now(), targetPage: targetPage}
+}
+
+// record accounts for one DynamoDB round trip returning result, of which matched items were kept, and
+// returns the updated progress
+func (t *progressTracker) record(result *dynamodb.QueryOutput, matched int) Progress {
+	p := &t.progress
+	p.RoundTrips++
+	scanned := int64(result.ScannedCount)
+	if n := int64(len(result.Items)); scanned < n {
+		scanned = n
+	}
+	p.ItemsScanned += scanned
+	p.ItemsMatched += int64(matched)
+	if consumed := result.ConsumedCapacity; consumed != nil && consumed.CapacityUnits != nil {
+		p.ConsumedRCU += *consumed.CapacityUnits
+	}
+
+	elapsed := time.Since(t.start)
+	p.ElapsedMs = elapsed.Milliseconds()
+	p.EtaMs = 0
+	if remaining := t.targetPage - p.RoundTrips; remaining > 0 {
+		p.EtaMs = (elapsed * time.Duration(remaining) / time.Duration(p.RoundTrips)).Milliseconds()
+	}
+
+	return *p
+}
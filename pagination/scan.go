@@ -0,0 +1,66 @@
+package pagination
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// ScanClient is the part of the DynamoDB API a scanning Paginator uses
+type ScanClient interface {
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+// NewScan creates a Paginator over every item of table, read with Scan instead of Query. Pages follow
+// the order DynamoDB scans the table in, so KeyCondition and OrderBy are ignored. Searches other than
+// client-side ones are sent as a FilterExpression.
+func NewScan[T any](client ScanClient, table string, keys KeySchema) *Paginator[T] {
+	p := New[T](scanQueries{client: client}, table, keys)
+	p.scan = true
+	return p
+}
+
+// NewSegmentScan creates a scanning Paginator over one segment of a parallel scan: DynamoDB splits the
+// table into totalSegments disjoint segments, which separate paginators can read concurrently
+func NewSegmentScan[T any](client ScanClient, table string, keys KeySchema, segment, totalSegments int32) *Paginator[T] {
+	p := New[T](scanQueries{client: client, segment: &segment, totalSegments: &totalSegments}, table, keys)
+	p.scan = true
+	return p
+}
+
+// scanQueries runs the queries of a scanning Paginator as Scan calls, so that page walks, cursors and
+// counts work the same way for both
+type scanQueries struct {
+	client ScanClient
+	// segment and totalSegments select the segment of a parallel scan, or are nil to scan the whole table
+	segment       *int32
+	totalSegments *int32
+}
+
+func (s scanQueries) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	result, err := s.client.Scan(ctx, &dynamodb.ScanInput{
+		TableName:                 params.TableName,
+		IndexName:                 params.IndexName,
+		Limit:                     params.Limit,
+		ExclusiveStartKey:         params.ExclusiveStartKey,
+		ProjectionExpression:      params.ProjectionExpression,
+		FilterExpression:          params.FilterExpression,
+		ExpressionAttributeNames:  params.ExpressionAttributeNames,
+		ExpressionAttributeValues: params.ExpressionAttributeValues,
+		Select:                    params.Select,
+		ConsistentRead:            params.ConsistentRead,
+		ReturnConsumedCapacity:    params.ReturnConsumedCapacity,
+		Segment:                   s.segment,
+		TotalSegments:             s.totalSegments,
+	}, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	return &dynamodb.QueryOutput{
+		Items:            result.Items,
+		Count:            result.Count,
+		ScannedCount:     result.ScannedCount,
+		LastEvaluatedKey: result.LastEvaluatedKey,
+		ConsumedCapacity: result.ConsumedCapacity,
+	}, nil
+}
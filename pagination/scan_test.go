@@ -0,0 +1,132 @@
+package pagination
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryScanClient scans the items of a memoryClient in order, honoring the contains filter
+type memoryScanClient struct {
+	memory *memoryClient
+	scans  []*dynamodb.ScanInput
+}
+
+func (m *memoryScanClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.scans = append(m.scans, params)
+	result, err := m.memory.Query(ctx, &dynamodb.QueryInput{Limit: params.Limit, ExclusiveStartKey: params.ExclusiveStartKey, Select: params.Select})
+	if err != nil {
+		return nil, err
+	}
+	items, count := result.Items, result.Count
+	if params.FilterExpression != nil {
+		search := params.ExpressionAttributeValues[":search"].(*types.AttributeValueMemberS).Value
+		items = nil
+		for _, item := range result.Items {
+			if strings.Contains(item["sort_key"].(*types.AttributeValueMemberS).Value, search) {
+				items = append(items, item)
+			}
+		}
+		count = int32(len(items))
+	}
+	return &dynamodb.ScanOutput{Items: items, Count: count, LastEvaluatedKey: result.LastEvaluatedKey}, nil
+}
+
+func TestScanPage(t *testing.T) {
+	client := &memoryScanClient{memory: newMemoryClient("a", "b", "c", "d", "e")}
+	paginator := NewScan[Entry](client, "Entries", testKeys)
+
+	res, err := paginator.GetPage(context.Background(), Params{Page: 2, PageSize: 2, OrderBy: "-sort_key"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, sortKeys(res.Data))
+	assert.True(t, res.HasMore)
+
+	require.Len(t, client.scans, 2)
+	input := client.scans[1]
+	assert.Equal(t, "Entries", *input.TableName)
+	assert.Nil(t, input.FilterExpression)
+	assert.Empty(t, input.ExpressionAttributeNames)
+	assert.Equal(t, "b", input.ExclusiveStartKey["sort_key"].(*types.AttributeValueMemberS).Value)
+
+	res, err = paginator.GetPage(context.Background(), Params{Select: "count"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *res.Meta.Count)
+	assert.Equal(t, types.SelectCount, client.scans[2].Select)
+}
+
+func TestScanPageCursor(t *testing.T) {
+	client := &memoryScanClient{memory: newMemoryClient("a", "b", "c")}
+	paginator := NewScan[Entry](client, "Entries", testKeys)
+
+	res, err := paginator.GetPage(context.Background(), Params{PageSize: 2, CursorMode: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, sortKeys(res.Data))
+
+	cursor, err := DecodeCursor(res.NextCursor)
+	require.NoError(t, err)
+	res, err = paginator.GetPage(context.Background(), Params{PageSize: 2, CursorMode: true, Cursor: cursor, Select: "keys_only"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, sortKeys(res.Data))
+	assert.Empty(t, res.NextCursor)
+	assert.Equal(t, "#pk, #sk", *client.scans[1].ProjectionExpression)
+	assert.Equal(t, map[string]string{"#pk": "key_cond", "#sk": "sort_key"}, client.scans[1].ExpressionAttributeNames)
+	assert.Nil(t, client.scans[1].ConsistentRead)
+
+	_, err = paginator.GetPage(context.Background(), Params{PageSize: 2, CursorMode: true, ConsistentRead: true})
+	require.NoError(t, err)
+	assert.True(t, *client.scans[2].ConsistentRead)
+}
+
+func TestScanPageSearch(t *testing.T) {
+	client := &memoryScanClient{memory: newMemoryClient("ab", "b", "c")}
+	paginator := NewScan[Entry](client, "Entries", testKeys)
+
+	res, err := paginator.GetPage(context.Background(), Params{Page: 1, PageSize: 3, Search: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ab", "b"}, sortKeys(res.Data))
+	assert.Equal(t, "contains(#sk, :search)", *client.scans[0].FilterExpression)
+	assert.Equal(t, map[string]string{"#sk": "sort_key"}, client.scans[0].ExpressionAttributeNames)
+
+	_, err = paginator.GetPage(context.Background(), Params{Page: 1, PageSize: 3, Search: "b", SearchMode: "prefix"})
+	require.NoError(t, err)
+	assert.Equal(t, "begins_with(#sk, :search)", *client.scans[1].FilterExpression)
+
+	_, err = paginator.GetPage(context.Background(), Params{Page: 1, PageSize: 3, Search: "b", SearchMode: "client"})
+	require.NoError(t, err)
+	assert.Nil(t, client.scans[2].FilterExpression)
+}
+
+func TestScanPageSortRange(t *testing.T) {
+	client := &memoryScanClient{memory: newMemoryClient("a", "b")}
+	paginator := NewScan[Entry](client, "Entries", testKeys)
+
+	_, err := paginator.GetPage(context.Background(), Params{Page: 1, PageSize: 2, Search: "b", SearchMode: "prefix", SortRange: &SortRange{Op: "between", Value: "a", End: "c"}})
+	require.NoError(t, err)
+
+	// Scans have no key condition, so the range joins the search in the filter
+	input := client.scans[0]
+	assert.Equal(t, "begins_with(#sk, :search) AND #sk BETWEEN :sortValue AND :sortEnd", *input.FilterExpression)
+	assert.Equal(t, map[string]types.AttributeValue{
+		":search":    &types.AttributeValueMemberS{Value: "b"},
+		":sortValue": &types.AttributeValueMemberS{Value: "a"},
+		":sortEnd":   &types.AttributeValueMemberS{Value: "c"},
+	}, input.ExpressionAttributeValues)
+}
+
+func TestSegmentScan(t *testing.T) {
+	client := &memoryScanClient{memory: newMemoryClient("a", "b", "c")}
+	paginator := NewSegmentScan[Entry](client, "Entries", testKeys, 2, 4)
+
+	_, err := paginator.GetPage(context.Background(), Params{Page: 1, PageSize: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, client.scans)
+	for _, input := range client.scans {
+		assert.Equal(t, int32(2), *input.Segment)
+		assert.Equal(t, int32(4), *input.TotalSegments)
+	}
+}
@@ -0,0 +1,74 @@
+package pagination
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// Stats describe how a page was read, returned with Params.Debug to help tune page sizes and filters
+type Stats struct {
+	// RoundTrips counts the DynamoDB queries made, including those counting the items for include_count
+	RoundTrips int64
+	// ItemsScanned counts the items DynamoDB read, including those a filter expression left out
+	ItemsScanned  int64
+	ItemsReturned int64
+	ConsumedRCU   float64
+	ElapsedMs     int64
+}
+
+// statsClient accounts for the queries of one page
+type statsClient struct {
+	DynamoClient
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func (s *statsClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	result, err := s.DynamoClient.Query(ctx, params, optFns...)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.RoundTrips++
+	if err == nil {
+		scanned := int64(result.ScannedCount)
+		if n := int64(len(result.Items)); scanned < n {
+			scanned = n
+		}
+		s.stats.ItemsScanned += scanned
+		s.stats.ConsumedRCU += ConsumedUnits(result.ConsumedCapacity)
+	}
+	return result, err
+}
+
+// debugPage serves a page like GetPage and adds its stats to the page's Meta. The queries report the
+// capacity they consume, in total unless params ask for a breakdown.
+func (p *Paginator[T]) debugPage(ctx context.Context, params Params) (Response[T], error) {
+	start := time.Now()
+	if params.ConsumedCapacity == "" {
+		params.ConsumedCapacity = "total"
+	}
+
+	stats := &statsClient{DynamoClient: p.client}
+	debug := *p
+	debug.client = stats
+	res, err := debug.getPage(ctx, params)
+	if err != nil {
+		return res, err
+	}
+
+	stats.mu.Lock()
+	page := stats.stats
+	stats.mu.Unlock()
+	page.ItemsReturned = int64(len(res.Data))
+	page.ElapsedMs = time.Since(start).Milliseconds()
+
+	if res.Meta == nil {
+		res.Meta = &Meta{}
+	}
+	res.Meta.Stats = &page
+	return res, nil
+}
@@ -0,0 +1,42 @@
+package pagination
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPageDebug(t *testing.T) {
+	client := newMemoryClient("item1", "item2", "item3", "item4", "item5")
+	client.consumed = &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}
+	p := New[Entry](client, "Entries", testKeys)
+
+	// Page 2 takes a query per page, with a third counting the partition
+	res, err := p.GetPage(context.Background(), Params{KeyCondition: "test", Page: 2, PageSize: 2, IncludeCount: true, Debug: true})
+	require.NoError(t, err)
+	require.NotNil(t, res.Meta)
+	require.NotNil(t, res.Meta.Stats)
+	stats := *res.Meta.Stats
+	stats.ElapsedMs = 0
+	assert.Equal(t, Stats{RoundTrips: 3, ItemsScanned: 9, ItemsReturned: 2, ConsumedRCU: 1.5}, stats)
+	assert.Equal(t, 1.5, res.Meta.ConsumedCapacity)
+	for _, query := range client.queries {
+		assert.Equal(t, types.ReturnConsumedCapacityTotal, query.ReturnConsumedCapacity)
+	}
+
+	// A breakdown asked for is kept
+	client.queries = nil
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 10, ConsumedCapacity: "indexes", CursorMode: true, Debug: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), res.Meta.Stats.RoundTrips)
+	assert.Equal(t, int64(5), res.Meta.Stats.ItemsReturned)
+	assert.Equal(t, types.ReturnConsumedCapacityIndexes, client.queries[0].ReturnConsumedCapacity)
+
+	res, err = p.GetPage(context.Background(), Params{KeyCondition: "test", PageSize: 2})
+	require.NoError(t, err)
+	assert.Nil(t, res.Meta)
+}
@@ -0,0 +1,183 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: pagination.proto
+
+// The gRPC API of the service, serving the pages of GET /paginate and the items of GET /export to
+// internal services without HTTP and JSON.
+
+package paginationpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Pagination_Paginate_FullMethodName = "/dynamopagination.v1.Pagination/Paginate"
+	Pagination_Export_FullMethodName   = "/dynamopagination.v1.Pagination/Export"
+)
+
+// PaginationClient is the client API for Pagination service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type PaginationClient interface {
+	// Paginate serves a page of a partition, like GET /paginate
+	Paginate(ctx context.Context, in *PaginateRequest, opts ...grpc.CallOption) (*PaginateResponse, error)
+	// Export streams every item a query selects, like GET /export. The trailer metadata holds
+	// x-stream-status and, for an export that didn't complete, x-export-cursor.
+	Export(ctx context.Context, in *PaginateRequest, opts ...grpc.CallOption) (Pagination_ExportClient, error)
+}
+
+type paginationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewPaginationClient(cc grpc.ClientConnInterface) PaginationClient {
+	return &paginationClient{cc}
+}
+
+func (c *paginationClient) Paginate(ctx context.Context, in *PaginateRequest, opts ...grpc.CallOption) (*PaginateResponse, error) {
+	out := new(PaginateResponse)
+	err := c.cc.Invoke(ctx, Pagination_Paginate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *paginationClient) Export(ctx context.Context, in *PaginateRequest, opts ...grpc.CallOption) (Pagination_ExportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Pagination_ServiceDesc.Streams[0], Pagination_Export_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &paginationExportClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Pagination_ExportClient interface {
+	Recv() (*Item, error)
+	grpc.ClientStream
+}
+
+type paginationExportClient struct {
+	grpc.ClientStream
+}
+
+func (x *paginationExportClient) Recv() (*Item, error) {
+	m := new(Item)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PaginationServer is the server API for Pagination service.
+// All implementations must embed UnimplementedPaginationServer
+// for forward compatibility
+type PaginationServer interface {
+	// Paginate serves a page of a partition, like GET /paginate
+	Paginate(context.Context, *PaginateRequest) (*PaginateResponse, error)
+	// Export streams every item a query selects, like GET /export. The trailer metadata holds
+	// x-stream-status and, for an export that didn't complete, x-export-cursor.
+	Export(*PaginateRequest, Pagination_ExportServer) error
+	mustEmbedUnimplementedPaginationServer()
+}
+
+// UnimplementedPaginationServer must be embedded to have forward compatible implementations.
+type UnimplementedPaginationServer struct {
+}
+
+func (UnimplementedPaginationServer) Paginate(context.Context, *PaginateRequest) (*PaginateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Paginate not implemented")
+}
+func (UnimplementedPaginationServer) Export(*PaginateRequest, Pagination_ExportServer) error {
+	return status.Errorf(codes.Unimplemented, "method Export not implemented")
+}
+func (UnimplementedPaginationServer) mustEmbedUnimplementedPaginationServer() {}
+
+// UnsafePaginationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to PaginationServer will
+// result in compilation errors.
+type UnsafePaginationServer interface {
+	mustEmbedUnimplementedPaginationServer()
+}
+
+func RegisterPaginationServer(s grpc.ServiceRegistrar, srv PaginationServer) {
+	s.RegisterService(&Pagination_ServiceDesc, srv)
+}
+
+func _Pagination_Paginate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PaginateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PaginationServer).Paginate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Pagination_Paginate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PaginationServer).Paginate(ctx, req.(*PaginateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Pagination_Export_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PaginateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(PaginationServer).Export(m, &paginationExportServer{stream})
+}
+
+type Pagination_ExportServer interface {
+	Send(*Item) error
+	grpc.ServerStream
+}
+
+type paginationExportServer struct {
+	grpc.ServerStream
+}
+
+func (x *paginationExportServer) Send(m *Item) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Pagination_ServiceDesc is the grpc.ServiceDesc for Pagination service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Pagination_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dynamopagination.v1.Pagination",
+	HandlerType: (*PaginationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Paginate",
+			Handler:    _Pagination_Paginate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       _Pagination_Export_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pagination.proto",
+}
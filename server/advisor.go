@@ -0,0 +1,218 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// maxAccessPatterns bounds the patterns the advisor keeps, as their attributes come from requests
+	maxAccessPatterns = 1000
+	// maxPatternFields bounds the projected attributes kept per pattern; beyond it, all are projected
+	maxPatternFields       = 20
+	defaultRecommendations = 10
+)
+
+// Kinds of access pattern the table's keys don't serve
+const (
+	// patternOrder is requests ordered by an attribute other than the sort key, which DynamoDB can't
+	// sort by, so clients sort the items themselves
+	patternOrder = "order"
+	// patternScanFilter is scans narrowed to a sort key range or prefix, which read the whole table to
+	// return the items in the range
+	patternScanFilter = "scan_filter"
+)
+
+// AccessPattern is a kind of read the table's keys don't serve, with the traffic it had
+type AccessPattern struct {
+	Kind string
+	// Index is the index read, or empty for the table
+	Index         string `json:",omitempty"`
+	Attribute     string
+	Requests      int64
+	ItemsScanned  int64
+	ItemsReturned int64
+	ConsumedRCU   float64
+	AvgElapsedMs  float64
+	MaxElapsedMs  int64
+
+	totalElapsedMs int64
+	// these are the attributes the requests read: all of them, only their keys, or the fields listed
+	allAttributes bool
+	fields        map[string]bool
+}
+
+// IndexRecommendation is a secondary index that would serve an access pattern with a query
+type IndexRecommendation struct {
+	Pattern AccessPattern
+	// Keys are in the format of INDEXES_FILE. Scan filters leave the partition key to choose.
+	Keys IndexKeys
+	// Projection is "ALL", "KEYS_ONLY" or "INCLUDE" with NonKeyAttributes
+	Projection       string
+	NonKeyAttributes []string `json:",omitempty"`
+	// ExistingIndex is a configured index with these keys, which the requests could select instead
+	ExistingIndex string `json:",omitempty"`
+	Reason        string
+}
+
+// IndexReport lists the index recommendations for the traffic seen since Since
+type IndexReport struct {
+	Since           time.Time
+	Recommendations []IndexRecommendation
+}
+
+type patternKey struct {
+	kind, index, attribute string
+}
+
+// IndexAdvisor collects the reads the table's keys don't serve and recommends secondary indexes that
+// would turn them into queries. It is safe for concurrent use.
+type IndexAdvisor struct {
+	mu       sync.Mutex
+	since    time.Time
+	patterns map[patternKey]*AccessPattern
+}
+
+// NewIndexAdvisor creates an advisor with no traffic recorded
+func NewIndexAdvisor() *IndexAdvisor {
+	return &IndexAdvisor{since: time.Now(), patterns: map[patternKey]*AccessPattern{}}
+}
+
+// record accounts for one request of a pattern. Requests projecting fields add them to the attributes
+// the index should project; others need all attributes, or only the keys with select=keys_only.
+func (a *IndexAdvisor) record(kind string, params Params, attribute string, progress pagination.Progress, returned int) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := patternKey{kind: kind, index: params.IndexName, attribute: attribute}
+	pattern := a.patterns[key]
+	if pattern == nil {
+		if len(a.patterns) >= maxAccessPatterns {
+			return
+		}
+		pattern = &AccessPattern{Kind: kind, Index: params.IndexName, Attribute: attribute, fields: map[string]bool{}}
+		a.patterns[key] = pattern
+	}
+	pattern.Requests++
+	pattern.ItemsScanned += progress.ItemsScanned
+	pattern.ItemsReturned += int64(returned)
+	pattern.ConsumedRCU += progress.ConsumedRCU
+	pattern.totalElapsedMs += progress.ElapsedMs
+	if progress.ElapsedMs > pattern.MaxElapsedMs {
+		pattern.MaxElapsedMs = progress.ElapsedMs
+	}
+
+	switch {
+	case params.Select == "keys_only":
+	case len(params.Fields) == 0:
+		pattern.allAttributes = true
+	default:
+		for _, field := range params.Fields {
+			pattern.fields[field] = true
+		}
+		if len(pattern.fields) > maxPatternFields {
+			pattern.allAttributes = true
+		}
+	}
+}
+
+// Report recommends an index for each of the top patterns, by requests and then by items scanned.
+// indexes are the configured indexes, keys those of the table.
+func (a *IndexAdvisor) Report(top int, keys pagination.KeySchema, indexes map[string]pagination.KeySchema) IndexReport {
+	a.mu.Lock()
+	report := IndexReport{Since: a.since, Recommendations: []IndexRecommendation{}}
+	for _, pattern := range a.patterns {
+		report.Recommendations = append(report.Recommendations, recommend(*pattern, keys, indexes))
+	}
+	a.mu.Unlock()
+
+	sort.Slice(report.Recommendations, func(i, j int) bool {
+		a, b := report.Recommendations[i].Pattern, report.Recommendations[j].Pattern
+		if a.Requests != b.Requests {
+			return a.Requests > b.Requests
+		}
+		if a.ItemsScanned != b.ItemsScanned {
+			return a.ItemsScanned > b.ItemsScanned
+		}
+		return a.Kind+a.Index+a.Attribute < b.Kind+b.Index+b.Attribute
+	})
+	if len(report.Recommendations) > top {
+		report.Recommendations = report.Recommendations[:top]
+	}
+	return report
+}
+
+// recommend derives the index serving a pattern
+func recommend(pattern AccessPattern, keys pagination.KeySchema, indexes map[string]pagination.KeySchema) IndexRecommendation {
+	if pattern.Requests > 0 {
+		pattern.AvgElapsedMs = float64(pattern.totalElapsedMs) / float64(pattern.Requests)
+	}
+	rec := IndexRecommendation{Pattern: pattern}
+
+	switch pattern.Kind {
+	case patternOrder:
+		partitionKey := keys.PartitionKey
+		if pattern.Index != "" {
+			partitionKey = indexes[pattern.Index].PartitionKey
+		}
+		rec.Keys = IndexKeys{PartitionKey: partitionKey, SortKey: pattern.Attribute, Local: partitionKey == keys.PartitionKey}
+		rec.Reason = "Queries can only be ordered by the sort key. An index sorted by " + pattern.Attribute + " serves these pages in order, instead of clients sorting the partition themselves."
+		if rec.Keys.Local {
+			rec.Reason += " A local secondary index can only be added when the table is created; a global one with the same keys can be added at any time."
+		}
+	case patternScanFilter:
+		rec.Keys = IndexKeys{SortKey: pattern.Attribute}
+		rec.Reason = "These scans read the whole table to return a range of sort keys. A global index sorted by " + pattern.Attribute + " and partitioned by an attribute the scanned items share, such as their type, serves the range with a query per partition value."
+	}
+
+	for name, index := range indexes {
+		if rec.Keys.PartitionKey != "" && index.PartitionKey == rec.Keys.PartitionKey && index.SortKey == rec.Keys.SortKey &&
+			(rec.ExistingIndex == "" || name < rec.ExistingIndex) {
+			rec.ExistingIndex = name
+		}
+	}
+
+	switch {
+	case pattern.allAttributes:
+		rec.Projection = "ALL"
+	case len(pattern.fields) == 0:
+		rec.Projection = "KEYS_ONLY"
+	default:
+		rec.Projection = "INCLUDE"
+		// Key attributes are always projected
+		skip := map[string]bool{keys.PartitionKey: true, keys.SortKey: true, rec.Keys.PartitionKey: true, rec.Keys.SortKey: true}
+		for field := range pattern.fields {
+			if !skip[field] {
+				rec.NonKeyAttributes = append(rec.NonKeyAttributes, field)
+			}
+		}
+		sort.Strings(rec.NonKeyAttributes)
+		if len(rec.NonKeyAttributes) == 0 {
+			rec.Projection = "KEYS_ONLY"
+		}
+	}
+	return rec
+}
+
+// handleIndexRecommendations serves the index recommendation report
+func (h *Handler) handleIndexRecommendations(c echo.Context) error {
+	if h.advisor == nil {
+		return respondError(c, http.StatusNotFound, "Index recommendations are disabled")
+	}
+
+	top, ok := boundedParam(c.QueryParam("top"), defaultRecommendations, maxAccessPatterns)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid top parameter")
+	}
+	_, keys := h.schema()
+	return c.JSON(http.StatusOK, h.advisor.Report(int(top), keys, h.indexes))
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexAdvisorReport(t *testing.T) {
+	keys := pagination.KeySchema{PartitionKey: "key_cond", SortKey: "sort_key"}
+	indexes := map[string]pagination.KeySchema{"by_status": {PartitionKey: "status", SortKey: "updated_at"}}
+
+	a := NewIndexAdvisor()
+	a.record(patternOrder, Params{Fields: []string{"price", "key_cond"}}, "price", pagination.Progress{}, 0)
+	a.record(patternOrder, Params{Fields: []string{"name"}}, "price", pagination.Progress{}, 0)
+	a.record(patternOrder, Params{IndexName: "by_status", Select: "keys_only"}, "updated_at", pagination.Progress{}, 0)
+	a.record(patternScanFilter, Params{}, "sort_key", pagination.Progress{ItemsScanned: 100, ConsumedRCU: 2, ElapsedMs: 30}, 4)
+	a.record(patternScanFilter, Params{}, "sort_key", pagination.Progress{ItemsScanned: 50, ConsumedRCU: 1, ElapsedMs: 10}, 1)
+
+	report := a.Report(10, keys, indexes)
+	require.Len(t, report.Recommendations, 3)
+
+	// Ordered by requests, then items scanned
+	scans := report.Recommendations[0]
+	assert.Equal(t, AccessPattern{Kind: patternScanFilter, Attribute: "sort_key", Requests: 2, ItemsScanned: 150, ItemsReturned: 5, ConsumedRCU: 3, AvgElapsedMs: 20, MaxElapsedMs: 30}, exported(scans.Pattern))
+	assert.Equal(t, IndexKeys{SortKey: "sort_key"}, scans.Keys)
+	assert.Equal(t, "ALL", scans.Projection)
+
+	byPrice := report.Recommendations[1]
+	assert.Equal(t, "price", byPrice.Pattern.Attribute)
+	assert.Equal(t, IndexKeys{PartitionKey: "key_cond", SortKey: "price", Local: true}, byPrice.Keys)
+	assert.Equal(t, "INCLUDE", byPrice.Projection)
+	assert.Equal(t, []string{"name"}, byPrice.NonKeyAttributes)
+
+	// The index already exists, and only its keys were read
+	byUpdate := report.Recommendations[2]
+	assert.Equal(t, IndexKeys{PartitionKey: "status", SortKey: "updated_at"}, byUpdate.Keys)
+	assert.Equal(t, "by_status", byUpdate.ExistingIndex)
+	assert.Equal(t, "KEYS_ONLY", byUpdate.Projection)
+
+	assert.Len(t, a.Report(1, keys, indexes).Recommendations, 1)
+}
+
+// exported drops the unexported fields of a pattern, for comparisons
+func exported(p AccessPattern) AccessPattern {
+	p.totalElapsedMs, p.allAttributes, p.fields = 0, false, nil
+	return p
+}
+
+func TestIndexAdvisorBounded(t *testing.T) {
+	a := NewIndexAdvisor()
+	for i := 0; i < maxAccessPatterns+10; i++ {
+		a.record(patternOrder, Params{}, string(rune('a'+i%26))+string(rune(i)), pagination.Progress{}, 0)
+	}
+	assert.Len(t, a.patterns, maxAccessPatterns)
+
+	// A nil advisor records nothing
+	var disabled *IndexAdvisor
+	disabled.record(patternOrder, Params{}, "price", pagination.Progress{}, 0)
+}
+
+func TestHandleIndexRecommendations(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client, advisor: NewIndexAdvisor()}
+
+	get := func(handle echo.HandlerFunc, target string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handle(e.NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusBadRequest, get(handler.handlePagination, "/paginate?key_condition=test&orderby=-price&fields=price,name").Code)
+	assert.Equal(t, http.StatusOK, get(handler.handleScan, "/scan?sort_begins_with=item1").Code)
+	// Unfiltered scans and searches anywhere in the sort key aren't index patterns
+	assert.Equal(t, http.StatusOK, get(handler.handleScan, "/scan?search=item").Code)
+
+	rec := get(handler.handleIndexRecommendations, "/admin/index-recommendations")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report IndexReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Recommendations, 2)
+
+	kinds := map[string]IndexRecommendation{}
+	for _, r := range report.Recommendations {
+		kinds[r.Pattern.Kind] = r
+	}
+	assert.Equal(t, IndexKeys{PartitionKey: "key_cond", SortKey: "price", Local: true}, kinds[patternOrder].Keys)
+	assert.Equal(t, "INCLUDE", kinds[patternOrder].Projection)
+	assert.Equal(t, []string{"name"}, kinds[patternOrder].NonKeyAttributes)
+
+	scan := kinds[patternScanFilter]
+	assert.Equal(t, "sort_key", scan.Pattern.Attribute)
+	// The filter left out the items scanned outside the prefix
+	assert.Equal(t, int64(4), scan.Pattern.ItemsScanned)
+	assert.Equal(t, int64(2), scan.Pattern.ItemsReturned)
+
+	assert.Equal(t, http.StatusBadRequest, get(handler.handleIndexRecommendations, "/admin/index-recommendations?top=none").Code)
+	assert.Equal(t, http.StatusNotFound, get((&Handler{}).handleIndexRecommendations, "/admin/index-recommendations").Code)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errUnauthenticated = errors.New("missing or invalid credentials")
+
+// Principal is who a request authenticated as, and what it may read and do
+type Principal struct {
+	// Name identifies the caller: the name of its API key, or the subject of its token
+	Name string
+	// Tables lists the tables the caller can read; nil allows every table
+	Tables []string
+	// Roles are what the caller may do beyond reading, e.g. "writer"
+	Roles []string
+	// Profile is the response profile the caller is pinned to, empty for the default
+	Profile string
+}
+
+// APIKeyConfig is a named set of API keys sharing what they may read and do
+type APIKeyConfig struct {
+	APIKeys []string `json:"api_keys"`
+	Tables  []string `json:"tables,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	// Profile pins the callers to a response profile: v1, v2, jsonapi or hal
+	Profile string `json:"profile,omitempty"`
+}
+
+// Auth requires every request to authenticate, with one of its API keys or a JWT its validator
+// accepts, and only admits requests for the tables the caller can read
+type Auth struct {
+	keys []authKey
+	jwt  *JWTValidator
+}
+
+type authKey struct {
+	key       []byte
+	principal *Principal
+}
+
+// NewAuth creates an authenticator for the named API keys and, when jwt is set, for tokens it accepts
+func NewAuth(keys map[string]APIKeyConfig, jwt *JWTValidator) (*Auth, error) {
+	a := &Auth{jwt: jwt}
+	seen := map[string]string{}
+	for name, cfg := range keys {
+		if len(cfg.APIKeys) == 0 {
+			return nil, fmt.Errorf("%s: no API keys", name)
+		}
+		if err := checkProfile(cfg.Profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		principal := &Principal{Name: name, Tables: cfg.Tables, Roles: cfg.Roles, Profile: cfg.Profile}
+		for _, key := range cfg.APIKeys {
+			if other, ok := seen[key]; ok {
+				return nil, fmt.Errorf("%s: API key is also assigned to %s", name, other)
+			}
+			seen[key] = name
+			a.keys = append(a.keys, authKey{key: []byte(key), principal: principal})
+		}
+	}
+	if len(a.keys) == 0 && jwt == nil {
+		return nil, errors.New("authentication needs API keys or a JWKS URL")
+	}
+	return a, nil
+}
+
+// loadAuth requires authentication when AUTH_API_KEYS_FILE names a JSON file of API keys, or
+// JWT_JWKS_URL the keys tokens are signed with. JWT_ISSUER and JWT_AUDIENCE, when set, are the issuer
+// and an audience tokens must have, and JWT_ALLOW_ALL_TABLES=true accepts tokens without a tables claim.
+func loadAuth() (*Auth, error) {
+	path, jwksURL := os.Getenv("AUTH_API_KEYS_FILE"), os.Getenv("JWT_JWKS_URL")
+	if path == "" && jwksURL == "" {
+		return nil, nil
+	}
+	var keys map[string]APIKeyConfig
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &keys); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	var jwt *JWTValidator
+	if jwksURL != "" {
+		var err error
+		if jwt, err = NewJWTValidator(jwksURL, os.Getenv("JWT_ISSUER"), os.Getenv("JWT_AUDIENCE")); err != nil {
+			return nil, err
+		}
+		jwt.AllowAllTables = os.Getenv("JWT_ALLOW_ALL_TABLES") == "true"
+	}
+	return NewAuth(keys, jwt)
+}
+
+// authenticate returns the principal of a credential: a JWT when it looks like one and tokens are
+// accepted, or else an API key
+func (a *Auth) authenticate(ctx context.Context, credential string) (*Principal, error) {
+	if credential == "" {
+		return nil, errUnauthenticated
+	}
+	if a.jwt != nil && strings.Count(credential, ".") == 2 {
+		return a.jwt.Validate(ctx, credential)
+	}
+	for _, known := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(credential), known.key) == 1 {
+			return known.principal, nil
+		}
+	}
+	return nil, errUnauthenticated
+}
+
+// Middleware rejects requests that don't authenticate with a 401, and requests for a table the caller
+// can't read with a 403: the table of the route's :table parameter, or else the default one. The
+// caller and its roles are recorded in the request context.
+func (a *Auth) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		principal, err := a.authenticate(c.Request().Context(), requestAPIKey(c.Request()))
+		if err != nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+			return respondError(c, http.StatusUnauthorized, "Authentication required")
+		}
+		table := c.Param("table")
+		if table == "" {
+			table = tableName
+		}
+		if !principal.allowsTable(table) {
+			return respondError(c, http.StatusForbidden, "Table is not available to this caller")
+		}
+		principal.record(ensureRequestContext(c))
+		return next(c)
+	}
+}
+
+// record makes the principal the caller of a request
+func (p *Principal) record(rc *RequestContext) {
+	rc.Caller = p.Name
+	rc.Principal = p
+	rc.Roles = append(rc.Roles, p.Roles...)
+	rc.Profile = p.Profile
+}
+
+// principalFrom returns who the caller of a request authenticated as, or nil when authentication isn't
+// configured
+func principalFrom(ctx context.Context) *Principal {
+	if rc := requestContextFrom(ctx); rc != nil {
+		return rc.Principal
+	}
+	return nil
+}
+
+// allowsTable reports whether the caller can read a table. A nil principal can read every table.
+func (p *Principal) allowsTable(table string) bool {
+	if p == nil || p.Tables == nil {
+		return true
+	}
+	for _, allowed := range p.Tables {
+		if allowed == table {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/elad-da/dynamopagination/paginationpb"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// jwtIssuer signs test tokens and serves the JWKS of its key
+type jwtIssuer struct {
+	key     *rsa.PrivateKey
+	server  *httptest.Server
+	fetches int
+}
+
+func newJWTIssuer(t *testing.T) *jwtIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &jwtIssuer{key: key}
+	issuer.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer.fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *jwtIssuer) sign(t *testing.T, header, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTValidator(t *testing.T) {
+	issuer := newJWTIssuer(t)
+	v, err := NewJWTValidator(issuer.server.URL, "https://issuer", "paginator")
+	require.NoError(t, err)
+	ctx := context.Background()
+	header := map[string]interface{}{"alg": "RS256", "kid": "test"}
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"sub": "svc", "iss": "https://issuer", "aud": []string{"other", "paginator"}, "exp": time.Now().Add(time.Minute).Unix(), "roles": []string{roleWriter}, "tables": []string{"orders"}}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	principal, err := v.Validate(ctx, issuer.sign(t, header, claims(nil)))
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "svc", Tables: []string{"orders"}, Roles: []string{roleWriter}}, principal)
+	principal, err = v.Validate(ctx, issuer.sign(t, header, claims(map[string]interface{}{"aud": "paginator", "tables": []string{}})))
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, principal.Tables)
+	assert.Equal(t, 1, issuer.fetches, "the JWKS is cached")
+
+	// Tokens that don't list their tables can only read every table when that is allowed
+	_, err = v.Validate(ctx, issuer.sign(t, header, claims(map[string]interface{}{"tables": nil})))
+	assert.ErrorIs(t, err, errUnauthenticated)
+	v.AllowAllTables = true
+	principal, err = v.Validate(ctx, issuer.sign(t, header, claims(map[string]interface{}{"tables": nil})))
+	require.NoError(t, err)
+	assert.Nil(t, principal.Tables)
+	v.AllowAllTables = false
+
+	token := issuer.sign(t, header, claims(nil))
+	for name, bad := range map[string]string{
+		"issuer":    issuer.sign(t, header, claims(map[string]interface{}{"iss": "https://other"})),
+		"audience":  issuer.sign(t, header, claims(map[string]interface{}{"aud": "other"})),
+		"expired":   issuer.sign(t, header, claims(map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})),
+		"no expiry": issuer.sign(t, header, claims(map[string]interface{}{"exp": nil})),
+		"not yet":   issuer.sign(t, header, claims(map[string]interface{}{"nbf": time.Now().Add(time.Minute).Unix()})),
+		"none":      issuer.sign(t, map[string]interface{}{"alg": "none", "kid": "test"}, claims(nil)),
+		"ecdsa":     issuer.sign(t, map[string]interface{}{"alg": "ES256", "kid": "test"}, claims(nil)),
+		"signature": token[:len(token)-4] + "AAAA",
+		"malformed": "a.b.c",
+	} {
+		_, err := v.Validate(ctx, bad)
+		assert.ErrorIs(t, err, errUnauthenticated, name)
+	}
+
+	// Unknown keys fetch the JWKS again, at most every jwksMinRefresh
+	_, err = v.Validate(ctx, issuer.sign(t, map[string]interface{}{"alg": "RS256", "kid": "rotated"}, claims(nil)))
+	assert.ErrorIs(t, err, errUnauthenticated)
+	assert.Equal(t, 1, issuer.fetches)
+	v.now = func() time.Time { return time.Now().Add(2 * jwksMinRefresh) }
+	_, err = v.Validate(ctx, issuer.sign(t, map[string]interface{}{"alg": "RS256", "kid": "rotated"}, claims(nil)))
+	assert.ErrorIs(t, err, errUnauthenticated)
+	assert.Equal(t, 2, issuer.fetches)
+
+	_, err = NewJWTValidator("jwks.json", "", "")
+	assert.Error(t, err)
+}
+
+func TestNewAuthInvalid(t *testing.T) {
+	_, err := NewAuth(nil, nil)
+	assert.Error(t, err)
+	_, err = NewAuth(map[string]APIKeyConfig{"acme": {}}, nil)
+	assert.Error(t, err)
+	_, err = NewAuth(map[string]APIKeyConfig{"acme": {APIKeys: []string{"key"}}, "globex": {APIKeys: []string{"key"}}}, nil)
+	assert.Error(t, err)
+}
+
+func TestLoadAuth(t *testing.T) {
+	auth, err := loadAuth()
+	require.NoError(t, err)
+	assert.Nil(t, auth)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"acme": {"api_keys": ["acme-key"], "tables": ["orders"]}}`), 0o600))
+	t.Setenv("AUTH_API_KEYS_FILE", path)
+	auth, err = loadAuth()
+	require.NoError(t, err)
+	principal, err := auth.authenticate(context.Background(), "acme-key")
+	require.NoError(t, err)
+	assert.Equal(t, &Principal{Name: "acme", Tables: []string{"orders"}}, principal)
+
+	t.Setenv("JWT_JWKS_URL", "not a url")
+	_, err = loadAuth()
+	assert.Error(t, err)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	issuer := newJWTIssuer(t)
+	jwt, err := NewJWTValidator(issuer.server.URL, "", "")
+	require.NoError(t, err)
+	auth, err := NewAuth(map[string]APIKeyConfig{
+		"acme":   {APIKeys: []string{"acme-key"}, Roles: []string{roleWriter}},
+		"globex": {APIKeys: []string{"globex-key"}, Tables: []string{"orders"}},
+	}, jwt)
+	require.NoError(t, err)
+
+	e := echo.New()
+	var rc *RequestContext
+	handler := auth.Middleware(func(c echo.Context) error {
+		rc = requestContextFrom(c.Request().Context())
+		return c.String(http.StatusOK, "ok")
+	})
+	serve := func(authorization, table string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		if authorization != "" {
+			req.Header.Set(echo.HeaderAuthorization, authorization)
+		}
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if table != "" {
+			c.SetParamNames("table")
+			c.SetParamValues(table)
+		}
+		require.NoError(t, handler(c))
+		return rec
+	}
+
+	rec := serve("", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get(echo.HeaderWWWAuthenticate))
+	assert.Equal(t, http.StatusUnauthorized, serve("Bearer other-key", "").Code)
+
+	require.Equal(t, http.StatusOK, serve("Bearer acme-key", "").Code)
+	assert.Equal(t, "acme", rc.Caller)
+	assert.True(t, rc.HasRole(roleWriter))
+
+	assert.Equal(t, http.StatusForbidden, serve("Bearer globex-key", "").Code)
+	assert.Equal(t, http.StatusOK, serve("Bearer globex-key", "orders").Code)
+
+	token := issuer.sign(t, map[string]interface{}{"alg": "RS256", "kid": "test"}, map[string]interface{}{"sub": "svc", "exp": time.Now().Add(time.Minute).Unix(), "tables": []string{tableName}})
+	require.Equal(t, http.StatusOK, serve("Bearer "+token, "").Code)
+	assert.Equal(t, "svc", rc.Caller)
+	assert.False(t, rc.HasRole(roleWriter))
+}
+
+func TestGRPCAuth(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 3))
+	require.NoError(t, err)
+	auth, err := NewAuth(map[string]APIKeyConfig{
+		"acme":   {APIKeys: []string{"acme-key"}},
+		"globex": {APIKeys: []string{"globex-key"}, Tables: []string{"orders"}},
+	}, nil)
+	require.NoError(t, err)
+	tenants, err := ParseTenants([]byte(`{"tenants": {"acme": {"api_keys": ["acme-key"], "max_page_size": 2, "rate": 0.001, "burst": 2}}}`))
+	require.NoError(t, err)
+	client := dialGRPC(t, &Handler{client: fixture, callers: []echo.MiddlewareFunc{auth.Middleware, tenants.Middleware}})
+	req := &paginationpb.PaginateRequest{KeyCondition: "test", PageSize: 10}
+
+	_, err = client.Paginate(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = client.Paginate(metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "globex-key"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// Calls get the budget of their tenant
+	acme := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer acme-key")
+	res, err := client.Paginate(acme, req)
+	require.NoError(t, err)
+	assert.Len(t, res.Items, 2)
+
+	stream, err := client.Export(context.Background(), req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	stream, err = client.Export(acme, req)
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.NoError(t, err)
+	for err == nil {
+		_, err = stream.Recv()
+	}
+	assert.Equal(t, io.EOF, err)
+
+	// and are rate limited with it
+	_, err = client.Paginate(acme, req)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestAuthCollections(t *testing.T) {
+	auth, err := NewAuth(map[string]APIKeyConfig{
+		"acme":   {APIKeys: []string{"acme-key"}, Tables: []string{tableName, "current"}},
+		"globex": {APIKeys: []string{"globex-key"}, Tables: []string{tableName, "current", "legacy"}},
+	}, nil)
+	require.NoError(t, err)
+	collections := map[string]*Collection{
+		"all": {Name: "all", SortAttribute: "sort_key", Sources: []CollectionSource{
+			{Table: "current", KeyCondition: "test"},
+			{Table: "legacy", KeyCondition: "test"},
+		}},
+	}
+	handler := &Handler{client: newUnionClient(t), collections: collections}
+
+	e := echo.New()
+	e.Use(auth.Middleware)
+	e.GET("/collections/:name", handler.handleCollection)
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/collections/all", nil)
+		req.Header.Set(headerAPIKey, key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Every source of a collection must be available to the caller
+	assert.Equal(t, http.StatusForbidden, serve("acme-key").Code)
+	assert.Equal(t, http.StatusOK, serve("globex-key").Code)
+}
@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// batchGetSize is the maximum number of keys BatchGetItem accepts
+	batchGetSize = 100
+	// batchGetMaxKeys bounds the keys of one request
+	batchGetMaxKeys = 1000
+	// batchGetMaxAttempts bounds how often unprocessed keys of a batch are retried
+	batchGetMaxAttempts = 5
+)
+
+var (
+	// batchGetRetryDelay is the initial backoff before retrying unprocessed keys, doubled on every attempt
+	batchGetRetryDelay = 50 * time.Millisecond
+	// batchGetMaxBodyBytes limits the size of a batch get request body
+	batchGetMaxBodyBytes int64 = 1 << 20
+)
+
+// BatchGetBody is the body of POST /items:batchGet
+type BatchGetBody struct {
+	Keys []ItemKey `json:"keys"`
+	// Fields, like the fields parameter of /items/:pk/:sk, reads only these attributes and the keys
+	Fields     []string `json:"fields,omitempty"`
+	Consistent bool     `json:"consistent,omitempty"`
+}
+
+// ItemKey is the primary key of an item, as served in the items of a page
+type ItemKey struct {
+	KeyCond string `json:"key_cond"`
+	SortKey string `json:"sort_key"`
+}
+
+// BatchGetResponse holds the items found for a batch of keys, in the order of the keys
+type BatchGetResponse struct {
+	Data []Entry
+	// Missing are the keys with no item, or whose item isn't served
+	Missing []ItemKey `json:",omitempty"`
+	// Unprocessed are the keys DynamoDB still hadn't read after the retries
+	Unprocessed []ItemKey `json:",omitempty"`
+	Meta        *Meta     `json:",omitempty"`
+}
+
+// handleBatchGet serves the items of a list of primary keys, read with BatchGetItem, so clients that
+// listed keys can fetch the full items in a single request
+func (h *Handler) handleBatchGet(c echo.Context) error {
+	client, ok := h.clientFor(c)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid region parameter")
+	}
+
+	var body BatchGetBody
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Response(), c.Request().Body, batchGetMaxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return respondError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+	}
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+	if len(body.Keys) == 0 || len(body.Keys) > batchGetMaxKeys {
+		return respondError(c, http.StatusBadRequest, "Invalid keys: send from 1 to 1000 keys")
+	}
+
+	// BatchGetItem rejects batches naming a key twice
+	var keys []ItemKey
+	seen := make(map[ItemKey]bool, len(body.Keys))
+	for _, key := range body.Keys {
+		if key.KeyCond == "" || (key.SortKey == "") != (tableKeys.SortKey == "") {
+			if tableKeys.SortKey == "" {
+				return respondError(c, http.StatusBadRequest, "Invalid keys: every key needs a key_cond, and no sort_key as the table has no sort key")
+			}
+			return respondError(c, http.StatusBadRequest, "Invalid keys: every key needs a key_cond and a sort_key")
+		}
+		var err error
+		if key.KeyCond, err = h.resolveKey(PartitionKeyPosition, key.KeyCond); err == nil {
+			key.SortKey, err = h.resolveKey(SortKeyPosition, key.SortKey)
+		}
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, "Invalid keys: a key isn't the ID of an item")
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	read := fullItem
+	request := types.KeysAndAttributes{ConsistentRead: aws.Bool(body.Consistent)}
+	if len(body.Fields) > 0 {
+		read = projectedItem
+		// The keys tell which key each item belongs to
+		fields := []string{tableKeys.PartitionKey}
+		if tableKeys.SortKey != "" {
+			fields = append(fields, tableKeys.SortKey)
+		}
+		for _, field := range body.Fields {
+			if field != tableKeys.PartitionKey && field != tableKeys.SortKey {
+				fields = append(fields, field)
+			}
+		}
+		b := newExpressionBuilder()
+		request.ProjectionExpression = aws.String(b.projection(fields))
+		request.ExpressionAttributeNames = b.attributeNames()
+	}
+
+	var res BatchGetResponse
+	items := make(map[ItemKey]map[string]types.AttributeValue, len(keys))
+	for start := 0; start < len(keys); start += batchGetSize {
+		end := start + batchGetSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		unprocessed, err := getBatch(c.Request().Context(), client, keys[start:end], request, items)
+		if err != nil {
+			reqErr := dynamoError("Error in DynamoDB query", err)
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+		res.Unprocessed = append(res.Unprocessed, unprocessed...)
+	}
+
+	unprocessed := make(map[ItemKey]bool, len(res.Unprocessed))
+	for _, key := range res.Unprocessed {
+		unprocessed[key] = true
+	}
+	var warnings []Warning
+	tenant := tenantFrom(c.Request().Context())
+	for _, key := range keys {
+		item, ok := items[key]
+		if !ok {
+			if !unprocessed[key] {
+				res.Missing = append(res.Missing, key)
+			}
+			continue
+		}
+		entry, itemWarnings, keep, reqErr := h.decodePageItem(item, read)
+		if reqErr != nil {
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+		warnings = append(warnings, itemWarnings...)
+		if !keep {
+			res.Missing = append(res.Missing, key)
+			continue
+		}
+		res.Data = append(res.Data, tenant.redactEntry(entry))
+	}
+	if res.Data == nil {
+		res.Data = []Entry{}
+	}
+	for _, keys := range [][]ItemKey{res.Missing, res.Unprocessed} {
+		for i := range keys {
+			keys[i] = ItemKey{KeyCond: h.exposeKey(PartitionKeyPosition, keys[i].KeyCond), SortKey: h.exposeKey(SortKeyPosition, keys[i].SortKey)}
+		}
+	}
+	if len(warnings) > 0 {
+		res.Meta = &Meta{Warnings: warnings}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// getBatch reads up to batchGetSize keys into items, retrying unprocessed keys with exponential
+// backoff, and returns the keys still unprocessed after the last attempt
+func getBatch(ctx context.Context, client DynamoClient, keys []ItemKey, request types.KeysAndAttributes, items map[ItemKey]map[string]types.AttributeValue) ([]ItemKey, error) {
+	for _, key := range keys {
+		request.Keys = append(request.Keys, primaryKey(key.KeyCond, key.SortKey))
+	}
+
+	delay := batchGetRetryDelay
+	for attempt := 1; ; attempt++ {
+		out, err := client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{tableName: request},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range out.Responses[tableName] {
+			items[ItemKey{KeyCond: attributeString(item[tableKeys.PartitionKey]), SortKey: attributeString(item[tableKeys.SortKey])}] = item
+		}
+
+		pending, ok := out.UnprocessedKeys[tableName]
+		if !ok || len(pending.Keys) == 0 {
+			return nil, nil
+		}
+		if attempt == batchGetMaxAttempts {
+			var unprocessed []ItemKey
+			for _, key := range pending.Keys {
+				unprocessed = append(unprocessed, ItemKey{KeyCond: attributeString(key[tableKeys.PartitionKey]), SortKey: attributeString(key[tableKeys.SortKey])})
+			}
+			return unprocessed, nil
+		}
+		request = pending
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		delay *= 2
+	}
+}
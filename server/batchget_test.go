@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func serveBatchGet(t *testing.T, handler *Handler, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	e.POST("/items\\:batchGet", handler.handleBatchGet)
+	req := httptest.NewRequest(http.MethodPost, "/items:batchGet", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestHandleBatchGet(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	rec := serveBatchGet(t, handler, `{"keys": [
+		{"key_cond": "other", "sort_key": "item1"},
+		{"key_cond": "test", "sort_key": "item3"},
+		{"key_cond": "test", "sort_key": "missing"},
+		{"key_cond": "other", "sort_key": "item1"}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var res BatchGetResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []Entry{{KeyCond: "other", SortKey: "item1"}, {KeyCond: "test", SortKey: "item3"}}, res.Data)
+	assert.Equal(t, []ItemKey{{KeyCond: "test", SortKey: "missing"}}, res.Missing)
+	assert.Empty(t, res.Unprocessed)
+
+	for _, body := range []string{`{"keys": []}`, `{"keys": [{"key_cond": "test"}]}`, `{"ids": []}`, `not json`} {
+		rec := serveBatchGet(t, handler, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+
+	var keys []string
+	for i := 0; i <= batchGetMaxKeys; i++ {
+		keys = append(keys, fmt.Sprintf(`{"key_cond": "test", "sort_key": "item%d"}`, i))
+	}
+	rec = serveBatchGet(t, handler, `{"keys": [`+strings.Join(keys, ",")+`]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleBatchGetUnprocessedKeys(t *testing.T) {
+	batchGetRetryDelay = 0
+	defer func() { batchGetRetryDelay = 50 * time.Millisecond }()
+
+	key := func(sk string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "test"},
+			"sort_key": &types.AttributeValueMemberS{Value: sk},
+		}
+	}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+		request := input.RequestItems[tableName]
+		return len(request.Keys) == 2 && *request.ProjectionExpression == "#n0, #n1, #n2"
+	})).Return(&dynamodb.BatchGetItemOutput{
+		Responses:       map[string][]map[string]types.AttributeValue{tableName: {key("item1")}},
+		UnprocessedKeys: map[string]types.KeysAndAttributes{tableName: {Keys: []map[string]types.AttributeValue{key("item2")}}},
+	}, nil).Once()
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchGetItemInput) bool {
+		return len(input.RequestItems[tableName].Keys) == 1
+	})).Return(&dynamodb.BatchGetItemOutput{
+		Responses: map[string][]map[string]types.AttributeValue{tableName: {key("item2")}},
+	}, nil).Once()
+
+	rec := serveBatchGet(t, &Handler{client: mockDynamoDB}, `{"keys": [
+		{"key_cond": "test", "sort_key": "item2"},
+		{"key_cond": "test", "sort_key": "item1"}
+	], "fields": ["label", "sort_key"]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	mockDynamoDB.AssertExpectations(t)
+
+	var res BatchGetResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []Entry{{KeyCond: "test", SortKey: "item2"}, {KeyCond: "test", SortKey: "item1"}}, res.Data)
+
+	// Keys DynamoDB keeps leaving unprocessed are reported
+	mockDynamoDB = new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{
+		UnprocessedKeys: map[string]types.KeysAndAttributes{tableName: {Keys: []map[string]types.AttributeValue{key("item1")}}},
+	}, nil)
+	rec = serveBatchGet(t, &Handler{client: mockDynamoDB}, `{"keys": [{"key_cond": "test", "sort_key": "item1"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Empty(t, res.Data)
+	assert.Empty(t, res.Missing)
+	assert.Equal(t, []ItemKey{{KeyCond: "test", SortKey: "item1"}}, res.Unprocessed)
+	mockDynamoDB.AssertNumberOfCalls(t, "BatchGetItem", batchGetMaxAttempts)
+}
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultBatchMaxQueries    = 10
+	defaultBatchMaxRoundTrips = 100
+	// batchConcurrency bounds the queries of a batch served at the same time
+	batchConcurrency = 4
+)
+
+var errBatchBudget = errors.New("the batch used up its round trip budget")
+
+// BatchLimits bounds the work of a POST /batch/paginate
+type BatchLimits struct {
+	// MaxQueries is the maximum number of queries of a batch, defaultBatchMaxQueries when zero
+	MaxQueries int
+	// MaxRoundTrips is the number of DynamoDB reads the queries of a batch share; zero disables it
+	MaxRoundTrips int64
+}
+
+// loadBatchLimits reads BATCH_MAX_QUERIES and BATCH_MAX_ROUND_TRIPS
+func loadBatchLimits() (BatchLimits, error) {
+	limits := BatchLimits{MaxQueries: defaultBatchMaxQueries, MaxRoundTrips: defaultBatchMaxRoundTrips}
+	if v := os.Getenv("BATCH_MAX_QUERIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return limits, fmt.Errorf("invalid BATCH_MAX_QUERIES %q", v)
+		}
+		limits.MaxQueries = n
+	}
+	if v := os.Getenv("BATCH_MAX_ROUND_TRIPS"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 {
+			return limits, fmt.Errorf("invalid BATCH_MAX_ROUND_TRIPS %q", v)
+		}
+		limits.MaxRoundTrips = n
+	}
+	return limits, nil
+}
+
+func (l BatchLimits) maxQueries() int {
+	if l.MaxQueries <= 0 {
+		return defaultBatchMaxQueries
+	}
+	return l.MaxQueries
+}
+
+// BatchPaginationBody is the body of POST /batch/paginate: queries taking the body of POST /paginate
+type BatchPaginationBody struct {
+	Queries []PaginationBody `json:"queries"`
+}
+
+// BatchPaginationResponse holds the responses of the queries of a batch, in the order of the queries
+type BatchPaginationResponse struct {
+	Results []BatchResult
+}
+
+// BatchResult is the response of one query of a batch: the status and JSON body POST /paginate
+// would have answered it with
+type BatchResult struct {
+	Status int
+	Body   json.RawMessage
+}
+
+// handleBatchPagination serves POST /batch/paginate. The queries are served concurrently like
+// POST /paginate serves them, and share the request's deadline and a budget of DynamoDB round trips;
+// queries left without budget fail with a 422 while the others are answered.
+func (h *Handler) handleBatchPagination(c echo.Context) error {
+	var body BatchPaginationBody
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Response(), c.Request().Body, paginationBodyMaxBytes))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return respondError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+	}
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+	if len(body.Queries) == 0 || len(body.Queries) > h.batch.maxQueries() {
+		return respondError(c, http.StatusBadRequest, fmt.Sprintf("Invalid queries: send from 1 to %d queries", h.batch.maxQueries()))
+	}
+
+	ctx := c.Request().Context()
+	if h.batch.MaxRoundTrips > 0 {
+		ctx = withBatchBudget(ctx, &batchBudget{remaining: h.batch.MaxRoundTrips})
+	}
+	res := BatchPaginationResponse{Results: make([]BatchResult, len(body.Queries))}
+	slots := make(chan struct{}, batchConcurrency)
+	var wg sync.WaitGroup
+	for i, query := range body.Queries {
+		wg.Add(1)
+		go func(i int, query PaginationBody) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			res.Results[i] = h.serveBatchQuery(c, ctx, query)
+		}(i, query)
+	}
+	wg.Wait()
+	return c.JSON(http.StatusOK, res)
+}
+
+// serveBatchQuery serves one query of a batch as a GET /paginate of its own, with the headers of the
+// batch except those choosing another representation than JSON
+func (h *Handler) serveBatchQuery(c echo.Context, ctx context.Context, query PaginationBody) BatchResult {
+	if query.Format != "" && query.Format != "json" {
+		return batchError(c, http.StatusBadRequest, "Invalid format parameter: batches are served as JSON")
+	}
+	conditions, reqErr := query.conditions()
+	if reqErr != nil {
+		return batchError(c, reqErr.status, reqErr.message)
+	}
+	values := url.Values{}
+	if reqErr := query.setQuery(values); reqErr != nil {
+		return batchError(c, reqErr.status, reqErr.message)
+	}
+	if len(conditions) > 0 {
+		canonical, _ := json.Marshal(query.Filters)
+		ctx = context.WithValue(ctx, filterKey{}, requestFilter{conditions: conditions, canonical: string(canonical)})
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "/paginate?"+values.Encode(), nil)
+	if err != nil {
+		return batchError(c, http.StatusBadRequest, "Invalid queries")
+	}
+	req.Header = c.Request().Header.Clone()
+	for _, name := range []string{echo.HeaderAccept, echo.HeaderContentType, echo.HeaderContentLength, "Range"} {
+		req.Header.Del(name)
+	}
+	rec := &queryRecorder{header: http.Header{}, status: http.StatusOK}
+	rec.header.Set(echo.HeaderXRequestID, c.Response().Header().Get(echo.HeaderXRequestID))
+	sub := c.Echo().NewContext(req, rec)
+	if err := h.handlePagination(sub); err != nil {
+		sub.Error(err)
+	}
+	return BatchResult{Status: rec.status, Body: rec.body.Bytes()}
+}
+
+// batchError is the result of a query of a batch that isn't served
+func batchError(c echo.Context, status int, message string) BatchResult {
+	body, _ := json.Marshal(ErrorResponse{Error: errorDetail(c, status, "", message)})
+	return BatchResult{Status: status, Body: body}
+}
+
+// queryRecorder keeps the response to a query of a batch
+type queryRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *queryRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *queryRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *queryRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+// batchBudget is the number of DynamoDB reads the queries of a batch may still make
+type batchBudget struct {
+	remaining int64
+}
+
+type batchBudgetKey struct{}
+
+func withBatchBudget(ctx context.Context, budget *batchBudget) context.Context {
+	return context.WithValue(ctx, batchBudgetKey{}, budget)
+}
+
+// batchLimited wraps the client of a query of a batch so its reads draw on the batch's budget, when
+// there is one
+func batchLimited(ctx context.Context, client DynamoClient) DynamoClient {
+	budget, ok := ctx.Value(batchBudgetKey{}).(*batchBudget)
+	if !ok {
+		return client
+	}
+	return &batchClient{DynamoClient: client, budget: budget}
+}
+
+// batchClient fails the reads of a query once its batch used up its budget
+type batchClient struct {
+	DynamoClient
+	budget *batchBudget
+}
+
+// spend takes a round trip from the budget
+func (b *batchBudget) spend() error {
+	if atomic.AddInt64(&b.remaining, -1) < 0 {
+		return errBatchBudget
+	}
+	return nil
+}
+
+func (c *batchClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if err := c.budget.spend(); err != nil {
+		return nil, err
+	}
+	return c.DynamoClient.Query(ctx, params, optFns...)
+}
+
+func (c *batchClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if err := c.budget.spend(); err != nil {
+		return nil, err
+	}
+	return c.DynamoClient.Scan(ctx, params, optFns...)
+}
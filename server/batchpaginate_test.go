@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serveBatchPagination(t *testing.T, handler *Handler, body string) (*httptest.ResponseRecorder, BatchPaginationResponse) {
+	t.Helper()
+	e := echo.New()
+	e.POST("/batch/paginate", handler.handleBatchPagination)
+	req := httptest.NewRequest(http.MethodPost, "/batch/paginate", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var res BatchPaginationResponse
+	if rec.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	}
+	return rec, res
+}
+
+func TestHandleBatchPagination(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	rec, res := serveBatchPagination(t, handler, `{"queries": [
+		{"key_condition": "test", "pagesize": 2},
+		{"key_condition": "other"},
+		{"pagesize": 2},
+		{"key_condition": "test", "format": "csv"},
+		{"key_condition": "test", "filters": [{"attribute": "status", "op": "like"}]}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, res.Results, 5)
+
+	var page Response
+	require.Equal(t, http.StatusOK, res.Results[0].Status)
+	require.NoError(t, json.Unmarshal(res.Results[0].Body, &page))
+	assert.Equal(t, []Entry{{KeyCond: "test", SortKey: "item1"}, {KeyCond: "test", SortKey: "item2"}}, page.Data)
+
+	require.Equal(t, http.StatusOK, res.Results[1].Status, string(res.Results[1].Body))
+	require.NoError(t, json.Unmarshal(res.Results[1].Body, &page))
+	assert.Equal(t, []Entry{{KeyCond: "other", SortKey: "item1"}}, page.Data)
+
+	var failed ErrorResponse
+	assert.Equal(t, http.StatusBadRequest, res.Results[2].Status)
+	require.NoError(t, json.Unmarshal(res.Results[2].Body, &failed))
+	assert.Equal(t, "Invalid key_condition parameter", failed.Error.Message)
+	assert.Equal(t, http.StatusBadRequest, res.Results[3].Status)
+	assert.Equal(t, http.StatusBadRequest, res.Results[4].Status)
+
+	for _, body := range []string{`{"queries": []}`, `{"queries": [{}, {}, {}, {}, {}, {}, {}, {}, {}, {}, {}]}`, `{"query": {}}`, `not json`} {
+		rec, _ := serveBatchPagination(t, handler, body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+	}
+}
+
+func TestBatchPaginationBudget(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test", "other"}, 10))
+	require.NoError(t, err)
+	handler := &Handler{client: fixture, batch: BatchLimits{MaxRoundTrips: 3}}
+
+	// The three round trips to the third page use up the budget of the batch
+	_, res := serveBatchPagination(t, handler, `{"queries": [
+		{"key_condition": "test", "pagesize": 2, "page": 3}
+	]}`)
+	require.Len(t, res.Results, 1)
+	assert.Equal(t, http.StatusOK, res.Results[0].Status, string(res.Results[0].Body))
+
+	_, res = serveBatchPagination(t, handler, `{"queries": [
+		{"key_condition": "test", "pagesize": 2, "page": 3},
+		{"key_condition": "other", "pagesize": 2, "page": 3}
+	]}`)
+	require.Len(t, res.Results, 2)
+	statuses := []int{res.Results[0].Status, res.Results[1].Status}
+	assert.Contains(t, statuses, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"container/list"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerBodyCache tells whether a page was served from the body cache
+	headerBodyCache = "X-Body-Cache"
+	// defaultBodyCacheBytes bounds the bodies kept when PAGE_BODY_CACHE_BYTES isn't set
+	defaultBodyCacheBytes = 64 << 20
+)
+
+// BodyCache keeps the bytes of the pages served, compressed as sent, keyed by the fingerprint of the
+// request and the content encoding. Repeated requests are answered from it without reading DynamoDB,
+// serializing or compressing. Writes made through the service drop the pages of the partition written;
+// other writes show once the pages expire. It is safe for concurrent use.
+type BodyCache struct {
+	ttl      time.Duration
+	maxBytes int
+
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	// entries and partitions index the elements of order by key and by table and partition
+	entries    map[string]*list.Element
+	partitions map[string]map[string]bool
+	now        func() time.Time
+}
+
+// cachedBody is a page as it was sent
+type cachedBody struct {
+	key       string
+	partition string
+	header    http.Header
+	body      []byte
+	stored    time.Time
+}
+
+// NewBodyCache creates a cache keeping pages for ttl, up to maxBytes of bodies
+func NewBodyCache(ttl time.Duration, maxBytes int) *BodyCache {
+	return &BodyCache{ttl: ttl, maxBytes: maxBytes, order: list.New(), entries: map[string]*list.Element{}, partitions: map[string]map[string]bool{}, now: time.Now}
+}
+
+// loadBodyCache enables the body cache for PAGE_BODY_CACHE_TTL, a Go duration, keeping up to
+// PAGE_BODY_CACHE_BYTES of bodies
+func loadBodyCache() (*BodyCache, error) {
+	v := os.Getenv("PAGE_BODY_CACHE_TTL")
+	if v == "" {
+		return nil, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid PAGE_BODY_CACHE_TTL %q", v)
+	}
+	maxBytes := defaultBodyCacheBytes
+	if v := os.Getenv("PAGE_BODY_CACHE_BYTES"); v != "" {
+		if maxBytes, err = strconv.Atoi(v); err != nil || maxBytes <= 0 {
+			return nil, fmt.Errorf("invalid PAGE_BODY_CACHE_BYTES %q", v)
+		}
+	}
+	return NewBodyCache(ttl, maxBytes), nil
+}
+
+func (b *BodyCache) get(key string) (*cachedBody, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	element, ok := b.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cachedBody)
+	if b.now().Sub(entry.stored) >= b.ttl {
+		b.remove(element)
+		return nil, false
+	}
+	b.order.MoveToFront(element)
+	return entry, true
+}
+
+// put stores a page, evicting the least recently served ones to stay within the size limit
+func (b *BodyCache) put(entry *cachedBody) {
+	if len(entry.body) > b.maxBytes {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if element, ok := b.entries[entry.key]; ok {
+		b.remove(element)
+	}
+	for b.size+len(entry.body) > b.maxBytes {
+		b.remove(b.order.Back())
+	}
+	b.entries[entry.key] = b.order.PushFront(entry)
+	b.size += len(entry.body)
+	if b.partitions[entry.partition] == nil {
+		b.partitions[entry.partition] = map[string]bool{}
+	}
+	b.partitions[entry.partition][entry.key] = true
+}
+
+func (b *BodyCache) remove(element *list.Element) {
+	entry := b.order.Remove(element).(*cachedBody)
+	delete(b.entries, entry.key)
+	b.size -= len(entry.body)
+	delete(b.partitions[entry.partition], entry.key)
+	if len(b.partitions[entry.partition]) == 0 {
+		delete(b.partitions, entry.partition)
+	}
+}
+
+// invalidate drops the pages a write to a partition of table can change: those of the partition, and
+// the scans and index queries of the table, whose partitions aren't known. An empty partition drops
+// every page of the table.
+func (b *BodyCache) invalidate(table, partition string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for tag, keys := range b.partitions {
+		tagTable, tagPartition, _ := strings.Cut(tag, "/")
+		if tagTable != table || (partition != "" && tagPartition != "" && tagPartition != partition) {
+			continue
+		}
+		for key := range keys {
+			b.remove(b.entries[key])
+		}
+	}
+}
+
+// cacheBodies serves pages from the body cache, and stores those it misses. Debug pages, long polls,
+// event streams, strongly consistent and offloaded reads are always served fresh, and so are pages
+// older than the Cache-Control request directives accept.
+func (h *Handler) cacheBodies(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.bodies == nil {
+			return next(c)
+		}
+		c.Response().Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+		if c.QueryParam("debug") == "true" || c.QueryParam("wait") != "" || c.QueryParam("consistent") == "true" || wantsEventStream(c) || c.Request().Header.Get(headerRange) != "" {
+			return next(c)
+		}
+
+		encoding := ""
+		if acceptsGzip(c.Request().Header.Get(echo.HeaderAcceptEncoding)) {
+			encoding = "gzip"
+		}
+		key := requestFingerprint(c) + "\n" + encoding
+		if entry, ok := h.bodies.get(key); ok && requestCacheDirectives(c).accepts(h.bodies.now().Sub(entry.stored)) {
+			return entry.replay(c, h.bodies.now())
+		}
+
+		header := c.Response().Header()
+		before := header.Clone()
+		recorder := &bodyRecorder{ResponseWriter: c.Response().Writer, status: http.StatusOK}
+		c.Response().Writer = recorder
+		err := next(c)
+		c.Response().Writer = recorder.ResponseWriter
+		if err != nil {
+			return err
+		}
+
+		body := recorder.body.Bytes()
+		if recorder.status != http.StatusOK || header.Get(headerOffloaded) != "" || header.Get(echo.HeaderContentEncoding) != "" {
+			return recorder.flush(body)
+		}
+		if encoding != "" {
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			if _, err := zw.Write(body); err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			body = compressed.Bytes()
+			header.Set(echo.HeaderContentEncoding, encoding)
+		}
+		header.Set(echo.HeaderContentLength, strconv.Itoa(len(body)))
+
+		entry := &cachedBody{key: key, partition: h.bodyPartition(c), header: http.Header{}, body: body, stored: h.bodies.now()}
+		for name, values := range header {
+			if strings.Join(before[name], "\n") != strings.Join(values, "\n") {
+				entry.header[name] = append([]string(nil), values...)
+			}
+		}
+		h.bodies.put(entry)
+		header.Set(headerBodyCache, "miss")
+		return recorder.flush(body)
+	}
+}
+
+// bodyPartition tags a page with the table it was read from and its partition: the key_condition of
+// queries of the table, and none for scans and index queries
+func (h *Handler) bodyPartition(c echo.Context) string {
+	table, _ := h.schema()
+	if th, ok := h.tables[c.Param("table")]; ok {
+		table, _ = th.schema()
+	}
+	if c.QueryParam("index") != "" || c.QueryParam("consistency_token") != "" {
+		return table + "/"
+	}
+	keyCond, _ := h.queryPartition(c)
+	return table + "/" + keyCond
+}
+
+// replay sends a cached page, with the Age it has been kept for
+func (e *cachedBody) replay(c echo.Context, now time.Time) error {
+	header := c.Response().Header()
+	for name, values := range e.header {
+		header[name] = append([]string(nil), values...)
+	}
+	header.Set("Age", strconv.Itoa(int(now.Sub(e.stored)/time.Second)))
+	header.Set(headerBodyCache, "hit")
+	c.Response().WriteHeader(http.StatusOK)
+	_, err := c.Response().Write(e.body)
+	return err
+}
+
+// acceptsGzip reports whether an Accept-Encoding header accepts gzip
+func acceptsGzip(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.ToLower(strings.TrimSpace(coding)); coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q := strings.ReplaceAll(params, " ", ""); strings.HasPrefix(q, "q=") {
+			if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// bodyRecorder holds back the body a handler writes, sharing the headers of the response it wraps
+type bodyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *bodyRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *bodyRecorder) Write(p []byte) (int, error) {
+	return r.body.Write(p)
+}
+
+// flush sends the response held back, with body in place of the one written
+func (r *bodyRecorder) flush(body []byte) error {
+	r.ResponseWriter.WriteHeader(r.status)
+	_, err := r.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyCache(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+		{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+	}}, nil)
+	now := time.Now()
+	bodies := NewBodyCache(time.Minute, 1<<20)
+	bodies.now = func() time.Time { return now }
+	handler := &Handler{client: mockDynamoDB, bodies: bodies}
+
+	e := echo.New()
+	e.Use(Fingerprint)
+	e.GET("/paginate", handler.handlePagination, handler.cacheBodies)
+	serve := func(target, encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if encoding != "" {
+			req.Header.Set(echo.HeaderAcceptEncoding, encoding)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	queries := func() int { return len(mockDynamoDB.Calls) }
+
+	rec := serve("/paginate?key_condition=test", "gzip, deflate")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "miss", rec.Header().Get(headerBodyCache))
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Contains(t, rec.Header().Values(echo.HeaderVary), echo.HeaderAcceptEncoding)
+	compressed := rec.Body.Bytes()
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err)
+	plain, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	var page Response
+	require.NoError(t, json.Unmarshal(plain, &page))
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, 1, queries())
+
+	// The same request is answered with the same bytes, without reading DynamoDB
+	now = now.Add(5 * time.Second)
+	rec = serve("/paginate?pagesize=10&key_condition=test", "gzip")
+	assert.Equal(t, "hit", rec.Header().Get(headerBodyCache))
+	assert.Equal(t, "5", rec.Header().Get("Age"))
+	assert.Equal(t, "gzip", rec.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, echo.MIMEApplicationJSON, rec.Header().Get(echo.HeaderContentType))
+	assert.True(t, bytes.Equal(compressed, rec.Body.Bytes()))
+	assert.Equal(t, 1, queries())
+
+	// Clients that don't accept gzip are served their own uncompressed copy
+	rec = serve("/paginate?key_condition=test", "")
+	assert.Equal(t, "miss", rec.Header().Get(headerBodyCache))
+	assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	assert.JSONEq(t, string(plain), rec.Body.String())
+	assert.Equal(t, "hit", serve("/paginate?key_condition=test", "gzip;q=0").Header().Get(headerBodyCache))
+	assert.Equal(t, 2, queries())
+
+	// Writing the partition drops its pages, other partitions keep theirs
+	bodies.invalidate(tableName, "other")
+	assert.Equal(t, "hit", serve("/paginate?key_condition=test", "gzip").Header().Get(headerBodyCache))
+	bodies.invalidate(tableName, "test")
+	assert.Equal(t, "miss", serve("/paginate?key_condition=test", "gzip").Header().Get(headerBodyCache))
+	assert.Equal(t, 3, queries())
+
+	// Pages expire after the TTL
+	now = now.Add(time.Minute)
+	assert.Equal(t, "miss", serve("/paginate?key_condition=test", "gzip").Header().Get(headerBodyCache))
+	assert.Equal(t, 4, queries())
+
+	// Debug pages are always read
+	for i := 0; i < 2; i++ {
+		rec = serve("/paginate?key_condition=test&debug=true", "gzip")
+		assert.Empty(t, rec.Header().Get(headerBodyCache))
+		assert.Empty(t, rec.Header().Get(echo.HeaderContentEncoding))
+	}
+	assert.Equal(t, 6, queries())
+
+	// Failed requests aren't cached
+	for i := 0; i < 2; i++ {
+		rec = serve("/paginate?key_condition=test&orderby=price", "gzip")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Empty(t, rec.Header().Get(headerBodyCache))
+	}
+}
+
+func TestBodyCacheEviction(t *testing.T) {
+	bodies := NewBodyCache(time.Minute, 10)
+	stored := time.Now()
+	bodies.put(&cachedBody{key: "a", partition: "T/a", body: []byte("123456"), stored: stored})
+	bodies.put(&cachedBody{key: "b", partition: "T/b", body: []byte("1234"), stored: stored})
+	_, ok := bodies.get("a")
+	require.True(t, ok)
+
+	// The least recently served page makes room
+	bodies.put(&cachedBody{key: "c", partition: "T/", body: []byte("12"), stored: stored})
+	_, ok = bodies.get("b")
+	assert.False(t, ok)
+	_, ok = bodies.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 8, bodies.size)
+
+	// Pages larger than the cache aren't kept
+	bodies.put(&cachedBody{key: "d", partition: "T/d", body: make([]byte, 11), stored: stored})
+	_, ok = bodies.get("d")
+	assert.False(t, ok)
+
+	// Scans and index queries go with any write to the table
+	bodies.invalidate("T", "x")
+	_, ok = bodies.get("c")
+	assert.False(t, ok)
+	_, ok = bodies.get("a")
+	assert.True(t, ok)
+	bodies.invalidate("T", "")
+	assert.Empty(t, bodies.entries)
+	assert.Empty(t, bodies.partitions)
+	assert.Zero(t, bodies.size)
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for accept, expected := range map[string]bool{
+		"":                     false,
+		"gzip":                 true,
+		"deflate, GZIP;q=0.5":  true,
+		"*":                    true,
+		"gzip;q=0":             false,
+		"gzip; q=0, identity":  false,
+		"br, identity;q=1":     false,
+		"deflate, gzip ; q=1 ": true,
+	} {
+		assert.Equal(t, expected, acceptsGzip(accept), accept)
+	}
+}
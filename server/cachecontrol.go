@@ -0,0 +1,51 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// cacheDirectives are the Cache-Control request directives clients steer the page and body caches
+// with
+type cacheDirectives struct {
+	// noCache reads the page again, bypassing what the caches hold; the page read is still stored
+	noCache bool
+	// maxAge, when positive, only accepts pages the caches stored within it
+	maxAge time.Duration
+}
+
+// requestCacheDirectives reads the no-cache and max-age directives of the request's Cache-Control
+// header, and a Pragma: no-cache from HTTP/1.0 clients. max-age=0 is no-cache; other directives and
+// invalid ages are ignored.
+func requestCacheDirectives(c echo.Context) cacheDirectives {
+	var d cacheDirectives
+	header := c.Request().Header
+	for _, part := range strings.Split(header.Get(echo.HeaderCacheControl), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "no-cache":
+			d.noCache = true
+		case "max-age":
+			seconds, err := strconv.ParseInt(strings.Trim(strings.TrimSpace(value), `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			if seconds == 0 {
+				d.noCache = true
+			}
+			d.maxAge = time.Duration(seconds) * time.Second
+		}
+	}
+	if header.Get(echo.HeaderCacheControl) == "" && strings.EqualFold(strings.TrimSpace(header.Get("Pragma")), "no-cache") {
+		d.noCache = true
+	}
+	return d
+}
+
+// accepts reports whether a cached page of an age satisfies the directives
+func (d cacheDirectives) accepts(age time.Duration) bool {
+	return !d.noCache && (d.maxAge <= 0 || age <= d.maxAge)
+}
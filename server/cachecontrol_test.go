@@ -0,0 +1,101 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestCacheDirectives(t *testing.T) {
+	directives := func(cacheControl, pragma string) cacheDirectives {
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		if cacheControl != "" {
+			req.Header.Set(echo.HeaderCacheControl, cacheControl)
+		}
+		if pragma != "" {
+			req.Header.Set("Pragma", pragma)
+		}
+		return requestCacheDirectives(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	assert.Equal(t, cacheDirectives{}, directives("", ""))
+	assert.Equal(t, cacheDirectives{noCache: true}, directives("No-Cache", ""))
+	assert.Equal(t, cacheDirectives{maxAge: 30 * time.Second}, directives("max-stale, max-age=30", ""))
+	assert.Equal(t, cacheDirectives{maxAge: 30 * time.Second}, directives(`max-age="30"`, ""))
+	assert.Equal(t, cacheDirectives{noCache: true}, directives("max-age=0", ""))
+	assert.Equal(t, cacheDirectives{}, directives("max-age=-1, max-age=soon", ""))
+	assert.Equal(t, cacheDirectives{noCache: true}, directives("", "no-cache"))
+	assert.Equal(t, cacheDirectives{maxAge: time.Minute}, directives("max-age=60", "no-cache"), "Cache-Control takes precedence over Pragma")
+
+	assert.True(t, cacheDirectives{}.accepts(time.Hour))
+	assert.True(t, cacheDirectives{maxAge: time.Minute}.accepts(time.Minute))
+	assert.False(t, cacheDirectives{maxAge: time.Minute}.accepts(time.Minute+time.Second))
+	assert.False(t, cacheDirectives{noCache: true}.accepts(0))
+}
+
+func TestBodyCacheDirectives(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	counter := &queryCounter{DynamoClient: client}
+	now := time.Now()
+	bodies := NewBodyCache(time.Hour, 1<<20)
+	bodies.now = func() time.Time { return now }
+	handler := &Handler{client: counter, bodies: bodies}
+
+	e := echo.New()
+	e.Use(Fingerprint)
+	e.GET("/paginate", handler.handlePagination, handler.cacheBodies)
+	serve := func(cacheControl string) string {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+		if cacheControl != "" {
+			req.Header.Set(echo.HeaderCacheControl, cacheControl)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec.Header().Get(headerBodyCache)
+	}
+
+	assert.Equal(t, "miss", serve(""))
+	now = now.Add(time.Minute)
+	assert.Equal(t, "hit", serve(""))
+	assert.Equal(t, "hit", serve("max-age=60"))
+	assert.Equal(t, 1, counter.queries)
+
+	// Too old for the client, the page is read again and replaces the one stored
+	assert.Equal(t, "miss", serve("max-age=30"))
+	assert.Equal(t, "hit", serve("max-age=30"))
+	assert.Equal(t, "miss", serve("no-cache"))
+	assert.Equal(t, 3, counter.queries)
+}
+
+func TestPageCacheDirectives(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	client := &queryCounter{DynamoClient: fixture}
+	handler := &Handler{client: client, pages: pagination.NewLRUPageCache(100, defaultPageCacheTTL, pagination.CachePages)}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	serve := func(cacheControl string) {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=2", nil)
+		req.Header.Set(echo.HeaderCacheControl, cacheControl)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	serve("")
+	serve("max-age=3600")
+	assert.Equal(t, 1, client.queries)
+	serve("no-cache")
+	assert.Equal(t, 2, client.queries)
+	serve("")
+	assert.Equal(t, 2, client.queries, "the page read with no-cache is stored")
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerCacheKey is the normalized request a page was served for, which a CDN can key its cache on
+	headerCacheKey       = "X-Cache-Key"
+	headerSurrogateKey   = "Surrogate-Key"
+	headerSurrogateCache = "Surrogate-Control"
+)
+
+// CDNCaching lets a CDN in front of the service, such as CloudFront, cache pages. Pages are marked
+// with how long a shared cache may keep them and with the normalized request they answer.
+type CDNCaching struct {
+	// TTL is how long a page selected by page number may be cached; those pages shift as items are written
+	TTL time.Duration
+	// CursorTTL is how long a page read from a cursor may be cached; those always start at the same key
+	CursorTTL time.Duration
+}
+
+// loadCDNCaching enables CDN caching for CDN_CACHE_TTL, with cursor pages kept for
+// CDN_CURSOR_CACHE_TTL when it is set. Both are Go durations.
+func loadCDNCaching() (*CDNCaching, error) {
+	v := os.Getenv("CDN_CACHE_TTL")
+	if v == "" {
+		return nil, nil
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		return nil, fmt.Errorf("invalid CDN_CACHE_TTL %q", v)
+	}
+	cdn := &CDNCaching{TTL: ttl, CursorTTL: ttl}
+	if v := os.Getenv("CDN_CURSOR_CACHE_TTL"); v != "" {
+		if cdn.CursorTTL, err = time.ParseDuration(v); err != nil || cdn.CursorTTL < 0 {
+			return nil, fmt.Errorf("invalid CDN_CURSOR_CACHE_TTL %q", v)
+		}
+	}
+	return cdn, nil
+}
+
+// cache marks the page about to be served as cacheable. The Surrogate-Key header tags it with the table
+// and partition it was read from, so they can be purged. Pages answering requests with an API key may be
+// redacted or limited for the tenant and are kept private, as are offloaded pages, whose links expire, debug
+// pages, whose stats are those of one read, and pages answering POST requests, which CDNs don't cache.
+func (d *CDNCaching) cache(c echo.Context, table, keyCond string) {
+	if d == nil {
+		return
+	}
+
+	header := c.Response().Header()
+	header.Set(headerCacheKey, canonicalTarget(c))
+	keys := "table:" + url.PathEscape(table)
+	if keyCond != "" {
+		keys += " partition:" + url.PathEscape(table) + "/" + url.PathEscape(keyCond)
+	}
+	header.Set(headerSurrogateKey, keys)
+
+	ttl := d.TTL
+	if c.QueryParam("cursor") != "" {
+		ttl = d.CursorTTL
+	}
+	private := requestAPIKey(c.Request()) != "" || c.QueryParam("debug") == "true" || c.Request().Method == http.MethodPost
+	c.Response().Before(func() {
+		if private || ttl <= 0 || c.Response().Status != http.StatusOK || header.Get(headerOffloaded) != "" {
+			header.Set(headerSurrogateCache, "no-store")
+			header.Set(echo.HeaderCacheControl, "private, no-store")
+			return
+		}
+		seconds := strconv.FormatInt(int64(ttl/time.Second), 10)
+		header.Set(headerSurrogateCache, "max-age="+seconds)
+		header.Set(echo.HeaderCacheControl, "public, max-age=0, s-maxage="+seconds)
+	})
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationCDNCaching(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client, cdn: &CDNCaching{TTL: time.Minute, CursorTTL: time.Hour}}
+
+	get := func(query string, header http.Header) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	rec := get("pagesize=2&key_condition=test&page=1", nil)
+	assert.Equal(t, "/paginate?key_condition=test&pagesize=2", rec.Header().Get(headerCacheKey))
+	assert.Equal(t, "table:TableName partition:TableName/test", rec.Header().Get(headerSurrogateKey))
+	assert.Equal(t, "max-age=60", rec.Header().Get(headerSurrogateCache))
+	assert.Equal(t, "public, max-age=0, s-maxage=60", rec.Header().Get(echo.HeaderCacheControl))
+
+	rec = get("key_condition=test&pagesize=2&cursor=", http.Header{"Accept": {"text/csv"}})
+	assert.Equal(t, "/paginate?cursor=&format=csv&key_condition=test&pagesize=2", rec.Header().Get(headerCacheKey))
+	// The first page of a cursor chain is selected by position too
+	assert.Equal(t, "max-age=60", rec.Header().Get(headerSurrogateCache))
+
+	rec = get("key_condition=test&pagesize=2&cursor="+url.QueryEscape(rec.Header().Get(headerNextCursor)), nil)
+	assert.Equal(t, "max-age=3600", rec.Header().Get(headerSurrogateCache))
+
+	// Tenants may be served differently, so their pages aren't shared
+	rec = get("key_condition=test", http.Header{headerAPIKey: {"key"}})
+	assert.Equal(t, "no-store", rec.Header().Get(headerSurrogateCache))
+	assert.Equal(t, "private, no-store", rec.Header().Get(echo.HeaderCacheControl))
+
+	// Debug stats are those of one read
+	rec = get("key_condition=test&debug=true", nil)
+	assert.Equal(t, "/paginate?debug=true&key_condition=test", rec.Header().Get(headerCacheKey))
+	assert.Equal(t, "private, no-store", rec.Header().Get(echo.HeaderCacheControl))
+}
+
+func TestCDNCachingOffloaded(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	store := &memoryStore{objects: map[string][]byte{}}
+	handler := &Handler{
+		client:  client,
+		cdn:     &CDNCaching{TTL: time.Minute},
+		offload: &Offloader{Store: store, Threshold: 10, TTL: time.Minute},
+	}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	assert.Equal(t, "true", rec.Header().Get(headerOffloaded))
+	assert.Equal(t, "no-store", rec.Header().Get(headerSurrogateCache))
+}
+
+func TestLoadCDNCaching(t *testing.T) {
+	cdn, err := loadCDNCaching()
+	require.NoError(t, err)
+	assert.Nil(t, cdn)
+
+	t.Setenv("CDN_CACHE_TTL", "30s")
+	cdn, err = loadCDNCaching()
+	require.NoError(t, err)
+	assert.Equal(t, &CDNCaching{TTL: 30 * time.Second, CursorTTL: 30 * time.Second}, cdn)
+
+	t.Setenv("CDN_CURSOR_CACHE_TTL", "1h")
+	cdn, err = loadCDNCaching()
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, cdn.CursorTTL)
+
+	t.Setenv("CDN_CACHE_TTL", "0")
+	_, err = loadCDNCaching()
+	assert.Error(t, err)
+}
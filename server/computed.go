@@ -0,0 +1,459 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// computedNow is the clock of the now() function, replaced in tests
+var computedNow = time.Now
+
+// ComputedField is an output field derived from the attributes of an item
+type ComputedField struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+
+	expr computedExpr
+}
+
+// ComputedFields are the derived fields added to the items of a table
+type ComputedFields struct {
+	Fields []ComputedField
+}
+
+// LoadComputedFields reads the derived fields of table from a JSON file mapping table names to fields
+func LoadComputedFields(path, table string) (*ComputedFields, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseComputedFields(data, table)
+}
+
+// ParseComputedFields decodes the derived fields of table and compiles their expressions
+func ParseComputedFields(data []byte, table string) (*ComputedFields, error) {
+	var tables map[string][]ComputedField
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+
+	fields := tables[table]
+	seen := map[string]bool{}
+	for i := range fields {
+		field := &fields[i]
+		if field.Name == "" {
+			return nil, fmt.Errorf("computed field %d has no name", i)
+		}
+		if seen[field.Name] {
+			return nil, fmt.Errorf("computed field %q is defined twice", field.Name)
+		}
+		seen[field.Name] = true
+
+		expr, err := parseComputedExpr(field.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("computed field %q: %w", field.Name, err)
+		}
+		field.expr = expr
+	}
+	return &ComputedFields{Fields: fields}, nil
+}
+
+// loadComputedFields reads the optional derived fields configured through COMPUTED_FIELDS_FILE
+func loadComputedFields() (*ComputedFields, error) {
+	path := os.Getenv("COMPUTED_FIELDS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadComputedFields(path, tableName)
+}
+
+// Apply evaluates the fields against an item. Fields whose expression reads a missing attribute are
+// left out; fields that can't be evaluated are left out and described in the returned problems, which
+// never quote item values.
+func (f *ComputedFields) Apply(item map[string]types.AttributeValue) (map[string]interface{}, []string) {
+	var values map[string]interface{}
+	var problems []string
+	for _, field := range f.Fields {
+		v, err := field.expr.eval(item)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("computed field %q: %v", field.Name, err))
+			continue
+		}
+		if v == nil {
+			continue
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Format(time.RFC3339)
+		}
+		// A field that is just a number attribute serves it with all its digits
+		if attr, ok := field.expr.(attributeExpr); ok {
+			if n, ok := item[attr.name].(*types.AttributeValueMemberN); ok {
+				v = json.Number(n.Value)
+			}
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		values[field.Name] = v
+	}
+	return values, problems
+}
+
+// computedExpr is a compiled expression. Values are nil for missing attributes, string, float64, bool
+// or time.Time; nil operands make the whole expression nil.
+type computedExpr interface {
+	eval(item map[string]types.AttributeValue) (interface{}, error)
+}
+
+type literalExpr struct{ value interface{} }
+
+func (e literalExpr) eval(map[string]types.AttributeValue) (interface{}, error) {
+	return e.value, nil
+}
+
+type attributeExpr struct{ name string }
+
+func (e attributeExpr) eval(item map[string]types.AttributeValue) (interface{}, error) {
+	switch v := item[e.name].(type) {
+	case nil, *types.AttributeValueMemberNULL:
+		return nil, nil
+	case *types.AttributeValueMemberS:
+		return v.Value, nil
+	case *types.AttributeValueMemberN:
+		n, err := strconv.ParseFloat(v.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %q isn't a valid number", e.name)
+		}
+		return n, nil
+	case *types.AttributeValueMemberBOOL:
+		return v.Value, nil
+	}
+	return nil, fmt.Errorf("attribute %q has an unsupported type", e.name)
+}
+
+type binaryExpr struct {
+	op          byte
+	left, right computedExpr
+}
+
+func (e binaryExpr) eval(item map[string]types.AttributeValue) (interface{}, error) {
+	left, err := e.left.eval(item)
+	if err != nil || left == nil {
+		return nil, err
+	}
+	right, err := e.right.eval(item)
+	if err != nil || right == nil {
+		return nil, err
+	}
+
+	if e.op == '+' {
+		_, leftString := left.(string)
+		_, rightString := right.(string)
+		if leftString || rightString {
+			return formatComputed(left) + formatComputed(right), nil
+		}
+	}
+
+	a, aok := left.(float64)
+	b, bok := right.(float64)
+	if !aok || !bok {
+		return nil, fmt.Errorf("%c needs numbers", e.op)
+	}
+	switch e.op {
+	case '+':
+		return a + b, nil
+	case '-':
+		return a - b, nil
+	case '*':
+		return a * b, nil
+	}
+	if b == 0 {
+		return nil, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+type callExpr struct {
+	name string
+	args []computedExpr
+}
+
+// computedFunctions maps function names to their number of arguments, -1 for any number
+var computedFunctions = map[string]int{
+	"concat":    -1,
+	"now":       0,
+	"date":      1,
+	"date_add":  2,
+	"date_diff": 2,
+}
+
+func (e callExpr) eval(item map[string]types.AttributeValue) (interface{}, error) {
+	args := make([]interface{}, len(e.args))
+	for i, arg := range e.args {
+		v, err := arg.eval(item)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	switch e.name {
+	case "concat":
+		var b strings.Builder
+		for _, arg := range args {
+			b.WriteString(formatComputed(arg))
+		}
+		return b.String(), nil
+	case "now":
+		return computedNow(), nil
+	case "date":
+		return toTime(args[0])
+	case "date_add":
+		t, err := toTime(args[0])
+		if err != nil {
+			return nil, err
+		}
+		d, err := toDuration(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return t.Add(d), nil
+	}
+
+	a, err := toTime(args[0])
+	if err != nil {
+		return nil, err
+	}
+	b, err := toTime(args[1])
+	if err != nil {
+		return nil, err
+	}
+	return a.Sub(b).Seconds(), nil
+}
+
+// formatComputed renders a value for concatenation
+func formatComputed(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return ""
+}
+
+// toTime reads timestamps in the layouts recognised by normalization, or numbers as Unix seconds
+func toTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	case string:
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, errors.New("value isn't a timestamp")
+}
+
+// toDuration reads Go durations, days written as "7d", or numbers of seconds
+func toDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case float64:
+		return time.Duration(v * float64(time.Second)), nil
+	case string:
+		if strings.HasSuffix(v, "d") {
+			if n, err := strconv.ParseFloat(strings.TrimSuffix(v, "d"), 64); err == nil {
+				return time.Duration(n * float64(24*time.Hour)), nil
+			}
+		}
+		if d, err := time.ParseDuration(v); err == nil {
+			return d, nil
+		}
+	}
+	return 0, errors.New("value isn't a duration")
+}
+
+// computedParser is a recursive descent parser of the expression language:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | string | attribute | function "(" [ expr { "," expr } ] ")" | "(" expr ")"
+type computedParser struct {
+	src string
+	pos int
+}
+
+func parseComputedExpr(src string) (computedExpr, error) {
+	p := &computedParser{src: src}
+	expr, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+	}
+	return expr, nil
+}
+
+func (p *computedParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// accept consumes c if it is the next character
+func (p *computedParser) accept(c byte) bool {
+	p.skipSpace()
+	if p.pos < len(p.src) && p.src[p.pos] == c {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *computedParser) expr() (computedExpr, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := byte('+')
+		if !p.accept('+') {
+			if op = '-'; !p.accept('-') {
+				return left, nil
+			}
+		}
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *computedParser) term() (computedExpr, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := byte('*')
+		if !p.accept('*') {
+			if op = '/'; !p.accept('/') {
+				return left, nil
+			}
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryExpr{op: op, left: left, right: right}
+	}
+}
+
+func (p *computedParser) unary() (computedExpr, error) {
+	if p.accept('-') {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return binaryExpr{op: '-', left: literalExpr{value: 0.0}, right: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *computedParser) primary() (computedExpr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return nil, errors.New("unexpected end of expression")
+	}
+
+	start := p.pos
+	switch c := p.src[p.pos]; {
+	case c == '(':
+		p.pos++
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(')') {
+			return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+		}
+		return expr, nil
+	case c == '"':
+		p.pos++
+		end := strings.IndexByte(p.src[p.pos:], '"')
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated string at offset %d", start)
+		}
+		p.pos += end + 1
+		return literalExpr{value: p.src[start+1 : p.pos-1]}, nil
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number at offset %d", start)
+		}
+		return literalExpr{value: n}, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		name := p.src[start:p.pos]
+		if !p.accept('(') {
+			return attributeExpr{name: name}, nil
+		}
+		return p.call(name)
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+}
+
+// call parses the arguments of a function whose opening parenthesis was consumed
+func (p *computedParser) call(name string) (computedExpr, error) {
+	arity, ok := computedFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+
+	call := callExpr{name: name}
+	if !p.accept(')') {
+		for {
+			arg, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(')') {
+				break
+			}
+			if !p.accept(',') {
+				return nil, fmt.Errorf("missing ) at offset %d", p.pos)
+			}
+		}
+	}
+	if arity >= 0 && len(call.args) != arity {
+		return nil, fmt.Errorf("%s takes %d arguments", name, arity)
+	}
+	return call, nil
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputedFields(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	computedNow = func() time.Time { return now }
+	defer func() { computedNow = time.Now }()
+
+	fields, err := ParseComputedFields([]byte(`{
+		"TableName": [
+			{"name": "label", "expression": "key_cond + \"/\" + sort_key"},
+			{"name": "total", "expression": "price * (quantity - 1) + 0.5"},
+			{"name": "expires_at", "expression": "date_add(created_at, \"30d\")"},
+			{"name": "age", "expression": "date_diff(now(), created_at) / 86400"},
+			{"name": "tags", "expression": "concat(status, \"-\", -quantity)"},
+			{"name": "missing", "expression": "price + discount"}
+		],
+		"Other": [{"name": "ignored", "expression": "price"}]
+	}`), "TableName")
+	require.NoError(t, err)
+
+	values, problems := fields.Apply(map[string]types.AttributeValue{
+		"key_cond":   &types.AttributeValueMemberS{Value: "test"},
+		"sort_key":   &types.AttributeValueMemberS{Value: "item1"},
+		"price":      &types.AttributeValueMemberN{Value: "2.5"},
+		"quantity":   &types.AttributeValueMemberN{Value: "3"},
+		"created_at": &types.AttributeValueMemberS{Value: "2024-02-20T00:00:00Z"},
+		"status":     &types.AttributeValueMemberS{Value: "active"},
+	})
+	assert.Empty(t, problems)
+	assert.Equal(t, map[string]interface{}{
+		"label":      "test/item1",
+		"total":      5.5,
+		"expires_at": "2024-03-21T00:00:00Z",
+		"age":        10.0,
+		"tags":       "active--3",
+	}, values)
+
+	values, problems = fields.Apply(map[string]types.AttributeValue{
+		"price":      &types.AttributeValueMemberS{Value: "secret"},
+		"quantity":   &types.AttributeValueMemberN{Value: "3"},
+		"created_at": &types.AttributeValueMemberS{Value: "yesterday"},
+	})
+	assert.Nil(t, values)
+	assert.Equal(t, []string{
+		`computed field "total": * needs numbers`,
+		`computed field "expires_at": value isn't a timestamp`,
+		`computed field "age": value isn't a timestamp`,
+	}, problems)
+}
+
+func TestParseComputedFieldsInvalid(t *testing.T) {
+	for _, config := range []string{
+		`{"TableName": [{"name": "", "expression": "price"}]}`,
+		`{"TableName": [{"name": "a", "expression": "price"}, {"name": "a", "expression": "price"}]}`,
+		`{"TableName": [{"name": "a", "expression": "price *"}]}`,
+		`{"TableName": [{"name": "a", "expression": "(price"}]}`,
+		`{"TableName": [{"name": "a", "expression": "upper(price)"}]}`,
+		`{"TableName": [{"name": "a", "expression": "date_add(price)"}]}`,
+		`{"TableName": [{"name": "a", "expression": "\"open"}]}`,
+		`{"TableName": [{"name": "a", "expression": "price price"}]}`,
+	} {
+		_, err := ParseComputedFields([]byte(config), "TableName")
+		assert.Error(t, err, config)
+	}
+}
+
+func TestDecodeItemComputedFields(t *testing.T) {
+	fields, err := ParseComputedFields([]byte(`{"TableName": [{"name": "label", "expression": "key_cond + \":\" + sort_key"}, {"name": "bad", "expression": "sort_key * 2"}]}`), "TableName")
+	require.NoError(t, err)
+	handler := &Handler{computed: fields}
+	item := map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+	}
+
+	entry, warnings, keep, reqErr := handler.decodeItem(item, fullItem)
+	require.Nil(t, reqErr)
+	assert.True(t, keep)
+	assert.Equal(t, map[string]interface{}{"label": "test:item1"}, entry.Computed)
+	assert.Equal(t, []Warning{{Code: "computed_field_error", Message: `computed field "bad": * needs numbers`, Key: entry.Key()}}, warnings)
+
+	// keys_only pages serve only the keys
+	entry, _, _, reqErr = handler.decodeItem(item, keysOnlyItem)
+	require.Nil(t, reqErr)
+	assert.Nil(t, entry.Computed)
+}
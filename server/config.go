@@ -0,0 +1,92 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+// ConfigureTable applies the table name and key attributes set in the options, or else in TABLE_NAME,
+// PARTITION_KEY and SORT_KEY, to every query the service builds, with the type of the sort key in
+// SORT_KEY_TYPE. Run calls it before serving; call it earlier to build fixtures with the configured key
+// attributes. A table without a sort key is set with NoSortKey or an empty SORT_KEY.
+func ConfigureTable(opts Options) {
+	tableName = firstSet(opts.Table, os.Getenv("TABLE_NAME"), tableName)
+	tableKeys.PartitionKey = firstSet(opts.PartitionKey, os.Getenv("PARTITION_KEY"), tableKeys.PartitionKey)
+	tableKeys.SortKey = firstSet(opts.SortKey, os.Getenv("SORT_KEY"), tableKeys.SortKey)
+	tableKeys.SortKeyType = types.ScalarAttributeType(firstSet(os.Getenv("SORT_KEY_TYPE"), string(tableKeys.SortKeyType)))
+	if sortKey, ok := os.LookupEnv("SORT_KEY"); opts.NoSortKey || (opts.SortKey == "" && ok && sortKey == "") {
+		tableKeys.SortKey, tableKeys.SortKeyType = "", ""
+	}
+}
+
+// checkSortKeyType validates the type of a sort key: sort keys are strings or numbers
+func checkSortKeyType(keys pagination.KeySchema) error {
+	switch keys.SortKeyType {
+	case "", types.ScalarAttributeTypeS, types.ScalarAttributeTypeN:
+		return nil
+	}
+	return fmt.Errorf("invalid sort key type %q", keys.SortKeyType)
+}
+
+// keyAttribute types the value of a key attribute like the key schema, e.g. keeping 00123 a string
+// for a string sort key
+func keyAttribute(keys pagination.KeySchema, name, value string) (types.AttributeValue, error) {
+	if t, _ := keys.AttributeType(name); t == types.ScalarAttributeTypeN {
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("key attribute %q must be a number", name)
+		}
+		return &types.AttributeValueMemberN{Value: value}, nil
+	}
+	return &types.AttributeValueMemberS{Value: value}, nil
+}
+
+func firstSet(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// primaryKey builds the key of the item with partition key pk and sort key sk of the table; sk is
+// ignored when the table has no sort key
+func primaryKey(pk, sk string) map[string]types.AttributeValue {
+	return schemaKey(tableKeys, pk, sk)
+}
+
+// schemaKey is primaryKey for a table with the key attributes keys
+func schemaKey(keys pagination.KeySchema, pk, sk string) map[string]types.AttributeValue {
+	key := map[string]types.AttributeValue{keys.PartitionKey: &types.AttributeValueMemberS{Value: pk}}
+	if keys.SortKey != "" {
+		key[keys.SortKey] = &types.AttributeValueMemberS{Value: sk}
+		if keys.SortKeyType == types.ScalarAttributeTypeN {
+			key[keys.SortKey] = &types.AttributeValueMemberN{Value: sk}
+		}
+	}
+	return key
+}
+
+// entryItem returns an item with its key attributes under the names Entry reads them from, key_cond
+// and sort_key, when the table's keys are named differently
+func entryItem(item map[string]types.AttributeValue, keys pagination.KeySchema) map[string]types.AttributeValue {
+	if keys.PartitionKey == "key_cond" && keys.SortKey == "sort_key" {
+		return item
+	}
+	renamed := make(map[string]types.AttributeValue, len(item))
+	for name, av := range item {
+		renamed[name] = av
+	}
+	renamed["key_cond"] = item[keys.PartitionKey]
+	renamed["sort_key"] = item[keys.SortKey]
+	for name, av := range renamed {
+		if av == nil {
+			delete(renamed, name)
+		}
+	}
+	return renamed
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withTableConfig(t *testing.T) {
+	name, keys := tableName, tableKeys
+	t.Cleanup(func() { tableName, tableKeys = name, keys })
+}
+
+func TestConfigureTable(t *testing.T) {
+	withTableConfig(t)
+	t.Setenv("TABLE_NAME", "EnvTable")
+	t.Setenv("PARTITION_KEY", "pk")
+	t.Setenv("SORT_KEY", "sk")
+
+	ConfigureTable(Options{Table: "FlagTable"})
+
+	assert.Equal(t, "FlagTable", tableName)
+	assert.Equal(t, pagination.KeySchema{PartitionKey: "pk", SortKey: "sk"}, tableKeys)
+}
+
+func TestConfigureTableDefaults(t *testing.T) {
+	withTableConfig(t)
+
+	ConfigureTable(Options{})
+
+	assert.Equal(t, "TableName", tableName)
+	assert.Equal(t, pagination.KeySchema{PartitionKey: "key_cond", SortKey: "sort_key"}, tableKeys)
+}
+
+func TestConfigureTableNoSortKey(t *testing.T) {
+	withTableConfig(t)
+	t.Setenv("SORT_KEY", "")
+	ConfigureTable(Options{})
+	assert.Equal(t, pagination.KeySchema{PartitionKey: "key_cond"}, tableKeys)
+	assert.Equal(t, map[string]types.AttributeValue{"key_cond": &types.AttributeValueMemberS{Value: "a"}}, primaryKey("a", "b"))
+
+	t.Setenv("SORT_KEY", "sk")
+	ConfigureTable(Options{NoSortKey: true})
+	assert.Empty(t, tableKeys.SortKey)
+}
+
+func TestDecodeItemConfiguredKeys(t *testing.T) {
+	withTableConfig(t)
+	ConfigureTable(Options{PartitionKey: "pk", SortKey: "sk"})
+
+	h := &Handler{}
+	entry, _, ok, err := h.decodeItem(map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "test"},
+		"sk": &types.AttributeValueMemberS{Value: "item1"},
+	}, fullItem)
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "test", entry.KeyCond)
+	assert.Equal(t, "item1", entry.SortKey)
+}
+
+func TestNewClientsEndpoint(t *testing.T) {
+	var authorization, target string
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization, target = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Target")
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"TableNames": ["TableName"]}`))
+	}))
+	defer local.Close()
+	t.Setenv("DYNAMO_ENDPOINT", local.URL)
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAPRODUCTION")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("REPLICA_REGIONS", "eu-west-1")
+
+	client, replicas, region, err := newClients(Options{})
+	require.NoError(t, err)
+	assert.Nil(t, replicas)
+	assert.Equal(t, "us-east-1", region)
+
+	out, err := client.(*dynamodb.Client).ListTables(context.Background(), &dynamodb.ListTablesInput{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TableName"}, out.TableNames)
+	assert.Equal(t, "DynamoDB_20120810.ListTables", target)
+	assert.True(t, strings.Contains(authorization, "Credential=local/"), authorization)
+
+	t.Setenv("DYNAMO_ENDPOINT", "localhost:8000")
+	_, _, _, err = newClients(Options{})
+	assert.Error(t, err)
+}
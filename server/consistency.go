@@ -0,0 +1,152 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Headers telling where a page was read from
+const (
+	headerServedRegion     = "X-Served-Region"
+	headerServedIndex      = "X-Served-Index"
+	headerReadTime         = "X-Read-Time"
+	headerConsistencyToken = "X-Consistency-Token"
+)
+
+// ConsistencyToken records where a page was read from. Passed back as consistency_token, it keeps the
+// pages after it on the same replica and index, so a session doesn't see the data move back in time
+// when replicas or indexes lag behind each other. It only pins the region and index: the time it
+// records isn't read back, and says nothing of how far the replica lags.
+type ConsistencyToken struct {
+	// Region is the replica region that served the page
+	Region string `json:"r,omitempty"`
+	// Index is the secondary index read, empty for the table
+	Index string `json:"i,omitempty"`
+	// ReadAt is the time the page was read, in Unix milliseconds
+	ReadAt int64 `json:"t,omitempty"`
+}
+
+// Encode renders the token as the opaque string handed to clients
+func (t ConsistencyToken) Encode() string {
+	data, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeConsistencyToken parses a token rendered by Encode
+func DecodeConsistencyToken(s string) (ConsistencyToken, error) {
+	var t ConsistencyToken
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	if err := json.Unmarshal(data, &t); err != nil {
+		return t, err
+	}
+	if t.ReadAt < 0 {
+		return t, errors.New("negative read time")
+	}
+	return t, nil
+}
+
+type consistencyKey struct{}
+
+// consistencyFrom returns the token a request is pinned to
+func consistencyFrom(ctx context.Context) (ConsistencyToken, bool) {
+	t, ok := ctx.Value(consistencyKey{}).(ConsistencyToken)
+	return t, ok
+}
+
+// parseConsistencyToken pins the request to the region and index of its consistency_token parameter.
+// The region and index parameters, when set, must agree with it.
+func parseConsistencyToken(c echo.Context) *requestError {
+	raw := c.QueryParam("consistency_token")
+	if raw == "" {
+		return nil
+	}
+	token, err := DecodeConsistencyToken(raw)
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid consistency_token parameter", err: err}
+	}
+	if region := c.QueryParam("region"); region != "" && region != token.Region {
+		return &requestError{status: http.StatusBadRequest, message: "The consistency_token was issued for another region"}
+	}
+	if index := c.QueryParam("index"); index != "" && index != token.Index {
+		return &requestError{status: http.StatusBadRequest, message: "The consistency_token was issued for another index"}
+	}
+	c.SetRequest(c.Request().WithContext(context.WithValue(c.Request().Context(), consistencyKey{}, token)))
+	return nil
+}
+
+// indexParam returns the index a request reads: the index parameter, or else the index of its
+// consistency token
+func indexParam(c echo.Context) string {
+	if index := c.QueryParam("index"); index != "" {
+		return index
+	}
+	token, _ := consistencyFrom(c.Request().Context())
+	return token.Index
+}
+
+// pinnedClient returns the client of the region a consistency token names: a routed or configured
+// replica, or the table's own region
+func (h *Handler) pinnedClient(c echo.Context, region string) (DynamoClient, bool) {
+	if h.router != nil {
+		if client, ok := h.router.Client(region); ok {
+			c.SetRequest(c.Request().WithContext(withCursorRegion(c.Request().Context(), region)))
+			return client, true
+		}
+	}
+	if client, ok := h.replicas[region]; ok {
+		return client, true
+	}
+	if region == h.region {
+		return h.client, true
+	}
+	return nil, false
+}
+
+// servedRegion returns the region that served a request
+func (h *Handler) servedRegion(c echo.Context) string {
+	if region := c.QueryParam("region"); region != "" {
+		return region
+	}
+	ctx := c.Request().Context()
+	if region, _ := ctx.Value(cursorRegionKey{}).(string); region != "" {
+		return region
+	}
+	if token, ok := consistencyFrom(ctx); ok {
+		return token.Region
+	}
+	return h.region
+}
+
+// setConsistency tells the client where the page it is sent was read from, with the token keeping its
+// next pages there
+func (h *Handler) setConsistency(c echo.Context) {
+	token := ConsistencyToken{Region: h.servedRegion(c), Index: indexParam(c), ReadAt: time.Now().UnixMilli()}
+	header := c.Response().Header()
+	if token.Region != "" {
+		header.Set(headerServedRegion, token.Region)
+	}
+	if token.Index != "" {
+		header.Set(headerServedIndex, token.Index)
+	}
+	header.Set(headerReadTime, time.UnixMilli(token.ReadAt).UTC().Format(time.RFC3339Nano))
+	header.Set(headerConsistencyToken, token.Encode())
+}
+
+// canonicalConsistencyToken drops the read time of a token, which doesn't change where a request is read
+func canonicalConsistencyToken(v string) string {
+	token, err := DecodeConsistencyToken(v)
+	if err != nil {
+		return v
+	}
+	token.ReadAt = 0
+	return token.Encode()
+}
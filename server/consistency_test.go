@@ -0,0 +1,98 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyTokenRoundTrip(t *testing.T) {
+	token := ConsistencyToken{Region: "eu-west-1", Index: "by_status", ReadAt: 1700000000000}
+	decoded, err := DecodeConsistencyToken(token.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, token, decoded)
+
+	for _, invalid := range []string{"not a token!", "bm90IGpzb24", ConsistencyToken{ReadAt: -1}.Encode()} {
+		_, err := DecodeConsistencyToken(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestHandlePaginationConsistencyToken(t *testing.T) {
+	primary := new(MockDynamoDB)
+	replica := new(MockDynamoDB)
+	onIndex := mock.MatchedBy(func(input *dynamodb.QueryInput) bool { return aws.StringValue(input.IndexName) == "by_status" })
+	replica.On("Query", mock.Anything, onIndex).Return(&dynamodb.QueryOutput{}, nil)
+	handler := &Handler{
+		client:   primary,
+		region:   "us-east-1",
+		replicas: map[string]DynamoClient{"eu-west-1": replica},
+		indexes:  map[string]pagination.KeySchema{"by_status": {PartitionKey: "status", SortKey: "sort_key"}},
+	}
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		_ = handler.handlePagination(echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec))
+		return rec
+	}
+
+	before := time.Now().Add(-time.Second)
+	rec := serve("/paginate?key_condition=test&region=eu-west-1&index=by_status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "eu-west-1", rec.Header().Get(headerServedRegion))
+	assert.Equal(t, "by_status", rec.Header().Get(headerServedIndex))
+	served, err := time.Parse(time.RFC3339Nano, rec.Header().Get(headerReadTime))
+	require.NoError(t, err)
+	assert.True(t, served.After(before))
+
+	// The next page is read from the same replica and index without naming them
+	token := rec.Header().Get(headerConsistencyToken)
+	rec = serve("/paginate?key_condition=test&page=2&consistency_token=" + token)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "eu-west-1", rec.Header().Get(headerServedRegion))
+	assert.Equal(t, "by_status", rec.Header().Get(headerServedIndex))
+	replica.AssertNumberOfCalls(t, "Query", 2)
+	primary.AssertNotCalled(t, "Query", mock.Anything, mock.Anything)
+
+	for _, target := range []string{
+		"/paginate?key_condition=test&consistency_token=invalid!",
+		"/paginate?key_condition=test&region=us-east-1&consistency_token=" + token,
+		"/paginate?key_condition=test&index=other&consistency_token=" + token,
+	} {
+		rec = serve(target)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Equal(t, codeValidation, errorBody(t, rec).Code, target)
+	}
+}
+
+func TestHandlePaginationConsistencyTokenPrimary(t *testing.T) {
+	primary := new(MockDynamoDB)
+	primary.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+	handler := &Handler{client: primary, region: "us-east-1", replicas: map[string]DynamoClient{"eu-west-1": new(MockDynamoDB)}}
+
+	rec := httptest.NewRecorder()
+	token := ConsistencyToken{Region: "us-east-1", ReadAt: 1700000000000}.Encode()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&consistency_token="+token, nil)
+	require.NoError(t, handler.handlePagination(echo.New().NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "us-east-1", rec.Header().Get(headerServedRegion))
+	assert.Empty(t, rec.Header().Get(headerServedIndex))
+	primary.AssertExpectations(t)
+}
+
+func TestCanonicalConsistencyToken(t *testing.T) {
+	// Tokens for the same replica and index fingerprint alike, whenever they were issued
+	first := ConsistencyToken{Region: "eu-west-1", ReadAt: 1}.Encode()
+	second := ConsistencyToken{Region: "eu-west-1", ReadAt: 2}.Encode()
+	assert.Equal(t, canonicalConsistencyToken(first), canonicalConsistencyToken(second))
+	assert.NotEqual(t, canonicalConsistencyToken(first), canonicalConsistencyToken(ConsistencyToken{Region: "us-east-1"}.Encode()))
+}
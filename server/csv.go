@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvSerializer renders the items of a page as CSV with a header row. The key columns are named by the
+// json tags of Entry, followed by a column per typed attribute and then per computed field found in the
+// page, by name.
+type csvSerializer struct{}
+
+func (csvSerializer) ContentType() string {
+	return "text/csv; charset=utf-8"
+}
+
+func (csvSerializer) Serialize(page interface{}, header http.Header) ([]byte, error) {
+	rows, ok := rowsOf(page)
+	if !ok {
+		return nil, errors.New("csv only renders pages of items")
+	}
+	rows.setHeaders(header)
+
+	fields := entryColumns()
+	attributes := mapColumns(rows.items, func(item Entry) map[string]interface{} { return item.Attributes })
+	computed := mapColumns(rows.items, func(item Entry) map[string]interface{} { return item.Computed })
+	columns := make([]string, 0, len(fields)+len(attributes)+len(computed))
+	for _, field := range fields {
+		columns = append(columns, field.name)
+	}
+	columns = append(columns, attributes...)
+	columns = append(columns, computed...)
+
+	var body bytes.Buffer
+	w := csv.NewWriter(&body)
+	if err := w.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, item := range rows.items {
+		record := make([]string, 0, len(columns))
+		v := reflect.ValueOf(item)
+		for _, field := range fields {
+			record = append(record, csvCell(v.Field(field.index).Interface()))
+		}
+		for _, name := range attributes {
+			record = append(record, csvCell(item.Attributes[name]))
+		}
+		for _, name := range computed {
+			record = append(record, csvCell(item.Computed[name]))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return body.Bytes(), w.Error()
+}
+
+// entryColumn is a field of Entry rendered as a column
+type entryColumn struct {
+	name  string
+	index int
+}
+
+// entryColumns lists the scalar fields of Entry under their json names
+func entryColumns() []entryColumn {
+	var columns []entryColumn
+	t := reflect.TypeOf(Entry{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() || field.Type.Kind() == reflect.Map {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		columns = append(columns, entryColumn{name: name, index: i})
+	}
+	return columns
+}
+
+// mapColumns lists the keys of a map field of any of the items, by name
+func mapColumns(items []Entry, field func(Entry) map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, item := range items {
+		for name := range field(item) {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// csvCell renders a value as a cell. Lists and maps are written as JSON, and text a spreadsheet would
+// read as a formula is prefixed with a quote.
+func csvCell(v interface{}) string {
+	var cell string
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		cell = val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		cell = string(data)
+	default:
+		cell = fmt.Sprint(val)
+	}
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
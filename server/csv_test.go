@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVSerializer(t *testing.T) {
+	page := Response{
+		Data: []Entry{
+			{KeyCond: "test", SortKey: "item1", Computed: map[string]interface{}{"total": 5.5, "label": "a, b"}},
+			{KeyCond: "test", SortKey: "=cmd()", Computed: map[string]interface{}{"tags": []interface{}{"x", "y"}, "active": true}},
+			{KeyCond: "test", SortKey: "item3"},
+		},
+		NextCursor: "abc",
+		HasMore:    true,
+	}
+
+	header := http.Header{}
+	body, err := csvSerializer{}.Serialize(page, header)
+	require.NoError(t, err)
+	assert.Equal(t, `key_cond,sort_key,active,label,tags,total
+test,item1,,"a, b",,5.5
+test,'=cmd(),true,,"[""x"",""y""]",
+test,item3,,,,
+`, string(body))
+	assert.Equal(t, "true", header.Get(headerHasMore))
+	assert.Equal(t, "abc", header.Get(headerNextCursor))
+
+	body, err = csvSerializer{}.Serialize(Response{}, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "key_cond,sort_key\n", string(body))
+}
+
+func TestHandlePaginationCSV(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	for _, request := range []func(*http.Request){
+		func(req *http.Request) { req.URL.RawQuery += "&format=csv" },
+		func(req *http.Request) { req.Header.Set(echo.HeaderAccept, "text/csv") },
+	} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=2", nil)
+		request(req)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "key_cond,sort_key\ntest,item1\ntest,item2\n", rec.Body.String())
+		assert.Equal(t, "true", rec.Header().Get(headerHasMore))
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// parseCursor switches params to cursor mode when the cursor parameter is present. An empty cursor
+// starts at the beginning; a cursor must belong to the partition being queried, unless keyCond is empty.
+// keys are those of the table or index being read. Sealed cursors are opened first, and rejected when
+// they were tampered with or have expired, and obfuscated cursors are decoded.
+func (h *Handler) parseCursor(c echo.Context, keys pagination.KeySchema, keyCond string, params *Params) *requestError {
+	token, ok := c.QueryParams()["cursor"]
+	if !ok {
+		return nil
+	}
+	params.CursorMode = true
+	if token[0] == "" {
+		return nil
+	}
+
+	_, sealed := splitCursorRegion(token[0])
+	opened, err := h.cursors.open(sealed)
+	if errors.Is(err, errExpiredCursor) {
+		return &requestError{status: http.StatusBadRequest, message: "Cursor has expired", err: err}
+	}
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid cursor parameter", err: err}
+	}
+	if opened, err = h.resolveKey(CursorPosition, opened); err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid cursor parameter", err: err}
+	}
+	encoded, total, err := splitCursorTotal(opened)
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid cursor parameter", err: err}
+	}
+	key, err := pagination.DecodeCursor(encoded)
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid cursor parameter", err: err}
+	}
+	if pk, ok := key[keys.PartitionKey]; ok && keyCond != "" && attributeString(pk) != keyCond {
+		return &requestError{status: http.StatusBadRequest, message: "Cursor doesn't belong to this key_condition"}
+	}
+	// The cursors of a table without a sort key hold its partition key alone; DynamoDB rejects others
+	if _, ok := key[keys.PartitionKey]; params.IndexName == "" && keys.SortKey == "" && (!ok || len(key) != 1) {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid cursor parameter: the table has no sort key"}
+	}
+	params.Cursor = key
+	params.Total = total
+	return nil
+}
+
+type cursorRegionKey struct{}
+
+// withCursorRegion records the replica region that cursors issued for a request are pinned to
+func withCursorRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, cursorRegionKey{}, region)
+}
+
+// pinCursor seals a cursor handed to a client and prefixes it with the region recorded in ctx, if any.
+// With key obfuscation the cursor, which holds the keys of an item, is obfuscated as a whole.
+func (h *Handler) pinCursor(ctx context.Context, cursor string) (string, *requestError) {
+	cursor, err := h.cursors.seal(h.exposeKey(CursorPosition, cursor))
+	if err != nil {
+		return "", &requestError{status: http.StatusInternalServerError, message: "Error sealing cursor", err: err}
+	}
+	region, _ := ctx.Value(cursorRegionKey{}).(string)
+	if region == "" || cursor == "" {
+		return cursor, nil
+	}
+	return region + "." + cursor, nil
+}
+
+// pinTotal appends the total counted for a cursor chain to the cursor continuing it, so its later pages
+// report the same total without counting again. Encoded keys never contain a tilde.
+func pinTotal(cursor string, total *int64) string {
+	if cursor == "" || total == nil {
+		return cursor
+	}
+	return cursor + "~" + strconv.FormatInt(*total, 10)
+}
+
+// splitCursorTotal separates the total pinned to a cursor, if any, from the encoded key
+func splitCursorTotal(token string) (string, *int64, error) {
+	encoded, pinned, ok := strings.Cut(token, "~")
+	if !ok {
+		return token, nil, nil
+	}
+	total, err := strconv.ParseInt(pinned, 10, 64)
+	if err != nil || total < 0 {
+		return "", nil, fmt.Errorf("invalid cursor total %q", pinned)
+	}
+	return encoded, &total, nil
+}
+
+// splitCursorRegion separates the region a cursor is pinned to from the encoded key. Encoded keys
+// never contain a dot.
+func splitCursorRegion(token string) (string, string) {
+	if region, key, ok := strings.Cut(token, "."); ok {
+		return region, key
+	}
+	return "", token
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationCursor(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	get := func(query string) (*httptest.ResponseRecorder, Response) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		require.NoError(t, handler.handlePagination(c))
+
+		var response Response
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		}
+		return rec, response
+	}
+
+	var sortKeys []string
+	cursor := ""
+	for i := 0; i < 3; i++ {
+		rec, response := get("key_condition=test&pagesize=2&cursor=" + url.QueryEscape(cursor))
+		require.Equal(t, http.StatusOK, rec.Code)
+		for _, entry := range response.Data {
+			sortKeys = append(sortKeys, entry.SortKey)
+		}
+		cursor = response.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"item1", "item2", "item3"}, sortKeys)
+	assert.Empty(t, cursor)
+
+	rec, _ := get("key_condition=test&cursor=garbage!")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	otherCursor, err := pagination.EncodeCursor(map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "other"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+	})
+	require.NoError(t, err)
+	rec, _ = get("key_condition=test&cursor=" + otherCursor)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Cursor doesn't belong to this key_condition", errorBody(t, rec).Message)
+}
+
+func TestHandlePaginationV2Cursor(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v2/paginate?key_condition=test&pagesize=2&cursor=", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: client}
+	require.NoError(t, handler.handlePaginationV2(c))
+
+	var env struct {
+		Meta  EnvelopeMeta
+		Links EnvelopeLinks
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &env))
+	require.NotEmpty(t, env.Meta.NextCursor)
+	assert.Zero(t, env.Meta.Page)
+	assert.Equal(t, "/v2/paginate?cursor="+env.Meta.NextCursor+"&key_condition=test&pagesize=2", env.Links.Next)
+}
+
+// countingClient counts the Select=COUNT queries made through it
+type countingClient struct {
+	DynamoClient
+	counts int
+}
+
+func (c *countingClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if params.Select == types.SelectCount {
+		c.counts++
+	}
+	return c.DynamoClient.Query(ctx, params, optFns...)
+}
+
+func TestHandlePaginationIncludeCount(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	client := &countingClient{DynamoClient: fixture}
+	handler := &Handler{client: client}
+
+	paginate := func(query string) Response {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=2&include_count=true&"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	first := paginate("cursor=")
+	assert.Equal(t, int64(5), *first.TotalItems)
+	assert.Equal(t, int64(3), *first.TotalPages)
+	assert.True(t, strings.HasSuffix(first.NextCursor, "~5"), first.NextCursor)
+
+	// Later pages of the chain report the total counted for the first one
+	second := paginate("cursor=" + url.QueryEscape(first.NextCursor))
+	assert.Equal(t, int64(5), *second.TotalItems)
+	assert.True(t, strings.HasSuffix(second.NextCursor, "~5"), second.NextCursor)
+	assert.Equal(t, 1, client.counts)
+
+	// Walked pages are counted on every request
+	paginate("page=2")
+	assert.Equal(t, 2, client.counts)
+
+	e := echo.New()
+	for _, query := range []string{"include_count=true&search=item", "include_count=true&group_by=sort_key", "cursor=abc~x"} {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
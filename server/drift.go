@@ -0,0 +1,135 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+// declaredAttribute is an attribute of the table's item struct together with the Go type it decodes into
+type declaredAttribute struct {
+	Type   string
+	GoType reflect.Type
+}
+
+// entryAttributes are the attributes declared by the Entry struct
+var entryAttributes = structAttributes(reflect.TypeOf(Entry{}))
+
+// declaredAttributes are the attributes of the items of a table with their declared types: the key
+// attributes, which Entry reads as key_cond and sort_key, typed like the key schema, and the other
+// attributes of Entry
+func declaredAttributes(keys pagination.KeySchema) map[string]declaredAttribute {
+	declared := make(map[string]declaredAttribute, len(entryAttributes))
+	for name, attribute := range entryAttributes {
+		if name != "key_cond" && name != "sort_key" {
+			declared[name] = attribute
+		}
+	}
+	for _, name := range []string{keys.PartitionKey, keys.SortKey} {
+		if t, ok := keys.AttributeType(name); ok {
+			declared[name] = declaredAttribute{Type: string(t), GoType: reflect.TypeOf("")}
+		}
+	}
+	return declared
+}
+
+func sortedKeys(m map[string]declaredAttribute) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// structAttributes maps the dynamodbav names of a struct's fields to their expected DynamoDB types
+func structAttributes(t reflect.Type) map[string]declaredAttribute {
+	attrs := map[string]declaredAttribute{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("dynamodbav"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		attrs[name] = declaredAttribute{Type: dynamoTypeOf(field.Type), GoType: field.Type}
+	}
+	return attrs
+}
+
+// dynamoTypeOf returns the DynamoDB type a Go type is normally stored as
+func dynamoTypeOf(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "S"
+	case reflect.Bool:
+		return "BOOL"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "N"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "B"
+		}
+		return "L"
+	case reflect.Map, reflect.Struct:
+		return "M"
+	case reflect.Ptr:
+		return dynamoTypeOf(t.Elem())
+	}
+	return ""
+}
+
+// checkDrift compares an item's attributes with their declared types. Attributes that can't be decoded
+// into their field are removed from the returned item so the rest of the entry still renders.
+func checkDrift(item map[string]types.AttributeValue, keys pagination.KeySchema) (map[string]types.AttributeValue, []string) {
+	var messages []string
+	cleaned := item
+	copied := false
+
+	attributes := declaredAttributes(keys)
+	for _, name := range sortedKeys(attributes) {
+		declared := attributes[name]
+		av, ok := item[name]
+		if !ok || declared.Type == "" {
+			continue
+		}
+
+		actual := attributeType(av)
+		if actual == declared.Type || actual == "NULL" {
+			continue
+		}
+
+		message := fmt.Sprintf("attribute %q is %s, expected %s", name, actual, declared.Type)
+		if err := attributevalue.Unmarshal(av, reflect.New(declared.GoType).Interface()); err != nil {
+			if !copied {
+				cleaned = make(map[string]types.AttributeValue, len(item))
+				for k, v := range item {
+					cleaned[k] = v
+				}
+				copied = true
+			}
+			delete(cleaned, name)
+			message += "; value omitted"
+		}
+		messages = append(messages, message)
+	}
+
+	return cleaned, messages
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationTypeDrift(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberN{Value: "42"}},
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberBOOL{Value: true}},
+		},
+	}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+
+	assert.Equal(t, Response{
+		Data: []Entry{{KeyCond: "test", SortKey: "42"}, {KeyCond: "test"}},
+		Page: 1,
+		Size: 2,
+		Meta: &Meta{Warnings: []Warning{
+			{Code: "type_mismatch", Message: `attribute "sort_key" is N, expected S`, Key: map[string]string{"key_cond": "test", "sort_key": "42"}},
+			{Code: "type_mismatch", Message: `attribute "sort_key" is BOOL, expected S; value omitted`, Key: map[string]string{"key_cond": "test", "sort_key": ""}},
+		}},
+	}, response)
+}
+
+func TestCheckDriftKeySchema(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"pk":       &types.AttributeValueMemberS{Value: "test"},
+		"sk":       &types.AttributeValueMemberN{Value: "42"},
+		"sort_key": &types.AttributeValueMemberN{Value: "7"},
+	}
+
+	// Key attributes are checked against the key schema, not the key_cond and sort_key of Entry
+	_, drift := checkDrift(item, pagination.KeySchema{PartitionKey: "pk", SortKey: "sk", SortKeyType: types.ScalarAttributeTypeN})
+	assert.Empty(t, drift)
+	_, drift = checkDrift(item, pagination.KeySchema{PartitionKey: "pk", SortKey: "sk"})
+	assert.Equal(t, []string{`attribute "sk" is N, expected S`}, drift)
+}
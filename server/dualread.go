@@ -0,0 +1,293 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+const (
+	// dualReadFallback reads a partition from the secondary table only when the primary has none of it
+	dualReadFallback = "fallback"
+	// dualReadMerge reads both tables and merges them on the sort key, preferring the primary's items
+	dualReadMerge = "merge"
+)
+
+// DualRead pairs a table with a second table holding the same items during a migration or backfill
+type DualRead struct {
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+	// Mode is "fallback" (the default) or "merge"
+	Mode string `json:"mode,omitempty"`
+
+	// keys and indexes are the key attributes of the primary table and of its secondary indexes,
+	// merged on; the configured table's when unset
+	keys    pagination.KeySchema
+	indexes map[string]pagination.KeySchema
+}
+
+// LoadDualReads reads dual-read table pairs from a JSON file
+func LoadDualReads(path string) (map[string]*DualRead, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDualReads(data)
+}
+
+// ParseDualReads decodes a JSON array of dual-read table pairs and indexes them by primary table
+func ParseDualReads(data []byte) (map[string]*DualRead, error) {
+	var list []*DualRead
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	dualReads := make(map[string]*DualRead, len(list))
+	for i, dual := range list {
+		if dual.Primary == "" || dual.Secondary == "" {
+			return nil, fmt.Errorf("dual read %d needs a primary and a secondary table", i)
+		}
+		if dual.Primary == dual.Secondary {
+			return nil, fmt.Errorf("dual read %q reads the same table twice", dual.Primary)
+		}
+		if _, dup := dualReads[dual.Primary]; dup {
+			return nil, fmt.Errorf("table %q has two dual reads", dual.Primary)
+		}
+		switch dual.Mode {
+		case "":
+			dual.Mode = dualReadFallback
+		case dualReadFallback, dualReadMerge:
+		default:
+			return nil, fmt.Errorf("dual read %q has unknown mode %q", dual.Primary, dual.Mode)
+		}
+		dualReads[dual.Primary] = dual
+	}
+	return dualReads, nil
+}
+
+// setDualReadKeys records the key attributes of the primary tables: the configured table's with its
+// indexes, or a registered table's
+func setDualReadKeys(dualReads map[string]*DualRead, indexes map[string]pagination.KeySchema, tables map[string]*Table) {
+	for name, dual := range dualReads {
+		if table, ok := tables[name]; ok && name != tableName {
+			dual.keys, dual.indexes = table.keys, table.indexes
+		} else {
+			dual.keys, dual.indexes = tableKeys, indexes
+		}
+	}
+}
+
+// keysFor returns the attribute a read of the primary table or of one of its indexes is ordered by,
+// and the attributes of the keys its pages end at: the table keys, and the index keys for index reads
+func (d *DualRead) keysFor(index string) (string, []string) {
+	keys := d.keys
+	if keys.PartitionKey == "" {
+		keys = tableKeys
+	}
+	attributes := []string{keys.PartitionKey}
+	if keys.SortKey != "" {
+		attributes = append(attributes, keys.SortKey)
+	}
+	if index == "" {
+		return keys.SortKey, attributes
+	}
+	indexKeys := d.indexes[index]
+	for _, name := range []string{indexKeys.PartitionKey, indexKeys.SortKey} {
+		if name != "" && name != keys.PartitionKey && name != keys.SortKey {
+			attributes = append(attributes, name)
+		}
+	}
+	return indexKeys.SortKey, attributes
+}
+
+// loadDualReads reads the optional dual-read table pairs configured through DUAL_READS_FILE
+func loadDualReads() (map[string]*DualRead, error) {
+	path := os.Getenv("DUAL_READS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadDualReads(path)
+}
+
+// dualReadClient serves queries and item reads against a primary table from both tables of its pair.
+// Scans and writes only go to the table they name.
+type dualReadClient struct {
+	DynamoClient
+	tables map[string]*DualRead
+}
+
+// readBoth wraps a client so reads of the primary tables also read their secondary tables
+func readBoth(client DynamoClient, tables map[string]*DualRead) DynamoClient {
+	if len(tables) == 0 {
+		return client
+	}
+	return &dualReadClient{DynamoClient: client, tables: tables}
+}
+
+func (c *dualReadClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	dual, ok := c.tables[aws.StringValue(params.TableName)]
+	if !ok {
+		return c.DynamoClient.Query(ctx, params, optFns...)
+	}
+	if dual.Mode == dualReadMerge {
+		return c.merge(ctx, dual, params, optFns)
+	}
+	return c.fallback(ctx, dual, params, optFns)
+}
+
+func (c *dualReadClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	out, err := c.DynamoClient.GetItem(ctx, params, optFns...)
+	dual, ok := c.tables[aws.StringValue(params.TableName)]
+	if err != nil || !ok || len(out.Item) > 0 {
+		return out, err
+	}
+
+	secondary := *params
+	secondary.TableName = &dual.Secondary
+	return c.DynamoClient.GetItem(ctx, &secondary, optFns...)
+}
+
+// onTable copies a query so it runs against another table
+func onTable(params *dynamodb.QueryInput, table string) *dynamodb.QueryInput {
+	input := *params
+	input.TableName = &table
+	return &input
+}
+
+// fallback serves the query from the primary table, or from the secondary when the primary has no
+// items in the partition
+func (c *dualReadClient) fallback(ctx context.Context, dual *DualRead, params *dynamodb.QueryInput, optFns []func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	out, err := c.DynamoClient.Query(ctx, params, optFns...)
+	if err != nil || out.Count > 0 || out.LastEvaluatedKey != nil {
+		return out, err
+	}
+
+	if params.ExclusiveStartKey != nil {
+		// An empty page after the start of the results is the end of the primary's partition, unless
+		// the primary has none of it and the earlier pages came from the secondary
+		probe := *params
+		probe.ExclusiveStartKey = nil
+		probe.Limit = aws.Int32(1)
+		first, err := c.DynamoClient.Query(ctx, &probe, optFns...)
+		if err != nil {
+			return nil, err
+		}
+		if first.Count > 0 {
+			return out, nil
+		}
+	}
+
+	return c.DynamoClient.Query(ctx, onTable(params, dual.Secondary), optFns...)
+}
+
+// merge reads both tables from the same start key and merges their items on the sort key. An item in
+// both tables is served from the primary. The merged page only reaches as far as both tables were
+// read, so the next page continues after its last item in both tables.
+func (c *dualReadClient) merge(ctx context.Context, dual *DualRead, params *dynamodb.QueryInput, optFns []func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	count := input.Select == types.SelectCount
+	if count {
+		// Items in both tables are only counted once, so they have to be read
+		input.Select = ""
+	}
+
+	primary, err := c.DynamoClient.Query(ctx, &input, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := c.DynamoClient.Query(ctx, onTable(&input, dual.Secondary), optFns...)
+	if err != nil {
+		return nil, err
+	}
+
+	sortKey, keyAttributes := dual.keysFor(aws.StringValue(params.IndexName))
+	forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+	compare := func(a, b types.AttributeValue) int {
+		if forward {
+			return compareSortValues(a, b)
+		}
+		return compareSortValues(b, a)
+	}
+
+	// Items past the last key read from a table that has more can't be ordered yet
+	var cut map[string]types.AttributeValue
+	for _, out := range []*dynamodb.QueryOutput{primary, secondary} {
+		if out.LastEvaluatedKey != nil && (cut == nil || compare(out.LastEvaluatedKey[sortKey], cut[sortKey]) < 0) {
+			cut = out.LastEvaluatedKey
+		}
+	}
+
+	var items []map[string]types.AttributeValue
+	more := cut != nil
+	p, s := primary.Items, secondary.Items
+	for len(p) > 0 || len(s) > 0 {
+		if params.Limit != nil && len(items) == int(*params.Limit) {
+			more = true
+			break
+		}
+
+		var next map[string]types.AttributeValue
+		switch {
+		case len(s) == 0:
+			next, p = p[0], p[1:]
+		case len(p) == 0:
+			next, s = s[0], s[1:]
+		default:
+			switch order := compare(p[0][sortKey], s[0][sortKey]); {
+			case order < 0:
+				next, p = p[0], p[1:]
+			case order > 0:
+				next, s = s[0], s[1:]
+			default:
+				next, p, s = p[0], p[1:], s[1:]
+			}
+		}
+
+		if cut != nil && compare(next[sortKey], cut[sortKey]) > 0 {
+			break
+		}
+		items = append(items, next)
+	}
+
+	out := &dynamodb.QueryOutput{
+		Items:            items,
+		Count:            int32(len(items)),
+		ScannedCount:     primary.ScannedCount + secondary.ScannedCount,
+		ConsumedCapacity: addCapacity(params.TableName, primary.ConsumedCapacity, secondary.ConsumedCapacity),
+	}
+	if more {
+		out.LastEvaluatedKey = cut
+		if len(items) > 0 {
+			last := items[len(items)-1]
+			out.LastEvaluatedKey = make(map[string]types.AttributeValue, len(keyAttributes))
+			for _, name := range keyAttributes {
+				out.LastEvaluatedKey[name] = last[name]
+			}
+		}
+	}
+	if count {
+		out.Items = nil
+	}
+	return out, nil
+}
+
+// addCapacity sums the capacity consumed by the reads of both tables
+func addCapacity(table *string, consumed ...*types.ConsumedCapacity) *types.ConsumedCapacity {
+	var total *types.ConsumedCapacity
+	for _, c := range consumed {
+		if c == nil || c.CapacityUnits == nil {
+			continue
+		}
+		if total == nil {
+			total = &types.ConsumedCapacity{TableName: table, CapacityUnits: aws.Float64(0)}
+		}
+		*total.CapacityUnits += *c.CapacityUnits
+	}
+	return total
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDualReads(t *testing.T) {
+	dualReads, err := ParseDualReads([]byte(`[{"primary": "orders_v2", "secondary": "orders"}]`))
+	require.NoError(t, err)
+	assert.Equal(t, dualReadFallback, dualReads["orders_v2"].Mode)
+
+	for _, data := range []string{
+		`[{"primary": "orders_v2"}]`,
+		`[{"primary": "orders", "secondary": "orders"}]`,
+		`[{"primary": "orders_v2", "secondary": "orders", "mode": "race"}]`,
+		`[{"primary": "a", "secondary": "b"}, {"primary": "a", "secondary": "c"}]`,
+	} {
+		_, err := ParseDualReads([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+// newDualReadClient serves TableName from a primary and a secondary fixture
+func newDualReadClient(t *testing.T, mode string, pageSize int32, primary, secondary map[string][]string) DynamoClient {
+	fixture := func(partitions map[string][]string) DynamoClient {
+		var items []map[string]interface{}
+		for pk, sortKeys := range partitions {
+			for _, sk := range sortKeys {
+				items = append(items, map[string]interface{}{"key_cond": pk, "sort_key": sk})
+			}
+		}
+		client, err := NewFixtureClient(Fixture{PartitionKey: "key_cond", SortKey: "sort_key", Items: items})
+		require.NoError(t, err)
+		return &pagedClient{DynamoClient: client, pageSize: pageSize}
+	}
+
+	return readBoth(&tableClient{tables: map[string]DynamoClient{
+		tableName: fixture(primary),
+		"legacy":  fixture(secondary),
+	}}, map[string]*DualRead{tableName: {Primary: tableName, Secondary: "legacy", Mode: mode}})
+}
+
+// pageSortKeys follows the cursors of a partition and returns the sort keys served
+func pageSortKeys(t *testing.T, client DynamoClient, orderBy string) []string {
+	handler := &Handler{client: client}
+	params := Params{PageSize: 2, OrderBy: orderBy, CursorMode: true}
+	sortKeys := []string{}
+	// Bounded, so a cursor that stops advancing fails the test instead of hanging it
+	for i := 0; i < 50; i++ {
+		res, reqErr := handler.fetchPage(context.Background(), client, "test", params, nil)
+		require.Nil(t, reqErr)
+		for _, entry := range res.Data {
+			sortKeys = append(sortKeys, entry.SortKey)
+		}
+		if res.NextCursor == "" {
+			return sortKeys
+		}
+		var err error
+		params.Cursor, err = pagination.DecodeCursor(res.NextCursor)
+		require.NoError(t, err)
+	}
+	t.Fatal("cursor walk didn't end")
+	return nil
+}
+
+func TestDualReadFallback(t *testing.T) {
+	client := newDualReadClient(t, dualReadFallback, 2,
+		map[string][]string{"test": {"a", "c", "e"}},
+		map[string][]string{"test": {"b", "d"}, "legacy-only": {"x", "y", "z"}},
+	)
+
+	assert.Equal(t, []string{"a", "c", "e"}, pageSortKeys(t, client, ""))
+
+	var sortKeys []string
+	var start map[string]types.AttributeValue
+	for {
+		input := keyConditionQuery("legacy-only")
+		input.Limit = aws.Int32(2)
+		input.ExclusiveStartKey = start
+		out, err := client.Query(context.Background(), input)
+		require.NoError(t, err)
+		for _, item := range out.Items {
+			sortKeys = append(sortKeys, attributeString(item["sort_key"]))
+		}
+		if start = out.LastEvaluatedKey; start == nil {
+			break
+		}
+	}
+	assert.Equal(t, []string{"x", "y", "z"}, sortKeys)
+
+	out, err := client.GetItem(context.Background(), &dynamodb.GetItemInput{TableName: &tableName, Key: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "d"},
+	}})
+	require.NoError(t, err)
+	assert.Equal(t, "d", attributeString(out.Item["sort_key"]))
+}
+
+func TestDualReadMerge(t *testing.T) {
+	primary := map[string][]string{"test": {"a", "c", "d", "f"}}
+	secondary := map[string][]string{"test": {"b", "c", "e", "g", "h"}}
+	expected := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+
+	// The tables return fewer items than asked for, like DynamoDB at the 1 MB limit
+	for _, pageSize := range []int32{1, 2, 100} {
+		client := newDualReadClient(t, dualReadMerge, pageSize, primary, secondary)
+		assert.Equal(t, expected, pageSortKeys(t, client, ""), "page size %d", pageSize)
+	}
+
+	client := newDualReadClient(t, dualReadMerge, 100, primary, secondary)
+	reversed := pageSortKeys(t, client, "-sort_key")
+	assert.Equal(t, []string{"h", "g", "f", "e", "d", "c", "b", "a"}, reversed)
+
+	res, reqErr := (&Handler{client: client}).fetchPage(context.Background(), client, "test", Params{Select: "count"}, nil)
+	require.Nil(t, reqErr)
+	assert.Equal(t, int64(8), *res.Meta.Count)
+}
+
+// cannedQueries answers the queries of each table with the same output
+type cannedQueries struct {
+	DynamoClient
+	outputs map[string]*dynamodb.QueryOutput
+}
+
+func (c *cannedQueries) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return c.outputs[aws.StringValue(params.TableName)], nil
+}
+
+func TestDualReadMergeKeySchema(t *testing.T) {
+	item := func(pk, sk, gsiPK, gsiSK string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"pk":     &types.AttributeValueMemberS{Value: pk},
+			"sk":     &types.AttributeValueMemberS{Value: sk},
+			"gsi_pk": &types.AttributeValueMemberS{Value: gsiPK},
+			"gsi_sk": &types.AttributeValueMemberS{Value: gsiSK},
+			"other":  &types.AttributeValueMemberS{Value: "x"},
+		}
+	}
+	tables, err := ParseTables([]byte(`{"orders": {"partition_key": "pk", "sort_key": "sk", "indexes": {"by_status": {"partition_key": "gsi_pk", "sort_key": "gsi_sk"}}}}`))
+	require.NoError(t, err)
+	dualReads := map[string]*DualRead{"orders": {Primary: "orders", Secondary: "orders_old", Mode: dualReadMerge}}
+	setDualReadKeys(dualReads, nil, tables)
+
+	canned := &cannedQueries{outputs: map[string]*dynamodb.QueryOutput{
+		"orders":     {Items: []map[string]types.AttributeValue{item("a", "3", "open", "1"), item("a", "1", "open", "4")}},
+		"orders_old": {Items: []map[string]types.AttributeValue{item("a", "2", "open", "2")}},
+	}}
+	client := readBoth(canned, dualReads)
+	merged := func(out *dynamodb.QueryOutput, attribute string) []string {
+		var values []string
+		for _, item := range out.Items {
+			values = append(values, attributeString(item[attribute]))
+		}
+		return values
+	}
+
+	// Index reads are merged on the index sort key, and continue from the table and index keys
+	out, err := client.Query(context.Background(), &dynamodb.QueryInput{TableName: aws.String("orders"), IndexName: aws.String("by_status"), Limit: aws.Int32(2)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, merged(out, "gsi_sk"))
+	assert.Equal(t, map[string]types.AttributeValue{
+		"pk":     &types.AttributeValueMemberS{Value: "a"},
+		"sk":     &types.AttributeValueMemberS{Value: "2"},
+		"gsi_pk": &types.AttributeValueMemberS{Value: "open"},
+		"gsi_sk": &types.AttributeValueMemberS{Value: "2"},
+	}, out.LastEvaluatedKey)
+
+	// Table reads are merged on the registered table's sort key
+	canned.outputs["orders"].Items = []map[string]types.AttributeValue{item("a", "1", "open", "4"), item("a", "3", "open", "1")}
+	out, err = client.Query(context.Background(), &dynamodb.QueryInput{TableName: aws.String("orders"), Limit: aws.Int32(2)})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, merged(out, "sk"))
+	assert.Equal(t, map[string]types.AttributeValue{
+		"pk": &types.AttributeValueMemberS{Value: "a"},
+		"sk": &types.AttributeValueMemberS{Value: "2"},
+	}, out.LastEvaluatedKey)
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Envelope is the response body of the v2 routes. Items are in data, paging details in meta,
+// navigation in links and per-item problems in warnings.
+type Envelope struct {
+	Data     interface{}       `json:"data"`
+	Meta     EnvelopeMeta      `json:"meta"`
+	Links    *EnvelopeLinks    `json:"links,omitempty"`
+	Warnings []EnvelopeWarning `json:"warnings,omitempty"`
+}
+
+// EnvelopeMeta describes the returned page
+type EnvelopeMeta struct {
+	// Page is left out in cursor mode
+	Page     int64 `json:"page,omitempty"`
+	PageSize int64 `json:"page_size"`
+	Count    int64 `json:"count"`
+	HasMore  bool  `json:"has_more"`
+	// NextCursor resumes after this page in cursor mode
+	NextCursor string `json:"next_cursor,omitempty"`
+	// ItemCount is the number of items in the partition, returned for select=count
+	ItemCount *int64 `json:"item_count,omitempty"`
+	// TotalItems and TotalPages count the items of the query and the pages they fill, returned with
+	// include_count
+	TotalItems *int64 `json:"total_items,omitempty"`
+	TotalPages *int64 `json:"total_pages,omitempty"`
+	// ConsumedCapacity is the total read capacity used, returned with return_consumed_capacity
+	ConsumedCapacity float64 `json:"consumed_capacity,omitempty"`
+	// CapacityBreakdown splits ConsumedCapacity by table and index, returned with return_consumed_capacity=indexes
+	CapacityBreakdown *EnvelopeCapacity `json:"capacity_breakdown,omitempty"`
+	// Stats describe the DynamoDB reads of the page, returned with debug=true
+	Stats *EnvelopeStats `json:"stats,omitempty"`
+	// Hints are likely reasons an empty page is empty, returned with explain_empty=true
+	Hints []EnvelopeHint `json:"hints,omitempty"`
+}
+
+// EnvelopeCapacity is the v2 form of pagination.CapacityBreakdown
+type EnvelopeCapacity struct {
+	Table                  float64            `json:"table"`
+	GlobalSecondaryIndexes map[string]float64 `json:"global_secondary_indexes,omitempty"`
+	LocalSecondaryIndexes  map[string]float64 `json:"local_secondary_indexes,omitempty"`
+}
+
+// EnvelopeStats is the v2 form of pagination.Stats
+type EnvelopeStats struct {
+	RoundTrips    int64   `json:"round_trips"`
+	ItemsScanned  int64   `json:"items_scanned"`
+	ItemsReturned int64   `json:"items_returned"`
+	ConsumedRCU   float64 `json:"consumed_rcu"`
+	ElapsedMs     int64   `json:"elapsed_ms"`
+}
+
+// EnvelopeHint is the v2 form of pagination.Hint
+type EnvelopeHint struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// EnvelopeLinks are URLs of the current and neighbouring pages, keeping all other parameters
+type EnvelopeLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// EnvelopeWarning is the v2 form of Warning
+type EnvelopeWarning struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Key     map[string]string `json:"key,omitempty"`
+}
+
+// newEnvelope wraps a page for the v2 routes, building links from the request URL
+func newEnvelope(c echo.Context, res Response, params Params) Envelope {
+	data := res.Data
+	if data == nil {
+		data = []Entry{}
+	}
+
+	env := Envelope{
+		Data:  data,
+		Meta:  EnvelopeMeta{Page: res.Page, PageSize: params.PageSize, Count: res.Size, HasMore: res.HasMore, TotalItems: res.TotalItems, TotalPages: res.TotalPages},
+		Links: &EnvelopeLinks{},
+	}
+
+	if params.CursorMode {
+		env.Meta.NextCursor = res.NextCursor
+		env.Links.Self = c.Request().URL.RequestURI()
+		if res.NextCursor != "" {
+			env.Links.Next = linkWith(c, "cursor", res.NextCursor)
+		}
+	} else {
+		env.Links.Self = pageLink(c, res.Page)
+		if res.HasMore {
+			env.Links.Next = pageLink(c, res.Page+1)
+		}
+		if res.Page > 1 {
+			env.Links.Prev = pageLink(c, res.Page-1)
+		}
+	}
+
+	if res.Meta != nil {
+		env.Meta.ItemCount = res.Meta.Count
+		env.Meta.ConsumedCapacity = res.Meta.ConsumedCapacity
+		if b := res.Meta.CapacityBreakdown; b != nil {
+			env.Meta.CapacityBreakdown = &EnvelopeCapacity{Table: b.Table, GlobalSecondaryIndexes: b.GlobalSecondaryIndexes, LocalSecondaryIndexes: b.LocalSecondaryIndexes}
+		}
+		if s := res.Meta.Stats; s != nil {
+			env.Meta.Stats = &EnvelopeStats{RoundTrips: s.RoundTrips, ItemsScanned: s.ItemsScanned, ItemsReturned: s.ItemsReturned, ConsumedRCU: s.ConsumedRCU, ElapsedMs: s.ElapsedMs}
+		}
+		for _, hint := range res.Meta.Hints {
+			env.Meta.Hints = append(env.Meta.Hints, EnvelopeHint{Code: hint.Code, Message: hint.Message})
+		}
+		for _, w := range res.Meta.Warnings {
+			env.Warnings = append(env.Warnings, EnvelopeWarning{Code: w.Code, Message: w.Message, Key: w.Key})
+		}
+	}
+	return env
+}
+
+// pageLink returns the request URL with the page parameter replaced
+func pageLink(c echo.Context, page int64) string {
+	return linkWith(c, "page", strconv.FormatInt(page, 10))
+}
+
+// linkWith returns the request URL with one parameter replaced
+func linkWith(c echo.Context, name, value string) string {
+	u := *c.Request().URL
+	query := u.Query()
+	query.Set(name, value)
+	u.RawQuery = query.Encode()
+	return u.RequestURI()
+}
+
+// handlePaginationV2 serves /v2/paginate, the same query as /paginate wrapped in an Envelope
+func (h *Handler) handlePaginationV2(c echo.Context) error {
+	client, keyCond, params, wait, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	if reqErr := h.preflight(c, client, params); reqErr != nil {
+		c.Logger().Warn(reqErr)
+		return reqErr.respond(c)
+	}
+
+	started := time.Now()
+	res, reqErr := h.fetchPageWaiting(c.Request().Context(), client, keyCond, params, wait)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	h.shadow(client, keyCond, params, wait, res)
+	h.mirror(keyCond, params, wait, res, time.Since(started))
+	return h.respondPage(c, newEnvelope(c, res, params))
+}
+
+// handleCollectionV2 serves /v2/collections/:name, wrapping the merged page in an Envelope
+func (h *Handler) handleCollectionV2(c echo.Context) error {
+	col, client, params, reqErr := h.collectionRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	res, reqErr := h.fetchUnionPage(c.Request().Context(), client, col, params)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	return c.JSON(http.StatusOK, newEnvelope(c, res, params))
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationV2(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedBody string
+	}{
+		{
+			name:  "first page",
+			query: "key_condition=test&pagesize=2",
+			expectedBody: `{"data":[{"key_cond":"test","sort_key":"item1"},{"key_cond":"test","sort_key":"item2"}],` +
+				`"meta":{"page":1,"page_size":2,"count":2,"has_more":true},` +
+				`"links":{"self":"/v2/paginate?key_condition=test&page=1&pagesize=2","next":"/v2/paginate?key_condition=test&page=2&pagesize=2"}}`,
+		},
+		{
+			name:  "empty page",
+			query: "key_condition=missing&page=2&pagesize=2",
+			expectedBody: `{"data":[],"meta":{"page":2,"page_size":2,"count":0,"has_more":false},` +
+				`"links":{"self":"/v2/paginate?key_condition=missing&page=2&pagesize=2","prev":"/v2/paginate?key_condition=missing&page=1&pagesize=2"}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/v2/paginate?"+test.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := &Handler{client: client}
+			require.NoError(t, handler.handlePaginationV2(c))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, test.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestNewEnvelopeWarnings(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/v2/paginate?key_condition=test&page=3", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	env := newEnvelope(c, Response{
+		Page: 3,
+		Meta: &Meta{Warnings: []Warning{{Code: "type_mismatch", Message: "bad", Key: map[string]string{"sort_key": "a"}}}},
+	}, Params{Page: 3, PageSize: 10})
+
+	assert.Equal(t, []EnvelopeWarning{{Code: "type_mismatch", Message: "bad", Key: map[string]string{"sort_key": "a"}}}, env.Warnings)
+	assert.Equal(t, "/v2/paginate?key_condition=test&page=2", env.Links.Prev)
+	assert.Empty(t, env.Links.Next)
+}
+
+func TestNewEnvelopeStats(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/v2/paginate?key_condition=test&debug=true", nil), httptest.NewRecorder())
+
+	env := newEnvelope(c, Response{
+		Page: 1,
+		Meta: &Meta{ConsumedCapacity: 1.5, Stats: &pagination.Stats{RoundTrips: 3, ItemsScanned: 9, ItemsReturned: 2, ConsumedRCU: 1.5, ElapsedMs: 12}},
+	}, Params{Page: 1, PageSize: 2})
+
+	assert.Equal(t, &EnvelopeStats{RoundTrips: 3, ItemsScanned: 9, ItemsReturned: 2, ConsumedRCU: 1.5, ElapsedMs: 12}, env.Meta.Stats)
+}
+
+func TestNewEnvelopeHints(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/v2/paginate?key_condition=test&explain_empty=true", nil), httptest.NewRecorder())
+
+	env := newEnvelope(c, Response{
+		Page: 1,
+		Meta: &Meta{Hints: []pagination.Hint{{Code: "filtered_out", Message: "The partition has items, but none match the search"}}},
+	}, Params{Page: 1, PageSize: 2})
+
+	assert.Equal(t, []EnvelopeHint{{Code: "filtered_out", Message: "The partition has items, but none match the search"}}, env.Meta.Hints)
+}
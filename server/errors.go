@@ -0,0 +1,141 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+)
+
+// Error codes of error responses, stable for clients to branch on
+const (
+	codeValidation         = "validation_error"
+	codeUnauthorized       = "unauthorized"
+	codeForbidden          = "forbidden"
+	codeNotFound           = "not_found"
+	codeMethodNotAllowed   = "method_not_allowed"
+	codeConflict           = "conflict"
+	codePreconditionFailed = "precondition_failed"
+	codePayloadTooLarge    = "payload_too_large"
+	codeUnprocessable      = "unprocessable"
+	codeRateLimited        = "rate_limited"
+	codeInternal           = "internal_error"
+	codeUnavailable        = "unavailable"
+	codeThrottled          = "throttled"
+	codeTimeout            = "timeout"
+)
+
+// statusCodes are the error codes of statuses whose failures have no more specific code
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            codeValidation,
+	http.StatusUnauthorized:          codeUnauthorized,
+	http.StatusForbidden:             codeForbidden,
+	http.StatusNotFound:              codeNotFound,
+	http.StatusMethodNotAllowed:      codeMethodNotAllowed,
+	http.StatusConflict:              codeConflict,
+	http.StatusPreconditionFailed:    codePreconditionFailed,
+	http.StatusRequestEntityTooLarge: codePayloadTooLarge,
+	http.StatusUnprocessableEntity:   codeUnprocessable,
+	http.StatusTooManyRequests:       codeRateLimited,
+	http.StatusInternalServerError:   codeInternal,
+	http.StatusServiceUnavailable:    codeUnavailable,
+	http.StatusGatewayTimeout:        codeTimeout,
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes why a request failed. Message is meant for people and may change; Code
+// doesn't. RequestID is the X-Request-Id of the request, to find it in the logs.
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// errorCode returns the code of a failure with the given status
+func errorCode(status int) string {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return codeInternal
+	}
+	return codeValidation
+}
+
+// errorDetail describes a failure of the request of c, with the code of its status when code is empty
+func errorDetail(c echo.Context, status int, code, message string) ErrorDetail {
+	if code == "" {
+		code = errorCode(status)
+	}
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	return ErrorDetail{Code: code, Message: message, RequestID: requestID}
+}
+
+// respondError writes an error response with the code of its status
+func respondError(c echo.Context, status int, message string) error {
+	return c.JSON(status, ErrorResponse{Error: errorDetail(c, status, "", message)})
+}
+
+// respond writes the error response of a failed request
+func (e *requestError) respond(c echo.Context) error {
+	return c.JSON(e.status, ErrorResponse{Error: errorDetail(c, e.status, e.code, e.message)})
+}
+
+// HTTPErrorHandler writes the errors returned to Echo, such as unknown routes, as error responses
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	status, message := http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		status = httpErr.Code
+		message = fmt.Sprint(httpErr.Message)
+	}
+	if status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(status)
+	} else {
+		err = respondError(c, status, message)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// dynamoError classifies a failed DynamoDB call: calls cut short by a timeout get a 504, calls
+// still throttled after their retries a 503, requests DynamoDB rejects as invalid a 400, missing tables
+// or indexes a 404, and anything else a 500 with the given message
+func dynamoError(message string, err error) *requestError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &requestError{status: http.StatusGatewayTimeout, message: "DynamoDB request timed out", err: err}
+	}
+	if errors.Is(err, errBatchBudget) {
+		return &requestError{status: http.StatusUnprocessableEntity, message: "The batch used up its round trip budget", err: err}
+	}
+	if isThrottled(err) {
+		return &requestError{status: http.StatusServiceUnavailable, code: codeThrottled, message: "DynamoDB is throttling requests", err: err}
+	}
+	var notFound *types.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return &requestError{status: http.StatusNotFound, message: "Table or index not found", err: err}
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationException" {
+		return &requestError{status: http.StatusBadRequest, message: "DynamoDB rejected the request as invalid", err: err}
+	}
+	return &requestError{status: http.StatusInternalServerError, message: message, err: err}
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// errorBody decodes the error response of a failed request
+func errorBody(t *testing.T, rec *httptest.ResponseRecorder) ErrorDetail {
+	t.Helper()
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	var response ErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response), rec.Body.String())
+	return response.Error
+}
+
+func TestDynamoError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, codeTimeout},
+		{&types.ProvisionedThroughputExceededException{}, http.StatusServiceUnavailable, codeThrottled},
+		{&smithy.GenericAPIError{Code: "ThrottlingException"}, http.StatusServiceUnavailable, codeThrottled},
+		{&types.ResourceNotFoundException{}, http.StatusNotFound, codeNotFound},
+		{&smithy.GenericAPIError{Code: "ValidationException"}, http.StatusBadRequest, codeValidation},
+		{&types.InternalServerError{}, http.StatusInternalServerError, codeInternal},
+		{errors.New("connection reset"), http.StatusInternalServerError, codeInternal},
+	}
+	for _, test := range tests {
+		e := echo.New()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+
+		reqErr := dynamoError("Error in DynamoDB query", test.err)
+		require.NoError(t, reqErr.respond(e.NewContext(req, rec)))
+
+		assert.Equal(t, test.status, rec.Code, test.err)
+		detail := errorBody(t, rec)
+		assert.Equal(t, test.code, detail.Code, test.err)
+		assert.Equal(t, "req-1", detail.RequestID)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), errors.New("connection reset"))
+	handler := &Handler{client: mockDynamoDB}
+
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	e.GET("/paginate", handler.handlePagination)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve("/paginate?key_condition=test&orderby=price")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	detail := errorBody(t, rec)
+	assert.Equal(t, codeValidation, detail.Code)
+	assert.Equal(t, "Invalid orderby parameter", detail.Message)
+	// Responses carry the request ID the logs know the request by
+	assert.NotEmpty(t, detail.RequestID)
+	assert.Equal(t, rec.Header().Get(echo.HeaderXRequestID), detail.RequestID)
+
+	rec = serve("/paginate?key_condition=test")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, ErrorDetail{Code: codeInternal, Message: "Error in DynamoDB query", RequestID: rec.Header().Get(echo.HeaderXRequestID)}, errorBody(t, rec))
+
+	// Routing errors from Echo get the same envelope
+	rec = serve("/missing")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, codeNotFound, errorBody(t, rec).Code)
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, codeRateLimited, errorCode(http.StatusTooManyRequests))
+	assert.Equal(t, codeValidation, errorCode(http.StatusRequestedRangeNotSatisfiable))
+	assert.Equal(t, codeInternal, errorCode(http.StatusBadGateway))
+}
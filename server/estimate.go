@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// tableStatsTTL is how long DescribeTable statistics are reused; DynamoDB refreshes them about every six hours
+	tableStatsTTL = 5 * time.Minute
+	// defaultItemSize is assumed when the table doesn't report any items yet
+	defaultItemSize = 1024
+	// readUnitBytes is the amount of data one eventually consistent half RCU covers
+	readUnitBytes = 4096
+	// partitionCountTTL is how long a maintained partition count is trusted, as imports and other
+	// writers change partitions without updating it
+	partitionCountTTL = time.Hour
+	// maxPartitionCounts bounds the number of partitions whose item count is maintained
+	maxPartitionCounts = 10000
+)
+
+// Estimate predicts the cost of serving a page before the queries run
+type Estimate struct {
+	RoundTrips  int64
+	ItemsRead   int64
+	ConsumedRCU float64
+	// AvgItemSize and TableItems are the DescribeTable statistics the estimate is based on
+	AvgItemSize float64
+	TableItems  int64
+	// PartitionItems is the maintained item count of the partition, when one is known
+	PartitionItems *int64 `json:",omitempty"`
+}
+
+type tableStats struct {
+	items   int64
+	bytes   int64
+	fetched time.Time
+}
+
+type partitionCount struct {
+	items    int64
+	observed time.Time
+}
+
+// Estimator predicts round trips and read capacity from table statistics and flags requests whose
+// estimate exceeds the configured limits. It also maintains item counts of the partitions it has seen
+// counted or walked to the end, kept current by the item writes.
+type Estimator struct {
+	// MaxRCU rejects requests estimated to consume more read capacity; zero disables it
+	MaxRCU float64
+	// WarnRCU logs requests estimated to consume more read capacity; zero disables it
+	WarnRCU float64
+
+	mu     sync.Mutex
+	stats  *tableStats
+	counts map[string]*partitionCount
+	now    func() time.Time
+}
+
+// NewEstimator creates an estimator with the given limits
+func NewEstimator(maxRCU, warnRCU float64) *Estimator {
+	return &Estimator{MaxRCU: maxRCU, WarnRCU: warnRCU, counts: map[string]*partitionCount{}, now: time.Now}
+}
+
+// loadEstimator reads PREFLIGHT_MAX_RCU and PREFLIGHT_WARN_RCU
+func loadEstimator() (*Estimator, error) {
+	var limits [2]float64
+	for i, name := range []string{"PREFLIGHT_MAX_RCU", "PREFLIGHT_WARN_RCU"} {
+		if v := os.Getenv(name); v != "" {
+			rcu, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			limits[i] = rcu
+		}
+	}
+	return NewEstimator(limits[0], limits[1]), nil
+}
+
+// tableStats returns the cached DescribeTable statistics, refreshing them when they are stale
+func (e *Estimator) tableStats(ctx context.Context, client DynamoClient) (tableStats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stats != nil && e.now().Sub(e.stats.fetched) < tableStatsTTL {
+		return *e.stats, nil
+	}
+
+	out, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &tableName})
+	if err != nil {
+		return tableStats{}, err
+	}
+
+	stats := &tableStats{fetched: e.now()}
+	if out.Table != nil {
+		if out.Table.ItemCount != nil {
+			stats.items = *out.Table.ItemCount
+		}
+		if out.Table.TableSizeBytes != nil {
+			stats.bytes = *out.Table.TableSizeBytes
+		}
+	}
+	e.stats = stats
+	return *stats, nil
+}
+
+// observeCount records the item count of a partition
+func (e *Estimator) observeCount(keyCond string, items int64) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	if _, ok := e.counts[keyCond]; !ok && len(e.counts) >= maxPartitionCounts {
+		for k, count := range e.counts {
+			if now.Sub(count.observed) >= partitionCountTTL {
+				delete(e.counts, k)
+			}
+		}
+		if len(e.counts) >= maxPartitionCounts {
+			return
+		}
+	}
+	e.counts[keyCond] = &partitionCount{items: items, observed: now}
+}
+
+// adjustCount updates the maintained count of a partition after a write created or deleted an item
+func (e *Estimator) adjustCount(keyCond string, delta int64) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if count, ok := e.counts[keyCond]; ok && count.items+delta >= 0 {
+		count.items += delta
+	}
+}
+
+// partitionItems returns the maintained count of a partition, reporting false when there is none
+func (e *Estimator) partitionItems(keyCond string) (int64, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	count, ok := e.counts[keyCond]
+	if !ok || e.now().Sub(count.observed) >= partitionCountTTL {
+		return 0, false
+	}
+	return count.items, true
+}
+
+// Estimate predicts the cost of walking to the requested page. Every page is one query of PageSize
+// items, and the walk can't read more items than the partition holds: its maintained count when there
+// is one, the whole table otherwise.
+func (e *Estimator) Estimate(ctx context.Context, client DynamoClient, params Params) (Estimate, error) {
+	stats, err := e.tableStats(ctx, client)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	est := Estimate{TableItems: stats.items, AvgItemSize: defaultItemSize, RoundTrips: params.Page}
+	if stats.items > 0 {
+		est.AvgItemSize = float64(stats.bytes) / float64(stats.items)
+	}
+
+	// Partition counts are kept for the table's partitions, not for those of its indexes
+	var items int64
+	var bounded bool
+	if params.IndexName == "" {
+		items, bounded = e.partitionItems(params.KeyCondition)
+	}
+	if bounded {
+		est.PartitionItems = &items
+	} else {
+		items, bounded = stats.items, stats.items > 0
+	}
+	if bounded {
+		if maxTrips := (items + params.PageSize - 1) / params.PageSize; est.RoundTrips > maxTrips {
+			est.RoundTrips = maxTrips
+		}
+	}
+	if est.RoundTrips < 1 {
+		est.RoundTrips = 1
+	}
+
+	est.ItemsRead = est.RoundTrips * params.PageSize
+	if bounded && est.ItemsRead > items {
+		est.ItemsRead = items
+	}
+
+	// A query is charged half an RCU per started 4 KB read, with at least one unit per round trip, and
+	// a whole RCU when the read is consistent
+	perTrip := math.Max(1, math.Ceil(float64(params.PageSize)*est.AvgItemSize/readUnitBytes))
+	unit := 0.5
+	if params.ConsistentRead {
+		unit = 1
+	}
+	est.ConsumedRCU = float64(est.RoundTrips) * perTrip * unit
+	return est, nil
+}
+
+// preflight estimates a pagination request and rejects it when it exceeds MaxRCU
+func (h *Handler) preflight(c echo.Context, client DynamoClient, params Params) *requestError {
+	if h.estimator == nil || (h.estimator.MaxRCU <= 0 && h.estimator.WarnRCU <= 0) || params.Select == "count" || params.CursorMode {
+		return nil
+	}
+
+	est, err := h.estimator.Estimate(c.Request().Context(), client, params)
+	if err != nil {
+		// Estimates are advisory, so the request still runs without one
+		c.Logger().Warnf("pre-flight estimate failed: %v", err)
+		return nil
+	}
+
+	if h.estimator.MaxRCU > 0 && est.ConsumedRCU > h.estimator.MaxRCU {
+		err := fmt.Errorf("estimated %.1f RCU over %d round trips", est.ConsumedRCU, est.RoundTrips)
+		return &requestError{status: http.StatusUnprocessableEntity, message: "Query is estimated to exceed the read capacity limit", err: err}
+	}
+	if h.estimator.WarnRCU > 0 && est.ConsumedRCU > h.estimator.WarnRCU {
+		c.Logger().Warnf("expensive query: estimated %.1f RCU over %d round trips for page %d", est.ConsumedRCU, est.RoundTrips, params.Page)
+	}
+	return nil
+}
+
+// handleEstimate serves /paginate/estimate, the pre-flight estimate of a /paginate request
+func (h *Handler) handleEstimate(c echo.Context) error {
+	if h.estimator == nil {
+		return respondError(c, http.StatusNotFound, "Estimates are disabled")
+	}
+
+	client, _, params, _, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	est, err := h.estimator.Estimate(c.Request().Context(), client, params)
+	if err != nil {
+		c.Logger().Error(err)
+		return respondError(c, http.StatusInternalServerError, "Error describing DynamoDB table")
+	}
+	return c.JSON(http.StatusOK, est)
+}
+
+// observePartition maintains the partition count from a served page that saw the whole partition: a
+// count, or a page walk that reached the end
+func (h *Handler) observePartition(params Params, res Response) {
+	if h.estimator == nil || params.Search != "" || params.SortRange != nil || params.CursorMode || params.IndexName != "" || (res.Meta != nil && len(res.Meta.Warnings) > 0) {
+		return
+	}
+	switch {
+	case params.Select == "count":
+		if res.Meta != nil && res.Meta.Count != nil {
+			h.estimator.observeCount(params.KeyCondition, *res.Meta.Count)
+		}
+	case !res.HasMore && (res.Size > 0 || params.Page == 1):
+		h.estimator.observeCount(params.KeyCondition, (params.Page-1)*params.PageSize+res.Size)
+	}
+}
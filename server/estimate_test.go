@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func describeTableMock(items, bytes int64) *MockDynamoDB {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DescribeTable", mock.Anything, mock.Anything).Return(&dynamodb.DescribeTableOutput{
+		Table: &types.TableDescription{ItemCount: aws.Int64(items), TableSizeBytes: aws.Int64(bytes)},
+	}, nil)
+	return mockDynamoDB
+}
+
+func TestEstimatorEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    int64
+		bytes    int64
+		params   Params
+		expected Estimate
+	}{
+		{
+			name:     "first page",
+			items:    1000,
+			bytes:    1000 * 2048,
+			params:   Params{Page: 1, PageSize: 10},
+			expected: Estimate{RoundTrips: 1, ItemsRead: 10, ConsumedRCU: 2.5, AvgItemSize: 2048, TableItems: 1000},
+		},
+		{
+			name:     "deep page",
+			items:    1000,
+			bytes:    1000 * 2048,
+			params:   Params{Page: 50, PageSize: 10},
+			expected: Estimate{RoundTrips: 50, ItemsRead: 500, ConsumedRCU: 125, AvgItemSize: 2048, TableItems: 1000},
+		},
+		{
+			name:     "consistent read",
+			items:    1000,
+			bytes:    1000 * 2048,
+			params:   Params{Page: 1, PageSize: 10, ConsistentRead: true},
+			expected: Estimate{RoundTrips: 1, ItemsRead: 10, ConsumedRCU: 5, AvgItemSize: 2048, TableItems: 1000},
+		},
+		{
+			name:     "page beyond the table",
+			items:    25,
+			bytes:    25 * 100,
+			params:   Params{Page: 1000, PageSize: 10},
+			expected: Estimate{RoundTrips: 3, ItemsRead: 25, ConsumedRCU: 1.5, AvgItemSize: 100, TableItems: 25},
+		},
+		{
+			name:     "empty table",
+			params:   Params{Page: 2, PageSize: 8},
+			expected: Estimate{RoundTrips: 2, ItemsRead: 16, ConsumedRCU: 2, AvgItemSize: defaultItemSize},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			est, err := NewEstimator(0, 0).Estimate(context.Background(), describeTableMock(test.items, test.bytes), test.params)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, est)
+		})
+	}
+}
+
+func TestEstimatorCachesTableStats(t *testing.T) {
+	mockDynamoDB := describeTableMock(10, 1000)
+	now := time.Now()
+	estimator := NewEstimator(0, 0)
+	estimator.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := estimator.Estimate(context.Background(), mockDynamoDB, Params{Page: 1, PageSize: 10})
+		require.NoError(t, err)
+	}
+	mockDynamoDB.AssertNumberOfCalls(t, "DescribeTable", 1)
+
+	now = now.Add(tableStatsTTL)
+	_, err := estimator.Estimate(context.Background(), mockDynamoDB, Params{Page: 1, PageSize: 10})
+	require.NoError(t, err)
+	mockDynamoDB.AssertNumberOfCalls(t, "DescribeTable", 2)
+}
+
+func TestHandlePaginationPreflightRejects(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&page=500&pagesize=100", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: describeTableMock(1000000, 1000000*4096), estimator: NewEstimator(100, 0)}
+	require.NoError(t, handler.handlePagination(c))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+}
+
+func TestHandleEstimate(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate/estimate?key_condition=test&page=3&pagesize=10", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: describeTableMock(100, 100*512), estimator: NewEstimator(1, 0)}
+	require.NoError(t, handler.handleEstimate(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var est Estimate
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &est))
+	assert.Equal(t, Estimate{RoundTrips: 3, ItemsRead: 30, ConsumedRCU: 3, AvgItemSize: 512, TableItems: 100}, est)
+}
+
+func TestEstimatorPartitionCounts(t *testing.T) {
+	now := time.Now()
+	estimator := NewEstimator(0, 0)
+	estimator.now = func() time.Time { return now }
+	client := describeTableMock(1000000, 1000000*512)
+	params := Params{KeyCondition: "test", Page: 10, PageSize: 10}
+
+	estimator.observeCount("test", 25)
+	est, err := estimator.Estimate(context.Background(), client, params)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), est.RoundTrips)
+	assert.Equal(t, int64(25), est.ItemsRead)
+	assert.Equal(t, aws.Int64(25), est.PartitionItems)
+
+	estimator.adjustCount("test", 1)
+	estimator.adjustCount("other", 1)
+	est, err = estimator.Estimate(context.Background(), client, params)
+	require.NoError(t, err)
+	assert.Equal(t, aws.Int64(26), est.PartitionItems)
+	_, known := estimator.partitionItems("other")
+	assert.False(t, known)
+
+	// Stale counts fall back to the table statistics
+	now = now.Add(partitionCountTTL)
+	est, err = estimator.Estimate(context.Background(), client, params)
+	require.NoError(t, err)
+	assert.Nil(t, est.PartitionItems)
+	assert.Equal(t, int64(10), est.RoundTrips)
+}
+
+func TestHandlePaginationMaintainsPartitionCount(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client, estimator: NewEstimator(0, 0)}
+
+	serve := func(query string) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// A page before the end doesn't tell the size of the partition
+	serve("key_condition=test&pagesize=2")
+	_, known := handler.estimator.partitionItems("test")
+	assert.False(t, known)
+
+	serve("key_condition=test&page=2&pagesize=2")
+	items, known := handler.estimator.partitionItems("test")
+	assert.True(t, known)
+	assert.Equal(t, int64(3), items)
+
+	serve("key_condition=other&select=count")
+	items, known = handler.estimator.partitionItems("other")
+	assert.True(t, known)
+	assert.Equal(t, int64(1), items)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// maxExchangeRoundTrips bounds the walk of an exchange when neither the caller's round trip budget nor
+// PAGE_NUMBER_MAX does
+const maxExchangeRoundTrips = 1000
+
+// CursorExchange relates a page of /paginate to the cursor serving the same items
+type CursorExchange struct {
+	// Cursor is the token to pass as cursor, "" for the first page
+	Cursor   string
+	Page     int64
+	PageSize int64
+	// Offset is the number of items before the cursor
+	Offset int64
+	// Aligned is false when the cursor falls inside Page rather than at its start
+	Aligned bool
+}
+
+// handleCursorExchange serves /paginate/exchange. Given page and pagesize it returns the cursor that
+// starts the same page; given a cursor it returns the page it starts at that page size.
+func (h *Handler) handleCursorExchange(c echo.Context) error {
+	client, keyCond, params, _, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if params.Select == "count" {
+		return respondError(c, http.StatusBadRequest, "Invalid select parameter")
+	}
+	if params.Search != "" {
+		// Searched pages are sliced from the filtered items, so no single query serves them
+		return respondError(c, http.StatusBadRequest, "Pages with search have no equivalent cursor")
+	}
+	if params.Filter != nil {
+		// Round trips of filtered pages match fewer items than a page, so pages don't start where they stop
+		return respondError(c, http.StatusBadRequest, "Pages with filter have no equivalent cursor")
+	}
+	var exchange CursorExchange
+	if params.CursorMode {
+		exchange, reqErr = h.cursorToPage(c.Request().Context(), client, keyCond, params)
+	} else {
+		if reqErr := h.preflight(c, client, params); reqErr != nil {
+			c.Logger().Warn(reqErr)
+			return reqErr.respond(c)
+		}
+		exchange, reqErr = h.pageToCursor(c.Request().Context(), client, keyCond, params)
+	}
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	if exchange.Cursor, reqErr = h.pinCursor(c.Request().Context(), exchange.Cursor); reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	return c.JSON(http.StatusOK, exchange)
+}
+
+// keysQuery builds the keys-only query of the round trip of a page starting after start, the way p
+// builds the queries of /paginate
+func keysQuery(p *pagination.Paginator[Entry], params Params, start map[string]types.AttributeValue) (*dynamodb.QueryInput, *requestError) {
+	params.Select = "keys_only"
+	input, err := p.RoundTripQuery(params, start)
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, message: "Invalid query parameters", err: err}
+	}
+	return input, nil
+}
+
+// exchangeRoundTrips is the number of round trips the walk of an exchange may take: the caller's round
+// trip budget, or else one per page up to PAGE_NUMBER_MAX, or else maxExchangeRoundTrips
+func (h *Handler) exchangeRoundTrips(ctx context.Context) int64 {
+	if max := requestContextFrom(ctx).budget().MaxRoundTrips; max > 0 {
+		return max
+	}
+	if h.limits != nil && h.limits.MaxPage > 0 {
+		return h.limits.MaxPage
+	}
+	return maxExchangeRoundTrips
+}
+
+// exchangeTooDeep is the error of an exchange whose walk would take more round trips than it may
+func exchangeTooDeep(roundTrips, max int64) *requestError {
+	return pageError(&pagination.BudgetError{RoundTrips: roundTrips, Max: max})
+}
+
+// pageToCursor encodes where the pages before params.Page stopped: the checkpoint of the page in the
+// page cache, or else the end of a walk over them
+func (h *Handler) pageToCursor(ctx context.Context, client DynamoClient, keyCond string, params Params) (CursorExchange, *requestError) {
+	exchange := CursorExchange{Page: params.Page, PageSize: params.PageSize, Aligned: true}
+	params.KeyCondition = keyCond
+	p := h.paginator(client, params)
+
+	checkpoint, ok := p.PageCheckpoint(ctx, params)
+	if !ok {
+		var reqErr *requestError
+		if checkpoint, reqErr = h.walkToPage(ctx, client, p, params); reqErr != nil {
+			return CursorExchange{}, reqErr
+		}
+	}
+	exchange.Offset = checkpoint.Offset
+
+	cursor, err := pagination.EncodeCursor(checkpoint.Start)
+	if err != nil {
+		return CursorExchange{}, &requestError{status: http.StatusInternalServerError, message: "Error encoding cursor", err: err}
+	}
+	exchange.Cursor = cursor
+	return exchange, nil
+}
+
+// walkToPage walks the pages before params.Page to the checkpoint of the page: where they stopped and
+// the items they read
+func (h *Handler) walkToPage(ctx context.Context, client DynamoClient, p *pagination.Paginator[Entry], params Params) (pagination.Checkpoint, *requestError) {
+	var checkpoint pagination.Checkpoint
+	if max := h.exchangeRoundTrips(ctx); params.Page-1 > max {
+		return checkpoint, exchangeTooDeep(params.Page-1, max)
+	}
+	walk, cancel := walkContext(ctx)
+	defer cancel()
+
+	for page := int64(1); page < params.Page; page++ {
+		input, reqErr := keysQuery(p, params, checkpoint.Start)
+		if reqErr != nil {
+			return checkpoint, reqErr
+		}
+		result, err := client.Query(pagination.WithPageDepth(walk, page), input)
+		if err != nil {
+			return checkpoint, walkError(ctx, walk, dynamoError("Error in DynamoDB query", err))
+		}
+		checkpoint.Offset += int64(len(result.Items))
+		if result.LastEvaluatedKey == nil {
+			return checkpoint, &requestError{status: http.StatusNotFound, message: "Page is past the end of the results"}
+		}
+		checkpoint.Start = result.LastEvaluatedKey
+	}
+	return checkpoint, nil
+}
+
+// cursorToPage counts the items up to the cursor to find the page it starts, within the round trips
+// the exchange may take
+func (h *Handler) cursorToPage(ctx context.Context, client DynamoClient, keyCond string, params Params) (CursorExchange, *requestError) {
+	token, _ := pagination.EncodeCursor(params.Cursor)
+	exchange := CursorExchange{Cursor: token, Page: 1, PageSize: params.PageSize, Aligned: true}
+	if params.Cursor == nil {
+		return exchange, nil
+	}
+	params.KeyCondition = keyCond
+	p := h.paginator(client, params)
+	max := h.exchangeRoundTrips(ctx)
+	walk, cancel := walkContext(ctx)
+	defer cancel()
+
+	var start map[string]types.AttributeValue
+	for depth := int64(1); ; depth++ {
+		if depth > max {
+			return CursorExchange{}, exchangeTooDeep(depth, max)
+		}
+		input, reqErr := keysQuery(p, params, start)
+		if reqErr != nil {
+			return CursorExchange{}, reqErr
+		}
+		result, err := client.Query(pagination.WithPageDepth(walk, depth), input)
+		if err != nil {
+			return CursorExchange{}, walkError(ctx, walk, dynamoError("Error in DynamoDB query", err))
+		}
+		for _, item := range result.Items {
+			exchange.Offset++
+			if sameKey(params.Cursor, item) {
+				exchange.Page = exchange.Offset/params.PageSize + 1
+				exchange.Aligned = exchange.Offset%params.PageSize == 0
+				return exchange, nil
+			}
+		}
+		if result.LastEvaluatedKey == nil {
+			return CursorExchange{}, &requestError{status: http.StatusNotFound, message: "Cursor item not found"}
+		}
+		start = result.LastEvaluatedKey
+	}
+}
+
+// sameKey reports whether an item has every attribute of a key
+func sameKey(key, item map[string]types.AttributeValue) bool {
+	for name, value := range key {
+		if attributeString(item[name]) != attributeString(value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleCursorExchange(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	exchange := func(query string) (*httptest.ResponseRecorder, CursorExchange) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate/exchange?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handleCursorExchange(e.NewContext(req, rec)))
+
+		var res CursorExchange
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec, res
+	}
+
+	rec, first := exchange("key_condition=test&page=1&pagesize=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, CursorExchange{Page: 1, PageSize: 2, Aligned: true}, first)
+
+	rec, second := exchange("key_condition=test&page=2&pagesize=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, second.Cursor)
+	assert.Equal(t, int64(2), second.Offset)
+
+	// The cursor serves the same items as the page
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=2&cursor="+second.Cursor, nil)
+	pageRec := httptest.NewRecorder()
+	require.NoError(t, handler.handlePagination(e.NewContext(req, pageRec)))
+	var page Response
+	require.NoError(t, json.Unmarshal(pageRec.Body.Bytes(), &page))
+	require.Len(t, page.Data, 1)
+	assert.Equal(t, "item3", page.Data[0].SortKey)
+
+	rec, back := exchange("key_condition=test&pagesize=2&cursor=" + second.Cursor)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, second, back)
+
+	rec, unaligned := exchange("key_condition=test&pagesize=3&cursor=" + second.Cursor)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int64(1), unaligned.Page)
+	assert.False(t, unaligned.Aligned)
+
+	rec, _ = exchange("key_condition=test&page=5&pagesize=2")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec, _ = exchange("key_condition=test&page=2&pagesize=2&search=item")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	missing, err := pagination.EncodeCursor(map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "missing"},
+	})
+	require.NoError(t, err)
+	rec, _ = exchange("key_condition=test&pagesize=2&cursor=" + missing)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestCursorExchangeBound(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	limits := *defaultParamLimits
+	limits.MaxPage = 2
+	handler := &Handler{client: client, limits: &limits}
+
+	deep, err := pagination.EncodeCursor(map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item0008"},
+	})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/paginate/exchange?key_condition=test&pagesize=2&cursor="+deep, nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handleCursorExchange(echo.New().NewContext(req, rec)))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+
+	// The caller's round trip budget bounds both directions
+	limits.MaxPage = 0
+	exchange := func(query string) int {
+		req := httptest.NewRequest(http.MethodGet, "/paginate/exchange?"+query, nil)
+		rc := &RequestContext{Budget: Budget{MaxRoundTrips: 1}}
+		req = req.WithContext(withRequestContext(req.Context(), rc))
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handleCursorExchange(echo.New().NewContext(req, rec)))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, exchange("key_condition=test&page=2&pagesize=2"))
+	assert.Equal(t, http.StatusUnprocessableEntity, exchange("key_condition=test&page=3&pagesize=2"))
+	assert.Equal(t, http.StatusUnprocessableEntity, exchange("key_condition=test&pagesize=2&cursor="+deep))
+}
+
+// lastQuery keeps the last query made through it
+type lastQuery struct {
+	queryCounter
+	input *dynamodb.QueryInput
+}
+
+func (c *lastQuery) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.input = params
+	return c.queryCounter.Query(ctx, params, optFns...)
+}
+
+func TestCursorExchangeQueries(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	client := &lastQuery{queryCounter: queryCounter{DynamoClient: fixture}}
+	handler := &Handler{client: client, pages: pagination.NewLRUPageCache(100, defaultPageCacheTTL, pagination.CacheAll)}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	e.GET("/paginate/exchange", handler.handleCursorExchange)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve("/paginate/exchange?key_condition=test&page=3&pagesize=2&consistent=true")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var walked CursorExchange
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &walked))
+	assert.Equal(t, int64(4), walked.Offset)
+	assert.True(t, aws.ToBool(client.input.ConsistentRead), "the walk reads like the page")
+
+	// Pages walked by /paginate are exchanged from their checkpoint in the page cache
+	require.Equal(t, http.StatusOK, serve("/paginate?key_condition=test&page=3&pagesize=2").Code)
+	queries := client.queries
+	rec = serve("/paginate/exchange?key_condition=test&page=3&pagesize=2")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var cached CursorExchange
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &cached))
+	assert.Equal(t, walked, cached)
+	assert.Equal(t, queries, client.queries)
+
+	rec = serve("/paginate/exchange?key_condition=test&page=2&pagesize=2&filter=status:eq:active")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
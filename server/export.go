@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// exportCursorTrailer carries the cursor an export stopped at when it didn't complete
+const exportCursorTrailer = "X-Export-Cursor"
+
+// handleExport streams every item a query selects as JSON lines. The query is paged through with
+// cursors and each page is written and flushed as it arrives, so the export holds one page at a time.
+// It takes the parameters of /paginate; a cursor resumes an export where an earlier one stopped.
+func (h *Handler) handleExport(c echo.Context) error {
+	client, _, params, _, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if params.Select == "count" {
+		return respondError(c, http.StatusBadRequest, "Counts can't be exported")
+	}
+	params.CursorMode = true
+	params.PageSize = streamPageSize
+	params.IncludeCount = false
+	ctx := c.Request().Context()
+
+	var roundTrip pagination.Progress
+	p := h.paginator(client, params)
+	p.OnProgress = func(progress pagination.Progress) { roundTrip = progress }
+
+	page, err := p.GetPage(ctx, params)
+	if err != nil {
+		reqErr := pageError(err)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set("Trailer", streamStatusTrailer+", "+exportCursorTrailer)
+	res.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(res)
+	status, cursor, err := h.exportPages(ctx, p, params, page, &roundTrip, func(page Response) error {
+		if page.Meta != nil {
+			for _, w := range page.Meta.Warnings {
+				c.Logger().Warnf("%s %v: %s", w.Code, w.Key, w.Message)
+			}
+		}
+		for _, entry := range page.Data {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		res.Flush()
+		return nil
+	})
+	if err != nil {
+		c.Logger().Error(err)
+	}
+	res.Header().Set(streamStatusTrailer, status)
+	if status != "complete" && cursor != "" {
+		pinned, reqErr := h.pinCursor(ctx, cursor)
+		if reqErr != nil {
+			c.Logger().Error(reqErr)
+			return nil
+		}
+		res.Header().Set(exportCursorTrailer, pinned)
+	}
+	return nil
+}
+
+// exportPages hands page, the first page of an export, and the pages after it to emit, within the
+// scan budget and read rate of the streams. It returns whether the export is complete, truncated or
+// failed with the error, and the cursor it stopped at.
+func (h *Handler) exportPages(ctx context.Context, p *pagination.Paginator[Entry], params Params, page Response, roundTrip *pagination.Progress, emit func(Response) error) (string, string, error) {
+	var scanned int64
+	cursor := ""
+	for {
+		tenantFrom(ctx).redact(page.Data)
+		if err := emit(page); err != nil {
+			return "error", cursor, err
+		}
+		if !page.HasMore {
+			return "complete", cursor, nil
+		}
+		cursor = page.NextCursor
+		var err error
+		if params.Cursor, err = pagination.DecodeCursor(cursor); err != nil {
+			return "error", cursor, err
+		}
+
+		scanned += roundTrip.ItemsScanned
+		if h.stream.ScanBudget > 0 && scanned >= h.stream.ScanBudget {
+			return "truncated", cursor, nil
+		}
+		rcu := roundTrip.ConsumedRCU
+		if err := h.stream.throttle(ctx, &types.ConsumedCapacity{CapacityUnits: &rcu}); err != nil {
+			return "error", cursor, err
+		}
+
+		if page, err = p.GetPage(ctx, params); err != nil {
+			return "error", cursor, pageError(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleExport(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: &pagedClient{DynamoClient: client, pageSize: 1}}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/export?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handleExport(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := export("key_condition=test&orderby=-sort_key")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, `{"key_cond":"test","sort_key":"item3"}
+{"key_cond":"test","sort_key":"item2"}
+{"key_cond":"test","sort_key":"item1"}
+`, rec.Body.String())
+	assert.Equal(t, "complete", rec.Header().Get(streamStatusTrailer))
+	assert.Empty(t, rec.Header().Get(exportCursorTrailer))
+
+	// A truncated export reports the cursor it can be resumed from
+	handler.stream = StreamLimits{ScanBudget: 2}
+	rec = export("key_condition=test")
+	assert.Equal(t, `{"key_cond":"test","sort_key":"item1"}
+{"key_cond":"test","sort_key":"item2"}
+`, rec.Body.String())
+	assert.Equal(t, "truncated", rec.Header().Get(streamStatusTrailer))
+	cursor := rec.Header().Get(exportCursorTrailer)
+	require.NotEmpty(t, cursor)
+
+	handler.stream = StreamLimits{}
+	rec = export("key_condition=test&cursor=" + url.QueryEscape(cursor))
+	assert.Equal(t, `{"key_cond":"test","sort_key":"item3"}
+`, rec.Body.String())
+	assert.Equal(t, "complete", rec.Header().Get(streamStatusTrailer))
+
+	assert.Equal(t, http.StatusBadRequest, export("key_condition=test&select=count").Code)
+	assert.Equal(t, http.StatusBadRequest, export("").Code)
+}
+
+func TestHandleExportQueryError(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), errors.New("DynamoDB query error"))
+	handler := &Handler{client: mockDynamoDB}
+
+	// A query that fails before the stream starts is answered with an error status
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/export?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handleExport(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get(streamStatusTrailer))
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Condition is a single comparison on an item attribute
+type Condition struct {
+	Attribute string
+	Op        string
+	Value     types.AttributeValue
+}
+
+// operators maps condition operators to whether they take a value
+var operators = map[string]bool{
+	"eq":          true,
+	"ne":          true,
+	"lt":          true,
+	"le":          true,
+	"gt":          true,
+	"ge":          true,
+	"begins_with": true,
+	"contains":    true,
+	"exists":      false,
+	"not_exists":  false,
+}
+
+var comparisons = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"lt": "<",
+	"le": "<=",
+	"gt": ">",
+	"ge": ">=",
+}
+
+// parseConditions reads a comma separated list of attr:op[:value] conditions, e.g. "status:eq:active,version:lt:3"
+func parseConditions(s string) ([]Condition, error) {
+	var conditions []Condition
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		cond, err := parseCondition(part)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+	return conditions, nil
+}
+
+// parseCondition reads a single attr:op[:value] condition
+func parseCondition(part string) (Condition, error) {
+	fields := strings.SplitN(part, ":", 3)
+	if len(fields) < 2 || fields[0] == "" {
+		return Condition{}, fmt.Errorf("invalid condition %q", part)
+	}
+
+	cond := Condition{Attribute: fields[0], Op: fields[1]}
+	needsValue, ok := operators[cond.Op]
+	if !ok {
+		return Condition{}, fmt.Errorf("unknown operator %q", cond.Op)
+	}
+	hasValue := len(fields) == 3
+	if needsValue && !hasValue {
+		return Condition{}, fmt.Errorf("condition %q: operator %s requires a value", part, cond.Op)
+	}
+	if !needsValue && hasValue {
+		return Condition{}, fmt.Errorf("condition %q: operator %s doesn't take a value", part, cond.Op)
+	}
+	if hasValue {
+		cond.Value = parseTypedValue(fields[2])
+	}
+	return cond, nil
+}
+
+// parseTypedValue converts a raw parameter value into an attribute value: true/false become BOOL,
+// numbers become N and anything else a string. Single quotes force a string, e.g. '123'.
+func parseTypedValue(raw string) types.AttributeValue {
+	if len(raw) >= 2 && raw[0] == '\'' && raw[len(raw)-1] == '\'' {
+		return &types.AttributeValueMemberS{Value: raw[1 : len(raw)-1]}
+	}
+	if raw == "true" || raw == "false" {
+		return &types.AttributeValueMemberBOOL{Value: raw == "true"}
+	}
+	if _, err := strconv.ParseFloat(raw, 64); err == nil {
+		return &types.AttributeValueMemberN{Value: raw}
+	}
+	return &types.AttributeValueMemberS{Value: raw}
+}
+
+// expressionBuilder allocates attribute name and value placeholders shared by the expressions of one request
+type expressionBuilder struct {
+	names  map[string]string
+	values map[string]types.AttributeValue
+}
+
+func newExpressionBuilder() *expressionBuilder {
+	return &expressionBuilder{names: map[string]string{}, values: map[string]types.AttributeValue{}}
+}
+
+// name returns the placeholder for an attribute name, reusing it when the attribute appears twice
+func (b *expressionBuilder) name(attribute string) string {
+	for placeholder, name := range b.names {
+		if name == attribute {
+			return placeholder
+		}
+	}
+	placeholder := fmt.Sprintf("#n%d", len(b.names))
+	b.names[placeholder] = attribute
+	return placeholder
+}
+
+// value returns a new placeholder bound to the value
+func (b *expressionBuilder) value(v types.AttributeValue) string {
+	placeholder := fmt.Sprintf(":v%d", len(b.values))
+	b.values[placeholder] = v
+	return placeholder
+}
+
+// condition renders a single condition
+func (b *expressionBuilder) condition(c Condition) string {
+	name := b.name(c.Attribute)
+	switch c.Op {
+	case "exists":
+		return fmt.Sprintf("attribute_exists(%s)", name)
+	case "not_exists":
+		return fmt.Sprintf("attribute_not_exists(%s)", name)
+	case "begins_with", "contains":
+		return fmt.Sprintf("%s(%s, %s)", c.Op, name, b.value(c.Value))
+	}
+	return fmt.Sprintf("%s %s %s", name, comparisons[c.Op], b.value(c.Value))
+}
+
+// projection renders a ProjectionExpression for the attributes
+func (b *expressionBuilder) projection(fields []string) string {
+	placeholders := make([]string, len(fields))
+	for i, field := range fields {
+		placeholders[i] = b.name(field)
+	}
+	return strings.Join(placeholders, ", ")
+}
+
+// groups renders groups of alternative conditions: the conditions of a group joined with OR, and the
+// groups joined with AND
+func (b *expressionBuilder) groups(groups [][]Condition) string {
+	parts := make([]string, len(groups))
+	for i, group := range groups {
+		alternatives := make([]string, len(group))
+		for j, c := range group {
+			alternatives[j] = b.condition(c)
+		}
+		parts[i] = strings.Join(alternatives, " OR ")
+		if len(group) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// and renders the conditions joined with AND, or an empty string when there are none
+func (b *expressionBuilder) and(conditions []Condition) string {
+	parts := make([]string, len(conditions))
+	for i, c := range conditions {
+		parts[i] = b.condition(c)
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// attributeNames returns the name placeholders, or nil when none were used
+func (b *expressionBuilder) attributeNames() map[string]string {
+	if len(b.names) == 0 {
+		return nil
+	}
+	return b.names
+}
+
+// attributeValues returns the value placeholders, or nil when none were used
+func (b *expressionBuilder) attributeValues() map[string]types.AttributeValue {
+	if len(b.values) == 0 {
+		return nil
+	}
+	return b.values
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConditions(t *testing.T) {
+	conditions, err := parseConditions("status:eq:active, price:gt:100,flag:eq:true,code:eq:'007',deleted:not_exists")
+	require.NoError(t, err)
+	assert.Equal(t, []Condition{
+		{Attribute: "status", Op: "eq", Value: &types.AttributeValueMemberS{Value: "active"}},
+		{Attribute: "price", Op: "gt", Value: &types.AttributeValueMemberN{Value: "100"}},
+		{Attribute: "flag", Op: "eq", Value: &types.AttributeValueMemberBOOL{Value: true}},
+		{Attribute: "code", Op: "eq", Value: &types.AttributeValueMemberS{Value: "007"}},
+		{Attribute: "deleted", Op: "not_exists"},
+	}, conditions)
+
+	b := newExpressionBuilder()
+	assert.Equal(t, "#n0 = :v0 AND #n1 > :v1 AND #n2 = :v2 AND #n3 = :v3 AND attribute_not_exists(#n4)", b.and(conditions))
+}
+
+func TestParseConditionsErrors(t *testing.T) {
+	for _, input := range []string{"status", "status:like:x", "status:eq", "deleted:exists:1", ":eq:1"} {
+		_, err := parseConditions(input)
+		assert.Error(t, err, input)
+	}
+}
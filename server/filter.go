@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// maxFilterConditions bounds the conditions of a filter, keeping its FilterExpression well within the
+// 4 KB DynamoDB accepts
+const maxFilterConditions = 32
+
+// parseFilter reads the filter parameter: attr:op[:value] conditions joined with commas, which all
+// must hold, or with |, of which one must, e.g. "status:eq:active|status:eq:pending,price:gt:100".
+// | binds tighter than the comma. Values are typed as by parseTypedValue; values quoted with single
+// quotes are strings and may hold commas and pipes.
+func parseFilter(s string) ([][]Condition, error) {
+	var groups [][]Condition
+	count := 0
+	for _, part := range splitUnquoted(s, ',') {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		var group []Condition
+		for _, alternative := range splitUnquoted(part, '|') {
+			if alternative = strings.TrimSpace(alternative); alternative == "" {
+				return nil, fmt.Errorf("empty alternative in %q", part)
+			}
+			cond, err := parseCondition(alternative)
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, cond)
+		}
+		if count += len(group); count > maxFilterConditions {
+			return nil, fmt.Errorf("more than %d conditions", maxFilterConditions)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// splitUnquoted splits s around sep, except where sep is within single quotes
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// parseRequestFilter sets the filter of a request from its filter parameter and the filters of its
+// body, which all must hold. Every attribute name goes through a placeholder, so reserved words and any
+// character can be filtered on. Queries can't filter on the keys they read.
+func (h *Handler) parseRequestFilter(c echo.Context, params *Params, scan bool) *requestError {
+	groups, err := parseFilter(c.QueryParam("filter"))
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid filter parameter", err: err}
+	}
+	body, _ := c.Request().Context().Value(filterKey{}).(requestFilter)
+	for _, cond := range body.conditions {
+		groups = append(groups, []Condition{cond})
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	var attributes []string
+	for _, group := range groups {
+		for _, cond := range group {
+			attributes = append(attributes, cond.Attribute)
+		}
+	}
+	if len(attributes) > maxFilterConditions {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid filters", err: errors.New("too many conditions")}
+	}
+	builder := newExpressionBuilder()
+	params.Filter = &pagination.Filter{
+		Expression: builder.groups(groups),
+		Names:      builder.attributeNames(),
+		Values:     builder.attributeValues(),
+		Attributes: attributes,
+	}
+	if scan {
+		return nil
+	}
+	if err := params.ValidateFilter(h.keysFor(params.IndexName)); err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid filters", err: err}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	groups, err := parseFilter("status:eq:active|status:eq:'pending', price:gt:100,name:contains:'a,b|c',deleted:not_exists")
+	require.NoError(t, err)
+	assert.Equal(t, [][]Condition{
+		{
+			{Attribute: "status", Op: "eq", Value: &types.AttributeValueMemberS{Value: "active"}},
+			{Attribute: "status", Op: "eq", Value: &types.AttributeValueMemberS{Value: "pending"}},
+		},
+		{{Attribute: "price", Op: "gt", Value: &types.AttributeValueMemberN{Value: "100"}}},
+		{{Attribute: "name", Op: "contains", Value: &types.AttributeValueMemberS{Value: "a,b|c"}}},
+		{{Attribute: "deleted", Op: "not_exists"}},
+	}, groups)
+
+	groups, err = parseFilter("")
+	require.NoError(t, err)
+	assert.Nil(t, groups)
+
+	for _, invalid := range []string{
+		"status",
+		"status:like:active",
+		"status:eq",
+		"deleted:exists:true",
+		"status:eq:active|",
+		"a:exists" + strings.Repeat("|a:exists", maxFilterConditions),
+	} {
+		_, err := parseFilter(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestExpressionBuilderGroups(t *testing.T) {
+	groups, err := parseFilter("status:eq:active|status:eq:pending,size:ge:3")
+	require.NoError(t, err)
+	b := newExpressionBuilder()
+	assert.Equal(t, "(#n0 = :v0 OR #n0 = :v1) AND #n1 >= :v2", b.groups(groups))
+	assert.Equal(t, map[string]string{"#n0": "status", "#n1": "size"}, b.attributeNames())
+}
+
+func TestHandlePaginationFilter(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	var queries []*dynamodb.QueryInput
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		queries = append(queries, args.Get(1).(*dynamodb.QueryInput))
+	}).Return(&dynamodb.QueryOutput{}, nil)
+	mockDynamoDB.On("Scan", mock.Anything, mock.Anything).Return(&dynamodb.ScanOutput{}, nil)
+	handler := &Handler{client: mockDynamoDB}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	e.POST("/paginate", handler.handlePaginationBody)
+	e.GET("/scan", handler.handleScan)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+	get := func(target, filter string) *httptest.ResponseRecorder {
+		return serve(httptest.NewRequest(http.MethodGet, target+"&filter="+url.QueryEscape(filter), nil))
+	}
+
+	// Reserved words are filtered on through placeholders
+	rec := get("/paginate?key_condition=test", "status:eq:active|status:eq:pending,size:gt:10,public:eq:true")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	input := queries[len(queries)-1]
+	assert.Equal(t, "(#n0 = :v0 OR #n0 = :v1) AND #n1 > :v2 AND #n2 = :v3", *input.FilterExpression)
+	assert.Equal(t, "size", input.ExpressionAttributeNames["#n1"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "10"}, input.ExpressionAttributeValues[":v2"])
+	assert.Equal(t, &types.AttributeValueMemberBOOL{Value: true}, input.ExpressionAttributeValues[":v3"])
+
+	// The filter of a body holds along with its filters
+	req := httptest.NewRequest(http.MethodPost, "/paginate", strings.NewReader(`{"key_condition": "test", "filter": "size:lt:5|size:gt:50", "filters": [{"attribute": "status", "op": "eq", "value": "open"}]}`))
+	rec = serve(req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "(#n0 < :v0 OR #n0 > :v1) AND #n1 = :v2", *queries[len(queries)-1].FilterExpression)
+
+	// Queries can't filter on their keys, scans can
+	rec = get("/paginate?key_condition=test", "sort_key:begins_with:a")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Invalid filters", errorBody(t, rec).Message)
+	rec = get("/scan?pagesize=5", "sort_key:begins_with:a")
+	assert.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	filterExpression := mockDynamoDB.Calls[len(mockDynamoDB.Calls)-1].Arguments.Get(1).(*dynamodb.ScanInput).FilterExpression
+	assert.Equal(t, "begins_with(#n0, :v0)", *filterExpression)
+
+	rec = get("/paginate?key_condition=test", "status:like:active")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Invalid filter parameter", errorBody(t, rec).Message)
+}
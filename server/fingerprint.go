@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// headerFingerprint returns the fingerprint of a request, to correlate it with logs and audit records
+const headerFingerprint = "X-Request-Fingerprint"
+
+// canonicalParams normalize the values of parameters that can be written several ways. They return an
+// empty string for values that select the default, so the parameter is left out.
+var canonicalParams = map[string]func(string) string{
+	"page":                     canonicalInt(1),
+	"pagesize":                 canonicalInt(10),
+	"include_count":            canonicalFlag,
+	"debug":                    canonicalFlag,
+	"explain_empty":            canonicalFlag,
+	"consistent":               canonicalBool,
+	"orderby":                  func(v string) string { return strings.TrimPrefix(v, "+") },
+	"select":                   strings.ToLower,
+	"search_mode":              strings.ToLower,
+	"format":                   canonicalFormat,
+	"fields":                   canonicalFields,
+	"return_consumed_capacity": canonicalCapacity,
+	"consistency_token":        canonicalConsistencyToken,
+}
+
+// canonicalInt drops numbers that aren't positive or are the default, as extractParams falls back to
+// the default for them
+func canonicalInt(def int64) func(string) string {
+	return func(v string) string {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n <= 0 || n == def {
+			return ""
+		}
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+// canonicalFlag keeps only "true", the one value that turns a flag on
+func canonicalFlag(v string) string {
+	if v == "true" {
+		return v
+	}
+	return ""
+}
+
+// canonicalBool normalizes the spellings strconv.ParseBool accepts, dropping false
+func canonicalBool(v string) string {
+	b, err := strconv.ParseBool(v)
+	switch {
+	case err != nil:
+		return v
+	case b:
+		return "true"
+	}
+	return ""
+}
+
+func canonicalFormat(v string) string {
+	if v = strings.ToLower(v); v == defaultFormat {
+		return ""
+	}
+	return v
+}
+
+// canonicalFields sorts the projected attributes, which are read the same in any order
+func canonicalFields(v string) string {
+	fields := parseFields(v)
+	sort.Strings(fields)
+	unique := fields[:0]
+	for i, field := range fields {
+		if i == 0 || field != fields[i-1] {
+			unique = append(unique, field)
+		}
+	}
+	return strings.Join(unique, ",")
+}
+
+func canonicalCapacity(v string) string {
+	if v = strings.ToLower(v); v == "none" {
+		return ""
+	}
+	return v
+}
+
+// canonicalQuery renders a query string so that equivalent queries render the same: parameters are
+// sorted, only the first value of each is kept as handlers read no other, values are normalized and
+// defaults are left out. An empty cursor is kept, as it switches to cursor mode.
+func canonicalQuery(query url.Values) string {
+	canonical := url.Values{}
+	for name, values := range query {
+		if len(values) == 0 {
+			continue
+		}
+		v := values[0]
+		if normalize, ok := canonicalParams[name]; ok && v != "" {
+			v = normalize(v)
+		}
+		if v != "" || name == "cursor" {
+			canonical.Set(name, v)
+		}
+	}
+	// Encode sorts by name
+	return canonical.Encode()
+}
+
+// requestFingerprint identifies what a request asks for: its method, path and canonical query, the
+// output format the Accept header negotiates, the tenant making it and the response profile it is
+// pinned to. Requests with the same fingerprint are answered the same way, so it keys caches,
+// coalescing and idempotency records.
+func requestFingerprint(c echo.Context) string {
+	if fingerprint := fingerprintFrom(c.Request().Context()); fingerprint != "" {
+		return fingerprint
+	}
+	return computeFingerprint(c)
+}
+
+func computeFingerprint(c echo.Context) string {
+	var b bytes.Buffer
+	b.WriteString(c.Request().Method)
+	b.WriteByte('\n')
+	b.WriteString(canonicalTarget(c))
+	b.WriteByte('\n')
+	if tenant := tenantFrom(c.Request().Context()); tenant != nil {
+		b.WriteString(tenant.name)
+	}
+	if profile := requestProfile(c); profile != "" {
+		b.WriteString("\n" + profile)
+	}
+	sum := sha256.Sum256(b.Bytes())
+	return hex.EncodeToString(sum[:16])
+}
+
+// canonicalTarget renders the path and canonical query of a request, with the output format the Accept
+// header negotiates set as the format parameter and the filters of a POST /paginate body as filters
+func canonicalTarget(c echo.Context) string {
+	req := c.Request()
+	query := req.URL.Query()
+	if query.Get("format") == "" {
+		if format, _, reqErr := selectSerializer(c); reqErr == nil && format != defaultFormat {
+			query.Set("format", format)
+		}
+	}
+	if filter, ok := c.Request().Context().Value(filterKey{}).(requestFilter); ok {
+		query.Set("filters", filter.canonical)
+	}
+	if canonical := canonicalQuery(query); canonical != "" {
+		return req.URL.Path + "?" + canonical
+	}
+	return req.URL.Path
+}
+
+type fingerprintKey struct{}
+
+func withFingerprint(ctx context.Context, fingerprint string) context.Context {
+	return context.WithValue(ctx, fingerprintKey{}, fingerprint)
+}
+
+// fingerprintFrom returns the fingerprint recorded for a request, if any
+func fingerprintFrom(ctx context.Context) string {
+	fingerprint, _ := ctx.Value(fingerprintKey{}).(string)
+	return fingerprint
+}
+
+// logFingerprint writes the fingerprint of a request to its access log line
+func logFingerprint(c echo.Context, buf *bytes.Buffer) (int, error) {
+	return buf.WriteString(c.Response().Header().Get(headerFingerprint))
+}
+
+// Fingerprint records the fingerprint of every request in its context and returns it in the
+// X-Request-Fingerprint header. It runs after the tenant is resolved, which the fingerprint includes.
+func Fingerprint(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		recordFingerprint(c)
+		return next(c)
+	}
+}
+
+// recordFingerprint records the fingerprint of a request in its context and response, replacing any
+// recorded before its parameters were read from its body
+func recordFingerprint(c echo.Context) {
+	fingerprint := computeFingerprint(c)
+	c.SetRequest(c.Request().WithContext(withFingerprint(c.Request().Context(), fingerprint)))
+	c.Response().Header().Set(headerFingerprint, fingerprint)
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalQuery(t *testing.T) {
+	canonical := func(query string) string {
+		values, err := url.ParseQuery(query)
+		require.NoError(t, err)
+		return canonicalQuery(values)
+	}
+
+	assert.Equal(t, "key_condition=test&orderby=sort_key&pagesize=20",
+		canonical("pagesize=020&orderby=%2Bsort_key&page=1&key_condition=test&key_condition=other&search="))
+	assert.Equal(t, canonical("key_condition=test"),
+		canonical("key_condition=test&page=0&pagesize=10&include_count=false&format=JSON&return_consumed_capacity=NONE"))
+	assert.Equal(t, "cursor=&select=keys_only", canonical("select=KEYS_ONLY&cursor="))
+	assert.Equal(t, "include_count=true&page=2", canonical("include_count=true&page=2"))
+	assert.Equal(t, "fields=size%2Cstatus", canonical("fields=status,+size,status,"))
+	assert.Equal(t, "consistent=true", canonical("consistent=1"))
+	assert.Equal(t, "", canonical("consistent=false"))
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := func(method, target string, header http.Header, tenant *TenantConfig) string {
+		req := httptest.NewRequest(method, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		if tenant != nil {
+			req = req.WithContext(withRequestContext(context.Background(), &RequestContext{Tenant: tenant}))
+		}
+		return requestFingerprint(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	base := fingerprint(http.MethodGet, "/paginate?key_condition=test&pagesize=5", nil, nil)
+	assert.Len(t, base, 32)
+	assert.Equal(t, base, fingerprint(http.MethodGet, "/paginate?pagesize=5&page=1&key_condition=test", nil, nil))
+	assert.Equal(t, base, fingerprint(http.MethodGet, "/paginate?key_condition=test&pagesize=5&format=json", nil, nil))
+
+	csv := fingerprint(http.MethodGet, "/paginate?key_condition=test&pagesize=5&format=csv", nil, nil)
+	assert.NotEqual(t, base, csv)
+	assert.Equal(t, csv, fingerprint(http.MethodGet, "/paginate?key_condition=test&pagesize=5", http.Header{"Accept": {"text/csv"}}, nil))
+
+	assert.NotEqual(t, base, fingerprint(http.MethodGet, "/paginate?key_condition=other&pagesize=5", nil, nil))
+	assert.NotEqual(t, base, fingerprint(http.MethodGet, "/scan?key_condition=test&pagesize=5", nil, nil))
+	assert.NotEqual(t, base, fingerprint(http.MethodPost, "/paginate?key_condition=test&pagesize=5", nil, nil))
+	assert.NotEqual(t, base, fingerprint(http.MethodGet, "/paginate?key_condition=test&pagesize=5", nil, &TenantConfig{name: "acme"}))
+}
+
+func TestFingerprintMiddleware(t *testing.T) {
+	var recorded string
+	e := echo.New()
+	e.Use(Fingerprint)
+	e.GET("/paginate", func(c echo.Context) error {
+		recorded = fingerprintFrom(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.NotEmpty(t, recorded)
+	assert.Equal(t, recorded, rec.Header().Get(headerFingerprint))
+}
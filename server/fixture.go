@@ -0,0 +1,386 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// errFixtureReadOnly is returned for writes against fixture data
+var errFixtureReadOnly = errors.New("fixtures are read-only")
+
+// errFixtureIndex is returned for reads of a secondary index, which fixtures don't have
+var errFixtureIndex = errors.New("fixtures have no secondary indexes")
+
+// partitionPlaceholder finds the value placeholder compared against the partition key
+var partitionPlaceholder = regexp.MustCompile(`=\s*(:\w+)`)
+
+// The conditions on the sort key of a search or a sort key range, in a FilterExpression or after AND in a
+// key condition
+var (
+	searchCondition  = regexp.MustCompile(`^(contains|begins_with)\((#\w+),\s*(:\w+)\)`)
+	betweenCondition = regexp.MustCompile(`^(#\w+) BETWEEN (:\w+) AND (:\w+)`)
+	compareCondition = regexp.MustCompile(`^(#\w+) (<|>) (:\w+)`)
+)
+
+// Fixture is a set of items served in place of a real table
+type Fixture struct {
+	PartitionKey string                   `json:"partition_key"`
+	SortKey      string                   `json:"sort_key"`
+	Items        []map[string]interface{} `json:"items"`
+}
+
+// GenerateFixture builds a fixture of the service's table with count items in each partition, with sort
+// keys item0001, item0002 and so on
+func GenerateFixture(partitions []string, count int) Fixture {
+	fixture := Fixture{PartitionKey: tableKeys.PartitionKey, SortKey: tableKeys.SortKey}
+	for _, partition := range partitions {
+		for i := 1; i <= count; i++ {
+			fixture.Items = append(fixture.Items, map[string]interface{}{
+				tableKeys.PartitionKey: partition,
+				tableKeys.SortKey:      fmt.Sprintf("item%04d", i),
+			})
+		}
+	}
+	return fixture
+}
+
+// FixtureClient is a DynamoClient that answers queries from a Fixture, for running the API without AWS
+type FixtureClient struct {
+	fixture Fixture
+	items   []map[string]types.AttributeValue
+}
+
+// LoadFixtureClient reads a fixture file and prepares it for querying
+func LoadFixtureClient(path string) (*FixtureClient, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are read with all their digits, like DynamoDB stores them
+	var fixture Fixture
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fixture); err != nil {
+		return nil, err
+	}
+	for _, item := range fixture.Items {
+		exactNumbers(item)
+	}
+	return NewFixtureClient(fixture)
+}
+
+// NewFixtureClient converts the fixture items into DynamoDB items ordered by sort key. Numeric sort keys
+// are ordered by value, like in DynamoDB.
+func NewFixtureClient(fixture Fixture) (*FixtureClient, error) {
+	if fixture.PartitionKey == "" {
+		return nil, fmt.Errorf("fixture has no partition_key")
+	}
+
+	items := make([]map[string]types.AttributeValue, 0, len(fixture.Items))
+	for i, raw := range fixture.Items {
+		item, err := attributevalue.MarshalMap(raw)
+		if err != nil {
+			return nil, fmt.Errorf("fixture item %d: %w", i, err)
+		}
+		items = append(items, item)
+	}
+
+	client := &FixtureClient{fixture: fixture, items: items}
+	sort.SliceStable(client.items, func(i, j int) bool {
+		return client.compareSort(client.items[i], client.items[j][fixture.SortKey]) < 0
+	})
+
+	return client, nil
+}
+
+func (f *FixtureClient) sortValue(item map[string]types.AttributeValue) string {
+	return attributeString(item[f.fixture.SortKey])
+}
+
+// compareSort orders an item against a sort key value
+func (f *FixtureClient) compareSort(item map[string]types.AttributeValue, sortKey types.AttributeValue) int {
+	return compareSortValues(item[f.fixture.SortKey], sortKey)
+}
+
+// attributeString renders scalar attribute values for comparisons
+func attributeString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
+
+// Query returns the fixture items of the requested partition, honoring Limit, ExclusiveStartKey, ScanIndexForward,
+// prefix searches and sort key ranges
+func (f *FixtureClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	if params.KeyConditionExpression == nil {
+		return nil, fmt.Errorf("fixture query requires a key condition")
+	}
+	if params.FilterExpression != nil {
+		return nil, fmt.Errorf("fixture queries don't support filters")
+	}
+	if params.IndexName != nil {
+		return nil, errFixtureIndex
+	}
+	match := partitionPlaceholder.FindStringSubmatch(*params.KeyConditionExpression)
+	if match == nil {
+		return nil, fmt.Errorf("unsupported key condition %q", *params.KeyConditionExpression)
+	}
+	partition := attributeString(params.ExpressionAttributeValues[match[1]])
+
+	var matched []map[string]types.AttributeValue
+	for _, item := range f.items {
+		if attributeString(item[f.fixture.PartitionKey]) == partition {
+			matched = append(matched, item)
+		}
+	}
+	if _, sortCondition, ok := strings.Cut(*params.KeyConditionExpression, " AND "); ok {
+		var err error
+		matched, err = filterItems(matched, &sortCondition, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if params.ScanIndexForward != nil && !*params.ScanIndexForward {
+		for i, j := 0, len(matched)-1; i < j; i, j = i+1, j-1 {
+			matched[i], matched[j] = matched[j], matched[i]
+		}
+	}
+
+	// Like DynamoDB, continue after the start key whether or not an item has that key
+	if params.ExclusiveStartKey != nil {
+		forward := params.ScanIndexForward == nil || *params.ScanIndexForward
+		startSort := params.ExclusiveStartKey[f.fixture.SortKey]
+		start := len(matched)
+		for i, item := range matched {
+			order := f.compareSort(item, startSort)
+			if (forward && order > 0) || (!forward && order < 0) {
+				start = i
+				break
+			}
+		}
+		matched = matched[start:]
+	}
+
+	output := &dynamodb.QueryOutput{}
+	if params.Limit != nil && int(*params.Limit) < len(matched) {
+		matched = matched[:*params.Limit]
+		last := matched[len(matched)-1]
+		output.LastEvaluatedKey = map[string]types.AttributeValue{
+			f.fixture.PartitionKey: last[f.fixture.PartitionKey],
+		}
+		if f.fixture.SortKey != "" {
+			output.LastEvaluatedKey[f.fixture.SortKey] = last[f.fixture.SortKey]
+		}
+	}
+
+	output.Items = matched
+	output.Count = int32(len(matched))
+	output.ScannedCount = output.Count
+	return output, nil
+}
+
+// filterItems applies the conditions of a search and a sort key range to items, like DynamoDB
+func filterItems(items []map[string]types.AttributeValue, filter *string, names map[string]string, values map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	if filter == nil {
+		return items, nil
+	}
+
+	for rest := *filter; rest != ""; {
+		matches, length, err := parseFixtureCondition(rest, names, values)
+		if err != nil {
+			return nil, err
+		}
+		rest = rest[length:]
+		if rest != "" && !strings.HasPrefix(rest, " AND ") {
+			return nil, fmt.Errorf("unsupported condition %q", *filter)
+		}
+		rest = strings.TrimPrefix(rest, " AND ")
+
+		var filtered []map[string]types.AttributeValue
+		for _, item := range items {
+			if matches(item) {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	return items, nil
+}
+
+// parseFixtureCondition parses the condition at the start of an expression, returning its test and length
+func parseFixtureCondition(expr string, names map[string]string, values map[string]types.AttributeValue) (func(map[string]types.AttributeValue) bool, int, error) {
+	if match := searchCondition.FindStringSubmatch(expr); match != nil {
+		contains := strings.Contains
+		if match[1] == "begins_with" {
+			contains = strings.HasPrefix
+		}
+		attribute, search := names[match[2]], attributeString(values[match[3]])
+		return func(item map[string]types.AttributeValue) bool {
+			// The search functions only match string attributes
+			v, ok := item[attribute].(*types.AttributeValueMemberS)
+			return ok && contains(v.Value, search)
+		}, len(match[0]), nil
+	}
+	if match := betweenCondition.FindStringSubmatch(expr); match != nil {
+		attribute, low, high := names[match[1]], values[match[2]], values[match[3]]
+		return func(item map[string]types.AttributeValue) bool {
+			return compareSortValues(item[attribute], low) >= 0 && compareSortValues(item[attribute], high) <= 0
+		}, len(match[0]), nil
+	}
+	if match := compareCondition.FindStringSubmatch(expr); match != nil {
+		attribute, op, value := names[match[1]], match[2], values[match[3]]
+		return func(item map[string]types.AttributeValue) bool {
+			if item[attribute] == nil {
+				return false
+			}
+			order := compareSortValues(item[attribute], value)
+			return (op == "<" && order < 0) || (op == ">" && order > 0)
+		}, len(match[0]), nil
+	}
+	return nil, 0, fmt.Errorf("unsupported condition %q", expr)
+}
+
+// Scan returns the fixture items of the requested segment, honoring Limit, ExclusiveStartKey and the search
+// filter. Items are assigned to segments round-robin in sort key order.
+func (f *FixtureClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	if params.IndexName != nil {
+		return nil, errFixtureIndex
+	}
+	segment, total := 0, 1
+	if params.TotalSegments != nil && params.Segment != nil {
+		segment, total = int(*params.Segment), int(*params.TotalSegments)
+	}
+
+	var matched []map[string]types.AttributeValue
+	for i, item := range f.items {
+		if i%total == segment {
+			matched = append(matched, item)
+		}
+	}
+
+	if params.ExclusiveStartKey != nil {
+		start := f.itemKey(params.ExclusiveStartKey)
+		next := len(matched)
+		for i, item := range matched {
+			if f.itemKey(item) == start {
+				next = i + 1
+				break
+			}
+			// Fixture scans run in sort key order, so a start key without an item resumes after its sort key
+			if f.compareSort(item, params.ExclusiveStartKey[f.fixture.SortKey]) > 0 {
+				next = i
+				break
+			}
+		}
+		matched = matched[next:]
+	}
+
+	output := &dynamodb.ScanOutput{}
+	if params.Limit != nil && int(*params.Limit) < len(matched) {
+		matched = matched[:*params.Limit]
+		last := matched[len(matched)-1]
+		output.LastEvaluatedKey = map[string]types.AttributeValue{f.fixture.PartitionKey: last[f.fixture.PartitionKey]}
+		if f.fixture.SortKey != "" {
+			output.LastEvaluatedKey[f.fixture.SortKey] = last[f.fixture.SortKey]
+		}
+	}
+
+	output.ScannedCount = int32(len(matched))
+	matched, err := filterItems(matched, params.FilterExpression, params.ExpressionAttributeNames, params.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	output.Items = matched
+	output.Count = int32(len(matched))
+	return output, nil
+}
+
+func (f *FixtureClient) itemKey(item map[string]types.AttributeValue) string {
+	return attributeString(item[f.fixture.PartitionKey]) + "\x00" + f.sortValue(item)
+}
+
+// GetItem returns the fixture item whose attributes match every attribute of the requested key
+func (f *FixtureClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	for _, item := range f.items {
+		matches := true
+		for name, value := range params.Key {
+			if attributeString(item[name]) != attributeString(value) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+// BatchGetItem returns the fixture items of the requested keys, processing every key
+func (f *FixtureClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+	for table, request := range params.RequestItems {
+		for _, key := range request.Keys {
+			result, err := f.GetItem(ctx, &dynamodb.GetItemInput{TableName: &table, Key: key})
+			if err != nil {
+				return nil, err
+			}
+			if len(result.Item) > 0 {
+				output.Responses[table] = append(output.Responses[table], result.Item)
+			}
+		}
+	}
+	return output, nil
+}
+
+// DescribeTable reports the number and estimated size of the fixture items, in an active table
+func (f *FixtureClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	var size int64
+	for _, item := range f.items {
+		size += itemSize(item)
+	}
+	count := int64(len(f.items))
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName:      params.TableName,
+		TableStatus:    types.TableStatusActive,
+		ItemCount:      &count,
+		TableSizeBytes: &size,
+	}}, nil
+}
+
+// PutItem is not supported on fixtures
+func (f *FixtureClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return nil, errFixtureReadOnly
+}
+
+// UpdateItem is not supported on fixtures
+func (f *FixtureClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return nil, errFixtureReadOnly
+}
+
+// DeleteItem is not supported on fixtures
+func (f *FixtureClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return nil, errFixtureReadOnly
+}
+
+// BatchWriteItem is not supported on fixtures
+func (f *FixtureClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return nil, errFixtureReadOnly
+}
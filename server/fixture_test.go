@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixtureClientQuery(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	input := keyConditionQuery("test")
+	input.Limit = aws.Int32(2)
+
+	out, err := client.Query(context.Background(), input)
+	require.NoError(t, err)
+	assert.Len(t, out.Items, 2)
+	assert.Equal(t, map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item2"},
+	}, out.LastEvaluatedKey)
+
+	input.ExclusiveStartKey = out.LastEvaluatedKey
+	out, err = client.Query(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]types.AttributeValue{
+		{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item3"}},
+	}, out.Items)
+	assert.Nil(t, out.LastEvaluatedKey)
+}
+
+func TestFixtureClientStartKeyPosition(t *testing.T) {
+	var items []map[string]interface{}
+	for _, sk := range []int{10, 9, 100, 20} {
+		items = append(items, map[string]interface{}{"key_cond": "test", "sort_key": sk})
+	}
+	client, err := NewFixtureClient(Fixture{PartitionKey: "key_cond", SortKey: "sort_key", Items: items})
+	require.NoError(t, err)
+
+	query := func(start string, forward bool) []string {
+		input := keyConditionQuery("test")
+		input.ScanIndexForward = aws.Bool(forward)
+		if start != "" {
+			input.ExclusiveStartKey = map[string]types.AttributeValue{
+				"key_cond": &types.AttributeValueMemberS{Value: "test"},
+				"sort_key": &types.AttributeValueMemberN{Value: start},
+			}
+		}
+		out, err := client.Query(context.Background(), input)
+		require.NoError(t, err)
+		var sortKeys []string
+		for _, item := range out.Items {
+			sortKeys = append(sortKeys, attributeString(item["sort_key"]))
+		}
+		return sortKeys
+	}
+
+	// Numbers are ordered by value
+	assert.Equal(t, []string{"9", "10", "20", "100"}, query("", true))
+	assert.Equal(t, []string{"20", "100"}, query("10", true))
+	// A start key without an item resumes after its position
+	assert.Equal(t, []string{"20", "100"}, query("15", true))
+	assert.Equal(t, []string{"10", "9"}, query("15", false))
+	assert.Empty(t, query("1000", true))
+}
+
+func TestHandlePaginationWithFixtures(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&orderby=-sort_key", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: client}
+	require.NoError(t, handler.handlePagination(c))
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, Response{
+		Data: []Entry{
+			{KeyCond: "test", SortKey: "item3"},
+			{KeyCond: "test", SortKey: "item2"},
+			{KeyCond: "test", SortKey: "item1"},
+		},
+		Page: 1,
+		Size: 3,
+	}, response)
+}
+
+func TestGenerateFixture(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test", "other"}, 12))
+	require.NoError(t, err)
+
+	input := keyConditionQuery("other")
+	input.Limit = aws.Int32(1)
+	input.ScanIndexForward = aws.Bool(false)
+	out, err := client.Query(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]types.AttributeValue{
+		{"key_cond": &types.AttributeValueMemberS{Value: "other"}, "sort_key": &types.AttributeValueMemberS{Value: "item0012"}},
+	}, out.Items)
+}
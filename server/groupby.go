@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// Group is the items of a page sharing a value of the group_by attribute
+type Group struct {
+	// Value is the attribute value, "" for items without the attribute
+	Value string
+	Count int
+	Items []Entry
+}
+
+// GroupedResponse is a page of /paginate with group_by: the items of the page grouped by an attribute,
+// with the groups in the order of their first item
+type GroupedResponse struct {
+	Groups     []Group
+	Page       int64
+	Size       int64
+	Meta       *Meta  `json:",omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// groupedEntry is an item with the value of the attribute it's grouped by
+type groupedEntry struct {
+	Entry
+	group string
+}
+
+// parseGroupBy validates the group_by parameter against the select mode. A keys-only page can only be
+// grouped by a key attribute of the table or of the index being read, and a count has no items to group.
+func parseGroupBy(c echo.Context, params Params, table, index pagination.KeySchema) (string, *requestError) {
+	groupBy := c.QueryParam("group_by")
+	if groupBy == "" {
+		return "", nil
+	}
+	switch {
+	case params.Select == "count":
+		return "", &requestError{status: http.StatusBadRequest, message: "group_by can't be combined with select=count"}
+	case params.IncludeCount:
+		return "", &requestError{status: http.StatusBadRequest, message: "group_by can't be combined with include_count"}
+	case params.Select == "keys_only" && !isKeyAttribute(groupBy, table) && !isKeyAttribute(groupBy, index):
+		return "", &requestError{status: http.StatusBadRequest, message: "select=keys_only can only be grouped by a key attribute"}
+	}
+	return groupBy, nil
+}
+
+func isKeyAttribute(name string, keys pagination.KeySchema) bool {
+	return name == keys.PartitionKey || name == keys.SortKey
+}
+
+// fetchGroupedPage assembles the requested page like fetchPage and groups its items by an attribute
+func (h *Handler) fetchGroupedPage(ctx context.Context, client DynamoClient, keyCond string, params Params, groupBy string) (GroupedResponse, *requestError) {
+	params.KeyCondition = keyCond
+	if len(params.Fields) > 0 {
+		// Items are grouped by an attribute, which has to be read
+		params.Fields = append(append([]string{}, params.Fields...), groupBy)
+	}
+	table, keys := h.schema()
+	p := pagination.New[groupedEntry](client, table, keys)
+	p.Indexes = h.indexes
+	p.Plans = h.plans
+	p.Decode = func(item map[string]types.AttributeValue, partial bool) (groupedEntry, []Warning, bool, error) {
+		entry, warnings, keep, reqErr := h.decodePageItem(item, readOf(params, partial))
+		if reqErr != nil {
+			return groupedEntry{}, warnings, keep, reqErr
+		}
+		return groupedEntry{Entry: entry, group: groupValue(item[groupBy])}, warnings, keep, nil
+	}
+
+	res, err := p.GetPage(ctx, params)
+	if err != nil {
+		return GroupedResponse{}, pageError(err)
+	}
+
+	nextCursor, reqErr := h.pinCursor(ctx, res.NextCursor)
+	if reqErr != nil {
+		return GroupedResponse{}, reqErr
+	}
+	grouped := GroupedResponse{Groups: []Group{}, Page: res.Page, Size: res.Size, Meta: res.Meta, NextCursor: nextCursor}
+	index := map[string]int{}
+	for _, item := range res.Data {
+		i, ok := index[item.group]
+		if !ok {
+			i = len(grouped.Groups)
+			index[item.group] = i
+			grouped.Groups = append(grouped.Groups, Group{Value: item.group})
+		}
+		grouped.Groups[i].Count++
+		grouped.Groups[i].Items = append(grouped.Groups[i].Items, item.Entry)
+	}
+	for _, group := range grouped.Groups {
+		tenantFrom(ctx).redact(group.Items)
+	}
+	return grouped, nil
+}
+
+// groupValue is the value an item is grouped by. Only strings, numbers and booleans form groups.
+func groupValue(av types.AttributeValue) string {
+	if v, ok := av.(*types.AttributeValueMemberBOOL); ok {
+		if v.Value {
+			return "true"
+		}
+		return "false"
+	}
+	return attributeString(av)
+}
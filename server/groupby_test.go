@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationGroupBy(t *testing.T) {
+	item := func(sk string, status types.AttributeValue) map[string]types.AttributeValue {
+		item := map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "test"},
+			"sort_key": &types.AttributeValueMemberS{Value: sk},
+		}
+		if status != nil {
+			item["status"] = status
+		}
+		return item
+	}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+		item("item1", &types.AttributeValueMemberS{Value: "active"}),
+		item("item2", &types.AttributeValueMemberS{Value: "inactive"}),
+		item("item3", &types.AttributeValueMemberS{Value: "active"}),
+		item("item4", nil),
+	}}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&group_by=status", nil)
+	rec := httptest.NewRecorder()
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response GroupedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, GroupedResponse{
+		Groups: []Group{
+			{Value: "active", Count: 2, Items: []Entry{{KeyCond: "test", SortKey: "item1"}, {KeyCond: "test", SortKey: "item3"}}},
+			{Value: "inactive", Count: 1, Items: []Entry{{KeyCond: "test", SortKey: "item2"}}},
+			{Value: "", Count: 1, Items: []Entry{{KeyCond: "test", SortKey: "item4"}}},
+		},
+		Page: 1,
+		Size: 4,
+	}, response)
+}
+
+func TestHandlePaginationGroupByInvalid(t *testing.T) {
+	for _, query := range []string{"group_by=status&select=count", "group_by=status&select=keys_only"} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&"+query, nil)
+		rec := httptest.NewRecorder()
+		handler := &Handler{client: new(MockDynamoDB)}
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+}
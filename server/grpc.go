@@ -0,0 +1,281 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/elad-da/dynamopagination/paginationpb"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcCodes translate the statuses of request errors into gRPC codes
+var grpcCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.FailedPrecondition,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// grpcPaths are the HTTP routes whose middleware configuration, such as their timeouts, applies to
+// the RPCs
+var grpcPaths = map[string]string{
+	paginationpb.Pagination_Paginate_FullMethodName: "/paginate",
+	paginationpb.Pagination_Export_FullMethodName:   "/export",
+}
+
+// grpcPagination serves the Pagination service through the validation, paginator, decoding, caches
+// and cursors of the HTTP routes
+type grpcPagination struct {
+	paginationpb.UnimplementedPaginationServer
+	h *Handler
+	// echo builds the contexts the parameters of the requests are read from
+	echo *echo.Echo
+}
+
+// NewGRPCServer creates a gRPC server serving the Pagination service for the handler's table. Calls
+// are authenticated, rate limited and budgeted like HTTP requests, by their tenant and priority class.
+func (h *Handler) NewGRPCServer() *grpc.Server {
+	p := &grpcPagination{h: h, echo: echo.New()}
+	s := grpc.NewServer(grpc.UnaryInterceptor(p.unaryInterceptor), grpc.StreamInterceptor(p.streamInterceptor))
+	paginationpb.RegisterPaginationServer(s, p)
+	return s
+}
+
+// Paginate serves a page like GET /paginate
+func (s *grpcPagination) Paginate(ctx context.Context, req *paginationpb.PaginateRequest) (*paginationpb.PaginateResponse, error) {
+	c, client, keyCond, params, reqErr := s.request(ctx, req)
+	if reqErr != nil {
+		return nil, grpcError(reqErr)
+	}
+	if reqErr := s.h.preflight(c, client, params); reqErr != nil {
+		return nil, grpcError(reqErr)
+	}
+	res, reqErr := s.h.fetchPage(c.Request().Context(), client, keyCond, params, nil)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return nil, grpcError(reqErr)
+	}
+
+	out := &paginationpb.PaginateResponse{Page: res.Page, Size: res.Size, TotalItems: res.TotalItems, NextCursor: res.NextCursor}
+	if out.Items, reqErr = grpcItems(res.Data); reqErr != nil {
+		return nil, grpcError(reqErr)
+	}
+	if res.Meta != nil {
+		for _, w := range res.Meta.Warnings {
+			out.Warnings = append(out.Warnings, &paginationpb.Warning{Code: w.Code, Message: w.Message, Key: w.Key})
+		}
+	}
+	return out, nil
+}
+
+// Export streams the items of a query like GET /export, reporting how it ended in the trailer
+func (s *grpcPagination) Export(req *paginationpb.PaginateRequest, stream paginationpb.Pagination_ExportServer) error {
+	c, client, _, params, reqErr := s.request(stream.Context(), req)
+	if reqErr != nil {
+		return grpcError(reqErr)
+	}
+	if params.Select == "count" {
+		return status.Error(codes.InvalidArgument, "Counts can't be exported")
+	}
+	params.CursorMode = true
+	params.PageSize = streamPageSize
+	params.IncludeCount = false
+	ctx := c.Request().Context()
+
+	var roundTrip pagination.Progress
+	p := s.h.paginator(client, params)
+	p.OnProgress = func(progress pagination.Progress) { roundTrip = progress }
+	page, err := p.GetPage(ctx, params)
+	if err != nil {
+		reqErr := pageError(err)
+		c.Logger().Error(reqErr)
+		return grpcError(reqErr)
+	}
+
+	exportStatus, cursor, err := s.h.exportPages(ctx, p, params, page, &roundTrip, func(page Response) error {
+		items, reqErr := grpcItems(page.Data)
+		if reqErr != nil {
+			return reqErr
+		}
+		for _, item := range items {
+			if err := stream.Send(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.Logger().Error(err)
+	}
+	trailer := metadata.Pairs("x-stream-status", exportStatus)
+	if exportStatus != "complete" && cursor != "" {
+		if pinned, reqErr := s.h.pinCursor(ctx, cursor); reqErr == nil {
+			trailer.Append("x-export-cursor", pinned)
+		}
+	}
+	stream.SetTrailer(trailer)
+	return nil
+}
+
+// request reads a request the way GET /paginate reads its query parameters
+func (s *grpcPagination) request(ctx context.Context, req *paginationpb.PaginateRequest) (echo.Context, DynamoClient, string, Params, *requestError) {
+	body := PaginationBody{
+		Params: Params{
+			KeyCondition:   req.KeyCondition,
+			Page:           req.Page,
+			PageSize:       req.PageSize,
+			OrderBy:        req.OrderBy,
+			Search:         req.Search,
+			SearchMode:     req.SearchMode,
+			Select:         req.Select,
+			Fields:         req.Fields,
+			IndexName:      req.Index,
+			ConsistentRead: req.Consistent,
+			IncludeCount:   req.IncludeCount,
+		},
+		Cursor: req.Cursor,
+		Region: req.Region,
+	}
+	if req.SortRange != nil {
+		body.SortRange = &pagination.SortRange{Op: req.SortRange.Op, Value: req.SortRange.Value, End: req.SortRange.End}
+	}
+	query := url.Values{}
+	if reqErr := body.setQuery(query); reqErr != nil {
+		return nil, nil, "", Params{}, reqErr
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/paginate?"+query.Encode(), nil)
+	if err != nil {
+		return nil, nil, "", Params{}, &requestError{status: http.StatusBadRequest, message: "Invalid request", err: err}
+	}
+	c := s.echo.NewContext(r, &queryRecorder{header: http.Header{}})
+	client, keyCond, params, _, reqErr := s.h.paginationRequest(c)
+	return c, client, keyCond, params, reqErr
+}
+
+// admit runs a call through the middleware admitting HTTP requests, as a request to path with the
+// call's metadata as headers, and serves it with the context they record if they let it through:
+// its caller, tenant, priority class and budget
+func (s *grpcPagination) admit(ctx context.Context, path string, serve func(context.Context) error) error {
+	if len(s.h.callers) == 0 {
+		return serve(ctx)
+	}
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for name, values := range md {
+		if strings.HasPrefix(name, ":") {
+			continue
+		}
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	rec := &queryRecorder{header: http.Header{}, status: http.StatusOK}
+	c := s.echo.NewContext(r, rec)
+	c.SetPath(path)
+
+	served := false
+	var serveErr error
+	next := func(c echo.Context) error {
+		served = true
+		serveErr = serve(c.Request().Context())
+		return nil
+	}
+	for i := len(s.h.callers) - 1; i >= 0; i-- {
+		next = s.h.callers[i](next)
+	}
+	if err := next(c); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if served {
+		return serveErr
+	}
+	var res ErrorResponse
+	json.Unmarshal(rec.body.Bytes(), &res)
+	return grpcError(&requestError{status: rec.status, message: res.Error.Message})
+}
+
+func (s *grpcPagination) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var res interface{}
+	err := s.admit(ctx, grpcPaths[info.FullMethod], func(ctx context.Context) error {
+		var err error
+		res, err = handler(ctx, req)
+		return err
+	})
+	return res, err
+}
+
+func (s *grpcPagination) streamInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return s.admit(stream.Context(), grpcPaths[info.FullMethod], func(ctx context.Context) error {
+		return handler(srv, &admittedStream{ServerStream: stream, ctx: ctx})
+	})
+}
+
+// admittedStream serves a stream with the context its call was admitted with
+type admittedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *admittedStream) Context() context.Context {
+	return s.ctx
+}
+
+// grpcItems converts the entries of a page, with their computed fields and typed attributes as
+// JSON would serve them
+func grpcItems(entries []Entry) ([]*paginationpb.Item, *requestError) {
+	items := make([]*paginationpb.Item, len(entries))
+	for i, entry := range entries {
+		item := &paginationpb.Item{KeyCond: entry.KeyCond, SortKey: entry.SortKey}
+		var err error
+		if item.Computed, err = grpcStruct(entry.Computed); err == nil {
+			item.Attributes, err = grpcStruct(entry.Attributes)
+		}
+		if err != nil {
+			return nil, &requestError{status: http.StatusInternalServerError, message: "Error serializing page", err: err}
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+func grpcStruct(fields map[string]interface{}) (*structpb.Struct, error) {
+	if fields == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var s structpb.Struct
+	if err := protojson.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// grpcError turns a request error into the status of an RPC, with the message of the HTTP error
+func grpcError(reqErr *requestError) error {
+	code, ok := grpcCodes[reqErr.status]
+	if !ok {
+		code = codes.Internal
+	}
+	return status.Error(code, reqErr.message)
+}
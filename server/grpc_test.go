@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/elad-da/dynamopagination/paginationpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// dialGRPC serves the handler's gRPC API in memory and returns a client of it
+func dialGRPC(t *testing.T, h *Handler) paginationpb.PaginationClient {
+	lis := bufconn.Listen(1 << 20)
+	s := h.NewGRPCServer()
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return paginationpb.NewPaginationClient(conn)
+}
+
+func TestGRPCPaginate(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	client := dialGRPC(t, &Handler{client: fixture})
+	ctx := context.Background()
+
+	res, err := client.Paginate(ctx, &paginationpb.PaginateRequest{KeyCondition: "test", PageSize: 2, Page: 2, IncludeCount: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), res.Page)
+	assert.Equal(t, int64(5), res.GetTotalItems())
+	require.Len(t, res.Items, 2)
+	assert.Equal(t, "item0003", res.Items[0].SortKey)
+	assert.Equal(t, "test", res.Items[0].KeyCond)
+
+	var sortKeys []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		res, err := client.Paginate(ctx, &paginationpb.PaginateRequest{KeyCondition: "test", PageSize: 2, Cursor: proto.String(cursor), OrderBy: "-sort_key"})
+		require.NoError(t, err)
+		for _, item := range res.Items {
+			sortKeys = append(sortKeys, item.SortKey)
+		}
+		if cursor = res.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"item0005", "item0004", "item0003", "item0002", "item0001"}, sortKeys)
+
+	_, err = client.Paginate(ctx, &paginationpb.PaginateRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "Invalid key_condition parameter", status.Convert(err).Message())
+	_, err = client.Paginate(ctx, &paginationpb.PaginateRequest{KeyCondition: "test", SortRange: &paginationpb.SortRange{Op: "~"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCExport(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 1200))
+	require.NoError(t, err)
+
+	export := func(h *Handler) ([]string, metadata.MD) {
+		stream, err := dialGRPC(t, h).Export(context.Background(), &paginationpb.PaginateRequest{KeyCondition: "test"})
+		require.NoError(t, err)
+		var sortKeys []string
+		for {
+			item, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			sortKeys = append(sortKeys, item.SortKey)
+		}
+		return sortKeys, stream.Trailer()
+	}
+
+	sortKeys, trailer := export(&Handler{client: fixture})
+	assert.Len(t, sortKeys, 1200)
+	assert.Equal(t, "item1200", sortKeys[1199])
+	assert.Equal(t, []string{"complete"}, trailer.Get("x-stream-status"))
+	assert.Empty(t, trailer.Get("x-export-cursor"))
+
+	sortKeys, trailer = export(&Handler{client: fixture, stream: StreamLimits{ScanBudget: 500}})
+	assert.Len(t, sortKeys, 500)
+	assert.Equal(t, []string{"truncated"}, trailer.Get("x-stream-status"))
+	assert.NotEmpty(t, trailer.Get("x-export-cursor"))
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	healthPath = "/healthz"
+	readyPath  = "/readyz"
+	// readinessTimeout bounds the DescribeTable calls of a readiness check
+	readinessTimeout = 2 * time.Second
+)
+
+// HealthResponse is the body of the health and readiness probes. Status is "ok" for /healthz, and
+// "ready" or "not_ready" for /readyz, which lists the tables it checked.
+type HealthResponse struct {
+	Status string        `json:"status"`
+	Tables []TableHealth `json:"tables,omitempty"`
+}
+
+// TableHealth is the state of a table the service reads, as DescribeTable reported it
+type TableHealth struct {
+	Name string `json:"name"`
+	// Status is the TableStatus of the table, empty when it couldn't be described
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// servingStatuses are the table statuses in which the table serves reads
+var servingStatuses = map[types.TableStatus]bool{
+	types.TableStatusActive:   true,
+	types.TableStatusUpdating: true,
+}
+
+// probes serves the health and readiness probes ahead of routing, so they skip the tenant, priority,
+// rate limit and logging middleware that would throttle, reject or log every probe
+func (h *Handler) probes(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Method != http.MethodGet && c.Request().Method != http.MethodHead {
+			return next(c)
+		}
+		switch c.Request().URL.Path {
+		case healthPath:
+			return c.JSON(http.StatusOK, HealthResponse{Status: "ok"})
+		case readyPath:
+			return h.handleReady(c)
+		}
+		return next(c)
+	}
+}
+
+// handleReady reports whether DynamoDB is reachable and the configured and registered tables can
+// serve reads, with 503 when one of them can't
+func (h *Handler) handleReady(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	res := HealthResponse{Status: "ready", Tables: []TableHealth{h.tableHealth(ctx, tableName)}}
+	names := make([]string, 0, len(h.tables))
+	for name := range h.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		res.Tables = append(res.Tables, h.tableHealth(ctx, name))
+	}
+
+	for _, table := range res.Tables {
+		if !servingStatuses[types.TableStatus(table.Status)] {
+			res.Status = "not_ready"
+			return c.JSON(http.StatusServiceUnavailable, res)
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) tableHealth(ctx context.Context, name string) TableHealth {
+	health := TableHealth{Name: name}
+	out, err := h.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: &name})
+	if err != nil {
+		health.Error = err.Error()
+		if ctx.Err() != nil {
+			health.Error = "DynamoDB didn't answer in time"
+		}
+		return health
+	}
+	if out.Table != nil {
+		health.Status = string(out.Table.TableStatus)
+	}
+	return health
+}
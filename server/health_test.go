@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func describedAs(status types.TableStatus) *dynamodb.DescribeTableOutput {
+	return &dynamodb.DescribeTableOutput{Table: &types.TableDescription{TableStatus: status}}
+}
+
+func serveProbe(t *testing.T, h *Handler, path string) (*httptest.ResponseRecorder, HealthResponse) {
+	e := echo.New()
+	e.Pre(h.probes)
+	// Probes are answered before the middleware of the routes
+	e.Use(func(echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error { return respondError(c, http.StatusForbidden, "Forbidden") }
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var res HealthResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return rec, res
+}
+
+func TestProbes(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DescribeTable", mock.Anything, mock.MatchedBy(func(input *dynamodb.DescribeTableInput) bool {
+		return *input.TableName == "TableName"
+	})).Return(describedAs(types.TableStatusActive), nil)
+	mockDynamoDB.On("DescribeTable", mock.Anything, mock.MatchedBy(func(input *dynamodb.DescribeTableInput) bool {
+		return *input.TableName == "Orders"
+	})).Return(describedAs(types.TableStatusUpdating), nil)
+	h := &Handler{client: mockDynamoDB, tables: map[string]*Handler{"Orders": {}}}
+
+	rec, res := serveProbe(t, h, "/healthz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", res.Status)
+
+	rec, res = serveProbe(t, h, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, HealthResponse{Status: "ready", Tables: []TableHealth{
+		{Name: "TableName", Status: "ACTIVE"},
+		{Name: "Orders", Status: "UPDATING"},
+	}}, res)
+
+	rec, _ = serveProbe(t, h, "/paginate")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestReadinessNotReady(t *testing.T) {
+	creating := new(MockDynamoDB)
+	creating.On("DescribeTable", mock.Anything, mock.Anything).Return(describedAs(types.TableStatusCreating), nil)
+	rec, res := serveProbe(t, &Handler{client: creating}, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, HealthResponse{Status: "not_ready", Tables: []TableHealth{{Name: "TableName", Status: "CREATING"}}}, res)
+
+	unreachable := new(MockDynamoDB)
+	unreachable.On("DescribeTable", mock.Anything, mock.Anything).Return((*dynamodb.DescribeTableOutput)(nil), errors.New("connection refused"))
+	rec, res = serveProbe(t, &Handler{client: unreachable}, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, HealthResponse{Status: "not_ready", Tables: []TableHealth{{Name: "TableName", Error: "connection refused"}}}, res)
+}
+
+func TestReadinessFixtures(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	rec, res := serveProbe(t, &Handler{client: client}, "/readyz")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ready", res.Status)
+}
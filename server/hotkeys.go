@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// hotKeyWindow is the period over which partition traffic is reported
+	hotKeyWindow = time.Minute
+	// hotKeyBuckets splits the window so old traffic ages out gradually
+	hotKeyBuckets    = 6
+	defaultHotKeyTop = 10
+)
+
+// PartitionTraffic is the read traffic of one partition within the window
+type PartitionTraffic struct {
+	Key         string
+	Requests    int64
+	ConsumedRCU float64
+	// Share is the percentage of all requests in the window
+	Share float64
+}
+
+// HotKeyReport lists the busiest partitions of the last window
+type HotKeyReport struct {
+	WindowSeconds int64
+	Requests      int64
+	Partitions    []PartitionTraffic
+}
+
+type trafficBucket struct {
+	start time.Time
+	keys  map[string]*PartitionTraffic
+}
+
+// HotKeyTracker counts reads per partition over a sliding window and logs partitions whose request
+// count crosses the alert threshold
+type HotKeyTracker struct {
+	mu      sync.Mutex
+	buckets [hotKeyBuckets]trafficBucket
+	// threshold is the number of requests per window that makes a partition hot; zero disables alerts
+	threshold int64
+	alerted   map[string]time.Time
+	now       func() time.Time
+}
+
+// NewHotKeyTracker creates a tracker alerting on partitions with more than threshold requests per window
+func NewHotKeyTracker(threshold int64) *HotKeyTracker {
+	return &HotKeyTracker{threshold: threshold, alerted: map[string]time.Time{}, now: time.Now}
+}
+
+// loadHotKeyTracker reads the alert threshold from HOT_KEY_REQUESTS_PER_MINUTE
+func loadHotKeyTracker() (*HotKeyTracker, error) {
+	var threshold int64
+	if v := os.Getenv("HOT_KEY_REQUESTS_PER_MINUTE"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return NewHotKeyTracker(threshold), nil
+}
+
+// bucket returns the bucket for the current time, resetting it when it belongs to an older window
+func (t *HotKeyTracker) bucket(now time.Time) *trafficBucket {
+	size := hotKeyWindow / hotKeyBuckets
+	start := now.Truncate(size)
+	b := &t.buckets[(start.UnixNano()/int64(size))%hotKeyBuckets]
+	if !b.start.Equal(start) {
+		b.start = start
+		b.keys = map[string]*PartitionTraffic{}
+	}
+	return b
+}
+
+// record accounts for one read against a partition
+func (t *HotKeyTracker) record(partition string, consumed *types.ConsumedCapacity) {
+	if partition == "" {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	b := t.bucket(now)
+	traffic := b.keys[partition]
+	if traffic == nil {
+		traffic = &PartitionTraffic{Key: partition}
+		b.keys[partition] = traffic
+	}
+	traffic.Requests++
+	traffic.ConsumedRCU += pagination.ConsumedUnits(consumed)
+
+	if t.threshold <= 0 || now.Sub(t.alerted[partition]) < hotKeyWindow {
+		return
+	}
+	if requests := t.totals(now)[partition].Requests; requests > t.threshold {
+		t.alerted[partition] = now
+		log.Printf("Hot partition %q: %d requests in the last %s", partition, requests, hotKeyWindow)
+	}
+}
+
+// totals sums the buckets that are still within the window
+func (t *HotKeyTracker) totals(now time.Time) map[string]PartitionTraffic {
+	sums := map[string]PartitionTraffic{}
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		if b.keys == nil || now.Sub(b.start) >= hotKeyWindow {
+			continue
+		}
+		for key, traffic := range b.keys {
+			sum := sums[key]
+			sum.Key = key
+			sum.Requests += traffic.Requests
+			sum.ConsumedRCU += traffic.ConsumedRCU
+			sums[key] = sum
+		}
+	}
+	return sums
+}
+
+// Report returns the top partitions by request count within the window
+func (t *HotKeyTracker) Report(top int) HotKeyReport {
+	t.mu.Lock()
+	sums := t.totals(t.now())
+	t.mu.Unlock()
+
+	report := HotKeyReport{WindowSeconds: int64(hotKeyWindow / time.Second), Partitions: []PartitionTraffic{}}
+	for _, traffic := range sums {
+		report.Requests += traffic.Requests
+		report.Partitions = append(report.Partitions, traffic)
+	}
+	for i := range report.Partitions {
+		report.Partitions[i].Share = float64(report.Partitions[i].Requests) * 100 / float64(report.Requests)
+	}
+
+	sort.Slice(report.Partitions, func(i, j int) bool {
+		a, b := report.Partitions[i], report.Partitions[j]
+		return a.Requests > b.Requests || (a.Requests == b.Requests && a.Key < b.Key)
+	})
+	if len(report.Partitions) > top {
+		report.Partitions = report.Partitions[:top]
+	}
+	return report
+}
+
+// handleHotKeys serves the hot partition report
+func (h *Handler) handleHotKeys(c echo.Context) error {
+	if h.hotKeys == nil {
+		return respondError(c, http.StatusNotFound, "Hot key tracking is disabled")
+	}
+
+	top, ok := boundedParam(c.QueryParam("top"), defaultHotKeyTop, 1000)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid top parameter")
+	}
+	report := h.hotKeys.Report(int(top))
+	for i := range report.Partitions {
+		report.Partitions[i].Key = h.exposeKey(PartitionKeyPosition, report.Partitions[i].Key)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// trackedClient records the partition and consumed capacity of every read in a HotKeyTracker
+type trackedClient struct {
+	DynamoClient
+	tracker *HotKeyTracker
+}
+
+// track wraps a client so its reads are counted
+func (t *HotKeyTracker) track(client DynamoClient) DynamoClient {
+	return &trackedClient{DynamoClient: client, tracker: t}
+}
+
+func (c *trackedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+
+	out, err := c.DynamoClient.Query(ctx, &input, optFns...)
+	if err == nil {
+		c.tracker.record(queryPartition(&input), out.ConsumedCapacity)
+	}
+	return out, err
+}
+
+func (c *trackedClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+
+	out, err := c.DynamoClient.GetItem(ctx, &input, optFns...)
+	if err == nil {
+		c.tracker.record(attributeString(input.Key[tableKeys.PartitionKey]), out.ConsumedCapacity)
+	}
+	return out, err
+}
+
+// queryPartition returns the partition key value a query is bound to
+func queryPartition(input *dynamodb.QueryInput) string {
+	if input.KeyConditionExpression == nil {
+		return ""
+	}
+	match := partitionPlaceholder.FindStringSubmatch(*input.KeyConditionExpression)
+	if match == nil {
+		return ""
+	}
+	return attributeString(input.ExpressionAttributeValues[match[1]])
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotKeyTrackerReport(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewHotKeyTracker(0)
+	tracker.now = func() time.Time { return now }
+
+	capacity := &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}
+	for i := 0; i < 3; i++ {
+		tracker.record("hot", capacity)
+	}
+	tracker.record("cold", nil)
+
+	report := tracker.Report(10)
+	assert.EqualValues(t, 4, report.Requests)
+	assert.Equal(t, []PartitionTraffic{
+		{Key: "hot", Requests: 3, ConsumedRCU: 1.5, Share: 75},
+		{Key: "cold", Requests: 1, Share: 25},
+	}, report.Partitions)
+
+	assert.Len(t, tracker.Report(1).Partitions, 1)
+
+	// Traffic ages out bucket by bucket
+	now = now.Add(30 * time.Second)
+	tracker.record("cold", nil)
+	assert.EqualValues(t, 5, tracker.Report(10).Requests)
+
+	now = now.Add(40 * time.Second)
+	report = tracker.Report(10)
+	assert.EqualValues(t, 1, report.Requests)
+	assert.Equal(t, "cold", report.Partitions[0].Key)
+
+	now = now.Add(time.Minute)
+	assert.Empty(t, tracker.Report(10).Partitions)
+}
+
+func TestHotKeyTrackerAlert(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := NewHotKeyTracker(2)
+	tracker.now = func() time.Time { return now }
+
+	tracker.record("hot", nil)
+	tracker.record("hot", nil)
+	assert.NotContains(t, tracker.alerted, "hot")
+
+	tracker.record("hot", nil)
+	assert.Equal(t, now, tracker.alerted["hot"])
+}
+
+func TestTrackedClient(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ReturnConsumedCapacity == types.ReturnConsumedCapacityTotal
+	})).Return(&dynamodb.QueryOutput{ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(1)}}, nil)
+	mockDynamoDB.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+
+	tracker := NewHotKeyTracker(0)
+	client := tracker.track(mockDynamoDB)
+
+	_, err := client.Query(context.Background(), keyConditionQuery("test"))
+	require.NoError(t, err)
+	_, err = client.GetItem(context.Background(), &dynamodb.GetItemInput{Key: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "other"},
+	}})
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/hot-keys", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{hotKeys: tracker}
+	require.NoError(t, handler.handleHotKeys(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report HotKeyReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, []PartitionTraffic{
+		{Key: "other", Requests: 1, Share: 50},
+		{Key: "test", Requests: 1, ConsumedRCU: 1, Share: 50},
+	}, report.Partitions)
+}
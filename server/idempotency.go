@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerIdempotencyKey = "Idempotency-Key"
+	headerReplayed       = "Idempotent-Replayed"
+	// idempotencyTTL is how long a response is kept for replaying retries
+	idempotencyTTL = 10 * time.Minute
+)
+
+// idempotentResponse is a recorded response, or a reservation while the first request is still running
+type idempotentResponse struct {
+	done bool
+	// bodyHash is the SHA-256 of the request body the response was recorded for
+	bodyHash []byte
+	status   int
+	header   http.Header
+	body     []byte
+	expires  time.Time
+}
+
+// IdempotencyStore remembers responses to POST requests sent with an Idempotency-Key header, so a
+// retried submission is answered from the store instead of running the job again
+type IdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+	now     func() time.Time
+}
+
+// NewIdempotencyStore creates an in-memory store keeping responses for ttl
+func NewIdempotencyStore(ttl time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{ttl: ttl, entries: map[string]*idempotentResponse{}, now: time.Now}
+}
+
+// reserve returns the recorded response for key, or reserves the key and returns nil when the
+// request should run. It reports false when another request with the key is still running.
+func (s *IdempotencyStore) reserve(key string) (*idempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, entry := range s.entries {
+		if entry.done && now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	if entry, ok := s.entries[key]; ok {
+		if !entry.done {
+			return nil, false
+		}
+		return entry, true
+	}
+	s.entries[key] = &idempotentResponse{}
+	return nil, true
+}
+
+// complete records the response for key. Server errors release the key so the request can be retried.
+func (s *IdempotencyStore) complete(key string, bodyHash []byte, status int, header http.Header, body []byte) {
+	if status >= http.StatusInternalServerError {
+		s.release(key)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[key] = &idempotentResponse{done: true, bodyHash: bodyHash, status: status, header: header, body: body, expires: s.now().Add(s.ttl)}
+}
+
+// release forgets a reserved key without recording a response
+func (s *IdempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// hashingBody hashes a request body as the handler reads it
+type hashingBody struct {
+	io.ReadCloser
+	hash hash.Hash
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	return n, err
+}
+
+// sum hashes the rest of the body the handler didn't read and returns the hash of the whole body
+func (b *hashingBody) sum() []byte {
+	_, _ = io.Copy(io.Discard, b)
+	return b.hash.Sum(nil)
+}
+
+// recordingWriter copies everything written to the response
+type recordingWriter struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware replays the recorded response for POST requests whose Idempotency-Key was seen before.
+// Keys are scoped to the fingerprint of the request; a retry arriving while the first request runs gets a 409, and
+// one with a different body than the recorded request gets a 422.
+func (s *IdempotencyStore) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(headerIdempotencyKey)
+		if c.Request().Method != http.MethodPost || key == "" {
+			return next(c)
+		}
+		key = requestFingerprint(c) + "\x00" + key
+
+		recorded, ok := s.reserve(key)
+		if !ok {
+			return respondError(c, http.StatusConflict, "A request with this Idempotency-Key is in progress")
+		}
+		body := &hashingBody{ReadCloser: c.Request().Body, hash: sha256.New()}
+		if recorded != nil {
+			if !bytes.Equal(body.sum(), recorded.bodyHash) {
+				return respondError(c, http.StatusUnprocessableEntity, "The Idempotency-Key was used with a different request body")
+			}
+			for name, values := range recorded.header {
+				c.Response().Header()[name] = values
+			}
+			c.Response().Header().Set(headerReplayed, "true")
+			c.Response().WriteHeader(recorded.status)
+			_, err := c.Response().Write(recorded.body)
+			return err
+		}
+
+		original := c.Response().Writer
+		writer := &recordingWriter{ResponseWriter: original}
+		c.Response().Writer = writer
+		c.Request().Body = body
+		completed := false
+		defer func() {
+			c.Response().Writer = original
+			// A panicking handler records nothing, so the request can be retried
+			if !completed {
+				s.release(key)
+			}
+		}()
+
+		err := next(c)
+		if err != nil {
+			c.Error(err)
+		}
+
+		status := c.Response().Status
+		if !c.Response().Committed {
+			status = http.StatusInternalServerError
+		}
+		s.complete(key, body.sum(), status, c.Response().Header().Clone(), writer.body.Bytes())
+		completed = true
+		return nil
+	}
+}
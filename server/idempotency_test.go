@@ -0,0 +1,150 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	calls := 0
+	status := http.StatusOK
+	handler := func(c echo.Context) error {
+		calls++
+		return c.String(status, "job started")
+	}
+
+	now := time.Now()
+	store := NewIdempotencyStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	e := echo.New()
+	e.POST("/jobs", handler, store.Middleware)
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+		if key != "" {
+			req.Header.Set(headerIdempotencyKey, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send("abc")
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(headerReplayed))
+
+	retry := send("abc")
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "job started", retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get(headerReplayed))
+	assert.Equal(t, 1, calls)
+
+	send("")
+	send("other")
+	assert.Equal(t, 3, calls)
+
+	now = now.Add(2 * time.Minute)
+	send("abc")
+	assert.Equal(t, 4, calls)
+
+	status = http.StatusInternalServerError
+	send("failing")
+	send("failing")
+	assert.Equal(t, 6, calls)
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	req.Header.Set(headerIdempotencyKey, "abc")
+	rec := httptest.NewRecorder()
+
+	store := NewIdempotencyStore(time.Minute)
+	_, ok := store.reserve(requestFingerprint(e.NewContext(req, rec)) + "\x00abc")
+	assert.True(t, ok)
+
+	e.POST("/jobs", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, store.Middleware)
+	e.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestIdempotencyDifferentBody(t *testing.T) {
+	calls := 0
+	store := NewIdempotencyStore(time.Minute)
+	e := echo.New()
+	e.POST("/jobs", func(c echo.Context) error {
+		calls++
+		// Only part of the body is read, the rest is still hashed
+		buf := make([]byte, 2)
+		_, _ = io.ReadFull(c.Request().Body, buf)
+		return c.NoContent(http.StatusAccepted)
+	}, store.Middleware)
+	send := func(body string) int {
+		req := httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body))
+		req.Header.Set(headerIdempotencyKey, "abc")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusAccepted, send("row 1\nrow 2"))
+	assert.Equal(t, http.StatusAccepted, send("row 1\nrow 2"))
+	assert.Equal(t, http.StatusUnprocessableEntity, send("row 1\nrow 3"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyPanicReleasesKey(t *testing.T) {
+	store := NewIdempotencyStore(time.Minute)
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/jobs", nil)
+	req.Header.Set(headerIdempotencyKey, "abc")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	writer := c.Response().Writer
+
+	handler := middleware.Recover()(store.Middleware(func(c echo.Context) error { panic("job failed") }))
+	require.NoError(t, handler(c))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, writer, c.Response().Writer)
+	_, ok := store.reserve("/jobs\x00abc")
+	assert.True(t, ok)
+}
+
+func TestIdempotencyPaginationBody(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	counter := &queryCounter{DynamoClient: client}
+	handler := &Handler{client: counter}
+
+	e := echo.New()
+	e.POST("/paginate", handler.handlePaginationBody, NewIdempotencyStore(time.Minute).Middleware)
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/paginate", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(headerIdempotencyKey, "query-1")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send(`{"key_condition": "test", "pagesize": 2}`)
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+	queries := counter.queries
+	retry := send(`{"key_condition": "test", "pagesize": 2}`)
+	assert.Equal(t, "true", retry.Header().Get(headerReplayed))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, queries, counter.queries, "replays don't query the table")
+
+	assert.Equal(t, http.StatusUnprocessableEntity, send(`{"key_condition": "test", "pagesize": 3}`).Code)
+}
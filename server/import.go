@@ -0,0 +1,325 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// batchWriteSize is the maximum number of requests BatchWriteItem accepts
+	batchWriteSize = 25
+	// importMaxAttempts bounds how often unprocessed items of a batch are retried
+	importMaxAttempts = 5
+)
+
+var (
+	// importRetryDelay is the initial backoff before retrying unprocessed items, doubled on every attempt
+	importRetryDelay = 50 * time.Millisecond
+	// importMaxBodyBytes limits the size of an import request body
+	importMaxBodyBytes int64 = 32 << 20
+)
+
+// ImportReport summarises an import request
+type ImportReport struct {
+	Rows    int
+	Written int
+	Failed  int
+	Errors  []RowError `json:",omitempty"`
+	// Duplicates are rows that weren't written because a later row of the same batch has the same key
+	Duplicates []RowError `json:",omitempty"`
+}
+
+// RowError describes why a single input row wasn't written
+type RowError struct {
+	Row     int
+	Message string
+}
+
+// importRow is a converted input row waiting to be written
+type importRow struct {
+	row  int
+	item map[string]types.AttributeValue
+}
+
+// handleImport writes NDJSON or CSV rows to the table with BatchWriteItem
+func (h *Handler) handleImport(c echo.Context) error {
+	if c.Param("table") != tableName {
+		return respondError(c, http.StatusNotFound, "Unknown table")
+	}
+
+	var report ImportReport
+	var rows []importRow
+	var err error
+
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, importMaxBodyBytes)
+	if strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), "text/csv") {
+		rows, err = readCSVRows(body, &report)
+	} else {
+		rows, err = readNDJSONRows(body, &report)
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return respondError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+	}
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	for start := 0; start < len(rows); start += batchWriteSize {
+		end := start + batchWriteSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		h.writeBatch(c, rows[start:end], &report)
+	}
+
+	if report.Written > 0 {
+		h.invalidate(c.Request().Context(), tableName, "")
+	}
+	report.Failed = len(report.Errors)
+	return c.JSON(http.StatusOK, report)
+}
+
+// writeBatch writes up to batchWriteSize rows, retrying unprocessed items with exponential backoff.
+// BatchWriteItem rejects batches that write a key twice, so only the last row of a key is written.
+// DynamoDB errors are logged, and reported on their rows with the message of their response.
+func (h *Handler) writeBatch(c echo.Context, rows []importRow, report *ImportReport) {
+	ctx := c.Request().Context()
+	rowsByKey := make(map[string]int, len(rows))
+	for _, r := range rows {
+		key := itemKeyString(r.item)
+		if previous, ok := rowsByKey[key]; ok {
+			report.Duplicates = append(report.Duplicates, RowError{Row: previous, Message: fmt.Sprintf("superseded by row %d", r.row)})
+		}
+		rowsByKey[key] = r.row
+	}
+
+	var latest []importRow
+	for _, r := range rows {
+		if rowsByKey[itemKeyString(r.item)] == r.row {
+			latest = append(latest, r)
+		}
+	}
+	versions, err := h.importVersions(ctx, latest)
+	if err != nil {
+		reqErr := dynamoError("Error reading the stored versions", err)
+		c.Logger().Error(reqErr)
+		for _, r := range latest {
+			report.Errors = append(report.Errors, RowError{Row: r.row, Message: reqErr.message})
+		}
+		return
+	}
+
+	pending := make([]types.WriteRequest, 0, len(latest))
+	for _, r := range latest {
+		version, ok := versions[importKey(r.item)]
+		if !ok {
+			report.Errors = append(report.Errors, RowError{Row: r.row, Message: "stored version unprocessed after retries"})
+			continue
+		}
+		r.item[versionAttribute] = &types.AttributeValueMemberN{Value: nextVersion(version)}
+		pending = append(pending, types.WriteRequest{PutRequest: &types.PutRequest{Item: r.item}})
+	}
+
+	delay := importRetryDelay
+	for attempt := 1; len(pending) > 0; attempt++ {
+		out, err := h.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{tableName: pending},
+		})
+		if err != nil {
+			reqErr := dynamoError("Error writing to DynamoDB", err)
+			c.Logger().Error(reqErr)
+			for _, req := range pending {
+				report.Errors = append(report.Errors, RowError{Row: rowsByKey[itemKeyString(req.PutRequest.Item)], Message: reqErr.message})
+			}
+			return
+		}
+
+		report.Written += len(pending) - len(out.UnprocessedItems[tableName])
+		pending = out.UnprocessedItems[tableName]
+		if len(pending) == 0 {
+			return
+		}
+
+		if attempt == importMaxAttempts {
+			for _, req := range pending {
+				report.Errors = append(report.Errors, RowError{Row: rowsByKey[itemKeyString(req.PutRequest.Item)], Message: "unprocessed after retries"})
+			}
+			return
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			for _, req := range pending {
+				report.Errors = append(report.Errors, RowError{Row: rowsByKey[itemKeyString(req.PutRequest.Item)], Message: "request ended before the row was written"})
+			}
+			return
+		}
+		delay *= 2
+	}
+}
+
+// importVersions reads the versions of the stored items rows replace, "0" for those that don't exist,
+// so imported items get the next version like PUTs. Keys left unprocessed are missing.
+func (h *Handler) importVersions(ctx context.Context, rows []importRow) (map[ItemKey]string, error) {
+	fields := []string{tableKeys.PartitionKey, versionAttribute}
+	if tableKeys.SortKey != "" {
+		fields = append(fields, tableKeys.SortKey)
+	}
+	b := newExpressionBuilder()
+	request := types.KeysAndAttributes{ProjectionExpression: aws.String(b.projection(fields)), ExpressionAttributeNames: b.attributeNames()}
+
+	keys := make([]ItemKey, len(rows))
+	for i, r := range rows {
+		keys[i] = importKey(r.item)
+	}
+	stored := make(map[ItemKey]map[string]types.AttributeValue, len(keys))
+	unprocessed, err := getBatch(ctx, h.client, keys, request, stored)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[ItemKey]string, len(keys))
+	for _, key := range keys {
+		versions[key] = storedVersion(stored[key])
+	}
+	for _, key := range unprocessed {
+		delete(versions, key)
+	}
+	return versions, nil
+}
+
+// importKey is the primary key of an imported item
+func importKey(item map[string]types.AttributeValue) ItemKey {
+	return ItemKey{KeyCond: attributeString(item[tableKeys.PartitionKey]), SortKey: attributeString(item[tableKeys.SortKey])}
+}
+
+// itemKeyString identifies an item by its key attributes
+func itemKeyString(item map[string]types.AttributeValue) string {
+	return attributeString(item[tableKeys.PartitionKey]) + "\x00" + attributeString(item[tableKeys.SortKey])
+}
+
+// checkImportRow makes sure a converted row has the key attributes of the table, typing them like its
+// key schema, and leaves the version to the service
+func checkImportRow(item map[string]types.AttributeValue) error {
+	if _, ok := item[versionAttribute]; ok {
+		return errors.New("the version attribute is managed by the service")
+	}
+	for _, name := range []string{tableKeys.PartitionKey, tableKeys.SortKey} {
+		if name == "" {
+			continue
+		}
+		value := attributeString(item[name])
+		if value == "" {
+			return fmt.Errorf("missing key attribute %q", name)
+		}
+		av, err := keyAttribute(tableKeys, name, value)
+		if err != nil {
+			return err
+		}
+		item[name] = av
+	}
+	return nil
+}
+
+// readNDJSONRows converts one JSON object per line into items; blank lines are ignored
+func readNDJSONRows(body io.Reader, report *ImportReport) ([]importRow, error) {
+	var rows []importRow
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		report.Rows++
+
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var doc map[string]interface{}
+		if err := decoder.Decode(&doc); err != nil || doc == nil {
+			report.Errors = append(report.Errors, RowError{Row: line, Message: "invalid JSON object"})
+			continue
+		}
+
+		item, err := attributevalue.MarshalMap(exactNumbers(doc))
+		if err == nil {
+			err = checkImportRow(item)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{row: line, item: item})
+	}
+
+	return rows, scanner.Err()
+}
+
+// readCSVRows converts CSV records into items using the header row as attribute names. Key columns are
+// typed like the key schema, other values like filter values. Empty cells are skipped.
+func readCSVRows(body io.Reader, report *ImportReport) ([]importRow, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []importRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		report.Rows++
+		if err != nil {
+			report.Errors = append(report.Errors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+		if len(record) != len(header) {
+			report.Errors = append(report.Errors, RowError{Row: line, Message: fmt.Sprintf("expected %d columns, got %d", len(header), len(record))})
+			continue
+		}
+
+		item := make(map[string]types.AttributeValue, len(header))
+		for i, name := range header {
+			if record[i] == "" {
+				continue
+			}
+			if _, ok := tableKeys.AttributeType(name); ok {
+				// checkImportRow types it
+				item[name] = &types.AttributeValueMemberS{Value: record[i]}
+				continue
+			}
+			item[name] = parseTypedValue(record[i])
+		}
+
+		if err := checkImportRow(item); err != nil {
+			report.Errors = append(report.Errors, RowError{Row: line, Message: err.Error()})
+			continue
+		}
+		rows = append(rows, importRow{row: line, item: item})
+	}
+}
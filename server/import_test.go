@@ -0,0 +1,270 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newImportContext(contentType, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/tables/"+tableName+"/import", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("table")
+	c.SetParamValues(tableName)
+	return c, rec
+}
+
+func TestHandleImportNDJSON(t *testing.T) {
+	importRetryDelay = 0
+	defer func() { importRetryDelay = 50 * time.Millisecond }()
+
+	unprocessed := types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item2"},
+	}}}
+
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems[tableName]) == 2
+	})).Return(&dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{tableName: {unprocessed}},
+	}, nil).Once()
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return len(input.RequestItems[tableName]) == 1
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+
+	body := `{"key_cond": "test", "sort_key": "item1", "count": 1}
+
+{"key_cond": "test", "sort_key": "item2"}
+{"key_cond": "test"}
+not json
+`
+	c, rec := newImportContext("application/x-ndjson", body)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleImport(c))
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, ImportReport{
+		Rows:    4,
+		Written: 2,
+		Failed:  2,
+		Errors: []RowError{
+			{Row: 4, Message: `missing key attribute "sort_key"`},
+			{Row: 5, Message: "invalid JSON object"},
+		},
+	}, report)
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandleImportCSV(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		return assert.Equal(t, []types.WriteRequest{
+			{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+				"key_cond": &types.AttributeValueMemberS{Value: "test"},
+				"sort_key": &types.AttributeValueMemberS{Value: "001"},
+				"price":    &types.AttributeValueMemberN{Value: "9.5"},
+				"version":  &types.AttributeValueMemberN{Value: "1"},
+			}}},
+		}, input.RequestItems[tableName])
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+
+	c, rec := newImportContext("text/csv", "key_cond,sort_key,price\ntest,001,9.5\ntest,002\n")
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleImport(c))
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, ImportReport{
+		Rows:    2,
+		Written: 1,
+		Failed:  1,
+		Errors:  []RowError{{Row: 3, Message: "expected 3 columns, got 2"}},
+	}, report)
+}
+
+func TestHandleImportKeyTypes(t *testing.T) {
+	withTableConfig(t)
+	ConfigureTable(Options{PartitionKey: "pk", SortKey: "sk"})
+
+	importBody := func(contentType, body string) ([]types.WriteRequest, ImportReport) {
+		var written []types.WriteRequest
+		mockDynamoDB := new(MockDynamoDB)
+		mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+		mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			written = args.Get(1).(*dynamodb.BatchWriteItemInput).RequestItems[tableName]
+		}).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+		c, rec := newImportContext(contentType, body)
+		require.NoError(t, (&Handler{client: mockDynamoDB}).handleImport(c))
+		var report ImportReport
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return written, report
+	}
+
+	// Key columns are typed by the key schema, whatever their names
+	written, _ := importBody("text/csv", "pk,sk,qty\ntest,00123,5\n")
+	require.Len(t, written, 1)
+	assert.Equal(t, map[string]types.AttributeValue{
+		"pk":      &types.AttributeValueMemberS{Value: "test"},
+		"sk":      &types.AttributeValueMemberS{Value: "00123"},
+		"qty":     &types.AttributeValueMemberN{Value: "5"},
+		"version": &types.AttributeValueMemberN{Value: "1"},
+	}, written[0].PutRequest.Item)
+	written, _ = importBody("application/x-ndjson", `{"pk": "test", "sk": 42}`+"\n")
+	require.Len(t, written, 1)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: "42"}, written[0].PutRequest.Item["sk"])
+
+	t.Setenv("SORT_KEY_TYPE", "N")
+	ConfigureTable(Options{})
+	written, report := importBody("text/csv", "pk,sk\ntest,42\ntest,abc\n")
+	require.Len(t, written, 1)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "42"}, written[0].PutRequest.Item["sk"])
+	assert.Equal(t, []RowError{{Row: 3, Message: `key attribute "sk" must be a number`}}, report.Errors)
+}
+
+func TestHandleImportDuplicateKeys(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.BatchWriteItemInput) bool {
+		requests := input.RequestItems[tableName]
+		return assert.Len(t, requests, 2) &&
+			assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, requests[1].PutRequest.Item["count"])
+	})).Return(&dynamodb.BatchWriteItemOutput{}, nil).Once()
+
+	body := `{"key_cond": "test", "sort_key": "item1", "count": 1}
+{"key_cond": "test", "sort_key": "item2"}
+{"key_cond": "test", "sort_key": "item1", "count": 3}
+`
+	c, rec := newImportContext("application/x-ndjson", body)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleImport(c))
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, ImportReport{
+		Rows:       3,
+		Written:    2,
+		Duplicates: []RowError{{Row: 1, Message: "superseded by row 3"}},
+	}, report)
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandleImportVersions(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{tableName: {{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"version":  &types.AttributeValueMemberN{Value: "4"},
+	}}}}, nil)
+	var written []types.WriteRequest
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		written = args.Get(1).(*dynamodb.BatchWriteItemInput).RequestItems[tableName]
+	}).Return(&dynamodb.BatchWriteItemOutput{}, nil)
+
+	body := `{"key_cond": "test", "sort_key": "item1"}
+{"key_cond": "test", "sort_key": "item2"}
+{"key_cond": "test", "sort_key": "item3", "version": 9}
+`
+	c, rec := newImportContext("application/x-ndjson", body)
+	require.NoError(t, (&Handler{client: mockDynamoDB}).handleImport(c))
+
+	// Replaced items get the next version, new ones the first, like PUT
+	require.Len(t, written, 2)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "5"}, written[0].PutRequest.Item["version"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "1"}, written[1].PutRequest.Item["version"])
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, []RowError{{Row: 3, Message: "the version attribute is managed by the service"}}, report.Errors)
+}
+
+func TestHandleImportCancelled(t *testing.T) {
+	importRetryDelay = time.Hour
+	defer func() { importRetryDelay = 50 * time.Millisecond }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+	unprocessed := types.WriteRequest{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+	}}}
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.Anything).Run(func(mock.Arguments) { cancel() }).Return(&dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{tableName: {unprocessed}},
+	}, nil)
+
+	c, rec := newImportContext("application/x-ndjson", `{"key_cond": "test", "sort_key": "item1"}`)
+	c.SetRequest(c.Request().WithContext(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, (&Handler{client: mockDynamoDB}).handleImport(c))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the import kept waiting to retry after its request ended")
+	}
+
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, []RowError{{Row: 1, Message: "request ended before the row was written"}}, report.Errors)
+}
+
+func TestHandleImportDynamoErrors(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("BatchGetItem", mock.Anything, mock.Anything).Return(&dynamodb.BatchGetItemOutput{}, nil)
+	mockDynamoDB.On("BatchWriteItem", mock.Anything, mock.Anything).Return((*dynamodb.BatchWriteItemOutput)(nil), &smithy.GenericAPIError{
+		Code:    "ValidationException",
+		Message: "One or more parameter values were invalid: Type mismatch for key sort_key in arn:aws:dynamodb:eu-west-1:123456789012:table/TableName",
+	})
+
+	c, rec := newImportContext("application/x-ndjson", `{"key_cond": "test", "sort_key": "item1"}`)
+	require.NoError(t, (&Handler{client: mockDynamoDB}).handleImport(c))
+
+	// The report carries a stable message, and the DynamoDB error, naming the account, is only logged
+	var report ImportReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, []RowError{{Row: 1, Message: "DynamoDB rejected the request as invalid"}}, report.Errors)
+	assert.NotContains(t, rec.Body.String(), "arn:aws")
+}
+
+func TestHandleImportBodyTooLarge(t *testing.T) {
+	importMaxBodyBytes = 16
+	defer func() { importMaxBodyBytes = 32 << 20 }()
+
+	c, rec := newImportContext("application/x-ndjson", `{"key_cond": "test", "sort_key": "item1"}`)
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handleImport(c))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	c, rec = newImportContext("text/csv", "key_cond,sort_key\ntest,item1\ntest,item2\n")
+	require.NoError(t, handler.handleImport(c))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
+
+func TestHandleImportUnknownTable(t *testing.T) {
+	c, rec := newImportContext("text/csv", "")
+	c.SetParamValues("Other")
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handleImport(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+// IndexKeys are the key attributes of a secondary index. Local secondary indexes share the table's
+// partition key, and unlike global ones support consistent reads.
+type IndexKeys struct {
+	PartitionKey string `json:"partition_key"`
+	SortKey      string `json:"sort_key"`
+	Local        bool   `json:"local,omitempty"`
+}
+
+// LoadIndexes reads the key attributes of the table's secondary indexes from a JSON file mapping index
+// names to their keys
+func LoadIndexes(path string) (map[string]pagination.KeySchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIndexes(data)
+}
+
+// ParseIndexes decodes the key attributes of secondary indexes
+func ParseIndexes(data []byte) (map[string]pagination.KeySchema, error) {
+	var indexes map[string]IndexKeys
+	if err := json.Unmarshal(data, &indexes); err != nil {
+		return nil, err
+	}
+	return indexSchemas(indexes)
+}
+
+func indexSchemas(indexes map[string]IndexKeys) (map[string]pagination.KeySchema, error) {
+	schemas := make(map[string]pagination.KeySchema, len(indexes))
+	for name, keys := range indexes {
+		if name == "" {
+			return nil, fmt.Errorf("index has no name")
+		}
+		if keys.PartitionKey == "" {
+			return nil, fmt.Errorf("index %q has no partition_key", name)
+		}
+		schemas[name] = pagination.KeySchema{PartitionKey: keys.PartitionKey, SortKey: keys.SortKey, Local: keys.Local}
+	}
+	return schemas, nil
+}
+
+// loadIndexes reads the optional secondary indexes configured through INDEXES_FILE
+func loadIndexes() (map[string]pagination.KeySchema, error) {
+	path := os.Getenv("INDEXES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadIndexes(path)
+}
+
+// keysFor returns the key attributes of the table, or of one of its configured indexes
+func (h *Handler) keysFor(index string) pagination.KeySchema {
+	if index == "" {
+		_, keys := h.schema()
+		return keys
+	}
+	return h.indexes[index]
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIndexes(t *testing.T) {
+	indexes, err := ParseIndexes([]byte(`{"by_status": {"partition_key": "status", "sort_key": "updated_at"}, "by_date": {"partition_key": "key_cond", "sort_key": "created_at"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]pagination.KeySchema{
+		"by_status": {PartitionKey: "status", SortKey: "updated_at"},
+		"by_date":   {PartitionKey: "key_cond", SortKey: "created_at"},
+	}, indexes)
+
+	indexes, err = ParseIndexes([]byte(`{"by_date": {"partition_key": "key_cond", "sort_key": "created_at", "local": true}}`))
+	require.NoError(t, err)
+	assert.True(t, indexes["by_date"].Local)
+
+	_, err = ParseIndexes([]byte(`{"by_status": {"sort_key": "updated_at"}}`))
+	assert.EqualError(t, err, `index "by_status" has no partition_key`)
+}
+
+func TestHandlePaginationIndex(t *testing.T) {
+	item := func(sk string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"key_cond":   &types.AttributeValueMemberS{Value: "test"},
+			"sort_key":   &types.AttributeValueMemberS{Value: sk},
+			"status":     &types.AttributeValueMemberS{Value: "open"},
+			"updated_at": &types.AttributeValueMemberS{Value: "2024-01-0" + sk[len(sk)-1:]},
+		}
+	}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.IndexName != nil && *input.IndexName == "by_status" &&
+			input.ExpressionAttributeNames["#pk"] == "status" &&
+			attributeString(input.ExpressionAttributeValues[":keyCond"]) == "open"
+	})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{item("item1"), item("item2")}, LastEvaluatedKey: item("item2")}, nil)
+	handler := &Handler{client: mockDynamoDB, indexes: map[string]pagination.KeySchema{"by_status": {PartitionKey: "status", SortKey: "updated_at"}}}
+
+	paginate := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := paginate("key_condition=open&index=by_status&pagesize=2&cursor=")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "test", SortKey: "item1"}, {KeyCond: "test", SortKey: "item2"}}, response.Data)
+
+	// The cursor belongs to the index partition, not to the table partition of its item
+	rec = paginate("key_condition=open&index=by_status&pagesize=2&cursor=" + url.QueryEscape(response.NextCursor))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = paginate("key_condition=closed&index=by_status&pagesize=2&cursor=" + url.QueryEscape(response.NextCursor))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	assert.Equal(t, http.StatusBadRequest, paginate("key_condition=open&index=by_owner").Code)
+
+	// Index pages follow the index's sort key
+	assert.Equal(t, http.StatusOK, paginate("key_condition=open&index=by_status&pagesize=2&orderby=-updated_at").Code)
+	assert.Equal(t, http.StatusBadRequest, paginate("key_condition=open&index=by_status&orderby=-sort_key").Code)
+}
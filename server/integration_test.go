@@ -0,0 +1,142 @@
+//go:build integration
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/elad-da/dynamopagination/fixture"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// dynamoLocalImage is the image started when DYNAMO_ENDPOINT isn't set
+const dynamoLocalImage = "amazon/dynamodb-local"
+
+// localEndpoint returns DYNAMO_ENDPOINT, or starts DynamoDB Local in Docker for the test and returns
+// its endpoint
+func localEndpoint(t *testing.T) string {
+	if endpoint := os.Getenv("DYNAMO_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	out, err := exec.Command("docker", "run", "--detach", "--rm", "--publish", "127.0.0.1::8000", dynamoLocalImage, "-jar", "DynamoDBLocal.jar", "-inMemory").Output()
+	if err != nil {
+		t.Skipf("DYNAMO_ENDPOINT isn't set and DynamoDB Local can't be started: %v", err)
+	}
+	container := strings.TrimSpace(string(out))
+	t.Cleanup(func() { exec.Command("docker", "stop", container).Run() })
+
+	out, err = exec.Command("docker", "port", container, "8000/tcp").Output()
+	require.NoError(t, err)
+	addr := strings.TrimSpace(strings.Split(string(out), "\n")[0])
+	endpoint := "http://" + addr
+	for deadline := time.Now().Add(30 * time.Second); ; {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return endpoint
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("DynamoDB Local didn't start: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+// TestIntegrationPagination seeds a table on DynamoDB Local and walks its pages through the handlers,
+// with a client configured by DYNAMO_ENDPOINT like the service's
+func TestIntegrationPagination(t *testing.T) {
+	withTableConfig(t)
+	t.Setenv("DYNAMO_ENDPOINT", localEndpoint(t))
+	client, _, _, err := newClients(Options{})
+	require.NoError(t, err)
+
+	tableName = fmt.Sprintf("integration-%d", time.Now().UnixNano())
+	tableKeys.PartitionKey, tableKeys.SortKey = "key_cond", "sort_key"
+	suite := fixture.Suite{Tables: []fixture.Table{{
+		Name:         tableName,
+		PartitionKey: tableKeys.PartitionKey,
+		SortKey:      tableKeys.SortKey,
+		Generate: []fixture.Generate{
+			{Partition: "test", Count: 25, SortKey: "item%02d", Attributes: map[string]interface{}{"status": "active"}},
+			{Partition: "other", Count: 3, SortKey: "item%02d"},
+		},
+	}}}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, suite.Seed(ctx, client.(*dynamodb.Client)))
+	t.Cleanup(func() {
+		client.(*dynamodb.Client).DeleteTable(context.Background(), &dynamodb.DeleteTableInput{TableName: &tableName})
+	})
+	handler := &Handler{client: client}
+
+	get := func(t *testing.T, query string) Response {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+	sortKeys := func(res Response) []string {
+		keys := make([]string, 0, len(res.Data))
+		for _, entry := range res.Data {
+			keys = append(keys, entry.SortKey)
+		}
+		return keys
+	}
+
+	t.Run("page numbers", func(t *testing.T) {
+		var all []string
+		for page := 1; page <= 3; page++ {
+			res := get(t, fmt.Sprintf("key_condition=test&pagesize=10&page=%d&include_count=true", page))
+			all = append(all, sortKeys(res)...)
+			require.NotNil(t, res.TotalItems)
+			assert.Equal(t, int64(25), *res.TotalItems)
+		}
+		require.Len(t, all, 25)
+		assert.Equal(t, "item01", all[0])
+		assert.Equal(t, "item25", all[24])
+		assert.Empty(t, get(t, "key_condition=test&pagesize=10&page=4").Data)
+	})
+
+	t.Run("cursors", func(t *testing.T) {
+		var all []string
+		cursor := ""
+		for i := 0; i < 5; i++ {
+			res := get(t, "key_condition=test&pagesize=10&cursor="+url.QueryEscape(cursor))
+			all = append(all, sortKeys(res)...)
+			if cursor = res.NextCursor; cursor == "" {
+				break
+			}
+		}
+		assert.Len(t, all, 25)
+		assert.Empty(t, cursor)
+	})
+
+	t.Run("descending", func(t *testing.T) {
+		res := get(t, "key_condition=test&pagesize=10&page=3&orderby=-sort_key")
+		assert.Equal(t, []string{"item05", "item04", "item03", "item02", "item01"}, sortKeys(res))
+	})
+
+	t.Run("other partition", func(t *testing.T) {
+		res := get(t, "key_condition=other&pagesize=10")
+		assert.Equal(t, []string{"item01", "item02", "item03"}, sortKeys(res))
+		assert.Empty(t, res.NextCursor)
+	})
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+)
+
+// ItemResponse wraps a single item returned by the detail endpoint
+type ItemResponse struct {
+	Data Entry
+	Meta *Meta `json:",omitempty"`
+}
+
+// parseFields splits the comma separated fields parameter into attribute names
+func parseFields(s string) []string {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// handleGetItem serves a single item by primary key through the same decoding pipeline as /paginate
+func (h *Handler) handleGetItem(c echo.Context) error {
+	client, ok := h.clientFor(c)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid region parameter")
+	}
+
+	table, keys := h.schema()
+	input := &dynamodb.GetItemInput{
+		TableName: &table,
+		Key:       schemaKey(keys, c.Param("pk"), c.Param("sk")),
+	}
+
+	if consistentStr := c.QueryParam("consistent"); consistentStr != "" {
+		consistent, err := strconv.ParseBool(consistentStr)
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, "Invalid consistent parameter")
+		}
+		input.ConsistentRead = aws.Bool(consistent)
+	}
+
+	fields := parseFields(c.QueryParam("fields"))
+	if len(fields) > 0 {
+		// Placeholder names keep reserved words and special characters safe
+		b := newExpressionBuilder()
+		input.ProjectionExpression = aws.String(b.projection(fields))
+		input.ExpressionAttributeNames = b.attributeNames()
+	}
+
+	result, err := client.GetItem(c.Request().Context(), input)
+	if err != nil {
+		reqErr := dynamoError("Error in DynamoDB query", err)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	if len(result.Item) == 0 {
+		return respondError(c, http.StatusNotFound, "Item not found")
+	}
+
+	read := fullItem
+	if len(fields) > 0 {
+		read = projectedItem
+	}
+	entry, warnings, keep, reqErr := h.decodeItem(result.Item, read)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	if !keep {
+		return respondError(c, http.StatusNotFound, "Item not found")
+	}
+
+	setETag(c, result.Item)
+	res := ItemResponse{Data: tenantFrom(c.Request().Context()).redactEntry(entry)}
+	if len(warnings) > 0 {
+		res.Meta = &Meta{Warnings: warnings}
+	}
+	return c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestHandleGetItem(t *testing.T) {
+	tests := []struct {
+		name             string
+		queryParam       string
+		expectedStatus   int
+		expectedResponse ItemResponse
+		expectedInput    func(*testing.T, *dynamodb.GetItemInput)
+		mockOutput       *dynamodb.GetItemOutput
+		mockError        error
+	}{
+		{
+			name:           "Found",
+			expectedStatus: http.StatusOK,
+			expectedResponse: ItemResponse{
+				Data: Entry{KeyCond: "test", SortKey: "item1"},
+			},
+			mockOutput: &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"key_cond": &types.AttributeValueMemberS{Value: "test"},
+				"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+			}},
+		},
+		{
+			name:           "Consistent Projection",
+			queryParam:     "consistent=true&fields=sort_key,status",
+			expectedStatus: http.StatusOK,
+			expectedResponse: ItemResponse{
+				Data: Entry{SortKey: "item1"},
+			},
+			expectedInput: func(t *testing.T, input *dynamodb.GetItemInput) {
+				assert.True(t, *input.ConsistentRead)
+				assert.Equal(t, "#n0, #n1", *input.ProjectionExpression)
+				assert.Equal(t, map[string]string{"#n0": "sort_key", "#n1": "status"}, input.ExpressionAttributeNames)
+			},
+			mockOutput: &dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+				"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+			}},
+		},
+		{
+			name:           "Not Found",
+			expectedStatus: http.StatusNotFound,
+			mockOutput:     &dynamodb.GetItemOutput{},
+		},
+		{
+			name:           "Invalid Consistent",
+			queryParam:     "consistent=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "DynamoDB Error",
+			expectedStatus: http.StatusInternalServerError,
+			mockOutput:     &dynamodb.GetItemOutput{},
+			mockError:      errors.New("DynamoDB error"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockDynamoDB := new(MockDynamoDB)
+			if test.mockOutput != nil {
+				mockDynamoDB.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+					assert.Equal(t, map[string]types.AttributeValue{
+						"key_cond": &types.AttributeValueMemberS{Value: "test"},
+						"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+					}, input.Key)
+					if test.expectedInput != nil {
+						test.expectedInput(t, input)
+					}
+					return true
+				})).Return(test.mockOutput, test.mockError)
+			}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/items/test/item1?"+test.queryParam, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("pk", "sk")
+			c.SetParamValues("test", "item1")
+
+			handler := &Handler{client: mockDynamoDB}
+			_ = handler.handleGetItem(c)
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+			if test.expectedStatus == http.StatusOK {
+				var response ItemResponse
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, test.expectedResponse, response)
+			}
+			mockDynamoDB.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package server
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// jwksTTL is how long the keys of a JWKS are used before they are fetched again
+	jwksTTL = time.Hour
+	// jwksMinRefresh spaces the fetches of a JWKS triggered by tokens signed with unknown keys
+	jwksMinRefresh = time.Minute
+	// jwtLeeway tolerates clock skew when checking the expiry and start of tokens
+	jwtLeeway = 30 * time.Second
+)
+
+// jwtAlgorithms are the signature algorithms tokens are accepted with
+var jwtAlgorithms = map[string]struct {
+	hash crypto.Hash
+	rsa  bool
+}{
+	"RS256": {crypto.SHA256, true},
+	"RS384": {crypto.SHA384, true},
+	"RS512": {crypto.SHA512, true},
+	"ES256": {crypto.SHA256, false},
+	"ES384": {crypto.SHA384, false},
+	"ES512": {crypto.SHA512, false},
+}
+
+// JWTValidator accepts JWTs signed with one of the keys of a JWKS, from an issuer and for an audience
+// when they are set. The token's sub names the caller, and its tables and roles claims, lists of
+// strings, what it may read and do like the settings of API keys.
+type JWTValidator struct {
+	// AllowAllTables accepts tokens without a tables claim, letting them read every table; they are
+	// rejected otherwise
+	AllowAllTables bool
+
+	jwksURL  string
+	issuer   string
+	audience string
+	client   *http.Client
+	now      func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// NewJWTValidator creates a validator for tokens signed with the keys served at jwksURL
+func NewJWTValidator(jwksURL, issuer, audience string) (*JWTValidator, error) {
+	if !strings.HasPrefix(jwksURL, "https://") && !strings.HasPrefix(jwksURL, "http://") {
+		return nil, fmt.Errorf("invalid JWT_JWKS_URL %q", jwksURL)
+	}
+	return &JWTValidator{jwksURL: jwksURL, issuer: issuer, audience: audience, client: &http.Client{Timeout: 5 * time.Second}, now: time.Now}, nil
+}
+
+// jwtClaims are the claims of a token the validator reads
+type jwtClaims struct {
+	Subject   string          `json:"sub"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt *float64        `json:"exp"`
+	NotBefore *float64        `json:"nbf"`
+	Tables    []string        `json:"tables"`
+	Roles     []string        `json:"roles"`
+}
+
+// Validate checks the signature and claims of a token and returns the principal it stands for.
+// Tokens must expire.
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errUnauthenticated
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	alg, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", errUnauthenticated, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errUnauthenticated
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	hash := alg.hash.New()
+	hash.Write([]byte(parts[0] + "." + parts[1]))
+	if !verifyJWTSignature(key, alg.rsa, alg.hash, hash.Sum(nil), signature) {
+		return nil, fmt.Errorf("%w: invalid signature", errUnauthenticated)
+	}
+
+	var claims jwtClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	if claims.Tables == nil && !v.AllowAllTables {
+		return nil, fmt.Errorf("%w: token has no tables claim", errUnauthenticated)
+	}
+	return &Principal{Name: claims.Subject, Tables: claims.Tables, Roles: claims.Roles}, nil
+}
+
+// checkClaims checks the expiry, start, issuer and audience of a token
+func (v *JWTValidator) checkClaims(claims jwtClaims) error {
+	now := float64(v.now().Unix())
+	if claims.ExpiresAt == nil || now > *claims.ExpiresAt+jwtLeeway.Seconds() {
+		return fmt.Errorf("%w: token has expired", errUnauthenticated)
+	}
+	if claims.NotBefore != nil && now < *claims.NotBefore-jwtLeeway.Seconds() {
+		return fmt.Errorf("%w: token isn't valid yet", errUnauthenticated)
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", errUnauthenticated)
+	}
+	if v.audience == "" {
+		return nil
+	}
+	var audiences []string
+	if err := json.Unmarshal(claims.Audience, &audiences); err != nil {
+		var audience string
+		if json.Unmarshal(claims.Audience, &audience) == nil {
+			audiences = []string{audience}
+		}
+	}
+	for _, audience := range audiences {
+		if audience == v.audience {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unexpected audience", errUnauthenticated)
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errUnauthenticated
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errUnauthenticated
+	}
+	return nil
+}
+
+func verifyJWTSignature(key crypto.PublicKey, isRSA bool, hash crypto.Hash, digest, signature []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return isRSA && rsa.VerifyPKCS1v15(k, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if isRSA || len(signature) != 2*size {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// key returns the key a token names, fetching the JWKS again when it is stale or, at most every
+// jwksMinRefresh, when it doesn't have the key
+func (v *JWTValidator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.keys[kid]
+	age := v.now().Sub(v.fetched)
+	if (ok && age < jwksTTL) || (!ok && v.keys != nil && age < jwksMinRefresh) {
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", errUnauthenticated, kid)
+		}
+		return key, nil
+	}
+
+	keys, err := v.fetch(ctx)
+	if err != nil {
+		if ok {
+			// A key that was served keeps validating tokens while the JWKS can't be fetched
+			return key, nil
+		}
+		return nil, fmt.Errorf("fetching the JWKS: %w", err)
+	}
+	v.keys, v.fetched = keys, v.now()
+	if key, ok = keys[kid]; !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", errUnauthenticated, kid)
+	}
+	return key, nil
+}
+
+// jwk is a key of a JWKS
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// fetch reads the RSA and EC signing keys of the JWKS
+func (v *JWTValidator) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("JWKS returned %s", res.Status)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, errors.New("invalid key parameter")
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point isn't on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// KeysResponse is a page of primary keys
+type KeysResponse struct {
+	Data []map[string]string
+	Page int64
+	Size int64
+	// NextCursor resumes after this page in cursor mode
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// handlePaginationKeys serves /paginate/keys, the pages of /paginate reduced to their primary keys and
+// read with a keys-only projection
+func (h *Handler) handlePaginationKeys(c echo.Context) error {
+	client, keyCond, params, wait, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if params.Select == "count" {
+		return respondError(c, http.StatusBadRequest, "Invalid select parameter")
+	}
+	params.Select = "keys_only"
+
+	if reqErr := h.preflight(c, client, params); reqErr != nil {
+		c.Logger().Warn(reqErr)
+		return reqErr.respond(c)
+	}
+
+	res, reqErr := h.fetchPageWaiting(c.Request().Context(), client, keyCond, params, wait)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+
+	keys := make([]map[string]string, len(res.Data))
+	for i, entry := range res.Data {
+		keys[i] = entry.Key()
+	}
+	return c.JSON(http.StatusOK, KeysResponse{Data: keys, Page: res.Page, Size: res.Size, NextCursor: res.NextCursor})
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationKeys(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ProjectionExpression != nil && *input.ProjectionExpression == "#pk, #sk"
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item2"}},
+		},
+	}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate/keys?key_condition=test&pagesize=2", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePaginationKeys(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"Data":[{"key_cond":"test","sort_key":"item1"},{"key_cond":"test","sort_key":"item2"}],"Page":1,"Size":2}`, rec.Body.String())
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePaginationKeysRejectsCount(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate/keys?key_condition=test&select=count", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handlePaginationKeys(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
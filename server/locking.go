@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// versionAttribute holds the item version used for optimistic locking
+	versionAttribute = "version"
+
+	headerIfMatch = "If-Match"
+	headerETag    = "ETag"
+)
+
+// versionConditions turns an If-Match header into conditions on the version attribute. "*" only
+// requires the item to exist. For a specific version it also returns that version, which the stored
+// item is expected to have.
+func versionConditions(ifMatch string) ([]Condition, string, error) {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" {
+		return nil, "", nil
+	}
+	if ifMatch == "*" {
+		return []Condition{{Attribute: tableKeys.PartitionKey, Op: "exists"}}, "", nil
+	}
+
+	raw := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || version < 0 {
+		return nil, "", errors.New("If-Match must be a version number or *")
+	}
+
+	expected := strconv.FormatInt(version, 10)
+	condition := Condition{Attribute: versionAttribute, Op: "eq", Value: &types.AttributeValueMemberN{Value: expected}}
+	return []Condition{condition}, expected, nil
+}
+
+// storedVersion returns the version of a stored item, "0" for items written before versioning
+func storedVersion(item map[string]types.AttributeValue) string {
+	if version, ok := item[versionAttribute].(*types.AttributeValueMemberN); ok {
+		return version.Value
+	}
+	return "0"
+}
+
+// nextVersion returns the version a write stores over an item at version
+func nextVersion(version string) string {
+	n, _ := strconv.ParseInt(version, 10, 64)
+	return strconv.FormatInt(n+1, 10)
+}
+
+// versionUpdate renders the SET action bumping the version of an updated item
+func versionUpdate(b *expressionBuilder) string {
+	name := b.name(versionAttribute)
+	return fmt.Sprintf("%s = if_not_exists(%s, %s) + %s", name, name,
+		b.value(&types.AttributeValueMemberN{Value: "0"}), b.value(&types.AttributeValueMemberN{Value: "1"}))
+}
+
+// setETag exposes the item version so clients can send it back in If-Match
+func setETag(c echo.Context, item map[string]types.AttributeValue) {
+	if version, ok := item[versionAttribute].(*types.AttributeValueMemberN); ok {
+		c.Response().Header().Set(headerETag, `"`+version.Value+`"`)
+	}
+}
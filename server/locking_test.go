@@ -0,0 +1,180 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		conditions []Condition
+		expected   string
+		wantErr    bool
+	}{
+		{name: "no header"},
+		{name: "any version", ifMatch: "*", conditions: []Condition{{Attribute: "key_cond", Op: "exists"}}},
+		{
+			name:       "quoted version",
+			ifMatch:    `"3"`,
+			conditions: []Condition{{Attribute: "version", Op: "eq", Value: &types.AttributeValueMemberN{Value: "3"}}},
+			expected:   "3",
+		},
+		{
+			name:       "weak validator",
+			ifMatch:    `W/"0"`,
+			conditions: []Condition{{Attribute: "version", Op: "eq", Value: &types.AttributeValueMemberN{Value: "0"}}},
+			expected:   "0",
+		},
+		{name: "not a number", ifMatch: `"abc"`, wantErr: true},
+		{name: "negative", ifMatch: "-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conditions, expected, err := versionConditions(tt.ifMatch)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.conditions, conditions)
+			assert.Equal(t, tt.expected, expected)
+		})
+	}
+}
+
+func TestHandlePutItemBumpsVersion(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, input.Item["version"]) &&
+			assert.Equal(t, "#n0 = :v0", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "2"}}, input.ExpressionAttributeValues)
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	c, rec := newItemContext(http.MethodPut, "", `{"status": "active", "version": 7}`)
+	c.Request().Header.Set(headerIfMatch, `"2"`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePutItem(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get(headerETag))
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePatchItemBumpsVersion(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return assert.Equal(t, "SET #n0 = :v0, #n1 = if_not_exists(#n1, :v1) + :v2", *input.UpdateExpression) &&
+			assert.Equal(t, "attribute_exists(#n2) AND #n1 = :v3", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberS{Value: "active"},
+				":v1": &types.AttributeValueMemberN{Value: "0"},
+				":v2": &types.AttributeValueMemberN{Value: "1"},
+				":v3": &types.AttributeValueMemberN{Value: "5"},
+			}, input.ExpressionAttributeValues)
+	})).Return(&dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"version":  &types.AttributeValueMemberN{Value: "6"},
+	}}, nil)
+
+	c, rec := newItemContext(http.MethodPatch, "", `{"status": "active"}`)
+	c.Request().Header.Set(headerIfMatch, `"5"`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePatchItem(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"6"`, rec.Header().Get(headerETag))
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePutItemWithoutIfMatchBumpsStoredVersion(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.ConsistentRead
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"version": &types.AttributeValueMemberN{Value: "4"},
+	}}, nil)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &types.AttributeValueMemberN{Value: "5"}, input.Item["version"]) &&
+			assert.Equal(t, "#n0 = :v0", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]types.AttributeValue{":v0": &types.AttributeValueMemberN{Value: "4"}}, input.ExpressionAttributeValues)
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	c, rec := newItemContext(http.MethodPut, "", `{"status": "active"}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePutItem(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"5"`, rec.Header().Get(headerETag))
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePatchItemRejectsVersionInBody(t *testing.T) {
+	c, rec := newItemContext(http.MethodPatch, "", `{"version": 9}`)
+	c.Request().Header.Set(headerIfMatch, `"5"`)
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handlePatchItem(c))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleDeleteItemVersionMismatch(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DeleteItem", mock.Anything, mock.Anything).Return((*dynamodb.DeleteItemOutput)(nil), &types.ConditionalCheckFailedException{})
+	mockDynamoDB.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"version":  &types.AttributeValueMemberN{Value: "4"},
+	}}, nil)
+
+	c, rec := newItemContext(http.MethodDelete, "", "")
+	c.Request().Header.Set(headerIfMatch, `"3"`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleDeleteItem(c))
+
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, `"4"`, rec.Header().Get(headerETag))
+	var response ConflictResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, ErrorDetail{Code: "precondition_failed", Message: "Version mismatch"}, response.Error)
+	assert.EqualValues(t, 4, response.Current["version"])
+}
+
+func TestHandleDeleteItemConditionFailedAtExpectedVersion(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DeleteItem", mock.Anything, mock.Anything).Return((*dynamodb.DeleteItemOutput)(nil), &types.ConditionalCheckFailedException{})
+	mockDynamoDB.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"status":   &types.AttributeValueMemberS{Value: "inactive"},
+		"version":  &types.AttributeValueMemberN{Value: "3"},
+	}}, nil)
+
+	// The version matches, so the status condition is what failed
+	c, rec := newItemContext(http.MethodDelete, "condition=status:eq:active", "")
+	c.Request().Header.Set(headerIfMatch, `"3"`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleDeleteItem(c))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestHandleWriteInvalidIfMatch(t *testing.T) {
+	c, rec := newItemContext(http.MethodDelete, "", "")
+	c.Request().Header.Set(headerIfMatch, "latest")
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handleDeleteItem(c))
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Invalid If-Match header", errorBody(t, rec).Message)
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+// maxWait caps how long a request may be held open waiting for new items
+const maxWait = 60 * time.Second
+
+// longPollInterval is how often the query is repeated while a request waits for new items
+var longPollInterval = time.Second
+
+// parseWait reads the wait parameter as a Go duration ("30s") or a number of seconds, capped at maxWait
+func parseWait(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	wait, err := time.ParseDuration(s)
+	if err != nil {
+		seconds, convErr := strconv.ParseInt(s, 10, 64)
+		if convErr != nil {
+			return 0, err
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+
+	if wait < 0 {
+		return 0, errors.New("negative wait")
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	return wait, nil
+}
+
+// fetchPageWaiting fetches a page and, while it is empty, repeats the query until matching items
+// arrive, the wait elapses or the client goes away
+func (h *Handler) fetchPageWaiting(ctx context.Context, client DynamoClient, keyCond string, params Params, wait time.Duration) (Response, *requestError) {
+	res, reqErr := h.fetchPage(ctx, client, keyCond, params, nil)
+	if reqErr != nil || wait <= 0 || res.Size > 0 {
+		return res, reqErr
+	}
+
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	ticker := time.NewTicker(longPollInterval)
+	defer ticker.Stop()
+
+	// Empty pages are explained once, not on every poll
+	var hints []pagination.Hint
+	if res.Meta != nil {
+		hints = res.Meta.Hints
+	}
+	params.ExplainEmpty = false
+	// Polls read DynamoDB for the items arriving, not the cached empty page
+	params.Fresh = true
+
+	for {
+		select {
+		case <-ctx.Done():
+			return withHints(res, hints), nil
+		case <-deadline.C:
+			return withHints(res, hints), nil
+		case <-ticker.C:
+		}
+
+		res, reqErr = h.fetchPage(ctx, client, keyCond, params, nil)
+		if reqErr != nil || res.Size > 0 {
+			return res, reqErr
+		}
+	}
+}
+
+// withHints adds the hints explaining an empty page to a later read of it
+func withHints(res Response, hints []pagination.Hint) Response {
+	if len(hints) == 0 {
+		return res
+	}
+	if res.Meta == nil {
+		res.Meta = &Meta{}
+	}
+	res.Meta.Hints = hints
+	return res
+}
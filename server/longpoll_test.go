@@ -0,0 +1,97 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWait(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{input: "", expected: 0},
+		{input: "30s", expected: 30 * time.Second},
+		{input: "5", expected: 5 * time.Second},
+		{input: "10m", expected: maxWait},
+		{input: "-1s", err: true},
+		{input: "soon", err: true},
+	}
+
+	for _, test := range tests {
+		wait, err := parseWait(test.input)
+		if test.err {
+			assert.Error(t, err, test.input)
+			continue
+		}
+		assert.NoError(t, err, test.input)
+		assert.Equal(t, test.expected, wait, test.input)
+	}
+}
+
+func TestHandlePaginationLongPoll(t *testing.T) {
+	longPollInterval = time.Millisecond
+	defer func() { longPollInterval = time.Second }()
+
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil).Twice()
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+		},
+	}, nil).Once()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&wait=5s", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(c))
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, Response{Data: []Entry{{KeyCond: "test", SortKey: "item1"}}, Page: 1, Size: 1}, response)
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePaginationLongPollExplainEmpty(t *testing.T) {
+	longPollInterval = time.Millisecond
+	defer func() { longPollInterval = time.Second }()
+
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&wait=20ms&explain_empty=true", nil)
+	rec := httptest.NewRecorder()
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotNil(t, response.Meta)
+	assert.Equal(t, []pagination.Hint{{Code: "partition_empty", Message: "No items in the table have this key_condition"}}, response.Meta.Hints)
+
+	// The partition is checked once, after the first page, not after every poll
+	counts := 0
+	for _, call := range mockDynamoDB.Calls {
+		if call.Arguments.Get(1).(*dynamodb.QueryInput).Select == types.SelectCount {
+			counts++
+		}
+	}
+	assert.Equal(t, 1, counts)
+}
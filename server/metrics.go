@@ -0,0 +1,357 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the latency histograms
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricFamily is a metric and its series, one per combination of label values
+type metricFamily struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	series  map[string]*metricSeries
+}
+
+type metricSeries struct {
+	values []string
+	// value is the total of a counter, and the sum of a histogram's observations
+	value  float64
+	count  uint64
+	counts []uint64
+}
+
+// Metrics counts the requests the service serves and the DynamoDB calls it makes, and exposes them in
+// the Prometheus text format
+type Metrics struct {
+	mu       sync.Mutex
+	families []*metricFamily
+
+	requests      *metricFamily
+	requestTime   *metricFamily
+	calls         *metricFamily
+	callTime      *metricFamily
+	retries       *metricFamily
+	itemsReturned *metricFamily
+	capacity      *metricFamily
+}
+
+// NewMetrics creates the service's metrics, all at zero
+func NewMetrics() *Metrics {
+	m := &Metrics{}
+	m.requests = m.family("dynamopagination_http_requests_total", "HTTP requests served, by route, method and status.", "counter", nil, "route", "method", "status")
+	m.requestTime = m.family("dynamopagination_http_request_duration_seconds", "Time spent serving HTTP requests, by route and method.", "histogram", latencyBuckets, "route", "method")
+	m.calls = m.family("dynamopagination_dynamodb_calls_total", "DynamoDB calls made, each retry included, by table, operation and outcome.", "counter", nil, "table", "operation", "outcome")
+	m.callTime = m.family("dynamopagination_dynamodb_call_duration_seconds", "Latency of DynamoDB calls, by table and operation.", "histogram", latencyBuckets, "table", "operation")
+	m.retries = m.family("dynamopagination_dynamodb_retries_total", "DynamoDB calls retried, by table, operation and reason (throttled or transient).", "counter", nil, "table", "operation", "reason")
+	m.itemsReturned = m.family("dynamopagination_dynamodb_items_returned_total", "Items returned by DynamoDB reads, by table and operation.", "counter", nil, "table", "operation")
+	m.capacity = m.family("dynamopagination_dynamodb_consumed_capacity_units_total", "Capacity units consumed by DynamoDB calls, by table and operation.", "counter", nil, "table", "operation")
+	return m
+}
+
+func (m *Metrics) family(name, help, kind string, buckets []float64, labels ...string) *metricFamily {
+	f := &metricFamily{name: name, help: help, kind: kind, labels: labels, buckets: buckets, series: map[string]*metricSeries{}}
+	m.families = append(m.families, f)
+	return f
+}
+
+// get returns the series of a family with the given label values, creating it. The caller holds m.mu.
+func (f *metricFamily) get(values []string) *metricSeries {
+	key := strings.Join(values, "\xff")
+	s := f.series[key]
+	if s == nil {
+		s = &metricSeries{values: values}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// add adds delta to a counter. A nil Metrics does nothing.
+func (m *Metrics) add(f *metricFamily, delta float64, values ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f.get(values).value += delta
+}
+
+// observe records an observation of a histogram. A nil Metrics does nothing.
+func (m *Metrics) observe(f *metricFamily, v float64, values ...string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := f.get(values)
+	s.value += v
+	s.count++
+	for i, bound := range f.buckets {
+		if v <= bound {
+			s.counts[i]++
+		}
+	}
+}
+
+// retried counts a retry of a failed DynamoDB call
+func (m *Metrics) retried(table, operation string, err error) {
+	if m == nil {
+		return
+	}
+	reason := "transient"
+	if isThrottled(err) {
+		reason = "throttled"
+	}
+	m.add(m.retries, 1, table, operation, reason)
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (m *Metrics) Write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range m.families {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			s := f.series[key]
+			if f.buckets == nil {
+				fmt.Fprintf(b, "%s%s %s\n", f.name, formatLabels(f.labels, s.values, ""), formatMetricValue(s.value))
+				continue
+			}
+			for i, bound := range f.buckets {
+				fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.values, formatMetricValue(bound)), s.counts[i])
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", f.name, formatLabels(f.labels, s.values, "+Inf"), s.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", f.name, formatLabels(f.labels, s.values, ""), formatMetricValue(s.value))
+			fmt.Fprintf(b, "%s_count%s %d\n", f.name, formatLabels(f.labels, s.values, ""), s.count)
+		}
+	}
+}
+
+// formatLabels formats a series' labels, with the le label of a histogram bucket when le isn't empty
+func formatLabels(names, values []string, le string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	if le != "" {
+		pairs = append(pairs, `le="`+le+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatMetricValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Handle serves the metrics to a Prometheus scrape
+func (m *Metrics) Handle(c echo.Context) error {
+	var b strings.Builder
+	m.Write(&b)
+	return c.Blob(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// Middleware counts and times the requests served, by the route they matched
+func (m *Metrics) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+
+		status := c.Response().Status
+		var httpErr *echo.HTTPError
+		if errors.As(err, &httpErr) {
+			status = httpErr.Code
+		} else if err != nil {
+			status = http.StatusInternalServerError
+		}
+		route := c.Path()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request().Method
+		m.add(m.requests, 1, route, method, strconv.Itoa(status))
+		m.observe(m.requestTime, time.Since(start).Seconds(), route, method)
+		return err
+	}
+}
+
+// measuredClient records the outcome, latency, items and consumed capacity of every DynamoDB call
+type measuredClient struct {
+	DynamoClient
+	metrics *Metrics
+}
+
+// measure wraps a client so its calls are measured
+func (m *Metrics) measure(client DynamoClient) DynamoClient {
+	return &measuredClient{DynamoClient: client, metrics: m}
+}
+
+// record records a finished call
+func (c *measuredClient) record(table, operation string, start time.Time, err error, items int32, consumed ...types.ConsumedCapacity) {
+	outcome := "ok"
+	if isThrottled(err) {
+		outcome = "throttled"
+	} else if err != nil {
+		outcome = "error"
+	}
+	c.metrics.add(c.metrics.calls, 1, table, operation, outcome)
+	c.metrics.observe(c.metrics.callTime, time.Since(start).Seconds(), table, operation)
+	if err != nil {
+		return
+	}
+	if items > 0 {
+		c.metrics.add(c.metrics.itemsReturned, float64(items), table, operation)
+	}
+	for i := range consumed {
+		if units := pagination.ConsumedUnits(&consumed[i]); units > 0 {
+			name := table
+			if consumed[i].TableName != nil {
+				name = *consumed[i].TableName
+			}
+			c.metrics.add(c.metrics.capacity, units, name, operation)
+		}
+	}
+}
+
+// capacities lists the consumed capacity of a call, which DynamoDB reports when asked to
+func capacities(consumed *types.ConsumedCapacity) []types.ConsumedCapacity {
+	if consumed == nil {
+		return nil
+	}
+	return []types.ConsumedCapacity{*consumed}
+}
+
+func (c *measuredClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+
+	start := time.Now()
+	out, err := c.DynamoClient.Query(ctx, &input, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(input.TableName), "Query", start, err, 0)
+		return out, err
+	}
+	c.record(aws.StringValue(input.TableName), "Query", start, nil, out.Count, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+
+	start := time.Now()
+	out, err := c.DynamoClient.Scan(ctx, &input, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(input.TableName), "Scan", start, err, 0)
+		return out, err
+	}
+	c.record(aws.StringValue(input.TableName), "Scan", start, nil, out.Count, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	input := *params
+	input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+
+	start := time.Now()
+	out, err := c.DynamoClient.GetItem(ctx, &input, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(input.TableName), "GetItem", start, err, 0)
+		return out, err
+	}
+	var items int32
+	if out.Item != nil {
+		items = 1
+	}
+	c.record(aws.StringValue(input.TableName), "GetItem", start, nil, items, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	start := time.Now()
+	out, err := c.DynamoClient.PutItem(ctx, params, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(params.TableName), "PutItem", start, err, 0)
+		return out, err
+	}
+	c.record(aws.StringValue(params.TableName), "PutItem", start, nil, 0, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	start := time.Now()
+	out, err := c.DynamoClient.UpdateItem(ctx, params, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(params.TableName), "UpdateItem", start, err, 0)
+		return out, err
+	}
+	c.record(aws.StringValue(params.TableName), "UpdateItem", start, nil, 0, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	start := time.Now()
+	out, err := c.DynamoClient.DeleteItem(ctx, params, optFns...)
+	if err != nil {
+		c.record(aws.StringValue(params.TableName), "DeleteItem", start, err, 0)
+		return out, err
+	}
+	c.record(aws.StringValue(params.TableName), "DeleteItem", start, nil, 0, capacities(out.ConsumedCapacity)...)
+	return out, nil
+}
+
+func (c *measuredClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	// Batches of one table, as the service writes them, are recorded under it
+	var table string
+	if len(params.RequestItems) == 1 {
+		for name := range params.RequestItems {
+			table = name
+		}
+	}
+	start := time.Now()
+	out, err := c.DynamoClient.BatchWriteItem(ctx, params, optFns...)
+	if err != nil {
+		c.record(table, "BatchWriteItem", start, err, 0)
+		return out, err
+	}
+	c.record(table, "BatchWriteItem", start, nil, 0, out.ConsumedCapacity...)
+	return out, nil
+}
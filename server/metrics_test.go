@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	require.NoError(t, m.Handle(e.NewContext(httptest.NewRequest(http.MethodGet, "/metrics", nil), rec)))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	return rec.Body.String()
+}
+
+func TestMetricsDynamoCalls(t *testing.T) {
+	throttled := &types.ProvisionedThroughputExceededException{Message: aws.String("Rate exceeded")}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ReturnConsumedCapacity == types.ReturnConsumedCapacityTotal
+	})).Return((*dynamodb.QueryOutput)(nil), throttled).Once()
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+		Count:            3,
+		ConsumedCapacity: &types.ConsumedCapacity{TableName: aws.String("TableName"), CapacityUnits: aws.Float64(1.5)},
+	}, nil).Once()
+
+	metrics := NewMetrics()
+	policy := testRetryPolicy(3)
+	policy.metrics = metrics
+	client := policy.retry(metrics.measure(mockDynamoDB))
+
+	input := keyConditionQuery("test")
+	input.TableName = aws.String("TableName")
+	_, err := client.Query(context.Background(), input)
+	require.NoError(t, err)
+	// The caller's input is left as it was
+	assert.Empty(t, input.ReturnConsumedCapacity)
+
+	out := scrape(t, metrics)
+	for _, line := range []string{
+		`dynamopagination_dynamodb_calls_total{table="TableName",operation="Query",outcome="ok"} 1`,
+		`dynamopagination_dynamodb_calls_total{table="TableName",operation="Query",outcome="throttled"} 1`,
+		`dynamopagination_dynamodb_retries_total{table="TableName",operation="Query",reason="throttled"} 1`,
+		`dynamopagination_dynamodb_items_returned_total{table="TableName",operation="Query"} 3`,
+		`dynamopagination_dynamodb_consumed_capacity_units_total{table="TableName",operation="Query"} 1.5`,
+		`dynamopagination_dynamodb_call_duration_seconds_bucket{table="TableName",operation="Query",le="+Inf"} 2`,
+		`dynamopagination_dynamodb_call_duration_seconds_count{table="TableName",operation="Query"} 2`,
+		"# TYPE dynamopagination_dynamodb_call_duration_seconds histogram",
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	metrics := NewMetrics()
+	e := echo.New()
+	e.Use(metrics.Middleware)
+	e.GET("/items/:pk/:sk", func(c echo.Context) error { return c.String(http.StatusNotFound, "Item not found") })
+	e.GET("/fail", func(c echo.Context) error { return echo.NewHTTPError(http.StatusTeapot) })
+	e.GET("/metrics", metrics.Handle)
+
+	for _, target := range []string{"/items/a/b", "/items/c/d", "/fail"} {
+		e.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	out := scrape(t, metrics)
+	assert.Contains(t, out, `dynamopagination_http_requests_total{route="/items/:pk/:sk",method="GET",status="404"} 2`+"\n")
+	assert.Contains(t, out, `dynamopagination_http_requests_total{route="/fail",method="GET",status="418"} 1`+"\n")
+	assert.Contains(t, out, `dynamopagination_http_request_duration_seconds_count{route="/items/:pk/:sk",method="GET"} 2`+"\n")
+	// Families are exposed in a stable order, even before they have series
+	assert.Less(t, strings.Index(out, "dynamopagination_http_requests_total"), strings.Index(out, "dynamopagination_dynamodb_calls_total"))
+	assert.Contains(t, out, "# TYPE dynamopagination_dynamodb_retries_total counter\n")
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, `{table="a\"b\\c\nd",le="0.5"}`, formatLabels([]string{"table"}, []string{"a\"b\\c\nd"}, "0.5"))
+	assert.Equal(t, "", formatLabels(nil, nil, ""))
+}
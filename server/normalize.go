@@ -0,0 +1,172 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// timestampLayouts are the formats recognised when unifying timestamp attributes
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// NormalizationRule describes how a single attribute is cleaned up before it is served
+type NormalizationRule struct {
+	Attribute string `json:"attribute"`
+	// Default is stored in the item when the attribute is missing
+	Default interface{} `json:"default,omitempty"`
+	// Trim removes leading and trailing whitespace from string values
+	Trim bool `json:"trim,omitempty"`
+	// Case is either "lower" or "upper"
+	Case string `json:"case,omitempty"`
+	// Timestamp is the output format for timestamp values: "rfc3339", "unix", "unix_ms" or a Go layout
+	Timestamp string `json:"timestamp,omitempty"`
+
+	defaultValue types.AttributeValue
+}
+
+// Normalizer applies a table's normalization rules to raw DynamoDB items
+type Normalizer struct {
+	Rules []NormalizationRule `json:"rules"`
+}
+
+// LoadNormalizer reads normalization rules from a JSON file
+func LoadNormalizer(path string) (*Normalizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNormalizer(data)
+}
+
+// ParseNormalizer decodes normalization rules and checks that they are usable
+func ParseNormalizer(data []byte) (*Normalizer, error) {
+	var n Normalizer
+	if err := json.Unmarshal(data, &n); err != nil {
+		return nil, err
+	}
+
+	for i := range n.Rules {
+		rule := &n.Rules[i]
+		if rule.Attribute == "" {
+			return nil, fmt.Errorf("normalization rule %d has no attribute", i)
+		}
+		switch rule.Case {
+		case "", "lower", "upper":
+		default:
+			return nil, fmt.Errorf("attribute %q: unknown case %q", rule.Attribute, rule.Case)
+		}
+		if rule.Default != nil {
+			av, err := attributevalue.Marshal(rule.Default)
+			if err != nil {
+				return nil, fmt.Errorf("attribute %q: invalid default: %w", rule.Attribute, err)
+			}
+			rule.defaultValue = av
+		}
+	}
+
+	return &n, nil
+}
+
+// Apply returns a copy of the item with every rule applied
+func (n *Normalizer) Apply(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	out := make(map[string]types.AttributeValue, len(item))
+	for k, v := range item {
+		out[k] = v
+	}
+
+	for _, rule := range n.Rules {
+		value, ok := out[rule.Attribute]
+		if !ok {
+			if rule.defaultValue != nil {
+				out[rule.Attribute] = rule.defaultValue
+			}
+			continue
+		}
+
+		out[rule.Attribute] = rule.apply(value)
+	}
+
+	return out
+}
+
+func (r NormalizationRule) apply(value types.AttributeValue) types.AttributeValue {
+	if r.Timestamp != "" {
+		// Values that can't be read as timestamps are served as stored
+		if t, err := parseTimestamp(value); err == nil {
+			value = formatTimestamp(t, r.Timestamp)
+		}
+	}
+
+	s, ok := value.(*types.AttributeValueMemberS)
+	if !ok {
+		return value
+	}
+
+	str := s.Value
+	if r.Trim {
+		str = strings.TrimSpace(str)
+	}
+	switch r.Case {
+	case "lower":
+		str = strings.ToLower(str)
+	case "upper":
+		str = strings.ToUpper(str)
+	}
+
+	return &types.AttributeValueMemberS{Value: str}
+}
+
+// parseTimestamp reads a timestamp stored either as a formatted string or as epoch seconds/milliseconds
+func parseTimestamp(value types.AttributeValue) (time.Time, error) {
+	var raw string
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		raw = strings.TrimSpace(v.Value)
+	case *types.AttributeValueMemberN:
+		raw = v.Value
+	default:
+		return time.Time{}, fmt.Errorf("unsupported timestamp type %T", value)
+	}
+
+	if epoch, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		// Values this large can only be milliseconds
+		if epoch > 1e12 || epoch < -1e12 {
+			return time.UnixMilli(epoch).UTC(), nil
+		}
+		return time.Unix(epoch, 0).UTC(), nil
+	}
+
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC(), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", raw)
+}
+
+func formatTimestamp(t time.Time, format string) types.AttributeValue {
+	switch format {
+	case "unix":
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.Unix(), 10)}
+	case "unix_ms":
+		return &types.AttributeValueMemberN{Value: strconv.FormatInt(t.UnixMilli(), 10)}
+	case "rfc3339":
+		format = time.RFC3339
+	}
+	return &types.AttributeValueMemberS{Value: t.Format(format)}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizerApply(t *testing.T) {
+	normalizer, err := ParseNormalizer([]byte(`{"rules": [
+		{"attribute": "sort_key", "trim": true, "case": "lower"},
+		{"attribute": "status", "default": "unknown"},
+		{"attribute": "created_at", "timestamp": "rfc3339"},
+		{"attribute": "updated_at", "timestamp": "unix"}
+	]}`))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		item     map[string]types.AttributeValue
+		expected map[string]types.AttributeValue
+	}{
+		{
+			name: "Trim Case And Default",
+			item: map[string]types.AttributeValue{
+				"sort_key": &types.AttributeValueMemberS{Value: "  Item1 "},
+			},
+			expected: map[string]types.AttributeValue{
+				"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+				"status":   &types.AttributeValueMemberS{Value: "unknown"},
+			},
+		},
+		{
+			name: "Timestamps",
+			item: map[string]types.AttributeValue{
+				"status":     &types.AttributeValueMemberS{Value: "active"},
+				"created_at": &types.AttributeValueMemberN{Value: "1700000000000"},
+				"updated_at": &types.AttributeValueMemberS{Value: "2023-11-14 22:13:20"},
+			},
+			expected: map[string]types.AttributeValue{
+				"status":     &types.AttributeValueMemberS{Value: "active"},
+				"created_at": &types.AttributeValueMemberS{Value: "2023-11-14T22:13:20Z"},
+				"updated_at": &types.AttributeValueMemberN{Value: "1700000000"},
+			},
+		},
+		{
+			name: "Unparseable Timestamp Is Kept",
+			item: map[string]types.AttributeValue{
+				"status":     &types.AttributeValueMemberS{Value: "active"},
+				"created_at": &types.AttributeValueMemberS{Value: "yesterday"},
+			},
+			expected: map[string]types.AttributeValue{
+				"status":     &types.AttributeValueMemberS{Value: "active"},
+				"created_at": &types.AttributeValueMemberS{Value: "yesterday"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, normalizer.Apply(test.item))
+		})
+	}
+}
+
+func TestParseNormalizerInvalidCase(t *testing.T) {
+	_, err := ParseNormalizer([]byte(`{"rules": [{"attribute": "sort_key", "case": "title"}]}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// loadNumberEncoding reads NUMBER_ENCODING, which serves DynamoDB numbers as exact JSON numbers
+// ("number", the default) or as JSON strings ("string") for clients that read JSON numbers as doubles
+func loadNumberEncoding() (bool, error) {
+	switch encoding := os.Getenv("NUMBER_ENCODING"); encoding {
+	case "", "number":
+		return false, nil
+	case "string":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown NUMBER_ENCODING %q", encoding)
+	}
+}
+
+// decodeAttributes converts raw attributes into plain values for a response. Numbers keep all their
+// digits, as JSON numbers or, with asStrings, as strings.
+func decodeAttributes(item map[string]types.AttributeValue, asStrings bool) (map[string]interface{}, error) {
+	if len(item) == 0 {
+		return nil, nil
+	}
+	var out map[string]interface{}
+	err := attributevalue.UnmarshalMapWithOptions(item, &out, func(o *attributevalue.DecoderOptions) { o.UseNumber = true })
+	if err != nil {
+		return nil, err
+	}
+	return servedNumbers(out, asStrings).(map[string]interface{}), nil
+}
+
+// servedNumbers replaces the exact numbers of decoded values by their served form: json.Number, which
+// encodes with all its digits, or a string
+func servedNumbers(v interface{}, asStrings bool) interface{} {
+	switch val := v.(type) {
+	case attributevalue.Number:
+		return servedNumber(string(val), asStrings)
+	case json.Number:
+		return servedNumber(string(val), asStrings)
+	case []attributevalue.Number:
+		out := make([]interface{}, len(val))
+		for i, n := range val {
+			out[i] = servedNumber(string(n), asStrings)
+		}
+		return out
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = servedNumbers(e, asStrings)
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = servedNumbers(e, asStrings)
+		}
+	}
+	return v
+}
+
+func servedNumber(n string, asString bool) interface{} {
+	if asString {
+		return n
+	}
+	return json.Number(n)
+}
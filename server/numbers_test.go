@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeAttributesExactNumbers(t *testing.T) {
+	item := map[string]types.AttributeValue{
+		"id":     &types.AttributeValueMemberN{Value: "9007199254740993"},
+		"amount": &types.AttributeValueMemberN{Value: "1234567890.123456789"},
+		"ids":    &types.AttributeValueMemberNS{Value: []string{"12345678901234567890"}},
+		"nested": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"list": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "18446744073709551615"}}},
+		}},
+		"name": &types.AttributeValueMemberS{Value: "item1"},
+	}
+
+	values, err := decodeAttributes(item, false)
+	require.NoError(t, err)
+	data, err := json.Marshal(values)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": 9007199254740993, "amount": 1234567890.123456789, "ids": [12345678901234567890], "nested": {"list": [18446744073709551615]}, "name": "item1"}`, string(data))
+	assert.Contains(t, string(data), `"id":9007199254740993`)
+
+	values, err = decodeAttributes(item, true)
+	require.NoError(t, err)
+	data, err = json.Marshal(values)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id": "9007199254740993", "amount": "1234567890.123456789", "ids": ["12345678901234567890"], "nested": {"list": ["18446744073709551615"]}, "name": "item1"}`, string(data))
+
+	values, err = decodeAttributes(nil, true)
+	require.NoError(t, err)
+	assert.Nil(t, values)
+}
+
+func TestLoadNumberEncoding(t *testing.T) {
+	asStrings, err := loadNumberEncoding()
+	require.NoError(t, err)
+	assert.False(t, asStrings)
+
+	t.Setenv("NUMBER_ENCODING", "string")
+	asStrings, err = loadNumberEncoding()
+	require.NoError(t, err)
+	assert.True(t, asStrings)
+
+	t.Setenv("NUMBER_ENCODING", "float")
+	_, err = loadNumberEncoding()
+	assert.Error(t, err)
+}
+
+func TestLoadFixtureClientExactNumbers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"partition_key": "key_cond",
+		"sort_key": "sort_key",
+		"items": [{"key_cond": "test", "sort_key": "item1", "id": 9007199254740993, "price": 0.10000000000000000001}]
+	}`), 0o600))
+
+	client, err := LoadFixtureClient(path)
+	require.NoError(t, err)
+	require.Len(t, client.items, 1)
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "9007199254740993"}, client.items[0]["id"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "0.10000000000000000001"}, client.items[0]["price"])
+}
+
+func TestDecodeItemExactComputedNumbers(t *testing.T) {
+	fields, err := ParseComputedFields([]byte(`{"TableName": [{"name": "id", "expression": "account_id"}, {"name": "double", "expression": "account_id * 2"}]}`), "TableName")
+	require.NoError(t, err)
+	item := map[string]types.AttributeValue{
+		"key_cond":   &types.AttributeValueMemberS{Value: "test"},
+		"sort_key":   &types.AttributeValueMemberS{Value: "item1"},
+		"account_id": &types.AttributeValueMemberN{Value: "9007199254740993"},
+	}
+
+	// Bare attributes keep their digits; arithmetic is done on doubles
+	handler := &Handler{computed: fields}
+	entry, _, _, reqErr := handler.decodeItem(item, fullItem)
+	require.Nil(t, reqErr)
+	assert.Equal(t, map[string]interface{}{"id": json.Number("9007199254740993"), "double": 18014398509481986.0}, entry.Computed)
+
+	handler.numbersAsStrings = true
+	entry, _, _, reqErr = handler.decodeItem(item, fullItem)
+	require.Nil(t, reqErr)
+	assert.Equal(t, "9007199254740993", entry.Computed["id"])
+}
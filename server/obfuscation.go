@@ -0,0 +1,190 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/labstack/echo/v4"
+)
+
+var errInvalidKeyID = errors.New("key ID isn't valid")
+
+// KeyPosition is where a key value is served: the ID of a value in one position must not be
+// accepted, nor be recognizable, in another
+type KeyPosition string
+
+const (
+	PartitionKeyPosition KeyPosition = "partition key"
+	SortKeyPosition      KeyPosition = "sort key"
+	// CursorPosition is the position of cursors, which are obfuscated as a whole
+	CursorPosition KeyPosition = "cursor"
+)
+
+// KeyObfuscator turns the primary key values served to clients into opaque IDs and back, so they
+// don't learn the internal format of the keys. Encode must be deterministic: the same value always
+// gets the same ID in a position, which clients compare and look items up with.
+type KeyObfuscator interface {
+	Encode(position KeyPosition, value string) string
+	Decode(position KeyPosition, id string) (string, error)
+}
+
+// AEADObfuscator encrypts key values with AES-GCM under a nonce derived from the value and its
+// position, which makes the IDs deterministic while keeping them unforgeable. The position is
+// authenticated along with the value, so an ID only decodes in its position. IDs are base64url encoded.
+type AEADObfuscator struct {
+	nonces []byte
+	aead   cipher.AEAD
+}
+
+// NewAEADObfuscator creates an obfuscator whose keys are derived from secret
+func NewAEADObfuscator(secret string) (*AEADObfuscator, error) {
+	if secret == "" {
+		return nil, errors.New("empty key obfuscation secret")
+	}
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(purpose))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("key encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AEADObfuscator{nonces: derive("key nonces"), aead: aead}, nil
+}
+
+// loadKeyObfuscator obfuscates the keys served with KEY_OBFUSCATION_SECRET when it is set
+func loadKeyObfuscator() (KeyObfuscator, error) {
+	secret := os.Getenv("KEY_OBFUSCATION_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+	return NewAEADObfuscator(secret)
+}
+
+// Encode returns the ID of a key value in a position
+func (o *AEADObfuscator) Encode(position KeyPosition, value string) string {
+	mac := hmac.New(sha256.New, o.nonces)
+	mac.Write([]byte(position))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	nonce := mac.Sum(nil)[:o.aead.NonceSize()]
+	return base64.RawURLEncoding.EncodeToString(o.aead.Seal(nonce, nonce, []byte(value), []byte(position)))
+}
+
+// Decode returns the key value of an ID, failing for IDs it didn't encode in that position
+func (o *AEADObfuscator) Decode(position KeyPosition, id string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(data) < o.aead.NonceSize() {
+		return "", errInvalidKeyID
+	}
+	nonce := data[:o.aead.NonceSize()]
+	value, err := o.aead.Open(nil, nonce, data[len(nonce):], []byte(position))
+	if err != nil {
+		return "", errInvalidKeyID
+	}
+	return string(value), nil
+}
+
+// exposeKey returns the ID served for a key value, the value itself without obfuscation
+func (h *Handler) exposeKey(position KeyPosition, value string) string {
+	if h.keyIDs == nil || value == "" {
+		return value
+	}
+	return h.keyIDs.Encode(position, value)
+}
+
+// resolveKey returns the key value of an ID sent by a client
+func (h *Handler) resolveKey(position KeyPosition, id string) (string, error) {
+	if h.keyIDs == nil || id == "" {
+		return id, nil
+	}
+	return h.keyIDs.Decode(position, id)
+}
+
+// queryPartition returns the partition named by the key_condition parameter, as stored
+func (h *Handler) queryPartition(c echo.Context) (string, *requestError) {
+	keyCond, err := h.resolveKey(PartitionKeyPosition, c.QueryParam("key_condition"))
+	if err != nil {
+		return "", &requestError{status: http.StatusBadRequest, message: "Invalid key_condition parameter", err: err}
+	}
+	return keyCond, nil
+}
+
+// checkHiddenKeys rejects the search, sort key conditions and filters of a read that compare the key
+// attributes as stored: clients could probe the values their IDs hide with them
+func (h *Handler) checkHiddenKeys(params Params) *requestError {
+	if h.keyIDs == nil {
+		return nil
+	}
+	_, keys := h.schema()
+	hidden := func(name string) bool {
+		return name != "" && (name == keys.PartitionKey || name == keys.SortKey)
+	}
+	if (params.Search != "" || params.SortRange != nil) && hidden(h.keysFor(params.IndexName).SortKey) {
+		return &requestError{status: http.StatusBadRequest, message: "Search and sort key conditions can't be used with key obfuscation"}
+	}
+	if params.Filter != nil {
+		for _, attribute := range params.Filter.Attributes {
+			if hidden(attribute) {
+				return &requestError{status: http.StatusBadRequest, message: "Invalid filters: key attributes can't be filtered on with key obfuscation"}
+			}
+		}
+	}
+	return nil
+}
+
+// exposeEntry replaces the keys of an entry by their IDs
+func (h *Handler) exposeEntry(entry *Entry) {
+	entry.KeyCond = h.exposeKey(PartitionKeyPosition, entry.KeyCond)
+	entry.SortKey = h.exposeKey(SortKeyPosition, entry.SortKey)
+}
+
+// exposeAttributes replaces the key attributes of a decoded item by their IDs
+func (h *Handler) exposeAttributes(item map[string]interface{}) {
+	if h.keyIDs == nil {
+		return
+	}
+	_, keys := h.schema()
+	for name, position := range map[string]KeyPosition{keys.PartitionKey: PartitionKeyPosition, keys.SortKey: SortKeyPosition} {
+		if value, ok := item[name].(string); ok && name != "" {
+			item[name] = h.exposeKey(position, value)
+		}
+	}
+}
+
+// resolveItemKey replaces the IDs in the path of the item routes by the key values they stand for,
+// so the handlers read the keys as stored
+func (h *Handler) resolveItemKey(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if h.keyIDs == nil {
+			return next(c)
+		}
+		names := c.ParamNames()
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = c.Param(name)
+			position, ok := map[string]KeyPosition{"pk": PartitionKeyPosition, "sk": SortKeyPosition}[name]
+			if !ok {
+				continue
+			}
+			value, err := h.resolveKey(position, values[i])
+			if err != nil {
+				return respondError(c, http.StatusNotFound, "Item not found")
+			}
+			values[i] = value
+		}
+		c.SetParamValues(values...)
+		return next(c)
+	}
+}
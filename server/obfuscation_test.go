@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAEADObfuscator(t *testing.T) {
+	o, err := NewAEADObfuscator("secret")
+	require.NoError(t, err)
+
+	id := o.Encode(PartitionKeyPosition, "user#42")
+	assert.Equal(t, id, o.Encode(PartitionKeyPosition, "user#42"), "IDs are deterministic")
+	assert.NotEqual(t, id, o.Encode(PartitionKeyPosition, "user#43"))
+	assert.NotContains(t, id, "user")
+	value, err := o.Decode(PartitionKeyPosition, id)
+	require.NoError(t, err)
+	assert.Equal(t, "user#42", value)
+
+	// The same value has unrelated IDs in each position, which only decode in their own
+	sortID := o.Encode(SortKeyPosition, "user#42")
+	assert.NotEqual(t, id[16:], sortID[16:])
+	_, err = o.Decode(SortKeyPosition, id)
+	assert.ErrorIs(t, err, errInvalidKeyID)
+	_, err = o.Decode(CursorPosition, sortID)
+	assert.ErrorIs(t, err, errInvalidKeyID)
+
+	tampered := []byte(id)
+	tampered[len(tampered)-1] ^= 1
+	for _, bad := range []string{"user#42", string(tampered), "", "!!"} {
+		_, err := o.Decode(PartitionKeyPosition, bad)
+		assert.ErrorIs(t, err, errInvalidKeyID, bad)
+	}
+	other, err := NewAEADObfuscator("other")
+	require.NoError(t, err)
+	_, err = other.Decode(PartitionKeyPosition, id)
+	assert.Error(t, err)
+
+	_, err = NewAEADObfuscator("")
+	assert.Error(t, err)
+}
+
+func TestLoadKeyObfuscator(t *testing.T) {
+	obfuscator, err := loadKeyObfuscator()
+	require.NoError(t, err)
+	assert.Nil(t, obfuscator)
+
+	t.Setenv("KEY_OBFUSCATION_SECRET", "secret")
+	obfuscator, err = loadKeyObfuscator()
+	require.NoError(t, err)
+	assert.NotNil(t, obfuscator)
+}
+
+func TestKeyObfuscationPagination(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	ids, err := NewAEADObfuscator("secret")
+	require.NoError(t, err)
+	handler := &Handler{client: fixture, keyIDs: ids}
+
+	get := func(query string) (*httptest.ResponseRecorder, Response) {
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil), rec)))
+		var res Response
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		}
+		return rec, res
+	}
+
+	partition := url.QueryEscape(ids.Encode(PartitionKeyPosition, "test"))
+	var sortKeys []string
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		rec, res := get("key_condition=" + partition + "&pagesize=2&cursor=" + url.QueryEscape(cursor))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		for _, entry := range res.Data {
+			assert.Equal(t, ids.Encode(PartitionKeyPosition, "test"), entry.KeyCond)
+			sk, err := ids.Decode(SortKeyPosition, entry.SortKey)
+			require.NoError(t, err)
+			sortKeys = append(sortKeys, sk)
+		}
+		if cursor = res.NextCursor; cursor == "" {
+			break
+		}
+		assert.NotContains(t, cursor, "item")
+	}
+	assert.Equal(t, []string{"item0001", "item0002", "item0003", "item0004", "item0005"}, sortKeys)
+
+	// Conditions comparing the keys as stored would let clients probe them
+	for _, query := range []string{"search=0003", "search=item&search_mode=prefix", "sort_gt=item0003", "sort_lt=item0003", "sort_begins_with=item", "sort_between_start=item0001&sort_between_end=item0003"} {
+		rec, _ := get("key_condition=" + partition + "&" + query)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handleScan(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/scan?filter="+url.QueryEscape("sort_key:begins_with:item"), nil), rec)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "scans can't filter on the keys either")
+
+	rec, _ = get("key_condition=test")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = get("key_condition=" + partition + "&cursor=eyJrZXlfY29uZCI6eyJTIjoidGVzdCJ9fQ")
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "cursors that weren't obfuscated are rejected")
+}
+
+func TestKeyObfuscationItems(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	ids, err := NewAEADObfuscator("secret")
+	require.NoError(t, err)
+	handler := &Handler{client: client, keyIDs: ids}
+
+	e := echo.New()
+	e.GET("/items/:pk/:sk", handler.handleGetItem, handler.resolveItemKey)
+	serve := func(pk, sk string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/"+url.PathEscape(pk)+"/"+url.PathEscape(sk), nil))
+		return rec
+	}
+
+	rec := serve(ids.Encode(PartitionKeyPosition, "test"), ids.Encode(SortKeyPosition, "item3"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var item ItemResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	assert.Equal(t, Entry{KeyCond: ids.Encode(PartitionKeyPosition, "test"), SortKey: ids.Encode(SortKeyPosition, "item3")}, item.Data)
+	assert.Equal(t, http.StatusNotFound, serve("test", "item3").Code)
+	assert.Equal(t, http.StatusNotFound, serve(ids.Encode(SortKeyPosition, "test"), ids.Encode(PartitionKeyPosition, "item3")).Code, "IDs only stand for keys in their position")
+
+	rec = serveBatchGet(t, handler, `{"keys": [
+		{"key_cond": "`+ids.Encode(PartitionKeyPosition, "test")+`", "sort_key": "`+ids.Encode(SortKeyPosition, "item3")+`"},
+		{"key_cond": "`+ids.Encode(PartitionKeyPosition, "test")+`", "sort_key": "`+ids.Encode(SortKeyPosition, "missing")+`"}
+	]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var batch BatchGetResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &batch))
+	assert.Equal(t, []Entry{{KeyCond: ids.Encode(PartitionKeyPosition, "test"), SortKey: ids.Encode(SortKeyPosition, "item3")}}, batch.Data)
+	assert.Equal(t, []ItemKey{{KeyCond: ids.Encode(PartitionKeyPosition, "test"), SortKey: ids.Encode(SortKeyPosition, "missing")}}, batch.Missing)
+	assert.Equal(t, http.StatusBadRequest, serveBatchGet(t, handler, `{"keys": [{"key_cond": "test", "sort_key": "item3"}]}`).Code)
+}
+
+func TestKeyObfuscationCreate(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, &types.AttributeValueMemberS{Value: "test"}, input.Item["key_cond"])
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+	ids, err := NewAEADObfuscator("secret")
+	require.NoError(t, err)
+	handler := &Handler{client: mockDynamoDB, keyIDs: ids}
+
+	// Creates name the item by its key attributes as stored, and are answered with its IDs
+	c, rec := newCreateContext("", `{"key_cond": "test", "sort_key": "item1", "status": "active"}`)
+	require.NoError(t, handler.handleCreateItem(c))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/items/"+ids.Encode(PartitionKeyPosition, "test")+"/"+ids.Encode(SortKeyPosition, "item1"), rec.Header().Get(echo.HeaderLocation))
+	var response WriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, ids.Encode(PartitionKeyPosition, "test"), response.New["key_cond"])
+	assert.Equal(t, ids.Encode(SortKeyPosition, "item1"), response.New["sort_key"])
+	assert.Equal(t, "active", response.New["status"])
+}
+
+func TestKeyObfuscationReports(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	ids, err := NewAEADObfuscator("secret")
+	require.NoError(t, err)
+	tracker := NewHotKeyTracker(0)
+	tracker.record("test", nil)
+	handler := &Handler{client: client, keyIDs: ids, hotKeys: tracker}
+
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handleSample(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/sample?segments=1", nil), rec)))
+	var sample SampleReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &sample))
+	require.NotEmpty(t, sample.HotPartitions)
+	assert.Equal(t, ids.Encode(PartitionKeyPosition, "test"), sample.HotPartitions[0].Key)
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, handler.handleHotKeys(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/admin/hot-keys", nil), rec)))
+	var hotKeys HotKeyReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hotKeys))
+	assert.Equal(t, []PartitionTraffic{{Key: ids.Encode(PartitionKeyPosition, "test"), Requests: 1, Share: 100}}, hotKeys.Partitions)
+}
@@ -0,0 +1,224 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultOffloadThreshold = 8 << 20
+	defaultOffloadTTL       = 15 * time.Minute
+	// headerOffloaded marks responses replaced by a link to the offloaded body
+	headerOffloaded = "X-Response-Offloaded"
+)
+
+// ObjectStore keeps offloaded response bodies and hands out time-limited links to them
+type ObjectStore interface {
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+	PresignGet(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// Offloader moves response bodies larger than Threshold bytes to an object store, so they are
+// downloaded from there instead of through the service
+type Offloader struct {
+	Store     ObjectStore
+	Threshold int
+	// TTL is how long the link to an offloaded body stays valid
+	TTL    time.Duration
+	Prefix string
+
+	now func() time.Time
+}
+
+// OffloadedResponse is returned in place of an offloaded body
+type OffloadedResponse struct {
+	URL         string    `json:"url"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Size        int       `json:"size"`
+	ContentType string    `json:"content_type"`
+	SHA256      string    `json:"sha256"`
+}
+
+// loadOffloader enables offloading to the S3 bucket OFFLOAD_BUCKET. Bodies over OFFLOAD_THRESHOLD_BYTES
+// are written under OFFLOAD_PREFIX and linked for OFFLOAD_URL_TTL. OFFLOAD_ENDPOINT replaces the S3
+// endpoint, e.g. for an S3-compatible store; region is the AWS region of the service.
+func loadOffloader(region string) (*Offloader, error) {
+	bucket := os.Getenv("OFFLOAD_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+
+	o := &Offloader{Threshold: defaultOffloadThreshold, TTL: defaultOffloadTTL, Prefix: os.Getenv("OFFLOAD_PREFIX")}
+	if v := os.Getenv("OFFLOAD_THRESHOLD_BYTES"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil || threshold <= 0 {
+			return nil, fmt.Errorf("invalid OFFLOAD_THRESHOLD_BYTES %q", v)
+		}
+		o.Threshold = threshold
+	}
+	if v := os.Getenv("OFFLOAD_URL_TTL"); v != "" {
+		ttl, err := time.ParseDuration(v)
+		// S3 rejects presigned URLs valid for more than a week
+		if err != nil || ttl <= 0 || ttl > 7*24*time.Hour {
+			return nil, fmt.Errorf("invalid OFFLOAD_URL_TTL %q", v)
+		}
+		o.TTL = ttl
+	}
+
+	var loadOpts []func(*config.LoadOptions) error
+	if region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	o.Store = NewS3Store(cfg, bucket, os.Getenv("OFFLOAD_ENDPOINT"))
+	return o, nil
+}
+
+// respond writes body, or a link to it when it is over the threshold. Bodies that can't be offloaded
+// are served as they are.
+func (o *Offloader) respond(c echo.Context, status int, format, contentType string, body []byte) error {
+	if o == nil || len(body) <= o.Threshold || status != http.StatusOK {
+		return c.Blob(status, contentType, body)
+	}
+
+	offloaded, err := o.offload(c.Request().Context(), format, contentType, body)
+	if err != nil {
+		c.Logger().Error(fmt.Errorf("offloading response: %w", err))
+		return c.Blob(status, contentType, body)
+	}
+	c.Response().Header().Set(headerOffloaded, "true")
+	return c.JSON(status, offloaded)
+}
+
+// offload stores a body of the named format and links to it
+func (o *Offloader) offload(ctx context.Context, format, contentType string, body []byte) (OffloadedResponse, error) {
+	now := time.Now
+	if o.now != nil {
+		now = o.now
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return OffloadedResponse{}, err
+	}
+	uploaded := now().UTC()
+	key := o.Prefix + uploaded.Format("2006/01/02/") + hex.EncodeToString(id) + "." + format
+
+	if err := o.Store.Put(ctx, key, body, contentType); err != nil {
+		return OffloadedResponse{}, err
+	}
+	link, err := o.Store.PresignGet(ctx, key, o.TTL)
+	if err != nil {
+		return OffloadedResponse{}, err
+	}
+
+	sum := sha256.Sum256(body)
+	return OffloadedResponse{
+		URL:         link,
+		ExpiresAt:   uploaded.Add(o.TTL),
+		Size:        len(body),
+		ContentType: contentType,
+		SHA256:      hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// S3Store is an ObjectStore on an S3 bucket, reached with requests signed by the AWS configuration
+type S3Store struct {
+	bucket      string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// NewS3Store creates a store on bucket. An empty endpoint addresses the bucket on S3 in the region of
+// cfg; other endpoints address it by path.
+func NewS3Store(cfg aws.Config, bucket, endpoint string) *S3Store {
+	return &S3Store{
+		bucket:      bucket,
+		region:      cfg.Region,
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      http.DefaultClient,
+	}
+}
+
+// objectURL is the URL of an object of the bucket
+func (s *S3Store) objectURL(key string) string {
+	path := (&url.URL{Path: "/" + key}).EscapedPath()
+	if s.endpoint == "" {
+		return "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com" + path
+	}
+	return s.endpoint + "/" + s.bucket + path
+}
+
+// Put uploads an object with PutObject
+func (s *S3Store) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(echo.HeaderContentType, contentType)
+
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now()); err != nil {
+		return err
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("PutObject returned %s: %s", res.Status, message)
+	}
+	return nil
+}
+
+// PresignGet returns a GetObject URL valid for expires
+func (s *S3Store) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key), nil)
+	if err != nil {
+		return "", err
+	}
+	query := req.URL.Query()
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	req.URL.RawQuery = query.Encode()
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+	link, _, err := s.signer.PresignHTTP(ctx, creds, req, "UNSIGNED-PAYLOAD", "s3", s.region, time.Now())
+	return link, err
+}
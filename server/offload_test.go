@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps objects in memory and links to them by key
+type memoryStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (m *memoryStore) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.objects[key] = body
+	return nil
+}
+
+func (m *memoryStore) PresignGet(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "https://objects.example/" + key + "?expires=" + expires.String(), nil
+}
+
+func TestOffloaderRespond(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{}}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	o := &Offloader{Store: store, Threshold: 10, TTL: time.Minute, Prefix: "pages/", now: func() time.Time { return now }}
+
+	respond := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/paginate", nil), rec)
+		require.NoError(t, o.respond(c, http.StatusOK, "json", echo.MIMEApplicationJSON, []byte(body)))
+		return rec
+	}
+
+	rec := respond(`{"Data":[]}`)
+	assert.Equal(t, "true", rec.Header().Get(headerOffloaded))
+	var offloaded OffloadedResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &offloaded))
+	assert.Equal(t, 11, offloaded.Size)
+	assert.Equal(t, now.Add(time.Minute), offloaded.ExpiresAt)
+	assert.Len(t, offloaded.SHA256, 64)
+
+	require.Len(t, store.objects, 1)
+	for key, body := range store.objects {
+		assert.True(t, strings.HasPrefix(key, "pages/2024/03/01/"), key)
+		assert.True(t, strings.HasSuffix(key, ".json"), key)
+		assert.Equal(t, `{"Data":[]}`, string(body))
+		assert.Equal(t, "https://objects.example/"+key+"?expires=1m0s", offloaded.URL)
+	}
+
+	// Small bodies, and bodies that can't be stored, are served as they are
+	rec = respond(`{}`)
+	assert.Equal(t, `{}`, rec.Body.String())
+	store.err = errors.New("unavailable")
+	rec = respond(`{"Data":[1,2,3]}`)
+	assert.Equal(t, `{"Data":[1,2,3]}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(headerOffloaded))
+
+	// Without an offloader every body is served
+	rec = httptest.NewRecorder()
+	require.NoError(t, (*Offloader)(nil).respond(echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec), http.StatusOK, "json", echo.MIMEApplicationJSON, []byte(`{"Data":[]}`)))
+	assert.Equal(t, `{"Data":[]}`, rec.Body.String())
+}
+
+func TestS3Store(t *testing.T) {
+	var uploaded *http.Request
+	var body []byte
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		uploaded = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer s3.Close()
+
+	cfg := aws.Config{Region: "eu-west-1", Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})}
+	store := NewS3Store(cfg, "responses", s3.URL+"/")
+
+	require.NoError(t, store.Put(context.Background(), "pages/a b.json", []byte(`{}`), echo.MIMEApplicationJSON))
+	assert.Equal(t, http.MethodPut, uploaded.Method)
+	assert.Equal(t, "/responses/pages/a%20b.json", uploaded.URL.EscapedPath())
+	assert.Equal(t, `{}`, string(body))
+	assert.True(t, strings.HasPrefix(uploaded.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"), uploaded.Header.Get("Authorization"))
+	assert.Contains(t, uploaded.Header.Get("Authorization"), "/eu-west-1/s3/aws4_request")
+
+	link, err := store.PresignGet(context.Background(), "pages/a b.json", 15*time.Minute)
+	require.NoError(t, err)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/responses/pages/a%20b.json", parsed.EscapedPath())
+	assert.Equal(t, "900", parsed.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, parsed.Query().Get("X-Amz-Signature"))
+
+	assert.Equal(t, "https://responses.s3.eu-west-1.amazonaws.com/key.json", NewS3Store(cfg, "responses", "").objectURL("key.json"))
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Output types of attributes
+const (
+	// outputNumber serves numbers as JSON numbers with all their digits, and numeric strings as numbers
+	outputNumber = "number"
+	// outputString serves numbers as strings with all their digits, so clients parsing JSON numbers as
+	// doubles don't round them
+	outputString = "string"
+	// outputTimestamp serves epoch seconds or milliseconds, and timestamps in the formats recognised by
+	// normalization, as RFC 3339
+	outputTimestamp = "timestamp"
+)
+
+var outputTypeNames = map[string]bool{outputNumber: true, outputString: true, outputTimestamp: true}
+
+// OutputTypes are the attributes of a table served in the attributes object of its items, by the type
+// each is served as
+type OutputTypes struct {
+	Attributes map[string]string
+}
+
+// LoadOutputTypes reads the output types of table from a JSON file mapping table names to their
+// attributes' types
+func LoadOutputTypes(path, table string) (*OutputTypes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseOutputTypes(data, table)
+}
+
+// ParseOutputTypes decodes the output types of table, which is nil when the file has none for it
+func ParseOutputTypes(data []byte, table string) (*OutputTypes, error) {
+	var tables map[string]map[string]string
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+	for name, attributes := range tables {
+		for attribute, outputType := range attributes {
+			if attribute == "" {
+				return nil, fmt.Errorf("table %q: output type of an unnamed attribute", name)
+			}
+			if !outputTypeNames[outputType] {
+				return nil, fmt.Errorf("table %q: attribute %q has unknown output type %q", name, attribute, outputType)
+			}
+		}
+	}
+	if len(tables[table]) == 0 {
+		return nil, nil
+	}
+	return &OutputTypes{Attributes: tables[table]}, nil
+}
+
+// loadOutputTypes reads the optional output types configured through OUTPUT_TYPES_FILE
+func loadOutputTypes() (*OutputTypes, error) {
+	path := os.Getenv("OUTPUT_TYPES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadOutputTypes(path, tableName)
+}
+
+// Apply converts the typed attributes of an item. Missing attributes are left out; attributes that
+// can't be converted are left out and described in the returned problems, which never quote item values.
+func (o *OutputTypes) Apply(item map[string]types.AttributeValue) (map[string]interface{}, []string) {
+	if o == nil {
+		return nil, nil
+	}
+	var values map[string]interface{}
+	var problems []string
+	for attribute, outputType := range o.Attributes {
+		value, ok := item[attribute]
+		if !ok {
+			continue
+		}
+		if _, null := value.(*types.AttributeValueMemberNULL); null {
+			continue
+		}
+		v, ok := convertOutput(value, outputType)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("attribute %q can't be served as a %s", attribute, outputType))
+			continue
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		values[attribute] = v
+	}
+	return values, problems
+}
+
+// convertOutput converts a value to an output type
+func convertOutput(value types.AttributeValue, outputType string) (interface{}, bool) {
+	switch outputType {
+	case outputNumber:
+		switch v := value.(type) {
+		case *types.AttributeValueMemberN:
+			return json.Number(v.Value), true
+		case *types.AttributeValueMemberS:
+			// Only strings in the syntax of JSON numbers are numbers, so "Inf" or "0x1F" aren't
+			var n json.Number
+			if err := json.Unmarshal([]byte(v.Value), &n); err != nil || n == "" || strings.HasPrefix(strings.TrimSpace(v.Value), `"`) {
+				return nil, false
+			}
+			return n, true
+		}
+	case outputString:
+		switch v := value.(type) {
+		case *types.AttributeValueMemberN:
+			return v.Value, true
+		case *types.AttributeValueMemberS:
+			return v.Value, true
+		case *types.AttributeValueMemberBOOL:
+			return strconv.FormatBool(v.Value), true
+		}
+	case outputTimestamp:
+		if t, err := parseTimestamp(value); err == nil {
+			return t.Format(time.RFC3339Nano), true
+		}
+	}
+	return nil, false
+}
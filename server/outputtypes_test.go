@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputTypes(t *testing.T) {
+	outputTypes, err := ParseOutputTypes([]byte(`{"TableName": {
+		"balance": "number", "quantity": "number", "code": "number",
+		"account_id": "string", "active": "string",
+		"created_at": "timestamp", "updated_at": "timestamp", "shipped_at": "timestamp",
+		"missing": "number", "deleted_at": "timestamp"
+	}}`), "TableName")
+	require.NoError(t, err)
+
+	item := map[string]types.AttributeValue{
+		"balance":    &types.AttributeValueMemberN{Value: "12345678901234567890.123456789"},
+		"quantity":   &types.AttributeValueMemberS{Value: "42"},
+		"code":       &types.AttributeValueMemberS{Value: "0x1F"},
+		"account_id": &types.AttributeValueMemberN{Value: "9007199254740993"},
+		"active":     &types.AttributeValueMemberBOOL{Value: true},
+		"created_at": &types.AttributeValueMemberN{Value: "1700000000"},
+		"updated_at": &types.AttributeValueMemberN{Value: "1700000000123"},
+		"shipped_at": &types.AttributeValueMemberS{Value: "2023-11-14 22:13:20"},
+		"deleted_at": &types.AttributeValueMemberNULL{Value: true},
+	}
+	values, problems := outputTypes.Apply(item)
+	assert.Equal(t, map[string]interface{}{
+		"balance":    json.Number("12345678901234567890.123456789"),
+		"quantity":   json.Number("42"),
+		"account_id": "9007199254740993",
+		"active":     "true",
+		"created_at": "2023-11-14T22:13:20Z",
+		"updated_at": "2023-11-14T22:13:20.123Z",
+		"shipped_at": "2023-11-14T22:13:20Z",
+	}, values)
+	assert.Equal(t, []string{`attribute "code" can't be served as a number`}, problems)
+
+	// Numbers keep all their digits in JSON
+	data, err := json.Marshal(values)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"balance":12345678901234567890.123456789`)
+
+	none, err := ParseOutputTypes([]byte(`{"Other": {"price": "string"}}`), "TableName")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+	values, problems = none.Apply(item)
+	assert.Nil(t, values)
+	assert.Nil(t, problems)
+}
+
+func TestParseOutputTypesInvalid(t *testing.T) {
+	for _, data := range []string{
+		`{"TableName": {"price": "decimal"}}`,
+		`{"Other": {"": "number"}}`,
+		`{"TableName": ["price"]}`,
+	} {
+		_, err := ParseOutputTypes([]byte(data), "TableName")
+		assert.Error(t, err, data)
+	}
+}
+
+func TestDecodeItemOutputTypes(t *testing.T) {
+	outputTypes, err := ParseOutputTypes([]byte(`{"TableName": {"price": "string", "created_at": "timestamp"}}`), "TableName")
+	require.NoError(t, err)
+	handler := &Handler{outputTypes: outputTypes}
+	item := map[string]types.AttributeValue{
+		"key_cond":   &types.AttributeValueMemberS{Value: "test"},
+		"sort_key":   &types.AttributeValueMemberS{Value: "item1"},
+		"price":      &types.AttributeValueMemberN{Value: "19.990"},
+		"created_at": &types.AttributeValueMemberS{Value: "soon"},
+	}
+
+	entry, warnings, keep, reqErr := handler.decodeItem(item, fullItem)
+	require.Nil(t, reqErr)
+	assert.True(t, keep)
+	assert.Equal(t, map[string]interface{}{"price": "19.990"}, entry.Attributes)
+	assert.Equal(t, []Warning{{Code: "output_type_error", Message: `attribute "created_at" can't be served as a timestamp`, Key: entry.Key()}}, warnings)
+
+	entry, _, _, reqErr = handler.decodeItem(item, keysOnlyItem)
+	require.Nil(t, reqErr)
+	assert.Nil(t, entry.Attributes)
+
+	// Typed attributes go in columns of their own, before computed fields
+	body, err := csvSerializer{}.Serialize(Response{Data: []Entry{
+		{KeyCond: "test", SortKey: "item1", Attributes: map[string]interface{}{"balance": json.Number("-12.50")}, Computed: map[string]interface{}{"label": "a"}},
+	}}, http.Header{})
+	require.NoError(t, err)
+	assert.Equal(t, "key_cond,sort_key,balance,label\ntest,item1,-12.50,a\n", string(body))
+}
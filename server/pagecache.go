@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+const (
+	// defaultPageCacheTTL is how long checkpoints and pages are kept when PAGE_CACHE_TTL isn't set
+	defaultPageCacheTTL = time.Minute
+	// pageCachePrefix starts the keys of the checkpoints shared in Redis
+	pageCachePrefix = "dynamopagination:pages:"
+)
+
+// pageCacheModes are the values of PAGE_CACHE_MODE
+var pageCacheModes = map[string]pagination.PageCacheMode{
+	"checkpoints": pagination.CacheCheckpoints,
+	"pages":       pagination.CachePages,
+	"all":         pagination.CacheAll,
+}
+
+// loadPageCache enables the page cache, holding up to PAGE_CACHE_SIZE checkpoints and pages for
+// PAGE_CACHE_TTL. PAGE_CACHE_MODE selects what it keeps, checkpoints by default. With
+// PAGE_CACHE_REDIS_URL checkpoints are shared in Redis instead, encrypted when PAGE_CACHE_SECRET is set,
+// and PAGE_CACHE_SIZE only keeps pages.
+func loadPageCache() (pagination.PageCache, error) {
+	size := 0
+	if v := os.Getenv("PAGE_CACHE_SIZE"); v != "" {
+		var err error
+		if size, err = strconv.Atoi(v); err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid PAGE_CACHE_SIZE %q", v)
+		}
+	}
+	redisURL := os.Getenv("PAGE_CACHE_REDIS_URL")
+	if size == 0 && redisURL == "" {
+		return nil, nil
+	}
+	ttl := defaultPageCacheTTL
+	if v := os.Getenv("PAGE_CACHE_TTL"); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid PAGE_CACHE_TTL %q", v)
+		}
+	}
+	mode := pagination.CacheCheckpoints
+	if v := os.Getenv("PAGE_CACHE_MODE"); v != "" {
+		var ok bool
+		if mode, ok = pageCacheModes[v]; !ok {
+			return nil, fmt.Errorf("invalid PAGE_CACHE_MODE %q", v)
+		}
+	}
+
+	var local pagination.PageCache
+	if size > 0 {
+		local = pagination.NewLRUPageCache(size, ttl, mode)
+	}
+	if redisURL == "" {
+		return local, nil
+	}
+	store, err := newRedisStore(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	shared := pagination.NewKVPageCache(store, pageCachePrefix, ttl)
+	shared.OnError = func(err error) { log.Printf("page cache: %v", err) }
+	if secret := os.Getenv("PAGE_CACHE_SECRET"); secret != "" {
+		if shared.AEAD, err = pageCacheAEAD(secret); err != nil {
+			return nil, err
+		}
+	}
+	if local == nil {
+		return shared, nil
+	}
+	return &sharedCheckpoints{PageCache: local, checkpoints: shared}, nil
+}
+
+// pageCacheAEAD derives the cipher encrypting shared checkpoints from a secret
+func pageCacheAEAD(secret string) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("page cache encryption"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sharedCheckpoints keeps pages in a local cache and checkpoints in a shared one
+type sharedCheckpoints struct {
+	pagination.PageCache
+	checkpoints pagination.PageCache
+}
+
+func (c *sharedCheckpoints) Checkpoint(ctx context.Context, key pagination.PageKey) (pagination.Checkpoint, bool) {
+	return c.checkpoints.Checkpoint(ctx, key)
+}
+
+func (c *sharedCheckpoints) PutCheckpoint(ctx context.Context, key pagination.PageKey, checkpoint pagination.Checkpoint) {
+	c.checkpoints.PutCheckpoint(ctx, key, checkpoint)
+}
+
+func (c *sharedCheckpoints) Invalidate(ctx context.Context, table, partition string) {
+	c.PageCache.Invalidate(ctx, table, partition)
+	c.checkpoints.Invalidate(ctx, table, partition)
+}
+
+// invalidate drops the cached pages a write to a partition of table can change. An empty partition
+// drops every page of the table.
+func (h *Handler) invalidate(ctx context.Context, table, partition string) {
+	h.bodies.invalidate(table, partition)
+	if h.pages != nil {
+		h.pages.Invalidate(ctx, table, partition)
+	}
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPageCache(t *testing.T) {
+	pages, err := loadPageCache()
+	require.NoError(t, err)
+	assert.Nil(t, pages)
+
+	t.Setenv("PAGE_CACHE_SIZE", "100")
+	t.Setenv("PAGE_CACHE_TTL", "30s")
+	t.Setenv("PAGE_CACHE_MODE", "all")
+	pages, err = loadPageCache()
+	require.NoError(t, err)
+	assert.NotNil(t, pages)
+
+	t.Setenv("PAGE_CACHE_REDIS_URL", "redis://localhost:6379")
+	pages, err = loadPageCache()
+	require.NoError(t, err)
+	assert.IsType(t, &sharedCheckpoints{}, pages)
+
+	t.Setenv("PAGE_CACHE_SIZE", "0")
+	pages, err = loadPageCache()
+	require.NoError(t, err)
+	assert.IsType(t, &pagination.KVPageCache{}, pages)
+
+	for name, value := range map[string]string{"PAGE_CACHE_SIZE": "x", "PAGE_CACHE_TTL": "0", "PAGE_CACHE_MODE": "items", "PAGE_CACHE_REDIS_URL": "localhost:6379"} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			_, err := loadPageCache()
+			assert.Error(t, err)
+		})
+	}
+}
+
+// queryCounter counts the queries made through it
+type queryCounter struct {
+	DynamoClient
+	queries int
+}
+
+func (c *queryCounter) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.queries++
+	return c.DynamoClient.Query(ctx, params, optFns...)
+}
+
+func TestHandlePaginationPageCache(t *testing.T) {
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	client := &queryCounter{DynamoClient: fixture}
+	handler := &Handler{client: client, pages: pagination.NewLRUPageCache(100, defaultPageCacheTTL, pagination.CacheAll)}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	firstKey := func(page int) string {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/paginate?key_condition=test&page=%d&pagesize=2", page), nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		require.NotEmpty(t, res.Data)
+		return res.Data[0].SortKey
+	}
+
+	assert.Equal(t, "item0001", firstKey(1))
+	assert.Equal(t, "item0003", firstKey(2))
+	assert.Equal(t, "item0005", firstKey(3))
+	assert.Equal(t, 3, client.queries, "each page resumes from the one before it")
+
+	assert.Equal(t, "item0003", firstKey(2))
+	assert.Equal(t, 3, client.queries, "repeated pages are served from the cache")
+
+	// Writes to the partition drop its pages and checkpoints
+	handler.invalidate(context.Background(), tableName, "test")
+	assert.Equal(t, "item0005", firstKey(3))
+	assert.Equal(t, 6, client.queries)
+}
+
+func TestHandlePaginationPageCacheRegions(t *testing.T) {
+	primary, err := NewFixtureClient(GenerateFixture([]string{"test"}, 4))
+	require.NoError(t, err)
+	replica, err := NewFixtureClient(GenerateFixture([]string{"test"}, 2))
+	require.NoError(t, err)
+	handler := &Handler{
+		client:   primary,
+		region:   "us-east-1",
+		replicas: map[string]DynamoClient{"eu-west-1": replica},
+		pages:    pagination.NewLRUPageCache(100, defaultPageCacheTTL, pagination.CacheAll),
+	}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	size := func(query string) int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=10"+query, nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var res Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return len(res.Data)
+	}
+
+	// A replica lagging behind the primary serves its own pages, not those cached from the primary
+	assert.Equal(t, 4, size(""))
+	assert.Equal(t, 2, size("&region=eu-west-1"))
+	assert.Equal(t, 4, size(""))
+}
+
+func TestHandlePaginationSharedCheckpoints(t *testing.T) {
+	redis := newFakeRedis(t, "")
+	t.Setenv("PAGE_CACHE_REDIS_URL", "redis://"+redis.listener.Addr().String())
+	t.Setenv("PAGE_CACHE_SECRET", "page-secret")
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	client := &queryCounter{DynamoClient: fixture}
+
+	// Two instances of the service share the checkpoints of their walks
+	serve := func() *httptest.ResponseRecorder {
+		pages, err := loadPageCache()
+		require.NoError(t, err)
+		e := echo.New()
+		e.GET("/paginate", (&Handler{client: client, pages: pages}).handlePagination)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&page=3&pagesize=2", nil))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+	serve()
+	assert.Equal(t, 3, client.queries)
+	rec := serve()
+	assert.Equal(t, 4, client.queries)
+	var res Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "item0005", res.Data[0].SortKey)
+
+	for key, value := range redis.values {
+		assert.NotContains(t, value, "item", key)
+	}
+}
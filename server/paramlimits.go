@@ -0,0 +1,90 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+const (
+	defaultMinPageSize = 1
+	defaultMaxPageSize = 1000
+)
+
+// ParamLimits bound the paging parameters clients may send. Requests outside them get a 422 instead of
+// being served a page they didn't ask for.
+type ParamLimits struct {
+	MinPageSize int64
+	MaxPageSize int64
+	// MaxPage is the highest page number, as walking to a page reads every page before it; zero allows any
+	MaxPage int64
+	// OrderBy lists the attributes pages may be ordered by; empty allows the sort key of the table or index
+	OrderBy map[string]bool
+}
+
+// defaultParamLimits apply to handlers configured without limits
+var defaultParamLimits = &ParamLimits{MinPageSize: defaultMinPageSize, MaxPageSize: defaultMaxPageSize}
+
+// loadParamLimits reads PAGE_SIZE_MIN, PAGE_SIZE_MAX, PAGE_NUMBER_MAX and ORDERBY_ALLOWED, a comma
+// separated list of attributes
+func loadParamLimits() (*ParamLimits, error) {
+	limits := &ParamLimits{MinPageSize: defaultMinPageSize, MaxPageSize: defaultMaxPageSize}
+	for _, l := range []struct {
+		name  string
+		value *int64
+	}{
+		{"PAGE_SIZE_MIN", &limits.MinPageSize},
+		{"PAGE_SIZE_MAX", &limits.MaxPageSize},
+		{"PAGE_NUMBER_MAX", &limits.MaxPage},
+	} {
+		if v := os.Getenv(l.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid %s %q", l.name, v)
+			}
+			*l.value = n
+		}
+	}
+	if limits.MinPageSize < 1 || limits.MaxPageSize < limits.MinPageSize {
+		return nil, fmt.Errorf("page size limits %d to %d are empty", limits.MinPageSize, limits.MaxPageSize)
+	}
+	if v := os.Getenv("ORDERBY_ALLOWED"); v != "" {
+		limits.OrderBy = map[string]bool{}
+		for _, attribute := range strings.Split(v, ",") {
+			if attribute = strings.TrimSpace(attribute); attribute != "" {
+				limits.OrderBy[attribute] = true
+			}
+		}
+	}
+	return limits, nil
+}
+
+// check verifies that the paging parameters asked for are within the limits. Pages and page sizes
+// that aren't positive numbers select their defaults, so they are left to extractParams.
+func (l *ParamLimits) check(page, pageSize int64, orderBy string) *requestError {
+	if l == nil {
+		l = defaultParamLimits
+	}
+	if pageSize > 0 && (pageSize < l.MinPageSize || pageSize > l.MaxPageSize) {
+		return &requestError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("pagesize must be between %d and %d", l.MinPageSize, l.MaxPageSize)}
+	}
+	if l.MaxPage > 0 && page > l.MaxPage {
+		return &requestError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("page must be at most %d; use cursors to read further", l.MaxPage)}
+	}
+	if orderBy != "" && len(l.OrderBy) > 0 {
+		if attribute, _ := pagination.ParseOrderBy(orderBy); !l.OrderBy[attribute] {
+			allowed := make([]string, 0, len(l.OrderBy))
+			for name := range l.OrderBy {
+				allowed = append(allowed, name)
+			}
+			sort.Strings(allowed)
+			return &requestError{status: http.StatusUnprocessableEntity, message: fmt.Sprintf("orderby must be one of %s", strings.Join(allowed, ", "))}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamLimits(t *testing.T) {
+	limits := &ParamLimits{MinPageSize: 5, MaxPageSize: 50, MaxPage: 20, OrderBy: map[string]bool{"sort_key": true, "created_at": true}}
+	tests := []struct {
+		page, pageSize int64
+		orderBy        string
+		message        string
+	}{
+		{page: 1, pageSize: 10},
+		{page: 20, pageSize: 50, orderBy: "-sort_key"},
+		{page: 1, pageSize: 4, message: "pagesize must be between 5 and 50"},
+		{page: 1, pageSize: 51, message: "pagesize must be between 5 and 50"},
+		{page: 21, pageSize: 10, message: "page must be at most 20; use cursors to read further"},
+		{page: 1, pageSize: 10, orderBy: "price", message: "orderby must be one of created_at, sort_key"},
+	}
+	for _, test := range tests {
+		reqErr := limits.check(test.page, test.pageSize, test.orderBy)
+		if test.message == "" {
+			assert.Nil(t, reqErr)
+			continue
+		}
+		require.NotNil(t, reqErr)
+		assert.Equal(t, http.StatusUnprocessableEntity, reqErr.status)
+		assert.Equal(t, test.message, reqErr.message)
+	}
+
+	// Handlers without limits still bound the page size
+	assert.Nil(t, (*ParamLimits)(nil).check(1000, 1000, "price"))
+	assert.NotNil(t, (*ParamLimits)(nil).check(1, 1001, ""))
+}
+
+func TestLoadParamLimits(t *testing.T) {
+	limits, err := loadParamLimits()
+	require.NoError(t, err)
+	assert.Equal(t, &ParamLimits{MinPageSize: 1, MaxPageSize: 1000}, limits)
+
+	t.Setenv("PAGE_SIZE_MAX", "200")
+	t.Setenv("PAGE_NUMBER_MAX", "50")
+	t.Setenv("ORDERBY_ALLOWED", "sort_key, created_at")
+	limits, err = loadParamLimits()
+	require.NoError(t, err)
+	assert.Equal(t, &ParamLimits{MinPageSize: 1, MaxPageSize: 200, MaxPage: 50, OrderBy: map[string]bool{"sort_key": true, "created_at": true}}, limits)
+
+	t.Setenv("PAGE_SIZE_MIN", "300")
+	_, err = loadParamLimits()
+	assert.Error(t, err)
+
+	t.Setenv("PAGE_SIZE_MIN", "")
+	t.Setenv("PAGE_NUMBER_MAX", "many")
+	_, err = loadParamLimits()
+	assert.Error(t, err)
+}
+
+func TestHandlePaginationPageSizeLimit(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=5000", nil)
+	rec := httptest.NewRecorder()
+
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, ErrorDetail{Code: "unprocessable", Message: "pagesize must be between 1 and 1000"}, errorBody(t, rec))
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// selectModes are the accepted values of the select parameter
+var selectModes = map[string]bool{
+	"all":       true,
+	"keys_only": true,
+	"count":     true,
+}
+
+// searchModes are the accepted values of the search_mode parameter
+var searchModes = map[string]bool{
+	"":       true,
+	"prefix": true,
+	"client": true,
+}
+
+// parsePassthrough validates the select, return_consumed_capacity and search_mode parameters, and
+// checks that the fields projection doesn't conflict with select
+func parsePassthrough(params *Params, selectMode, consumedCapacity string) *requestError {
+	selectMode = strings.ToLower(selectMode)
+	if selectMode != "" && !selectModes[selectMode] {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid select parameter"}
+	}
+	if !searchModes[params.SearchMode] {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid search_mode parameter"}
+	}
+	if selectMode == "count" && params.Search != "" && params.SearchMode != "prefix" {
+		// Only prefix searches are applied by the query, so DynamoDB can't count other matching items
+		return &requestError{status: http.StatusBadRequest, message: "select=count can't be combined with search"}
+	}
+	if params.IncludeCount && params.Search != "" && params.SearchMode != "prefix" {
+		return &requestError{status: http.StatusBadRequest, message: "include_count can't be combined with search"}
+	}
+
+	if len(params.Fields) > 0 && selectMode != "" && selectMode != "all" {
+		return &requestError{status: http.StatusBadRequest, message: "fields can't be combined with select=" + selectMode}
+	}
+
+	consumedCapacity = strings.ToLower(consumedCapacity)
+	if _, ok := pagination.ConsumedCapacityModes[consumedCapacity]; consumedCapacity != "" && !ok {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid return_consumed_capacity parameter"}
+	}
+
+	if consumedCapacity == "none" {
+		consumedCapacity = ""
+	}
+
+	params.Select = selectMode
+	params.ConsumedCapacity = consumedCapacity
+	return nil
+}
+
+// parseConsistent reads the consistent parameter. Global secondary indexes are only eventually
+// consistent, so consistent reads of them are rejected.
+func (h *Handler) parseConsistent(c echo.Context, params *Params) *requestError {
+	v := c.QueryParam("consistent")
+	if v == "" {
+		return nil
+	}
+	consistent, err := strconv.ParseBool(v)
+	if err != nil {
+		return &requestError{status: http.StatusBadRequest, message: "Invalid consistent parameter"}
+	}
+	if consistent && params.IndexName != "" && !h.indexes[params.IndexName].Local {
+		return &requestError{status: http.StatusBadRequest, message: "Global secondary indexes don't support consistent reads"}
+	}
+	params.ConsistentRead = consistent
+	return nil
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePassthrough(t *testing.T) {
+	tests := []struct {
+		name             string
+		search           string
+		searchMode       string
+		selectMode       string
+		consumedCapacity string
+		expected         Params
+		expectedError    string
+	}{
+		{name: "defaults"},
+		{name: "keys only", selectMode: "KEYS_ONLY", expected: Params{Select: "keys_only"}},
+		{name: "capacity", consumedCapacity: "indexes", expected: Params{ConsumedCapacity: "indexes"}},
+		{name: "no capacity", consumedCapacity: "none"},
+		{name: "unknown select", selectMode: "some", expectedError: "Invalid select parameter"},
+		{name: "count with search", selectMode: "count", search: "x", expectedError: "select=count can't be combined with search"},
+		{name: "count with prefix search", selectMode: "count", search: "x", searchMode: "prefix", expected: Params{Select: "count"}},
+		{name: "unknown search mode", search: "x", searchMode: "fuzzy", expectedError: "Invalid search_mode parameter"},
+		{name: "unknown capacity", consumedCapacity: "all", expectedError: "Invalid return_consumed_capacity parameter"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := Params{Search: test.search, SearchMode: test.searchMode}
+			reqErr := parsePassthrough(&params, test.selectMode, test.consumedCapacity)
+			if test.expectedError != "" {
+				require.NotNil(t, reqErr)
+				assert.Equal(t, test.expectedError, reqErr.message)
+				return
+			}
+			require.Nil(t, reqErr)
+			test.expected.Search = test.search
+			test.expected.SearchMode = test.searchMode
+			assert.Equal(t, test.expected, params)
+		})
+	}
+}
+
+func TestHandlePaginationCount(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.Select == types.SelectCount && input.ExclusiveStartKey == nil
+	})).Return(&dynamodb.QueryOutput{
+		Count:            3,
+		LastEvaluatedKey: map[string]types.AttributeValue{"sort_key": &types.AttributeValueMemberS{Value: "item3"}},
+		ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)},
+	}, nil).Once()
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.Select == types.SelectCount && input.ExclusiveStartKey != nil
+	})).Return(&dynamodb.QueryOutput{Count: 2, ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}}, nil).Once()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&select=count&return_consumed_capacity=total", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.NotNil(t, response.Meta)
+	assert.EqualValues(t, 5, *response.Meta.Count)
+	assert.Equal(t, 1.0, response.Meta.ConsumedCapacity)
+	assert.Empty(t, response.Data)
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePaginationKeysOnly(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return assert.Equal(t, "#pk, #sk", *input.ProjectionExpression) &&
+			assert.Equal(t, types.ReturnConsumedCapacityTotal, input.ReturnConsumedCapacity)
+	})).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+		},
+		ConsumedCapacity: &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)},
+	}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&select=keys_only&return_consumed_capacity=true", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "test", SortKey: "item1"}}, response.Data)
+	require.NotNil(t, response.Meta)
+	assert.Equal(t, 0.5, response.Meta.ConsumedCapacity)
+}
+
+func TestHandlePaginationConsistentRead(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return input.ConsistentRead != nil && *input.ConsistentRead
+	})).Return(&dynamodb.QueryOutput{}, nil)
+	handler := &Handler{client: mockDynamoDB, indexes: map[string]pagination.KeySchema{
+		"by_status":  {PartitionKey: "status", SortKey: "updated_at"},
+		"by_created": {PartitionKey: "key_cond", SortKey: "created_at", Local: true},
+	}}
+
+	paginate := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, paginate("key_condition=test&consistent=true").Code)
+	assert.Equal(t, http.StatusOK, paginate("key_condition=test&index=by_created&consistent=1").Code)
+
+	rec := paginate("key_condition=open&index=by_status&consistent=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Global secondary indexes don't support consistent reads", errorBody(t, rec).Message)
+	assert.Equal(t, http.StatusBadRequest, paginate("key_condition=test&consistent=maybe").Code)
+	mockDynamoDB.AssertNumberOfCalls(t, "Query", 2)
+}
+
+func TestHandlePaginationDebug(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	get := func(handle echo.HandlerFunc, target string) Response {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handle(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var res Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return res
+	}
+
+	res := get(handler.handlePagination, "/paginate?key_condition=test&pagesize=1&page=2&debug=true")
+	require.NotNil(t, res.Meta)
+	require.NotNil(t, res.Meta.Stats)
+	assert.Equal(t, int64(2), res.Meta.Stats.RoundTrips)
+	assert.Equal(t, int64(2), res.Meta.Stats.ItemsScanned)
+	assert.Equal(t, int64(1), res.Meta.Stats.ItemsReturned)
+
+	res = get(handler.handleScan, "/scan?pagesize=2&debug=true")
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, int64(2), res.Meta.Stats.ItemsReturned)
+
+	res = get(handler.handlePagination, "/paginate?key_condition=test&debug=false")
+	assert.Nil(t, res.Meta)
+}
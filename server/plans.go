@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// defaultPlanCacheSize is the number of query shapes whose plans are kept
+const defaultPlanCacheSize = 1000
+
+// loadPlanCache creates the plan cache, holding PLAN_CACHE_SIZE plans. A size of 0 disables it.
+func loadPlanCache() (*pagination.PlanCache, error) {
+	size := defaultPlanCacheSize
+	if v := os.Getenv("PLAN_CACHE_SIZE"); v != "" {
+		var err error
+		if size, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if size <= 0 {
+		return nil, nil
+	}
+	return pagination.NewPlanCache(size), nil
+}
+
+// handlePlanCache reports the size and hit rate of the plan cache
+func (h *Handler) handlePlanCache(c echo.Context) error {
+	if h.plans == nil {
+		return respondError(c, http.StatusNotFound, "The plan cache is disabled")
+	}
+	return c.JSON(http.StatusOK, h.plans.Stats())
+}
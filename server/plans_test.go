@@ -0,0 +1,37 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePlanCache(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+	handler := &Handler{client: mockDynamoDB, plans: pagination.NewPlanCache(defaultPlanCacheSize)}
+
+	e := echo.New()
+	for _, partition := range []string{"a", "b", "c"} {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition="+partition, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/plan-cache", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handlePlanCache(e.NewContext(req, rec)))
+	assert.JSONEq(t, `{"Plans": 1, "Hits": 2, "Misses": 1, "HitRate": 0.6666666666666666}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	require.NoError(t, (&Handler{}).handlePlanCache(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// headerPriority names the priority class a request asks for
+const headerPriority = "X-Priority"
+
+// PriorityClass is a pool of requests sharing a concurrency limit and a rate limit. Zero values disable
+// the limits.
+type PriorityClass struct {
+	// Concurrency is the number of requests of the class served at once
+	Concurrency int `json:"concurrency,omitempty"`
+	// Rate is the number of requests per second admitted, with bursts of up to Burst requests
+	Rate  float64 `json:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// Queue is how long a request waits for a concurrency slot, in Go syntax ("250ms"); zero rejects
+	// it straight away
+	Queue string `json:"queue,omitempty"`
+
+	queue   time.Duration
+	slots   chan struct{}
+	limiter *rate.Limiter
+}
+
+// Priorities sorts requests into classes, so background traffic such as exports can't starve the
+// interactive pagination of the same service. A request belongs to the class of its API key, or else
+// the one named in X-Priority, or else the default.
+type Priorities struct {
+	Default string                    `json:"default"`
+	Classes map[string]*PriorityClass `json:"classes"`
+	// APIKeys assigns API keys to classes. Their requests can't pick another class through X-Priority.
+	APIKeys map[string]string `json:"api_keys,omitempty"`
+
+	keys []priorityKey
+}
+
+type priorityKey struct {
+	key   []byte
+	class string
+}
+
+// LoadPriorities reads priority classes from a JSON file
+func LoadPriorities(path string) (*Priorities, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePriorities(data)
+}
+
+// ParsePriorities decodes priority classes and sets up their pools
+func ParsePriorities(data []byte) (*Priorities, error) {
+	var p Priorities
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Classes) == 0 {
+		return nil, errors.New("no priority classes")
+	}
+	if p.Classes[p.Default] == nil {
+		return nil, fmt.Errorf("default class %q isn't defined", p.Default)
+	}
+	for name, class := range p.Classes {
+		if class == nil {
+			return nil, fmt.Errorf("%s: class has no limits", name)
+		}
+		if err := class.setup(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	for key, class := range p.APIKeys {
+		if p.Classes[class] == nil {
+			return nil, fmt.Errorf("API key assigned to undefined class %q", class)
+		}
+		p.keys = append(p.keys, priorityKey{key: []byte(key), class: class})
+	}
+	return &p, nil
+}
+
+func (c *PriorityClass) setup() error {
+	if c.Concurrency < 0 || c.Rate < 0 || c.Burst < 0 {
+		return errors.New("limits can't be negative")
+	}
+	if c.Queue != "" {
+		var err error
+		if c.queue, err = time.ParseDuration(c.Queue); err != nil {
+			return fmt.Errorf("invalid queue: %w", err)
+		}
+	}
+	if c.Concurrency > 0 {
+		c.slots = make(chan struct{}, c.Concurrency)
+	}
+	if c.Rate > 0 {
+		burst := c.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(c.Rate))
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(c.Rate), burst)
+	}
+	return nil
+}
+
+// loadPriorities reads the optional priority classes configured through PRIORITIES_FILE
+func loadPriorities() (*Priorities, error) {
+	path := os.Getenv("PRIORITIES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadPriorities(path)
+}
+
+// classify returns the class of a request, or false when X-Priority names an unknown class
+func (p *Priorities) classify(req *http.Request) (string, bool) {
+	if key := requestAPIKey(req); key != "" {
+		for _, known := range p.keys {
+			if subtle.ConstantTimeCompare([]byte(key), known.key) == 1 {
+				return known.class, true
+			}
+		}
+	}
+	if name := req.Header.Get(headerPriority); name != "" {
+		return name, p.Classes[name] != nil
+	}
+	return p.Default, true
+}
+
+// Middleware admits requests within the rate and concurrency limits of their class. Requests over the
+// rate get a 429, and requests that find no free slot within the queue time a 503.
+func (p *Priorities) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		name, ok := p.classify(c.Request())
+		if !ok {
+			return respondError(c, http.StatusBadRequest, "Invalid X-Priority header")
+		}
+		class := p.Classes[name]
+		c.Response().Header().Set(headerPriority, name)
+		ensureRequestContext(c).Priority = name
+
+		if class.limiter != nil && !class.limiter.Allow() {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter(class.limiter)))
+			return respondError(c, http.StatusTooManyRequests, "Too many requests")
+		}
+
+		if class.slots != nil {
+			if !class.acquire(c.Request()) {
+				c.Response().Header().Set("Retry-After", "1")
+				return respondError(c, http.StatusServiceUnavailable, "Too many requests in progress")
+			}
+			defer func() { <-class.slots }()
+		}
+		return next(c)
+	}
+}
+
+// acquire takes a concurrency slot, waiting up to the queue time while the request is live
+func (c *PriorityClass) acquire(req *http.Request) bool {
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if c.queue <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(c.queue)
+	defer timer.Stop()
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-req.Context().Done():
+		return false
+	}
+}
+
+// retryAfter is the number of whole seconds until the limiter admits another request
+func retryAfter(limiter *rate.Limiter) int {
+	// The reservation only measures the wait, so it's handed back straight away
+	r := limiter.Reserve()
+	defer r.Cancel()
+	if seconds := int(math.Ceil(r.Delay().Seconds())); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
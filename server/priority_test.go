@@ -0,0 +1,115 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePriorities(t *testing.T) {
+	p, err := ParsePriorities([]byte(`{
+		"default": "interactive",
+		"classes": {"interactive": {"concurrency": 50, "queue": "250ms"}, "background": {"concurrency": 2, "rate": 0.5}},
+		"api_keys": {"export-key": "background"}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, 50, cap(p.Classes["interactive"].slots))
+	assert.Nil(t, p.Classes["interactive"].limiter)
+	assert.Equal(t, 1, p.Classes["background"].limiter.Burst())
+
+	for _, data := range []string{
+		`{"default": "interactive"}`,
+		`{"default": "missing", "classes": {"interactive": {}}}`,
+		`{"default": "interactive", "classes": {"interactive": {"queue": "soon"}}}`,
+		`{"default": "interactive", "classes": {"interactive": {"concurrency": -1}}}`,
+		`{"default": "interactive", "classes": {"interactive": {}}, "api_keys": {"key": "background"}}`,
+	} {
+		_, err := ParsePriorities([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestPrioritiesClassify(t *testing.T) {
+	p, err := ParsePriorities([]byte(`{
+		"default": "interactive",
+		"classes": {"interactive": {}, "background": {}},
+		"api_keys": {"export-key": "background"}
+	}`))
+	require.NoError(t, err)
+
+	classify := func(headers map[string]string) (string, bool) {
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		return p.classify(req)
+	}
+
+	class, ok := classify(nil)
+	assert.True(t, ok)
+	assert.Equal(t, "interactive", class)
+
+	class, _ = classify(map[string]string{headerPriority: "background"})
+	assert.Equal(t, "background", class)
+
+	// Keyed clients keep their class whatever they ask for
+	class, _ = classify(map[string]string{headerAPIKey: "export-key", headerPriority: "interactive"})
+	assert.Equal(t, "background", class)
+	class, _ = classify(map[string]string{echo.HeaderAuthorization: "Bearer export-key"})
+	assert.Equal(t, "background", class)
+
+	_, ok = classify(map[string]string{headerPriority: "urgent"})
+	assert.False(t, ok)
+}
+
+func TestPrioritiesMiddleware(t *testing.T) {
+	p, err := ParsePriorities([]byte(`{
+		"default": "interactive",
+		"classes": {"interactive": {"concurrency": 1}, "background": {"rate": 1}}
+	}`))
+	require.NoError(t, err)
+
+	e := echo.New()
+	inside := make(chan struct{})
+	release := make(chan struct{})
+	handler := p.Middleware(func(c echo.Context) error {
+		if c.QueryParam("block") != "" {
+			close(inside)
+			<-release
+		}
+		return c.String(http.StatusOK, "ok")
+	})
+	serve := func(target, class string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if class != "" {
+			req.Header.Set(headerPriority, class)
+		}
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler(e.NewContext(req, rec)))
+		return rec
+	}
+
+	// The interactive slot is taken, so another interactive request is turned away
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- serve("/paginate?block=1", "") }()
+	<-inside
+	rec := serve("/paginate", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "interactive", rec.Header().Get(headerPriority))
+
+	// Background requests have their own limits
+	assert.Equal(t, http.StatusOK, serve("/paginate", "background").Code)
+	rec = serve("/paginate", "background")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, http.StatusOK, serve("/paginate", "").Code)
+
+	assert.Equal(t, http.StatusBadRequest, serve("/paginate", "urgent").Code)
+}
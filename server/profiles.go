@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// profileV1 serves /paginate pages as a Response, the default
+	profileV1 = "v1"
+	// profileV2 serves them in the Envelope of the v2 routes
+	profileV2 = "v2"
+	// profileJSONAPI serves them as a JSON:API document
+	profileJSONAPI = "jsonapi"
+	// profileHAL serves them as a HAL document
+	profileHAL = "hal"
+	// headerResponseProfile names the profile a page was served in
+	headerResponseProfile = "X-Response-Profile"
+)
+
+// checkProfile validates the response profile of an API key or tenant; empty keeps the default
+func checkProfile(profile string) error {
+	switch profile {
+	case "", profileV1, profileV2, profileJSONAPI, profileHAL:
+		return nil
+	}
+	return fmt.Errorf("unknown response profile %q", profile)
+}
+
+// JSONAPIDocument is a page in the JSON:API profile
+type JSONAPIDocument struct {
+	Data  []JSONAPIResource `json:"data"`
+	Meta  JSONAPIMeta       `json:"meta"`
+	Links *EnvelopeLinks    `json:"links,omitempty"`
+}
+
+// JSONAPIMeta is the meta of the v2 envelope, with its warnings
+type JSONAPIMeta struct {
+	EnvelopeMeta
+	Warnings []EnvelopeWarning `json:"warnings,omitempty"`
+}
+
+// JSONAPIResource is an item as a JSON:API resource, identified by its item path
+type JSONAPIResource struct {
+	Type       string            `json:"type"`
+	ID         string            `json:"id"`
+	Attributes Entry             `json:"attributes"`
+	Links      map[string]string `json:"links"`
+}
+
+func (JSONAPIDocument) MediaType() string {
+	return "application/vnd.api+json"
+}
+
+// HALDocument is a page in the HAL profile: the items are embedded, and the meta of the v2 envelope
+// are properties of the page
+type HALDocument struct {
+	Links    map[string]HALLink `json:"_links"`
+	Embedded struct {
+		Items []HALItem `json:"items"`
+	} `json:"_embedded"`
+	EnvelopeMeta
+	Warnings []EnvelopeWarning `json:"warnings,omitempty"`
+}
+
+// HALItem is an item with a link to it
+type HALItem struct {
+	Entry
+	Links map[string]HALLink `json:"_links"`
+}
+
+// HALLink is a link of a HAL document
+type HALLink struct {
+	Href string `json:"href"`
+}
+
+func (HALDocument) MediaType() string {
+	return "application/hal+json"
+}
+
+// itemID is the path of an item under the item routes of the table the handler serves
+func (h *Handler) itemID(entry Entry) string {
+	id := url.PathEscape(entry.KeyCond)
+	if _, keys := h.schema(); keys.SortKey != "" {
+		id += "/" + url.PathEscape(entry.SortKey)
+	}
+	return id
+}
+
+// itemLink is the path an item is read at: /items for the configured table, /tables/:table/items for
+// the registered ones
+func (h *Handler) itemLink(id string) string {
+	if h.table == nil {
+		return "/items/" + id
+	}
+	return "/tables/" + url.PathEscape(h.table.name) + "/items/" + id
+}
+
+// requestProfile returns the response profile the caller is pinned to, empty for the default
+func requestProfile(c echo.Context) string {
+	if rc := requestContextFrom(c.Request().Context()); rc != nil {
+		return rc.Profile
+	}
+	return ""
+}
+
+// profilePage renders a /paginate page in the profile the caller is pinned to. Profiles shape JSON
+// bodies: pages in other formats are served as they are.
+func (h *Handler) profilePage(c echo.Context, res Response, params Params) interface{} {
+	profile := requestProfile(c)
+	if profile == "" || profile == profileV1 {
+		return res
+	}
+	if format, _, reqErr := selectSerializer(c); reqErr != nil || format != defaultFormat {
+		return res
+	}
+	c.Response().Header().Set(headerResponseProfile, profile)
+	env := newEnvelope(c, res, params)
+	if profile == profileV2 {
+		return env
+	}
+	// The documents are built from the envelope with the warnings of the request
+	env = withWarnings(c, env).(Envelope)
+	items, _ := env.Data.([]Entry)
+
+	if profile == profileJSONAPI {
+		doc := JSONAPIDocument{Data: make([]JSONAPIResource, len(items)), Meta: JSONAPIMeta{EnvelopeMeta: env.Meta, Warnings: env.Warnings}, Links: env.Links}
+		for i, item := range items {
+			id := h.itemID(item)
+			doc.Data[i] = JSONAPIResource{Type: "items", ID: id, Attributes: item, Links: map[string]string{"self": h.itemLink(id)}}
+		}
+		return doc
+	}
+
+	doc := HALDocument{Links: map[string]HALLink{"self": {Href: env.Links.Self}}, EnvelopeMeta: env.Meta, Warnings: env.Warnings}
+	if env.Links.Next != "" {
+		doc.Links["next"] = HALLink{Href: env.Links.Next}
+	}
+	if env.Links.Prev != "" {
+		doc.Links["prev"] = HALLink{Href: env.Links.Prev}
+	}
+	doc.Embedded.Items = make([]HALItem, len(items))
+	for i, item := range items {
+		doc.Embedded.Items[i] = HALItem{Entry: item, Links: map[string]HALLink{"self": {Href: h.itemLink(h.itemID(item))}}}
+	}
+	return doc
+}
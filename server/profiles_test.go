@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseProfiles(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{
+		"defaults": {"profile": "v2"},
+		"tenants": {
+			"acme": {"api_keys": ["acme-key"], "profile": "jsonapi"},
+			"globex": {"api_keys": ["globex-key"], "profile": "hal"},
+			"legacy": {"api_keys": ["legacy-key"], "profile": "v1"}
+		}
+	}`))
+	require.NoError(t, err)
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	serve := func(key, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=2&"+query, nil)
+		if key != "" {
+			req.Header.Set(headerAPIKey, key)
+		}
+		rec := httptest.NewRecorder()
+		require.NoError(t, tenants.Middleware(handler.handlePagination)(echo.New().NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+
+	rec := serve("legacy-key", "")
+	var v1 Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v1))
+	assert.Len(t, v1.Data, 2)
+	assert.Empty(t, rec.Header().Get(headerResponseProfile))
+
+	rec = serve("", "page=2")
+	assert.Equal(t, profileV2, rec.Header().Get(headerResponseProfile))
+	var v2 struct {
+		Data  []Entry       `json:"data"`
+		Meta  EnvelopeMeta  `json:"meta"`
+		Links EnvelopeLinks `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &v2))
+	assert.Equal(t, "item0003", v2.Data[0].SortKey)
+	assert.Equal(t, int64(2), v2.Meta.Page)
+	assert.Contains(t, v2.Links.Prev, "page=1")
+
+	rec = serve("acme-key", "")
+	assert.Equal(t, "application/vnd.api+json", rec.Header().Get(echo.HeaderContentType))
+	var jsonAPI struct {
+		Data []struct {
+			Type       string            `json:"type"`
+			ID         string            `json:"id"`
+			Attributes Entry             `json:"attributes"`
+			Links      map[string]string `json:"links"`
+		} `json:"data"`
+		Meta  map[string]interface{} `json:"meta"`
+		Links EnvelopeLinks          `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &jsonAPI))
+	require.Len(t, jsonAPI.Data, 2)
+	assert.Equal(t, "items", jsonAPI.Data[0].Type)
+	assert.Equal(t, "test/item0001", jsonAPI.Data[0].ID)
+	assert.Equal(t, "item0001", jsonAPI.Data[0].Attributes.SortKey)
+	assert.Equal(t, "/items/test/item0001", jsonAPI.Data[0].Links["self"])
+	assert.Equal(t, true, jsonAPI.Meta["has_more"])
+	assert.Contains(t, jsonAPI.Links.Next, "page=2")
+
+	rec = serve("globex-key", "")
+	assert.Equal(t, "application/hal+json", rec.Header().Get(echo.HeaderContentType))
+	var hal struct {
+		Links    map[string]HALLink `json:"_links"`
+		Embedded struct {
+			Items []struct {
+				SortKey string             `json:"sort_key"`
+				Links   map[string]HALLink `json:"_links"`
+			} `json:"items"`
+		} `json:"_embedded"`
+		PageSize int64 `json:"page_size"`
+		HasMore  bool  `json:"has_more"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &hal))
+	require.Len(t, hal.Embedded.Items, 2)
+	assert.Equal(t, "item0002", hal.Embedded.Items[1].SortKey)
+	assert.Equal(t, "/items/test/item0002", hal.Embedded.Items[1].Links["self"].Href)
+	assert.Contains(t, hal.Links["next"].Href, "page=2")
+	assert.Equal(t, int64(2), hal.PageSize)
+	assert.True(t, hal.HasMore)
+
+	// Other formats than JSON are served as they are
+	rec = serve("globex-key", "format=ndjson")
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	assert.Empty(t, rec.Header().Get(headerResponseProfile))
+}
+
+func TestResponseProfileAPIKey(t *testing.T) {
+	auth, err := NewAuth(map[string]APIKeyConfig{"acme": {APIKeys: []string{"acme-key"}, Profile: "hal"}}, nil)
+	require.NoError(t, err)
+	tenants, err := ParseTenants([]byte(`{"tenants": {"acme": {"api_keys": ["acme-key"], "profile": "jsonapi"}}}`))
+	require.NoError(t, err)
+
+	var profile string
+	handler := auth.Middleware(tenants.Middleware(func(c echo.Context) error {
+		profile = requestProfile(c)
+		return nil
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+	req.Header.Set(headerAPIKey, "acme-key")
+	require.NoError(t, handler(echo.New().NewContext(req, httptest.NewRecorder())))
+	assert.Equal(t, profileHAL, profile, "the profile of the API key wins over its tenant's")
+}
+
+func TestResponseProfileInvalid(t *testing.T) {
+	_, err := ParseTenants([]byte(`{"tenants": {"acme": {"api_keys": ["key"], "profile": "v3"}}}`))
+	assert.Error(t, err)
+	_, err = NewAuth(map[string]APIKeyConfig{"acme": {APIKeys: []string{"key"}, Profile: "xml"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestResponseProfileTableLinks(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{"tenants": {"acme": {"api_keys": ["acme-key"], "profile": "jsonapi"}, "globex": {"api_keys": ["globex-key"], "profile": "hal"}}}`))
+	require.NoError(t, err)
+	tables, err := ParseTables([]byte(`{"Orders": {"partition_key": "customer", "sort_key": "created_at"}, "Customers": {"partition_key": "customer"}}`))
+	require.NoError(t, err)
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"customer":   &types.AttributeValueMemberS{Value: "c 1"},
+		"created_at": &types.AttributeValueMemberS{Value: "2024-01-01"},
+	}}}, nil)
+	handler := &Handler{client: mockDynamoDB}
+	handler.tables = handler.forTables(tables)
+
+	serve := func(key, table string) []byte {
+		req := httptest.NewRequest(http.MethodGet, "/paginate/"+table+"?key_condition=c1", nil)
+		req.Header.Set(headerAPIKey, key)
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		c.SetParamNames("table")
+		c.SetParamValues(table)
+		require.NoError(t, tenants.Middleware(handler.handleTablePagination)(c))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec.Body.Bytes()
+	}
+
+	// Links point at the item routes of the table served, with the keys of its schema
+	var jsonAPI struct {
+		Data []JSONAPIResource `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(serve("acme-key", "Orders"), &jsonAPI))
+	require.Len(t, jsonAPI.Data, 1)
+	assert.Equal(t, "c%201/2024-01-01", jsonAPI.Data[0].ID)
+	assert.Equal(t, "/tables/Orders/items/c%201/2024-01-01", jsonAPI.Data[0].Links["self"])
+
+	var hal struct {
+		Embedded struct {
+			Items []HALItem `json:"items"`
+		} `json:"_embedded"`
+	}
+	require.NoError(t, json.Unmarshal(serve("globex-key", "Customers"), &hal))
+	require.Len(t, hal.Embedded.Items, 1)
+	assert.Equal(t, "/tables/Customers/items/c%201", hal.Embedded.Items[0].Links["self"].Href)
+}
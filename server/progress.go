@@ -0,0 +1,49 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// wantsEventStream reports whether the client asked for server-sent events
+func wantsEventStream(c echo.Context) bool {
+	return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/event-stream")
+}
+
+// writeEvent sends one server-sent event with a JSON payload
+func writeEvent(res *echo.Response, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	res.Flush()
+	return nil
+}
+
+// streamWithProgress serves a page as server-sent events: a progress event after every DynamoDB round
+// trip followed by a single result or error event
+func (h *Handler) streamWithProgress(c echo.Context, client DynamoClient, keyCond string, params Params) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.WriteHeader(http.StatusOK)
+
+	page, reqErr := h.fetchPage(c.Request().Context(), client, keyCond, params, func(p Progress) {
+		if err := writeEvent(res, "progress", p); err != nil {
+			c.Logger().Error(err)
+		}
+	})
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return writeEvent(res, "error", map[string]string{"message": reqErr.message})
+	}
+
+	return writeEvent(res, "result", page)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationProgressEvents(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&page=2&pagesize=1", nil)
+	req.Header.Set(echo.HeaderAccept, "text/event-stream")
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: client}
+	require.NoError(t, handler.handlePagination(c))
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+
+	var events []string
+	var payloads []string
+	for _, block := range strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n") {
+		lines := strings.Split(block, "\n")
+		require.Len(t, lines, 2)
+		events = append(events, strings.TrimPrefix(lines[0], "event: "))
+		payloads = append(payloads, strings.TrimPrefix(lines[1], "data: "))
+	}
+	assert.Equal(t, []string{"progress", "progress", "result"}, events)
+
+	var progress Progress
+	require.NoError(t, json.Unmarshal([]byte(payloads[1]), &progress))
+	assert.Equal(t, int64(2), progress.RoundTrips)
+	assert.Equal(t, int64(2), progress.ItemsScanned)
+	assert.Equal(t, int64(0), progress.EtaMs)
+
+	var response Response
+	require.NoError(t, json.Unmarshal([]byte(payloads[2]), &response))
+	assert.Equal(t, Response{Data: []Entry{{KeyCond: "test", SortKey: "item2"}}, Page: 2, Size: 1}, response)
+}
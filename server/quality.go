@@ -0,0 +1,218 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultQualityLimit = 1000
+	maxQualityLimit     = 10000
+)
+
+// QualityReport summarises the shape of the items matching a query
+type QualityReport struct {
+	ItemsScanned      int64
+	Truncated         bool
+	Attributes        map[string]AttributeStats
+	DuplicateSortKeys []string `json:",omitempty"`
+}
+
+// AttributeStats describes how one attribute appears across the scanned items
+type AttributeStats struct {
+	Present int64
+	// Presence is the percentage of scanned items that have the attribute
+	Presence float64
+	// Types counts occurrences per DynamoDB type (S, N, BOOL, ...)
+	Types map[string]int64
+	// ExpectedType is the schema type when one is declared, otherwise the most common DynamoDB type
+	ExpectedType string
+	Mismatched   int64
+}
+
+// attributeType returns the DynamoDB type descriptor of an attribute value
+func attributeType(av types.AttributeValue) string {
+	switch av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	}
+	return "unknown"
+}
+
+// matchesSchemaType reports whether a DynamoDB type satisfies a JSON Schema type
+func matchesSchemaType(schemaType, dynamoType string) bool {
+	switch schemaType {
+	case "string":
+		return dynamoType == "S"
+	case "number", "integer":
+		return dynamoType == "N"
+	case "boolean":
+		return dynamoType == "BOOL"
+	case "null":
+		return dynamoType == "NULL"
+	case "object":
+		return dynamoType == "M"
+	case "array":
+		return dynamoType == "L" || dynamoType == "SS" || dynamoType == "NS" || dynamoType == "BS"
+	case "binary":
+		return dynamoType == "B"
+	}
+	return true
+}
+
+func (h *Handler) handleQuality(c echo.Context) error {
+	keyCond, reqErr := h.queryPartition(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if keyCond == "" {
+		return respondError(c, http.StatusBadRequest, "Invalid key_condition parameter")
+	}
+
+	client, ok := h.clientFor(c)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid region parameter")
+	}
+
+	limit := int64(defaultQualityLimit)
+	if limitStr := c.QueryParam("limit"); limitStr != "" {
+		l, err := strconv.ParseInt(limitStr, 10, 64)
+		if err != nil || l <= 0 {
+			return respondError(c, http.StatusBadRequest, "Invalid limit parameter")
+		}
+		limit = l
+	}
+	if limit > maxQualityLimit {
+		limit = maxQualityLimit
+	}
+
+	var items []map[string]types.AttributeValue
+	var lastEvaluatedKey map[string]types.AttributeValue
+	truncated := false
+
+	for {
+		input := keyConditionQuery(keyCond)
+		input.ExclusiveStartKey = lastEvaluatedKey
+
+		result, err := client.Query(c.Request().Context(), input)
+		if err != nil {
+			reqErr := dynamoError("Error in DynamoDB query", err)
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+
+		items = append(items, result.Items...)
+		lastEvaluatedKey = result.LastEvaluatedKey
+
+		if int64(len(items)) >= limit {
+			truncated = int64(len(items)) > limit || lastEvaluatedKey != nil
+			items = items[:limit]
+			break
+		}
+		if lastEvaluatedKey == nil {
+			break
+		}
+	}
+
+	var schema *Schema
+	if h.validation != nil {
+		schema = h.validation.Schema
+	}
+
+	report := buildQualityReport(items, schema)
+	report.Truncated = truncated
+	for i, sk := range report.DuplicateSortKeys {
+		report.DuplicateSortKeys[i] = h.exposeKey(SortKeyPosition, sk)
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
+
+// buildQualityReport computes attribute statistics for a set of raw items
+func buildQualityReport(items []map[string]types.AttributeValue, schema *Schema) QualityReport {
+	report := QualityReport{
+		ItemsScanned: int64(len(items)),
+		Attributes:   map[string]AttributeStats{},
+	}
+
+	sortKeys := map[string]int{}
+	for _, item := range items {
+		for name, av := range item {
+			stats := report.Attributes[name]
+			if stats.Types == nil {
+				stats.Types = map[string]int64{}
+			}
+			stats.Present++
+			stats.Types[attributeType(av)]++
+			report.Attributes[name] = stats
+		}
+
+		if sk, ok := item[tableKeys.SortKey].(*types.AttributeValueMemberS); ok {
+			sortKeys[sk.Value]++
+		}
+	}
+
+	for name, stats := range report.Attributes {
+		stats.Presence = float64(stats.Present) * 100 / float64(len(items))
+
+		var declared *Schema
+		if schema != nil {
+			declared = schema.Properties[name]
+		}
+
+		if declared != nil && declared.Type != "" {
+			stats.ExpectedType = declared.Type
+			for t, n := range stats.Types {
+				if !matchesSchemaType(declared.Type, t) {
+					stats.Mismatched += n
+				}
+			}
+		} else {
+			stats.ExpectedType = mostCommonType(stats.Types)
+			stats.Mismatched = stats.Present - stats.Types[stats.ExpectedType]
+		}
+
+		report.Attributes[name] = stats
+	}
+
+	for sk, n := range sortKeys {
+		if n > 1 {
+			report.DuplicateSortKeys = append(report.DuplicateSortKeys, sk)
+		}
+	}
+	sort.Strings(report.DuplicateSortKeys)
+
+	return report
+}
+
+func mostCommonType(counts map[string]int64) string {
+	best := ""
+	for t, n := range counts {
+		if n > counts[best] || (n == counts[best] && t < best) {
+			best = t
+		}
+	}
+	return best
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleQuality(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"sort_key": &types.AttributeValueMemberS{Value: "item1"}, "count": &types.AttributeValueMemberN{Value: "1"}},
+			{"sort_key": &types.AttributeValueMemberS{Value: "item1"}, "count": &types.AttributeValueMemberS{Value: "2"}},
+			{"sort_key": &types.AttributeValueMemberS{Value: "item2"}, "count": &types.AttributeValueMemberN{Value: "3"}},
+			{"sort_key": &types.AttributeValueMemberS{Value: "item3"}},
+		},
+	}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/quality?key_condition=test&limit=3", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleQuality(c))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var report QualityReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+
+	assert.Equal(t, QualityReport{
+		ItemsScanned: 3,
+		Truncated:    true,
+		Attributes: map[string]AttributeStats{
+			"sort_key": {Present: 3, Presence: 100, Types: map[string]int64{"S": 3}, ExpectedType: "S"},
+			"count":    {Present: 3, Presence: 100, Types: map[string]int64{"N": 2, "S": 1}, ExpectedType: "N", Mismatched: 1},
+		},
+		DuplicateSortKeys: []string{"item1"},
+	}, report)
+}
+
+func TestBuildQualityReportWithSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"properties": {"count": {"type": "string"}}}`))
+	require.NoError(t, err)
+
+	report := buildQualityReport([]map[string]types.AttributeValue{
+		{"count": &types.AttributeValueMemberN{Value: "1"}},
+		{"count": &types.AttributeValueMemberN{Value: "2"}},
+		{"count": &types.AttributeValueMemberS{Value: "3"}},
+		{},
+	}, schema)
+
+	stats := report.Attributes["count"]
+	assert.Equal(t, float64(75), stats.Presence)
+	assert.Equal(t, "string", stats.ExpectedType)
+	assert.Equal(t, int64(2), stats.Mismatched)
+}
+
+func TestHandleQualityInvalidLimit(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/quality?key_condition=test&limit=abc", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: new(MockDynamoDB)}
+	_ = handler.handleQuality(c)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+)
+
+// paginationBodyMaxBytes limits the size of a POST /paginate body
+var paginationBodyMaxBytes int64 = 1 << 20
+
+// PaginationBody is the JSON body of POST /paginate: the parameters of GET /paginate, with filters on
+// the other attributes of the items, which don't fit a query string
+type PaginationBody struct {
+	Params
+	Filter           string            `json:"filter,omitempty"`
+	Filters          []FilterCondition `json:"filters,omitempty"`
+	Cursor           *string           `json:"cursor,omitempty"`
+	Region           string            `json:"region,omitempty"`
+	ConsistencyToken string            `json:"consistency_token,omitempty"`
+	Format           string            `json:"format,omitempty"`
+	Wait             string            `json:"wait,omitempty"`
+	Debug            bool              `json:"debug,omitempty"`
+	ExplainEmpty     bool              `json:"explain_empty,omitempty"`
+}
+
+// FilterCondition is one of the filters of a body, taking the operators of conditional writes. Values
+// are typed by their JSON type: strings, numbers or booleans.
+type FilterCondition struct {
+	Attribute string      `json:"attribute"`
+	Op        string      `json:"op"`
+	Value     interface{} `json:"value,omitempty"`
+}
+
+type filterKey struct{}
+
+// requestFilter are the filters a request's body set, with the canonical form its fingerprint covers
+type requestFilter struct {
+	conditions []Condition
+	canonical  string
+}
+
+// handlePaginationBody serves POST /paginate. The body replaces the query parameters it sets, and the
+// request is served the way GET /paginate serves them, with the filters applied by DynamoDB.
+func (h *Handler) handlePaginationBody(c echo.Context) error {
+	body, reqErr := readPaginationBody(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	conditions, reqErr := body.conditions()
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	// Handlers read the parameters Echo already parsed, so they are updated in place
+	query := c.QueryParams()
+	if reqErr := body.setQuery(query); reqErr != nil {
+		return reqErr.respond(c)
+	}
+	req := c.Request()
+	req.URL.RawQuery = query.Encode()
+	if len(conditions) > 0 {
+		canonical, _ := json.Marshal(body.Filters)
+		c.SetRequest(req.WithContext(context.WithValue(req.Context(), filterKey{}, requestFilter{conditions: conditions, canonical: string(canonical)})))
+	}
+	recordFingerprint(c)
+	return h.cacheBodies(h.handlePagination)(c)
+}
+
+// readPaginationBody decodes the JSON body of a request, keeping its numbers exact
+func readPaginationBody(c echo.Context) (PaginationBody, *requestError) {
+	var body PaginationBody
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Response(), c.Request().Body, paginationBodyMaxBytes))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return body, &requestError{status: http.StatusRequestEntityTooLarge, message: "Request body too large"}
+	}
+	if err != nil {
+		return body, &requestError{status: http.StatusBadRequest, message: "Invalid request body", err: err}
+	}
+	return body, nil
+}
+
+// setQuery sets the query parameters of GET /paginate that the body sets
+func (b PaginationBody) setQuery(query url.Values) *requestError {
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	flag := func(name string, value bool) {
+		if value {
+			query.Set(name, "true")
+		}
+	}
+	set("key_condition", b.KeyCondition)
+	if b.Page != 0 {
+		query.Set("page", strconv.FormatInt(b.Page, 10))
+	}
+	if b.PageSize != 0 {
+		query.Set("pagesize", strconv.FormatInt(b.PageSize, 10))
+	}
+	set("orderby", b.OrderBy)
+	set("search", b.Search)
+	set("search_mode", b.SearchMode)
+	set("select", b.Select)
+	set("fields", strings.Join(b.Fields, ","))
+	set("index", b.IndexName)
+	flag("consistent", b.ConsistentRead)
+	set("return_consumed_capacity", b.ConsumedCapacity)
+	flag("include_count", b.IncludeCount)
+	flag("debug", b.Debug)
+	flag("explain_empty", b.ExplainEmpty)
+	if b.Cursor != nil {
+		query.Set("cursor", *b.Cursor)
+	}
+	set("region", b.Region)
+	set("consistency_token", b.ConsistencyToken)
+	set("format", b.Format)
+	set("wait", b.Wait)
+	set("filter", b.Filter)
+
+	if b.SortRange == nil {
+		return nil
+	}
+	for _, p := range sortRangeParams {
+		if p.op == b.SortRange.Op {
+			query.Set(p.param, b.SortRange.Value)
+			if p.op == "between" {
+				query.Set("sort_between_end", b.SortRange.End)
+			}
+			return nil
+		}
+	}
+	return &requestError{status: http.StatusBadRequest, message: "Invalid sort_range"}
+}
+
+// conditions converts the filters of the body, which all must hold
+func (b PaginationBody) conditions() ([]Condition, *requestError) {
+	conditions := make([]Condition, len(b.Filters))
+	for i, f := range b.Filters {
+		cond, err := f.condition()
+		if err != nil {
+			return nil, &requestError{status: http.StatusBadRequest, message: "Invalid filters", err: err}
+		}
+		conditions[i] = cond
+	}
+	return conditions, nil
+}
+
+// condition converts the filter into a Condition, checking its operator takes the value it has
+func (f FilterCondition) condition() (Condition, error) {
+	if f.Attribute == "" {
+		return Condition{}, errors.New("filter without attribute")
+	}
+	needsValue, ok := operators[f.Op]
+	if !ok {
+		return Condition{}, fmt.Errorf("unknown operator %q", f.Op)
+	}
+	cond := Condition{Attribute: f.Attribute, Op: f.Op}
+	switch {
+	case needsValue && f.Value == nil:
+		return Condition{}, fmt.Errorf("filter on %s: operator %s requires a value", f.Attribute, f.Op)
+	case !needsValue && f.Value != nil:
+		return Condition{}, fmt.Errorf("filter on %s: operator %s doesn't take a value", f.Attribute, f.Op)
+	case needsValue:
+		switch v := f.Value.(type) {
+		case string:
+			cond.Value = &types.AttributeValueMemberS{Value: v}
+		case json.Number:
+			cond.Value = &types.AttributeValueMemberN{Value: v.String()}
+		case bool:
+			cond.Value = &types.AttributeValueMemberBOOL{Value: v}
+		default:
+			return Condition{}, fmt.Errorf("filter on %s: values must be strings, numbers or booleans", f.Attribute)
+		}
+	}
+	return cond, nil
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationBody(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	var queries []*dynamodb.QueryInput
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		queries = append(queries, args.Get(1).(*dynamodb.QueryInput))
+	}).Return(&dynamodb.QueryOutput{}, nil)
+	handler := &Handler{client: mockDynamoDB}
+
+	e := echo.New()
+	e.Use(Fingerprint)
+	e.POST("/paginate", handler.handlePaginationBody)
+	serve := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/paginate", strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(`{"key_condition": "test", "pagesize": 5, "orderby": "-sort_key", "sort_range": {"op": "begins_with", "value": "item"},
+		"filters": [{"attribute": "status", "op": "eq", "value": "open"}, {"attribute": "size", "op": "gt", "value": 3}, {"attribute": "archived", "op": "not_exists"}]}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.Len(t, queries, 1)
+	input := queries[0]
+	assert.Equal(t, "#pk = :keyCond AND begins_with(#sk, :sortValue)", *input.KeyConditionExpression)
+	assert.Equal(t, "#n0 = :v0 AND #n1 > :v1 AND attribute_not_exists(#n2)", *input.FilterExpression)
+	assert.Equal(t, "status", input.ExpressionAttributeNames["#n0"])
+	assert.Equal(t, &types.AttributeValueMemberN{Value: "3"}, input.ExpressionAttributeValues[":v1"])
+	assert.Equal(t, int32(5), *input.Limit)
+	assert.False(t, *input.ScanIndexForward)
+
+	// The fingerprint covers the body like the query of a GET
+	fingerprint := rec.Header().Get(headerFingerprint)
+	rec = serve(`{"filters": [{"attribute": "status", "op": "eq", "value": "open"}, {"attribute": "size", "op": "gt", "value": 3}, {"attribute": "archived", "op": "not_exists"}],
+		"sort_range": {"op": "begins_with", "value": "item"}, "orderby": "-sort_key", "pagesize": 5, "key_condition": "test"}`)
+	assert.Equal(t, fingerprint, rec.Header().Get(headerFingerprint))
+	rec = serve(`{"key_condition": "test", "pagesize": 5, "orderby": "-sort_key", "sort_range": {"op": "begins_with", "value": "item"}, "filters": [{"attribute": "status", "op": "eq", "value": "closed"}]}`)
+	assert.NotEqual(t, fingerprint, rec.Header().Get(headerFingerprint))
+	rec = serve(`{"key_condition": "test", "pagesize": 5}`)
+	assert.NotEqual(t, fingerprint, rec.Header().Get(headerFingerprint))
+	assert.Nil(t, queries[len(queries)-1].FilterExpression)
+
+	for _, body := range []string{
+		`not json`,
+		`{"key_condition": "test", "unknown": true}`,
+		`{"key_condition": "test", "sort_range": {"op": "~", "value": "a"}}`,
+		`{"key_condition": "test", "filters": [{"attribute": "status", "op": "like", "value": "open"}]}`,
+		`{"key_condition": "test", "filters": [{"attribute": "status", "op": "eq"}]}`,
+		`{"key_condition": "test", "filters": [{"attribute": "status", "op": "exists", "value": "open"}]}`,
+		`{"key_condition": "test", "filters": [{"attribute": "status", "op": "eq", "value": ["open"]}]}`,
+		// Queries can't filter on their keys
+		`{"key_condition": "test", "filters": [{"attribute": "sort_key", "op": "eq", "value": "a"}]}`,
+		`{"filters": [{"attribute": "status", "op": "eq", "value": "open"}]}`,
+	} {
+		rec = serve(body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, body)
+		assert.Equal(t, codeValidation, errorBody(t, rec).Code, body)
+	}
+}
+
+func TestHandlePaginationBodyTooLarge(t *testing.T) {
+	defer func(limit int64) { paginationBodyMaxBytes = limit }(paginationBodyMaxBytes)
+	paginationBodyMaxBytes = 16
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/paginate", strings.NewReader(`{"key_condition": "test"}`))
+	require.NoError(t, (&Handler{}).handlePaginationBody(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+}
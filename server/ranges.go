@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerRange        = "Range"
+	headerContentRange = "Content-Range"
+	headerAcceptRanges = "Accept-Ranges"
+	// rangeUnit is the range unit of result sets, counting items
+	rangeUnit = "items"
+)
+
+// itemRange is the items a Range header selects, numbered from 0, first and last included
+type itemRange struct {
+	first, last int64
+}
+
+// parseItemRange reads a Range header of the form "items=first-last" or "items=first-", which runs
+// for pageSize items. Like other servers it ignores the ranges it can't serve, reporting false for
+// other units, several ranges, suffix ranges and invalid ones.
+func parseItemRange(header string, pageSize int64) (itemRange, bool) {
+	spec := strings.TrimSpace(header)
+	if !strings.HasPrefix(spec, rangeUnit+"=") {
+		return itemRange{}, false
+	}
+	from, to, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(spec, rangeUnit+"=")), "-")
+	if !ok || strings.Contains(to, ",") {
+		return itemRange{}, false
+	}
+	first, err := strconv.ParseInt(strings.TrimSpace(from), 10, 64)
+	if err != nil || first < 0 {
+		return itemRange{}, false
+	}
+	if to = strings.TrimSpace(to); to == "" {
+		return itemRange{first: first, last: first + pageSize - 1}, true
+	}
+	last, err := strconv.ParseInt(to, 10, 64)
+	if err != nil || last < first {
+		return itemRange{}, false
+	}
+	return itemRange{first: first, last: last}, true
+}
+
+// requestRange returns the range of items a request asks for, for the routes serving pages by
+// number. Ranges are ignored with cursors, long polls, progress streams, grouped pages and counts.
+func requestRange(c echo.Context, params Params, wait bool) (itemRange, bool) {
+	header := c.Request().Header.Get(headerRange)
+	if header == "" || params.CursorMode || wait || params.Select == "count" || c.QueryParam("group_by") != "" || wantsEventStream(c) {
+		return itemRange{}, false
+	}
+	return parseItemRange(header, params.PageSize)
+}
+
+// fetchRange serves a range of items as a page, reading the one or two pages of the range's length
+// that hold it, and returns the range served. Ranges longer than the largest page size are shortened
+// to it.
+func (h *Handler) fetchRange(c echo.Context, client DynamoClient, keyCond string, params Params, rng itemRange) (Response, itemRange, *requestError) {
+	limits := h.limits
+	if limits == nil {
+		limits = defaultParamLimits
+	}
+	length := rng.last - rng.first + 1
+	size := length
+	if size < limits.MinPageSize {
+		size = limits.MinPageSize
+	}
+	if size > limits.MaxPageSize {
+		size = limits.MaxPageSize
+	}
+	size = requestContextFrom(c.Request().Context()).budget().capPageSize(size)
+	if length > size {
+		length = size
+	}
+
+	params.PageSize = size
+	params.Page = rng.first/size + 1
+	offset := rng.first % size
+	if reqErr := limits.check(params.Page, params.PageSize, ""); reqErr != nil {
+		return Response{}, rng, reqErr
+	}
+	res, reqErr := h.fetchPage(c.Request().Context(), client, keyCond, params, nil)
+	if reqErr != nil {
+		return Response{}, rng, reqErr
+	}
+
+	// A range starting within a page ends in the next one
+	if offset+length > size && res.HasMore && int64(len(res.Data)) == size {
+		params.Page++
+		next, reqErr := h.fetchPage(c.Request().Context(), client, keyCond, params, nil)
+		if reqErr != nil {
+			return Response{}, rng, reqErr
+		}
+		res.Data = append(res.Data, next.Data...)
+		res.HasMore = next.HasMore
+		if next.Meta != nil && len(next.Meta.Warnings) > 0 {
+			if res.Meta == nil {
+				res.Meta = &Meta{}
+			}
+			res.Meta.Warnings = append(res.Meta.Warnings, next.Meta.Warnings...)
+		}
+	}
+
+	if offset > int64(len(res.Data)) {
+		offset = int64(len(res.Data))
+	}
+	end := offset + length
+	if end > int64(len(res.Data)) {
+		end = int64(len(res.Data))
+	} else if end < int64(len(res.Data)) {
+		res.HasMore = true
+	}
+	res.Data = res.Data[offset:end]
+	res.Size = int64(len(res.Data))
+	return res, itemRange{first: rng.first, last: rng.first + res.Size - 1}, nil
+}
+
+// respondRange serves the items of a range with 206 Partial Content, or 416 when the results end
+// before the range starts. Content-Range carries the total when include_count counted it.
+func (h *Handler) respondRange(c echo.Context, res Response, served itemRange) error {
+	header := c.Response().Header()
+	header.Add(echo.HeaderVary, headerRange)
+	total := "*"
+	if res.TotalItems != nil {
+		total = strconv.FormatInt(*res.TotalItems, 10)
+	}
+	if res.Size == 0 {
+		header.Set(headerContentRange, fmt.Sprintf("%s */%s", rangeUnit, total))
+		return respondError(c, http.StatusRequestedRangeNotSatisfiable, "The results end before the requested range")
+	}
+	header.Set(headerContentRange, fmt.Sprintf("%s %d-%d/%s", rangeUnit, served.first, served.last, total))
+	return h.respondPageStatus(c, http.StatusPartialContent, res)
+}
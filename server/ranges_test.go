@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseItemRange(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected itemRange
+		ok       bool
+	}{
+		{header: "items=0-9", expected: itemRange{first: 0, last: 9}, ok: true},
+		{header: " items=100 - 199 ", expected: itemRange{first: 100, last: 199}, ok: true},
+		{header: "items=20-", expected: itemRange{first: 20, last: 29}, ok: true},
+		{header: "bytes=0-9"},
+		{header: "items=-10"},
+		{header: "items=0-4,10-14"},
+		{header: "items=9-0"},
+		{header: "items=a-b"},
+	}
+	for _, test := range tests {
+		rng, ok := parseItemRange(test.header, 10)
+		assert.Equal(t, test.ok, ok, test.header)
+		assert.Equal(t, test.expected, rng, test.header)
+	}
+}
+
+func TestHandlePaginationRange(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	serve := func(target, rng string) (*httptest.ResponseRecorder, []string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if rng != "" {
+			req.Header.Set(headerRange, rng)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		var res Response
+		var keys []string
+		if json.Unmarshal(rec.Body.Bytes(), &res) == nil {
+			for _, entry := range res.Data {
+				keys = append(keys, entry.SortKey)
+			}
+		}
+		return rec, keys
+	}
+
+	rec, keys := serve("/paginate?key_condition=test", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, rangeUnit, rec.Header().Get(headerAcceptRanges))
+	assert.Len(t, keys, 10)
+
+	// A range within one page
+	rec, keys = serve("/paginate?key_condition=test", "items=4-7")
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "items 4-7/*", rec.Header().Get(headerContentRange))
+	assert.Equal(t, []string{"item0005", "item0006", "item0007", "item0008"}, keys)
+
+	// A range across two pages, with the total counted
+	rec, keys = serve("/paginate?key_condition=test&include_count=true", "items=3-5")
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "items 3-5/10", rec.Header().Get(headerContentRange))
+	assert.Equal(t, []string{"item0004", "item0005", "item0006"}, keys)
+
+	// A range running past the results is shortened
+	rec, keys = serve("/paginate?key_condition=test", "items=8-11")
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	assert.Equal(t, "items 8-9/*", rec.Header().Get(headerContentRange))
+	assert.Equal(t, []string{"item0009", "item0010"}, keys)
+
+	rec, _ = serve("/paginate?key_condition=test", "items=20-29")
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, rec.Code)
+	assert.Equal(t, "items */*", rec.Header().Get(headerContentRange))
+
+	// Ranges that can't be served are ignored
+	rec, keys = serve("/paginate?key_condition=test&pagesize=2", "items=-5")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"item0001", "item0002"}, keys)
+	rec, _ = serve("/paginate?key_condition=test&cursor=", "items=0-1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisTimeout bounds the Redis calls whose context has no deadline
+	redisTimeout = time.Second
+	// redisPoolSize is the number of idle connections kept to Redis
+	redisPoolSize = 8
+)
+
+// redisStore is a pagination.KVStore on a Redis server. It speaks the Redis protocol itself, over a
+// small pool of connections, for the handful of commands the page cache needs.
+type redisStore struct {
+	addr     string
+	username string
+	password string
+	db       int
+	conns    chan *redisConn
+}
+
+type redisConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// redisError is an error reply of the server
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// newRedisStore creates a store for a URL of the form redis://[[user]:password@]host[:port][/db]
+func newRedisStore(rawURL string) (*redisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "redis" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Redis URL %q", rawURL)
+	}
+	s := &redisStore{addr: u.Host, conns: make(chan *redisConn, redisPoolSize)}
+	if u.Port() == "" {
+		s.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		s.username = u.User.Username()
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil || s.db < 0 {
+			return nil, fmt.Errorf("invalid Redis database %q", db)
+		}
+	}
+	return s, nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %v to GET", reply)
+	}
+	return value, true, nil
+}
+
+func (s *redisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := s.do(ctx, args...)
+	return err
+}
+
+func (s *redisStore) Incr(ctx context.Context, key string) (int64, error) {
+	reply, err := s.do(ctx, "INCR", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected reply %v to INCR", reply)
+	}
+	return n, nil
+}
+
+// do runs a command on a pooled connection, dropping the connection when the command fails short of
+// an error reply
+func (s *redisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, err := s.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := conn.do(ctx, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		return nil, err
+	}
+	select {
+	case s.conns <- conn:
+	default:
+		conn.conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection, or dials a new one, authenticated and on the database of the URL
+func (s *redisStore) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	default:
+	}
+
+	ctx, cancel := redisContext(ctx)
+	defer cancel()
+	var dialer net.Dialer
+	netConn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: netConn, reader: bufio.NewReader(netConn)}
+	if s.password != "" {
+		args := []string{"AUTH", s.password}
+		if s.username != "" {
+			args = []string{"AUTH", s.username, s.password}
+		}
+		if _, err := conn.do(ctx, args...); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := conn.do(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// redisContext bounds a call without a deadline by redisTimeout
+func redisContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, redisTimeout)
+}
+
+// do sends a command and reads its reply
+func (c *redisConn) do(ctx context.Context, args ...string) (interface{}, error) {
+	ctx, cancel := redisContext(ctx)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var command strings.Builder
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, command.String()); err != nil {
+		return nil, err
+	}
+	return c.reply()
+}
+
+// reply reads a simple string, error, integer or bulk string reply; a missing bulk string is nil
+func (c *redisConn) reply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves GET, SET, INCR, AUTH and SELECT from memory, recording the commands it receives
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	r := &fakeRedis{listener: listener, password: password, values: map[string]string{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := r.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		r.mu.Lock()
+		r.commands = append(r.commands, strings.Join(args, " "))
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[len(args)-1] == r.password
+			reply = "+OK\r\n"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+			reply = "+OK\r\n"
+		case args[0] == "GET":
+			if value, ok := r.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case args[0] == "SET":
+			r.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case args[0] == "INCR":
+			n, _ := strconv.Atoi(r.values[args[1]])
+			r.values[args[1]] = strconv.Itoa(n + 1)
+			reply = fmt.Sprintf(":%d\r\n", n+1)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		r.mu.Unlock()
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	redis := newFakeRedis(t, "secret")
+	store, err := newRedisStore("redis://:secret@" + redis.listener.Addr().String() + "/2")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, ok, err := store.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.Set(ctx, "key", []byte("value\r\nwith a newline"), 30*time.Second))
+	value, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "value\r\nwith a newline", string(value))
+
+	n, err := store.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n)
+	n, err = store.Incr(ctx, "counter")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+
+	// Connections are authenticated and select the database once
+	assert.Equal(t, []string{"AUTH secret", "SELECT 2", "GET missing", "SET key value\r\nwith a newline PX 30000", "GET key", "INCR counter", "INCR counter"}, redis.commands)
+
+	store, err = newRedisStore("redis://:wrong@" + redis.listener.Addr().String())
+	require.NoError(t, err)
+	_, _, err = store.Get(ctx, "key")
+	assert.EqualError(t, err, "redis: WRONGPASS invalid password")
+
+	for _, url := range []string{"http://localhost", "redis://", "redis://localhost/db"} {
+		_, err := newRedisStore(url)
+		assert.Error(t, err, url)
+	}
+}
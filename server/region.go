@@ -0,0 +1,64 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+)
+
+// parseList splits a comma separated list, ignoring blanks
+func parseList(s string) []string {
+	var regions []string
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// replicaClients creates a DynamoDB client pinned to each replica region of the global table
+func replicaClients(cfg aws.Config, regions []string) map[string]DynamoClient {
+	if len(regions) == 0 {
+		return nil
+	}
+
+	clients := make(map[string]DynamoClient, len(regions))
+	for _, region := range regions {
+		region := region
+		clients[region] = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.Region = region
+		})
+	}
+	return clients
+}
+
+// clientFor returns the client serving a request, honoring the optional region parameter and
+// falling back to latency-based replica selection when it is enabled. A cursor issued by a latency
+// routed request stays on the region that served its first page, which is recorded in the request
+// context so the next cursor keeps it. A consistency token keeps the request on the region that served
+// the page it was issued for.
+// It reports false when the region isn't one of the configured replicas.
+func (h *Handler) clientFor(c echo.Context) (DynamoClient, bool) {
+	region := c.QueryParam("region")
+	if token, ok := consistencyFrom(c.Request().Context()); ok && region == "" {
+		return h.pinnedClient(c, token.Region)
+	}
+	if region == "" {
+		if h.router == nil {
+			return h.client, true
+		}
+		region, _ = splitCursorRegion(c.QueryParam("cursor"))
+		client, ok := h.router.Client(region)
+		if !ok {
+			region, client = h.router.Pick()
+		}
+		c.SetRequest(c.Request().WithContext(withCursorRegion(c.Request().Context(), region)))
+		return client, true
+	}
+
+	client, ok := h.replicas[region]
+	return client, ok
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePaginationRegion(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParam     string
+		expectedStatus int
+		expectReplica  bool
+	}{
+		{name: "Default Region", queryParam: "key_condition=test", expectedStatus: http.StatusOK},
+		{name: "Replica Region", queryParam: "key_condition=test&region=eu-west-1", expectedStatus: http.StatusOK, expectReplica: true},
+		{name: "Unknown Region", queryParam: "key_condition=test&region=ap-south-1", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primary := new(MockDynamoDB)
+			replica := new(MockDynamoDB)
+			if test.expectedStatus == http.StatusOK {
+				target := primary
+				if test.expectReplica {
+					target = replica
+				}
+				target.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{}, nil)
+			}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/paginate?"+test.queryParam, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := &Handler{client: primary, replicas: map[string]DynamoClient{"eu-west-1": replica}}
+			_ = handler.handlePagination(c)
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+			primary.AssertExpectations(t)
+			replica.AssertExpectations(t)
+		})
+	}
+}
+
+func TestParseList(t *testing.T) {
+	assert.Equal(t, []string{"us-east-1", "eu-west-1"}, parseList(" us-east-1, ,eu-west-1"))
+	assert.Nil(t, parseList(""))
+}
+
+func TestHandlePaginationCursorStaysOnRegion(t *testing.T) {
+	fixtures, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	other := new(MockDynamoDB)
+	router := NewReplicaRouter(map[string]DynamoClient{"eu-west-1": fixtures, "us-east-1": other})
+	handler := &Handler{router: router}
+
+	get := func(query string) Response {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		return response
+	}
+
+	first := get("key_condition=test&pagesize=2&cursor=")
+	assert.True(t, strings.HasPrefix(first.NextCursor, "eu-west-1."))
+
+	// us-east-1 becomes the faster replica, but the cursor keeps going to eu-west-1
+	router.observe("eu-west-1", 80*time.Millisecond, nil)
+	router.observe("us-east-1", 10*time.Millisecond, nil)
+	next := get("key_condition=test&pagesize=2&cursor=" + url.QueryEscape(first.NextCursor))
+	assert.Equal(t, []Entry{{KeyCond: "test", SortKey: "item3"}}, next.Data)
+	other.AssertExpectations(t)
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// anonymousCaller is the caller of requests without an API key
+const anonymousCaller = "anonymous"
+
+// RequestContext is what the service knows about a request: who makes it and what it may spend. The
+// RequestContexts middleware records it for every request, the middleware resolving tenants, priority
+// classes and timeouts fill it in, and the handlers, the paginator, the caches and the policies read
+// it instead of state of their own.
+type RequestContext struct {
+	// RequestID is the X-Request-Id of the request
+	RequestID string
+	// Caller identifies the caller in logs and reports: the name of its tenant, a digest of its API key,
+	// or "anonymous"
+	Caller string
+	// Tenant is the configuration applying to the caller, nil when tenants aren't configured
+	Tenant *TenantConfig
+	// Principal is who the caller authenticated as, nil when authentication isn't configured
+	Principal *Principal
+	// Roles are what the caller may do beyond reading, e.g. "writer"
+	Roles []string
+	// Profile is the response profile /paginate pages are served in, empty for the default
+	Profile string
+	// Priority is the priority class the request is served in, empty when classes aren't configured
+	Priority string
+	Budget   Budget
+}
+
+// Budget is what a request may spend. Zero values are unlimited.
+type Budget struct {
+	// Deadline is when the request times out
+	Deadline time.Time
+	// WalkTimeout bounds the round trips serving one page, CallTimeout each DynamoDB call
+	WalkTimeout time.Duration
+	CallTimeout time.Duration
+	// MaxPageSize caps the pagesize parameter
+	MaxPageSize int64
+	// MaxRoundTrips bounds the DynamoDB round trips walking to one page
+	MaxRoundTrips int64
+}
+
+type requestContextKey struct{}
+
+// withRequestContext records the context of a request
+func withRequestContext(ctx context.Context, rc *RequestContext) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, rc)
+}
+
+// requestContextFrom returns the context recorded in ctx, or nil
+func requestContextFrom(ctx context.Context) *RequestContext {
+	rc, _ := ctx.Value(requestContextKey{}).(*RequestContext)
+	return rc
+}
+
+// RequestContexts records the context of every request, identifying its caller by its API key until
+// a later middleware resolves it further. It runs after the RequestID middleware.
+func RequestContexts(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ensureRequestContext(c)
+		return next(c)
+	}
+}
+
+// ensureRequestContext returns the context of a request, recording a new one when there is none, so
+// middleware registered on its own still fills one in
+func ensureRequestContext(c echo.Context) *RequestContext {
+	if rc := requestContextFrom(c.Request().Context()); rc != nil {
+		return rc
+	}
+	requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+	if requestID == "" {
+		requestID = c.Request().Header.Get(echo.HeaderXRequestID)
+	}
+	rc := &RequestContext{RequestID: requestID, Caller: anonymousCaller}
+	if key := requestAPIKey(c.Request()); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		rc.Caller = "key:" + hex.EncodeToString(sum[:6])
+	}
+	c.SetRequest(c.Request().WithContext(withRequestContext(c.Request().Context(), rc)))
+	return rc
+}
+
+// HasRole reports whether the caller has a role. A nil context has none.
+func (rc *RequestContext) HasRole(role string) bool {
+	if rc == nil {
+		return false
+	}
+	for _, r := range rc.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// capPageSize limits a page size to the budget's maximum
+func (b Budget) capPageSize(pageSize int64) int64 {
+	if b.MaxPageSize > 0 && pageSize > b.MaxPageSize {
+		return b.MaxPageSize
+	}
+	return pageSize
+}
+
+// budget returns the budget of the request, the zero Budget when there is no context
+func (rc *RequestContext) budget() Budget {
+	if rc == nil {
+		return Budget{}
+	}
+	return rc.Budget
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContexts(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{
+		"tenants": {"acme": {"api_keys": ["acme-key"], "roles": ["writer"], "max_page_size": 50, "max_round_trips": 20}}
+	}`))
+	require.NoError(t, err)
+	timeouts, err := ParseTimeouts([]byte(`{"default": {"request": "5s", "walk": "2s", "dynamodb": "1s"}}`))
+	require.NoError(t, err)
+
+	var rc *RequestContext
+	e := echo.New()
+	e.Use(RequestContexts, tenants.Middleware, timeouts.Middleware)
+	e.GET("/paginate", func(c echo.Context) error {
+		rc = requestContextFrom(c.Request().Context())
+		return c.NoContent(http.StatusOK)
+	})
+	serve := func(key string) {
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		req.Header.Set(echo.HeaderXRequestID, "req-1")
+		if key != "" {
+			req.Header.Set(headerAPIKey, key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	serve("acme-key")
+	require.NotNil(t, rc)
+	assert.Equal(t, "req-1", rc.RequestID)
+	assert.Equal(t, "acme", rc.Caller)
+	assert.Same(t, tenants.Tenants["acme"], rc.Tenant)
+	assert.True(t, rc.HasRole(roleWriter))
+	assert.EqualValues(t, 50, rc.Budget.MaxPageSize)
+	assert.EqualValues(t, 20, rc.Budget.MaxRoundTrips)
+	assert.Equal(t, 2*time.Second, rc.Budget.WalkTimeout)
+	assert.Equal(t, time.Second, rc.Budget.CallTimeout)
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), rc.Budget.Deadline, time.Second)
+
+	// Callers without a tenant of their own are told apart by their key
+	serve("other-key")
+	assert.Regexp(t, `^key:[0-9a-f]{12}$`, rc.Caller)
+	assert.False(t, rc.HasRole(roleWriter))
+	serve("")
+	assert.Equal(t, anonymousCaller, rc.Caller)
+
+	assert.False(t, (*RequestContext)(nil).HasRole(roleWriter))
+}
+
+func TestWriteGuardAdmitsWriters(t *testing.T) {
+	guard, err := NewWriteGuard([]string{"secret"}, nil)
+	require.NoError(t, err)
+	tenants, err := ParseTenants([]byte(`{
+		"tenants": {
+			"acme": {"api_keys": ["acme-key"], "roles": ["writer"]},
+			"globex": {"api_keys": ["globex-key"]}
+		}
+	}`))
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(RequestContexts, tenants.Middleware)
+	e.DELETE("/items/:key_cond/:sort_key", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, guard.Middleware)
+	serve := func(key string) int {
+		req := httptest.NewRequest(http.MethodDelete, "/items/test/item1", nil)
+		req.Header.Set(headerAPIKey, key)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, serve("acme-key"))
+	assert.Equal(t, http.StatusForbidden, serve("globex-key"))
+}
+
+func TestHandlePaginationRoundTripBudget(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{"defaults": {"max_round_trips": 2}}`))
+	require.NoError(t, err)
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	e := echo.New()
+	serve := func(target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, tenants.Middleware(handler.handlePagination)(e.NewContext(req, rec)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve("/paginate?key_condition=test&pagesize=2&page=2").Code)
+	rec := serve("/paginate?key_condition=test&pagesize=2&page=3")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, errorBody(t, rec).Message, "round trip budget")
+}
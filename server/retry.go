@@ -0,0 +1,197 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+)
+
+const (
+	defaultRetryAttempts  = 5
+	defaultRetryBaseDelay = 25 * time.Millisecond
+	defaultRetryMaxDelay  = time.Second
+	defaultRetryDeadline  = 5 * time.Second
+)
+
+// transientCodes are the error codes of DynamoDB failures that a later attempt can get past
+var transientCodes = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"RequestLimitExceeded":                   true,
+	"ThrottlingException":                    true,
+	"InternalServerError":                    true,
+	"InternalFailure":                        true,
+	"ServiceUnavailable":                     true,
+}
+
+// RetryPolicy retries the reads DynamoDB throttled or failed transiently, with exponential backoff and
+// full jitter: the nth retry waits a random time up to BaseDelay*2^(n-1), capped at MaxDelay
+type RetryPolicy struct {
+	// MaxAttempts is the most calls made for one read, the first included
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Deadline bounds the time spent on one read across its attempts; a retry that would wait past it
+	// isn't made
+	Deadline time.Duration
+
+	// metrics counts the retries made, when set
+	metrics *Metrics
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// loadRetryPolicy reads DYNAMO_RETRY_MAX_ATTEMPTS, DYNAMO_RETRY_BASE_DELAY, DYNAMO_RETRY_MAX_DELAY and
+// DYNAMO_RETRY_DEADLINE. A single attempt disables retries.
+func loadRetryPolicy() (*RetryPolicy, error) {
+	p := &RetryPolicy{MaxAttempts: defaultRetryAttempts, BaseDelay: defaultRetryBaseDelay, MaxDelay: defaultRetryMaxDelay, Deadline: defaultRetryDeadline}
+	if v := os.Getenv("DYNAMO_RETRY_MAX_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil || attempts < 1 {
+			return nil, fmt.Errorf("invalid DYNAMO_RETRY_MAX_ATTEMPTS %q", v)
+		}
+		p.MaxAttempts = attempts
+	}
+	for _, d := range []struct {
+		name  string
+		value *time.Duration
+	}{
+		{"DYNAMO_RETRY_BASE_DELAY", &p.BaseDelay},
+		{"DYNAMO_RETRY_MAX_DELAY", &p.MaxDelay},
+		{"DYNAMO_RETRY_DEADLINE", &p.Deadline},
+	} {
+		if v := os.Getenv(d.name); v != "" {
+			duration, err := time.ParseDuration(v)
+			if err != nil || duration <= 0 {
+				return nil, fmt.Errorf("invalid %s %q", d.name, v)
+			}
+			*d.value = duration
+		}
+	}
+	if p.MaxAttempts == 1 {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// isThrottled reports whether DynamoDB rejected a call for exceeding the table's or the account's
+// throughput
+func isThrottled(err error) bool {
+	var provisioned *types.ProvisionedThroughputExceededException
+	var limit *types.RequestLimitExceeded
+	var apiErr smithy.APIError
+	return errors.As(err, &provisioned) || errors.As(err, &limit) ||
+		(errors.As(err, &apiErr) && apiErr.ErrorCode() == "ThrottlingException")
+}
+
+// retryable reports whether a failed call may succeed when made again: throttling, DynamoDB server
+// errors, and network errors and attempt timeouts while ctx, the context of the read, still has time
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return transientCodes[apiErr.ErrorCode()]
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// backoff returns the wait before the given retry, 1 for the first
+func (p *RetryPolicy) backoff(retry int) time.Duration {
+	limit := p.MaxDelay
+	if shift := retry - 1; shift < 32 {
+		if d := p.BaseDelay << shift; d > 0 && d < limit {
+			limit = d
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return time.Duration(p.rand.Int63n(int64(limit) + 1))
+}
+
+// do calls call, an operation on table, until it succeeds, fails for good, or runs out of attempts or time
+func (p *RetryPolicy) do(ctx context.Context, table, operation string, call func() error) error {
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		err := call()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(ctx, err) {
+			return err
+		}
+		wait := p.backoff(attempt)
+		if time.Since(start)+wait > p.Deadline {
+			return err
+		}
+		p.metrics.retried(table, operation, err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// retryClient retries the reads of a client under a RetryPolicy. Writes aren't retried, as a failed
+// write may have been applied.
+type retryClient struct {
+	DynamoClient
+	policy *RetryPolicy
+}
+
+// retry wraps a client so its reads are retried
+func (p *RetryPolicy) retry(client DynamoClient) DynamoClient {
+	return &retryClient{DynamoClient: client, policy: p}
+}
+
+// noSDKRetries leaves retrying to the policy, so attempts don't multiply with those of the SDK
+func noSDKRetries(optFns []func(*dynamodb.Options)) []func(*dynamodb.Options) {
+	return append(optFns[:len(optFns):len(optFns)], func(o *dynamodb.Options) { o.RetryMaxAttempts = 1 })
+}
+
+func (c *retryClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	var out *dynamodb.QueryOutput
+	err := c.policy.do(ctx, aws.StringValue(params.TableName), "Query", func() error {
+		var err error
+		out, err = c.DynamoClient.Query(ctx, params, noSDKRetries(optFns)...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	var out *dynamodb.ScanOutput
+	err := c.policy.do(ctx, aws.StringValue(params.TableName), "Scan", func() error {
+		var err error
+		out, err = c.DynamoClient.Scan(ctx, params, noSDKRetries(optFns)...)
+		return err
+	})
+	return out, err
+}
+
+func (c *retryClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	var out *dynamodb.GetItemOutput
+	err := c.policy.do(ctx, aws.StringValue(params.TableName), "GetItem", func() error {
+		var err error
+		out, err = c.DynamoClient.GetItem(ctx, params, noSDKRetries(optFns)...)
+		return err
+	})
+	return out, err
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/smithy-go"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy(attempts int) *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: attempts, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Deadline: time.Second}
+}
+
+func TestRetryClientRetriesThrottling(t *testing.T) {
+	throttled := &types.ProvisionedThroughputExceededException{Message: aws.String("Rate exceeded")}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), throttled).Twice()
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Count: 1}, nil).Once()
+
+	out, err := testRetryPolicy(5).retry(mockDynamoDB).Query(context.Background(), keyConditionQuery("test"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), out.Count)
+	mockDynamoDB.AssertNumberOfCalls(t, "Query", 3)
+}
+
+func TestRetryClientGivesUp(t *testing.T) {
+	throttled := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Scan", mock.Anything, mock.Anything).Return((*dynamodb.ScanOutput)(nil), throttled)
+
+	_, err := testRetryPolicy(3).retry(mockDynamoDB).Scan(context.Background(), &dynamodb.ScanInput{})
+	assert.True(t, isThrottled(err))
+	mockDynamoDB.AssertNumberOfCalls(t, "Scan", 3)
+
+	// A retry that would wait past the deadline isn't made
+	policy := testRetryPolicy(10)
+	policy.Deadline, policy.BaseDelay, policy.MaxDelay = time.Millisecond, time.Hour, time.Hour
+	_, err = policy.retry(mockDynamoDB).Scan(context.Background(), &dynamodb.ScanInput{})
+	require.Error(t, err)
+	mockDynamoDB.AssertNumberOfCalls(t, "Scan", 4)
+}
+
+func TestRetryClientPermanentErrors(t *testing.T) {
+	invalid := &smithy.GenericAPIError{Code: "ValidationException", Message: "Invalid KeyConditionExpression"}
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), invalid)
+
+	client := testRetryPolicy(5).retry(mockDynamoDB)
+	_, err := client.Query(context.Background(), keyConditionQuery("test"))
+	assert.Equal(t, invalid, err)
+	mockDynamoDB.AssertNumberOfCalls(t, "Query", 1)
+
+	// Timeouts of the read itself aren't retried, only those of an attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.False(t, retryable(ctx, context.DeadlineExceeded))
+	assert.True(t, retryable(context.Background(), context.DeadlineExceeded))
+	assert.True(t, retryable(context.Background(), &types.InternalServerError{}))
+	assert.False(t, retryable(context.Background(), errors.New("marshal failed")))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for retry := 1; retry <= 40; retry++ {
+		wait := policy.backoff(retry)
+		assert.GreaterOrEqual(t, wait, time.Duration(0))
+		assert.LessOrEqual(t, wait, 50*time.Millisecond)
+		if retry == 1 {
+			assert.LessOrEqual(t, wait, 10*time.Millisecond)
+		}
+	}
+}
+
+func TestHandlePaginationThrottled(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), &types.RequestLimitExceeded{})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+
+	handler := &Handler{client: testRetryPolicy(2).retry(mockDynamoDB)}
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, ErrorDetail{Code: "throttled", Message: "DynamoDB is throttling requests"}, errorBody(t, rec))
+	mockDynamoDB.AssertNumberOfCalls(t, "Query", 2)
+}
+
+func TestLoadRetryPolicy(t *testing.T) {
+	policy, err := loadRetryPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, defaultRetryAttempts, policy.MaxAttempts)
+
+	t.Setenv("DYNAMO_RETRY_MAX_ATTEMPTS", "3")
+	t.Setenv("DYNAMO_RETRY_DEADLINE", "2s")
+	policy, err = loadRetryPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, 3, policy.MaxAttempts)
+	assert.Equal(t, 2*time.Second, policy.Deadline)
+	assert.Equal(t, defaultRetryBaseDelay, policy.BaseDelay)
+
+	t.Setenv("DYNAMO_RETRY_MAX_ATTEMPTS", "1")
+	policy, err = loadRetryPolicy()
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+
+	t.Setenv("DYNAMO_RETRY_MAX_ATTEMPTS", "0")
+	_, err = loadRetryPolicy()
+	assert.Error(t, err)
+
+	t.Setenv("DYNAMO_RETRY_MAX_ATTEMPTS", "")
+	t.Setenv("DYNAMO_RETRY_BASE_DELAY", "fast")
+	_, err = loadRetryPolicy()
+	assert.Error(t, err)
+}
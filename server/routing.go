@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+const (
+	// latencySmoothing is the weight of the newest sample in the moving latency average
+	latencySmoothing = 0.2
+	// unhealthyAfter consecutive failures take a replica out of rotation
+	unhealthyAfter = 3
+	// unhealthyCooldown is how long an unhealthy replica is skipped before it is tried again
+	unhealthyCooldown = 30 * time.Second
+)
+
+type replicaStats struct {
+	latency     time.Duration
+	samples     int
+	failures    int
+	lastFailure time.Time
+}
+
+// ReplicaRouter picks the healthy replica with the lowest observed latency
+type ReplicaRouter struct {
+	mu      sync.Mutex
+	regions []string
+	clients map[string]DynamoClient
+	stats   map[string]*replicaStats
+	now     func() time.Time
+}
+
+// NewReplicaRouter creates a router over the given replica clients
+func NewReplicaRouter(replicas map[string]DynamoClient) *ReplicaRouter {
+	r := &ReplicaRouter{
+		clients: map[string]DynamoClient{},
+		stats:   map[string]*replicaStats{},
+		now:     time.Now,
+	}
+	for region, client := range replicas {
+		r.regions = append(r.regions, region)
+		r.clients[region] = &routedClient{DynamoClient: client, router: r, region: region}
+		r.stats[region] = &replicaStats{}
+	}
+	sort.Strings(r.regions)
+	return r
+}
+
+// Client returns the client of a replica, reporting false when the router doesn't have it
+func (r *ReplicaRouter) Client(region string) (DynamoClient, bool) {
+	client, ok := r.clients[region]
+	return client, ok
+}
+
+// Pick returns the replica to use for the next request. Replicas without samples are preferred so
+// every region gets measured; when all replicas are unhealthy the least recently failed one is used.
+func (r *ReplicaRouter) Pick() (string, DynamoClient) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	best := ""
+	fallback := ""
+	for _, region := range r.regions {
+		s := r.stats[region]
+		if s.failures >= unhealthyAfter && now.Sub(s.lastFailure) < unhealthyCooldown {
+			if fallback == "" || s.lastFailure.Before(r.stats[fallback].lastFailure) {
+				fallback = region
+			}
+			continue
+		}
+		if best == "" || r.faster(region, best) {
+			best = region
+		}
+	}
+
+	if best == "" {
+		best = fallback
+	}
+	return best, r.clients[best]
+}
+
+func (r *ReplicaRouter) faster(a, b string) bool {
+	sa, sb := r.stats[a], r.stats[b]
+	if sa.samples == 0 || sb.samples == 0 {
+		return sa.samples < sb.samples
+	}
+	return sa.latency < sb.latency
+}
+
+// observe records the outcome of one call against a replica
+func (r *ReplicaRouter) observe(region string, elapsed time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.stats[region]
+	if err != nil {
+		s.failures++
+		s.lastFailure = r.now()
+		return
+	}
+
+	s.failures = 0
+	if s.samples == 0 {
+		s.latency = elapsed
+	} else {
+		s.latency = time.Duration(latencySmoothing*float64(elapsed) + (1-latencySmoothing)*float64(s.latency))
+	}
+	s.samples++
+}
+
+// routedClient measures the reads made through a replica client
+type routedClient struct {
+	DynamoClient
+	router *ReplicaRouter
+	region string
+}
+
+func (c *routedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	start := time.Now()
+	out, err := c.DynamoClient.Query(ctx, params, optFns...)
+	c.router.observe(c.region, time.Since(start), err)
+	return out, err
+}
+
+func (c *routedClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	start := time.Now()
+	out, err := c.DynamoClient.GetItem(ctx, params, optFns...)
+	c.router.observe(c.region, time.Since(start), err)
+	return out, err
+}
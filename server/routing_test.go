@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaRouterPick(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	router := NewReplicaRouter(map[string]DynamoClient{
+		"eu-west-1": new(MockDynamoDB),
+		"us-east-1": new(MockDynamoDB),
+	})
+	router.now = func() time.Time { return now }
+
+	// Unmeasured replicas are tried first
+	router.observe("eu-west-1", 80*time.Millisecond, nil)
+	region, _ := router.Pick()
+	assert.Equal(t, "us-east-1", region)
+
+	// Then the fastest one wins
+	router.observe("us-east-1", 20*time.Millisecond, nil)
+	region, _ = router.Pick()
+	assert.Equal(t, "us-east-1", region)
+
+	// Repeated failures take a replica out of rotation until the cooldown passes
+	for i := 0; i < unhealthyAfter; i++ {
+		router.observe("us-east-1", 0, errors.New("timeout"))
+	}
+	region, _ = router.Pick()
+	assert.Equal(t, "eu-west-1", region)
+
+	now = now.Add(unhealthyCooldown)
+	region, _ = router.Pick()
+	assert.Equal(t, "us-east-1", region)
+}
+
+func TestReplicaRouterAllUnhealthy(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	router := NewReplicaRouter(map[string]DynamoClient{
+		"eu-west-1": new(MockDynamoDB),
+		"us-east-1": new(MockDynamoDB),
+	})
+	router.now = func() time.Time { return now }
+
+	for i := 0; i < unhealthyAfter; i++ {
+		router.observe("eu-west-1", 0, errors.New("timeout"))
+	}
+	now = now.Add(time.Second)
+	for i := 0; i < unhealthyAfter; i++ {
+		router.observe("us-east-1", 0, errors.New("timeout"))
+	}
+
+	region, client := router.Pick()
+	assert.Equal(t, "eu-west-1", region)
+	assert.NotNil(t, client)
+}
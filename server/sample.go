@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultSampleLimit    = 1000
+	maxSampleLimit        = 10000
+	defaultSampleSegments = 16
+	maxSampleSegments     = 1000
+	// maxTrackedValues caps the distinct values remembered per attribute when estimating cardinality
+	maxTrackedValues = 1000
+	// hotPartitionCount is the number of busiest partitions listed in a sample report
+	hotPartitionCount = 10
+	// queryPageBytes is the most data a single Query or Scan call returns
+	queryPageBytes = 1 << 20
+)
+
+// sampleShuffle orders the scan segments visited by a sample, replaced in tests
+var sampleShuffle = rand.Perm
+
+// SampleReport describes a bounded random sample of the table
+type SampleReport struct {
+	ItemsSampled  int64
+	SegmentsRead  int
+	TotalSegments int
+	// AvgItemSize and MaxItemSize are estimated in bytes the way DynamoDB accounts item size
+	AvgItemSize float64
+	MaxItemSize int64
+	// ItemsPerPage estimates how many items fit in one 1 MB Query page
+	ItemsPerPage  int64
+	Attributes    map[string]AttributeSample
+	HotPartitions []PartitionSample `json:",omitempty"`
+}
+
+// AttributeSample describes how one attribute appears in the sample
+type AttributeSample struct {
+	Present int64
+	// Distinct counts the distinct scalar values seen, up to maxTrackedValues
+	Distinct       int
+	DistinctCapped bool `json:",omitempty"`
+}
+
+// PartitionSample is a partition key and its share of the sample
+type PartitionSample struct {
+	Key   string
+	Items int64
+	Share float64
+}
+
+func (h *Handler) handleSample(c echo.Context) error {
+	limit, ok := boundedParam(c.QueryParam("limit"), defaultSampleLimit, maxSampleLimit)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid limit parameter")
+	}
+	segments, ok := boundedParam(c.QueryParam("segments"), defaultSampleSegments, maxSampleSegments)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid segments parameter")
+	}
+
+	items, read, err := sampleItems(c.Request().Context(), h.client, int(limit), int(segments))
+	if err != nil {
+		reqErr := dynamoError("Error in DynamoDB scan", err)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+
+	report := buildSampleReport(items)
+	report.SegmentsRead = read
+	report.TotalSegments = int(segments)
+	for i := range report.HotPartitions {
+		report.HotPartitions[i].Key = h.exposeKey(PartitionKeyPosition, report.HotPartitions[i].Key)
+	}
+	return c.JSON(http.StatusOK, report)
+}
+
+// boundedParam parses a positive integer parameter, falling back to def when empty and capping it at max
+func boundedParam(s string, def, max int64) (int64, bool) {
+	if s == "" {
+		return def, true
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || v <= 0 {
+		return 0, false
+	}
+	if v > max {
+		v = max
+	}
+	return v, true
+}
+
+// sampleItems reads the first page of randomly chosen scan segments until limit items are collected.
+// Segments split the key space by hash, so the sample spreads over the table without a full scan.
+func sampleItems(ctx context.Context, client DynamoClient, limit, segments int) ([]map[string]types.AttributeValue, int, error) {
+	perSegment := int32(limit / segments)
+	if perSegment == 0 {
+		perSegment = 1
+	}
+
+	var items []map[string]types.AttributeValue
+	read := 0
+	for _, segment := range sampleShuffle(segments) {
+		if len(items) >= limit {
+			break
+		}
+
+		out, err := client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:     &tableName,
+			Segment:       aws.Int32(int32(segment)),
+			TotalSegments: aws.Int32(int32(segments)),
+			Limit:         &perSegment,
+		})
+		if err != nil {
+			return nil, read, err
+		}
+		read++
+		items = append(items, out.Items...)
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, read, nil
+}
+
+// buildSampleReport computes size, cardinality and partition statistics for sampled items
+func buildSampleReport(items []map[string]types.AttributeValue) SampleReport {
+	report := SampleReport{
+		ItemsSampled: int64(len(items)),
+		Attributes:   map[string]AttributeSample{},
+	}
+
+	values := map[string]map[string]bool{}
+	partitions := map[string]int64{}
+	var totalSize int64
+
+	for _, item := range items {
+		size := itemSize(item)
+		totalSize += size
+		if size > report.MaxItemSize {
+			report.MaxItemSize = size
+		}
+
+		for name, av := range item {
+			stats := report.Attributes[name]
+			stats.Present++
+
+			if value, ok := scalarValue(av); ok {
+				if values[name] == nil {
+					values[name] = map[string]bool{}
+				}
+				if len(values[name]) < maxTrackedValues {
+					values[name][value] = true
+				} else if !values[name][value] {
+					stats.DistinctCapped = true
+				}
+				stats.Distinct = len(values[name])
+			}
+			report.Attributes[name] = stats
+		}
+
+		if pk := attributeString(item[tableKeys.PartitionKey]); pk != "" {
+			partitions[pk]++
+		}
+	}
+
+	if len(items) > 0 {
+		report.AvgItemSize = float64(totalSize) / float64(len(items))
+		report.ItemsPerPage = int64(queryPageBytes / report.AvgItemSize)
+	}
+
+	for key, n := range partitions {
+		report.HotPartitions = append(report.HotPartitions, PartitionSample{Key: key, Items: n, Share: float64(n) * 100 / float64(len(items))})
+	}
+	sort.Slice(report.HotPartitions, func(i, j int) bool {
+		a, b := report.HotPartitions[i], report.HotPartitions[j]
+		return a.Items > b.Items || (a.Items == b.Items && a.Key < b.Key)
+	})
+	if len(report.HotPartitions) > hotPartitionCount {
+		report.HotPartitions = report.HotPartitions[:hotPartitionCount]
+	}
+
+	return report
+}
+
+// scalarValue renders scalar attribute values for counting distinct values
+func scalarValue(av types.AttributeValue) (string, bool) {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return "S:" + v.Value, true
+	case *types.AttributeValueMemberN:
+		return "N:" + v.Value, true
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL:" + strconv.FormatBool(v.Value), true
+	}
+	return "", false
+}
+
+// itemSize estimates the size DynamoDB accounts for an item: attribute names plus their values
+func itemSize(item map[string]types.AttributeValue) int64 {
+	var size int64
+	for name, av := range item {
+		size += int64(len(name)) + attributeSize(av)
+	}
+	return size
+}
+
+func attributeSize(av types.AttributeValue) int64 {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return int64(len(v.Value))
+	case *types.AttributeValueMemberN:
+		return numberSize(v.Value)
+	case *types.AttributeValueMemberB:
+		return int64(len(v.Value))
+	case *types.AttributeValueMemberBOOL, *types.AttributeValueMemberNULL:
+		return 1
+	case *types.AttributeValueMemberSS:
+		var size int64
+		for _, s := range v.Value {
+			size += int64(len(s))
+		}
+		return size
+	case *types.AttributeValueMemberNS:
+		var size int64
+		for _, n := range v.Value {
+			size += numberSize(n)
+		}
+		return size
+	case *types.AttributeValueMemberBS:
+		var size int64
+		for _, b := range v.Value {
+			size += int64(len(b))
+		}
+		return size
+	case *types.AttributeValueMemberM:
+		return 3 + itemSize(v.Value) + int64(len(v.Value))
+	case *types.AttributeValueMemberL:
+		size := int64(3 + len(v.Value))
+		for _, e := range v.Value {
+			size += attributeSize(e)
+		}
+		return size
+	}
+	return 0
+}
+
+// numberSize approximates a number's size: one byte per two significant digits plus one
+func numberSize(n string) int64 {
+	digits := 0
+	for _, r := range n {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	return int64((digits+1)/2 + 1)
+}
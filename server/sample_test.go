@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleSample(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	shuffle := sampleShuffle
+	sampleShuffle = func(n int) []int {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = n - 1 - i
+		}
+		return order
+	}
+	defer func() { sampleShuffle = shuffle }()
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedItems int64
+		expectedRead  int
+	}{
+		{name: "whole table", query: "segments=2", expectedItems: 4, expectedRead: 2},
+		{name: "limited", query: "limit=2&segments=2", expectedItems: 2, expectedRead: 2},
+		{name: "stops early", query: "limit=1&segments=4", expectedItems: 1, expectedRead: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/admin/sample?"+test.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := &Handler{client: client}
+			require.NoError(t, handler.handleSample(c))
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			var report SampleReport
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+			assert.Equal(t, test.expectedItems, report.ItemsSampled)
+			assert.Equal(t, test.expectedRead, report.SegmentsRead)
+		})
+	}
+}
+
+func TestHandleSampleInvalidLimit(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/admin/sample?limit=-1", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handleSample(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestBuildSampleReport(t *testing.T) {
+	item := func(pk, sk string, count string) map[string]types.AttributeValue {
+		return map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: pk},
+			"sort_key": &types.AttributeValueMemberS{Value: sk},
+			"count":    &types.AttributeValueMemberN{Value: count},
+		}
+	}
+
+	report := buildSampleReport([]map[string]types.AttributeValue{
+		item("a", "1", "10"),
+		item("a", "2", "10"),
+		item("a", "3", "200"),
+		item("b", "1", "10"),
+	})
+
+	assert.EqualValues(t, 4, report.ItemsSampled)
+	// key_cond (8+1) + sort_key (8+1) + count (5+2 or 5+3)
+	assert.Equal(t, 25.25, report.AvgItemSize)
+	assert.EqualValues(t, 26, report.MaxItemSize)
+	assert.EqualValues(t, 41527, report.ItemsPerPage)
+	assert.Equal(t, AttributeSample{Present: 4, Distinct: 2}, report.Attributes["key_cond"])
+	assert.Equal(t, AttributeSample{Present: 4, Distinct: 3}, report.Attributes["sort_key"])
+	assert.Equal(t, AttributeSample{Present: 4, Distinct: 2}, report.Attributes["count"])
+	assert.Equal(t, []PartitionSample{{Key: "a", Items: 3, Share: 75}, {Key: "b", Items: 1, Share: 25}}, report.HotPartitions)
+}
+
+func TestAttributeSize(t *testing.T) {
+	assert.EqualValues(t, 5, attributeSize(&types.AttributeValueMemberS{Value: "hello"}))
+	assert.EqualValues(t, 2, attributeSize(&types.AttributeValueMemberN{Value: "12"}))
+	assert.EqualValues(t, 1, attributeSize(&types.AttributeValueMemberBOOL{Value: true}))
+	assert.EqualValues(t, 3+2+4, attributeSize(&types.AttributeValueMemberL{Value: []types.AttributeValue{
+		&types.AttributeValueMemberS{Value: "ab"},
+		&types.AttributeValueMemberS{Value: "cd"},
+	}}))
+}
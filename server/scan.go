@@ -0,0 +1,104 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// handleScan serves pages of the whole table, read with Scan, for browsing items without knowing their
+// partition. Pages, cursors, search and select work like on /paginate; scans have no order.
+func (h *Handler) handleScan(c echo.Context) error {
+	client, params, reqErr := h.scanRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	ctx := c.Request().Context()
+	p := pagination.NewScan[Entry](client, tableName, tableKeys)
+	p.Decode = h.decoder(params)
+	p.Indexes = h.indexes
+	p.Plans = h.plans
+	p.Cache = h.pages
+	// Scans of a sort key range or prefix are what a secondary index could serve with queries
+	advisable := h.advisor != nil && (params.SortRange != nil || (params.Search != "" && params.SearchMode == "prefix"))
+	var progress pagination.Progress
+	if advisable {
+		p.OnProgress = func(pr pagination.Progress) { progress = pr }
+	}
+	walk, cancel := walkContext(ctx)
+	defer cancel()
+	res, err := p.GetPage(walk, params)
+	if err != nil {
+		reqErr := pageError(err)
+		var queryErr *pagination.QueryError
+		if errors.As(err, &queryErr) {
+			reqErr = dynamoError("Error in DynamoDB scan", queryErr.Err)
+		}
+		reqErr = walkError(ctx, walk, reqErr)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	if advisable {
+		h.advisor.record(patternScanFilter, params, h.keysFor(params.IndexName).SortKey, progress, len(res.Data))
+	}
+	if res.NextCursor, reqErr = h.pinCursor(ctx, pinTotal(res.NextCursor, res.TotalItems)); reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	tenantFrom(ctx).redact(res.Data)
+
+	return h.respondPage(c, res)
+}
+
+// scanRequest validates the parameters of a scan and returns the client serving it
+func (h *Handler) scanRequest(c echo.Context) (DynamoClient, Params, *requestError) {
+	if c.QueryParam("orderby") != "" {
+		return nil, Params{}, &requestError{status: http.StatusBadRequest, message: "Scans can't be ordered"}
+	}
+	if reqErr := parseConsistencyToken(c); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+
+	client, ok := h.clientFor(c)
+	if !ok {
+		return nil, Params{}, &requestError{status: http.StatusBadRequest, message: "Invalid region parameter"}
+	}
+
+	params, reqErr := h.extractParams(c)
+	if reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	params.Region = h.servedRegion(c)
+	if reqErr := parsePassthrough(&params, c.QueryParam("select"), c.QueryParam("return_consumed_capacity")); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	sortRange, reqErr := parseSortRange(c)
+	if reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	params.SortRange = sortRange
+	if params.IndexName = indexParam(c); params.IndexName != "" {
+		if _, ok := h.indexes[params.IndexName]; !ok {
+			return nil, Params{}, &requestError{status: http.StatusBadRequest, message: "Invalid index parameter"}
+		}
+	}
+	if reqErr := h.parseRequestFilter(c, &params, true); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	if reqErr := h.parseConsistent(c, &params); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	if reqErr := checkSortKey(params, h.keysFor(params.IndexName)); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	if reqErr := h.checkHiddenKeys(params); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	if reqErr := h.parseCursor(c, h.keysFor(params.IndexName), "", &params); reqErr != nil {
+		return nil, Params{}, reqErr
+	}
+	return client, params, nil
+}
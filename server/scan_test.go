@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleScan(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"a", "b"}, 2))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	scan := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/scan?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handleScan(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := scan("page=2&pagesize=3")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "b", SortKey: "item0002"}}, response.Data)
+
+	// Cursors continue across partitions
+	rec = scan("pagesize=3&cursor=")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Len(t, response.Data, 3)
+	require.NotEmpty(t, response.NextCursor)
+	rec = scan("pagesize=3&cursor=" + url.QueryEscape(response.NextCursor))
+	require.Equal(t, http.StatusOK, rec.Code)
+	response = Response{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "b", SortKey: "item0002"}}, response.Data)
+	assert.Empty(t, response.NextCursor)
+
+	rec = scan("select=count")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, int64(4), *response.Meta.Count)
+
+	rec = scan("search=0002")
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "a", SortKey: "item0002"}, {KeyCond: "b", SortKey: "item0002"}}, response.Data)
+
+	assert.Equal(t, http.StatusBadRequest, scan("orderby=-sort_key").Code)
+	assert.Equal(t, http.StatusBadRequest, scan("select=everything").Code)
+}
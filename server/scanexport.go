@@ -0,0 +1,219 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultScanSegments = 4
+	maxScanSegments     = 1000
+)
+
+// ParallelScan configures the parallel scans of table exports
+type ParallelScan struct {
+	// Segments is the number of segments a table is split into when the request doesn't set it
+	Segments int
+	// Workers is the most segments read at once per export
+	Workers int
+}
+
+// loadParallelScan reads EXPORT_SCAN_SEGMENTS and EXPORT_SCAN_WORKERS, which defaults to the number of
+// segments
+func loadParallelScan() (ParallelScan, error) {
+	scan := ParallelScan{Segments: defaultScanSegments}
+	if v := os.Getenv("EXPORT_SCAN_SEGMENTS"); v != "" {
+		segments, err := strconv.Atoi(v)
+		if err != nil || segments <= 0 || segments > maxScanSegments {
+			return scan, fmt.Errorf("invalid EXPORT_SCAN_SEGMENTS %q", v)
+		}
+		scan.Segments = segments
+	}
+	if v := os.Getenv("EXPORT_SCAN_WORKERS"); v != "" {
+		workers, err := strconv.Atoi(v)
+		if err != nil || workers <= 0 {
+			return scan, fmt.Errorf("invalid EXPORT_SCAN_WORKERS %q", v)
+		}
+		scan.Workers = workers
+	}
+	return scan, nil
+}
+
+// segmentPage is a page read from one segment of a parallel scan
+type segmentPage struct {
+	items    []Entry
+	warnings []pagination.Warning
+	scanned  int64
+	err      error
+}
+
+// handleScanExport streams every item of the table as JSON lines. The table is read with a parallel
+// scan: its segments are paged through by a pool of workers and their pages are written as they
+// arrive, so items come in no particular order. It takes the parameters of /scan, except page, pagesize
+// and cursor, and segments to override the number of segments.
+func (h *Handler) handleScanExport(c echo.Context) error {
+	if _, ok := c.QueryParams()["cursor"]; ok {
+		return respondError(c, http.StatusBadRequest, "Parallel exports can't be resumed from a cursor")
+	}
+	client, params, reqErr := h.scanRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if params.Select == "count" {
+		return respondError(c, http.StatusBadRequest, "Counts can't be exported")
+	}
+	def := int64(h.parallelScan.Segments)
+	if def <= 0 {
+		def = defaultScanSegments
+	}
+	segments, ok := boundedParam(c.QueryParam("segments"), def, maxScanSegments)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid segments parameter")
+	}
+	params.CursorMode = true
+	params.PageSize = streamPageSize
+	params.IncludeCount = false
+
+	workers := h.parallelScan.Workers
+	if workers <= 0 || workers > int(segments) {
+		workers = int(segments)
+	}
+	// Each worker gets its share of the read rate, so the export as a whole stays within it
+	limits := h.stream
+	limits.RCUPerSecond /= float64(workers)
+
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	defer cancel()
+	pages := make(chan segmentPage)
+	next := make(chan int32)
+	go func() {
+		defer close(next)
+		for segment := int32(0); segment < int32(segments); segment++ {
+			select {
+			case next <- segment:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for segment := range next {
+				if !h.scanSegment(ctx, client, params, segment, int32(segments), limits, pages) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(pages)
+	}()
+
+	// A scan that fails before the stream starts is answered with an error status
+	page, ok := <-pages
+	if ok && page.err != nil {
+		reqErr := pageError(page.err)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set("Trailer", streamStatusTrailer)
+	res.WriteHeader(http.StatusOK)
+
+	status := "complete"
+	defer func() {
+		res.Header().Set(streamStatusTrailer, status)
+	}()
+
+	encoder := json.NewEncoder(res)
+	var scanned int64
+	for ; ok; page, ok = <-pages {
+		if page.err != nil {
+			c.Logger().Error(pageError(page.err))
+			status = "error"
+			return nil
+		}
+		for _, w := range page.warnings {
+			c.Logger().Warnf("%s %v: %s", w.Code, w.Key, w.Message)
+		}
+		tenantFrom(ctx).redact(page.items)
+		for _, entry := range page.items {
+			if err := encoder.Encode(entry); err != nil {
+				status = "error"
+				return err
+			}
+		}
+		res.Flush()
+
+		scanned += page.scanned
+		if h.stream.ScanBudget > 0 && scanned >= h.stream.ScanBudget {
+			status = "truncated"
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		status = "error"
+	}
+	return nil
+}
+
+// scanSegment pages through one segment of a parallel scan, sending its pages. It reports false when
+// the export stopped, because it was cancelled or this segment failed.
+func (h *Handler) scanSegment(ctx context.Context, client DynamoClient, params Params, segment, segments int32, limits StreamLimits, pages chan<- segmentPage) bool {
+	name, keys := h.schema()
+	p := pagination.NewSegmentScan[Entry](client, name, keys, segment, segments)
+	p.Decode = h.decoder(params)
+	p.Indexes = h.indexes
+	p.Plans = h.plans
+	var roundTrip pagination.Progress
+	p.OnProgress = func(progress pagination.Progress) { roundTrip = progress }
+
+	send := func(page segmentPage) bool {
+		select {
+		case pages <- page:
+			return page.err == nil
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		res, err := p.GetPage(ctx, params)
+		if err != nil {
+			return send(segmentPage{err: err})
+		}
+		page := segmentPage{items: res.Data, scanned: roundTrip.ItemsScanned}
+		if res.Meta != nil {
+			page.warnings = res.Meta.Warnings
+		}
+		if !send(page) {
+			return false
+		}
+		if !res.HasMore {
+			return true
+		}
+
+		if params.Cursor, err = pagination.DecodeCursor(res.NextCursor); err != nil {
+			return send(segmentPage{err: err})
+		}
+		rcu := roundTrip.ConsumedRCU
+		if err := limits.throttle(ctx, &types.ConsumedCapacity{CapacityUnits: &rcu}); err != nil {
+			return false
+		}
+	}
+}
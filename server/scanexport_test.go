@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// segmentClient scans one item at a time and records the segments scanned
+type segmentClient struct {
+	DynamoClient
+	mu       sync.Mutex
+	segments map[int32]int
+}
+
+func (s *segmentClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	s.mu.Lock()
+	s.segments[*params.Segment]++
+	s.mu.Unlock()
+
+	input := *params
+	limit := int32(1)
+	input.Limit = &limit
+	return s.DynamoClient.Scan(ctx, &input, optFns...)
+}
+
+func TestHandleScanExport(t *testing.T) {
+	fixtures, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	client := &segmentClient{DynamoClient: fixtures, segments: map[int32]int{}}
+	handler := &Handler{client: client, parallelScan: ParallelScan{Segments: 3, Workers: 2}}
+
+	export := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/export/scan?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handleScanExport(e.NewContext(req, rec)))
+		return rec
+	}
+
+	rec := export("")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		`{"key_cond":"other","sort_key":"item1"}`,
+		`{"key_cond":"test","sort_key":"item1"}`,
+		`{"key_cond":"test","sort_key":"item2"}`,
+		`{"key_cond":"test","sort_key":"item3"}`,
+	}, lines)
+	assert.Equal(t, "complete", rec.Header().Get(streamStatusTrailer))
+	assert.Len(t, client.segments, 3)
+
+	client.segments = map[int32]int{}
+	rec = export("segments=2&search=item1&search_mode=client")
+	assert.Equal(t, 2, strings.Count(rec.Body.String(), "\n"))
+	assert.Len(t, client.segments, 2)
+
+	// The scan budget truncates the export
+	handler.stream = StreamLimits{ScanBudget: 1}
+	rec = export("segments=1")
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"))
+	assert.Equal(t, "truncated", rec.Header().Get(streamStatusTrailer))
+
+	assert.Equal(t, http.StatusBadRequest, export("cursor=").Code)
+	assert.Equal(t, http.StatusBadRequest, export("select=count").Code)
+	assert.Equal(t, http.StatusBadRequest, export("segments=none").Code)
+	assert.Equal(t, http.StatusBadRequest, export("orderby=sort_key").Code)
+}
+
+func TestHandleScanExportError(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Scan", mock.Anything, mock.Anything).Return((*dynamodb.ScanOutput)(nil), errors.New("DynamoDB scan error"))
+	handler := &Handler{client: mockDynamoDB}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/export/scan", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handleScanExport(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get(streamStatusTrailer))
+}
+
+func TestLoadParallelScan(t *testing.T) {
+	scan, err := loadParallelScan()
+	require.NoError(t, err)
+	assert.Equal(t, ParallelScan{Segments: defaultScanSegments}, scan)
+
+	t.Setenv("EXPORT_SCAN_SEGMENTS", "16")
+	t.Setenv("EXPORT_SCAN_WORKERS", "4")
+	scan, err = loadParallelScan()
+	require.NoError(t, err)
+	assert.Equal(t, ParallelScan{Segments: 16, Workers: 4}, scan)
+
+	t.Setenv("EXPORT_SCAN_SEGMENTS", "0")
+	_, err = loadParallelScan()
+	assert.Error(t, err)
+}
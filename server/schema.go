@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SchemaPolicy controls what happens to items that fail schema validation
+type SchemaPolicy string
+
+const (
+	// SchemaPolicyDrop silently removes invalid items from the page
+	SchemaPolicyDrop SchemaPolicy = "drop"
+	// SchemaPolicyFlag keeps invalid items and reports them in the response metadata
+	SchemaPolicyFlag SchemaPolicy = "flag"
+	// SchemaPolicyFail fails the whole request when an invalid item is found
+	SchemaPolicyFail SchemaPolicy = "fail"
+)
+
+// ParseSchemaPolicy converts a configuration value into a SchemaPolicy, defaulting to flag
+func ParseSchemaPolicy(s string) (SchemaPolicy, error) {
+	switch SchemaPolicy(s) {
+	case "", SchemaPolicyFlag:
+		return SchemaPolicyFlag, nil
+	case SchemaPolicyDrop, SchemaPolicyFail:
+		return SchemaPolicy(s), nil
+	}
+	return "", fmt.Errorf("unknown schema policy %q", s)
+}
+
+// Schema is the subset of JSON Schema used to validate table items
+type Schema struct {
+	Type                 string             `json:"type,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// LoadSchema reads a JSON Schema document from disk
+func LoadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSchema(data)
+}
+
+// ParseSchema decodes a JSON Schema document and compiles its patterns
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	for _, p := range s.Properties {
+		if err := p.compile(); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile()
+	}
+	return nil
+}
+
+// Validate checks a decoded item against the schema and returns one message per violation
+func (s *Schema) Validate(v interface{}) []string {
+	var violations []string
+	s.validate("$", v, &violations)
+	return violations
+}
+
+func (s *Schema) validate(path string, v interface{}, violations *[]string) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if s.Type != "" && !matchesType(s.Type, v) {
+		fail("expected %s, got %s", s.Type, typeName(v))
+		return
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		fail("value not in enum")
+	}
+
+	switch val := v.(type) {
+	case string:
+		if s.MinLength != nil && len(val) < *s.MinLength {
+			fail("shorter than %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && len(val) > *s.MaxLength {
+			fail("longer than %d", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(val) {
+			fail("does not match pattern %q", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			fail("less than %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			fail("greater than %v", *s.Maximum)
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				fail("missing required attribute %q", name)
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					fail("unexpected attribute %q", name)
+				}
+				continue
+			}
+			prop.validate(path+"."+name, val[name], violations)
+		}
+	}
+
+	if s.Items != nil {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && !isBinary(v) {
+			for i := 0; i < rv.Len(); i++ {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), violations)
+			}
+		}
+	}
+}
+
+func matchesType(want string, v interface{}) bool {
+	switch want {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == float64(int64(f))
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return typeName(v) == want
+}
+
+// typeName reports the JSON Schema type of a value decoded by attributevalue
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []byte:
+		return "binary"
+	}
+	if reflect.ValueOf(v).Kind() == reflect.Slice {
+		return "array"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func isBinary(v interface{}) bool {
+	_, ok := v.([]byte)
+	return ok
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validation pairs a table's item schema with the policy applied to items that violate it
+type Validation struct {
+	Schema *Schema
+	Policy SchemaPolicy
+}
+
+// check decodes a raw DynamoDB item and validates it against the schema. Required attributes are
+// only enforced for complete items.
+func (v *Validation) check(item map[string]types.AttributeValue, partial bool) ([]string, error) {
+	var doc map[string]interface{}
+	if err := attributevalue.UnmarshalMap(item, &doc); err != nil {
+		return nil, err
+	}
+
+	schema := v.Schema
+	if partial {
+		withoutRequired := *schema
+		withoutRequired.Required = nil
+		schema = &withoutRequired
+	}
+	return schema.Validate(doc), nil
+}
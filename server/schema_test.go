@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"type": "object",
+	"required": ["key_cond", "sort_key", "status"],
+	"properties": {
+		"key_cond": {"type": "string"},
+		"sort_key": {"type": "string", "pattern": "^item"},
+		"status": {"type": "string", "enum": ["active", "inactive"]},
+		"count": {"type": "integer", "minimum": 0}
+	}
+}`
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		item       map[string]interface{}
+		violations []string
+	}{
+		{
+			name: "Valid Item",
+			item: map[string]interface{}{"key_cond": "test", "sort_key": "item1", "status": "active", "count": float64(3)},
+		},
+		{
+			name:       "Missing Required",
+			item:       map[string]interface{}{"key_cond": "test", "sort_key": "item1"},
+			violations: []string{`$: missing required attribute "status"`},
+		},
+		{
+			name:       "Wrong Type",
+			item:       map[string]interface{}{"key_cond": "test", "sort_key": "item1", "status": "active", "count": "3"},
+			violations: []string{"$.count: expected integer, got string"},
+		},
+		{
+			name:       "Pattern And Enum",
+			item:       map[string]interface{}{"key_cond": "test", "sort_key": "other", "status": "deleted"},
+			violations: []string{`$.sort_key: does not match pattern "^item"`, "$.status: value not in enum"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.violations, schema.Validate(test.item))
+		})
+	}
+}
+
+func TestHandlePaginationSchemaPolicy(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	require.NoError(t, err)
+
+	output := &dynamodb.QueryOutput{
+		Items: []map[string]types.AttributeValue{
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}, "status": &types.AttributeValueMemberS{Value: "active"}},
+			{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item2"}},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		policy           SchemaPolicy
+		expectedStatus   int
+		expectedResponse Response
+	}{
+		{
+			name:           "Drop",
+			policy:         SchemaPolicyDrop,
+			expectedStatus: http.StatusOK,
+			expectedResponse: Response{
+				Data: []Entry{{KeyCond: "test", SortKey: "item1"}},
+				Page: 1,
+				Size: 1,
+			},
+		},
+		{
+			name:           "Flag",
+			policy:         SchemaPolicyFlag,
+			expectedStatus: http.StatusOK,
+			expectedResponse: Response{
+				Data: []Entry{{KeyCond: "test", SortKey: "item1"}, {KeyCond: "test", SortKey: "item2"}},
+				Page: 1,
+				Size: 2,
+				Meta: &Meta{Warnings: []Warning{{
+					Code:    "schema_violation",
+					Message: `$: missing required attribute "status"`,
+					Key:     map[string]string{"key_cond": "test", "sort_key": "item2"},
+				}}},
+			},
+		},
+		{
+			name:           "Fail",
+			policy:         SchemaPolicyFail,
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mockDynamoDB := new(MockDynamoDB)
+			mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(output, nil)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := &Handler{client: mockDynamoDB, validation: &Validation{Schema: schema, Policy: test.policy}}
+			_ = handler.handlePagination(c)
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+			if test.expectedStatus == http.StatusOK {
+				var response Response
+				assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.Equal(t, test.expectedResponse, response)
+			}
+		})
+	}
+}
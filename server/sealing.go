@@ -0,0 +1,184 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	defaultCursorTTL = 24 * time.Hour
+	// sealedSigned and sealedEncrypted are the first byte of signed and of encrypted cursors
+	sealedSigned    byte = 1
+	sealedEncrypted byte = 2
+)
+
+var (
+	errInvalidCursor = errors.New("cursor isn't valid")
+	errExpiredCursor = errors.New("cursor has expired")
+)
+
+// CursorSealer protects the cursors handed to clients, which hold table keys: they are signed with
+// HMAC-SHA256, or encrypted with AES-GCM, so clients can neither forge a cursor to start reading at an
+// arbitrary key nor, when encrypted, read the keys. Sealed cursors expire after TTL.
+type CursorSealer struct {
+	// keys are the current key and any previous ones still accepted, to rotate the secret
+	keys    []sealingKey
+	encrypt bool
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// sealingKey holds the keys derived from one secret
+type sealingKey struct {
+	mac  []byte
+	aead cipher.AEAD
+}
+
+// NewCursorSealer creates a sealer for secret. Cursors sealed with one of the previous secrets are still
+// opened. A zero ttl makes cursors never expire.
+func NewCursorSealer(secret string, previous []string, encrypt bool, ttl time.Duration) (*CursorSealer, error) {
+	if secret == "" {
+		return nil, errors.New("empty cursor secret")
+	}
+	if ttl < 0 {
+		return nil, errors.New("cursor TTL can't be negative")
+	}
+	s := &CursorSealer{encrypt: encrypt, ttl: ttl, now: time.Now}
+	for _, secret := range append([]string{secret}, previous...) {
+		key, err := newSealingKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = append(s.keys, key)
+	}
+	return s, nil
+}
+
+// newSealingKey derives separate signing and encryption keys from a secret
+func newSealingKey(secret string) (sealingKey, error) {
+	derive := func(purpose string) []byte {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(purpose))
+		return mac.Sum(nil)
+	}
+	block, err := aes.NewCipher(derive("cursor encryption"))
+	if err != nil {
+		return sealingKey{}, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return sealingKey{}, err
+	}
+	return sealingKey{mac: derive("cursor signing"), aead: aead}, nil
+}
+
+// loadCursorSealer seals cursors with CURSOR_SECRET when it is set. CURSOR_PREVIOUS_SECRETS lists comma
+// separated secrets still accepted, CURSOR_ENCRYPT=true encrypts cursors and CURSOR_TTL sets how long
+// they are valid, in Go syntax ("24h" by default, "0" for ever).
+func loadCursorSealer() (*CursorSealer, error) {
+	secret := os.Getenv("CURSOR_SECRET")
+	if secret == "" {
+		return nil, nil
+	}
+	ttl := defaultCursorTTL
+	if v := os.Getenv("CURSOR_TTL"); v != "" {
+		var err error
+		if ttl, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("invalid CURSOR_TTL %q: %w", v, err)
+		}
+	}
+	return NewCursorSealer(secret, parseList(os.Getenv("CURSOR_PREVIOUS_SECRETS")), os.Getenv("CURSOR_ENCRYPT") == "true", ttl)
+}
+
+// seal turns a cursor into a token, leaving cursors unchanged without a sealer. The token is base64url
+// encoded, so it never contains the dot separating a region.
+func (s *CursorSealer) seal(cursor string) (string, error) {
+	if s == nil || cursor == "" {
+		return cursor, nil
+	}
+
+	var expires uint64
+	if s.ttl > 0 {
+		expires = uint64(s.now().Add(s.ttl).Unix())
+	}
+	payload := binary.BigEndian.AppendUint64(nil, expires)
+	payload = append(payload, cursor...)
+
+	key := s.keys[0]
+	var token []byte
+	if s.encrypt {
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		token = append([]byte{sealedEncrypted}, nonce...)
+		token = key.aead.Seal(token, nonce, payload, token[:1])
+	} else {
+		token = append([]byte{sealedSigned}, payload...)
+		token = append(token, key.sign(token)...)
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// open verifies a token and returns the cursor sealed in it. Without a sealer tokens are cursors.
+func (s *CursorSealer) open(token string) (string, error) {
+	if s == nil {
+		return token, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) == 0 {
+		return "", errInvalidCursor
+	}
+
+	payload, ok := s.verify(data)
+	if !ok || len(payload) < 8 {
+		return "", errInvalidCursor
+	}
+	if expires := binary.BigEndian.Uint64(payload); expires != 0 && s.now().Unix() > int64(expires) {
+		return "", errExpiredCursor
+	}
+	return string(payload[8:]), nil
+}
+
+// verify checks a token against every accepted key and returns its payload. Both signed and encrypted
+// tokens are accepted, so turning encryption on or off doesn't break the cursors already handed out.
+func (s *CursorSealer) verify(data []byte) ([]byte, bool) {
+	for _, key := range s.keys {
+		switch data[0] {
+		case sealedSigned:
+			if len(data) < 1+sha256.Size {
+				return nil, false
+			}
+			signed, mac := data[:len(data)-sha256.Size], data[len(data)-sha256.Size:]
+			if hmac.Equal(mac, key.sign(signed)) {
+				return signed[1:], true
+			}
+		case sealedEncrypted:
+			if len(data) < 1+key.aead.NonceSize() {
+				return nil, false
+			}
+			nonce := data[1 : 1+key.aead.NonceSize()]
+			if payload, err := key.aead.Open(nil, nonce, data[1+len(nonce):], data[:1]); err == nil {
+				return payload, true
+			}
+		default:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+func (k sealingKey) sign(data []byte) []byte {
+	mac := hmac.New(sha256.New, k.mac)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
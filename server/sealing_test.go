@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursorSealer(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		s, err := NewCursorSealer("secret", nil, encrypt, time.Hour)
+		require.NoError(t, err)
+
+		token, err := s.seal("eyJrZXkiOiJ2YWx1ZSJ9~3")
+		require.NoError(t, err)
+		assert.NotContains(t, token, ".")
+		assert.NotContains(t, token, "~")
+		assert.Equal(t, !encrypt, strings.Contains(decodeToken(t, token), "eyJrZXkiOiJ2YWx1ZSJ9"))
+
+		cursor, err := s.open(token)
+		require.NoError(t, err)
+		assert.Equal(t, "eyJrZXkiOiJ2YWx1ZSJ9~3", cursor)
+
+		// Changing any byte of the token invalidates it
+		data, _ := base64.RawURLEncoding.DecodeString(token)
+		for i := range data {
+			tampered := append([]byte(nil), data...)
+			tampered[i] ^= 1
+			_, err := s.open(base64.RawURLEncoding.EncodeToString(tampered))
+			assert.ErrorIs(t, err, errInvalidCursor)
+		}
+		_, err = s.open("garbage!")
+		assert.ErrorIs(t, err, errInvalidCursor)
+
+		other, err := NewCursorSealer("other", nil, encrypt, time.Hour)
+		require.NoError(t, err)
+		_, err = other.open(token)
+		assert.ErrorIs(t, err, errInvalidCursor)
+
+		s.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+		_, err = s.open(token)
+		assert.ErrorIs(t, err, errExpiredCursor)
+	}
+}
+
+func TestCursorSealerRotation(t *testing.T) {
+	old, err := NewCursorSealer("old", nil, false, 0)
+	require.NoError(t, err)
+	token, err := old.seal("cursor")
+	require.NoError(t, err)
+
+	rotated, err := NewCursorSealer("new", []string{"old"}, false, 0)
+	require.NoError(t, err)
+	cursor, err := rotated.open(token)
+	require.NoError(t, err)
+	assert.Equal(t, "cursor", cursor)
+
+	// Cursors signed before encryption was turned on are still accepted
+	encrypted, err := NewCursorSealer("old", nil, true, 0)
+	require.NoError(t, err)
+	cursor, err = encrypted.open(token)
+	require.NoError(t, err)
+	assert.Equal(t, "cursor", cursor)
+}
+
+func TestLoadCursorSealer(t *testing.T) {
+	s, err := loadCursorSealer()
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	t.Setenv("CURSOR_SECRET", "secret")
+	t.Setenv("CURSOR_ENCRYPT", "true")
+	s, err = loadCursorSealer()
+	require.NoError(t, err)
+	assert.True(t, s.encrypt)
+	assert.Equal(t, defaultCursorTTL, s.ttl)
+
+	t.Setenv("CURSOR_TTL", "soon")
+	_, err = loadCursorSealer()
+	assert.Error(t, err)
+}
+
+func TestHandlePaginationSealedCursor(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	sealer, err := NewCursorSealer("secret", nil, false, time.Hour)
+	require.NoError(t, err)
+	handler := &Handler{client: client, cursors: sealer}
+
+	get := func(query string) (*httptest.ResponseRecorder, Response) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+		var response Response
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		}
+		return rec, response
+	}
+
+	rec, first := get("key_condition=test&pagesize=2&cursor=")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotEmpty(t, first.NextCursor)
+
+	rec, second := get("key_condition=test&pagesize=2&cursor=" + url.QueryEscape(first.NextCursor))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Len(t, second.Data, 1)
+	assert.Equal(t, "item3", second.Data[0].SortKey)
+
+	// Unsealed cursors, as handed out without a secret, are rejected
+	unsealed, err := sealer.open(first.NextCursor)
+	require.NoError(t, err)
+	rec, _ = get("key_condition=test&pagesize=2&cursor=" + url.QueryEscape(unsealed))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Invalid cursor parameter", errorBody(t, rec).Message)
+
+	sealer.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	rec, _ = get("key_condition=test&pagesize=2&cursor=" + url.QueryEscape(first.NextCursor))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "Cursor has expired", errorBody(t, rec).Message)
+}
+
+func decodeToken(t *testing.T, token string) string {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	require.NoError(t, err)
+	return string(data)
+}
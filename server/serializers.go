@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultFormat = "json"
+	// headerHasMore and headerNextCursor carry the continuation of a page in formats that only hold items
+	headerHasMore    = "X-Has-More"
+	headerNextCursor = "X-Next-Cursor"
+)
+
+// Serializer renders pages in one output format. A page is a Response, or an Envelope on the v2 routes.
+type Serializer interface {
+	// ContentType is the media type of the rendered pages, matched against the Accept header
+	ContentType() string
+	// Serialize renders a page. Formats without room for the page's metadata can put it in header.
+	Serialize(page interface{}, header http.Header) ([]byte, error)
+}
+
+// serializers are the output formats of pages, by the name the format parameter selects them with
+var serializers = map[string]Serializer{
+	"json":   jsonSerializer{},
+	"ndjson": ndjsonSerializer{},
+	"csv":    csvSerializer{},
+}
+
+// RegisterSerializer adds an output format, or replaces the one registered under name. Formats are
+// registered before the server starts.
+func RegisterSerializer(name string, s Serializer) {
+	serializers[strings.ToLower(name)] = s
+}
+
+// selectSerializer picks the format of a page: the one named by the format parameter, or else the first
+// registered media type the Accept header lists, falling back to JSON
+func selectSerializer(c echo.Context) (string, Serializer, *requestError) {
+	if format := strings.ToLower(c.QueryParam("format")); format != "" {
+		s, ok := serializers[format]
+		if !ok {
+			return "", nil, &requestError{status: http.StatusBadRequest, message: "Invalid format parameter"}
+		}
+		return format, s, nil
+	}
+
+	byType := map[string]string{}
+	for _, name := range sortedFormats() {
+		contentType, _, _ := mime.ParseMediaType(serializers[name].ContentType())
+		if _, ok := byType[contentType]; !ok {
+			byType[contentType] = name
+		}
+	}
+	for _, accepted := range acceptedTypes(c.Request().Header.Get(echo.HeaderAccept)) {
+		if name, ok := byType[accepted]; ok {
+			return name, serializers[name], nil
+		}
+	}
+	return defaultFormat, serializers[defaultFormat], nil
+}
+
+// sortedFormats lists the registered formats by name, so media types registered twice resolve the same way
+func sortedFormats() []string {
+	names := make([]string, 0, len(serializers))
+	for name := range serializers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// acceptedTypes lists the media types of an Accept header from the most to the least preferred, leaving
+// out the ones it refuses with q=0
+func acceptedTypes(accept string) []string {
+	type acceptedType struct {
+		mediaType string
+		q         float64
+	}
+	var accepted []acceptedType
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			accepted = append(accepted, acceptedType{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].q > accepted[j].q })
+
+	types := make([]string, len(accepted))
+	for i, a := range accepted {
+		types[i] = a.mediaType
+	}
+	return types
+}
+
+// pageRows is what formats that only hold items carry of a page
+type pageRows struct {
+	items      []Entry
+	hasMore    bool
+	nextCursor string
+}
+
+// rowsOf returns the items of a page and where it continues, or false for bodies that aren't pages
+func rowsOf(page interface{}) (pageRows, bool) {
+	switch p := page.(type) {
+	case Response:
+		return pageRows{items: p.Data, hasMore: p.HasMore, nextCursor: p.NextCursor}, true
+	case Envelope:
+		items, ok := p.Data.([]Entry)
+		return pageRows{items: items, hasMore: p.Meta.HasMore, nextCursor: p.Meta.NextCursor}, ok
+	}
+	return pageRows{}, false
+}
+
+// setHeaders moves the continuation of a page to the response headers
+func (r pageRows) setHeaders(header http.Header) {
+	header.Set(headerHasMore, strconv.FormatBool(r.hasMore))
+	if r.nextCursor != "" {
+		header.Set(headerNextCursor, r.nextCursor)
+	}
+}
+
+// jsonSerializer renders pages as a JSON document, the default format
+type jsonSerializer struct{}
+
+func (jsonSerializer) ContentType() string {
+	return echo.MIMEApplicationJSON
+}
+
+func (jsonSerializer) Serialize(page interface{}, header http.Header) ([]byte, error) {
+	return json.Marshal(page)
+}
+
+// ndjsonSerializer renders the items of a page as newline-delimited JSON, one item per line
+type ndjsonSerializer struct{}
+
+func (ndjsonSerializer) ContentType() string {
+	return "application/x-ndjson"
+}
+
+func (ndjsonSerializer) Serialize(page interface{}, header http.Header) ([]byte, error) {
+	rows, ok := rowsOf(page)
+	if !ok {
+		return nil, errors.New("ndjson only renders pages of items")
+	}
+	rows.setHeaders(header)
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, item := range rows.items {
+		if err := encoder.Encode(item); err != nil {
+			return nil, err
+		}
+	}
+	return body.Bytes(), nil
+}
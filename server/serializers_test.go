@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectSerializer(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		accept   string
+		expected string
+	}{
+		{name: "default", expected: "json"},
+		{name: "format parameter", query: "format=NDJSON", accept: echo.MIMEApplicationJSON, expected: "ndjson"},
+		{name: "accept", accept: "application/x-ndjson", expected: "ndjson"},
+		{name: "accept preference", accept: "application/json;q=0.5, application/x-ndjson", expected: "ndjson"},
+		{name: "refused type", accept: "application/x-ndjson;q=0, application/json", expected: "json"},
+		{name: "unregistered type", accept: "text/html, */*", expected: "json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/paginate?"+test.query, nil)
+			req.Header.Set(echo.HeaderAccept, test.accept)
+			format, serializer, reqErr := selectSerializer(echo.New().NewContext(req, httptest.NewRecorder()))
+			require.Nil(t, reqErr)
+			assert.Equal(t, test.expected, format)
+			assert.Equal(t, serializers[test.expected], serializer)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/paginate?format=xml", nil)
+	_, _, reqErr := selectSerializer(echo.New().NewContext(req, httptest.NewRecorder()))
+	require.NotNil(t, reqErr)
+	assert.Equal(t, http.StatusBadRequest, reqErr.status)
+}
+
+func TestHandlePaginationNDJSON(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 3))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	paginate := func(handle func(echo.Context) error, query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, query, nil)
+		req.Header.Set(echo.HeaderAccept, "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		require.NoError(t, handle(e.NewContext(req, rec)))
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		return rec
+	}
+
+	for _, rec := range []*httptest.ResponseRecorder{
+		paginate(handler.handlePagination, "/paginate?key_condition=test&pagesize=2&cursor="),
+		paginate(handler.handlePaginationV2, "/v2/paginate?key_condition=test&pagesize=2&cursor="),
+	} {
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+		assert.Equal(t, "true", rec.Header().Get(headerHasMore))
+		assert.NotEmpty(t, rec.Header().Get(headerNextCursor))
+
+		lines := strings.Split(strings.TrimSuffix(rec.Body.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		for i, line := range lines {
+			var entry Entry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			assert.Equal(t, fmt.Sprintf("item%04d", i+1), entry.SortKey)
+		}
+	}
+}
+
+// keysSerializer renders the sort keys of a page, one per line
+type keysSerializer struct{}
+
+func (keysSerializer) ContentType() string {
+	return "text/plain"
+}
+
+func (keysSerializer) Serialize(page interface{}, header http.Header) ([]byte, error) {
+	rows, _ := rowsOf(page)
+	var keys []string
+	for _, item := range rows.items {
+		keys = append(keys, item.SortKey)
+	}
+	return []byte(strings.Join(keys, "\n")), nil
+}
+
+func TestRegisterSerializer(t *testing.T) {
+	RegisterSerializer("keys", keysSerializer{})
+	defer delete(serializers, "keys")
+
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 2))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&format=keys", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/plain", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "item0001\nitem0002", rec.Body.String())
+}
@@ -0,0 +1,952 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// tableName and tableKeys are the table the service reads and its key attributes, set by ConfigureTable.
+// Queries name the partition key through a placeholder bound to PartitionKey, and items are served with
+// their keys as key_cond and sort_key whatever the attributes are named.
+var tableName = "TableName"
+var tableKeys = pagination.KeySchema{PartitionKey: "key_cond", SortKey: "sort_key"}
+
+const (
+	// localAccessKey is the access key and secret of the static credentials sent to DYNAMO_ENDPOINT
+	localAccessKey = "local"
+	// localRegion is the region of DYNAMO_ENDPOINT when the AWS configuration has none
+	localRegion = "us-east-1"
+)
+
+// The pagination types are served as they are by the handlers
+type (
+	Params   = pagination.Params
+	Entry    = pagination.Entry
+	Response = pagination.Response[Entry]
+	Meta     = pagination.Meta
+	Warning  = pagination.Warning
+	Progress = pagination.Progress
+)
+
+// Options configure a server beyond the environment variables
+type Options struct {
+	// Addr is the address to listen on, ":8080" when empty
+	Addr string
+	// Fixtures serves the API from a fixture file instead of DynamoDB, like MOCK_FIXTURES
+	Fixtures string
+	// Fixture serves the API from these items instead of DynamoDB, taking precedence over Fixtures
+	Fixture *Fixture
+
+	// Table, PartitionKey and SortKey name the table and its key attributes, like TABLE_NAME,
+	// PARTITION_KEY and SORT_KEY
+	Table        string
+	PartitionKey string
+	SortKey      string
+	// NoSortKey serves a table whose primary key is only its partition key, taking precedence over
+	// SortKey
+	NoSortKey bool
+	// Region is the AWS region of the table, overriding the AWS configuration
+	Region string
+	// Endpoint is the URL of a DynamoDB Local or LocalStack to use instead of AWS, like DYNAMO_ENDPOINT
+	Endpoint string
+	// GRPCAddr is the address the gRPC API listens on, like GRPC_ADDR; it isn't served when empty
+	GRPCAddr string
+}
+
+// Run configures the service from the environment and serves it until the HTTP server fails
+func Run(opts Options) error {
+	ConfigureTable(opts)
+	if err := checkSortKeyType(tableKeys); err != nil {
+		return fmt.Errorf("invalid SORT_KEY_TYPE: %w", err)
+	}
+	client, replicas, region, err := newClients(opts)
+	if err != nil {
+		return err
+	}
+
+	validation, err := loadValidation()
+	if err != nil {
+		return fmt.Errorf("failed to load item schema: %w", err)
+	}
+
+	normalizer, err := loadNormalizer()
+	if err != nil {
+		return fmt.Errorf("failed to load normalization rules: %w", err)
+	}
+
+	indexes, err := loadIndexes()
+	if err != nil {
+		return fmt.Errorf("failed to load indexes: %w", err)
+	}
+
+	computed, err := loadComputedFields()
+	if err != nil {
+		return fmt.Errorf("failed to load computed fields: %w", err)
+	}
+	outputTypes, err := loadOutputTypes()
+	if err != nil {
+		return fmt.Errorf("failed to load output types: %w", err)
+	}
+	limits, err := loadParamLimits()
+	if err != nil {
+		return fmt.Errorf("failed to load parameter limits: %w", err)
+	}
+	numbersAsStrings, err := loadNumberEncoding()
+	if err != nil {
+		return fmt.Errorf("failed to load number encoding: %w", err)
+	}
+	signer, err := loadResponseSigner()
+	if err != nil {
+		return fmt.Errorf("failed to load response signing key: %w", err)
+	}
+
+	tables, err := loadTables()
+	if err != nil {
+		return fmt.Errorf("failed to load tables: %w", err)
+	}
+
+	streamLimits, err := loadStreamLimits()
+	if err != nil {
+		return fmt.Errorf("failed to load stream limits: %w", err)
+	}
+
+	batchLimits, err := loadBatchLimits()
+	if err != nil {
+		return fmt.Errorf("failed to load batch limits: %w", err)
+	}
+
+	collections, err := loadCollections()
+	if err != nil {
+		return fmt.Errorf("failed to load collections: %w", err)
+	}
+
+	hotKeys, err := loadHotKeyTracker()
+	if err != nil {
+		return fmt.Errorf("failed to load hot key tracking: %w", err)
+	}
+	timeouts, err := loadTimeouts()
+	if err != nil {
+		return fmt.Errorf("failed to load timeouts: %w", err)
+	}
+	retries, err := loadRetryPolicy()
+	if err != nil {
+		return fmt.Errorf("failed to load retries: %w", err)
+	}
+	dualReads, err := loadDualReads()
+	if err != nil {
+		return fmt.Errorf("failed to load dual reads: %w", err)
+	}
+	setDualReadKeys(dualReads, indexes, tables)
+	priorities, err := loadPriorities()
+	if err != nil {
+		return fmt.Errorf("failed to load priority classes: %w", err)
+	}
+	auth, err := loadAuth()
+	if err != nil {
+		return fmt.Errorf("failed to load authentication: %w", err)
+	}
+	tenants, err := loadTenants()
+	if err != nil {
+		return fmt.Errorf("failed to load tenants: %w", err)
+	}
+	offload, err := loadOffloader(opts.Region)
+	if err != nil {
+		return fmt.Errorf("failed to load response offloading: %w", err)
+	}
+	plans, err := loadPlanCache()
+	if err != nil {
+		return fmt.Errorf("failed to load plan cache: %w", err)
+	}
+	querySalt, logShapes, err := loadQueryLog()
+	if err != nil {
+		return fmt.Errorf("failed to load query logging: %w", err)
+	}
+
+	// Shadow reads bypass the logs and hot key tracking of served requests
+	shadowClient := client
+	if timeouts != nil {
+		shadowClient = timeouts.limit(shadowClient)
+	}
+	shadowClient = readBoth(shadowClient, dualReads)
+
+	metrics := NewMetrics()
+	if retries != nil {
+		retries.metrics = metrics
+	}
+	instrument := func(client DynamoClient) DynamoClient {
+		if timeouts != nil {
+			client = timeouts.limit(client)
+		}
+		// Metrics sit under retries, so every attempt is counted and timed
+		client = metrics.measure(client)
+		// Retries wrap the call timeout, so each attempt gets its own
+		if retries != nil {
+			client = retries.retry(client)
+		}
+		if logShapes {
+			client = logQueryShapes(client, querySalt, log.Default())
+		}
+		// Dual reads go through the instrumented client, so each table read is measured
+		return readBoth(hotKeys.track(client), dualReads)
+	}
+	client = instrument(client)
+	for region, replica := range replicas {
+		replicas[region] = instrument(replica)
+	}
+
+	estimator, err := loadEstimator()
+	if err != nil {
+		return fmt.Errorf("failed to load pre-flight limits: %w", err)
+	}
+
+	shadowReads, err := loadShadowReader(shadowClient)
+	if err != nil {
+		return fmt.Errorf("failed to load shadow reads: %w", err)
+	}
+
+	staging, err := loadStagingShadow(shadowClient, opts.Region)
+	if err != nil {
+		return fmt.Errorf("failed to load staging shadowing: %w", err)
+	}
+
+	writes, err := loadWriteGuard()
+	if err != nil {
+		return fmt.Errorf("failed to load write access: %w", err)
+	}
+
+	cursors, err := loadCursorSealer()
+	if err != nil {
+		return fmt.Errorf("failed to load cursor signing: %w", err)
+	}
+
+	keyIDs, err := loadKeyObfuscator()
+	if err != nil {
+		return fmt.Errorf("failed to load key obfuscation: %w", err)
+	}
+
+	cdn, err := loadCDNCaching()
+	if err != nil {
+		return fmt.Errorf("failed to load CDN caching: %w", err)
+	}
+
+	bodies, err := loadBodyCache()
+	if err != nil {
+		return fmt.Errorf("failed to load body cache: %w", err)
+	}
+
+	pages, err := loadPageCache()
+	if err != nil {
+		return fmt.Errorf("failed to load page cache: %w", err)
+	}
+
+	parallelScan, err := loadParallelScan()
+	if err != nil {
+		return fmt.Errorf("failed to load parallel scans: %w", err)
+	}
+
+	h := Handler{client: client, replicas: replicas, validation: validation, normalizer: normalizer, computed: computed, outputTypes: outputTypes, indexes: indexes, stream: streamLimits, collections: collections, hotKeys: hotKeys, estimator: estimator, shadowReads: shadowReads, plans: plans, offload: offload, cursors: cursors, cdn: cdn, parallelScan: parallelScan, advisor: NewIndexAdvisor()}
+	h.strictDecoding = os.Getenv("STRICT_DECODING") == "true"
+	h.assertOrder = os.Getenv("ASSERT_ORDER") == "true"
+	h.numbersAsStrings = numbersAsStrings
+	h.limits = limits
+	h.region = region
+	h.bodies = bodies
+	h.pages = pages
+	h.staging = staging
+	h.keyIDs = keyIDs
+	h.batch = batchLimits
+	// gRPC calls are admitted and budgeted like HTTP requests
+	if auth != nil {
+		h.callers = append(h.callers, auth.Middleware)
+	}
+	if tenants != nil {
+		h.callers = append(h.callers, tenants.Middleware)
+	}
+	if priorities != nil {
+		h.callers = append(h.callers, priorities.Middleware)
+	}
+	if timeouts != nil {
+		h.callers = append(h.callers, timeouts.Middleware)
+	}
+	if os.Getenv("REPLICA_ROUTING") == "latency" && len(replicas) > 0 {
+		h.router = NewReplicaRouter(replicas)
+	}
+	h.tables = h.forTables(tables)
+	// Create a new Echo instance
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+
+	// Middleware
+	e.Pre(h.probes)
+	e.Use(middleware.RequestID())
+	e.Use(RequestContexts)
+	e.Use(middleware.LoggerWithConfig(middleware.LoggerConfig{
+		Format:        strings.TrimSuffix(middleware.DefaultLoggerConfig.Format, "}\n") + `,"fingerprint":"${custom}"}` + "\n",
+		CustomTagFunc: logFingerprint,
+	}))
+	e.Use(middleware.Recover())
+	e.Use(metrics.Middleware)
+	if signer != nil {
+		e.Use(signer.Middleware)
+	}
+	if auth != nil {
+		e.Use(auth.Middleware)
+	}
+	if tenants != nil {
+		e.Use(tenants.Middleware)
+	}
+	e.Use(Fingerprint)
+	e.Use(Deprecations)
+	if priorities != nil {
+		e.Use(priorities.Middleware)
+	}
+	if timeouts != nil {
+		e.Use(timeouts.Middleware)
+	}
+
+	// Routes
+	idempotency := NewIdempotencyStore(idempotencyTTL)
+	e.GET("/paginate", h.handlePagination, h.cacheBodies)
+	e.POST("/paginate", h.handlePaginationBody, idempotency.Middleware)
+	e.POST("/batch/paginate", h.handleBatchPagination, idempotency.Middleware)
+	e.GET("/paginate/keys", h.handlePaginationKeys)
+	e.GET("/paginate/estimate", h.handleEstimate)
+	e.GET("/paginate/exchange", h.handleCursorExchange)
+	e.GET("/paginate/:table", h.handleTablePagination, h.cacheBodies)
+	e.GET("/scan", h.handleScan, h.cacheBodies)
+	e.GET("/quality", h.handleQuality)
+	e.GET("/stream-all", h.handleStreamAll)
+	e.GET("/export", h.handleExport)
+	e.GET("/export/scan", h.handleScanExport)
+	// Items of a table without a sort key are named by their partition key alone
+	itemPath := "/items/:pk/:sk"
+	if tableKeys.SortKey == "" {
+		itemPath = "/items/:pk"
+	}
+	e.GET(itemPath, h.handleGetItem, h.resolveItemKey)
+	e.POST("/items\\:batchGet", h.handleBatchGet)
+	e.GET("/tables/:table/items/:pk/:sk", h.handleTableItem, h.resolveItemKey)
+	e.GET("/tables/:table/items/:pk", h.handleTableItem, h.resolveItemKey)
+	if writes != nil {
+		e.POST("/items", h.handleCreateItem, writes.Middleware, idempotency.Middleware)
+		e.PUT(itemPath, h.handlePutItem, writes.Middleware, h.resolveItemKey)
+		e.PATCH(itemPath, h.handlePatchItem, writes.Middleware, h.resolveItemKey)
+		e.DELETE(itemPath, h.handleDeleteItem, writes.Middleware, h.resolveItemKey)
+		e.POST("/tables/:table/import", h.handleImport, writes.Middleware, idempotency.Middleware)
+	}
+	e.GET("/collections/:name", h.handleCollection)
+	e.GET("/admin/sample", h.handleSample)
+	e.GET("/admin/hot-keys", h.handleHotKeys)
+	e.GET("/admin/index-recommendations", h.handleIndexRecommendations)
+	e.GET("/admin/plan-cache", h.handlePlanCache)
+	e.GET("/admin/staging", h.handleStagingReport)
+	e.GET("/metrics", metrics.Handle)
+	if signer != nil {
+		e.GET(signingKeysPath, signer.handleKeys)
+	}
+
+	v2 := e.Group("/v2")
+	v2.GET("/paginate", h.handlePaginationV2, h.cacheBodies)
+	v2.GET("/collections/:name", h.handleCollectionV2)
+
+	if opts.GRPCAddr == "" {
+		opts.GRPCAddr = os.Getenv("GRPC_ADDR")
+	}
+	errs := make(chan error, 2)
+	if opts.GRPCAddr != "" {
+		lis, err := net.Listen("tcp", opts.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		log.Printf("Serving gRPC on %s", lis.Addr())
+		go func() { errs <- h.NewGRPCServer().Serve(lis) }()
+	}
+
+	// Start the HTTP server
+	if opts.Addr == "" {
+		opts.Addr = ":8080"
+	}
+	go func() { errs <- e.Start(opts.Addr) }()
+	return <-errs
+}
+
+// newClients creates the DynamoDB client and one client per global-table replica listed in
+// REPLICA_REGIONS, or a fixture-backed fake when the options or MOCK_FIXTURES name fixtures. It also
+// returns the region of the client, empty for fixtures.
+func newClients(opts Options) (DynamoClient, map[string]DynamoClient, string, error) {
+	if opts.Fixture != nil {
+		client, err := NewFixtureClient(*opts.Fixture)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to load fixtures: %w", err)
+		}
+		log.Printf("Serving %d generated items", len(opts.Fixture.Items))
+		return client, nil, "", nil
+	}
+
+	fixtures := opts.Fixtures
+	if fixtures == "" {
+		fixtures = os.Getenv("MOCK_FIXTURES")
+	}
+	if fixtures != "" {
+		client, err := LoadFixtureClient(fixtures)
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("failed to load fixtures: %w", err)
+		}
+		log.Printf("Serving fixtures from %s", fixtures)
+		return client, nil, "", nil
+	}
+
+	// Load AWS configuration
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, config.WithRegion(opts.Region))
+	}
+	endpoint := opts.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("DYNAMO_ENDPOINT")
+	}
+	if endpoint != "" {
+		// DynamoDB Local and LocalStack accept any credentials, and the AWS ones shouldn't leak to them
+		loadOpts = append(loadOpts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(localAccessKey, localAccessKey, "")))
+	}
+	cfg, err := config.LoadDefaultConfig(context.TODO(), loadOpts...)
+	if err != nil {
+		return nil, nil, "", errors.New("failed to load AWS configuration")
+	}
+
+	if endpoint != "" {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, nil, "", fmt.Errorf("invalid DYNAMO_ENDPOINT %q", endpoint)
+		}
+		if cfg.Region == "" {
+			cfg.Region = localRegion
+		}
+		log.Printf("Using the DynamoDB endpoint %s", endpoint)
+		// Replicas are regions of AWS, which a local endpoint doesn't have
+		return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		}), nil, cfg.Region, nil
+	}
+
+	// Create a DynamoDB client
+	return dynamodb.NewFromConfig(cfg), replicaClients(cfg, parseList(os.Getenv("REPLICA_REGIONS"))), cfg.Region, nil
+}
+
+type DynamoClient interface {
+	pagination.DynamoClient
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
+	BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+}
+
+type Handler struct {
+	client   DynamoClient
+	replicas map[string]DynamoClient
+	// region is the region of client, named in consistency tokens
+	region     string
+	router     *ReplicaRouter
+	validation *Validation
+	normalizer *Normalizer
+	// computed are the derived fields added to served items
+	computed *ComputedFields
+	// outputTypes are the attributes served in the attributes object of items, by their type
+	outputTypes *OutputTypes
+	// indexes are the key attributes of the secondary indexes the index parameter can select
+	indexes map[string]pagination.KeySchema
+	stream  StreamLimits
+	// batch bounds the queries of POST /batch/paginate
+	batch BatchLimits
+	// collections are the virtual collections served by /collections/:name
+	collections map[string]*Collection
+	hotKeys     *HotKeyTracker
+	// advisor, when set, collects the reads that secondary indexes would serve better
+	advisor *IndexAdvisor
+	// strictDecoding fails a whole page when one of its items can't be unmarshalled
+	strictDecoding bool
+	// limits bound the paging parameters of requests, the defaults when nil
+	limits *ParamLimits
+	// numbersAsStrings serves the exact numbers of responses as JSON strings
+	numbersAsStrings bool
+	// assertOrder checks the order of the items of every page, reporting violations in its warnings
+	assertOrder bool
+	estimator   *Estimator
+	// shadowReads compares a sample of pages with the cursor path
+	shadowReads *ShadowReader
+	// staging mirrors a sample of pages to a staging table
+	staging *StagingShadow
+	// offload moves pages too large to serve to an object store
+	offload *Offloader
+	// plans caches the queries of the paginators by query shape
+	plans *pagination.PlanCache
+	// table is the registered table the handler serves, or nil for the configured table
+	table *Table
+	// tables are the registered tables served by /paginate/:table
+	tables map[string]*Handler
+	// cursors seals the cursors handed to clients, or is nil to hand them out as encoded keys
+	cursors *CursorSealer
+	// keyIDs obfuscates the primary key values served, or is nil to serve them as stored
+	keyIDs KeyObfuscator
+	// callers are the middleware authenticating callers, resolving their tenant and priority class and
+	// setting their timeouts, which the gRPC API applies to its calls as well
+	callers []echo.MiddlewareFunc
+	// cdn marks pages as cacheable by a CDN
+	cdn *CDNCaching
+	// bodies keeps the bytes of the pages served, to answer repeated requests with
+	bodies *BodyCache
+	// pages keeps the checkpoints and pages of the walks of the paginators
+	pages pagination.PageCache
+	// parallelScan splits the scans of table exports into segments read concurrently
+	parallelScan ParallelScan
+}
+
+// loadValidation reads the optional item schema configured through SCHEMA_FILE and SCHEMA_POLICY
+func loadValidation() (*Validation, error) {
+	path := os.Getenv("SCHEMA_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadValidation(path)
+}
+
+// LoadValidation reads an item schema from a file, enforced with the policy set by SCHEMA_POLICY
+func LoadValidation(path string) (*Validation, error) {
+	schema, err := LoadSchema(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ParseSchemaPolicy(os.Getenv("SCHEMA_POLICY"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validation{Schema: schema, Policy: policy}, nil
+}
+
+// loadNormalizer reads the optional normalization rules configured through NORMALIZATION_FILE
+func loadNormalizer() (*Normalizer, error) {
+	path := os.Getenv("NORMALIZATION_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadNormalizer(path)
+}
+
+// extractParams parses the paging parameters of a request, checking them against the parameter limits
+func (h *Handler) extractParams(c echo.Context) (Params, *requestError) {
+	// Parse the query parameters to get Pagination parameters
+	pageStr := c.QueryParam("page")
+	pageSizeStr := c.QueryParam("pagesize")
+	orderBy := c.QueryParam("orderby")
+	search := c.QueryParam("search")
+
+	page, err := strconv.ParseInt(pageStr, 10, 64)
+	if err != nil {
+		page = 1
+	}
+
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize, err := strconv.ParseInt(pageSizeStr, 10, 64)
+	if err != nil {
+		pageSize = 10
+	}
+
+	if pageSize <= 0 {
+		pageSize = 10
+	}
+	if reqErr := h.limits.check(page, pageSize, orderBy); reqErr != nil {
+		return Params{}, reqErr
+	}
+	pageSize = requestContextFrom(c.Request().Context()).budget().capPageSize(pageSize)
+	directives := requestCacheDirectives(c)
+
+	return Params{
+		Page:         page,
+		PageSize:     pageSize,
+		OrderBy:      orderBy,
+		Search:       search,
+		SearchMode:   strings.ToLower(c.QueryParam("search_mode")),
+		IncludeCount: c.QueryParam("include_count") == "true",
+		Fields:       parseFields(c.QueryParam("fields")),
+		AssertOrder:  h.assertOrder,
+		Debug:        c.QueryParam("debug") == "true",
+		ExplainEmpty: c.QueryParam("explain_empty") == "true",
+		Fresh:        directives.noCache,
+		MaxAge:       directives.maxAge,
+	}, nil
+}
+
+// keyConditionQuery builds the base QueryInput selecting every item of a partition
+func keyConditionQuery(keyCond string) *dynamodb.QueryInput {
+	return tableKeys.Query(tableName, keyCond)
+}
+
+// requestError is a failure while serving a request, with the status and message returned to the client
+type requestError struct {
+	status int
+	// code is the error code of the response, when its status doesn't tell it
+	code    string
+	message string
+	err     error
+	// skippable is set when the failure only concerns a single item that can be left out of a page
+	skippable bool
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprintf("%s: %v", e.message, e.err)
+}
+
+// itemRead is how much of an item was read
+type itemRead int
+
+const (
+	fullItem itemRead = iota
+	// projectedItem holds the attributes named by the fields parameter, and the keys
+	projectedItem
+	// keysOnlyItem holds the key attributes only
+	keysOnlyItem
+)
+
+// readOf tells how the items of a page were read from the partial flag they are decoded with
+func readOf(params Params, partial bool) itemRead {
+	switch {
+	case !partial:
+		return fullItem
+	case params.Select != "keys_only" && len(params.Fields) > 0:
+		return projectedItem
+	}
+	return keysOnlyItem
+}
+
+// decodeItem runs a raw item through normalization, type-drift checks, computed fields, output types and schema validation. It reports
+// false for items dropped by the schema policy and returns a *requestError when the item can't be served.
+// Projected items only get the computed fields and typed attributes that were read, and partial items aren't
+// checked for required attributes.
+func (h *Handler) decodeItem(item map[string]types.AttributeValue, read itemRead) (Entry, []Warning, bool, *requestError) {
+	if h.normalizer != nil {
+		item = h.normalizer.Apply(item)
+	}
+
+	_, keys := h.schema()
+	item, drift := checkDrift(item, keys)
+
+	var entry Entry
+	if err := attributevalue.UnmarshalMap(entryItem(item, keys), &entry); err != nil {
+		return entry, nil, false, &requestError{status: http.StatusInternalServerError, message: "Error unmarshalling DynamoDB item", err: err, skippable: true}
+	}
+	h.exposeEntry(&entry)
+
+	var warnings []Warning
+	for _, message := range drift {
+		warnings = append(warnings, Warning{Code: "type_mismatch", Message: message, Key: entry.Key()})
+	}
+
+	if h.computed != nil && read != keysOnlyItem {
+		var problems []string
+		entry.Computed, problems = h.computed.Apply(item)
+		if h.numbersAsStrings && entry.Computed != nil {
+			servedNumbers(entry.Computed, true)
+		}
+		for _, message := range problems {
+			warnings = append(warnings, Warning{Code: "computed_field_error", Message: message, Key: entry.Key()})
+		}
+	}
+
+	if h.outputTypes != nil && read != keysOnlyItem {
+		var problems []string
+		entry.Attributes, problems = h.outputTypes.Apply(item)
+		for _, message := range problems {
+			warnings = append(warnings, Warning{Code: "output_type_error", Message: message, Key: entry.Key()})
+		}
+	}
+
+	if h.validation == nil {
+		return entry, warnings, true, nil
+	}
+
+	violations, err := h.validation.check(item, read != fullItem)
+	if err != nil {
+		return entry, nil, false, &requestError{status: http.StatusInternalServerError, message: "Error validating DynamoDB item", err: err}
+	}
+	if len(violations) == 0 {
+		return entry, warnings, true, nil
+	}
+
+	switch h.validation.Policy {
+	case SchemaPolicyFail:
+		err := fmt.Errorf("item %s/%s: %v", entry.KeyCond, entry.SortKey, violations)
+		return entry, nil, false, &requestError{status: http.StatusInternalServerError, message: "Item failed schema validation", err: err}
+	case SchemaPolicyDrop:
+		return entry, warnings, false, nil
+	}
+
+	for _, v := range violations {
+		warnings = append(warnings, Warning{Code: "schema_violation", Message: v, Key: entry.Key()})
+	}
+	return entry, warnings, true, nil
+}
+
+// decodePageItem is decodeItem for items served as part of a page. An item that can't be unmarshalled
+// is left out and reported in a decode_error warning, unless strict decoding keeps failing the page.
+func (h *Handler) decodePageItem(item map[string]types.AttributeValue, read itemRead) (Entry, []Warning, bool, *requestError) {
+	entry, warnings, keep, reqErr := h.decodeItem(item, read)
+	if reqErr == nil || !reqErr.skippable || h.strictDecoding {
+		return entry, warnings, keep, reqErr
+	}
+
+	_, keys := h.schema()
+	warning := decodeErrorWarning(item, keys)
+	warning.Key["key_cond"] = h.exposeKey(PartitionKeyPosition, warning.Key["key_cond"])
+	warning.Key["sort_key"] = h.exposeKey(SortKeyPosition, warning.Key["sort_key"])
+	return Entry{}, []Warning{warning}, false, nil
+}
+
+// decodeErrorWarning reports an item left out of a page. It doesn't carry the unmarshalling error,
+// which can quote item values.
+func decodeErrorWarning(item map[string]types.AttributeValue, keys pagination.KeySchema) Warning {
+	key := map[string]string{"key_cond": attributeString(item[keys.PartitionKey]), "sort_key": attributeString(item[keys.SortKey])}
+	return Warning{Code: "decode_error", Message: "The item can't be unmarshalled", Key: key}
+}
+
+// paginationRequest reads the parameters shared by the pagination routes
+func (h *Handler) paginationRequest(c echo.Context) (DynamoClient, string, Params, time.Duration, *requestError) {
+	keyCond, reqErr := h.queryPartition(c)
+	if reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if keyCond == "" {
+		return nil, "", Params{}, 0, &requestError{status: http.StatusBadRequest, message: "Invalid key_condition parameter"}
+	}
+	if reqErr := parseConsistencyToken(c); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+
+	client, ok := h.clientFor(c)
+	if !ok {
+		return nil, "", Params{}, 0, &requestError{status: http.StatusBadRequest, message: "Invalid region parameter"}
+	}
+	client = batchLimited(c.Request().Context(), client)
+
+	params, reqErr := h.extractParams(c)
+	if reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	params.KeyCondition = keyCond
+	params.Region = h.servedRegion(c)
+	if params.IndexName = indexParam(c); params.IndexName != "" {
+		if _, ok := h.indexes[params.IndexName]; !ok {
+			return nil, "", Params{}, 0, &requestError{status: http.StatusBadRequest, message: "Invalid index parameter"}
+		}
+	}
+	if reqErr := h.parseRequestFilter(c, &params, false); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if reqErr := h.parseConsistent(c, &params); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if err := params.ValidateOrder(h.keysFor(params.IndexName)); err != nil {
+		// Clients denied an order sort the items themselves, which an index could spare them
+		attribute, _ := pagination.ParseOrderBy(params.OrderBy)
+		recorded := params
+		recorded.Select = strings.ToLower(c.QueryParam("select"))
+		h.advisor.record(patternOrder, recorded, attribute, pagination.Progress{}, 0)
+		message := "Invalid orderby parameter"
+		if h.keysFor(params.IndexName).SortKey == "" {
+			message += ": the table has no sort key"
+		}
+		return nil, "", Params{}, 0, &requestError{status: http.StatusBadRequest, message: message, err: err}
+	}
+	if reqErr := parsePassthrough(&params, c.QueryParam("select"), c.QueryParam("return_consumed_capacity")); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if reqErr := parseQuerySortRange(c, &params); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if reqErr := checkSortKey(params, h.keysFor(params.IndexName)); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if reqErr := h.checkHiddenKeys(params); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+	if reqErr := h.parseCursor(c, h.keysFor(params.IndexName), keyCond, &params); reqErr != nil {
+		return nil, "", Params{}, 0, reqErr
+	}
+
+	wait, err := parseWait(c.QueryParam("wait"))
+	if err != nil {
+		return nil, "", Params{}, 0, &requestError{status: http.StatusBadRequest, message: "Invalid wait parameter", err: err}
+	}
+
+	return client, keyCond, params, wait, nil
+}
+
+func (h *Handler) handlePagination(c echo.Context) error {
+	client, keyCond, params, wait, reqErr := h.paginationRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	groupBy, reqErr := parseGroupBy(c, params, h.keysFor(""), h.keysFor(params.IndexName))
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	if reqErr := h.preflight(c, client, params); reqErr != nil {
+		c.Logger().Warn(reqErr)
+		return reqErr.respond(c)
+	}
+
+	if groupBy != "" {
+		grouped, reqErr := h.fetchGroupedPage(c.Request().Context(), client, keyCond, params, groupBy)
+		if reqErr != nil {
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+		return c.JSON(http.StatusOK, grouped)
+	}
+
+	if wantsEventStream(c) {
+		return h.streamWithProgress(c, client, keyCond, params)
+	}
+
+	if rng, ok := requestRange(c, params, wait > 0); ok {
+		res, served, reqErr := h.fetchRange(c, client, keyCond, params, rng)
+		if reqErr != nil {
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+		return h.respondRange(c, res, served)
+	}
+
+	started := time.Now()
+	res, reqErr := h.fetchPageWaiting(c.Request().Context(), client, keyCond, params, wait)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	h.shadow(client, keyCond, params, wait, res)
+	h.mirror(keyCond, params, wait, res, time.Since(started))
+
+	// Respond with the paginated results for the requested page
+	c.Response().Header().Set(headerAcceptRanges, rangeUnit)
+	return h.respondPage(c, h.profilePage(c, res, params))
+}
+
+// respondPage writes a page in the format the request selects, offloading it when it is too large to serve
+func (h *Handler) respondPage(c echo.Context, page interface{}) error {
+	return h.respondPageStatus(c, http.StatusOK, page)
+}
+
+// respondPageStatus is respondPage with another status than 200
+func (h *Handler) respondPageStatus(c echo.Context, status int, page interface{}) error {
+	page = withWarnings(c, page)
+	format, serializer, reqErr := selectSerializer(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	responseData, err := serializer.Serialize(page, c.Response().Header())
+	if err != nil {
+		c.Logger().Error(err)
+		return respondError(c, http.StatusInternalServerError, "Error serializing page")
+	}
+	contentType := serializer.ContentType()
+	if typed, ok := page.(interface{ MediaType() string }); ok && format == defaultFormat {
+		contentType = typed.MediaType()
+	}
+	h.setConsistency(c)
+	table, _ := h.schema()
+	keyCond, _ := h.queryPartition(c)
+	h.cdn.cache(c, table, keyCond)
+	return h.offload.respond(c, status, format, contentType, responseData)
+}
+
+// fetchPage assembles the requested page. When progress is set it is called after every DynamoDB
+// round trip.
+func (h *Handler) fetchPage(ctx context.Context, client DynamoClient, keyCond string, params Params, progress func(Progress)) (Response, *requestError) {
+	params.KeyCondition = keyCond
+	p := h.paginator(client, params)
+	p.OnProgress = progress
+	p.MaxRoundTrips = requestContextFrom(ctx).budget().MaxRoundTrips
+
+	walk, cancel := walkContext(ctx)
+	defer cancel()
+	res, err := p.GetPage(walk, params)
+	if err != nil {
+		return Response{}, walkError(ctx, walk, pageError(err))
+	}
+	var reqErr *requestError
+	if res.NextCursor, reqErr = h.pinCursor(ctx, pinTotal(res.NextCursor, res.TotalItems)); reqErr != nil {
+		return Response{}, reqErr
+	}
+	tenantFrom(ctx).redact(res.Data)
+	h.observePartition(params, res)
+	return res, nil
+}
+
+// paginator creates a Paginator over the table that decodes the items read for params like the other
+// routes
+func (h *Handler) paginator(client DynamoClient, params Params) *pagination.Paginator[Entry] {
+	table, keys := h.schema()
+	p := pagination.New[Entry](client, table, keys)
+	p.Decode = h.decoder(params)
+	p.Indexes = h.indexes
+	p.Plans = h.plans
+	p.Cache = h.pages
+	return p
+}
+
+// decoder is decodePageItem as a pagination.DecodeFunc for the items read for params
+func (h *Handler) decoder(params Params) pagination.DecodeFunc[Entry] {
+	return func(item map[string]types.AttributeValue, partial bool) (Entry, []Warning, bool, error) {
+		entry, warnings, keep, reqErr := h.decodePageItem(item, readOf(params, partial))
+		if reqErr != nil {
+			return entry, warnings, keep, reqErr
+		}
+		return entry, warnings, keep, nil
+	}
+}
+
+// pageError maps a failure from the Paginator onto the response returned to the client
+func pageError(err error) *requestError {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		return reqErr
+	}
+	var queryErr *pagination.QueryError
+	if errors.As(err, &queryErr) {
+		return dynamoError("Error in DynamoDB query", queryErr.Err)
+	}
+	var budgetErr *pagination.BudgetError
+	if errors.As(err, &budgetErr) {
+		return &requestError{status: http.StatusUnprocessableEntity, message: "Page is too deep for the round trip budget; use a cursor", err: err}
+	}
+	return &requestError{status: http.StatusInternalServerError, message: "Error assembling page", err: err}
+}
@@ -0,0 +1,313 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDynamoDB is a mock implementation of the DynamoDB client
+type MockDynamoDB struct {
+	mock.Mock
+}
+
+func (m *MockDynamoDB) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.QueryOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.ScanOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.DescribeTableOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.GetItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.PutItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.UpdateItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.DeleteItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.BatchWriteItemOutput), args.Error(1)
+}
+
+func (m *MockDynamoDB) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	args := m.Called(ctx, params)
+	return args.Get(0).(*dynamodb.BatchGetItemOutput), args.Error(1)
+}
+
+func TestHandlePagination(t *testing.T) {
+	tests := []struct {
+		name             string
+		queryParam       string
+		expectedStatus   int
+		expectedResponse Response
+		mockOutput       *dynamodb.QueryOutput
+		mockError        error
+	}{
+		{
+			name:           "Successful Query",
+			queryParam:     "key_condition=test",
+			expectedStatus: http.StatusOK,
+			expectedResponse: Response{
+				Data: []Entry{
+					{KeyCond: "test", SortKey: "item1"},
+					{KeyCond: "test", SortKey: "item2"},
+				},
+				Page: 1,
+				Size: 2,
+			},
+			mockOutput: &dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item2"}},
+				},
+				LastEvaluatedKey: nil,
+			},
+			mockError: nil,
+		},
+		{
+			name:           "Successful Query Order By",
+			queryParam:     "key_condition=test&orderby=-sort_key",
+			expectedStatus: http.StatusOK,
+			expectedResponse: Response{
+				Data: []Entry{
+					{KeyCond: "test", SortKey: "item2"},
+					{KeyCond: "test", SortKey: "item1"},
+				},
+				Page: 1,
+				Size: 2,
+			},
+			mockOutput: &dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item2"}},
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+				},
+				LastEvaluatedKey: nil,
+			},
+			mockError: nil,
+		},
+		{
+			name:           "Successful Query Search",
+			queryParam:     "key_condition=test&search=1",
+			expectedStatus: http.StatusOK,
+			expectedResponse: Response{
+				Data: []Entry{
+					{KeyCond: "test", SortKey: "item1"},
+				},
+				Page: 1,
+				Size: 1,
+			},
+			mockOutput: &dynamodb.QueryOutput{
+				Items: []map[string]types.AttributeValue{
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item1"}},
+					{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "item2"}},
+				},
+				LastEvaluatedKey: nil,
+			},
+			mockError: nil,
+		},
+		{
+			name:           "Invalid Key Condition",
+			queryParam:     "",
+			expectedStatus: http.StatusBadRequest,
+			expectedResponse: Response{
+				Data: nil,
+				Page: 0,
+				Size: 0,
+			},
+			mockOutput: nil,
+			mockError:  nil,
+		},
+		{
+			name:           "DynamoDB Query Error",
+			queryParam:     "key_condition=test",
+			expectedStatus: http.StatusInternalServerError,
+			expectedResponse: Response{
+				Data: nil,
+				Page: 0,
+				Size: 0,
+			},
+			mockOutput: nil,
+			mockError:  errors.New("DynamoDB query error"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Create a new instance of the mock DynamoDB client
+			mockDynamoDB := new(MockDynamoDB)
+
+			// Set up a test echo instance
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/paginate?"+test.queryParam, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			// Set up the mock DynamoDB response
+			if test.mockOutput != nil || test.mockError != nil {
+				mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(test.mockOutput, test.mockError)
+			}
+
+			// Set up the handler with the mock DynamoDB client
+			handler := &Handler{client: mockDynamoDB}
+
+			// Call the handler
+			_ = handler.handlePagination(c)
+
+			assert.Equal(t, test.expectedStatus, rec.Code)
+
+			if test.expectedStatus == http.StatusOK {
+				// Unmarshal the response
+				var response Response
+				err := json.Unmarshal(rec.Body.Bytes(), &response)
+				assert.NoError(t, err)
+
+				// Check the response data
+				assert.Equal(t, test.expectedResponse, response)
+			}
+
+			// Assert that the Query method on the mock DynamoDB client was called
+			mockDynamoDB.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandlePaginationInvalidOrderBy(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	for _, orderBy := range []string{"-", "created_at", "-key_cond"} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&orderby="+url.QueryEscape(orderBy), nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, orderBy)
+		assert.Equal(t, "Invalid orderby parameter", errorBody(t, rec).Message)
+	}
+}
+
+func TestDecodePageItem(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"properties": {"count": {"type": "number"}}}`))
+	require.NoError(t, err)
+
+	// Validation failures aren't about a single item, so they still fail the page
+	invalid := map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"count":    &types.AttributeValueMemberN{Value: "not a number"},
+	}
+	handler := &Handler{validation: &Validation{Schema: schema, Policy: SchemaPolicyFlag}}
+	_, warnings, _, reqErr := handler.decodePageItem(invalid, fullItem)
+	require.NotNil(t, reqErr)
+	assert.Empty(t, warnings)
+	assert.Equal(t, "Error validating DynamoDB item", reqErr.message)
+
+	warning := decodeErrorWarning(invalid, tableKeys)
+	assert.Equal(t, "decode_error", warning.Code)
+	assert.NotContains(t, warning.Message, "not a number")
+	assert.Equal(t, map[string]string{"key_cond": "test", "sort_key": "item1"}, warning.Key)
+}
+
+func TestExtractParamsDefaults(t *testing.T) {
+	e := echo.New()
+	for _, query := range []string{"", "page=0&pagesize=0", "page=-1&pagesize=-5", "page=x&pagesize=y"} {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		params, reqErr := (&Handler{}).extractParams(e.NewContext(req, httptest.NewRecorder()))
+		require.Nil(t, reqErr, query)
+		assert.Equal(t, int64(1), params.Page, query)
+		assert.Equal(t, int64(10), params.PageSize, query)
+	}
+}
+
+func TestHandlePaginationPrefixSearch(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.KeyConditionExpression == "#pk = :keyCond AND begins_with(#sk, :search)" &&
+			input.FilterExpression == nil &&
+			input.ExpressionAttributeNames["#sk"] == "sort_key" &&
+			attributeString(input.ExpressionAttributeValues[":search"]) == "item" &&
+			input.Select == types.SelectCount
+	})).Return(&dynamodb.QueryOutput{Count: 2}, nil)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&search=item&search_mode=prefix&select=count", nil)
+	rec := httptest.NewRecorder()
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, int64(2), *response.Meta.Count)
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePaginationFields(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.ProjectionExpression == "#pk, #sk, #f0" && input.ExpressionAttributeNames["#f0"] == "status"
+	})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"status":   &types.AttributeValueMemberS{Value: "open"},
+	}}}, nil)
+	computed, err := ParseComputedFields([]byte(`{"TableName": [{"name": "state", "expression": "status + \"!\""}, {"name": "total", "expression": "price * 2"}]}`), "TableName")
+	require.NoError(t, err)
+	schema, err := ParseSchema([]byte(`{"type": "object", "required": ["price"]}`))
+	require.NoError(t, err)
+	handler := &Handler{client: mockDynamoDB, computed: computed, validation: &Validation{Schema: schema, Policy: SchemaPolicyFail}}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		return rec
+	}
+
+	// Projected items get the computed fields of the attributes read and skip required attributes
+	rec := get("key_condition=test&fields=status")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, map[string]interface{}{"state": "open!"}, response.Data[0].Computed)
+	mockDynamoDB.AssertExpectations(t)
+
+	rec = get("key_condition=test&fields=status&select=keys_only")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "fields can't be combined with select=keys_only", errorBody(t, rec).Message)
+}
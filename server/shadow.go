@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+const (
+	// shadowReadTimeout bounds a shadow read, which outlives the request that triggered it
+	shadowReadTimeout = 30 * time.Second
+	// defaultShadowReadRate is the fraction of requests shadowed when SHADOW_READ_RATE isn't set
+	defaultShadowReadRate = 0.01
+	// maxShadowReads bounds the shadow reads in flight; requests arriving while they're all busy aren't shadowed
+	maxShadowReads = 4
+)
+
+// runShadowRead runs a shadow read off the request path
+var runShadowRead = func(read func()) { go read() }
+
+// ShadowMismatch describes a page the cursor path served differently from the page walk. It leaves out
+// item values, so it can be logged like query shapes.
+type ShadowMismatch struct {
+	Page          int64
+	PageSize      int64
+	LegacySize    int64
+	CursorSize    int64
+	LegacyHasMore bool
+	CursorHasMore bool
+	// FirstDifference is the index of the first item that differs, -1 when only the sizes or HasMore differ
+	FirstDifference int
+	Error           string `json:",omitempty"`
+}
+
+// ShadowReader re-serves a sample of pages through the cursor path and logs when its result differs
+// from the page that was served
+type ShadowReader struct {
+	// Rate is the fraction of eligible requests that are shadowed
+	Rate float64
+	// client serves the shadow reads, so they don't count towards hot keys or replica latency. When nil
+	// the client that served the page is used.
+	client DynamoClient
+	slots  chan struct{}
+	logger *log.Logger
+	sample func() float64
+}
+
+// NewShadowReader creates a shadow reader for a fraction rate of the requests, reading through client
+func NewShadowReader(rate float64, client DynamoClient, logger *log.Logger) *ShadowReader {
+	return &ShadowReader{Rate: rate, client: client, slots: make(chan struct{}, maxShadowReads), logger: logger, sample: rand.Float64}
+}
+
+// loadShadowReader enables shadow reads when SHADOW_READS is "cursor", for the fraction of requests set
+// by SHADOW_READ_RATE. client should bypass the instrumentation of served requests.
+func loadShadowReader(client DynamoClient) (*ShadowReader, error) {
+	mode := os.Getenv("SHADOW_READS")
+	if mode == "" {
+		return nil, nil
+	}
+	if mode != "cursor" {
+		return nil, fmt.Errorf("unknown SHADOW_READS mode %q", mode)
+	}
+
+	rate := defaultShadowReadRate
+	if v := os.Getenv("SHADOW_READ_RATE"); v != "" {
+		var err error
+		if rate, err = strconv.ParseFloat(v, 64); err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid SHADOW_READ_RATE %q", v)
+		}
+	}
+	return NewShadowReader(rate, client, log.Default()), nil
+}
+
+// shadowable reports whether a page request has a cursor equivalent to compare against
+func shadowable(params Params, wait time.Duration) bool {
+	return !params.CursorMode && params.Select != "count" && params.Search == "" && params.Filter == nil && wait == 0
+}
+
+// shadow starts a shadow read of a page that was served by walking the query
+func (h *Handler) shadow(client DynamoClient, keyCond string, params Params, wait time.Duration, legacy Response) {
+	s := h.shadowReads
+	if s == nil || !shadowable(params, wait) || s.sample() >= s.Rate {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return
+	}
+	if s.client != nil {
+		client = s.client
+	}
+	runShadowRead(func() {
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowReadTimeout)
+		defer cancel()
+		s.compare(params, legacy, h.cursorRead(ctx, client, keyCond, params))
+	})
+}
+
+// shadowResult is the page served through the cursor path, or why it couldn't be
+type shadowResult struct {
+	res Response
+	err *requestError
+}
+
+// cursorRead serves a page the way a migrated client would: by exchanging the page for a cursor and
+// resuming from it
+func (h *Handler) cursorRead(ctx context.Context, client DynamoClient, keyCond string, params Params) shadowResult {
+	exchange, reqErr := h.pageToCursor(ctx, client, keyCond, params)
+	if reqErr != nil {
+		return shadowResult{err: reqErr}
+	}
+
+	params.CursorMode = true
+	if exchange.Cursor != "" {
+		key, err := pagination.DecodeCursor(exchange.Cursor)
+		if err != nil {
+			return shadowResult{err: &requestError{message: "Invalid cursor parameter", err: err}}
+		}
+		params.Cursor = key
+	}
+
+	res, reqErr := h.fetchPage(ctx, client, keyCond, params, nil)
+	return shadowResult{res: res, err: reqErr}
+}
+
+// compare logs the shadow result when it differs from the page that was served
+func (s *ShadowReader) compare(params Params, legacy Response, shadow shadowResult) {
+	mismatch := ShadowMismatch{
+		Page:            params.Page,
+		PageSize:        params.PageSize,
+		LegacySize:      legacy.Size,
+		CursorSize:      shadow.res.Size,
+		LegacyHasMore:   legacy.HasMore,
+		CursorHasMore:   shadow.res.HasMore,
+		FirstDifference: -1,
+	}
+	if shadow.err != nil {
+		mismatch.Error = shadow.err.message
+	} else {
+		for i := range legacy.Data {
+			if i >= len(shadow.res.Data) || !sameEntry(legacy.Data[i], shadow.res.Data[i]) {
+				mismatch.FirstDifference = i
+				break
+			}
+		}
+		if mismatch.FirstDifference < 0 && len(shadow.res.Data) > len(legacy.Data) {
+			mismatch.FirstDifference = len(legacy.Data)
+		}
+		if mismatch.FirstDifference < 0 && legacy.HasMore == shadow.res.HasMore {
+			return
+		}
+	}
+
+	if data, err := json.Marshal(mismatch); err == nil {
+		s.logger.Printf("shadow_read_mismatch %s", data)
+	}
+}
+
+// sameEntry reports whether two entries are the same item of the table. Computed fields aren't compared:
+// they can depend on the time they were evaluated at.
+func sameEntry(a, b Entry) bool {
+	return a.KeyCond == b.KeyCond && a.SortKey == b.SortKey
+}
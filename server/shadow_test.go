@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowReads(t *testing.T) {
+	runShadowRead = func(read func()) { read() }
+	defer func() { runShadowRead = func(read func()) { go read() } }()
+
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{name: "matching page", query: "key_condition=test&page=2&pagesize=2"},
+		{name: "first page", query: "key_condition=test&page=1&pagesize=3"},
+		{name: "cursor requests aren't shadowed", query: "key_condition=test&pagesize=2&cursor="},
+		{name: "search isn't shadowed", query: "key_condition=test&page=9&pagesize=2&search=item"},
+		{
+			name:     "page past the end",
+			query:    "key_condition=test&page=5&pagesize=2",
+			expected: `shadow_read_mismatch {"Page":5,"PageSize":2,"LegacySize":0,"CursorSize":0,"LegacyHasMore":false,"CursorHasMore":false,"FirstDifference":-1,"Error":"Page is past the end of the results"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			shadowReads := NewShadowReader(1, nil, log.New(&out, "", 0))
+			handler := &Handler{client: client, shadowReads: shadowReads}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/paginate?"+test.query, nil)
+			rec := httptest.NewRecorder()
+			require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
+
+func TestShadowReaderCompare(t *testing.T) {
+	var out bytes.Buffer
+	shadowReads := NewShadowReader(1, nil, log.New(&out, "", 0))
+	params := Params{Page: 2, PageSize: 2}
+
+	legacy := Response{Data: []Entry{{KeyCond: "test", SortKey: "b"}, {KeyCond: "test", SortKey: "c"}}, Page: 2, Size: 2, HasMore: true}
+	shadowReads.compare(params, legacy, shadowResult{res: legacy})
+	assert.Empty(t, out.String())
+
+	shifted := Response{Data: []Entry{{KeyCond: "test", SortKey: "b"}, {KeyCond: "test", SortKey: "d"}}, Size: 2, HasMore: true}
+	shadowReads.compare(params, legacy, shadowResult{res: shifted})
+	assert.Contains(t, out.String(), `"FirstDifference":1`)
+	assert.NotContains(t, out.String(), `"d"`)
+
+	out.Reset()
+	shadowReads.compare(params, legacy, shadowResult{res: Response{Data: legacy.Data, Size: 2}})
+	assert.Contains(t, out.String(), `"LegacyHasMore":true,"CursorHasMore":false,"FirstDifference":-1`)
+}
+
+func TestShadowSampling(t *testing.T) {
+	shadowed := 0
+	runShadowRead = func(read func()) { shadowed++ }
+	defer func() { runShadowRead = func(read func()) { go read() } }()
+
+	shadowReads := NewShadowReader(0.25, nil, log.New(&bytes.Buffer{}, "", 0))
+	samples := []float64{0.1, 0.5, 0.9, 0.2}
+	shadowReads.sample = func() float64 {
+		s := samples[0]
+		samples = samples[1:]
+		return s
+	}
+	handler := &Handler{shadowReads: shadowReads}
+
+	for i := 0; i < 4; i++ {
+		handler.shadow(nil, "test", Params{Page: 1, PageSize: 2}, 0, Response{})
+	}
+	assert.Equal(t, 2, shadowed)
+}
+
+func TestShadowConcurrencyLimit(t *testing.T) {
+	var pending []func()
+	runShadowRead = func(read func()) { pending = append(pending, read) }
+	defer func() { runShadowRead = func(read func()) { go read() } }()
+
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	shadowReads := NewShadowReader(1, client, log.New(&out, "", 0))
+	// The served client isn't used by shadow reads
+	handler := &Handler{shadowReads: shadowReads}
+
+	legacy := Response{Data: []Entry{{KeyCond: "test", SortKey: "item1"}, {KeyCond: "test", SortKey: "item2"}}, Page: 1, Size: 2, HasMore: true}
+	for i := 0; i < maxShadowReads+2; i++ {
+		handler.shadow(new(MockDynamoDB), "test", Params{Page: 1, PageSize: 2}, 0, legacy)
+	}
+	assert.Len(t, pending, maxShadowReads)
+
+	pending[0]()
+	handler.shadow(new(MockDynamoDB), "test", Params{Page: 1, PageSize: 2}, 0, legacy)
+	assert.Len(t, pending, maxShadowReads+1)
+	assert.Empty(t, out.String())
+}
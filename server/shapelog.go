@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+)
+
+// QueryShape describes a DynamoDB query without the data it was run with. Expressions only contain
+// placeholders, and values are reduced to their type and a salted hash so equal values can be grouped.
+type QueryShape struct {
+	Table      string
+	Index      string `json:",omitempty"`
+	KeyCond    string
+	Filter     string            `json:",omitempty"`
+	Projection string            `json:",omitempty"`
+	Values     map[string]string `json:",omitempty"`
+	Select     string            `json:",omitempty"`
+	Limit      int32             `json:",omitempty"`
+	Descending bool              `json:",omitempty"`
+	// PageDepth is the round trip within the request, 1 for the first page
+	PageDepth int64 `json:",omitempty"`
+	Items     int32
+	ElapsedMs int64
+	Error     bool `json:",omitempty"`
+}
+
+// shapeLogger logs the shape of every query made through a client
+type shapeLogger struct {
+	DynamoClient
+	salt   string
+	logger *log.Logger
+}
+
+// loadQueryLog reports whether QUERY_LOG enables shape logging, and returns the QUERY_LOG_SALT it
+// requires: without a secret salt, hashes of short or common values can be reversed by hashing guesses
+func loadQueryLog() (string, bool, error) {
+	mode := os.Getenv("QUERY_LOG")
+	if mode == "" {
+		return "", false, nil
+	}
+	if mode != "shape" {
+		return "", false, fmt.Errorf("unknown QUERY_LOG mode %q", mode)
+	}
+	salt := os.Getenv("QUERY_LOG_SALT")
+	if salt == "" {
+		return "", false, errors.New("QUERY_LOG=shape requires QUERY_LOG_SALT")
+	}
+	return salt, true, nil
+}
+
+// logQueryShapes wraps a client so its queries are logged without their values
+func logQueryShapes(client DynamoClient, salt string, logger *log.Logger) DynamoClient {
+	return &shapeLogger{DynamoClient: client, salt: salt, logger: logger}
+}
+
+func (s *shapeLogger) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	start := time.Now()
+	out, err := s.DynamoClient.Query(ctx, params, optFns...)
+
+	shape := s.shape(params)
+	shape.ElapsedMs = time.Since(start).Milliseconds()
+	shape.PageDepth = pagination.PageDepth(ctx)
+	shape.Error = err != nil
+	if out != nil {
+		shape.Items = out.Count
+	}
+
+	if data, jsonErr := json.Marshal(shape); jsonErr == nil {
+		s.logger.Printf("query_shape %s", data)
+	}
+	return out, err
+}
+
+// shape reduces a query to its shape
+func (s *shapeLogger) shape(params *dynamodb.QueryInput) QueryShape {
+	shape := QueryShape{
+		Table:      aws.StringValue(params.TableName),
+		Index:      aws.StringValue(params.IndexName),
+		KeyCond:    aws.StringValue(params.KeyConditionExpression),
+		Filter:     aws.StringValue(params.FilterExpression),
+		Projection: aws.StringValue(params.ProjectionExpression),
+		Select:     string(params.Select),
+		Descending: params.ScanIndexForward != nil && !*params.ScanIndexForward,
+	}
+	if params.Limit != nil {
+		shape.Limit = *params.Limit
+	}
+
+	if len(params.ExpressionAttributeValues) > 0 {
+		shape.Values = make(map[string]string, len(params.ExpressionAttributeValues))
+		for placeholder, av := range params.ExpressionAttributeValues {
+			shape.Values[placeholder] = s.redact(av)
+		}
+	}
+	return shape
+}
+
+// redact replaces a value with its type and a short salted hash
+func (s *shapeLogger) redact(av types.AttributeValue) string {
+	typ := attributeType(av)
+	value, ok := scalarValue(av)
+	if !ok {
+		return typ
+	}
+
+	sum := sha256.Sum256([]byte(s.salt + value))
+	return typ + ":" + hex.EncodeToString(sum[:6])
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShapeLogger(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Count: 2}, nil)
+
+	var buf bytes.Buffer
+	client := logQueryShapes(mockDynamoDB, "salt", log.New(&buf, "", 0))
+
+	input := keyConditionQuery("customer-42")
+	input.Limit = aws.Int32(10)
+	input.ScanIndexForward = aws.Bool(false)
+	_, err := client.Query(pagination.WithPageDepth(context.Background(), 3), input)
+	require.NoError(t, err)
+
+	line := buf.String()
+	assert.NotContains(t, line, "customer-42")
+	require.True(t, strings.HasPrefix(line, "query_shape "))
+
+	var shape QueryShape
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "query_shape ")), &shape))
+	assert.Equal(t, "TableName", shape.Table)
+	assert.Equal(t, "#pk = :keyCond", shape.KeyCond)
+	assert.EqualValues(t, 10, shape.Limit)
+	assert.True(t, shape.Descending)
+	assert.EqualValues(t, 3, shape.PageDepth)
+	assert.EqualValues(t, 2, shape.Items)
+	assert.Regexp(t, `^S:[0-9a-f]{12}$`, shape.Values[":keyCond"])
+}
+
+func TestShapeLoggerRedact(t *testing.T) {
+	logger := &shapeLogger{salt: "salt"}
+	other := &shapeLogger{salt: "pepper"}
+
+	a := keyConditionQuery("same").ExpressionAttributeValues[":keyCond"]
+	b := keyConditionQuery("same").ExpressionAttributeValues[":keyCond"]
+	assert.Equal(t, logger.redact(a), logger.redact(b))
+	assert.NotEqual(t, logger.redact(a), other.redact(a))
+}
+
+func TestLoadQueryLog(t *testing.T) {
+	_, enabled, err := loadQueryLog()
+	require.NoError(t, err)
+	assert.False(t, enabled)
+
+	t.Setenv("QUERY_LOG", "shape")
+	_, _, err = loadQueryLog()
+	assert.Error(t, err)
+
+	t.Setenv("QUERY_LOG_SALT", "secret")
+	salt, enabled, err := loadQueryLog()
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, "secret", salt)
+
+	t.Setenv("QUERY_LOG", "full")
+	_, _, err = loadQueryLog()
+	assert.Error(t, err)
+}
@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerSignature carries the detached JWS of a signed response
+	headerSignature = "X-JWS-Signature"
+	// signingKeysPath serves the key verifying signed responses as a JWKS
+	signingKeysPath = "/.well-known/jwks.json"
+)
+
+// ResponseSigner signs response bodies with an ECDSA P-256 (ES256) or RSA (RS256) private key as a
+// detached JWS (RFC 7515, appendix F), so systems archiving query results can verify they came from this
+// service unmodified with its public key
+type ResponseSigner struct {
+	key    crypto.Signer
+	alg    string
+	keyID  string
+	header string
+}
+
+// NewResponseSigner creates a signer for an ECDSA P-256 or RSA private key; keyID is put in the kid
+// header when set
+func NewResponseSigner(key crypto.PrivateKey, keyID string) (*ResponseSigner, error) {
+	s := &ResponseSigner{keyID: keyID}
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("unsupported curve %s, use P-256", k.Curve.Params().Name)
+		}
+		s.key, s.alg = k, "ES256"
+	case *rsa.PrivateKey:
+		s.key, s.alg = k, "RS256"
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	protected := map[string]string{"alg": s.alg}
+	if keyID != "" {
+		protected["kid"] = keyID
+	}
+	data, _ := json.Marshal(protected)
+	s.header = base64.RawURLEncoding.EncodeToString(data)
+	return s, nil
+}
+
+// loadResponseSigner reads the optional PEM private key signing responses from the file
+// RESPONSE_SIGNING_KEY_FILE and its id from RESPONSE_SIGNING_KEY_ID
+func loadResponseSigner() (*ResponseSigner, error) {
+	if os.Getenv("RESPONSE_SIGNING_KEY") != "" {
+		return nil, errors.New("RESPONSE_SIGNING_KEY is no longer supported, as verifiers holding a shared key could forge signatures; set RESPONSE_SIGNING_KEY_FILE to a private key")
+	}
+	path := os.Getenv("RESPONSE_SIGNING_KEY_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RESPONSE_SIGNING_KEY_FILE: %w", err)
+	}
+	return NewResponseSigner(key, os.Getenv("RESPONSE_SIGNING_KEY_ID"))
+}
+
+// parsePrivateKey reads a PEM private key in PKCS #8, SEC 1 or PKCS #1 form
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block")
+	}
+	switch block.Type {
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+}
+
+// sign returns the detached JWS of a payload: the protected header and signature with the payload left out
+func (s *ResponseSigner) sign(payload []byte) (string, error) {
+	digest := signingDigest(s.header, payload)
+	var signature []byte
+	switch k := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		r, ss, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return "", err
+		}
+		// JWS signatures of ECDSA keys are r and s as fixed size big-endian integers
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		ss.FillBytes(signature[32:])
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest); err != nil {
+			return "", err
+		}
+	}
+	return s.header + ".." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// signingDigest is the SHA-256 of the signing input of a JWS
+func signingDigest(header string, payload []byte) []byte {
+	sum := sha256.Sum256([]byte(header + "." + base64.RawURLEncoding.EncodeToString(payload)))
+	return sum[:]
+}
+
+// verify reports whether jws is a valid detached signature of payload
+func (s *ResponseSigner) verify(payload []byte, jws string) bool {
+	parts := strings.Split(jws, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		return false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	_, isRSA := s.key.(*rsa.PrivateKey)
+	return verifyJWTSignature(s.key.Public(), isRSA, crypto.SHA256, signingDigest(parts[0], payload), signature)
+}
+
+// jwk returns the public key verifying the signatures as a JWK
+func (s *ResponseSigner) jwk() jwk {
+	encode := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	key := jwk{Kid: s.keyID, Use: "sig", Alg: s.alg}
+	switch k := s.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		key.Kty, key.Crv = "EC", "P-256"
+		key.X, key.Y = encode(k.X.FillBytes(make([]byte, 32))), encode(k.Y.FillBytes(make([]byte, 32)))
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N, key.E = encode(k.N.Bytes()), encode(big.NewInt(int64(k.E)).Bytes())
+	}
+	return key
+}
+
+// handleKeys serves the key verifying signed responses as a JWKS
+func (s *ResponseSigner) handleKeys(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string][]jwk{"keys": {s.jwk()}})
+}
+
+// signingWriter holds back the response body so its signature can be sent as a header. Streamed
+// responses are passed through unsigned, as their body isn't known before the headers are sent.
+type signingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	passthrough bool
+	body        bytes.Buffer
+}
+
+func (w *signingWriter) WriteHeader(code int) {
+	w.status = code
+	w.wroteHeader = true
+
+	contentType := w.Header().Get(echo.HeaderContentType)
+	if strings.HasPrefix(contentType, "text/event-stream") || strings.HasPrefix(contentType, "application/x-ndjson") {
+		w.passthrough = true
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *signingWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.body.Write(b)
+}
+
+func (w *signingWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); w.passthrough && ok {
+		f.Flush()
+	}
+}
+
+// identityBody decodes a body sent with a content encoding, so signatures cover the payload whichever
+// encoding it was sent in
+func identityBody(encoding string, body []byte) ([]byte, error) {
+	switch encoding {
+	case "", "identity":
+		return body, nil
+	case "gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(zr)
+	}
+	return nil, fmt.Errorf("can't sign a body with content encoding %q", encoding)
+}
+
+// Middleware signs every buffered response body and sends the detached JWS in X-JWS-Signature. Bodies
+// compressed by the body cache are signed decompressed.
+func (s *ResponseSigner) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		original := c.Response().Writer
+		writer := &signingWriter{ResponseWriter: original}
+		c.Response().Writer = writer
+		defer func() { c.Response().Writer = original }()
+
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+		if !writer.wroteHeader || writer.passthrough {
+			return nil
+		}
+
+		payload, err := identityBody(original.Header().Get(echo.HeaderContentEncoding), writer.body.Bytes())
+		if err != nil {
+			return err
+		}
+		jws, err := s.sign(payload)
+		if err != nil {
+			return err
+		}
+		original.Header().Set(headerSignature, jws)
+		original.WriteHeader(writer.status)
+		_, err = original.Write(writer.body.Bytes())
+		return err
+	}
+}
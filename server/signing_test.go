@@ -0,0 +1,158 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseSignerMiddleware(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := NewResponseSigner(key, "key-1")
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(signer.Middleware)
+	e.GET("/page", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"hello": "world"})
+	})
+	e.GET("/missing", func(c echo.Context) error {
+		return echo.ErrNotFound
+	})
+	e.GET("/stream", func(c echo.Context) error {
+		c.Response().Header().Set(echo.HeaderContentType, "application/x-ndjson")
+		c.Response().WriteHeader(http.StatusOK)
+		_, err := c.Response().Write([]byte("{}\n"))
+		c.Response().Flush()
+		return err
+	})
+	e.GET(signingKeysPath, signer.handleKeys)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/page", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	jws := rec.Header().Get(headerSignature)
+	require.NotEmpty(t, jws)
+	assert.True(t, signer.verify(rec.Body.Bytes(), jws))
+	assert.False(t, signer.verify([]byte(`{"hello":"mallory"}`), jws))
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherSigner, err := NewResponseSigner(other, "")
+	require.NoError(t, err)
+	assert.False(t, otherSigner.verify(rec.Body.Bytes(), jws))
+
+	header, err := base64.RawURLEncoding.DecodeString(strings.Split(jws, ".")[0])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"alg":"ES256","kid":"key-1"}`, string(header))
+
+	// The published key verifies the signature
+	keys := httptest.NewRecorder()
+	e.ServeHTTP(keys, httptest.NewRequest(http.MethodGet, signingKeysPath, nil))
+	require.Equal(t, http.StatusOK, keys.Code)
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(keys.Body.Bytes(), &set))
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "key-1", set.Keys[0].Kid)
+	assert.Equal(t, "ES256", set.Keys[0].Alg)
+	public, err := set.Keys[0].publicKey()
+	require.NoError(t, err)
+	parts := strings.Split(jws, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	assert.True(t, verifyJWTSignature(public, false, crypto.SHA256, signingDigest(parts[0], rec.Body.Bytes()), signature))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.True(t, signer.verify(rec.Body.Bytes(), rec.Header().Get(headerSignature)))
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.Equal(t, "{}\n", rec.Body.String())
+	assert.Empty(t, rec.Header().Get(headerSignature))
+}
+
+func TestResponseSignerBodyCache(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := NewResponseSigner(key, "")
+	require.NoError(t, err)
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 3))
+	require.NoError(t, err)
+	handler := &Handler{client: client, bodies: NewBodyCache(time.Minute, 1<<20)}
+
+	e := echo.New()
+	e.Use(signer.Middleware)
+	e.Use(Fingerprint)
+	e.GET("/paginate", handler.handlePagination, handler.cacheBodies)
+	serve := func(encoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+		req.Header.Set(echo.HeaderAcceptEncoding, encoding)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusOK, rec.Code)
+		return rec
+	}
+
+	// Compressed bodies carry the signature of the body they decompress to, the same as uncompressed ones
+	compressed := serve("gzip")
+	require.Equal(t, "gzip", compressed.Header().Get(echo.HeaderContentEncoding))
+	zr, err := gzip.NewReader(bytes.NewReader(compressed.Body.Bytes()))
+	require.NoError(t, err)
+	payload, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.True(t, signer.verify(payload, compressed.Header().Get(headerSignature)))
+
+	plain := serve("identity")
+	assert.Empty(t, plain.Header().Get(echo.HeaderContentEncoding))
+	assert.Equal(t, payload, plain.Body.Bytes())
+	assert.True(t, signer.verify(plain.Body.Bytes(), plain.Header().Get(headerSignature)))
+}
+
+func TestLoadResponseSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0o600))
+	t.Setenv("RESPONSE_SIGNING_KEY_FILE", path)
+
+	signer, err := loadResponseSigner()
+	require.NoError(t, err)
+	jws, err := signer.sign([]byte("{}"))
+	require.NoError(t, err)
+	assert.True(t, signer.verify([]byte("{}"), jws))
+	assert.Equal(t, "RSA", signer.jwk().Kty)
+
+	// Shared secrets let anyone verifying forge, so they are refused
+	t.Setenv("RESPONSE_SIGNING_KEY", "secret")
+	_, err = loadResponseSigner()
+	assert.Error(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewResponseSigner(p384, "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// sortRangeParams maps the sort key condition parameters onto their SortRange operator
+var sortRangeParams = []struct {
+	param, op string
+}{
+	{"sort_begins_with", "begins_with"},
+	{"sort_between_start", "between"},
+	{"sort_gt", ">"},
+	{"sort_lt", "<"},
+}
+
+// parseSortRange reads the sort key condition parameters. DynamoDB takes a single condition on the sort
+// key, so only one of them can be set; a between needs both sort_between_start and sort_between_end.
+func parseSortRange(c echo.Context) (*pagination.SortRange, *requestError) {
+	var sortRange *pagination.SortRange
+	for _, p := range sortRangeParams {
+		value := c.QueryParam(p.param)
+		if value == "" {
+			continue
+		}
+		if sortRange != nil {
+			return nil, &requestError{status: http.StatusBadRequest, message: "Only one sort key condition can be used"}
+		}
+		sortRange = &pagination.SortRange{Op: p.op, Value: value}
+	}
+
+	end := c.QueryParam("sort_between_end")
+	switch {
+	case sortRange != nil && sortRange.Op == "between":
+		if end == "" {
+			return nil, &requestError{status: http.StatusBadRequest, message: "sort_between_start needs sort_between_end"}
+		}
+		sortRange.End = end
+	case end != "":
+		return nil, &requestError{status: http.StatusBadRequest, message: "sort_between_end needs sort_between_start"}
+	}
+	return sortRange, nil
+}
+
+// parseQuerySortRange reads the sort key condition of a query, which can't be combined with a prefix
+// search as both are conditions on the sort key
+func parseQuerySortRange(c echo.Context, params *Params) *requestError {
+	sortRange, reqErr := parseSortRange(c)
+	if reqErr != nil {
+		return reqErr
+	}
+	if sortRange != nil && params.Search != "" && params.SearchMode == "prefix" {
+		return &requestError{status: http.StatusBadRequest, message: "Sort key conditions can't be combined with search_mode=prefix"}
+	}
+	params.SortRange = sortRange
+	return nil
+}
+
+// checkSortKey rejects the search and sort key conditions of a read when the keys being read have no
+// sort key, which they would condition on
+func checkSortKey(params Params, keys pagination.KeySchema) *requestError {
+	err := params.ValidateSortKey(keys)
+	switch {
+	case err == nil:
+		return nil
+	case params.Search != "":
+		return &requestError{status: http.StatusBadRequest, message: "Invalid search parameter: the table has no sort key", err: err}
+	default:
+		return &requestError{status: http.StatusBadRequest, message: "Invalid sort key condition: the table has no sort key", err: err}
+	}
+}
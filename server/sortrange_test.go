@@ -0,0 +1,133 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSortRange(t *testing.T) {
+	parse := func(query string) (*pagination.SortRange, *requestError) {
+		req := httptest.NewRequest(http.MethodGet, "/paginate?"+query, nil)
+		return parseSortRange(echo.New().NewContext(req, httptest.NewRecorder()))
+	}
+
+	sortRange, reqErr := parse("")
+	assert.Nil(t, reqErr)
+	assert.Nil(t, sortRange)
+
+	sortRange, reqErr = parse("sort_between_start=2024-01&sort_between_end=2024-03")
+	assert.Nil(t, reqErr)
+	assert.Equal(t, &pagination.SortRange{Op: "between", Value: "2024-01", End: "2024-03"}, sortRange)
+
+	sortRange, reqErr = parse("sort_gt=item0005")
+	assert.Nil(t, reqErr)
+	assert.Equal(t, &pagination.SortRange{Op: ">", Value: "item0005"}, sortRange)
+
+	for _, query := range []string{"sort_gt=a&sort_lt=b", "sort_between_start=a", "sort_between_end=b", "sort_begins_with=a&sort_between_end=b"} {
+		_, reqErr := parse(query)
+		require.NotNil(t, reqErr, query)
+		assert.Equal(t, http.StatusBadRequest, reqErr.status, query)
+	}
+}
+
+func TestHandlePaginationSortRange(t *testing.T) {
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 10))
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	paginate := func(query string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&"+query, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handler.handlePagination(e.NewContext(req, rec)))
+		return rec
+	}
+	sortKeys := func(rec *httptest.ResponseRecorder) []string {
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		var keys []string
+		for _, entry := range response.Data {
+			keys = append(keys, entry.SortKey)
+		}
+		return keys
+	}
+
+	assert.Equal(t, []string{"item0003", "item0004", "item0005"}, sortKeys(paginate("sort_between_start=item0003&sort_between_end=item0005")))
+	assert.Equal(t, []string{"item0009", "item0010"}, sortKeys(paginate("sort_gt=item0008")))
+	assert.Equal(t, []string{"item0002", "item0001"}, sortKeys(paginate("sort_lt=item0003&orderby=-sort_key")))
+	assert.Equal(t, []string{"item0010"}, sortKeys(paginate("sort_begins_with=item001")))
+
+	assert.Equal(t, http.StatusBadRequest, paginate("sort_begins_with=item&search=item&search_mode=prefix").Code)
+}
+
+func TestPaginationWithoutSortKey(t *testing.T) {
+	withTableConfig(t)
+	ConfigureTable(Options{NoSortKey: true})
+	client, err := NewFixtureClient(Fixture{PartitionKey: "key_cond", Items: []map[string]interface{}{
+		{"key_cond": "alice", "status": "active"},
+		{"key_cond": "bob", "status": "inactive"},
+		{"key_cond": "carol", "status": "active"},
+	}})
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	serve := func(handle echo.HandlerFunc, target string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		require.NoError(t, handle(e.NewContext(req, rec)))
+		return rec
+	}
+	partitions := func(rec *httptest.ResponseRecorder) ([]string, string) {
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		var keys []string
+		for _, entry := range response.Data {
+			assert.Empty(t, entry.SortKey)
+			keys = append(keys, entry.KeyCond)
+		}
+		return keys, response.NextCursor
+	}
+
+	keys, _ := partitions(serve(handler.handlePagination, "/paginate?key_condition=bob&select=keys_only"))
+	assert.Equal(t, []string{"bob"}, keys)
+
+	keys, cursor := partitions(serve(handler.handleScan, "/scan?pagesize=2&cursor="))
+	assert.Equal(t, []string{"alice", "bob"}, keys)
+	keys, cursor = partitions(serve(handler.handleScan, "/scan?pagesize=2&cursor="+url.QueryEscape(cursor)))
+	assert.Equal(t, []string{"carol"}, keys)
+	assert.Empty(t, cursor)
+
+	withSortKey, err := pagination.EncodeCursor(map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "alice"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+	})
+	require.NoError(t, err)
+	for _, target := range []string{
+		"/paginate?key_condition=bob&search=b",
+		"/paginate?key_condition=bob&sort_gt=a",
+		"/paginate?key_condition=bob&orderby=-sort_key",
+		"/scan?search=b",
+		"/scan?cursor=" + withSortKey,
+	} {
+		handle := handler.handlePagination
+		if strings.HasPrefix(target, "/scan") {
+			handle = handler.handleScan
+		}
+		rec := serve(handle, target)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+		assert.Contains(t, errorBody(t, rec).Message, "the table has no sort key", target)
+	}
+}
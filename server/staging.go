@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultStagingRate is the fraction of requests mirrored when STAGING_SHADOW_RATE isn't set
+	defaultStagingRate = 0.01
+	// stagingWindow is the number of recent comparisons the staging report summarizes
+	stagingWindow = 1000
+)
+
+// errStagingReadOnly is returned for the writes attempted through the staging client
+var errStagingReadOnly = errors.New("the staging table is read-only")
+
+// StagingComparison is a page served from production next to the same page read from staging. Like
+// shadow read mismatches, it leaves out item values.
+type StagingComparison struct {
+	Page              int64
+	PageSize          int64
+	ProductionSize    int64
+	StagingSize       int64
+	ProductionHasMore bool
+	StagingHasMore    bool
+	ProductionMs      int64
+	StagingMs         int64
+	// Match is set when both pages hold the same number of items and agree on HasMore
+	Match bool
+	Error string `json:",omitempty"`
+}
+
+// StagingReport summarizes the recent comparisons with staging
+type StagingReport struct {
+	Table       string
+	Comparisons int
+	Mismatches  int
+	Errors      int
+	// The latency percentiles of the pages compared, in milliseconds
+	ProductionP50Ms int64
+	ProductionP95Ms int64
+	StagingP50Ms    int64
+	StagingP95Ms    int64
+}
+
+// StagingShadow mirrors a sample of the pages served to a staging table, read-only, and compares their
+// latency and item counts, to validate schema or index changes before traffic moves to them
+type StagingShadow struct {
+	// Rate is the fraction of eligible requests that are mirrored
+	Rate   float64
+	table  string
+	client DynamoClient
+	slots  chan struct{}
+	logger *log.Logger
+	sample func() float64
+
+	mu sync.Mutex
+	// recent holds the last stagingWindow comparisons, next is where the following one goes
+	recent []StagingComparison
+	next   int
+}
+
+// NewStagingShadow creates a staging shadow mirroring a fraction rate of the requests to table, read
+// through client
+func NewStagingShadow(rate float64, table string, client DynamoClient, logger *log.Logger) *StagingShadow {
+	return &StagingShadow{Rate: rate, table: table, client: &stagingClient{DynamoClient: client, table: table}, slots: make(chan struct{}, maxShadowReads), logger: logger, sample: rand.Float64}
+}
+
+// loadStagingShadow mirrors requests to STAGING_SHADOW_TABLE for the fraction set by
+// STAGING_SHADOW_RATE, through client or, when STAGING_SHADOW_ENDPOINT is set, a client of that
+// endpoint in region. client should bypass the instrumentation of served requests.
+func loadStagingShadow(client DynamoClient, region string) (*StagingShadow, error) {
+	table := os.Getenv("STAGING_SHADOW_TABLE")
+	if table == "" {
+		return nil, nil
+	}
+
+	rate := defaultStagingRate
+	if v := os.Getenv("STAGING_SHADOW_RATE"); v != "" {
+		var err error
+		if rate, err = strconv.ParseFloat(v, 64); err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid STAGING_SHADOW_RATE %q", v)
+		}
+	}
+
+	if endpoint := os.Getenv("STAGING_SHADOW_ENDPOINT"); endpoint != "" {
+		var loadOpts []func(*config.LoadOptions) error
+		if region != "" {
+			loadOpts = append(loadOpts, config.WithRegion(region))
+		}
+		cfg, err := config.LoadDefaultConfig(context.TODO(), loadOpts...)
+		if err != nil {
+			return nil, errors.New("failed to load AWS configuration")
+		}
+		client = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			o.BaseEndpoint = aws.String(endpoint)
+		})
+	}
+	return NewStagingShadow(rate, table, client, log.Default()), nil
+}
+
+// mirror starts reading a page served from production from staging, with the time production took
+func (h *Handler) mirror(keyCond string, params Params, wait time.Duration, served Response, elapsed time.Duration) {
+	s := h.staging
+	// Pages waiting for items took as long as they waited, and cursors are production keys
+	if s == nil || wait > 0 || params.CursorMode || s.sample() >= s.Rate {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	// Staging is read without the caches and shadows of production
+	staged := *h
+	staged.pages = nil
+	staged.shadowReads = nil
+	staged.staging = nil
+	runShadowRead(func() {
+		defer func() { <-s.slots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowReadTimeout)
+		defer cancel()
+
+		started := time.Now()
+		res, reqErr := staged.fetchPage(ctx, s.client, keyCond, params, nil)
+		s.record(params, served, elapsed, res, reqErr, time.Since(started))
+	})
+}
+
+// record compares a page read from staging with the one served, and logs the comparison
+func (s *StagingShadow) record(params Params, served Response, elapsed time.Duration, staged Response, reqErr *requestError, stagedElapsed time.Duration) {
+	comparison := StagingComparison{
+		Page:              params.Page,
+		PageSize:          params.PageSize,
+		ProductionSize:    served.Size,
+		StagingSize:       staged.Size,
+		ProductionHasMore: served.HasMore,
+		StagingHasMore:    staged.HasMore,
+		ProductionMs:      elapsed.Milliseconds(),
+		StagingMs:         stagedElapsed.Milliseconds(),
+	}
+	if reqErr != nil {
+		comparison.Error = reqErr.message
+	}
+	comparison.Match = reqErr == nil && served.Size == staged.Size && served.HasMore == staged.HasMore
+
+	s.mu.Lock()
+	if len(s.recent) < stagingWindow {
+		s.recent = append(s.recent, comparison)
+	} else {
+		s.recent[s.next] = comparison
+	}
+	s.next = (s.next + 1) % stagingWindow
+	s.mu.Unlock()
+
+	if data, err := json.Marshal(comparison); err == nil {
+		s.logger.Printf("staging_shadow %s", data)
+	}
+}
+
+// Report summarizes the recent comparisons
+func (s *StagingShadow) Report() StagingReport {
+	s.mu.Lock()
+	recent := append([]StagingComparison(nil), s.recent...)
+	s.mu.Unlock()
+
+	report := StagingReport{Table: s.table, Comparisons: len(recent)}
+	var production, staging []int64
+	for _, comparison := range recent {
+		if comparison.Error != "" {
+			report.Errors++
+			continue
+		}
+		if !comparison.Match {
+			report.Mismatches++
+		}
+		production = append(production, comparison.ProductionMs)
+		staging = append(staging, comparison.StagingMs)
+	}
+	report.ProductionP50Ms, report.ProductionP95Ms = percentile(production, 0.5), percentile(production, 0.95)
+	report.StagingP50Ms, report.StagingP95Ms = percentile(staging, 0.5), percentile(staging, 0.95)
+	return report
+}
+
+// percentile returns the q quantile of values, 0 when there are none
+func percentile(values []int64, q float64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+// handleStagingReport reports how the pages read from staging compare with those served
+func (h *Handler) handleStagingReport(c echo.Context) error {
+	if h.staging == nil {
+		return respondError(c, http.StatusNotFound, "Staging shadowing is disabled")
+	}
+	return c.JSON(http.StatusOK, h.staging.Report())
+}
+
+// stagingClient reads the staging table in place of the tables it is asked for, and refuses writes
+type stagingClient struct {
+	DynamoClient
+	table string
+}
+
+func (c *stagingClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return c.DynamoClient.Query(ctx, onTable(params, c.table), optFns...)
+}
+
+func (c *stagingClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	input := *params
+	input.TableName = &c.table
+	return c.DynamoClient.Scan(ctx, &input, optFns...)
+}
+
+func (c *stagingClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	input := *params
+	input.TableName = &c.table
+	return c.DynamoClient.GetItem(ctx, &input, optFns...)
+}
+
+func (c *stagingClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	input := *params
+	input.TableName = &c.table
+	return c.DynamoClient.DescribeTable(ctx, &input, optFns...)
+}
+
+func (c *stagingClient) PutItem(context.Context, *dynamodb.PutItemInput, ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	return nil, errStagingReadOnly
+}
+
+func (c *stagingClient) UpdateItem(context.Context, *dynamodb.UpdateItemInput, ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	return nil, errStagingReadOnly
+}
+
+func (c *stagingClient) DeleteItem(context.Context, *dynamodb.DeleteItemInput, ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	return nil, errStagingReadOnly
+}
+
+func (c *stagingClient) BatchWriteItem(context.Context, *dynamodb.BatchWriteItemInput, ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	return nil, errStagingReadOnly
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tableRecorder records the tables queried through it
+type tableRecorder struct {
+	DynamoClient
+	tables []string
+}
+
+func (c *tableRecorder) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	c.tables = append(c.tables, aws.StringValue(params.TableName))
+	return c.DynamoClient.Query(ctx, params, optFns...)
+}
+
+func TestStagingShadow(t *testing.T) {
+	runShadowRead = func(read func()) { read() }
+	defer func() { runShadowRead = func(read func()) { go read() } }()
+
+	production, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	fixture, err := NewFixtureClient(GenerateFixture([]string{"test"}, 1))
+	require.NoError(t, err)
+	staged := &tableRecorder{DynamoClient: fixture}
+	var out bytes.Buffer
+	staging := NewStagingShadow(1, "EntriesStaging", staged, log.New(&out, "", 0))
+	handler := &Handler{client: production, staging: staging, pages: pagination.NewLRUPageCache(100, time.Minute, pagination.CacheAll)}
+
+	e := echo.New()
+	e.GET("/paginate", handler.handlePagination)
+	e.GET("/admin/staging", handler.handleStagingReport)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	for i := 0; i < 2; i++ {
+		require.Equal(t, http.StatusOK, serve("/paginate?key_condition=test&page=1&pagesize=2").Code)
+	}
+	// Staging is read every time, from the staging table, though production pages are cached
+	assert.Equal(t, []string{"EntriesStaging", "EntriesStaging"}, staged.tables)
+	line, _, _ := strings.Cut(out.String(), "\n")
+	assert.Regexp(t, `^staging_shadow \{"Page":1,"PageSize":2,"ProductionSize":2,"StagingSize":1,"ProductionHasMore":true,"StagingHasMore":false,"ProductionMs":\d+,"StagingMs":\d+,"Match":false\}$`, line)
+
+	// Long polls and cursor pages aren't mirrored
+	serve("/paginate?key_condition=test&pagesize=2&cursor=")
+	serve("/paginate?key_condition=other&page=1&wait=1")
+	assert.Len(t, staged.tables, 2)
+
+	rec := serve("/admin/staging")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var report StagingReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.Equal(t, "EntriesStaging", report.Table)
+	assert.Equal(t, 2, report.Comparisons)
+	assert.Equal(t, 2, report.Mismatches)
+
+	assert.Equal(t, http.StatusNotFound, (func() int {
+		rec := httptest.NewRecorder()
+		require.NoError(t, (&Handler{}).handleStagingReport(e.NewContext(httptest.NewRequest(http.MethodGet, "/admin/staging", nil), rec)))
+		return rec.Code
+	})())
+}
+
+func TestStagingShadowReport(t *testing.T) {
+	staging := NewStagingShadow(1, "Staging", nil, log.New(&bytes.Buffer{}, "", 0))
+	page := Response{Size: 2, HasMore: true}
+	for i := 1; i <= stagingWindow+10; i++ {
+		staging.record(Params{Page: 1, PageSize: 2}, page, time.Duration(i)*time.Millisecond, page, nil, 2*time.Duration(i)*time.Millisecond)
+	}
+	staging.record(Params{Page: 1, PageSize: 2}, page, time.Millisecond, Response{}, &requestError{message: "Error in DynamoDB query"}, 0)
+
+	// The window keeps the last comparisons: those of 12ms to 1010ms, and the error
+	report := staging.Report()
+	assert.Equal(t, stagingWindow, report.Comparisons)
+	assert.Equal(t, 0, report.Mismatches)
+	assert.Equal(t, 1, report.Errors)
+	assert.Equal(t, int64(511), report.ProductionP50Ms)
+	assert.Equal(t, int64(2*511), report.StagingP50Ms)
+	assert.Equal(t, int64(960), report.ProductionP95Ms)
+}
+
+func TestStagingClientIsReadOnly(t *testing.T) {
+	client := &stagingClient{table: "Staging"}
+	_, err := client.PutItem(context.Background(), &dynamodb.PutItemInput{})
+	assert.ErrorIs(t, err, errStagingReadOnly)
+	_, err = client.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{})
+	assert.ErrorIs(t, err, errStagingReadOnly)
+	_, err = client.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{})
+	assert.ErrorIs(t, err, errStagingReadOnly)
+	_, err = client.BatchWriteItem(context.Background(), &dynamodb.BatchWriteItemInput{})
+	assert.ErrorIs(t, err, errStagingReadOnly)
+}
+
+func TestLoadStagingShadow(t *testing.T) {
+	staging, err := loadStagingShadow(nil, "")
+	require.NoError(t, err)
+	assert.Nil(t, staging)
+
+	t.Setenv("STAGING_SHADOW_TABLE", "EntriesStaging")
+	staging, err = loadStagingShadow(nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, defaultStagingRate, staging.Rate)
+
+	t.Setenv("STAGING_SHADOW_RATE", "2")
+	_, err = loadStagingShadow(nil, "")
+	assert.Error(t, err)
+}
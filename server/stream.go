@@ -0,0 +1,180 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultStreamScanBudget = 100000
+	streamPageSize          = 500
+	// streamStatusTrailer reports whether the stream completed, hit the scan budget or failed
+	streamStatusTrailer = "X-Stream-Status"
+)
+
+// StreamLimits bounds the work a single streaming request may do
+type StreamLimits struct {
+	// ScanBudget is the maximum number of items read from DynamoDB per request; zero disables it
+	ScanBudget int64
+	// RCUPerSecond throttles reads to this many consumed read capacity units per second; zero disables it
+	RCUPerSecond float64
+}
+
+// loadStreamLimits reads STREAM_SCAN_BUDGET and STREAM_RCU_PER_SECOND
+func loadStreamLimits() (StreamLimits, error) {
+	limits := StreamLimits{ScanBudget: defaultStreamScanBudget}
+
+	if v := os.Getenv("STREAM_SCAN_BUDGET"); v != "" {
+		budget, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return limits, err
+		}
+		limits.ScanBudget = budget
+	}
+
+	if v := os.Getenv("STREAM_RCU_PER_SECOND"); v != "" {
+		rcu, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return limits, err
+		}
+		limits.RCUPerSecond = rcu
+	}
+
+	return limits, nil
+}
+
+// throttle waits long enough for the consumed capacity to fit within the configured rate
+func (l StreamLimits) throttle(ctx context.Context, consumed *types.ConsumedCapacity) error {
+	if l.RCUPerSecond <= 0 || consumed == nil || consumed.CapacityUnits == nil {
+		return nil
+	}
+
+	wait := time.Duration(*consumed.CapacityUnits / l.RCUPerSecond * float64(time.Second))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// handleStreamAll walks every page of a query and streams the matching items as JSON lines
+func (h *Handler) handleStreamAll(c echo.Context) error {
+	keyCond, reqErr := h.queryPartition(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if keyCond == "" {
+		return respondError(c, http.StatusBadRequest, "Invalid key_condition parameter")
+	}
+
+	client, ok := h.clientFor(c)
+	if !ok {
+		return respondError(c, http.StatusBadRequest, "Invalid region parameter")
+	}
+
+	params, reqErr := h.extractParams(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if params.ValidateOrder(tableKeys) != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid orderby parameter")
+	}
+	if reqErr := parseQuerySortRange(c, &params); reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if reqErr := checkSortKey(params, tableKeys); reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if reqErr := h.checkHiddenKeys(params); reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if reqErr := h.parseConsistent(c, &params); reqErr != nil {
+		return reqErr.respond(c)
+	}
+	ctx := c.Request().Context()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/x-ndjson")
+	res.Header().Set("Trailer", streamStatusTrailer)
+	res.WriteHeader(http.StatusOK)
+
+	status := "complete"
+	defer func() {
+		res.Header().Set(streamStatusTrailer, status)
+	}()
+
+	encoder := json.NewEncoder(res)
+	limit := int32(streamPageSize)
+	var scanned int64
+	var lastEvaluatedKey map[string]types.AttributeValue
+
+	for {
+		input := keyConditionQuery(keyCond)
+		input.Limit = &limit
+		input.ExclusiveStartKey = lastEvaluatedKey
+		params.ApplyOrder(input)
+		params.ApplySearch(input, tableKeys)
+		params.ApplySortRange(input, tableKeys)
+		if params.ConsistentRead {
+			input.ConsistentRead = aws.Bool(true)
+		}
+		if h.stream.RCUPerSecond > 0 {
+			input.ReturnConsumedCapacity = pagination.RequestCapacity(input.ReturnConsumedCapacity, types.ReturnConsumedCapacityTotal)
+		}
+
+		result, err := client.Query(ctx, input)
+		if err != nil {
+			c.Logger().Error(err)
+			status = "error"
+			return nil
+		}
+
+		for _, item := range result.Items {
+			entry, warnings, keep, reqErr := h.decodePageItem(item, fullItem)
+			if reqErr != nil {
+				c.Logger().Error(reqErr)
+				status = "error"
+				return nil
+			}
+			for _, w := range warnings {
+				c.Logger().Warnf("%s %v: %s", w.Code, w.Key, w.Message)
+			}
+
+			if keep && params.Matches(attributeString(item[tableKeys.SortKey])) {
+				if err := encoder.Encode(tenantFrom(ctx).redactEntry(entry)); err != nil {
+					status = "error"
+					return err
+				}
+			}
+		}
+		res.Flush()
+
+		scanned += int64(len(result.Items))
+		lastEvaluatedKey = result.LastEvaluatedKey
+		if lastEvaluatedKey == nil {
+			return nil
+		}
+		if h.stream.ScanBudget > 0 && scanned >= h.stream.ScanBudget {
+			status = "truncated"
+			return nil
+		}
+
+		if err := h.stream.throttle(ctx, result.ConsumedCapacity); err != nil {
+			status = "error"
+			return nil
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleStreamAll(t *testing.T) {
+	tests := []struct {
+		name           string
+		queryParam     string
+		limits         StreamLimits
+		expectedBody   string
+		expectedStatus string
+	}{
+		{
+			name:       "All Pages",
+			queryParam: "key_condition=test",
+			expectedBody: `{"key_cond":"test","sort_key":"item1"}
+{"key_cond":"test","sort_key":"item2"}
+{"key_cond":"test","sort_key":"item3"}
+`,
+			expectedStatus: "complete",
+		},
+		{
+			name:       "Search",
+			queryParam: "key_condition=test&search=2",
+			expectedBody: `{"key_cond":"test","sort_key":"item2"}
+`,
+			expectedStatus: "complete",
+		},
+		{
+			name:       "Scan Budget",
+			queryParam: "key_condition=test",
+			limits:     StreamLimits{ScanBudget: 1},
+			expectedBody: `{"key_cond":"test","sort_key":"item1"}
+`,
+			expectedStatus: "truncated",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, err := LoadFixtureClient("testdata/fixtures.json")
+			require.NoError(t, err)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/stream-all?"+test.queryParam, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := &Handler{client: &pagedClient{DynamoClient: client, pageSize: 1}, stream: test.limits}
+			require.NoError(t, handler.handleStreamAll(c))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/x-ndjson", rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, test.expectedBody, rec.Body.String())
+			assert.Equal(t, test.expectedStatus, rec.Header().Get(streamStatusTrailer))
+		})
+	}
+}
+
+// pagedClient forces a small page size so tests exercise multi-page traversal
+type pagedClient struct {
+	DynamoClient
+	pageSize int32
+}
+
+func (p *pagedClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	input := *params
+	input.Limit = &p.pageSize
+	return p.DynamoClient.Query(ctx, &input, optFns...)
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// reservedTableNames are the /paginate routes that take precedence over /paginate/:table
+var reservedTableNames = map[string]bool{"keys": true, "estimate": true, "exchange": true}
+
+// Table is a table registered for /paginate/:table, with its key attributes and secondary indexes.
+// Its items are served as Entry, with the key attributes read as key_cond and sort_key.
+type Table struct {
+	PartitionKey string `json:"partition_key"`
+	SortKey      string `json:"sort_key"`
+	// SortKeyType is "S" or "N", a string when empty
+	SortKeyType string               `json:"sort_key_type,omitempty"`
+	Indexes     map[string]IndexKeys `json:"indexes,omitempty"`
+	// SchemaFile and NormalizationFile are the item schema and normalization rules of the table's
+	// items, which the configured table's don't apply to
+	SchemaFile        string `json:"schema_file,omitempty"`
+	NormalizationFile string `json:"normalization_file,omitempty"`
+
+	name       string
+	keys       pagination.KeySchema
+	indexes    map[string]pagination.KeySchema
+	computed   *ComputedFields
+	types      *OutputTypes
+	validation *Validation
+	normalizer *Normalizer
+}
+
+// LoadTables reads the table registry from a JSON file mapping table names to their schema
+func LoadTables(path string) (map[string]*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTables(data)
+}
+
+// ParseTables decodes the table registry
+func ParseTables(data []byte) (map[string]*Table, error) {
+	var tables map[string]*Table
+	if err := json.Unmarshal(data, &tables); err != nil {
+		return nil, err
+	}
+
+	for name, table := range tables {
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("table has no name")
+		case reservedTableNames[name]:
+			return nil, fmt.Errorf("table %q clashes with the /paginate/%s route", name, name)
+		case table == nil || table.PartitionKey == "":
+			return nil, fmt.Errorf("table %q needs a partition_key", name)
+		}
+		indexes, err := indexSchemas(table.Indexes)
+		if err != nil {
+			return nil, fmt.Errorf("table %q: %w", name, err)
+		}
+		table.name = name
+		table.keys = pagination.KeySchema{PartitionKey: table.PartitionKey, SortKey: table.SortKey, SortKeyType: types.ScalarAttributeType(table.SortKeyType)}
+		if err := checkSortKeyType(table.keys); err != nil {
+			return nil, fmt.Errorf("table %q: %w", name, err)
+		}
+		table.indexes = indexes
+	}
+	return tables, nil
+}
+
+// loadTables reads the optional table registry configured through TABLES_FILE. Each table gets the
+// computed fields COMPUTED_FIELDS_FILE and the output types OUTPUT_TYPES_FILE define for it, and the
+// item schema and normalization rules of its own files.
+func loadTables() (map[string]*Table, error) {
+	path := os.Getenv("TABLES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	tables, err := LoadTables(path)
+	if err != nil {
+		return nil, err
+	}
+
+	for name, table := range tables {
+		if table.SchemaFile != "" {
+			if table.validation, err = LoadValidation(table.SchemaFile); err != nil {
+				return nil, fmt.Errorf("table %q: %w", name, err)
+			}
+		}
+		if table.NormalizationFile != "" {
+			if table.normalizer, err = LoadNormalizer(table.NormalizationFile); err != nil {
+				return nil, fmt.Errorf("table %q: %w", name, err)
+			}
+		}
+	}
+
+	if computedPath := os.Getenv("COMPUTED_FIELDS_FILE"); computedPath != "" {
+		for name, table := range tables {
+			if table.computed, err = LoadComputedFields(computedPath, name); err != nil {
+				return nil, fmt.Errorf("table %q: %w", name, err)
+			}
+		}
+	}
+	if typesPath := os.Getenv("OUTPUT_TYPES_FILE"); typesPath != "" {
+		for name, table := range tables {
+			if table.types, err = LoadOutputTypes(typesPath, name); err != nil {
+				return nil, fmt.Errorf("table %q: %w", name, err)
+			}
+		}
+	}
+	return tables, nil
+}
+
+// schema returns the name and key attributes of the table the handler serves
+func (h *Handler) schema() (string, pagination.KeySchema) {
+	if h.table == nil {
+		return tableName, tableKeys
+	}
+	return h.table.name, h.table.keys
+}
+
+// forTables creates a handler per registered table. They share the clients of h, but decode items
+// with the rules of their table, and don't get its pre-flight estimates, shadow reads and index
+// recommendations, which are kept for the configured table.
+func (h *Handler) forTables(tables map[string]*Table) map[string]*Handler {
+	handlers := make(map[string]*Handler, len(tables))
+	for name, table := range tables {
+		th := *h
+		th.table = table
+		th.indexes = table.indexes
+		th.computed = table.computed
+		th.outputTypes = table.types
+		th.validation = table.validation
+		th.normalizer = table.normalizer
+		th.estimator = nil
+		th.shadowReads = nil
+		th.staging = nil
+		th.advisor = nil
+		th.tables = nil
+		handlers[name] = &th
+	}
+	return handlers
+}
+
+// handleTableItem serves GET /items for one of the registered tables, at /tables/:table/items
+func (h *Handler) handleTableItem(c echo.Context) error {
+	th, ok := h.tables[c.Param("table")]
+	if !ok {
+		return respondError(c, http.StatusNotFound, "Table not found")
+	}
+	// Items of a table without a sort key are named by their partition key alone
+	named := len(c.ParamNames()) == 3
+	if named != (th.table.SortKey != "") {
+		return respondError(c, http.StatusNotFound, "Item not found")
+	}
+	return th.handleGetItem(c)
+}
+
+// handleTablePagination serves /paginate for one of the registered tables
+func (h *Handler) handleTablePagination(c echo.Context) error {
+	th, ok := h.tables[c.Param("table")]
+	if !ok {
+		return respondError(c, http.StatusNotFound, "Table not found")
+	}
+	return th.handlePagination(c)
+}
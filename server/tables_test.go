@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTables(t *testing.T) {
+	tables, err := ParseTables([]byte(`{"Orders": {"partition_key": "customer", "sort_key": "created_at", "indexes": {"by_status": {"partition_key": "status"}}}}`))
+	require.NoError(t, err)
+	orders := tables["Orders"]
+	assert.Equal(t, pagination.KeySchema{PartitionKey: "customer", SortKey: "created_at"}, orders.keys)
+	assert.Equal(t, map[string]pagination.KeySchema{"by_status": {PartitionKey: "status"}}, orders.indexes)
+
+	tables, err = ParseTables([]byte(`{"Customers": {"partition_key": "customer"}}`))
+	require.NoError(t, err)
+	assert.Equal(t, pagination.KeySchema{PartitionKey: "customer"}, tables["Customers"].keys)
+
+	for _, data := range []string{
+		`{"Orders": {"sort_key": "created_at"}}`,
+		`{"keys": {"partition_key": "customer", "sort_key": "created_at"}}`,
+		`{"Orders": {"partition_key": "customer", "sort_key": "created_at", "indexes": {"by_status": {}}}}`,
+	} {
+		_, err := ParseTables([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestLoadTablesDecodingRules(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+		return path
+	}
+	schema := write("orders-schema.json", `{"type": "object", "required": ["total"]}`)
+	rules := write("orders-rules.json", `{"rules": []}`)
+	t.Setenv("TABLES_FILE", write("tables.json", `{
+		"Orders": {"partition_key": "customer", "sort_key": "created_at", "schema_file": "`+schema+`", "normalization_file": "`+rules+`"},
+		"Invoices": {"partition_key": "account", "sort_key": "number"}
+	}`))
+	tables, err := loadTables()
+	require.NoError(t, err)
+	require.NotNil(t, tables["Orders"].validation)
+	assert.Equal(t, []string{"total"}, tables["Orders"].validation.Schema.Required)
+	assert.NotNil(t, tables["Orders"].normalizer)
+
+	// The rules of the configured table aren't applied to the registered ones
+	handler := &Handler{validation: &Validation{Schema: &Schema{}}, normalizer: &Normalizer{}}
+	handlers := handler.forTables(tables)
+	assert.Same(t, tables["Orders"].validation, handlers["Orders"].validation)
+	assert.Same(t, tables["Orders"].normalizer, handlers["Orders"].normalizer)
+	assert.Nil(t, handlers["Invoices"].validation)
+	assert.Nil(t, handlers["Invoices"].normalizer)
+
+	t.Setenv("TABLES_FILE", write("invalid.json", `{"Orders": {"partition_key": "customer", "schema_file": "`+filepath.Join(dir, "missing.json")+`"}}`))
+	_, err = loadTables()
+	assert.Error(t, err)
+}
+
+func TestHandleTablePagination(t *testing.T) {
+	tables, err := ParseTables([]byte(`{"Orders": {"partition_key": "customer", "sort_key": "created_at"}}`))
+	require.NoError(t, err)
+
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.MatchedBy(func(input *dynamodb.QueryInput) bool {
+		return *input.TableName == "Orders" && input.ExpressionAttributeNames["#pk"] == "customer" &&
+			attributeString(input.ExpressionAttributeValues[":keyCond"]) == "c1"
+	})).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{{
+		"customer":   &types.AttributeValueMemberS{Value: "c1"},
+		"created_at": &types.AttributeValueMemberS{Value: "2024-01-01"},
+	}}}, nil)
+	handler := &Handler{client: mockDynamoDB}
+	handler.tables = handler.forTables(tables)
+
+	paginate := func(table string) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/paginate/"+table+"?key_condition=c1", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("table")
+		c.SetParamValues(table)
+		require.NoError(t, handler.handleTablePagination(c))
+		return rec
+	}
+
+	rec := paginate("Orders")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, []Entry{{KeyCond: "c1", SortKey: "2024-01-01"}}, response.Data)
+
+	assert.Equal(t, http.StatusNotFound, paginate("Invoices").Code)
+	mockDynamoDB.AssertNumberOfCalls(t, "Query", 1)
+}
+
+func TestHandleTableItem(t *testing.T) {
+	tables, err := ParseTables([]byte(`{"Orders": {"partition_key": "customer", "sort_key": "created_at"}, "Customers": {"partition_key": "customer"}}`))
+	require.NoError(t, err)
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return *input.TableName == "Orders" && attributeString(input.Key["customer"]) == "c1" && attributeString(input.Key["created_at"]) == "2024-01-01"
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"customer":   &types.AttributeValueMemberS{Value: "c1"},
+		"created_at": &types.AttributeValueMemberS{Value: "2024-01-01"},
+	}}, nil)
+	handler := &Handler{client: mockDynamoDB}
+	handler.tables = handler.forTables(tables)
+
+	e := echo.New()
+	e.GET("/tables/:table/items/:pk/:sk", handler.handleTableItem)
+	e.GET("/tables/:table/items/:pk", handler.handleTableItem)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get("/tables/Orders/items/c1/2024-01-01")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var item ItemResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &item))
+	assert.Equal(t, Entry{KeyCond: "c1", SortKey: "2024-01-01"}, item.Data)
+
+	assert.Equal(t, http.StatusNotFound, get("/tables/Orders/items/c1").Code, "items of tables with a sort key are named by both keys")
+	assert.Equal(t, http.StatusNotFound, get("/tables/Customers/items/c1/2024-01-01").Code)
+	assert.Equal(t, http.StatusNotFound, get("/tables/Invoices/items/c1/2024-01-01").Code)
+	mockDynamoDB.AssertNumberOfCalls(t, "GetItem", 1)
+}
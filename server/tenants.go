@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// TenantConfig is what a tenant's requests may do. The defaults apply to every request; a tenant's
+// settings replace them, except Redact, which adds to the attributes the defaults redact.
+type TenantConfig struct {
+	// APIKeys identify the requests of a tenant
+	APIKeys []string `json:"api_keys,omitempty"`
+	// MaxPageSize caps the pagesize parameter
+	MaxPageSize int64 `json:"max_page_size,omitempty"`
+	// Tables lists the tables that can be read; unset allows every table
+	Tables []string `json:"tables,omitempty"`
+	// Rate is the number of requests per second admitted, with bursts of up to Burst requests. Each
+	// tenant has its own limit; callers that aren't tenants share one.
+	Rate  float64 `json:"rate,omitempty"`
+	Burst int     `json:"burst,omitempty"`
+	// Redact lists computed fields and typed attributes left out of the items served
+	Redact []string `json:"redact,omitempty"`
+	// Roles are what the tenant may do beyond reading, e.g. "writer" for the write endpoints
+	Roles []string `json:"roles,omitempty"`
+	// MaxRoundTrips bounds the DynamoDB round trips walking to one page
+	MaxRoundTrips int64 `json:"max_round_trips,omitempty"`
+	// Profile is the response profile /paginate pages are served in: v1, v2, jsonapi or hal
+	Profile string `json:"profile,omitempty"`
+
+	// name is the tenant's name in the configuration, empty for the defaults
+	name    string
+	limiter *rate.Limiter
+}
+
+// Tenants layers the configuration of tenants, identified by their API keys, over global defaults
+type Tenants struct {
+	Defaults TenantConfig             `json:"defaults"`
+	Tenants  map[string]*TenantConfig `json:"tenants"`
+
+	keys []tenantKey
+}
+
+type tenantKey struct {
+	key    []byte
+	tenant *TenantConfig
+}
+
+// LoadTenants reads the tenant configuration from a JSON file
+func LoadTenants(path string) (*Tenants, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTenants(data)
+}
+
+// ParseTenants decodes the tenant configuration and resolves every tenant over the defaults
+func ParseTenants(data []byte) (*Tenants, error) {
+	var t Tenants
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+
+	if len(t.Defaults.APIKeys) > 0 {
+		return nil, errors.New("defaults can't have API keys")
+	}
+	if err := t.Defaults.setup(); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	seen := map[string]string{}
+	for name, tenant := range t.Tenants {
+		if tenant == nil || len(tenant.APIKeys) == 0 {
+			return nil, fmt.Errorf("%s: tenant has no API keys", name)
+		}
+		resolved := t.Defaults.overlay(*tenant)
+		resolved.name = name
+		if err := resolved.setup(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		t.Tenants[name] = &resolved
+
+		for _, key := range tenant.APIKeys {
+			if other, ok := seen[key]; ok {
+				return nil, fmt.Errorf("%s: API key is also assigned to %s", name, other)
+			}
+			seen[key] = name
+			t.keys = append(t.keys, tenantKey{key: []byte(key), tenant: &resolved})
+		}
+	}
+	return &t, nil
+}
+
+// overlay returns the defaults with the settings of a tenant applied
+func (c TenantConfig) overlay(tenant TenantConfig) TenantConfig {
+	resolved := c
+	resolved.APIKeys = tenant.APIKeys
+	if tenant.MaxPageSize != 0 {
+		resolved.MaxPageSize = tenant.MaxPageSize
+	}
+	if tenant.Tables != nil {
+		resolved.Tables = tenant.Tables
+	}
+	if tenant.Rate != 0 {
+		resolved.Rate, resolved.Burst = tenant.Rate, tenant.Burst
+	}
+	if tenant.Roles != nil {
+		resolved.Roles = tenant.Roles
+	}
+	if tenant.MaxRoundTrips != 0 {
+		resolved.MaxRoundTrips = tenant.MaxRoundTrips
+	}
+	if tenant.Profile != "" {
+		resolved.Profile = tenant.Profile
+	}
+	resolved.Redact = append(append([]string{}, c.Redact...), tenant.Redact...)
+	return resolved
+}
+
+func (c *TenantConfig) setup() error {
+	if c.MaxPageSize < 0 || c.MaxRoundTrips < 0 || c.Rate < 0 || c.Burst < 0 {
+		return errors.New("limits can't be negative")
+	}
+	if err := checkProfile(c.Profile); err != nil {
+		return err
+	}
+	if c.Rate > 0 {
+		burst := c.Burst
+		if burst == 0 {
+			burst = int(math.Ceil(c.Rate))
+		}
+		c.limiter = rate.NewLimiter(rate.Limit(c.Rate), burst)
+	}
+	return nil
+}
+
+// loadTenants reads the optional tenant configuration from TENANTS_FILE
+func loadTenants() (*Tenants, error) {
+	path := os.Getenv("TENANTS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadTenants(path)
+}
+
+// resolve returns the configuration of the tenant a request's API key belongs to, or the defaults
+func (t *Tenants) resolve(req *http.Request) *TenantConfig {
+	if key := requestAPIKey(req); key != "" {
+		for _, known := range t.keys {
+			if subtle.ConstantTimeCompare([]byte(key), known.key) == 1 {
+				return known.tenant
+			}
+		}
+	}
+	return &t.Defaults
+}
+
+// Middleware resolves the tenant of every request, for the routes to apply its configuration. Requests
+// over the tenant's rate get a 429, and requests for a table it can't read a 403: the table of the
+// route's :table parameter, or else the default one.
+func (t *Tenants) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tenant := t.resolve(c.Request())
+		if tenant.limiter != nil && !tenant.limiter.Allow() {
+			c.Response().Header().Set("Retry-After", strconv.Itoa(retryAfter(tenant.limiter)))
+			return respondError(c, http.StatusTooManyRequests, "Too many requests")
+		}
+		table := c.Param("table")
+		if table == "" {
+			table = tableName
+		}
+		if !tenant.allowsTable(table) {
+			return respondError(c, http.StatusForbidden, "Table is not available to this tenant")
+		}
+		rc := ensureRequestContext(c)
+		rc.Tenant = tenant
+		if tenant.name != "" {
+			rc.Caller = tenant.name
+		}
+		rc.Roles = append(rc.Roles, tenant.Roles...)
+		// The profile of an API key is more specific than its tenant's
+		if rc.Profile == "" {
+			rc.Profile = tenant.Profile
+		}
+		rc.Budget.MaxPageSize = tenant.MaxPageSize
+		rc.Budget.MaxRoundTrips = tenant.MaxRoundTrips
+		return next(c)
+	}
+}
+
+// tenantFrom returns the configuration of the tenant making a request, or nil when tenants aren't
+// configured
+func tenantFrom(ctx context.Context) *TenantConfig {
+	if rc := requestContextFrom(ctx); rc != nil {
+		return rc.Tenant
+	}
+	return nil
+}
+
+// allowsTable reports whether the tenant can read a table
+func (c *TenantConfig) allowsTable(table string) bool {
+	if c == nil || c.Tables == nil {
+		return true
+	}
+	for _, allowed := range c.Tables {
+		if allowed == table {
+			return true
+		}
+	}
+	return false
+}
+
+// redact removes the fields the tenant can't see from entries
+func (c *TenantConfig) redact(entries []Entry) {
+	if c == nil || len(c.Redact) == 0 {
+		return
+	}
+	for i := range entries {
+		entries[i] = c.redactEntry(entries[i])
+	}
+}
+
+// redactEntry returns entry without the fields the tenant can't see. Its maps are copied, as the
+// entries of cached pages are shared between requests.
+func (c *TenantConfig) redactEntry(entry Entry) Entry {
+	if c == nil || len(c.Redact) == 0 {
+		return entry
+	}
+	without := func(fields map[string]interface{}) map[string]interface{} {
+		if fields == nil {
+			return nil
+		}
+		kept := make(map[string]interface{}, len(fields))
+		for name, v := range fields {
+			kept[name] = v
+		}
+		for _, field := range c.Redact {
+			delete(kept, field)
+		}
+		return kept
+	}
+	entry.Computed = without(entry.Computed)
+	entry.Attributes = without(entry.Attributes)
+	return entry
+}
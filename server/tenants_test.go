@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTenants(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{
+		"defaults": {"max_page_size": 100, "rate": 10, "redact": ["email"]},
+		"tenants": {
+			"acme": {"api_keys": ["acme-key"], "max_page_size": 500, "tables": ["orders"], "redact": ["ssn"]},
+			"globex": {"api_keys": ["globex-key"], "rate": 2, "burst": 4}
+		}
+	}`))
+	require.NoError(t, err)
+
+	acme := tenants.Tenants["acme"]
+	assert.Equal(t, int64(500), acme.MaxPageSize)
+	assert.Equal(t, []string{"orders"}, acme.Tables)
+	assert.Equal(t, []string{"email", "ssn"}, acme.Redact)
+	assert.Equal(t, 10, acme.limiter.Burst())
+
+	globex := tenants.Tenants["globex"]
+	assert.Equal(t, int64(100), globex.MaxPageSize)
+	assert.Nil(t, globex.Tables)
+	assert.Equal(t, 4, globex.limiter.Burst())
+
+	// Every tenant has its own limiter, and callers that aren't tenants share the defaults'
+	assert.NotSame(t, tenants.Defaults.limiter, acme.limiter)
+	req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+	assert.Same(t, &tenants.Defaults, tenants.resolve(req))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer acme-key")
+	assert.Same(t, acme, tenants.resolve(req))
+}
+
+func TestParseTenantsInvalid(t *testing.T) {
+	for _, config := range []string{
+		`{"defaults": {"api_keys": ["key"]}}`,
+		`{"defaults": {"max_page_size": -1}}`,
+		`{"tenants": {"acme": {}}}`,
+		`{"tenants": {"acme": {"api_keys": ["key"], "rate": -1}}}`,
+		`{"tenants": {"acme": {"api_keys": ["key"]}, "globex": {"api_keys": ["key"]}}}`,
+	} {
+		_, err := ParseTenants([]byte(config))
+		assert.Error(t, err, config)
+	}
+}
+
+func TestTenantsMiddleware(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{
+		"tenants": {
+			"acme": {"api_keys": ["acme-key"], "tables": ["` + tableName + `", "orders"], "rate": 1},
+			"globex": {"api_keys": ["globex-key"], "tables": ["orders"]}
+		}
+	}`))
+	require.NoError(t, err)
+
+	e := echo.New()
+	handler := tenants.Middleware(func(c echo.Context) error {
+		assert.NotNil(t, tenantFrom(c.Request().Context()))
+		return c.String(http.StatusOK, "ok")
+	})
+	serve := func(key, table string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/paginate", nil)
+		req.Header.Set(headerAPIKey, key)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		if table != "" {
+			c.SetParamNames("table")
+			c.SetParamValues(table)
+		}
+		require.NoError(t, handler(c))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, serve("acme-key", "").Code)
+	rec := serve("acme-key", "orders")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, serve("globex-key", "orders").Code)
+	assert.Equal(t, http.StatusForbidden, serve("globex-key", "").Code)
+	assert.Equal(t, http.StatusForbidden, serve("globex-key", "invoices").Code)
+	assert.Equal(t, http.StatusOK, serve("", "invoices").Code)
+}
+
+func TestHandlePaginationTenant(t *testing.T) {
+	tenants, err := ParseTenants([]byte(`{"defaults": {"max_page_size": 2, "redact": ["label"]}}`))
+	require.NoError(t, err)
+	computed, err := ParseComputedFields([]byte(`{"`+tableName+`": [{"name": "label", "expression": "sort_key"}, {"name": "partition", "expression": "key_cond"}]}`), tableName)
+	require.NoError(t, err)
+	client, err := NewFixtureClient(GenerateFixture([]string{"test"}, 5))
+	require.NoError(t, err)
+	handler := &Handler{client: client, computed: computed}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&pagesize=50", nil)
+	rec := httptest.NewRecorder()
+	require.NoError(t, tenants.Middleware(handler.handlePagination)(e.NewContext(req, rec)))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var response Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.EqualValues(t, 2, response.Size)
+	for _, entry := range response.Data {
+		assert.Equal(t, map[string]interface{}{"partition": "test"}, entry.Computed)
+	}
+}
+
+func TestRedactLeavesCachedEntriesAlone(t *testing.T) {
+	tenant := &TenantConfig{Redact: []string{"email"}}
+	cached := []Entry{{SortKey: "a", Computed: map[string]interface{}{"email": "a@example.com", "name": "a"}}}
+	served := append([]Entry(nil), cached...)
+	tenant.redact(served)
+	assert.Equal(t, map[string]interface{}{"name": "a"}, served[0].Computed)
+	assert.Equal(t, map[string]interface{}{"email": "a@example.com", "name": "a"}, cached[0].Computed)
+}
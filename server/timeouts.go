@@ -0,0 +1,248 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/labstack/echo/v4"
+)
+
+// TimeoutRule bounds how long a request, the walk of the DynamoDB pages serving it, and each DynamoDB
+// call it makes, may take. Durations use Go syntax ("800ms", "30s"); empty or zero means no limit.
+type TimeoutRule struct {
+	Request  string `json:"request,omitempty"`
+	Walk     string `json:"walk,omitempty"`
+	DynamoDB string `json:"dynamodb,omitempty"`
+
+	request  time.Duration
+	walk     time.Duration
+	dynamodb time.Duration
+}
+
+// Timeouts holds the default rule and overrides per route pattern (e.g. "/stream-all") and per table.
+// A table rule only sets the DynamoDB call timeout and takes precedence over the route.
+type Timeouts struct {
+	Default TimeoutRule            `json:"default"`
+	Routes  map[string]TimeoutRule `json:"routes,omitempty"`
+	Tables  map[string]TimeoutRule `json:"tables,omitempty"`
+}
+
+// LoadTimeouts reads timeout rules from a JSON file
+func LoadTimeouts(path string) (*Timeouts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTimeouts(data)
+}
+
+// ParseTimeouts decodes timeout rules and parses their durations
+func ParseTimeouts(data []byte) (*Timeouts, error) {
+	var t Timeouts
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+
+	if err := t.Default.parse(); err != nil {
+		return nil, fmt.Errorf("default: %w", err)
+	}
+	for _, rules := range []map[string]TimeoutRule{t.Routes, t.Tables} {
+		for name, rule := range rules {
+			if err := rule.parse(); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			rules[name] = rule
+		}
+	}
+	return &t, nil
+}
+
+func (r *TimeoutRule) parse() error {
+	var err error
+	if r.Request != "" {
+		if r.request, err = time.ParseDuration(r.Request); err != nil {
+			return fmt.Errorf("invalid request timeout: %w", err)
+		}
+	}
+	if r.Walk != "" {
+		if r.walk, err = time.ParseDuration(r.Walk); err != nil {
+			return fmt.Errorf("invalid walk timeout: %w", err)
+		}
+	}
+	if r.DynamoDB != "" {
+		if r.dynamodb, err = time.ParseDuration(r.DynamoDB); err != nil {
+			return fmt.Errorf("invalid dynamodb timeout: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadTimeouts reads the optional timeout rules configured through TIMEOUTS_FILE
+func loadTimeouts() (*Timeouts, error) {
+	path := os.Getenv("TIMEOUTS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadTimeouts(path)
+}
+
+// route returns the rule for a route pattern, falling back to the default for unset durations
+func (t *Timeouts) route(path string) TimeoutRule {
+	rule := t.Default
+	if override, ok := t.Routes[path]; ok {
+		if override.request > 0 {
+			rule.request = override.request
+		}
+		if override.walk > 0 {
+			rule.walk = override.walk
+		}
+		if override.dynamodb > 0 {
+			rule.dynamodb = override.dynamodb
+		}
+	}
+	return rule
+}
+
+// Middleware enforces the request timeout of the matched route through the request context and
+// passes its walk and DynamoDB call timeouts on to the handler and the client. A request that runs out of time before
+// responding gets a 504.
+func (t *Timeouts) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		rule := t.route(c.Path())
+
+		rc := ensureRequestContext(c)
+		rc.Budget.CallTimeout = rule.dynamodb
+		rc.Budget.WalkTimeout = rule.walk
+		ctx := c.Request().Context()
+		if rule.request > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, rule.request)
+			defer cancel()
+			rc.Budget.Deadline, _ = ctx.Deadline()
+		}
+		c.SetRequest(c.Request().WithContext(ctx))
+
+		err := next(c)
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && !c.Response().Committed {
+			return respondError(c, http.StatusGatewayTimeout, "Request timed out")
+		}
+		return err
+	}
+}
+
+// walkContext bounds the DynamoDB round trips serving one page by the walk timeout of the route, so a
+// deep page number or a sparse filter can't keep reading past it
+func walkContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := requestContextFrom(ctx).budget().WalkTimeout; timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// walkError replaces the error of a page whose walk ran out of time, while the request still had some
+func walkError(ctx, walk context.Context, reqErr *requestError) *requestError {
+	if ctx.Err() == nil && errors.Is(walk.Err(), context.DeadlineExceeded) {
+		return &requestError{status: http.StatusGatewayTimeout, message: "Page walk timed out", err: reqErr}
+	}
+	return reqErr
+}
+
+// timeoutClient bounds every DynamoDB call by the timeout of its table, or else the one of the route
+type timeoutClient struct {
+	DynamoClient
+	timeouts *Timeouts
+}
+
+// limit wraps a client so its calls honor the configured DynamoDB timeouts
+func (t *Timeouts) limit(client DynamoClient) DynamoClient {
+	return &timeoutClient{DynamoClient: client, timeouts: t}
+}
+
+// callContext derives the context of one call against table
+func (c *timeoutClient) callContext(ctx context.Context, table *string) (context.Context, context.CancelFunc) {
+	timeout := requestContextFrom(ctx).budget().CallTimeout
+	if timeout == 0 {
+		timeout = c.timeouts.Default.dynamodb
+	}
+	if table != nil {
+		if rule, ok := c.timeouts.Tables[*table]; ok && rule.dynamodb > 0 {
+			timeout = rule.dynamodb
+		}
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (c *timeoutClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.Query(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.Scan(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.GetItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.PutItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) UpdateItem(ctx context.Context, params *dynamodb.UpdateItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.UpdateItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) DeleteItem(ctx context.Context, params *dynamodb.DeleteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.DeleteItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) BatchWriteItem(ctx context.Context, params *dynamodb.BatchWriteItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	// Batches written by this service only ever target a single table
+	var table *string
+	for name := range params.RequestItems {
+		name := name
+		table = &name
+	}
+	ctx, cancel := c.callContext(ctx, table)
+	defer cancel()
+	return c.DynamoClient.BatchWriteItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) BatchGetItem(ctx context.Context, params *dynamodb.BatchGetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	// Batches read by this service only ever target a single table
+	var table *string
+	for name := range params.RequestItems {
+		name := name
+		table = &name
+	}
+	ctx, cancel := c.callContext(ctx, table)
+	defer cancel()
+	return c.DynamoClient.BatchGetItem(ctx, params, optFns...)
+}
+
+func (c *timeoutClient) DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	ctx, cancel := c.callContext(ctx, params.TableName)
+	defer cancel()
+	return c.DynamoClient.DescribeTable(ctx, params, optFns...)
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	timeouts, err := ParseTimeouts([]byte(`{
+		"default": {"request": "10s", "dynamodb": "2s"},
+		"routes": {"/stream-all": {"request": "5m"}, "/paginate": {"dynamodb": "300ms"}},
+		"tables": {"Legacy": {"dynamodb": "5s"}}
+	}`))
+	require.NoError(t, err)
+
+	stream := timeouts.route("/stream-all")
+	assert.Equal(t, 5*time.Minute, stream.request)
+	assert.Equal(t, 2*time.Second, stream.dynamodb)
+
+	paginate := timeouts.route("/paginate")
+	assert.Equal(t, 10*time.Second, paginate.request)
+	assert.Equal(t, 300*time.Millisecond, paginate.dynamodb)
+
+	assert.Equal(t, 5*time.Second, timeouts.Tables["Legacy"].dynamodb)
+
+	_, err = ParseTimeouts([]byte(`{"routes": {"/paginate": {"request": "soon"}}}`))
+	assert.Error(t, err)
+}
+
+// deadlineClient records the deadline of the last query and fails it the way a timed out call does
+type deadlineClient struct {
+	DynamoClient
+	deadline time.Duration
+}
+
+func (d *deadlineClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return &dynamodb.QueryOutput{}, nil
+	}
+	d.deadline = time.Until(deadline).Round(100 * time.Millisecond)
+	return nil, context.DeadlineExceeded
+}
+
+func TestTimeoutClient(t *testing.T) {
+	timeouts, err := ParseTimeouts([]byte(`{"default": {"dynamodb": "1s"}, "tables": {"Legacy": {"dynamodb": "3s"}}}`))
+	require.NoError(t, err)
+
+	inner := &deadlineClient{}
+	client := timeouts.limit(inner)
+
+	_, err = client.Query(context.Background(), keyConditionQuery("test"))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Equal(t, time.Second, inner.deadline)
+
+	legacy := keyConditionQuery("test")
+	legacy.TableName = aws.String("Legacy")
+	_, err = client.Query(context.Background(), legacy)
+	require.Error(t, err)
+	assert.Equal(t, 3*time.Second, inner.deadline)
+
+	// The route's DynamoDB timeout applies to tables without their own
+	ctx := withRequestContext(context.Background(), &RequestContext{Budget: Budget{CallTimeout: 2 * time.Second}})
+	_, err = client.Query(ctx, keyConditionQuery("test"))
+	require.Error(t, err)
+	assert.Equal(t, 2*time.Second, inner.deadline)
+}
+
+func TestHandlePaginationDynamoTimeout(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("Query", mock.Anything, mock.Anything).Return((*dynamodb.QueryOutput)(nil), context.DeadlineExceeded)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePagination(c))
+
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Equal(t, "DynamoDB request timed out", errorBody(t, rec).Message)
+}
+
+func TestTimeoutsMiddleware(t *testing.T) {
+	timeouts, err := ParseTimeouts([]byte(`{"routes": {"/slow": {"request": "10ms"}}}`))
+	require.NoError(t, err)
+
+	e := echo.New()
+	e.Use(timeouts.Middleware)
+	e.GET("/slow", func(c echo.Context) error {
+		<-c.Request().Context().Done()
+		return nil
+	})
+	e.GET("/fast", func(c echo.Context) error {
+		_, hasDeadline := c.Request().Context().Deadline()
+		assert.False(t, hasDeadline)
+		return c.NoContent(http.StatusOK)
+	})
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+// slowClient serves a page whose query continues until the context of the read is done
+type slowClient struct {
+	DynamoClient
+	calls int
+}
+
+func (s *slowClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	s.calls++
+	if s.calls > 1 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return &dynamodb.QueryOutput{LastEvaluatedKey: map[string]types.AttributeValue{"key_cond": &types.AttributeValueMemberS{Value: "test"}}}, nil
+}
+
+func TestWalkTimeout(t *testing.T) {
+	timeouts, err := ParseTimeouts([]byte(`{"default": {"request": "10s"}, "routes": {"/paginate": {"walk": "20ms"}}}`))
+	require.NoError(t, err)
+	assert.Equal(t, 20*time.Millisecond, timeouts.route("/paginate").walk)
+	assert.Equal(t, 10*time.Second, timeouts.route("/paginate").request)
+
+	client := &slowClient{}
+	handler := &Handler{client: client}
+	e := echo.New()
+	e.Use(timeouts.Middleware)
+	e.GET("/paginate", handler.handlePagination)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&page=3", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, rec.Code)
+	assert.Equal(t, "Page walk timed out", errorBody(t, rec).Message)
+
+	_, err = ParseTimeouts([]byte(`{"default": {"walk": "later"}}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/elad-da/dynamopagination/pagination"
+	"github.com/labstack/echo/v4"
+)
+
+// CollectionSource is one table partition contributing items to a collection
+type CollectionSource struct {
+	Table        string `json:"table"`
+	KeyCondition string `json:"key_condition"`
+}
+
+// Collection is a virtual collection that merges several queries into one stream ordered by a
+// shared sort attribute, e.g. a dataset split across environments or legacy tables
+type Collection struct {
+	Name string `json:"name"`
+	// SortAttribute orders the merged items and must be the sort key of every source. Defaults to sort_key.
+	SortAttribute string             `json:"sort_attribute,omitempty"`
+	Sources       []CollectionSource `json:"sources"`
+}
+
+// LoadCollections reads collection definitions from a JSON file
+func LoadCollections(path string) (map[string]*Collection, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCollections(data)
+}
+
+// ParseCollections decodes a JSON array of collections and indexes them by name
+func ParseCollections(data []byte) (map[string]*Collection, error) {
+	var list []*Collection
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	collections := make(map[string]*Collection, len(list))
+	for i, col := range list {
+		if col.Name == "" {
+			return nil, fmt.Errorf("collection %d has no name", i)
+		}
+		if _, dup := collections[col.Name]; dup {
+			return nil, fmt.Errorf("collection %q is defined twice", col.Name)
+		}
+		if len(col.Sources) == 0 {
+			return nil, fmt.Errorf("collection %q has no sources", col.Name)
+		}
+		for j, src := range col.Sources {
+			if src.Table == "" || src.KeyCondition == "" {
+				return nil, fmt.Errorf("collection %q: source %d needs a table and key_condition", col.Name, j)
+			}
+		}
+		if col.SortAttribute == "" {
+			col.SortAttribute = tableKeys.SortKey
+		}
+		collections[col.Name] = col
+	}
+	return collections, nil
+}
+
+// loadCollections reads the optional collections configured through COLLECTIONS_FILE
+func loadCollections() (map[string]*Collection, error) {
+	path := os.Getenv("COLLECTIONS_FILE")
+	if path == "" {
+		return nil, nil
+	}
+	return LoadCollections(path)
+}
+
+// unionSource reads one source of a collection a page at a time
+type unionSource struct {
+	client DynamoClient
+	input  *dynamodb.QueryInput
+	buffer []map[string]types.AttributeValue
+	done   bool
+}
+
+// peek returns the next item of the source without consuming it, or nil when the source is exhausted
+func (s *unionSource) peek(ctx context.Context) (map[string]types.AttributeValue, error) {
+	for len(s.buffer) == 0 && !s.done {
+		result, err := s.client.Query(ctx, s.input)
+		if err != nil {
+			return nil, err
+		}
+		s.buffer = result.Items
+		s.input.ExclusiveStartKey = result.LastEvaluatedKey
+		s.done = result.LastEvaluatedKey == nil
+	}
+	if len(s.buffer) == 0 {
+		return nil, nil
+	}
+	return s.buffer[0], nil
+}
+
+// compareSortValues orders two sort attribute values. Numbers compare numerically and other values by
+// their string form; items missing the attribute sort last.
+func compareSortValues(a, b types.AttributeValue) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return 1
+		}
+		return -1
+	}
+
+	an, aIsNum := a.(*types.AttributeValueMemberN)
+	bn, bIsNum := b.(*types.AttributeValueMemberN)
+	if aIsNum && bIsNum {
+		af, errA := strconv.ParseFloat(an.Value, 64)
+		bf, errB := strconv.ParseFloat(bn.Value, 64)
+		if errA == nil && errB == nil {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(attributeString(a), attributeString(b))
+}
+
+// fetchUnionPage merges the sources of a collection and returns the requested page. The sources are
+// read in the same direction and merged on the sort attribute, so each source is only read as far as
+// the page needs. Ties keep the order in which the sources are configured.
+func (h *Handler) fetchUnionPage(ctx context.Context, client DynamoClient, col *Collection, params Params) (Response, *requestError) {
+	limit := int32(params.PageSize)
+	descending := params.Descending()
+	_, keys := h.schema()
+
+	sources := make([]*unionSource, len(col.Sources))
+	for i, src := range col.Sources {
+		input := keyConditionQuery(src.KeyCondition)
+		input.TableName = aws.String(src.Table)
+		input.Limit = &limit
+		params.ApplyOrder(input)
+		params.ApplySearch(input, tableKeys)
+		sources[i] = &unionSource{client: client, input: input}
+	}
+
+	// The merge is checked on its own comparison of the sort attribute
+	check := pagination.NewOrderCheck(params, pagination.KeySchema{PartitionKey: tableKeys.PartitionKey, SortKey: col.SortAttribute})
+	skip := (params.Page - 1) * params.PageSize
+	var pageItems []Entry
+	var warnings []Warning
+
+	for int64(len(pageItems)) < params.PageSize {
+		next := -1
+		var nextItem map[string]types.AttributeValue
+		for i, src := range sources {
+			item, err := src.peek(ctx)
+			if err != nil {
+				return Response{}, dynamoError("Error in DynamoDB query", err)
+			}
+			if item == nil {
+				continue
+			}
+			if next == -1 {
+				next, nextItem = i, item
+				continue
+			}
+			cmp := compareSortValues(item[col.SortAttribute], nextItem[col.SortAttribute])
+			if descending && item[col.SortAttribute] != nil && nextItem[col.SortAttribute] != nil {
+				cmp = -cmp
+			}
+			if cmp < 0 {
+				next, nextItem = i, item
+			}
+		}
+		if next == -1 {
+			break
+		}
+		sources[next].buffer = sources[next].buffer[1:]
+		check.Observe(nextItem)
+
+		entry, itemWarnings, keep, reqErr := h.decodePageItem(nextItem, fullItem)
+		if reqErr != nil {
+			return Response{}, reqErr
+		}
+		// Entries carry the IDs of their keys; the search matches the sort key as stored
+		if !keep || !params.Matches(attributeString(nextItem[keys.SortKey])) {
+			continue
+		}
+		if skip > 0 {
+			skip--
+			continue
+		}
+		warnings = append(warnings, itemWarnings...)
+		pageItems = append(pageItems, entry)
+	}
+
+	res := Response{Data: pageItems, Page: params.Page, Size: int64(len(pageItems))}
+	if int64(len(pageItems)) == params.PageSize {
+		for _, src := range sources {
+			item, err := src.peek(ctx)
+			if err != nil {
+				return Response{}, dynamoError("Error in DynamoDB query", err)
+			}
+			if item != nil {
+				res.HasMore = true
+				break
+			}
+		}
+	}
+	warnings = append(warnings, check.Violations()...)
+	if len(warnings) > 0 {
+		res.Meta = &Meta{Warnings: warnings}
+	}
+	tenantFrom(ctx).redact(res.Data)
+	return res, nil
+}
+
+// collectionRequest reads the collection and parameters of a collection route
+func (h *Handler) collectionRequest(c echo.Context) (*Collection, DynamoClient, Params, *requestError) {
+	col, ok := h.collections[c.Param("name")]
+	if !ok {
+		return nil, nil, Params{}, &requestError{status: http.StatusNotFound, message: "Unknown collection"}
+	}
+
+	client, ok := h.clientFor(c)
+	if !ok {
+		return nil, nil, Params{}, &requestError{status: http.StatusBadRequest, message: "Invalid region parameter"}
+	}
+
+	for _, src := range col.Sources {
+		if !principalFrom(c.Request().Context()).allowsTable(src.Table) {
+			return nil, nil, Params{}, &requestError{status: http.StatusForbidden, message: "Table is not available to this caller"}
+		}
+		if !tenantFrom(c.Request().Context()).allowsTable(src.Table) {
+			return nil, nil, Params{}, &requestError{status: http.StatusForbidden, message: "Table is not available to this tenant"}
+		}
+	}
+
+	// The merged items are ordered by the sort attribute, so it's the only one they can be ordered by
+	params, reqErr := h.extractParams(c)
+	if reqErr != nil {
+		return nil, nil, Params{}, reqErr
+	}
+	if err := params.ValidateOrder(pagination.KeySchema{SortKey: col.SortAttribute}); err != nil {
+		return nil, nil, Params{}, &requestError{status: http.StatusBadRequest, message: "Invalid orderby parameter", err: err}
+	}
+	return col, client, params, nil
+}
+
+// handleCollection paginates a configured collection with the same parameters as /paginate
+func (h *Handler) handleCollection(c echo.Context) error {
+	col, client, params, reqErr := h.collectionRequest(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	res, reqErr := h.fetchUnionPage(c.Request().Context(), client, col, params)
+	if reqErr != nil {
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	return c.JSON(http.StatusOK, res)
+}
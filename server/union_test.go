@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// tableClient sends each query to the client of its table
+type tableClient struct {
+	DynamoClient
+	tables map[string]DynamoClient
+}
+
+func (t *tableClient) Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	return t.tables[*params.TableName].Query(ctx, params, optFns...)
+}
+
+func (t *tableClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	return t.tables[*params.TableName].GetItem(ctx, params, optFns...)
+}
+
+func newUnionClient(t *testing.T) DynamoClient {
+	fixture := func(sortKeys ...string) DynamoClient {
+		var items []map[string]interface{}
+		for _, sk := range sortKeys {
+			items = append(items, map[string]interface{}{"key_cond": "test", "sort_key": sk})
+		}
+		client, err := NewFixtureClient(Fixture{PartitionKey: "key_cond", SortKey: "sort_key", Items: items})
+		require.NoError(t, err)
+		return &pagedClient{DynamoClient: client, pageSize: 1}
+	}
+	return &tableClient{tables: map[string]DynamoClient{
+		"current": fixture("a", "c", "e"),
+		"legacy":  fixture("b", "d"),
+	}}
+}
+
+func TestParseCollections(t *testing.T) {
+	collections, err := ParseCollections([]byte(`[{"name": "all", "sources": [{"table": "current", "key_condition": "test"}]}]`))
+	require.NoError(t, err)
+	assert.Equal(t, "sort_key", collections["all"].SortAttribute)
+
+	for _, data := range []string{
+		`[{"sources": [{"table": "current", "key_condition": "test"}]}]`,
+		`[{"name": "all"}]`,
+		`[{"name": "all", "sources": [{"table": "current"}]}]`,
+		`[{"name": "all", "sources": [{"table": "a", "key_condition": "x"}]}, {"name": "all", "sources": [{"table": "b", "key_condition": "y"}]}]`,
+	} {
+		_, err := ParseCollections([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestCompareSortValues(t *testing.T) {
+	n := func(v string) types.AttributeValue { return &types.AttributeValueMemberN{Value: v} }
+	s := func(v string) types.AttributeValue { return &types.AttributeValueMemberS{Value: v} }
+
+	assert.Equal(t, -1, compareSortValues(n("9"), n("10")))
+	assert.Equal(t, 1, compareSortValues(s("9"), s("10")))
+	assert.Equal(t, 0, compareSortValues(s("a"), s("a")))
+	assert.Equal(t, -1, compareSortValues(s("z"), nil))
+	assert.Equal(t, 1, compareSortValues(nil, s("a")))
+}
+
+func TestHandleCollection(t *testing.T) {
+	collections := map[string]*Collection{
+		"all": {Name: "all", SortAttribute: "sort_key", Sources: []CollectionSource{
+			{Table: "current", KeyCondition: "test"},
+			{Table: "legacy", KeyCondition: "test"},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "first page", query: "pagesize=2", expected: []string{"a", "b"}},
+		{name: "second page", query: "page=2&pagesize=2", expected: []string{"c", "d"}},
+		{name: "last page", query: "page=3&pagesize=2", expected: []string{"e"}},
+		{name: "descending", query: "pagesize=3&orderby=-sort_key", expected: []string{"e", "d", "c"}},
+		{name: "search", query: "pagesize=5&search=d", expected: []string{"d"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/collections/all?"+test.query, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetParamNames("name")
+			c.SetParamValues("all")
+
+			handler := &Handler{client: newUnionClient(t), collections: collections}
+			require.NoError(t, handler.handleCollection(c))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			var response Response
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+			sortKeys := []string{}
+			for _, entry := range response.Data {
+				sortKeys = append(sortKeys, entry.SortKey)
+			}
+			assert.Equal(t, test.expected, sortKeys)
+			assert.EqualValues(t, len(test.expected), response.Size)
+		})
+	}
+}
+
+func TestHandleCollectionInvalidOrderBy(t *testing.T) {
+	collections := map[string]*Collection{"all": {Name: "all", SortAttribute: "sort_key"}}
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/collections/all?orderby=-created_at", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues("all")
+
+	handler := &Handler{client: newUnionClient(t), collections: collections}
+	require.NoError(t, handler.handleCollection(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestHandleCollectionUnknown(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/collections/missing", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("name")
+	c.SetParamValues("missing")
+
+	handler := &Handler{}
+	require.NoError(t, handler.handleCollection(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandleCollectionAssertOrder(t *testing.T) {
+	collections := map[string]*Collection{
+		"all": {Name: "all", SortAttribute: "sort_key", Sources: []CollectionSource{
+			{Table: "current", KeyCondition: "test"},
+			{Table: "legacy", KeyCondition: "test"},
+		}},
+	}
+	// The legacy source returns its items out of order, which the merge carries into the page
+	legacy := new(MockDynamoDB)
+	legacy.On("Query", mock.Anything, mock.Anything).Return(&dynamodb.QueryOutput{Items: []map[string]types.AttributeValue{
+		{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "d"}},
+		{"key_cond": &types.AttributeValueMemberS{Value: "test"}, "sort_key": &types.AttributeValueMemberS{Value: "b"}},
+	}}, nil)
+	client := newUnionClient(t).(*tableClient)
+	client.tables["legacy"] = legacy
+
+	for _, assertOrder := range []bool{true, false} {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/collections/all?pagesize=5", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.SetParamNames("name")
+		c.SetParamValues("all")
+
+		handler := &Handler{client: client, collections: collections, assertOrder: assertOrder}
+		require.NoError(t, handler.handleCollection(c))
+
+		var response Response
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+		require.Len(t, response.Data, 5)
+		if !assertOrder {
+			assert.Nil(t, response.Meta)
+			continue
+		}
+		require.NotNil(t, response.Meta)
+		require.Len(t, response.Meta.Warnings, 1)
+		assert.Equal(t, "order_violation", response.Meta.Warnings[0].Code)
+		assert.Equal(t, map[string]string{"key_cond": "test", "sort_key": "b"}, response.Meta.Warnings[0].Key)
+	}
+}
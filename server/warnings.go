@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerWarning     = "Warning"
+	headerDeprecation = "Deprecation"
+	// maxWarningHeaders bounds the Warning headers of a response, one per warning code
+	maxWarningHeaders = 10
+)
+
+// deprecation is a query parameter, or one of its values, that clients should stop sending
+type deprecation struct {
+	param string
+	// value is the deprecated value, or empty when the parameter itself is deprecated
+	value       string
+	replacement string
+	// since is when it was deprecated, sent in the Deprecation header
+	since time.Time
+}
+
+// deprecations are the parameters still served but due to be removed
+var deprecations = []deprecation{
+	// sort_begins_with runs the same key condition, and leaves search free to match in the service
+	{param: "search_mode", value: "prefix", replacement: "sort_begins_with", since: time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)},
+}
+
+// usedDeprecations returns the deprecated parameters a request sends
+func usedDeprecations(c echo.Context) []deprecation {
+	var used []deprecation
+	for _, d := range deprecations {
+		if v := c.QueryParam(d.param); v != "" && (d.value == "" || strings.EqualFold(v, d.value)) {
+			used = append(used, d)
+		}
+	}
+	return used
+}
+
+// setDeprecation sets the Deprecation header (RFC 9745) of a request sending deprecated parameters, to
+// the earliest date one of them was deprecated
+func setDeprecation(c echo.Context, used []deprecation) {
+	if len(used) == 0 {
+		return
+	}
+	since := used[0].since
+	for _, d := range used[1:] {
+		if d.since.Before(since) {
+			since = d.since
+		}
+	}
+	c.Response().Header().Set(headerDeprecation, "@"+strconv.FormatInt(since.Unix(), 10))
+}
+
+// Deprecations sets the Deprecation header of every request sending deprecated parameters. Pages also
+// report them in their warnings.
+func Deprecations(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		setDeprecation(c, usedDeprecations(c))
+		return next(c)
+	}
+}
+
+// requestWarnings are the warnings about a page as a whole: the deprecated parameters the request sent,
+// and whether items were left out of the page
+func requestWarnings(c echo.Context, warnings []Warning) []Warning {
+	used := usedDeprecations(c)
+	setDeprecation(c, used)
+
+	var page []Warning
+	for _, d := range used {
+		name := d.param
+		if d.value != "" {
+			name += "=" + d.value
+		}
+		page = append(page, Warning{Code: "deprecated_parameter", Message: fmt.Sprintf("%s is deprecated; use %s instead", name, d.replacement)})
+	}
+	skipped := 0
+	for _, w := range warnings {
+		if w.Code == "decode_error" {
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		page = append(page, Warning{Code: "partial_results", Message: fmt.Sprintf("%d items couldn't be decoded and were left out of the page", skipped)})
+	}
+	return page
+}
+
+// withWarnings adds the warnings about the page as a whole to a Response or an Envelope, and reports
+// every warning of the page in Warning headers
+func withWarnings(c echo.Context, page interface{}) interface{} {
+	switch p := page.(type) {
+	case Response:
+		var warnings []Warning
+		if p.Meta != nil {
+			warnings = p.Meta.Warnings
+		}
+		if extra := requestWarnings(c, warnings); len(extra) > 0 {
+			if p.Meta == nil {
+				p.Meta = &Meta{}
+			}
+			meta := *p.Meta
+			meta.Warnings = append(extra, warnings...)
+			p.Meta = &meta
+		}
+		if p.Meta != nil {
+			setWarnings(c, p.Meta.Warnings)
+		}
+		return p
+	case Envelope:
+		warnings := make([]Warning, len(p.Warnings))
+		for i, w := range p.Warnings {
+			warnings[i] = Warning{Code: w.Code, Message: w.Message, Key: w.Key}
+		}
+		extra := requestWarnings(c, warnings)
+		envelopeWarnings := make([]EnvelopeWarning, 0, len(extra)+len(p.Warnings))
+		for _, w := range extra {
+			envelopeWarnings = append(envelopeWarnings, EnvelopeWarning{Code: w.Code, Message: w.Message})
+		}
+		if p.Warnings = append(envelopeWarnings, p.Warnings...); len(p.Warnings) == 0 {
+			p.Warnings = nil
+		}
+		setWarnings(c, append(extra, warnings...))
+		return p
+	}
+	return page
+}
+
+// setWarnings reports warnings in Warning headers (RFC 7234), one per code with the first message of
+// the code and the number of others
+func setWarnings(c echo.Context, warnings []Warning) {
+	var codes []string
+	first := map[string]string{}
+	counts := map[string]int{}
+	for _, w := range warnings {
+		if counts[w.Code] == 0 {
+			codes = append(codes, w.Code)
+			first[w.Code] = w.Message
+		}
+		counts[w.Code]++
+	}
+	if len(codes) > maxWarningHeaders {
+		codes = codes[:maxWarningHeaders]
+	}
+
+	header := c.Response().Header()
+	header.Del(headerWarning)
+	for _, code := range codes {
+		text := code + ": " + first[code]
+		if others := counts[code] - 1; others > 0 {
+			text += fmt.Sprintf(" (and %d more)", others)
+		}
+		header.Add(headerWarning, `299 - "`+warningText(text)+`"`)
+	}
+}
+
+// warningText escapes text for the quoted string of a Warning header, replacing the characters a
+// header can't hold
+func warningText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f || r > 0x7e:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWarnings(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test&search=item&search_mode=PREFIX", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	res := Response{Meta: &Meta{Warnings: []Warning{
+		{Code: "decode_error", Message: "item couldn't be decoded", Key: map[string]string{"sort_key": "a"}},
+		{Code: "decode_error", Message: "item couldn't be decoded", Key: map[string]string{"sort_key": "b"}},
+	}}}
+	page := withWarnings(c, res).(Response)
+	require.Len(t, page.Meta.Warnings, 4)
+	assert.Equal(t, Warning{Code: "deprecated_parameter", Message: "search_mode=prefix is deprecated; use sort_begins_with instead"}, page.Meta.Warnings[0])
+	assert.Equal(t, Warning{Code: "partial_results", Message: "2 items couldn't be decoded and were left out of the page"}, page.Meta.Warnings[1])
+	assert.Len(t, res.Meta.Warnings, 2, "the page passed in is left alone")
+
+	assert.Equal(t, "@1791936000", rec.Header().Get(headerDeprecation))
+	assert.Equal(t, []string{
+		`299 - "deprecated_parameter: search_mode=prefix is deprecated; use sort_begins_with instead"`,
+		`299 - "partial_results: 2 items couldn't be decoded and were left out of the page"`,
+		`299 - "decode_error: item couldn't be decoded (and 1 more)"`,
+	}, rec.Header().Values(headerWarning))
+
+	// Envelopes carry them in their warnings
+	rec = httptest.NewRecorder()
+	env := withWarnings(e.NewContext(req, rec), Envelope{}).(Envelope)
+	assert.Equal(t, []EnvelopeWarning{{Code: "deprecated_parameter", Message: "search_mode=prefix is deprecated; use sort_begins_with instead"}}, env.Warnings)
+	assert.Len(t, rec.Header().Values(headerWarning), 1)
+
+	// Pages without warnings are served as they are
+	req = httptest.NewRequest(http.MethodGet, "/paginate?key_condition=test", nil)
+	rec = httptest.NewRecorder()
+	assert.Nil(t, withWarnings(e.NewContext(req, rec), Response{}).(Response).Meta)
+	assert.Nil(t, withWarnings(e.NewContext(req, rec), Envelope{}).(Envelope).Warnings)
+	assert.Empty(t, rec.Header().Values(headerWarning))
+	assert.Empty(t, rec.Header().Get(headerDeprecation))
+}
+
+func TestWarningText(t *testing.T) {
+	assert.Equal(t, `a \"b\" \\ c?d?`, warningText("a \"b\" \\ c\ndé"))
+}
+
+func TestHandlePaginationDeprecations(t *testing.T) {
+	client, err := LoadFixtureClient("testdata/fixtures.json")
+	require.NoError(t, err)
+	handler := &Handler{client: client}
+
+	e := echo.New()
+	e.Use(Deprecations)
+	e.GET("/paginate", handler.handlePagination)
+	e.GET("/other", func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := serve("/paginate?key_condition=test&search=item&search_mode=prefix")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "@1791936000", rec.Header().Get(headerDeprecation))
+	assert.Len(t, rec.Header().Values(headerWarning), 1)
+	var res Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.NotNil(t, res.Meta)
+	assert.Equal(t, "deprecated_parameter", res.Meta.Warnings[0].Code)
+
+	// Every route reports deprecated parameters in the Deprecation header
+	assert.Equal(t, "@1791936000", serve("/other?search_mode=prefix").Header().Get(headerDeprecation))
+	assert.Empty(t, serve("/paginate?key_condition=test&search=item&search_mode=client").Header().Get(headerDeprecation))
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// headerAPIKey carries the API key of a write request
+	headerAPIKey = "X-Api-Key"
+	// roleWriter admits the callers that have it to the write endpoints
+	roleWriter = "writer"
+)
+
+// WriteGuard admits write requests from callers that send one of the API keys, connect from one of the
+// allowed networks or have the writer role
+type WriteGuard struct {
+	keys     [][]byte
+	networks []*net.IPNet
+}
+
+// NewWriteGuard creates a guard for the API keys and CIDR networks
+func NewWriteGuard(keys, cidrs []string) (*WriteGuard, error) {
+	g := &WriteGuard{}
+	for _, key := range keys {
+		g.keys = append(g.keys, []byte(key))
+	}
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q: %w", cidr, err)
+		}
+		g.networks = append(g.networks, network)
+	}
+	if len(g.keys) == 0 && len(g.networks) == 0 {
+		return nil, errors.New("writes need WRITE_API_KEYS or WRITE_ALLOWED_CIDRS")
+	}
+	return g, nil
+}
+
+// loadWriteGuard enables the write endpoints when WRITES_ENABLED is "true". Callers are admitted with
+// one of the comma separated WRITE_API_KEYS or from one of the WRITE_ALLOWED_CIDRS networks.
+func loadWriteGuard() (*WriteGuard, error) {
+	if os.Getenv("WRITES_ENABLED") != "true" {
+		return nil, nil
+	}
+	return NewWriteGuard(parseList(os.Getenv("WRITE_API_KEYS")), parseList(os.Getenv("WRITE_ALLOWED_CIDRS")))
+}
+
+// Middleware rejects write requests that don't carry a known API key, come from an allowed network or
+// come from a caller with the writer role
+func (g *WriteGuard) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if g.hasKey(c.Request()) || g.allowsAddress(c.Request().RemoteAddr) || requestContextFrom(c.Request().Context()).HasRole(roleWriter) {
+			return next(c)
+		}
+		return respondError(c, http.StatusForbidden, "Writes are not allowed for this client")
+	}
+}
+
+// requestAPIKey returns the API key of a request, sent in X-Api-Key or as a bearer token
+func requestAPIKey(req *http.Request) string {
+	if key := req.Header.Get(headerAPIKey); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer ")
+}
+
+// hasKey reports whether the request sends one of the API keys
+func (g *WriteGuard) hasKey(req *http.Request) bool {
+	key := requestAPIKey(req)
+	if key == "" {
+		return false
+	}
+	for _, known := range g.keys {
+		if subtle.ConstantTimeCompare([]byte(key), known) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAddress reports whether the connecting address is in one of the allowed networks. Forwarding
+// headers are ignored, as any client can set them.
+func (g *WriteGuard) allowsAddress(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
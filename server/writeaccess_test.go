@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGuard(t *testing.T) {
+	guard, err := NewWriteGuard([]string{"secret"}, []string{"10.0.0.0/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		header         string
+		value          string
+		expectedStatus int
+	}{
+		{name: "API key", remoteAddr: "192.0.2.1:1234", header: headerAPIKey, value: "secret", expectedStatus: http.StatusOK},
+		{name: "Bearer token", remoteAddr: "192.0.2.1:1234", header: echo.HeaderAuthorization, value: "Bearer secret", expectedStatus: http.StatusOK},
+		{name: "Allowed network", remoteAddr: "10.1.2.3:1234", expectedStatus: http.StatusOK},
+		{name: "Wrong key", remoteAddr: "192.0.2.1:1234", header: headerAPIKey, value: "guess", expectedStatus: http.StatusForbidden},
+		{name: "Forwarded address", remoteAddr: "192.0.2.1:1234", header: echo.HeaderXForwardedFor, value: "10.1.2.3", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/items/test/item1", nil)
+			req.RemoteAddr = test.remoteAddr
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			handler := guard.Middleware(func(c echo.Context) error { return c.NoContent(http.StatusOK) })
+			require.NoError(t, handler(c))
+			assert.Equal(t, test.expectedStatus, rec.Code)
+		})
+	}
+}
+
+func TestLoadWriteGuard(t *testing.T) {
+	guard, err := loadWriteGuard()
+	require.NoError(t, err)
+	assert.Nil(t, guard)
+
+	t.Setenv("WRITES_ENABLED", "true")
+	_, err = loadWriteGuard()
+	assert.Error(t, err)
+
+	t.Setenv("WRITE_ALLOWED_CIDRS", "not-a-network")
+	_, err = loadWriteGuard()
+	assert.Error(t, err)
+
+	t.Setenv("WRITE_ALLOWED_CIDRS", "10.0.0.0/8, 192.168.0.0/16")
+	guard, err = loadWriteGuard()
+	require.NoError(t, err)
+	assert.Len(t, guard.networks, 2)
+}
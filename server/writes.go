@@ -0,0 +1,384 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/labstack/echo/v4"
+)
+
+// itemMaxBodyBytes limits the size of the body of an item write. DynamoDB items are at most 400 KB,
+// which their JSON can exceed.
+var itemMaxBodyBytes int64 = 1 << 20
+
+// WriteResponse reports an item before and/or after a write
+type WriteResponse struct {
+	Old map[string]interface{} `json:",omitempty"`
+	New map[string]interface{} `json:",omitempty"`
+}
+
+// ConflictResponse is the error response of a write whose condition doesn't hold, with the item as
+// currently stored
+type ConflictResponse struct {
+	Error   ErrorDetail            `json:"error"`
+	Current map[string]interface{} `json:",omitempty"`
+}
+
+// itemKey builds the primary key of an item from the path parameters
+func itemKey(c echo.Context) map[string]types.AttributeValue {
+	return primaryKey(c.Param("pk"), c.Param("sk"))
+}
+
+// readItemBody decodes a JSON object from the request body, keeping numbers exact. Bodies larger than
+// itemMaxBodyBytes are rejected before they are read in full.
+func readItemBody(c echo.Context) (map[string]interface{}, *requestError) {
+	decoder := json.NewDecoder(http.MaxBytesReader(c.Response(), c.Request().Body, itemMaxBodyBytes))
+	decoder.UseNumber()
+
+	var body map[string]interface{}
+	err := decoder.Decode(&body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, &requestError{status: http.StatusRequestEntityTooLarge, message: "Request body too large"}
+	}
+	if err == nil && body == nil {
+		err = errors.New("body must be a JSON object")
+	}
+	if err != nil {
+		return nil, &requestError{status: http.StatusBadRequest, message: "Invalid request body", err: err}
+	}
+	return exactNumbers(body).(map[string]interface{}), nil
+}
+
+// exactNumbers replaces json.Number values so they are stored as DynamoDB numbers without rounding
+func exactNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		return attributevalue.Number(val)
+	case map[string]interface{}:
+		for k, e := range val {
+			val[k] = exactNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range val {
+			val[i] = exactNumbers(e)
+		}
+	}
+	return v
+}
+
+// writeConditions combines the condition parameter with the If-Match version check. It also returns the
+// version If-Match expects, or "" when it doesn't name one.
+func writeConditions(c echo.Context) ([]Condition, string, *requestError) {
+	conditions, err := parseConditions(c.QueryParam("condition"))
+	if err != nil {
+		return nil, "", &requestError{status: http.StatusBadRequest, message: "Invalid condition parameter", err: err}
+	}
+
+	versionConds, expected, err := versionConditions(c.Request().Header.Get(headerIfMatch))
+	if err != nil {
+		return nil, "", &requestError{status: http.StatusBadRequest, message: "Invalid If-Match header", err: err}
+	}
+	return append(conditions, versionConds...), expected, nil
+}
+
+// conditionExpression renders the conditions, or returns nil when there are none
+func conditionExpression(b *expressionBuilder, conditions []Condition) *string {
+	if len(conditions) == 0 {
+		return nil
+	}
+	return aws.String(b.and(conditions))
+}
+
+// isConditionFailure reports whether a write was rejected by its condition expression
+func isConditionFailure(err error) bool {
+	var ccf *types.ConditionalCheckFailedException
+	return errors.As(err, &ccf)
+}
+
+// writeFailed turns a failed write into a response. Rejected conditions return 409 with the current
+// item (412 when it isn't at the expected version), or 404 when the item doesn't exist.
+func (h *Handler) writeFailed(c echo.Context, err error, expectedVersion string) error {
+	if !isConditionFailure(err) {
+		reqErr := dynamoError("Error in DynamoDB write", err)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+
+	current, getErr := h.client.GetItem(c.Request().Context(), &dynamodb.GetItemInput{TableName: &tableName, Key: itemKey(c)})
+	if getErr != nil {
+		reqErr := dynamoError("Error in DynamoDB query", getErr)
+		c.Logger().Error(reqErr)
+		return reqErr.respond(c)
+	}
+	if len(current.Item) == 0 {
+		return respondError(c, http.StatusNotFound, "Item not found")
+	}
+
+	item, decodeErr := decodeAttributes(current.Item, h.numbersAsStrings)
+	if decodeErr != nil {
+		c.Logger().Error(decodeErr)
+		return respondError(c, http.StatusInternalServerError, "Error unmarshalling DynamoDB item")
+	}
+	h.exposeAttributes(item)
+	setETag(c, current.Item)
+	if expectedVersion != "" && storedVersion(current.Item) != expectedVersion {
+		return c.JSON(http.StatusPreconditionFailed, ConflictResponse{Error: errorDetail(c, http.StatusPreconditionFailed, "", "Version mismatch"), Current: item})
+	}
+	return c.JSON(http.StatusConflict, ConflictResponse{Error: errorDetail(c, http.StatusConflict, "", "Condition check failed"), Current: item})
+}
+
+// writeSucceeded responds with the old and new item images
+func (h *Handler) writeSucceeded(c echo.Context, oldItem, newItem map[string]types.AttributeValue) error {
+	return h.writeSucceededStatus(c, http.StatusOK, oldItem, newItem)
+}
+
+// writeSucceededStatus is writeSucceeded with another status than 200
+func (h *Handler) writeSucceededStatus(c echo.Context, status int, oldItem, newItem map[string]types.AttributeValue) error {
+	h.invalidate(c.Request().Context(), tableName, c.Param("pk"))
+	var res WriteResponse
+	var err error
+	if res.Old, err = decodeAttributes(oldItem, h.numbersAsStrings); err == nil {
+		res.New, err = decodeAttributes(newItem, h.numbersAsStrings)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+		return respondError(c, http.StatusInternalServerError, "Error unmarshalling DynamoDB item")
+	}
+	h.exposeAttributes(res.Old)
+	h.exposeAttributes(res.New)
+	if newItem != nil {
+		setETag(c, newItem)
+	}
+	return c.JSON(status, res)
+}
+
+// handleCreateItem creates an item named by the key attributes of the body, failing with 409 and
+// the stored item when one already has its key
+func (h *Handler) handleCreateItem(c echo.Context) error {
+	body, reqErr := readItemBody(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	pk, _ := body[tableKeys.PartitionKey].(string)
+	sk, _ := body[tableKeys.SortKey].(string)
+	if tableKeys.SortKey == "" {
+		if pk == "" {
+			return respondError(c, http.StatusBadRequest, fmt.Sprintf("The body needs a %s string", tableKeys.PartitionKey))
+		}
+	} else if pk == "" || sk == "" {
+		return respondError(c, http.StatusBadRequest, fmt.Sprintf("The body needs %s and %s strings", tableKeys.PartitionKey, tableKeys.SortKey))
+	}
+	if c.QueryParam("condition") != "" || c.Request().Header.Get(headerIfMatch) != "" {
+		return respondError(c, http.StatusBadRequest, "Creates only take the condition that the item doesn't exist; use PUT /items/:pk/:sk")
+	}
+
+	item, err := attributevalue.MarshalMap(body)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+	item[versionAttribute] = &types.AttributeValueMemberN{Value: nextVersion("0")}
+
+	// The rest of the write path, like PUT, finds the item by its path parameters
+	c.SetParamNames("pk", "sk")
+	c.SetParamValues(pk, sk)
+
+	b := newExpressionBuilder()
+	condition := conditionExpression(b, []Condition{{Attribute: tableKeys.PartitionKey, Op: "not_exists"}})
+	if _, err := h.client.PutItem(c.Request().Context(), &dynamodb.PutItemInput{
+		TableName:                 &tableName,
+		Item:                      item,
+		ConditionExpression:       condition,
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+	}); err != nil {
+		return h.writeFailed(c, err, "")
+	}
+	h.estimator.adjustCount(pk, 1)
+
+	location := "/items/" + url.PathEscape(h.exposeKey(PartitionKeyPosition, pk))
+	if tableKeys.SortKey != "" {
+		location += "/" + url.PathEscape(h.exposeKey(SortKeyPosition, sk))
+	}
+	c.Response().Header().Set(echo.HeaderLocation, location)
+	return h.writeSucceededStatus(c, http.StatusCreated, nil, item)
+}
+
+// handlePutItem creates or replaces an item, returning the previous version
+func (h *Handler) handlePutItem(c echo.Context) error {
+	body, reqErr := readItemBody(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	item, err := attributevalue.MarshalMap(body)
+	if err != nil {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+	for name, value := range itemKey(c) {
+		item[name] = value
+	}
+
+	conditions, expectedVersion, reqErr := writeConditions(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if expectedVersion == "" {
+		// A PutItem can't compute the version, so read it and make sure it doesn't change before the put
+		current, err := h.client.GetItem(c.Request().Context(), &dynamodb.GetItemInput{
+			TableName:                &tableName,
+			Key:                      itemKey(c),
+			ProjectionExpression:     aws.String("#v"),
+			ExpressionAttributeNames: map[string]string{"#v": versionAttribute},
+			ConsistentRead:           aws.Bool(true),
+		})
+		if err != nil {
+			reqErr := dynamoError("Error in DynamoDB query", err)
+			c.Logger().Error(reqErr)
+			return reqErr.respond(c)
+		}
+		expectedVersion = storedVersion(current.Item)
+		if _, ok := current.Item[versionAttribute]; ok {
+			conditions = append(conditions, Condition{Attribute: versionAttribute, Op: "eq", Value: current.Item[versionAttribute]})
+		} else {
+			conditions = append(conditions, Condition{Attribute: versionAttribute, Op: "not_exists"})
+		}
+	}
+	item[versionAttribute] = &types.AttributeValueMemberN{Value: nextVersion(expectedVersion)}
+
+	b := newExpressionBuilder()
+	out, err := h.client.PutItem(c.Request().Context(), &dynamodb.PutItemInput{
+		TableName:                 &tableName,
+		Item:                      item,
+		ConditionExpression:       conditionExpression(b, conditions),
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+		ReturnValues:              types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return h.writeFailed(c, err, expectedVersion)
+	}
+	if len(out.Attributes) == 0 {
+		h.estimator.adjustCount(c.Param("pk"), 1)
+	}
+
+	return h.writeSucceeded(c, out.Attributes, item)
+}
+
+// handlePatchItem updates attributes of an existing item. Attributes set to null are removed.
+func (h *Handler) handlePatchItem(c echo.Context) error {
+	body, reqErr := readItemBody(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+	if len(body) == 0 {
+		return respondError(c, http.StatusBadRequest, "Invalid request body")
+	}
+
+	conditions, expectedVersion, reqErr := writeConditions(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	key := itemKey(c)
+	b := newExpressionBuilder()
+
+	var set, remove []string
+	for _, name := range sortedNames(body) {
+		if _, isKey := key[name]; isKey {
+			return respondError(c, http.StatusBadRequest, "Key attributes can't be updated")
+		}
+		if name == versionAttribute {
+			return respondError(c, http.StatusBadRequest, "The version attribute is managed by the service")
+		}
+		if body[name] == nil {
+			remove = append(remove, b.name(name))
+			continue
+		}
+		value, err := attributevalue.Marshal(body[name])
+		if err != nil {
+			return respondError(c, http.StatusBadRequest, "Invalid request body")
+		}
+		set = append(set, b.name(name)+" = "+b.value(value))
+	}
+	set = append(set, versionUpdate(b))
+
+	var update []string
+	if len(set) > 0 {
+		update = append(update, "SET "+strings.Join(set, ", "))
+	}
+	if len(remove) > 0 {
+		update = append(update, "REMOVE "+strings.Join(remove, ", "))
+	}
+
+	// Only update items that exist, PUT is used to create them
+	conditions = append([]Condition{{Attribute: tableKeys.PartitionKey, Op: "exists"}}, conditions...)
+
+	returnValues := types.ReturnValueAllNew
+	if c.QueryParam("return") == "old" {
+		returnValues = types.ReturnValueAllOld
+	}
+
+	out, err := h.client.UpdateItem(c.Request().Context(), &dynamodb.UpdateItemInput{
+		TableName:                 &tableName,
+		Key:                       key,
+		UpdateExpression:          aws.String(strings.Join(update, " ")),
+		ConditionExpression:       aws.String(b.and(conditions)),
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+		ReturnValues:              returnValues,
+	})
+	if err != nil {
+		return h.writeFailed(c, err, expectedVersion)
+	}
+
+	if returnValues == types.ReturnValueAllOld {
+		return h.writeSucceeded(c, out.Attributes, nil)
+	}
+	return h.writeSucceeded(c, nil, out.Attributes)
+}
+
+// handleDeleteItem deletes an item, returning the deleted version
+func (h *Handler) handleDeleteItem(c echo.Context) error {
+	conditions, expectedVersion, reqErr := writeConditions(c)
+	if reqErr != nil {
+		return reqErr.respond(c)
+	}
+
+	b := newExpressionBuilder()
+	out, err := h.client.DeleteItem(c.Request().Context(), &dynamodb.DeleteItemInput{
+		TableName:                 &tableName,
+		Key:                       itemKey(c),
+		ConditionExpression:       conditionExpression(b, conditions),
+		ExpressionAttributeNames:  b.attributeNames(),
+		ExpressionAttributeValues: b.attributeValues(),
+		ReturnValues:              types.ReturnValueAllOld,
+	})
+	if err != nil {
+		return h.writeFailed(c, err, expectedVersion)
+	}
+	if len(out.Attributes) == 0 {
+		return respondError(c, http.StatusNotFound, "Item not found")
+	}
+	h.estimator.adjustCount(c.Param("pk"), -1)
+
+	return h.writeSucceeded(c, out.Attributes, nil)
+}
+
+func sortedNames(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
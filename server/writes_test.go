@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newItemContext(method, query, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(method, "/items/test/item1?"+query, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("pk", "sk")
+	c.SetParamValues("test", "item1")
+	return c, rec
+}
+
+func TestHandlePutItem(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{}, nil)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "test"},
+			"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+			"count":    &types.AttributeValueMemberN{Value: "12345678901234567890"},
+			"version":  &types.AttributeValueMemberN{Value: "1"},
+		}, input.Item) && assert.Equal(t, "attribute_not_exists(#n0) AND attribute_not_exists(#n1)", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]string{"#n0": "sort_key", "#n1": "version"}, input.ExpressionAttributeNames)
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	c, rec := newItemContext(http.MethodPut, "condition=sort_key:not_exists", `{"count": 12345678901234567890}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePutItem(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response WriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Nil(t, response.Old)
+	assert.Equal(t, "item1", response.New["sort_key"])
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePatchItem(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("UpdateItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.UpdateItemInput) bool {
+		return assert.Equal(t, "SET #n0 = :v0, #n2 = if_not_exists(#n2, :v1) + :v2 REMOVE #n1", *input.UpdateExpression) &&
+			assert.Equal(t, "attribute_exists(#n3) AND #n2 = :v3", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]string{"#n0": "status", "#n1": "tmp", "#n2": "version", "#n3": "key_cond"}, input.ExpressionAttributeNames) &&
+			assert.Equal(t, map[string]types.AttributeValue{
+				":v0": &types.AttributeValueMemberS{Value: "active"},
+				":v1": &types.AttributeValueMemberN{Value: "0"},
+				":v2": &types.AttributeValueMemberN{Value: "1"},
+				":v3": &types.AttributeValueMemberN{Value: "2"},
+			}, input.ExpressionAttributeValues)
+	})).Return(&dynamodb.UpdateItemOutput{Attributes: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"status":   &types.AttributeValueMemberS{Value: "active"},
+	}}, nil)
+
+	c, rec := newItemContext(http.MethodPatch, "condition=version:eq:2", `{"status": "active", "tmp": null}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handlePatchItem(c))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var response WriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "active", response.New["status"])
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandlePatchItemRejectsKeyUpdate(t *testing.T) {
+	c, rec := newItemContext(http.MethodPatch, "", `{"sort_key": "item2"}`)
+	handler := &Handler{client: new(MockDynamoDB)}
+	require.NoError(t, handler.handlePatchItem(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestWriteBodyTooLarge(t *testing.T) {
+	defer func(limit int64) { itemMaxBodyBytes = limit }(itemMaxBodyBytes)
+	itemMaxBodyBytes = 16
+	handler := &Handler{client: new(MockDynamoDB)}
+	body := `{"status": "` + strings.Repeat("a", 32) + `"}`
+
+	for method, handle := range map[string]echo.HandlerFunc{http.MethodPut: handler.handlePutItem, http.MethodPatch: handler.handlePatchItem} {
+		c, rec := newItemContext(method, "", body)
+		require.NoError(t, handle(c))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code, method)
+	}
+	c, rec := newCreateContext("", body)
+	require.NoError(t, handler.handleCreateItem(c))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	c, rec = newItemContext(http.MethodPut, "", `null`)
+	require.NoError(t, handler.handlePutItem(c))
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "bodies within the limit are read")
+}
+
+func TestHandleDeleteItemConditionFailed(t *testing.T) {
+	current := map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"status":   &types.AttributeValueMemberS{Value: "active"},
+	}
+
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DeleteItem", mock.Anything, mock.Anything).Return((*dynamodb.DeleteItemOutput)(nil), &types.ConditionalCheckFailedException{})
+	mockDynamoDB.On("GetItem", mock.Anything, mock.Anything).Return(&dynamodb.GetItemOutput{Item: current}, nil)
+
+	c, rec := newItemContext(http.MethodDelete, "condition=status:eq:inactive", "")
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleDeleteItem(c))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var response ConflictResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, "conflict", response.Error.Code)
+	assert.Equal(t, "active", response.Current["status"])
+}
+
+func TestHandleDeleteItemNotFound(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("DeleteItem", mock.Anything, mock.Anything).Return(&dynamodb.DeleteItemOutput{}, nil)
+
+	c, rec := newItemContext(http.MethodDelete, "", "")
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleDeleteItem(c))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func newCreateContext(query, body string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/items?"+query, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func TestHandleCreateItem(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "test"},
+			"sort_key": &types.AttributeValueMemberS{Value: "item 1"},
+			"status":   &types.AttributeValueMemberS{Value: "active"},
+			"version":  &types.AttributeValueMemberN{Value: "1"},
+		}, input.Item) && assert.Equal(t, "attribute_not_exists(#n0)", *input.ConditionExpression) &&
+			assert.Equal(t, map[string]string{"#n0": "key_cond"}, input.ExpressionAttributeNames)
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	c, rec := newCreateContext("", `{"key_cond": "test", "sort_key": "item 1", "status": "active"}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleCreateItem(c))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/items/test/item%201", rec.Header().Get(echo.HeaderLocation))
+	var response WriteResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Nil(t, response.Old)
+	assert.Equal(t, "active", response.New["status"])
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandleCreateItemExists(t *testing.T) {
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.Anything).Return((*dynamodb.PutItemOutput)(nil), &types.ConditionalCheckFailedException{})
+	mockDynamoDB.On("GetItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.GetItemInput) bool {
+		return assert.Equal(t, &types.AttributeValueMemberS{Value: "item1"}, input.Key["sort_key"])
+	})).Return(&dynamodb.GetItemOutput{Item: map[string]types.AttributeValue{
+		"key_cond": &types.AttributeValueMemberS{Value: "test"},
+		"sort_key": &types.AttributeValueMemberS{Value: "item1"},
+		"version":  &types.AttributeValueMemberN{Value: "3"},
+	}}, nil)
+
+	c, rec := newCreateContext("", `{"key_cond": "test", "sort_key": "item1"}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleCreateItem(c))
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	var response ConflictResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+	assert.Equal(t, float64(3), response.Current["version"])
+	mockDynamoDB.AssertExpectations(t)
+}
+
+func TestHandleCreateItemInvalid(t *testing.T) {
+	for _, tc := range []struct{ query, body string }{
+		{"", `{"key_cond": "test"}`},
+		{"", `{"key_cond": "test", "sort_key": 1}`},
+		{"condition=status:eq:active", `{"key_cond": "test", "sort_key": "item1"}`},
+	} {
+		c, rec := newCreateContext(tc.query, tc.body)
+		handler := &Handler{client: new(MockDynamoDB)}
+		require.NoError(t, handler.handleCreateItem(c))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, tc.body)
+	}
+}
+
+func TestHandleCreateItemWithoutSortKey(t *testing.T) {
+	withTableConfig(t)
+	ConfigureTable(Options{NoSortKey: true})
+	mockDynamoDB := new(MockDynamoDB)
+	mockDynamoDB.On("PutItem", mock.Anything, mock.MatchedBy(func(input *dynamodb.PutItemInput) bool {
+		return assert.Equal(t, map[string]types.AttributeValue{
+			"key_cond": &types.AttributeValueMemberS{Value: "alice"},
+			"status":   &types.AttributeValueMemberS{Value: "active"},
+			"version":  &types.AttributeValueMemberN{Value: "1"},
+		}, input.Item)
+	})).Return(&dynamodb.PutItemOutput{}, nil)
+
+	c, rec := newCreateContext("", `{"key_cond": "alice", "status": "active"}`)
+	handler := &Handler{client: mockDynamoDB}
+	require.NoError(t, handler.handleCreateItem(c))
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/items/alice", rec.Header().Get(echo.HeaderLocation))
+	mockDynamoDB.AssertExpectations(t)
+}